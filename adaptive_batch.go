@@ -0,0 +1,178 @@
+package lbug
+
+import (
+	"fmt"
+	"time"
+)
+
+// The defaults of AdaptiveBatching.
+const (
+	defaultTargetBatchLatency = 250 * time.Millisecond
+	defaultMinBatchSize       = 16
+	defaultMaxBatchSize       = 100000
+	defaultMaxBatchBytes      = 64 << 20
+)
+
+// AdaptiveBatching configures the adaptive sizing of the batches inserted by
+// CopyTables and Connection.CreateRels. The first batch is small, and the
+// size of every following batch is derived from the latency of the previous
+// one, so that batches take about TargetLatency to insert: it at most doubles
+// or halves from a batch to the next, and is reduced so that the estimated
+// memory held by the rows of a batch stays under MaxBatchBytes. Wide rows thus
+// get small batches and narrow rows large ones, without a size fitting both
+// having to be guessed.
+type AdaptiveBatching struct {
+	// TargetLatency is the time a batch should take to insert. If it is
+	// zero, 250ms is used.
+	TargetLatency time.Duration
+	// MinBatchSize and MaxBatchSize bound the number of rows of a batch. If
+	// they are zero, 16 and 100000 are used. The first batch has
+	// MinBatchSize rows, or the BatchSize of the options if it is set.
+	MinBatchSize int
+	MaxBatchSize int
+	// MaxBatchBytes bounds the memory held by the rows of a batch, as
+	// estimated from the sizes of their values, before they are bound. If it
+	// is zero, 64 MiB is used.
+	MaxBatchBytes uint64
+}
+
+// BatchReport describes a batch of rows inserted by an ingestion helper.
+type BatchReport struct {
+	// Table is the table the rows were inserted into.
+	Table string
+	// Rows is the number of rows of the batch.
+	Rows int
+	// Bytes is the estimated memory held by the rows of the batch. It is
+	// only estimated with AdaptiveBatching, and zero otherwise.
+	Bytes uint64
+	// Latency is the time the batch took to insert.
+	Latency time.Duration
+	// NextSize is the batch size chosen for the following batches.
+	NextSize int
+}
+
+// IngestionReport reports the batches inserted by an ingestion helper, in
+// the order they were inserted.
+type IngestionReport struct {
+	Batches []BatchReport
+}
+
+// BatchSizes returns the numbers of rows of the batches.
+func (report *IngestionReport) BatchSizes() []int {
+	sizes := make([]int, len(report.Batches))
+	for i, batch := range report.Batches {
+		sizes[i] = batch.Rows
+	}
+	return sizes
+}
+
+// batchSizer chooses the sizes of the batches of an ingestion helper and
+// reports them.
+type batchSizer struct {
+	adaptive *AdaptiveBatching
+	clock    func() time.Time
+	size     int
+	table    string
+	report   *IngestionReport
+	start    time.Time
+}
+
+// newBatchSizer returns the sizer of the batches inserted into the table
+// through the connection, whose clock measures the latency of the batches, of
+// the fixed size if adaptive is nil. The report may be nil.
+func newBatchSizer(conn *Connection, size int, adaptive *AdaptiveBatching, table string, report *IngestionReport) *batchSizer {
+	sizer := &batchSizer{clock: conn.now, size: size, table: table, report: report}
+	if adaptive != nil {
+		options := adaptive.withDefaults()
+		sizer.adaptive = &options
+		if sizer.size <= 0 {
+			sizer.size = options.MinBatchSize
+		}
+		sizer.size = max(options.MinBatchSize, min(sizer.size, options.MaxBatchSize))
+	}
+	return sizer
+}
+
+// validate returns an error if the options are invalid.
+func (adaptive *AdaptiveBatching) validate() error {
+	if adaptive == nil {
+		return nil
+	}
+	if adaptive.TargetLatency < 0 || adaptive.MinBatchSize < 0 || adaptive.MaxBatchSize < 0 {
+		return fmt.Errorf("invalid adaptive batching %+v: must not be negative", *adaptive)
+	}
+	options := adaptive.withDefaults()
+	if options.MinBatchSize > options.MaxBatchSize {
+		return fmt.Errorf("invalid adaptive batching: minimum batch size %d is greater than the maximum %d", options.MinBatchSize, options.MaxBatchSize)
+	}
+	return nil
+}
+
+// withDefaults returns the options with the defaults of the unset fields.
+func (adaptive AdaptiveBatching) withDefaults() AdaptiveBatching {
+	if adaptive.TargetLatency == 0 {
+		adaptive.TargetLatency = defaultTargetBatchLatency
+	}
+	if adaptive.MinBatchSize == 0 {
+		adaptive.MinBatchSize = defaultMinBatchSize
+	}
+	if adaptive.MaxBatchSize == 0 {
+		adaptive.MaxBatchSize = max(defaultMaxBatchSize, adaptive.MinBatchSize)
+	}
+	if adaptive.MaxBatchBytes == 0 {
+		adaptive.MaxBatchBytes = defaultMaxBatchBytes
+	}
+	return adaptive
+}
+
+// rowBytes returns the estimated memory held by a row of a batch, or zero if
+// the batches have a fixed size.
+func (sizer *batchSizer) rowBytes(row map[string]any) uint64 {
+	if sizer.adaptive == nil {
+		return 0
+	}
+	return valueMemoryUsage(row)
+}
+
+// full returns true if a batch of the rows and estimated bytes must be
+// inserted.
+func (sizer *batchSizer) full(rows int, bytes uint64) bool {
+	return rows >= sizer.size || sizer.adaptive != nil && bytes >= sizer.adaptive.MaxBatchBytes
+}
+
+// begin is called before a batch is inserted.
+func (sizer *batchSizer) begin() {
+	sizer.start = sizer.clock()
+}
+
+// done is called once a batch of the rows and estimated bytes has been
+// inserted. It reports the batch and adapts the size of the next ones.
+func (sizer *batchSizer) done(rows int, bytes uint64) {
+	latency := sizer.clock().Sub(sizer.start)
+	if sizer.adaptive != nil && rows > 0 {
+		sizer.size = sizer.adaptive.nextSize(sizer.size, rows, bytes, latency)
+	}
+	if sizer.report != nil {
+		sizer.report.Batches = append(sizer.report.Batches, BatchReport{
+			Table:    sizer.table,
+			Rows:     rows,
+			Bytes:    bytes,
+			Latency:  latency,
+			NextSize: sizer.size,
+		})
+	}
+}
+
+// nextSize returns the size of the batches following a batch of the rows and
+// estimated bytes inserted in latency, when the batch size was size.
+func (adaptive *AdaptiveBatching) nextSize(size int, rows int, bytes uint64, latency time.Duration) int {
+	next := 2 * size
+	if latency > 0 {
+		next = int(min(float64(rows)*float64(adaptive.TargetLatency)/float64(latency), float64(2*size)))
+	}
+	next = max(next, size/2)
+	if bytes > 0 {
+		next = int(min(uint64(next), adaptive.MaxBatchBytes/max(bytes/uint64(rows), 1)))
+	}
+	return max(adaptive.MinBatchSize, min(next, adaptive.MaxBatchSize))
+}
@@ -0,0 +1,144 @@
+package lbug
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchSizerAdapts(t *testing.T) {
+	now := time.Unix(0, 0)
+	conn := &Connection{}
+	conn.SetClock(ClockFunc(func() time.Time { return now }))
+	report := &IngestionReport{}
+	sizer := newBatchSizer(conn, 0, &AdaptiveBatching{TargetLatency: 100 * time.Millisecond, MaxBatchSize: 1000}, "t", report)
+	insert := func(perRow time.Duration) {
+		rows := sizer.size
+		sizer.begin()
+		now = now.Add(time.Duration(rows) * perRow)
+		sizer.done(rows, 0)
+	}
+	// Fast rows grow the batches, by at most twice per batch, up to the
+	// maximum size.
+	for i := 0; i < 8; i++ {
+		insert(time.Microsecond)
+	}
+	assert.Equal(t, []int{16, 32, 64, 128, 256, 512, 1000, 1000}, report.BatchSizes())
+	// Slow rows shrink them, by at most half per batch, to the target
+	// latency: 1ms per row gives batches of 100 rows.
+	for i := 0; i < 4; i++ {
+		insert(time.Millisecond)
+	}
+	assert.Equal(t, []int{1000, 500, 250, 125}, report.BatchSizes()[8:])
+	assert.Equal(t, 100, sizer.size)
+	assert.Equal(t, 125*time.Millisecond, report.Batches[11].Latency)
+	assert.Equal(t, "t", report.Batches[11].Table)
+}
+
+func TestBatchSizerBytes(t *testing.T) {
+	conn := &Connection{}
+	conn.SetClock(ClockFunc(func() time.Time { return time.Unix(0, 0) }))
+	adaptive := &AdaptiveBatching{MaxBatchBytes: 1 << 20}
+	sizer := newBatchSizer(conn, 100, adaptive, "t", nil)
+	row := map[string]any{"c0": strings.Repeat("x", 64<<10)}
+	bytes := sizer.rowBytes(row)
+	assert.Greater(t, bytes, uint64(64<<10))
+	assert.False(t, sizer.full(15, 15*bytes))
+	assert.True(t, sizer.full(16, 16*bytes))
+	// The next batches hold at most MaxBatchBytes of such rows.
+	sizer.begin()
+	sizer.done(16, 16*bytes)
+	assert.Equal(t, defaultMinBatchSize, sizer.size)
+
+	// Fixed batches do not estimate the rows.
+	fixed := newBatchSizer(conn, 100, nil, "t", nil)
+	assert.Equal(t, uint64(0), fixed.rowBytes(row))
+	fixed.begin()
+	fixed.done(100, 0)
+	assert.Equal(t, 100, fixed.size)
+}
+
+func TestAdaptiveBatchingValidate(t *testing.T) {
+	assert.Nil(t, (*AdaptiveBatching)(nil).validate())
+	assert.Nil(t, (&AdaptiveBatching{}).validate())
+	assert.NotNil(t, (&AdaptiveBatching{TargetLatency: -time.Second}).validate())
+	assert.NotNil(t, (&AdaptiveBatching{MinBatchSize: 10, MaxBatchSize: 5}).validate())
+}
+
+func TestCopyTablesAdaptive(t *testing.T) {
+	src := openCopyTestConnection(t)
+	dst := openCopyTestConnection(t)
+	// Every batch takes 10ms, under the target latency, so the batches grow.
+	now := time.Unix(0, 0)
+	dst.SetClock(ClockFunc(func() time.Time {
+		now = now.Add(5 * time.Millisecond)
+		return now
+	}))
+	mustRun(t, src, "CREATE NODE TABLE person(name STRING, PRIMARY KEY(name));")
+	for i := 0; i < 26; i++ {
+		mustRun(t, src, fmt.Sprintf("CREATE (:person {name: 'p%d'});", i))
+	}
+	report := &IngestionReport{}
+	counts, err := CopyTables(src, dst, nil, CopyTablesOptions{
+		Adaptive: &AdaptiveBatching{MinBatchSize: 2, MaxBatchSize: 8},
+		Report:   report,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]uint64{"person": 26}, counts)
+	assert.Equal(t, []int{2, 4, 8, 8, 4}, report.BatchSizes())
+	assert.Equal(t, 10*time.Millisecond, report.Batches[0].Latency)
+	assert.Greater(t, report.Batches[0].Bytes, uint64(0))
+
+	_, err = CopyTables(src, dst, nil, CopyTablesOptions{DropExisting: true, Adaptive: &AdaptiveBatching{MinBatchSize: -1}})
+	assert.NotNil(t, err)
+}
+
+// BenchmarkCopyTablesSkewed copies rows whose widths vary from a few bytes to
+// 64 KiB with fixed batch sizes and with adaptive batching.
+func BenchmarkCopyTablesSkewed(b *testing.B) {
+	open := func() *Connection {
+		db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(db.Close)
+		conn, err := OpenConnection(db)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(conn.Close)
+		return conn
+	}
+	src := open()
+	if err := runStatement(src, "CREATE NODE TABLE doc(id INT64, body STRING, PRIMARY KEY(id));"); err != nil {
+		b.Fatal(err)
+	}
+	if err := runStatement(src, "UNWIND range(0, 19999) AS i CREATE (:doc {id: i, body: CASE WHEN i % 100 = 0 THEN lpad('x', 65536, 'x') ELSE 'x' END});"); err != nil {
+		b.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		opts CopyTablesOptions
+	}{
+		{"Fixed10", CopyTablesOptions{BatchSize: 10}},
+		{"Fixed100", CopyTablesOptions{BatchSize: 100}},
+		{"Fixed1000", CopyTablesOptions{BatchSize: 1000}},
+		{"Fixed10000", CopyTablesOptions{BatchSize: 10000}},
+		{"Adaptive", CopyTablesOptions{Adaptive: &AdaptiveBatching{}}},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			dst := open()
+			opts := c.opts
+			opts.DropExisting = true
+			for i := 0; i < b.N; i++ {
+				if _, err := CopyTables(src, dst, nil, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
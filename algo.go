@@ -0,0 +1,239 @@
+package lbug
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrExtensionNotLoaded is returned by the graph algorithm wrappers and the
+// index helpers when the procedures of the extension they rely on are not
+// available on the connection.
+var ErrExtensionNotLoaded = errors.New("extension is not loaded")
+
+// NodeScore is a node with the score computed for it by an algorithm, e.g.
+// its rank.
+type NodeScore struct {
+	Node  Node
+	Score float64
+}
+
+// NodeComponent is a node with the ID of the component it belongs to.
+type NodeComponent struct {
+	Node      Node
+	Component int64
+}
+
+// PageRankOptions configures Connection.PageRank. The zero values select the
+// defaults of the engine.
+type PageRankOptions struct {
+	// RelTables are the relationship tables of the graph the ranks are
+	// computed on. If it is empty, all relationship tables from and to the
+	// node table are used.
+	RelTables     []string
+	DampingFactor float64
+	MaxIterations int
+	Tolerance     float64
+}
+
+// ComponentOptions configures Connection.WeaklyConnectedComponents.
+type ComponentOptions struct {
+	// RelTables are the relationship tables of the graph the components are
+	// computed on. If it is empty, all relationship tables from and to the
+	// node table are used.
+	RelTables []string
+}
+
+// ShortestPath returns the shortest paths from the node with the ID from to
+// the node with the ID to along relationships of the table, with at most
+// maxHops relationships. All paths of the shortest length are returned, and
+// no path if to is not reachable. It does not need the algo extension.
+func (conn *Connection) ShortestPath(from, to InternalID, relTable string, maxHops int) ([]RecursiveRelationship, error) {
+	if maxHops < 1 {
+		return nil, fmt.Errorf("invalid maximum number of hops %d: must be positive", maxHops)
+	}
+	query := fmt.Sprintf(
+		"MATCH (a)-[r:%s* ALL SHORTEST 1..%d]->(b) WHERE CAST(id(a) AS STRING) = $from AND CAST(id(b) AS STRING) = $to RETURN r;",
+		QuoteIdentifier(relTable), maxHops)
+	statement, err := conn.Prepare(query)
+	defer statement.Close()
+	if err != nil {
+		return nil, err
+	}
+	result, err := conn.Execute(statement, map[string]any{
+		"from": internalIDString(from),
+		"to":   internalIDString(to),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	var paths []RecursiveRelationship
+	for result.HasNext() {
+		tuple, err := result.Next()
+		if err != nil {
+			return nil, err
+		}
+		value, err := tuple.GetValue(0)
+		tuple.Close()
+		if err != nil {
+			return nil, err
+		}
+		path, ok := value.(RecursiveRelationship)
+		if !ok {
+			return nil, fmt.Errorf("shortest path query returned %T", value)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// internalIDString formats an internal ID as the engine casts it to a string.
+func internalIDString(id InternalID) string {
+	return fmt.Sprintf("%d:%d", id.TableID, id.Offset)
+}
+
+// PageRank computes the PageRank of the nodes of the table with the page_rank
+// procedure of the algo extension and returns them by decreasing score. It
+// returns an error matching ErrExtensionNotLoaded if the extension is not
+// loaded.
+func (conn *Connection) PageRank(nodeTable string, opts PageRankOptions) ([]NodeScore, error) {
+	var args []string
+	if opts.DampingFactor != 0 {
+		args = append(args, fmt.Sprintf("dampingFactor := %v", opts.DampingFactor))
+	}
+	if opts.MaxIterations != 0 {
+		args = append(args, fmt.Sprintf("maxIterations := %d", opts.MaxIterations))
+	}
+	if opts.Tolerance != 0 {
+		args = append(args, fmt.Sprintf("tolerance := %v", opts.Tolerance))
+	}
+	rows, err := conn.runAlgorithm("page_rank", nodeTable, opts.RelTables, args, "rank")
+	if err != nil {
+		return nil, err
+	}
+	scores := make([]NodeScore, 0, len(rows))
+	for _, row := range rows {
+		node, _ := row[0].(Node)
+		rank, ok := row[1].(float64)
+		if !ok {
+			return nil, fmt.Errorf("page_rank returned a rank of type %T", row[1])
+		}
+		scores = append(scores, NodeScore{Node: node, Score: rank})
+	}
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].Score > scores[j].Score })
+	return scores, nil
+}
+
+// WeaklyConnectedComponents computes the weakly connected components of the
+// nodes of the table with the weakly_connected_components procedure of the
+// algo extension. It returns an error matching ErrExtensionNotLoaded if the
+// extension is not loaded.
+func (conn *Connection) WeaklyConnectedComponents(nodeTable string, opts ComponentOptions) ([]NodeComponent, error) {
+	rows, err := conn.runAlgorithm("weakly_connected_components", nodeTable, opts.RelTables, nil, "group_id")
+	if err != nil {
+		return nil, err
+	}
+	components := make([]NodeComponent, 0, len(rows))
+	for _, row := range rows {
+		node, _ := row[0].(Node)
+		group, ok := row[1].(int64)
+		if !ok {
+			return nil, fmt.Errorf("weakly_connected_components returned a group of type %T", row[1])
+		}
+		components = append(components, NodeComponent{Node: node, Component: group})
+	}
+	return components, nil
+}
+
+// runAlgorithm projects a graph of the node table and the relationship tables,
+// runs the procedure of the algo extension on it and returns the node and the
+// result column of every row. The projected graph is dropped before
+// runAlgorithm returns.
+func (conn *Connection) runAlgorithm(procedure string, nodeTable string, relTables []string, args []string, column string) ([][]any, error) {
+	if len(relTables) == 0 {
+		var err error
+		relTables, err = conn.relTablesBetween(nodeTable)
+		if err != nil {
+			return nil, err
+		}
+		if len(relTables) == 0 {
+			return nil, fmt.Errorf("no relationship table connects nodes of table %s", nodeTable)
+		}
+	}
+	graph, err := newTempTableName("graph")
+	if err != nil {
+		return nil, err
+	}
+	quotedRelTables := make([]string, len(relTables))
+	for i, table := range relTables {
+		quotedRelTables[i] = quoteStringLiteral(table)
+	}
+	project := fmt.Sprintf("CALL project_graph(%s, [%s], [%s]);",
+		quoteStringLiteral(graph), quoteStringLiteral(nodeTable), strings.Join(quotedRelTables, ", "))
+	if err := runStatement(conn, project); err != nil {
+		return nil, extensionError("algo", "project_graph", err)
+	}
+	defer runStatement(conn, fmt.Sprintf("CALL drop_projected_graph(%s);", quoteStringLiteral(graph)))
+
+	call := append([]string{quoteStringLiteral(graph)}, args...)
+	result, err := conn.Query(fmt.Sprintf("CALL %s(%s) RETURN node, %s;", procedure, strings.Join(call, ", "), column))
+	if err != nil {
+		return nil, extensionError("algo", procedure, err)
+	}
+	defer result.Close()
+	var rows [][]any
+	for result.HasNext() {
+		tuple, err := result.Next()
+		if err != nil {
+			return nil, err
+		}
+		row, err := tuple.GetAsSlice()
+		tuple.Close()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// relTablesBetween returns the relationship tables connecting nodes of the
+// node table, sorted by name.
+func (conn *Connection) relTablesBetween(nodeTable string) ([]string, error) {
+	tables, err := conn.catalogTables()
+	if err != nil {
+		return nil, err
+	}
+	var relTables []string
+	for name, isRel := range tables {
+		if !isRel {
+			continue
+		}
+		schema, err := describeTable(conn, name, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, connection := range schema.connections {
+			if connection.from == nodeTable && connection.to == nodeTable {
+				relTables = append(relTables, name)
+				break
+			}
+		}
+	}
+	sort.Strings(relTables)
+	return relTables, nil
+}
+
+// extensionError returns an error matching ErrExtensionNotLoaded if err
+// reports that the procedure of the extension does not exist, or err
+// otherwise.
+func extensionError(extension string, procedure string, err error) error {
+	message := strings.ToLower(err.Error())
+	if strings.Contains(message, strings.ToLower(procedure)) && strings.Contains(message, "does not exist") {
+		return fmt.Errorf("%w: %s is a procedure of the %s extension, install and load it with INSTALL %s; LOAD %s;: %w",
+			ErrExtensionNotLoaded, procedure, extension, extension, extension, err)
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+//go:build algo_extension
+
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The tests in this file need the algo extension, which is downloaded by
+// INSTALL. Run them with:
+//
+//	go test -tags algo_extension -run 'PageRank|WeaklyConnected'
+
+func loadAlgoExtension(t *testing.T, conn *Connection) {
+	t.Helper()
+	assert.Nil(t, runStatement(conn, "INSTALL algo;"))
+	assert.Nil(t, runStatement(conn, "LOAD algo;"))
+}
+
+func TestPageRank(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	_, err := conn.PageRank("person", PageRankOptions{RelTables: []string{"knows"}})
+	assert.ErrorIs(t, err, ErrExtensionNotLoaded)
+
+	loadAlgoExtension(t, conn)
+	scores, err := conn.PageRank("person", PageRankOptions{RelTables: []string{"knows"}, MaxIterations: 50})
+	assert.Nil(t, err)
+	count, err := queryRows(conn, "MATCH (p:person) RETURN count(p) AS n")
+	assert.Nil(t, err)
+	assert.Equal(t, count[0]["n"], int64(len(scores)))
+	for i := 1; i < len(scores); i++ {
+		assert.GreaterOrEqual(t, scores[i-1].Score, scores[i].Score)
+	}
+	assert.Equal(t, "person", scores[0].Node.Label)
+}
+
+func TestWeaklyConnectedComponents(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	loadAlgoExtension(t, conn)
+	components, err := conn.WeaklyConnectedComponents("person", ComponentOptions{})
+	assert.Nil(t, err)
+	groups := make(map[string]int64)
+	for _, component := range components {
+		name, _ := component.Node.Properties["fName"].(string)
+		groups[name] = component.Component
+	}
+	// Alice knows Bob, so they are in the same component.
+	assert.Equal(t, groups["Alice"], groups["Bob"])
+}
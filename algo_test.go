@@ -0,0 +1,50 @@
+package lbug
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtensionError(t *testing.T) {
+	missing := &Error{Op: OpExecute, Message: "Catalog exception: function PAGE_RANK does not exist."}
+	err := extensionError("algo", "page_rank", missing)
+	assert.ErrorIs(t, err, ErrExtensionNotLoaded)
+	assert.ErrorContains(t, err, "LOAD algo")
+	var lbugErr *Error
+	assert.True(t, errors.As(err, &lbugErr))
+	assert.Equal(t, missing, lbugErr)
+
+	other := &Error{Op: OpExecute, Message: "Binder exception: table person does not exist."}
+	assert.Equal(t, error(other), extensionError("algo", "page_rank", other))
+}
+
+func TestInternalIDString(t *testing.T) {
+	assert.Equal(t, "3:42", internalIDString(InternalID{TableID: 3, Offset: 42}))
+}
+
+// personID returns the internal ID of the person with the first name.
+func personID(t *testing.T, conn *Connection, name string) InternalID {
+	t.Helper()
+	rows, err := queryRows(conn, "MATCH (p:person {fName: '"+name+"'}) RETURN id(p) AS id")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rows))
+	id, _ := rows[0]["id"].(InternalID)
+	return id
+}
+
+func TestShortestPath(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	alice := personID(t, conn, "Alice")
+	bob := personID(t, conn, "Bob")
+	paths, err := conn.ShortestPath(alice, bob, "knows", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(paths))
+	assert.Equal(t, 1, len(paths[0].Relationships))
+	assert.Equal(t, alice, paths[0].Relationships[0].SourceID)
+	assert.Equal(t, bob, paths[0].Relationships[0].DestinationID)
+
+	_, err = conn.ShortestPath(alice, bob, "knows", 0)
+	assert.ErrorContains(t, err, "must be positive")
+}
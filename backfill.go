@@ -0,0 +1,302 @@
+package lbug
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultBackfillBatchSize is the number of nodes updated per transaction by
+// AddColumnWithBackfill when BackfillOptions.BatchSize is not set.
+const defaultBackfillBatchSize = 1000
+
+// defaultWatermarkTable is the node table recording the progress of the
+// backfills when BackfillOptions.WatermarkTable is not set.
+const defaultWatermarkTable = "lbug_backfills"
+
+// beforeBackfillCommitForTesting, if set, is called by AddColumnWithBackfill
+// before the transaction of every batch is committed, with the number of the
+// batch starting at 1. If it returns an error, the transaction is rolled back.
+var beforeBackfillCommitForTesting func(batch int) error
+
+// Row is a node read by AddColumnWithBackfill, with its properties by name.
+type Row map[string]any
+
+// BackfillOptions configures AddColumnWithBackfill.
+type BackfillOptions struct {
+	// Name identifies the backfill in the watermark table. If it is empty,
+	// the table and column names joined by a dot are used.
+	Name string
+	// BatchSize is the number of nodes read and updated per transaction. If
+	// it is zero, 1000 is used.
+	BatchSize int
+	// Throttle is the time slept between two batches, so that other queries
+	// are not held up by the backfill.
+	Throttle time.Duration
+	// WatermarkTable is the node table recording the primary key of the last
+	// node of the last batch committed by every backfill, which is created
+	// on demand. If it is empty, "lbug_backfills" is used.
+	WatermarkTable string
+	// OnProgress, if set, is called after every batch has been committed.
+	OnProgress func(BackfillProgress)
+}
+
+// BackfillProgress reports the progress of AddColumnWithBackfill.
+type BackfillProgress struct {
+	// Batches is the number of batches committed by this call.
+	Batches int
+	// Rows is the number of nodes backfilled, including those backfilled
+	// before resuming.
+	Rows uint64
+	// LastKey is the primary key of the last node backfilled, or nil if no
+	// node was backfilled yet.
+	LastKey any
+	// Completed is true once every node has been backfilled.
+	Completed bool
+}
+
+// AddColumnWithBackfill adds the column to the node table and sets it in
+// batches of nodes in primary key order, each batch in its own transaction.
+// backfill receives the properties of the nodes of a batch and returns the
+// values of the column for them, in the same order; NULL values leave the
+// column unset.
+//
+// The primary key of the last node of every batch is recorded as a watermark
+// in the transaction of the batch, so a backfill that fails or whose process
+// dies is resumed by calling AddColumnWithBackfill again with the same name:
+// the column is kept if it exists with the same type, and the backfill goes
+// on after the watermark without updating the committed batches again. Once
+// the backfill has completed, calling it again does nothing.
+//
+// Nodes created during the backfill with a primary key lower than the
+// watermark are not backfilled, so writers should set the column once it has
+// been added.
+func AddColumnWithBackfill(conn *Connection, table string, column ColumnSpec, backfill func(batch []Row) []any, opts BackfillOptions) (BackfillProgress, error) {
+	var progress BackfillProgress
+	if backfill == nil {
+		return progress, fmt.Errorf("backfill of column %s has no backfill function", column.Name)
+	}
+	if opts.BatchSize < 0 || opts.Throttle < 0 {
+		return progress, fmt.Errorf("backfill of column %s: batch size and throttle must not be negative", column.Name)
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultBackfillBatchSize
+	}
+	name, err := conn.ResolveTableName(table)
+	if err != nil {
+		return progress, err
+	}
+	if opts.Name == "" {
+		opts.Name = name + "." + column.Name
+	}
+	watermarks := opts.WatermarkTable
+	if watermarks == "" {
+		watermarks = defaultWatermarkTable
+	}
+	watermarks = QuoteIdentifier(watermarks)
+
+	schema, err := describeTable(conn, name, false)
+	if err != nil {
+		return progress, err
+	}
+	key, ok := schema.primaryKey()
+	if !ok {
+		return progress, fmt.Errorf("node table %s has no primary key", name)
+	}
+	keyType := key.dataType
+	if strings.EqualFold(keyType, "SERIAL") {
+		keyType = "INT64"
+	}
+	if !isExportKeyType(keyType) {
+		return progress, fmt.Errorf("primary key %s of type %s cannot be used to backfill in batches", key.name, key.dataType)
+	}
+	projections := make([]string, 0, len(schema.columns))
+	var properties []string
+	existing, exists := schema.column(column.Name)
+	for _, property := range schema.columns {
+		if exists && property.name == existing.name {
+			continue
+		}
+		properties = append(properties, property.name)
+		projections = append(projections, "n."+QuoteIdentifier(property.name))
+	}
+	if exists && !strings.EqualFold(existing.dataType, column.Type) {
+		return progress, fmt.Errorf("table %s already has a column %s of type %s", name, existing.name, existing.dataType)
+	}
+	if !exists {
+		if err := runStatement(conn, fmt.Sprintf("ALTER TABLE %s ADD %s %s;", QuoteIdentifier(name), QuoteIdentifier(column.Name), column.Type)); err != nil {
+			return progress, err
+		}
+	}
+
+	if err := runStatement(conn, fmt.Sprintf("CREATE NODE TABLE IF NOT EXISTS %s(name STRING, last_key STRING, row_count UINT64, completed BOOLEAN, PRIMARY KEY(name));", watermarks)); err != nil {
+		return progress, err
+	}
+	progress, err = loadWatermark(conn, watermarks, opts.Name, keyType)
+	if err != nil || progress.Completed {
+		return progress, err
+	}
+
+	match := fmt.Sprintf("MATCH (n:%s)", QuoteIdentifier(name))
+	page := fmt.Sprintf("RETURN n.%s, %s ORDER BY n.%s LIMIT %d;", QuoteIdentifier(key.name), strings.Join(projections, ", "), QuoteIdentifier(key.name), opts.BatchSize)
+	firstPage, err := conn.Prepare(fmt.Sprintf("%s WHERE n.%s IS NOT NULL %s", match, QuoteIdentifier(key.name), page))
+	if err != nil {
+		firstPage.Close()
+		return progress, err
+	}
+	defer firstPage.Close()
+	nextPage, err := conn.Prepare(fmt.Sprintf("%s WHERE n.%s > $lastKey %s", match, QuoteIdentifier(key.name), page))
+	if err != nil {
+		nextPage.Close()
+		return progress, err
+	}
+	defer nextPage.Close()
+	update, err := conn.Prepare(fmt.Sprintf("UNWIND $rows AS r MATCH (n:%s) WHERE n.%s = r.k SET n.%s = r.v;",
+		QuoteIdentifier(name), QuoteIdentifier(key.name), QuoteIdentifier(column.Name)))
+	if err != nil {
+		update.Close()
+		return progress, err
+	}
+	defer update.Close()
+	saveWatermark, err := conn.Prepare(fmt.Sprintf("MERGE (w:%s {name: $name}) SET w.last_key = $lastKey, w.row_count = $rows, w.completed = $completed;", watermarks))
+	if err != nil {
+		saveWatermark.Close()
+		return progress, err
+	}
+	defer saveWatermark.Close()
+
+	for {
+		var result *QueryResult
+		if progress.LastKey == nil {
+			result, err = conn.Execute(firstPage, nil)
+		} else {
+			result, err = conn.Execute(nextPage, map[string]any{"lastKey": progress.LastKey})
+		}
+		if err != nil {
+			return progress, err
+		}
+		keys, values, err := readExportPage(result)
+		result.Close()
+		if err != nil {
+			return progress, err
+		}
+		rows := make([]Row, len(values))
+		for i, row := range values {
+			rows[i] = make(Row, len(properties))
+			for j, property := range properties {
+				rows[i][property] = row[j]
+			}
+		}
+		var updates []any
+		if len(rows) > 0 {
+			backfilled := backfill(rows)
+			if len(backfilled) != len(rows) {
+				return progress, fmt.Errorf("backfill of column %s returned %d values for %d rows", column.Name, len(backfilled), len(rows))
+			}
+			for i, value := range backfilled {
+				if value == nil {
+					continue
+				}
+				value, err := copyParameterValue(value, column.Type)
+				if err != nil {
+					return progress, fmt.Errorf("column %s: %w", column.Name, err)
+				}
+				updates = append(updates, map[string]any{"k": keys[i], "v": value})
+			}
+		}
+
+		next := progress
+		next.Batches++
+		next.Rows += uint64(len(rows))
+		if len(keys) > 0 {
+			next.LastKey = keys[len(keys)-1]
+		}
+		next.Completed = len(rows) < opts.BatchSize
+		encodedKey, err := json.Marshal(next.LastKey)
+		if err != nil {
+			return progress, err
+		}
+		if err := runStatement(conn, "BEGIN TRANSACTION;"); err != nil {
+			return progress, err
+		}
+		err = func() error {
+			if len(updates) > 0 {
+				if _, err := update.Exec(map[string]any{"rows": updates}); err != nil {
+					return err
+				}
+			}
+			if _, err := saveWatermark.Exec(map[string]any{
+				"name": opts.Name, "lastKey": string(encodedKey), "rows": next.Rows, "completed": next.Completed,
+			}); err != nil {
+				return err
+			}
+			if beforeBackfillCommitForTesting != nil {
+				return beforeBackfillCommitForTesting(next.Batches)
+			}
+			return nil
+		}()
+		if err != nil {
+			runStatement(conn, "ROLLBACK;")
+			return progress, err
+		}
+		if err := runStatement(conn, "COMMIT;"); err != nil {
+			return progress, err
+		}
+		progress = next
+		if opts.OnProgress != nil {
+			opts.OnProgress(progress)
+		}
+		if progress.Completed {
+			return progress, nil
+		}
+		if opts.Throttle > 0 {
+			time.Sleep(opts.Throttle)
+		}
+	}
+}
+
+// loadWatermark returns the progress recorded in the quoted watermark table
+// for the backfill, with the last key converted to the type of the primary
+// key, or the progress of a backfill that has not started.
+func loadWatermark(conn *Connection, table string, name string, keyType string) (BackfillProgress, error) {
+	var progress BackfillProgress
+	statement, err := conn.Prepare(fmt.Sprintf("MATCH (w:%s) WHERE w.name = $name RETURN w.last_key, w.row_count, w.completed;", table))
+	if err != nil {
+		statement.Close()
+		return progress, err
+	}
+	defer statement.Close()
+	result, err := conn.Execute(statement, map[string]any{"name": name})
+	if err != nil {
+		return progress, err
+	}
+	defer result.Close()
+	if !result.HasNext() {
+		return progress, nil
+	}
+	tuple, err := result.Next()
+	if err != nil {
+		tuple.Close()
+		return progress, err
+	}
+	values, err := tuple.GetAsSlice()
+	tuple.Close()
+	if err != nil {
+		return progress, err
+	}
+	encodedKey, _ := values[0].(string)
+	progress.Rows, _ = values[1].(uint64)
+	progress.Completed, _ = values[2].(bool)
+	decoder := json.NewDecoder(bytes.NewReader([]byte(encodedKey)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&progress.LastKey); err != nil {
+		return progress, fmt.Errorf("invalid watermark of backfill %s: %w", name, err)
+	}
+	progress.LastKey, err = normalizeExportKey(progress.LastKey, keyType)
+	if err != nil {
+		return progress, fmt.Errorf("invalid watermark of backfill %s: %w", name, err)
+	}
+	return progress, nil
+}
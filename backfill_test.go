@@ -0,0 +1,95 @@
+package lbug
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddColumnWithBackfill(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE person(id INT64, age INT64, PRIMARY KEY(id));")
+	mustRun(t, conn, "UNWIND range(1, 50000) AS i CREATE (:person {id: i, age: i % 90});")
+
+	var backfilled []int64
+	double := func(batch []Row) []any {
+		values := make([]any, len(batch))
+		for i, row := range batch {
+			backfilled = append(backfilled, row["id"].(int64))
+			values[i] = row["age"].(int64) * 2
+		}
+		return values
+	}
+	column := ColumnSpec{Name: "double_age", Type: "INT64"}
+	opts := BackfillOptions{BatchSize: 5000}
+
+	// The process crashes before committing the fourth batch, which is
+	// rolled back.
+	crash := errors.New("crash")
+	beforeBackfillCommitForTesting = func(batch int) error {
+		if batch == 4 {
+			return crash
+		}
+		return nil
+	}
+	progress, err := AddColumnWithBackfill(conn, "Person", column, double, opts)
+	beforeBackfillCommitForTesting = nil
+	assert.ErrorIs(t, err, crash)
+	assert.Equal(t, 3, progress.Batches)
+	assert.Equal(t, uint64(15000), progress.Rows)
+	assert.Equal(t, int64(15000), progress.LastKey)
+	assert.Len(t, backfilled, 20000)
+	count, err := queryCount(conn, "MATCH (p:person) WHERE p.double_age IS NOT NULL RETURN count(p);")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(15000), count)
+
+	// The resumed backfill starts after the last committed batch.
+	backfilled = nil
+	var reports []BackfillProgress
+	opts.OnProgress = func(progress BackfillProgress) { reports = append(reports, progress) }
+	progress, err = AddColumnWithBackfill(conn, "person", column, double, opts)
+	assert.Nil(t, err)
+	assert.True(t, progress.Completed)
+	assert.Equal(t, uint64(50000), progress.Rows)
+	assert.Equal(t, 8, progress.Batches)
+	assert.Len(t, reports, 8)
+	if assert.NotEmpty(t, backfilled) {
+		assert.Equal(t, int64(15001), backfilled[0])
+	}
+	assert.Len(t, backfilled, 35000)
+	count, err = queryCount(conn, "MATCH (p:person) WHERE p.double_age = p.age * 2 RETURN count(p);")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(50000), count)
+
+	// A completed backfill does nothing.
+	backfilled = nil
+	progress, err = AddColumnWithBackfill(conn, "person", column, double, opts)
+	assert.Nil(t, err)
+	assert.True(t, progress.Completed)
+	assert.Empty(t, backfilled)
+}
+
+func TestAddColumnWithBackfillErrors(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE person(id INT64, name STRING, PRIMARY KEY(id));")
+	mustRun(t, conn, "CREATE (:person {id: 1, name: 'Alice'}), (:person {id: 2, name: 'Bob'});")
+	constant := func(batch []Row) []any { return make([]any, len(batch)) }
+
+	_, err := AddColumnWithBackfill(conn, "person", ColumnSpec{Name: "name", Type: "INT64"}, constant, BackfillOptions{})
+	assert.ErrorContains(t, err, "already has a column name of type STRING")
+	_, err = AddColumnWithBackfill(conn, "person", ColumnSpec{Name: "x", Type: "INT64"}, nil, BackfillOptions{})
+	assert.ErrorContains(t, err, "no backfill function")
+	_, err = AddColumnWithBackfill(conn, "person", ColumnSpec{Name: "x", Type: "INT64"}, constant, BackfillOptions{BatchSize: -1})
+	assert.ErrorContains(t, err, "must not be negative")
+	_, err = AddColumnWithBackfill(conn, "person", ColumnSpec{Name: "x", Type: "INT64"}, func([]Row) []any { return nil }, BackfillOptions{})
+	assert.ErrorContains(t, err, "returned 0 values for 2 rows")
+
+	// NULL values leave the column unset.
+	progress, err := AddColumnWithBackfill(conn, "person", ColumnSpec{Name: "y", Type: "STRING"}, constant, BackfillOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), progress.Rows)
+	count, err := queryCount(conn, "MATCH (p:person) WHERE p.y IS NULL RETURN count(p);")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), count)
+}
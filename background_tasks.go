@@ -0,0 +1,176 @@
+package lbug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TaskInfo describes a goroutine started by the package that has not
+// returned yet, as listed by BackgroundTasks.
+type TaskInfo struct {
+	// Name identifies what the task does, e.g. "DecodePipeline worker".
+	Name string
+	// StartedAt is the time the task was started.
+	StartedAt time.Time
+	// ConnectionID is the handle ID of the connection the task works for, as
+	// in HandleInfo.ID, or zero if it works for none.
+	ConnectionID uint64
+}
+
+// taskRegistry supervises the goroutines started by the package, so that
+// they can be listed, bounded and waited for.
+type taskRegistry struct {
+	mu     sync.Mutex
+	nextID uint64
+	tasks  map[uint64]TaskInfo
+	// count is the number of running and reserved tasks, and idle is closed
+	// when it drops to zero.
+	count int64
+	idle  chan struct{}
+	// limit is the limit set with SetMaxBackgroundTasks, or zero for no
+	// limit.
+	limit atomic.Int64
+}
+
+var background = &taskRegistry{tasks: make(map[uint64]TaskInfo)}
+
+// BackgroundTasks returns the goroutines started by the package that are
+// still running, ordered by start: the workers of DecodePipeline, the
+// batches of a BatchLoader, the preparations abandoned by PrepareWithContext
+// and the closes running in the background, among others.
+func BackgroundTasks() []TaskInfo {
+	background.mu.Lock()
+	ids := make([]uint64, 0, len(background.tasks))
+	for id := range background.tasks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	infos := make([]TaskInfo, len(ids))
+	for i, id := range ids {
+		infos[i] = background.tasks[id]
+	}
+	background.mu.Unlock()
+	return infos
+}
+
+// SetMaxBackgroundTasks sets the maximum number of goroutines started by the
+// package running at the same time in the process. Once it is reached, the
+// operations that would start more, e.g. DecodePipeline,
+// PrepareWithContext or BatchLoader.Load, fail with an error matching
+// ErrTooManyBackgroundTasks. The goroutines closing handles, which must not
+// fail, are counted but never rejected. Zero, the default, is no limit.
+func SetMaxBackgroundTasks(limit int) {
+	background.limit.Store(int64(limit))
+}
+
+// MaxBackgroundTasks returns the limit set with SetMaxBackgroundTasks.
+func MaxBackgroundTasks() int {
+	return int(background.limit.Load())
+}
+
+// reserve counts n tasks about to be started with start, or returns an error
+// matching ErrTooManyBackgroundTasks if they would exceed the limit, so that
+// the goroutines of an operation are either all started or none is.
+func (registry *taskRegistry) reserve(name string, n int) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if limit := registry.limit.Load(); limit > 0 && registry.count+int64(n) > limit {
+		return fmt.Errorf("%w: cannot start %s: the limit of %d is reached", ErrTooManyBackgroundTasks, name, limit)
+	}
+	registry.add(int64(n))
+	return nil
+}
+
+// add adds n to the count of tasks. mu must be held.
+func (registry *taskRegistry) add(n int64) {
+	if registry.count == 0 {
+		registry.idle = make(chan struct{})
+	}
+	registry.count += n
+	if registry.count == 0 {
+		close(registry.idle)
+	}
+}
+
+// release undoes the reservation of n tasks that are not started.
+func (registry *taskRegistry) release(n int) {
+	registry.mu.Lock()
+	registry.add(-int64(n))
+	registry.mu.Unlock()
+}
+
+// start runs f in a goroutine as a reserved task of the connection, which
+// may be nil.
+func (registry *taskRegistry) start(name string, conn *Connection, f func()) {
+	info := TaskInfo{Name: name, StartedAt: time.Now()}
+	if conn != nil {
+		info.ConnectionID = conn.handleID
+	}
+	registry.mu.Lock()
+	registry.nextID++
+	id := registry.nextID
+	registry.tasks[id] = info
+	registry.mu.Unlock()
+	go func() {
+		defer func() {
+			registry.mu.Lock()
+			delete(registry.tasks, id)
+			registry.add(-1)
+			registry.mu.Unlock()
+		}()
+		f()
+	}()
+}
+
+// spawn runs f in a goroutine as a task of the connection, which may be nil,
+// unless it would exceed the limit set with SetMaxBackgroundTasks.
+func (registry *taskRegistry) spawn(name string, conn *Connection, f func()) error {
+	if err := registry.reserve(name, 1); err != nil {
+		return err
+	}
+	registry.start(name, conn, f)
+	return nil
+}
+
+// spawnUnbounded runs f in a goroutine as a task of the connection, which may
+// be nil, even if it exceeds the limit, for the tasks that must not fail,
+// such as closes.
+func (registry *taskRegistry) spawnUnbounded(name string, conn *Connection, f func()) {
+	registry.mu.Lock()
+	registry.add(1)
+	registry.mu.Unlock()
+	registry.start(name, conn, f)
+}
+
+// wait waits for all the tasks to return. If the context is done first, it
+// returns an error per running task, joined into one, which matches
+// ErrCloseTimedOut.
+func (registry *taskRegistry) wait(ctx context.Context) error {
+	for {
+		registry.mu.Lock()
+		if registry.count == 0 {
+			registry.mu.Unlock()
+			return nil
+		}
+		idle := registry.idle
+		registry.mu.Unlock()
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			infos := BackgroundTasks()
+			errs := make([]error, len(infos))
+			for i, info := range infos {
+				errs[i] = &Error{Op: OpClose, Message: fmt.Sprintf("background task %s started at %s is still running", info.Name, info.StartedAt.Format(time.RFC3339)), Err: closeTimedOut(ctx)}
+			}
+			if len(errs) == 0 {
+				return &Error{Op: OpClose, Message: "background tasks are still starting", Err: closeTimedOut(ctx)}
+			}
+			return errors.Join(errs...)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package lbug
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// checkBackgroundTasks asserts, once the test and its other cleanups have
+// completed, that the goroutines it started have returned.
+func checkBackgroundTasks(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		assert.Nil(t, background.wait(ctx), "leaked background tasks")
+		assert.Empty(t, BackgroundTasks())
+	})
+}
+
+func TestBackgroundTasks(t *testing.T) {
+	checkBackgroundTasks(t)
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	for _, name := range []string{"first", "second"} {
+		assert.Nil(t, background.spawn(name, nil, func() {
+			started <- struct{}{}
+			<-release
+		}))
+		<-started
+	}
+	tasks := BackgroundTasks()
+	if assert.Len(t, tasks, 2) {
+		assert.Equal(t, "first", tasks[0].Name)
+		assert.Equal(t, "second", tasks[1].Name)
+		assert.Zero(t, tasks[0].ConnectionID)
+		assert.False(t, tasks[1].StartedAt.Before(tasks[0].StartedAt))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := background.wait(ctx)
+	assert.ErrorIs(t, err, ErrCloseTimedOut)
+	assert.ErrorContains(t, err, "background task first started at")
+	assert.ErrorContains(t, err, "background task second started at")
+	close(release)
+	assert.Nil(t, background.wait(context.Background()))
+	assert.Empty(t, BackgroundTasks())
+}
+
+func TestMaxBackgroundTasks(t *testing.T) {
+	checkBackgroundTasks(t)
+	SetMaxBackgroundTasks(2)
+	defer SetMaxBackgroundTasks(0)
+	assert.Equal(t, 2, MaxBackgroundTasks())
+
+	release := make(chan struct{})
+	assert.Nil(t, background.spawn("bounded", nil, func() { <-release }))
+	err := background.reserve("pipeline", 2)
+	assert.ErrorIs(t, err, ErrTooManyBackgroundTasks)
+	assert.ErrorContains(t, err, "cannot start pipeline: the limit of 2 is reached")
+	assert.Len(t, BackgroundTasks(), 1)
+	// The tasks that must not fail exceed the limit.
+	background.spawnUnbounded("unbounded", nil, func() { <-release })
+	background.spawnUnbounded("unbounded", nil, func() { <-release })
+	assert.ErrorIs(t, background.spawn("bounded", nil, func() {}), ErrTooManyBackgroundTasks)
+	assert.Len(t, BackgroundTasks(), 3)
+	close(release)
+	assert.Nil(t, background.wait(context.Background()))
+
+	assert.Nil(t, background.reserve("pipeline", 2))
+	background.release(2)
+	assert.Nil(t, background.wait(context.Background()))
+}
+
+func TestMaxBackgroundTasksRejectsOperations(t *testing.T) {
+	checkBackgroundTasks(t)
+	result := pipelineResult(t, 10)
+	conn := result.connection
+	SetMaxBackgroundTasks(1)
+	defer SetMaxBackgroundTasks(0)
+
+	err := result.DecodePipeline(context.Background(), DecodeOptions{Workers: 2}, func(row []any) error { return nil })
+	assert.ErrorIs(t, err, ErrTooManyBackgroundTasks)
+	// The rows are left to read.
+	assert.True(t, result.HasNext())
+
+	release := make(chan struct{})
+	assert.Nil(t, background.spawn("busy", nil, func() { <-release }))
+	_, err = conn.PrepareWithContext(context.Background(), "RETURN 1;")
+	assert.ErrorIs(t, err, ErrTooManyBackgroundTasks)
+	close(release)
+	assert.Nil(t, background.wait(context.Background()))
+	statement, err := conn.PrepareWithContext(context.Background(), "RETURN 1;")
+	assert.Nil(t, err)
+	statement.Close()
+}
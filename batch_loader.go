@@ -0,0 +1,194 @@
+package lbug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchSource runs the queries of a BatchLoader. It is implemented by
+// *Connection, on which the batches share the connection, and by *Pool, from
+// which every batch acquires a connection of its own.
+type BatchSource interface {
+	batchConnection(ctx context.Context) (*Connection, func(), error)
+}
+
+func (conn *Connection) batchConnection(context.Context) (*Connection, func(), error) {
+	return conn, func() {}, nil
+}
+
+func (pool *Pool) batchConnection(ctx context.Context) (*Connection, func(), error) {
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pooled.Connection, pooled.Release, nil
+}
+
+// BatchLoaderOptions configures a BatchLoader created with NewBatchLoader.
+type BatchLoaderOptions[K comparable, V any] struct {
+	// Key returns the key of a row decoded from the result of the query. It
+	// is required.
+	Key func(row V) K
+	// Wait is how long the keys requested after the first key of a batch are
+	// coalesced into it before its query runs. Zero means one millisecond.
+	Wait time.Duration
+	// MaxBatchSize is the maximum number of distinct keys of a query. A batch
+	// reaching it runs immediately, and the keys requested later go to the
+	// next batch. Zero means 1000.
+	MaxBatchSize int
+	// Collect configures the decoding of the rows, see CollectWithOptions.
+	Collect CollectOptions
+}
+
+// BatchLoader coalesces the keys requested by concurrent callers, e.g. the
+// resolvers of the fields of a GraphQL query, into one query per batch
+// instead of one query per key, like a data loader. A batch collects the keys
+// requested within a short window, runs the query with the list of its keys
+// and hands every caller the row of its key. It is safe for concurrent use.
+//
+// A BatchLoader does not cache the rows: every batch queries the database, so
+// that callers see the rows as of their request.
+type BatchLoader[K comparable, V any] struct {
+	source   BatchSource
+	template *Template
+	keyParam string
+	opts     BatchLoaderOptions[K, V]
+
+	mutex   sync.Mutex
+	pending *loaderBatch[K, V]
+}
+
+// loaderBatch is a batch of keys whose query has not completed yet.
+type loaderBatch[K comparable, V any] struct {
+	keys  []K
+	seen  map[K]bool
+	timer *time.Timer
+	// done is closed once rows and err are set.
+	done chan struct{}
+	rows map[K]V
+	err  error
+}
+
+// NewBatchLoader returns a loader running the query on the source for every
+// batch, with the list of the keys of the batch bound to the keyParam
+// parameter, e.g. "keys" for a query with WHERE p.id IN $keys. The query must
+// use no other parameter, and return at most one row per key; the rows are
+// decoded into the struct type V as by CollectWithOptions, and matched with
+// the keys by BatchLoaderOptions.Key. Later rows of a key already returned
+// are ignored.
+func NewBatchLoader[K comparable, V any](source BatchSource, query string, keyParam string, opts BatchLoaderOptions[K, V]) (*BatchLoader[K, V], error) {
+	if opts.Key == nil {
+		return nil, errors.New("batch loader requires a Key function")
+	}
+	template, err := NewTemplate(query, keyParam)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Wait <= 0 {
+		opts.Wait = time.Millisecond
+	}
+	if opts.MaxBatchSize <= 0 {
+		opts.MaxBatchSize = 1000
+	}
+	return &BatchLoader[K, V]{source: source, template: template, keyParam: template.params[0], opts: opts}, nil
+}
+
+// Load returns the row of the key, once the query of the batch of the key has
+// run. It returns an error matching ErrNotFound if the query returned no row
+// for the key, and the error of the query if it failed, for all the keys of
+// the batch. If the context is done first, Load returns its error; the query
+// of the batch is not canceled, since other callers wait for it.
+func (loader *BatchLoader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	batch := loader.add(key)
+	var row V
+	select {
+	case <-batch.done:
+	case <-ctx.Done():
+		return row, ctx.Err()
+	}
+	if batch.err != nil {
+		return row, batch.err
+	}
+	row, ok := batch.rows[key]
+	if !ok {
+		return row, fmt.Errorf("%w: no row has key %v", ErrNotFound, key)
+	}
+	return row, nil
+}
+
+// add adds the key to the pending batch, starting a new batch if there is
+// none, and returns the batch.
+func (loader *BatchLoader[K, V]) add(key K) *loaderBatch[K, V] {
+	loader.mutex.Lock()
+	defer loader.mutex.Unlock()
+	batch := loader.pending
+	if batch == nil {
+		batch = &loaderBatch[K, V]{seen: make(map[K]bool), done: make(chan struct{})}
+		batch.timer = time.AfterFunc(loader.opts.Wait, func() { loader.dispatch(batch) })
+		loader.pending = batch
+	}
+	if !batch.seen[key] {
+		batch.seen[key] = true
+		batch.keys = append(batch.keys, key)
+	}
+	if len(batch.keys) >= loader.opts.MaxBatchSize {
+		loader.pending = nil
+		// If the timer has fired already, dispatch runs the batch.
+		if batch.timer.Stop() {
+			loader.spawn(batch)
+		}
+	}
+	return batch
+}
+
+// spawn runs the batch on a background task, or fails it with an error
+// matching ErrTooManyBackgroundTasks if the limit is reached.
+func (loader *BatchLoader[K, V]) spawn(batch *loaderBatch[K, V]) {
+	err := background.spawn("BatchLoader batch", nil, func() { loader.run(batch) })
+	if err != nil {
+		batch.err = err
+		close(batch.done)
+	}
+}
+
+// dispatch runs the batch when its window ends.
+func (loader *BatchLoader[K, V]) dispatch(batch *loaderBatch[K, V]) {
+	loader.mutex.Lock()
+	if loader.pending == batch {
+		loader.pending = nil
+	}
+	loader.mutex.Unlock()
+	loader.spawn(batch)
+}
+
+// run runs the query of the batch and wakes up its callers.
+func (loader *BatchLoader[K, V]) run(batch *loaderBatch[K, V]) {
+	defer close(batch.done)
+	conn, release, err := loader.source.batchConnection(context.Background())
+	if err != nil {
+		batch.err = err
+		return
+	}
+	defer release()
+	result, err := loader.template.Query(conn, map[string]any{loader.keyParam: batch.keys})
+	if err != nil {
+		batch.err = err
+		return
+	}
+	defer result.Close()
+	rows, err := CollectWithOptions[V](result, loader.opts.Collect)
+	if err != nil {
+		batch.err = err
+		return
+	}
+	batch.rows = make(map[K]V, len(rows))
+	for _, row := range rows {
+		key := loader.opts.Key(row)
+		if _, ok := batch.rows[key]; !ok {
+			batch.rows[key] = row
+		}
+	}
+}
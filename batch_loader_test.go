@@ -0,0 +1,213 @@
+package lbug
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type loadedPerson struct {
+	ID   int64  `lbug:"id"`
+	Name string `lbug:"name"`
+}
+
+const loadPeopleQuery = "MATCH (p:person) WHERE p.ID IN $ids RETURN p.ID AS id, p.fName AS name;"
+
+var loadPeopleOptions = BatchLoaderOptions[int64, loadedPerson]{
+	Key: func(person loadedPerson) int64 { return person.ID },
+}
+
+// loadConcurrently loads the keys from concurrent goroutines and returns the
+// rows and errors by the index of their key.
+func loadConcurrently(loader *BatchLoader[int64, loadedPerson], keys []int64) ([]loadedPerson, []error) {
+	people := make([]loadedPerson, len(keys))
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			people[i], errs[i] = loader.Load(context.Background(), key)
+		}()
+	}
+	wg.Wait()
+	return people, errs
+}
+
+func TestNewBatchLoaderErrors(t *testing.T) {
+	checkBackgroundTasks(t)
+	_, conn := SetupTestDatabase(t)
+	_, err := NewBatchLoader(conn, loadPeopleQuery, "ids", BatchLoaderOptions[int64, loadedPerson]{})
+	assert.ErrorContains(t, err, "batch loader requires a Key function")
+	_, err = NewBatchLoader(conn, loadPeopleQuery, "keys", loadPeopleOptions)
+	assert.ErrorContains(t, err, "undeclared placeholders: $ids")
+}
+
+func TestBatchLoader(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, _ := SetupTestDatabase(t)
+	pool, err := NewPool(db, PoolConfig{MaxConns: 4})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+	opts := loadPeopleOptions
+	opts.Wait = time.Second
+	opts.MaxBatchSize = 5
+	loader, err := NewBatchLoader(pool, loadPeopleQuery, "$ids", opts)
+	assert.Nil(t, err)
+
+	// The duplicate keys are loaded once, and the batch runs as soon as it
+	// has MaxBatchSize keys, without waiting for the end of its window.
+	keys := []int64{0, 2, 3, 0, 5, 4, 2}
+	before := db.Stats().QueriesExecuted
+	start := time.Now()
+	people, errs := loadConcurrently(loader, keys)
+	assert.True(t, time.Since(start) < opts.Wait)
+	assert.Equal(t, before+1, db.Stats().QueriesExecuted)
+	for i, key := range keys {
+		if key == 4 {
+			assert.ErrorIs(t, errs[i], ErrNotFound)
+			assert.ErrorContains(t, errs[i], "no row has key 4")
+			continue
+		}
+		assert.Nil(t, errs[i])
+		assert.Equal(t, key, people[i].ID)
+	}
+	assert.Equal(t, "Alice", people[0].Name)
+	assert.Equal(t, "Dan", people[4].Name)
+
+	// A naive loop runs a query per key.
+	template := MustTemplate(loadPeopleQuery, "ids")
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	before = db.Stats().QueriesExecuted
+	for _, key := range keys {
+		result, err := template.Query(conn, map[string]any{"ids": []int64{key}})
+		assert.Nil(t, err)
+		result.Close()
+	}
+	assert.Equal(t, before+uint64(len(keys)), db.Stats().QueriesExecuted)
+}
+
+func TestBatchLoaderMaxBatchSize(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, conn := SetupTestDatabase(t)
+	opts := loadPeopleOptions
+	opts.Wait = 200 * time.Millisecond
+	opts.MaxBatchSize = 2
+	loader, err := NewBatchLoader(conn, loadPeopleQuery, "ids", opts)
+	assert.Nil(t, err)
+	keys := []int64{0, 2, 3, 5, 7}
+	before := db.Stats().QueriesExecuted
+	people, errs := loadConcurrently(loader, keys)
+	assert.Equal(t, before+3, db.Stats().QueriesExecuted)
+	for i, key := range keys {
+		assert.Nil(t, errs[i])
+		assert.Equal(t, key, people[i].ID)
+	}
+}
+
+func TestBatchLoaderErrors(t *testing.T) {
+	checkBackgroundTasks(t)
+	_, conn := SetupTestDatabase(t)
+	loader, err := NewBatchLoader(conn, "MATCH (p:person) WHERE p.ID IN $ids RETURN p.ID AS id, p.fName + 1 AS name;", "ids", loadPeopleOptions)
+	assert.Nil(t, err)
+	_, errs := loadConcurrently(loader, []int64{0, 2})
+	for _, err := range errs {
+		assert.NotNil(t, err)
+	}
+
+	// Load returns when the context is done, before the end of the window.
+	opts := loadPeopleOptions
+	opts.Wait = time.Second
+	loader, err = NewBatchLoader(conn, loadPeopleQuery, "ids", opts)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = loader.Load(ctx, 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// personResolver resolves the names of people like the resolver of a GraphQL
+// field would, with a query per batch of people instead of a query per
+// person.
+type personResolver struct {
+	people *BatchLoader[int64, loadedPerson]
+}
+
+func (resolver *personResolver) Name(ctx context.Context, id int64) (string, error) {
+	person, err := resolver.people.Load(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return person.Name, nil
+}
+
+func ExampleBatchLoader() {
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+	conn, err := OpenConnection(db)
+	if err != nil {
+		panic(err)
+	}
+	for _, query := range []string{
+		"CREATE NODE TABLE person(ID INT64, fName STRING, PRIMARY KEY(ID));",
+		"CREATE (:person {ID: 1, fName: 'Alice'}), (:person {ID: 2, fName: 'Bob'}), (:person {ID: 3, fName: 'Carol'});",
+	} {
+		result, err := conn.Query(query)
+		if err != nil {
+			panic(err)
+		}
+		result.Close()
+	}
+	conn.Close()
+
+	pool, err := NewPool(db, PoolConfig{})
+	if err != nil {
+		panic(err)
+	}
+	defer pool.Close(context.Background())
+	people, err := NewBatchLoader(pool, loadPeopleQuery, "ids", BatchLoaderOptions[int64, loadedPerson]{
+		Key:  func(person loadedPerson) int64 { return person.ID },
+		Wait: 50 * time.Millisecond,
+	})
+	if err != nil {
+		panic(err)
+	}
+	resolver := &personResolver{people: people}
+
+	// The resolvers of the names run concurrently, and their keys are loaded
+	// by one query.
+	ids := []int64{1, 2, 3, 4}
+	names := make([]string, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name, err := resolver.Name(context.Background(), id)
+			if err != nil {
+				name = err.Error()
+			}
+			names[i] = name
+		}()
+	}
+	wg.Wait()
+	for i, id := range ids {
+		fmt.Printf("%d: %s\n", id, names[i])
+	}
+	fmt.Println("queries:", db.Stats().QueriesExecuted-2)
+	// Output:
+	// 1: Alice
+	// 2: Bob
+	// 3: Carol
+	// 4: node not found: no row has key 4
+	// queries: 1
+}
@@ -0,0 +1,51 @@
+//go:build asan
+
+package lbug
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBindMemoryUnderGC binds large strings and nested values, then forces
+// garbage collections and heap growth before executing the statement, so
+// that the address sanitizer reports any use of memory freed or moved after
+// the values were bound. Run it with go test -asan.
+func TestBindMemoryUnderGC(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	statement, err := conn.Prepare("RETURN $text AS text, $names AS names, $fields.a AS a, $blob AS blob;")
+	assert.Nil(t, err)
+	defer statement.Close()
+	text := strings.Repeat("lbug", 1<<18)
+	names := []any{strings.Repeat("x", 1<<16), strings.Repeat("y", 1<<16)}
+	fields := map[string]any{"a": strings.Repeat("z", 1<<16)}
+	blob := []byte(strings.Repeat("\x00\xff", 1<<15))
+	for key, value := range map[string]any{
+		"text":   strings.Clone(text),
+		"names":  []any{strings.Clone(names[0].(string)), strings.Clone(names[1].(string))},
+		"fields": map[string]any{"a": strings.Clone(fields["a"].(string))},
+		"blob":   []byte(string(blob)),
+	} {
+		assert.Nil(t, conn.bindParameter(statement, key, value))
+	}
+	// The bound copies are unreachable from Go now.
+	var garbage [][]byte
+	for i := 0; i < 64; i++ {
+		garbage = append(garbage, make([]byte, 1<<20))
+		runtime.GC()
+	}
+	runtime.KeepAlive(garbage)
+
+	result, err := conn.Execute(statement, nil)
+	assert.Nil(t, err)
+	defer result.Close()
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	values, err := tuple.GetAsSlice()
+	assert.Nil(t, err)
+	assert.Equal(t, []any{text, names, fields["a"], blob}, values)
+}
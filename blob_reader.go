@@ -0,0 +1,196 @@
+package lbug
+
+/*
+#include "lbug.h"
+#include <stdlib.h>
+
+// lbug_go_try_malloc allocates size bytes, returning NULL if the allocation
+// fails, unlike C.malloc, which aborts the process.
+static void *lbug_go_try_malloc(size_t size) {
+	return malloc(size);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// GetBlobReader returns a reader of the BLOB value at the given index in the
+// FlatTuple. The C API does not give chunked access to blobs, so the contents
+// are copied once into C memory, which the reader streams from without
+// copying them into the Go heap. Close releases the C memory and must be
+// called when done. Reading after the tuple or its QueryResult is closed
+// returns an error matching ErrClosed.
+func (tuple *FlatTuple) GetBlobReader(index uint64) (io.ReadCloser, error) {
+	tuple.queryResult.connection.closeMutex.RLock()
+	defer tuple.queryResult.connection.closeMutex.RUnlock()
+	reader, err := tuple.getBlobReader(index)
+	if err != nil {
+		return nil, conversionError(index, err)
+	}
+	return reader, nil
+}
+
+func (tuple *FlatTuple) getBlobReader(index uint64) (*blobReader, error) {
+	cValue, err := tuple.getCValue(index)
+	if err != nil {
+		return nil, err
+	}
+	if C.lbug_value_is_null(&cValue) {
+		return nil, fmt.Errorf("value at index %d is NULL", index)
+	}
+	var logicalType C.lbug_logical_type
+	defer C.lbug_data_type_destroy(&logicalType)
+	C.lbug_value_get_data_type(&cValue, &logicalType)
+	logicalTypeId := C.lbug_data_type_get_id(&logicalType)
+	if logicalTypeId != C.LBUG_BLOB {
+		return nil, fmt.Errorf("value at index %d is not a blob, type id: %d", index, logicalTypeId)
+	}
+	reader := &blobReader{tuple: tuple, index: index}
+	status := C.lbug_value_get_blob(&cValue, &reader.data, &reader.length)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get blob value with status: %d", status)
+	}
+	return reader, nil
+}
+
+// blobReader reads the contents of a blob copied into C memory.
+type blobReader struct {
+	tuple  *FlatTuple
+	index  uint64
+	data   *C.uint8_t
+	length C.uint64_t
+	offset uint64
+	closed bool
+}
+
+func (reader *blobReader) Read(p []byte) (int, error) {
+	if reader.closed {
+		return 0, conversionError(reader.index, &closedError{"failed to read blob because the reader is closed"})
+	}
+	connection := reader.tuple.queryResult.connection
+	connection.closeMutex.RLock()
+	closed := reader.tuple.isClosed || reader.tuple.queryResult.isClosed
+	connection.closeMutex.RUnlock()
+	if closed {
+		reader.Close()
+		return 0, conversionError(reader.index, &closedError{"failed to read blob because the tuple is closed"})
+	}
+	if reader.offset >= uint64(reader.length) {
+		return 0, io.EOF
+	}
+	contents := unsafe.Slice((*byte)(unsafe.Pointer(reader.data)), uint64(reader.length))
+	n := copy(p, contents[reader.offset:])
+	reader.offset += uint64(n)
+	return n, nil
+}
+
+// Close releases the C memory holding the blob. It may be called more than
+// once.
+func (reader *blobReader) Close() error {
+	if reader.closed {
+		return nil
+	}
+	C.lbug_destroy_blob(reader.data)
+	reader.data = nil
+	reader.closed = true
+	return nil
+}
+
+// Blob is a BLOB parameter whose contents are read from Reader when the
+// parameter is bound. Exactly Length bytes are read, at most 256 MiB. Since
+// the C API cannot create BLOB values, the contents are bound as a string of
+// \xHH escapes that the query must cast to BLOB, e.g. with
+// CAST($data, 'BLOB').
+//
+// The C API only creates a string value from a whole C string, which the
+// engine copies, so the escapes cannot be streamed to it: binding a blob of n
+// bytes needs 4n bytes of C memory for the escapes, freed once bound, in
+// addition to the 4n bytes of the string value. The contents are read through
+// a buffer of 32 KiB and never held in Go memory. A []byte is not a Blob: it
+// is bound as a LIST of UINT8.
+type Blob struct {
+	Reader io.Reader
+	Length int64
+}
+
+// maxBlobLength is the length of the longest Blob that can be bound, which
+// bounds the C memory of its escapes to 1 GiB.
+const maxBlobLength = 256 << 20
+
+// maxEmptyBlobReads is the number of successive reads returning no bytes and
+// no error after which the reader of a Blob is deemed stuck, as in bufio.
+const maxEmptyBlobReads = 100
+
+// checkLength returns an error if the length of the blob is negative or
+// greater than maxBlobLength.
+func (blob Blob) checkLength() error {
+	if blob.Length < 0 || blob.Length > maxBlobLength {
+		return fmt.Errorf("blob length %d is out of range [0, %d]", blob.Length, maxBlobLength)
+	}
+	return nil
+}
+
+// cEscapedBlob reads the contents of the blob and returns them as a C string
+// of \xHH escapes, which must be freed by the caller.
+func (blob Blob) cEscapedBlob() (*C.char, error) {
+	if err := blob.checkLength(); err != nil {
+		return nil, err
+	}
+	size := 4*blob.Length + 1
+	cEscaped := (*C.char)(C.lbug_go_try_malloc(C.size_t(size)))
+	if cEscaped == nil {
+		return nil, fmt.Errorf("failed to allocate %d bytes for the escapes of a blob of %d bytes", size, blob.Length)
+	}
+	buffer := unsafe.Slice((*byte)(unsafe.Pointer(cEscaped)), size)
+	escaped, err := blob.escapedBlob(buffer[: 0 : size-1])
+	if err != nil {
+		C.free(unsafe.Pointer(cEscaped))
+		return nil, err
+	}
+	buffer[len(escaped)] = 0
+	return cEscaped, nil
+}
+
+// escapedBlob reads the contents of the blob and appends them to escaped as
+// \xHH escapes. The contents are read in chunks of at most 32 KiB, so only
+// escaped grows with the blob. A reader returning no bytes and no error
+// maxEmptyBlobReads times in a row fails with io.ErrNoProgress.
+func (blob Blob) escapedBlob(escaped []byte) ([]byte, error) {
+	if err := blob.checkLength(); err != nil {
+		return nil, err
+	}
+	buffer := make([]byte, min(blob.Length, 32*1024))
+	remaining := blob.Length
+	emptyReads := 0
+	for remaining > 0 {
+		n, err := blob.Reader.Read(buffer[:min(remaining, int64(len(buffer)))])
+		escaped = appendEscapedBlob(escaped, buffer[:n])
+		remaining -= int64(n)
+		if err == io.EOF && remaining > 0 {
+			return nil, fmt.Errorf("blob reader returned %d of %d bytes: %w", blob.Length-remaining, blob.Length, io.ErrUnexpectedEOF)
+		}
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read blob: %w", err)
+		}
+		if n > 0 {
+			emptyReads = 0
+		} else if emptyReads++; emptyReads == maxEmptyBlobReads {
+			return nil, fmt.Errorf("blob reader returned %d of %d bytes: %w", blob.Length-remaining, blob.Length, io.ErrNoProgress)
+		}
+	}
+	return escaped, nil
+}
+
+// appendEscapedBlob appends the bytes as \xHH escapes, which the engine parses
+// when casting a string to BLOB.
+func appendEscapedBlob(escaped []byte, data []byte) []byte {
+	const digits = "0123456789ABCDEF"
+	for _, b := range data {
+		escaped = append(escaped, '\\', 'x', digits[b>>4], digits[b&0x0F])
+	}
+	return escaped
+}
@@ -0,0 +1,135 @@
+package lbug
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBlobReader(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	res, err := conn.Query("RETURN BLOB('\\\\xAA\\\\xBB\\\\xCD\\\\x1A'), 1, CAST(NULL AS BLOB)")
+	assert.Nil(t, err)
+	defer res.Close()
+	tuple, err := res.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	reader, err := tuple.GetBlobReader(0)
+	assert.Nil(t, err)
+	assert.Nil(t, iotest.TestReader(reader, []byte{0xAA, 0xBB, 0xCD, 0x1A}))
+	assert.Nil(t, reader.Close())
+	assert.Nil(t, reader.Close())
+	_, err = reader.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, ErrClosed)
+
+	_, err = tuple.GetBlobReader(1)
+	assert.ErrorContains(t, err, "is not a blob")
+	_, err = tuple.GetBlobReader(2)
+	assert.ErrorContains(t, err, "is NULL")
+	_, err = tuple.GetBlobReader(3)
+	assert.ErrorIs(t, err, ErrColumnIndexOutOfRange)
+}
+
+func TestGetBlobReaderAfterResultClosed(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	res, err := conn.Query("RETURN BLOB('\\\\xAA\\\\xBB')")
+	assert.Nil(t, err)
+	tuple, err := res.Next()
+	assert.Nil(t, err)
+	reader, err := tuple.GetBlobReader(0)
+	assert.Nil(t, err)
+	buffer := make([]byte, 1)
+	n, err := reader.Read(buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	tuple.Close()
+	res.Close()
+	_, err = reader.Read(buffer)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.Nil(t, reader.Close())
+}
+
+func TestBlobParam(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	data := make([]byte, 100*1024)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	stmt, err := conn.Prepare("RETURN CAST($data, 'BLOB')")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	res, err := conn.Execute(stmt, map[string]any{"data": Blob{Reader: iotest.HalfReader(bytes.NewReader(data)), Length: int64(len(data))}})
+	assert.Nil(t, err)
+	defer res.Close()
+	tuple, err := res.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	reader, err := tuple.GetBlobReader(0)
+	assert.Nil(t, err)
+	defer reader.Close()
+	contents, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, data, contents)
+
+	_, err = conn.Execute(stmt, map[string]any{"data": Blob{Reader: bytes.NewReader(data[:10]), Length: 11}})
+	assert.ErrorContains(t, err, "blob reader returned 10 of 11 bytes")
+}
+
+func TestEscapedBlob(t *testing.T) {
+	escaped, err := Blob{Reader: bytes.NewReader([]byte{0x00, 0x1F, 0xFF, 'a'}), Length: 4}.escapedBlob(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `\x00\x1F\xFF\x61`, string(escaped))
+	// Only the declared length is read.
+	escaped, err = Blob{Reader: bytes.NewReader([]byte{0x01, 0x02}), Length: 1}.escapedBlob(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `\x01`, string(escaped))
+	// The escapes are appended to the buffer without growing it.
+	buffer := make([]byte, 0, 8)
+	escaped, err = Blob{Reader: bytes.NewReader([]byte{0x01, 0x02}), Length: 2}.escapedBlob(buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, &buffer[:1][0], &escaped[0])
+	_, err = Blob{Reader: bytes.NewReader(nil), Length: 1}.escapedBlob(nil)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	_, err = Blob{Reader: iotest.ErrReader(errors.New("boom")), Length: 1}.escapedBlob(nil)
+	assert.ErrorContains(t, err, "failed to read blob: boom")
+	_, err = Blob{Length: -1}.escapedBlob(nil)
+	assert.ErrorContains(t, err, "out of range")
+	_, err = Blob{Length: maxBlobLength + 1}.escapedBlob(nil)
+	assert.ErrorContains(t, err, "out of range")
+	_, err = Blob{Reader: emptyReader{}, Length: 1}.escapedBlob(nil)
+	assert.ErrorIs(t, err, io.ErrNoProgress)
+	// Empty reads between reads of bytes are allowed.
+	escaped, err = Blob{Reader: &emptyReadsReader{data: []byte{0x01, 0x02}}, Length: 2}.escapedBlob(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, `\x01\x02`, string(escaped))
+}
+
+// emptyReader returns no bytes and no error.
+type emptyReader struct{}
+
+func (emptyReader) Read([]byte) (int, error) {
+	return 0, nil
+}
+
+// emptyReadsReader returns no bytes and no error before each byte of data.
+type emptyReadsReader struct {
+	data  []byte
+	empty bool
+}
+
+func (reader *emptyReadsReader) Read(p []byte) (int, error) {
+	if len(reader.data) == 0 {
+		return 0, io.EOF
+	}
+	reader.empty = !reader.empty
+	if reader.empty || len(p) == 0 {
+		return 0, nil
+	}
+	p[0] = reader.data[0]
+	reader.data = reader.data[1:]
+	return 1, nil
+}
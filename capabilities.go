@@ -0,0 +1,210 @@
+package lbug
+
+// #include "lbug.h"
+import "C"
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Capabilities describes the optional features of the engine a Database runs
+// on, so that code running against several releases of the engine can check
+// for a feature instead of failing on the first query using it.
+type Capabilities struct {
+	// Version is the version of the engine, e.g. "0.11.0".
+	Version string
+	// MaxSupportedStorageVersion is the storage version written by the
+	// engine, which is the newest version of database files it can open.
+	MaxSupportedStorageVersion uint64
+	// HasInterrupt is true if queries can be interrupted with
+	// Connection.Interrupt, which also backs SetTimeout and the context of
+	// QueryWithContext.
+	HasInterrupt bool
+	// HasArrowExport is true if query results can be exported in the Arrow
+	// format.
+	HasArrowExport bool
+	// SupportedLogicalTypes are the logical types the engine supports,
+	// sorted by type id.
+	SupportedLogicalTypes []DataType
+	// extensions are the names, in lower case, of the loaded extensions.
+	extensions map[string]bool
+}
+
+// HasExtension returns true if the extension, e.g. "fts" or "vector", was
+// loaded when the capabilities were detected. The name is compared
+// case-insensitively.
+func (caps Capabilities) HasExtension(name string) bool {
+	return caps.extensions[strings.ToLower(name)]
+}
+
+// SupportsType returns true if the engine supports the logical type with the
+// Cypher name, e.g. "UUID" or "INT128", as named by DataType.Name.
+func (caps Capabilities) SupportsType(name string) bool {
+	for _, dataType := range caps.SupportedLogicalTypes {
+		if strings.EqualFold(dataType.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// capabilityProbes detect the capabilities of the engine.
+type capabilityProbes struct {
+	version          func() string
+	storageVersion   func() uint64
+	interrupt        func() bool
+	arrowExport      func() bool
+	loadedExtensions func(conn *Connection) ([]string, error)
+	castsTo          func(conn *Connection, cypherType string) bool
+}
+
+// engineProbes detect the capabilities of the engine the bindings are linked
+// with. Tests replace them to simulate engines with other capabilities.
+var engineProbes = capabilityProbes{
+	version: func() string {
+		cVersion := C.lbug_get_version()
+		defer C.lbug_destroy_string(cVersion)
+		return C.GoString(cVersion)
+	},
+	storageVersion: func() uint64 {
+		return uint64(C.lbug_get_storage_version())
+	},
+	// The bindings call lbug_connection_interrupt, so they only link with
+	// engines supporting it.
+	interrupt: func() bool { return true },
+	// The C API has no Arrow export.
+	arrowExport: func() bool { return false },
+	loadedExtensions: func(conn *Connection) ([]string, error) {
+		rows, err := queryRows(conn, "CALL show_loaded_extensions() RETURN *;")
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, row := range rows {
+			if name, ok := row["extension name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	},
+	castsTo: func(conn *Connection, cypherType string) bool {
+		return runStatement(conn, fmt.Sprintf("RETURN CAST(NULL AS %s);", cypherType)) == nil
+	},
+}
+
+// probedTypes are the types whose support is probed by casting NULL to the
+// type, with the spelling of the type used in the cast. The other types known
+// to the bindings, such as NODE or SERIAL, cannot be the target of a cast and
+// are supported by every release of the engine, except the internal types ANY
+// and POINTER, which are never reported.
+var probedTypes = map[C.lbug_data_type_id]string{
+	C.LBUG_BOOL:          "BOOL",
+	C.LBUG_INT64:         "INT64",
+	C.LBUG_INT32:         "INT32",
+	C.LBUG_INT16:         "INT16",
+	C.LBUG_INT8:          "INT8",
+	C.LBUG_UINT64:        "UINT64",
+	C.LBUG_UINT32:        "UINT32",
+	C.LBUG_UINT16:        "UINT16",
+	C.LBUG_UINT8:         "UINT8",
+	C.LBUG_INT128:        "INT128",
+	C.LBUG_DOUBLE:        "DOUBLE",
+	C.LBUG_FLOAT:         "FLOAT",
+	C.LBUG_DATE:          "DATE",
+	C.LBUG_TIMESTAMP:     "TIMESTAMP",
+	C.LBUG_TIMESTAMP_SEC: "TIMESTAMP_SEC",
+	C.LBUG_TIMESTAMP_MS:  "TIMESTAMP_MS",
+	C.LBUG_TIMESTAMP_NS:  "TIMESTAMP_NS",
+	C.LBUG_TIMESTAMP_TZ:  "TIMESTAMP_TZ",
+	C.LBUG_INTERVAL:      "INTERVAL",
+	C.LBUG_DECIMAL:       "DECIMAL(18, 3)",
+	C.LBUG_STRING:        "STRING",
+	C.LBUG_BLOB:          "BLOB",
+	C.LBUG_LIST:          "INT64[]",
+	C.LBUG_ARRAY:         "INT64[2]",
+	C.LBUG_STRUCT:        "STRUCT(a INT64)",
+	C.LBUG_MAP:           "MAP(STRING, INT64)",
+	C.LBUG_UNION:         "UNION(a INT64)",
+	C.LBUG_UUID:          "UUID",
+}
+
+// capabilityCache holds the capabilities of a Database once detected.
+type capabilityCache struct {
+	sync.Mutex
+	capabilities *Capabilities
+}
+
+// Capabilities returns the capabilities of the engine of the database. They
+// are detected on the first call, on a connection opened for the purpose, and
+// cached for the lifetime of the Database. Extensions loaded afterwards are
+// not reported until RefreshCapabilities is called. Failures of the probe
+// queries are taken as missing features; an error is only returned if the
+// connection cannot be opened, e.g. because the database is closed.
+func (db *Database) Capabilities() (Capabilities, error) {
+	db.capabilities.Lock()
+	defer db.capabilities.Unlock()
+	if db.capabilities.capabilities == nil {
+		caps, err := detectCapabilities(db, engineProbes)
+		if err != nil {
+			return Capabilities{}, err
+		}
+		db.capabilities.capabilities = &caps
+	}
+	return db.capabilities.capabilities.clone(), nil
+}
+
+// RefreshCapabilities detects the capabilities of the engine again, e.g.
+// after an extension has been loaded, and returns them.
+func (db *Database) RefreshCapabilities() (Capabilities, error) {
+	db.capabilities.Lock()
+	db.capabilities.capabilities = nil
+	db.capabilities.Unlock()
+	return db.Capabilities()
+}
+
+// detectCapabilities runs the probes on a new connection to the database.
+func detectCapabilities(db *Database, probes capabilityProbes) (Capabilities, error) {
+	conn, err := OpenConnection(db)
+	if err != nil {
+		return Capabilities{}, fmt.Errorf("failed to detect the capabilities of the database: %w", err)
+	}
+	defer conn.Close()
+	caps := Capabilities{
+		Version:                    probes.version(),
+		MaxSupportedStorageVersion: probes.storageVersion(),
+		HasInterrupt:               probes.interrupt(),
+		HasArrowExport:             probes.arrowExport(),
+		extensions:                 make(map[string]bool),
+	}
+	// Releases of the engine without show_loaded_extensions have no
+	// extensions.
+	if names, err := probes.loadedExtensions(conn); err == nil {
+		for _, name := range names {
+			caps.extensions[strings.ToLower(name)] = true
+		}
+	}
+	for typeID := range dataTypeNames {
+		if typeID == C.LBUG_ANY || typeID == C.LBUG_POINTER {
+			continue
+		}
+		if cypherType, ok := probedTypes[typeID]; ok && !probes.castsTo(conn, cypherType) {
+			continue
+		}
+		caps.SupportedLogicalTypes = append(caps.SupportedLogicalTypes, newDataType(typeID))
+	}
+	sort.Slice(caps.SupportedLogicalTypes, func(i, j int) bool {
+		return caps.SupportedLogicalTypes[i].TypeID < caps.SupportedLogicalTypes[j].TypeID
+	})
+	return caps, nil
+}
+
+// clone returns a copy of the capabilities that does not share the slice of
+// supported types with the cache.
+func (caps Capabilities) clone() Capabilities {
+	clone := caps
+	clone.SupportedLogicalTypes = append([]DataType(nil), caps.SupportedLogicalTypes...)
+	return clone
+}
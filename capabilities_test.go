@@ -0,0 +1,100 @@
+package lbug
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubCapabilityProbes replaces the probes of the engine for the duration of
+// the test with probes simulating an engine without interrupt, UUID and INT128
+// support, whose loaded extensions are listed with the given result. It returns
+// the number of times the version has been probed.
+func stubCapabilityProbes(t *testing.T, extensions []string, extensionsErr error) *int {
+	t.Helper()
+	probed := 0
+	saved := engineProbes
+	t.Cleanup(func() { engineProbes = saved })
+	engineProbes = capabilityProbes{
+		version: func() string {
+			probed++
+			return "0.9.0"
+		},
+		storageVersion: func() uint64 { return 36 },
+		interrupt:      func() bool { return false },
+		arrowExport:    func() bool { return true },
+		loadedExtensions: func(*Connection) ([]string, error) {
+			return extensions, extensionsErr
+		},
+		castsTo: func(_ *Connection, cypherType string) bool {
+			return cypherType != "UUID" && cypherType != "INT128"
+		},
+	}
+	return &probed
+}
+
+func openCapabilityTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	t.Cleanup(db.Close)
+	return db
+}
+
+func TestCapabilitiesStubbed(t *testing.T) {
+	probed := stubCapabilityProbes(t, []string{"FTS"}, nil)
+	db := openCapabilityTestDatabase(t)
+	caps, err := db.Capabilities()
+	assert.Nil(t, err)
+	assert.Equal(t, "0.9.0", caps.Version)
+	assert.Equal(t, uint64(36), caps.MaxSupportedStorageVersion)
+	assert.False(t, caps.HasInterrupt)
+	assert.True(t, caps.HasArrowExport)
+	assert.True(t, caps.HasExtension("fts"))
+	assert.False(t, caps.HasExtension("vector"))
+	assert.True(t, caps.SupportsType("STRING"))
+	assert.True(t, caps.SupportsType("node"))
+	assert.False(t, caps.SupportsType("UUID"))
+	assert.False(t, caps.SupportsType("INT128"))
+	assert.False(t, caps.SupportsType("ANY"))
+	for i := 1; i < len(caps.SupportedLogicalTypes); i++ {
+		assert.True(t, caps.SupportedLogicalTypes[i-1].TypeID < caps.SupportedLogicalTypes[i].TypeID)
+	}
+
+	// The capabilities are cached, and the cache is not changed by the
+	// caller.
+	caps.SupportedLogicalTypes[0].Name = "changed"
+	again, err := db.Capabilities()
+	assert.Nil(t, err)
+	assert.NotEqual(t, "changed", again.SupportedLogicalTypes[0].Name)
+	assert.Equal(t, 1, *probed)
+	_, err = db.RefreshCapabilities()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, *probed)
+}
+
+func TestCapabilitiesWithoutExtensionListing(t *testing.T) {
+	stubCapabilityProbes(t, nil, errors.New("function show_loaded_extensions does not exist"))
+	db := openCapabilityTestDatabase(t)
+	caps, err := db.Capabilities()
+	assert.Nil(t, err)
+	assert.False(t, caps.HasExtension("fts"))
+}
+
+func TestCapabilities(t *testing.T) {
+	db := openCapabilityTestDatabase(t)
+	caps, err := db.Capabilities()
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", caps.Version)
+	assert.True(t, caps.MaxSupportedStorageVersion > 0)
+	assert.True(t, caps.HasInterrupt)
+	assert.False(t, caps.HasArrowExport)
+	for _, name := range []string{"INT64", "STRING", "LIST", "STRUCT", "NODE", "REL", "SERIAL"} {
+		assert.True(t, caps.SupportsType(name), name)
+	}
+
+	closed := &Database{isClosed: true}
+	_, err = closed.Capabilities()
+	assert.ErrorIs(t, err, ErrClosed)
+}
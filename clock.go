@@ -0,0 +1,60 @@
+package lbug
+
+import "time"
+
+// Clock tells the time to the helpers of a Connection that stamp the time of
+// writes, see AutoTimestamps, and measures the latency of the batches of
+// AdaptiveBatching. Tests set a fixed clock with Connection.SetClock to assert
+// the stored values.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to the Clock interface.
+type ClockFunc func() time.Time
+
+// Now returns the result of the function.
+func (clock ClockFunc) Now() time.Time {
+	return clock()
+}
+
+// AutoTimestamps names the properties set to the time of the clock of the
+// connection by CreateNode and UpdateNode. Empty names are not set, and a
+// property whose value is given explicitly keeps it. The time is bound as a
+// TIMESTAMP of its instant, regardless of the location of the clock.
+type AutoTimestamps struct {
+	// CreatedAt is set by CreateNode.
+	CreatedAt string
+	// UpdatedAt is set by CreateNode and UpdateNode.
+	UpdatedAt string
+}
+
+// stamp sets the properties of the timestamps of a write to now, unless they
+// are set already.
+func (timestamps AutoTimestamps) stamp(properties map[string]any, now time.Time, create bool) {
+	if create && timestamps.CreatedAt != "" {
+		if _, ok := properties[timestamps.CreatedAt]; !ok {
+			properties[timestamps.CreatedAt] = now
+		}
+	}
+	if timestamps.UpdatedAt != "" {
+		if _, ok := properties[timestamps.UpdatedAt]; !ok {
+			properties[timestamps.UpdatedAt] = now
+		}
+	}
+}
+
+// SetClock sets the clock used by the helpers of the connection that stamp
+// the time of writes or measure the latency of batches. A nil clock restores
+// the real clock.
+func (conn *Connection) SetClock(clock Clock) {
+	conn.clock = clock
+}
+
+// now returns the time of the clock of the connection.
+func (conn *Connection) now() time.Time {
+	if conn.clock == nil {
+		return time.Now()
+	}
+	return conn.clock.Now()
+}
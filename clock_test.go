@@ -0,0 +1,36 @@
+package lbug
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionClock(t *testing.T) {
+	conn := &Connection{}
+	assert.True(t, time.Since(conn.now()) < time.Minute)
+	fixed := time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)
+	conn.SetClock(ClockFunc(func() time.Time { return fixed }))
+	assert.Equal(t, fixed, conn.now())
+	conn.SetClock(nil)
+	assert.NotEqual(t, fixed, conn.now())
+}
+
+func TestAutoTimestampsStamp(t *testing.T) {
+	now := time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Hour)
+	timestamps := AutoTimestamps{CreatedAt: "created_at", UpdatedAt: "updated_at"}
+
+	properties := map[string]any{"name": "Alice"}
+	timestamps.stamp(properties, now, true)
+	assert.Equal(t, map[string]any{"name": "Alice", "created_at": now, "updated_at": now}, properties)
+
+	// Updates do not set the creation time, and explicit values are kept.
+	properties = map[string]any{"updated_at": earlier}
+	timestamps.stamp(properties, now, false)
+	assert.Equal(t, map[string]any{"updated_at": earlier}, properties)
+	properties = map[string]any{}
+	AutoTimestamps{UpdatedAt: "updated_at"}.stamp(properties, now, true)
+	assert.Equal(t, map[string]any{"updated_at": now}, properties)
+}
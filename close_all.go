@@ -0,0 +1,184 @@
+package lbug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// CloseAll closes every Database, Connection, PreparedStatement, QueryResult
+// and FlatTuple open in the process, e.g. when a server shuts down. The
+// queries running on the connections are interrupted, the temporary tables of
+// the connections are dropped, and the handles are closed in dependency
+// order: the tuples, results and statements of a connection before the
+// connection, and the connections of a database before the database.
+//
+// CloseAll may be called concurrently with other operations and with itself.
+// An operation on a handle that has been closed returns an error matching
+// ErrClosed. Closing a connection waits for the operations running on it to
+// complete. If the context is done before all handles are closed, CloseAll
+// returns an error joining one error per handle that is not closed yet, which
+// matches ErrCloseTimedOut; those handles are still closed in the background,
+// and CloseAll may be called again to wait for them. Once the handles are
+// closed, CloseAll waits for the goroutines started by the package listed by
+// BackgroundTasks to return, or returns an error per task still running,
+// matching ErrCloseTimedOut, if the context is done first. CloseAll returns
+// nil if there is nothing to close.
+//
+// The package does not release C handles with finalizers, so once CloseAll
+// returns nil the C resources of the handles have been released and no
+// cleanup is pending, e.g. before the volume of a database is unmounted.
+// With EnableHandleDebugging, the handles open when CloseAll is called are
+// reported with their creation stacks.
+func CloseAll(ctx context.Context) error {
+	reportLeaks()
+	return closeObjects(ctx, handles.openObjects())
+}
+
+// closeObjects closes the databases and connections among the objects as
+// described by CloseAll.
+func closeObjects(ctx context.Context, objects []any) error {
+	var databases []*Database
+	connections := make(map[*Database][]*Connection)
+	for _, object := range objects {
+		switch object := object.(type) {
+		case *Database:
+			databases = append(databases, object)
+		case *Connection:
+			connections[object.database] = append(connections[object.database], object)
+		}
+	}
+	pending := &pendingHandles{names: make(map[uint64]string)}
+	for _, db := range databases {
+		pending.add(db.handleID, HandleDatabase)
+	}
+	for _, conns := range connections {
+		for _, conn := range conns {
+			pending.add(conn.handleID, HandleConnection)
+		}
+	}
+
+	var wg sync.WaitGroup
+	closeConnections := func(conns []*Connection) {
+		var connWg sync.WaitGroup
+		for _, conn := range conns {
+			connWg.Add(1)
+			background.spawnUnbounded("CloseAll connection close", conn, func() {
+				defer connWg.Done()
+				conn.closeForShutdown()
+				pending.done(conn.handleID)
+			})
+		}
+		connWg.Wait()
+	}
+	for _, db := range databases {
+		wg.Add(1)
+		background.spawnUnbounded("CloseAll database close", nil, func() {
+			defer wg.Done()
+			closeConnections(connections[db])
+			// Joins the close of a concurrent Database.CloseWithContext.
+			<-db.closing.start("Database close", nil, db.Close)
+			pending.done(db.handleID)
+		})
+		delete(connections, db)
+	}
+	// The databases of the remaining connections have already been closed.
+	for _, conns := range connections {
+		wg.Add(1)
+		background.spawnUnbounded("CloseAll connection close", nil, func() {
+			defer wg.Done()
+			closeConnections(conns)
+		})
+	}
+	done := make(chan struct{})
+	background.spawnUnbounded("CloseAll", nil, func() {
+		wg.Wait()
+		close(done)
+	})
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return pending.errors(closeTimedOut(ctx))
+	}
+	return background.wait(ctx)
+}
+
+// closeForShutdown interrupts the running query of the connection, drops its
+// temporary tables and closes it with closeWithHandles.
+func (conn *Connection) closeForShutdown() {
+	// The connection is closed by a goroutine of CloseAll, which takes a
+	// single-threaded connection over.
+	conn.closeMutex.owner.release()
+	conn.Interrupt()
+	conn.dropTempTables()
+	conn.closeWithHandles()
+}
+
+// closeWithHandles closes the connection with the tuples, results and
+// statements created from it.
+func (conn *Connection) closeWithHandles() {
+	conn.closeMutex.Lock()
+	defer conn.closeMutex.Unlock()
+	// No handle can be created on the connection while closeMutex is held,
+	// so its children are all of them.
+	var tuples []*FlatTuple
+	var results []*QueryResult
+	var statements []*PreparedStatement
+	for _, object := range conn.openChildren() {
+		switch object := object.(type) {
+		case *FlatTuple:
+			tuples = append(tuples, object)
+		case *QueryResult:
+			results = append(results, object)
+		case *PreparedStatement:
+			statements = append(statements, object)
+		}
+	}
+	for _, tuple := range tuples {
+		tuple.close()
+	}
+	for _, result := range results {
+		result.close()
+	}
+	for _, statement := range statements {
+		statement.close()
+	}
+	conn.close()
+}
+
+// pendingHandles tracks the handles CloseAll has not closed yet.
+type pendingHandles struct {
+	mu    sync.Mutex
+	names map[uint64]string
+}
+
+func (pending *pendingHandles) add(id uint64, kind HandleKind) {
+	pending.mu.Lock()
+	pending.names[id] = fmt.Sprintf("%v %d", kind, id)
+	pending.mu.Unlock()
+}
+
+func (pending *pendingHandles) done(id uint64) {
+	pending.mu.Lock()
+	delete(pending.names, id)
+	pending.mu.Unlock()
+}
+
+// errors returns an error per pending handle, ordered by handle ID, joined
+// into one.
+func (pending *pendingHandles) errors(cause error) error {
+	pending.mu.Lock()
+	defer pending.mu.Unlock()
+	ids := make([]uint64, 0, len(pending.names))
+	for id := range pending.names {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	errs := make([]error, len(ids))
+	for i, id := range ids {
+		errs[i] = &Error{Op: OpClose, Message: "failed to close " + pending.names[id], Err: cause}
+	}
+	return errors.Join(errs...)
+}
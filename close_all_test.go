@@ -0,0 +1,135 @@
+package lbug
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// objectsOf returns the open objects of the registry that belong to the
+// database, so that tests do not close the handles of other tests.
+func objectsOf(db *Database) []any {
+	var objects []any
+	for _, object := range handles.openObjects() {
+		switch object := object.(type) {
+		case *Database:
+			if object == db {
+				objects = append(objects, object)
+			}
+		case *Connection:
+			if object.database == db {
+				objects = append(objects, object)
+			}
+		}
+	}
+	return objects
+}
+
+func TestCloseAll(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	other, err := OpenConnection(db)
+	assert.Nil(t, err)
+	stmt, err := conn.Prepare("RETURN $x")
+	assert.Nil(t, err)
+	result, err := conn.Query("UNWIND range(1, 10) AS i RETURN i;")
+	assert.Nil(t, err)
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+	_, _, err = other.TempTable(tempTableSpec)
+	assert.Nil(t, err)
+
+	assert.Nil(t, closeObjects(context.Background(), objectsOf(db)))
+	assert.True(t, db.isClosed)
+	assert.True(t, conn.isClosed)
+	assert.True(t, other.isClosed)
+	assert.True(t, stmt.isClosed)
+	assert.True(t, result.isClosed)
+	assert.True(t, tuple.isClosed)
+	assert.Empty(t, objectsOf(db))
+
+	_, err = conn.Query("RETURN 1;")
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = conn.Execute(stmt, nil)
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = stmt.Exec(nil)
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = result.Next()
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = tuple.GetValue(0)
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = OpenConnection(db)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.False(t, result.HasNext())
+
+	// Closing again is a no-op.
+	tuple.Close()
+	result.Close()
+	stmt.Close()
+	conn.Close()
+	db.Close()
+	assert.Nil(t, closeObjects(context.Background(), []any{db, conn}))
+}
+
+func TestCloseAllInterruptsRunningQuery(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	started := make(chan struct{})
+	finished := make(chan error, 1)
+	go func() {
+		close(started)
+		result, err := conn.Query("UNWIND range(1, 1000000) AS i UNWIND range(1, 1000000) AS j RETURN count(*);")
+		if err == nil {
+			result.Close()
+		}
+		finished <- err
+	}()
+	<-started
+	time.Sleep(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	assert.Nil(t, closeObjects(ctx, objectsOf(db)))
+	assert.NotNil(t, <-finished)
+	assert.True(t, conn.isClosed)
+}
+
+func TestCloseAllContextExpired(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	// Hold the connection so that it cannot be closed before the context
+	// expires.
+	conn.closeMutex.RLock()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = closeObjects(ctx, objectsOf(db))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.ErrorIs(t, err, ErrCloseTimedOut)
+	assert.ErrorContains(t, err, "failed to close Connection")
+	assert.ErrorContains(t, err, "failed to close Database")
+	conn.closeMutex.RUnlock()
+	// The handles are closed in the background.
+	deadline := time.Now().Add(10 * time.Second)
+	for len(objectsOf(db)) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Empty(t, objectsOf(db))
+}
+
+func TestClosedError(t *testing.T) {
+	checkBackgroundTasks(t)
+	err := &closedError{"failed to get value because the tuple is closed"}
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.Equal(t, "failed to get value because the tuple is closed", err.Error())
+}
@@ -0,0 +1,120 @@
+package lbug
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// CloseWithContext closes the connection like Close, waiting for the
+// operations running on it to complete, unless the context is done first.
+// In that case the running query is interrupted and CloseWithContext returns
+// an error matching ErrCloseTimedOut and the error of the context, while the
+// close continues in the background: the connection must not be used
+// anymore, and its C resources are released once the close completes.
+//
+// CloseWithContext and Close may be called again after a timeout, e.g. with
+// a longer deadline: they wait for the close already in progress instead of
+// starting another one.
+func (conn *Connection) CloseWithContext(ctx context.Context) error {
+	done := conn.closing.start("Connection close", conn, conn.closeInBackground)
+	return awaitClose(ctx, done, HandleConnection, conn.handleID, conn.Interrupt)
+}
+
+// CloseWithContext closes the connections opened on the database, waiting
+// for the operations running on them to complete, and then the database,
+// unless the context is done first. In that case the running queries are
+// interrupted and CloseWithContext returns an error matching
+// ErrCloseTimedOut and the error of the context, while the close continues
+// in the background, as for Connection.CloseWithContext.
+//
+// Unlike Close, which must only be called once the connections of the
+// database are closed, CloseWithContext may be called while they are in
+// use. It may be called again after a timeout.
+func (db *Database) CloseWithContext(ctx context.Context) error {
+	done := db.closing.start("Database close", nil, func() {
+		connections := db.openConnections()
+		var wg sync.WaitGroup
+		for _, conn := range connections {
+			wg.Add(1)
+			background.spawnUnbounded("Database close of a connection", conn, func() {
+				defer wg.Done()
+				<-conn.closing.start("Connection close", conn, conn.closeInBackground)
+			})
+		}
+		wg.Wait()
+		db.Close()
+	})
+	return awaitClose(ctx, done, HandleDatabase, db.handleID, func() {
+		for _, conn := range db.openConnections() {
+			conn.Interrupt()
+		}
+	})
+}
+
+// closeInBackground closes the connection from the goroutine of a
+// backgroundClose, which takes a single-threaded connection over.
+func (conn *Connection) closeInBackground() {
+	conn.closeMutex.owner.release()
+	conn.Close()
+}
+
+// openConnections returns the connections opened on the database and not
+// closed yet.
+func (db *Database) openConnections() []*Connection {
+	var connections []*Connection
+	for _, object := range handles.openObjects() {
+		if conn, ok := object.(*Connection); ok && conn.database == db {
+			connections = append(connections, conn)
+		}
+	}
+	return connections
+}
+
+// backgroundClose runs the close of a handle in a goroutine, once, so that
+// the callers of CloseWithContext can stop waiting for it.
+type backgroundClose struct {
+	mutex sync.Mutex
+	done  chan struct{}
+}
+
+// start runs closeHandle in the background as the task of the given name and
+// connection, which may be nil, unless a close has already been started, and
+// returns a channel closed once that close returns.
+func (closing *backgroundClose) start(name string, conn *Connection, closeHandle func()) <-chan struct{} {
+	closing.mutex.Lock()
+	defer closing.mutex.Unlock()
+	if closing.done == nil {
+		done := make(chan struct{})
+		closing.done = done
+		background.spawnUnbounded(name, conn, func() {
+			defer close(done)
+			closeHandle()
+		})
+	}
+	return closing.done
+}
+
+// awaitClose waits for the close of a handle to complete. If the context is
+// done first, it calls interrupt and returns an error matching
+// ErrCloseTimedOut.
+func awaitClose(ctx context.Context, done <-chan struct{}, kind HandleKind, id uint64, interrupt func()) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	select {
+	case <-done:
+		return nil
+	default:
+	}
+	interrupt()
+	return &Error{Op: OpClose, Message: fmt.Sprintf("failed to close %v %d", kind, id), Err: closeTimedOut(ctx)}
+}
+
+// closeTimedOut returns the cause of the errors of a close interrupted by the
+// context.
+func closeTimedOut(ctx context.Context) error {
+	return fmt.Errorf("%w: %w", ErrCloseTimedOut, ctx.Err())
+}
@@ -0,0 +1,109 @@
+package lbug
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionCloseWithContext(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, conn := SetupTestDatabase(t)
+	defer db.Close()
+	assert.Nil(t, conn.CloseWithContext(context.Background()))
+	assert.True(t, conn.isClosed)
+	// Closing again is a no-op.
+	assert.Nil(t, conn.CloseWithContext(context.Background()))
+	conn.Close()
+}
+
+func TestConnectionCloseWithContextTimedOut(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, conn := SetupTestDatabase(t)
+	defer db.Close()
+	// Hold the connection as a running operation does, so that it cannot be
+	// closed before the context expires.
+	conn.closeMutex.RLock()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := conn.CloseWithContext(ctx)
+	assert.ErrorIs(t, err, ErrCloseTimedOut)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "failed to close Connection")
+	assert.False(t, conn.closed())
+
+	// A retry waits for the close in progress.
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, conn.CloseWithContext(ctx), ErrCloseTimedOut)
+	conn.closeMutex.RUnlock()
+	assert.Nil(t, conn.CloseWithContext(context.Background()))
+	assert.True(t, conn.closed())
+	conn.Close()
+}
+
+// TestDatabaseCloseWithContext closes a database while a connection holds an
+// open transaction and another runs a long query, which slows the close down.
+func TestDatabaseCloseWithContext(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	mustRun(t, conn, "CREATE NODE TABLE Item(id INT64, PRIMARY KEY(id));")
+	mustRun(t, conn, "BEGIN TRANSACTION;")
+	mustRun(t, conn, "CREATE (:Item {id: 1});")
+	other, err := OpenConnection(db)
+	assert.Nil(t, err)
+	started := make(chan struct{})
+	finished := make(chan error, 1)
+	go func() {
+		close(started)
+		result, err := other.Query("UNWIND range(1, 1000000) AS i UNWIND range(1, 1000000) AS j RETURN count(*);")
+		if err == nil {
+			result.Close()
+		}
+		finished <- err
+	}()
+	<-started
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = db.CloseWithContext(ctx)
+	assert.ErrorIs(t, err, ErrCloseTimedOut)
+	assert.ErrorContains(t, err, "failed to close Database")
+	// The running query is interrupted on timeout, and the close completes
+	// in the background.
+	assert.NotNil(t, <-finished)
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	assert.Nil(t, db.CloseWithContext(ctx))
+	assert.True(t, db.closed())
+	assert.True(t, conn.closed())
+	assert.True(t, other.closed())
+	assert.Empty(t, objectsOf(db))
+
+	// Closing again is a no-op.
+	db.Close()
+	conn.Close()
+	assert.Nil(t, db.CloseWithContext(context.Background()))
+}
+
+func TestCloseAllJoinsCloseWithContext(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	conn.closeMutex.RLock()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, db.CloseWithContext(ctx), ErrCloseTimedOut)
+	conn.closeMutex.RUnlock()
+	assert.Nil(t, closeObjects(context.Background(), objectsOf(db)))
+	assert.True(t, db.closed())
+	assert.True(t, conn.closed())
+}
@@ -0,0 +1,139 @@
+package lbug
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// closeConcurrently calls each close function from several goroutines at
+// once, twice per goroutine, one function after the other.
+func closeConcurrently(closes ...func()) {
+	for _, close := range closes {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				close()
+				close()
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func TestDoubleClose(t *testing.T) {
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	stmt, err := conn.Prepare("RETURN $x AS x;")
+	assert.Nil(t, err)
+	result, err := conn.Execute(stmt, map[string]any{"x": int64(1)})
+	assert.Nil(t, err)
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+
+	closeConcurrently(tuple.Close, result.Close, stmt.Close, conn.Close, db.Close)
+	assert.True(t, tuple.isClosed)
+	assert.True(t, result.isClosed)
+	assert.True(t, stmt.isClosed)
+	assert.True(t, conn.isClosed)
+	assert.True(t, db.isClosed)
+	_, err = tuple.GetValue(0)
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = result.Next()
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = stmt.Exec(nil)
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = conn.Query("RETURN 1;")
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = OpenConnection(db)
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestCloseAfterError(t *testing.T) {
+	db, conn := SetupTestDatabase(t)
+	defer db.Close()
+	defer conn.Close()
+
+	// A statement that failed to prepare.
+	stmt, err := conn.Prepare("RETURN $x +;")
+	assert.NotNil(t, err)
+	assert.NotPanics(t, func() {
+		stmt.Close()
+		stmt.Close()
+	})
+
+	// A tuple returned with the error of Next past the last tuple.
+	result, err := conn.Query("RETURN 1;")
+	assert.Nil(t, err)
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+	tuple.Close()
+	tuple, err = result.Next()
+	assert.NotNil(t, err)
+	assert.NotPanics(t, func() {
+		tuple.Close()
+		tuple.Close()
+		result.Close()
+		result.Close()
+	})
+
+	// A statement whose execution failed.
+	stmt, err = conn.Prepare("RETURN CAST($x AS INT64);")
+	assert.Nil(t, err)
+	_, err = conn.Execute(stmt, map[string]any{"x": "not a number"})
+	assert.NotNil(t, err)
+	stmt.Close()
+	stmt.Close()
+	assert.True(t, stmt.isClosed)
+}
+
+func TestCloseWithChildren(t *testing.T) {
+	db, conn := SetupTestDatabase(t)
+	defer db.Close()
+	defer conn.Close()
+
+	// Closing a result closes its tuples, whose C tuples belong to it.
+	result, err := conn.Query("RETURN 1 AS x;")
+	assert.Nil(t, err)
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+	result.Close()
+	_, err = tuple.GetValue(0)
+	assert.ErrorIs(t, err, ErrClosed)
+	tuple.Close()
+
+	// Results may outlive their statement.
+	stmt, err := conn.Prepare("RETURN $x AS x;")
+	assert.Nil(t, err)
+	result, err = conn.Execute(stmt, map[string]any{"x": int64(2)})
+	assert.Nil(t, err)
+	stmt.Close()
+	tuple, err = result.Next()
+	assert.Nil(t, err)
+	value, err = tuple.GetValue(0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), value)
+	tuple.Close()
+	result.Close()
+
+	// Closing a connection with open children is done with CloseAll, which
+	// closes the children first.
+	other, err := OpenConnection(db)
+	assert.Nil(t, err)
+	stmt, err = other.Prepare("RETURN 1;")
+	assert.Nil(t, err)
+	result, err = other.Query("RETURN 1;")
+	assert.Nil(t, err)
+	tuple, err = result.Next()
+	assert.Nil(t, err)
+	other.closeForShutdown()
+	assert.True(t, stmt.isClosed)
+	assert.True(t, result.isClosed)
+	assert.True(t, tuple.isClosed)
+	closeConcurrently(tuple.Close, result.Close, stmt.Close, other.Close)
+}
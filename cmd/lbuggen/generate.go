@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	lbug "github.com/LadybugDB/go-ladybug"
+)
+
+// runtimePackage is the import path of the runtime support of the generated
+// code.
+const runtimePackage = "github.com/LadybugDB/go-ladybug/lbuggen"
+
+// importPaths are the import paths of the packages qualifying the Go types of
+// the values.
+var importPaths = map[string]string{
+	"big":     "math/big",
+	"time":    "time",
+	"uuid":    "github.com/google/uuid",
+	"decimal": "github.com/shopspring/decimal",
+	"lbug":    "github.com/LadybugDB/go-ladybug",
+}
+
+// typeSupport returns the support of the logical types by the bindings, by
+// Cypher name.
+func typeSupport() map[string]lbug.TypeSupport {
+	support := make(map[string]lbug.TypeSupport)
+	for _, entry := range lbug.SupportMatrix() {
+		support[entry.Type.Name] = entry
+	}
+	return support
+}
+
+// goType returns the Go type of the values of a column or parameter of the
+// declared type, or an error if the bindings do not support the type for the
+// capability.
+func goType(support map[string]lbug.TypeSupport, declared column, capability lbug.TypeCapability) (string, error) {
+	entry, ok := support[declared.Type]
+	if !ok {
+		return "", fmt.Errorf("unknown type %s of %s", declared.Type, declared.Name)
+	}
+	if !entry.Capabilities.Has(capability) || entry.GoType == "" {
+		return "", fmt.Errorf("type %s of %s is not supported (%s)", declared.Type, declared.Name, entry.Capabilities)
+	}
+	name := strings.ReplaceAll(entry.GoType, "interface {}", "any")
+	if name == "[]uint8" {
+		name = "[]byte"
+	}
+	if declared.Nullable && !strings.HasPrefix(name, "*") && !strings.HasPrefix(name, "[]") && !strings.HasPrefix(name, "map[") {
+		name = "*" + name
+	}
+	return name, nil
+}
+
+// qualifierPattern matches the package qualifiers of a Go type.
+var qualifierPattern = regexp.MustCompile(`([a-z]+)\.`)
+
+// generate returns the Go source of the functions running the queries, in
+// the package.
+func generate(pkg string, queries []*query, support map[string]lbug.TypeSupport) ([]byte, error) {
+	imports := map[string]bool{"context": true, runtimePackage: true}
+	addImports := func(typeName string) {
+		for _, match := range qualifierPattern.FindAllStringSubmatch(typeName, -1) {
+			if path, ok := importPaths[match[1]]; ok {
+				imports[path] = true
+			}
+		}
+	}
+
+	// The row types, in the order of their first query.
+	type rowType struct {
+		name    string
+		columns []column
+		fields  []string
+		queries []string
+	}
+	var rows []*rowType
+	rowsByName := make(map[string]*rowType)
+	names := make(map[string]*query)
+	for _, q := range queries {
+		if previous, ok := names[q.Name]; ok {
+			return nil, fmt.Errorf("%s: query %s is already declared at %s", q.location(), q.Name, previous.location())
+		}
+		names[q.Name] = q
+		if q.Row == "" {
+			continue
+		}
+		row, ok := rowsByName[q.Row]
+		if !ok {
+			row = &rowType{name: q.Row, columns: q.Columns}
+			for _, c := range q.Columns {
+				typeName, err := goType(support, c, lbug.CapabilityCollect)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", q.location(), err)
+				}
+				addImports(typeName)
+				row.fields = append(row.fields, fmt.Sprintf("%s %s `lbug:%q`", exportedName(c.Name), typeName, c.Name))
+			}
+			rows = append(rows, row)
+			rowsByName[q.Row] = row
+		} else if !equalColumns(row.columns, q.Columns) {
+			return nil, fmt.Errorf("%s: query %s returns %s with other columns than query %s", q.location(), q.Name, q.Row, row.queries[0])
+		}
+		row.queries = append(row.queries, q.Name)
+	}
+
+	var body bytes.Buffer
+	for _, row := range rows {
+		fmt.Fprintf(&body, "\n// %s is a row returned by %s.\ntype %s struct {\n", row.name, joinNames(row.queries), row.name)
+		for _, field := range row.fields {
+			fmt.Fprintf(&body, "\t%s\n", field)
+		}
+		body.WriteString("}\n")
+	}
+	for _, q := range queries {
+		constant := unexportedName(q.Name) + "Query"
+		fmt.Fprintf(&body, "\nconst %s = %s\n\n", constant, goStringLiteral(q.Text))
+		fmt.Fprintf(&body, "// %s runs the :%s query %s of %s.\n", q.Name, q.Kind, q.Name, filepath.Base(q.File))
+		if len(q.Doc) > 0 {
+			body.WriteString("//\n")
+			for _, line := range q.Doc {
+				fmt.Fprintf(&body, "// %s\n", line)
+			}
+		}
+		params := []string{"ctx context.Context", "conn *lbuggen.Conn"}
+		var args []string
+		for _, p := range q.Params {
+			typeName, err := goType(support, p, lbug.CapabilityBind)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", q.location(), err)
+			}
+			addImports(typeName)
+			name := parameterName(p.Name)
+			params = append(params, name+" "+typeName)
+			args = append(args, fmt.Sprintf("%q: %s", p.Name, name))
+		}
+		argsMap := "nil"
+		if len(args) > 0 {
+			argsMap = "map[string]any{" + strings.Join(args, ", ") + "}"
+		}
+		signature := fmt.Sprintf("func %s(%s)", q.Name, strings.Join(params, ", "))
+		switch q.Kind {
+		case kindOne:
+			fmt.Fprintf(&body, "%s (%s, error) {\n\treturn lbuggen.One[%s](ctx, conn, %s, %s)\n}\n", signature, q.Row, q.Row, constant, argsMap)
+		case kindMany:
+			fmt.Fprintf(&body, "%s ([]%s, error) {\n\treturn lbuggen.All[%s](ctx, conn, %s, %s)\n}\n", signature, q.Row, q.Row, constant, argsMap)
+		case kindExec:
+			fmt.Fprintf(&body, "%s error {\n\treturn lbuggen.Exec(ctx, conn, %s, %s)\n}\n", signature, constant, argsMap)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by lbuggen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	// The standard library first, as goimports groups them.
+	sort.Slice(paths, func(i, j int) bool {
+		iStd, jStd := !strings.Contains(paths[i], "."), !strings.Contains(paths[j], ".")
+		if iStd != jStd {
+			return iStd
+		}
+		return paths[i] < paths[j]
+	})
+	for i, path := range paths {
+		if i > 0 && !strings.Contains(paths[i-1], ".") && strings.Contains(path, ".") {
+			out.WriteString("\n")
+		}
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	out.WriteString(")\n")
+	out.Write(body.Bytes())
+	source, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format the generated code: %w", err)
+	}
+	return source, nil
+}
+
+func equalColumns(a, b []column) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// joinNames joins the names as in "A, B and C".
+func joinNames(names []string) string {
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// goStringLiteral returns a raw string literal of the text, or a quoted one
+// if the text holds backquotes.
+func goStringLiteral(text string) string {
+	if strings.Contains(text, "`") {
+		return strconv.Quote(text)
+	}
+	return "`" + text + "`"
+}
+
+// initialisms are the words written in upper case in Go identifiers.
+var initialisms = map[string]bool{"ID": true, "URL": true, "UUID": true, "JSON": true, "HTTP": true, "API": true}
+
+// exportedName returns the exported Go identifier of a Cypher name, e.g.
+// UserID for user_id.
+func exportedName(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' }) {
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	if b.Len() == 0 {
+		return "X"
+	}
+	return b.String()
+}
+
+// unexportedName returns the unexported Go identifier of a name, e.g. userID
+// for user_id or getUserByID for GetUserByID.
+func unexportedName(name string) string {
+	exported := exportedName(name)
+	for word := range initialisms {
+		if strings.HasPrefix(exported, word) && (len(exported) == len(word) || unicode.IsUpper(rune(exported[len(word)]))) {
+			return strings.ToLower(word) + exported[len(word):]
+		}
+	}
+	runes := []rune(exported)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+// parameterName returns the name of the argument of the generated function
+// for a parameter of a query, which must not be a keyword or one of the
+// other arguments.
+func parameterName(name string) string {
+	name = unexportedName(name)
+	if token.IsKeyword(name) || name == "ctx" || name == "conn" || name == "lbuggen" {
+		name += "_"
+	}
+	return name
+}
@@ -0,0 +1,122 @@
+// lbuggen generates typed Go functions from annotated Cypher queries, built on
+// the runtime support of the lbuggen package.
+//
+// Usage:
+//
+//	go run ./cmd/lbuggen -schema schema.cypher -queries ./queries -out ./db/queries.go
+//
+// Every .cypher file of the queries directory holds queries annotated with
+// comments:
+//
+//	// name: GetUserByID :one
+//	// Returns the user with the id.
+//	// params: id INT64
+//	// returns: User(id INT64, name STRING, age INT64?)
+//	MATCH (u:User) WHERE u.id = $id RETURN u.id AS id, u.name AS name, u.age AS age;
+//
+// Queries of kind :one return a single row, :many all the rows and :exec
+// none. Types are the Cypher names of the logical types, e.g. INT64, DATE or
+// LIST, and returned columns of types followed by "?" may be NULL. For the
+// query above, lbuggen generates:
+//
+//	type User struct {
+//		ID   int64  `lbug:"id"`
+//		Name string `lbug:"name"`
+//		Age  *int64 `lbug:"age"`
+//	}
+//
+//	func GetUserByID(ctx context.Context, conn *lbuggen.Conn, id int64) (User, error)
+//
+// Before generating the code, the queries are validated against an empty
+// database created with the DDL statements of the schema file: each query
+// must prepare, use exactly the declared parameters, and return the declared
+// columns with their types, so that typos in labels, properties and
+// annotations are caught at generation time.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	schemaPath := flag.String("schema", "", "path of the file of DDL statements creating the schema")
+	queriesDir := flag.String("queries", ".", "directory of the annotated .cypher files")
+	outPath := flag.String("out", "queries.go", "path of the generated Go file")
+	pkg := flag.String("package", "", "package of the generated code (default: name of the directory of -out)")
+	flag.Parse()
+	if *schemaPath == "" {
+		fatal(fmt.Errorf("-schema is required"))
+	}
+	if *pkg == "" {
+		absolute, err := filepath.Abs(*outPath)
+		if err != nil {
+			fatal(err)
+		}
+		*pkg = strings.ReplaceAll(filepath.Base(filepath.Dir(absolute)), "-", "_")
+	}
+	if err := run(*schemaPath, *queriesDir, *outPath, *pkg); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "lbuggen:", err)
+	os.Exit(1)
+}
+
+// run generates the code of the queries of the directory, validated against
+// the schema, into the output file.
+func run(schemaPath string, queriesDir string, outPath string, pkg string) error {
+	queries, err := loadQueries(queriesDir)
+	if err != nil {
+		return err
+	}
+	ddl, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return err
+	}
+	schema, err := openSchemaDatabase(string(ddl))
+	if err != nil {
+		return err
+	}
+	defer schema.Close()
+	if err := schema.validateAll(queries); err != nil {
+		return err
+	}
+	source, err := generate(pkg, queries, typeSupport())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outPath, source, 0o644)
+}
+
+// loadQueries parses the queries of the .cypher files of the directory, in
+// the order of the file names.
+func loadQueries(dir string) ([]*query, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.cypher"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var queries []*query
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseQueries(filepath.Base(path), string(content))
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, parsed...)
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no annotated queries in %s", dir)
+	}
+	return queries, nil
+}
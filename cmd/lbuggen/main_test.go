@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestGenerateGolden generates the code of the sample queries and compares it
+// with testdata/queries.go.golden. Run with -update to rewrite it.
+func TestGenerateGolden(t *testing.T) {
+	queries, err := loadQueries(filepath.Join("testdata", "queries"))
+	assert.Nil(t, err)
+	source, err := generate("queries", queries, typeSupport())
+	assert.Nil(t, err)
+	golden := filepath.Join("testdata", "queries.go.golden")
+	if *update {
+		assert.Nil(t, os.WriteFile(golden, source, 0o644))
+	}
+	expected, err := os.ReadFile(golden)
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), string(source))
+}
+
+func TestParseQueries(t *testing.T) {
+	queries, err := parseQueries("users.cypher", `// Ignored comment.
+// name: GetUser :one
+// The user.
+// params: id INT64
+// returns: User(id INT64, age INT64?)
+MATCH (u:User) WHERE u.id = $id // trailing comment
+RETURN u.id AS id, u.age AS age;
+
+// name: DeleteAll :exec
+MATCH (u:User) DELETE u;
+`)
+	assert.Nil(t, err)
+	if assert.Len(t, queries, 2) {
+		assert.Equal(t, "GetUser", queries[0].Name)
+		assert.Equal(t, kindOne, queries[0].Kind)
+		assert.Equal(t, 2, queries[0].Line)
+		assert.Equal(t, []string{"The user."}, queries[0].Doc)
+		assert.Equal(t, []column{{Name: "id", Type: "INT64"}}, queries[0].Params)
+		assert.Equal(t, "User", queries[0].Row)
+		assert.Equal(t, []column{{Name: "id", Type: "INT64"}, {Name: "age", Type: "INT64", Nullable: true}}, queries[0].Columns)
+		assert.Equal(t, "MATCH (u:User) WHERE u.id = $id // trailing comment\nRETURN u.id AS id, u.age AS age;", queries[0].Text)
+		assert.Equal(t, kindExec, queries[1].Kind)
+		assert.Equal(t, "MATCH (u:User) DELETE u;", queries[1].Text)
+	}
+
+	for _, content := range []string{
+		"// name: Get one\nRETURN 1;",
+		"// name: Get :one\nRETURN 1 AS x;",
+		"// name: Get :exec\n// returns: Row(x INT64)\nRETURN 1 AS x;",
+		"// name: Get :one\n// returns: Row(x INT64, x INT64)\nRETURN 1 AS x;",
+		"// name: Get :exec\n// params: id INT64?\nRETURN $id;",
+		"// name: Get :many\n// returns: x INT64\nRETURN 1 AS x;",
+		"// name: Get :exec\n",
+	} {
+		_, err := parseQueries("q.cypher", content)
+		assert.NotNil(t, err, content)
+	}
+}
+
+func TestParameterNames(t *testing.T) {
+	assert.Equal(t, []string{"id", "min_age"}, parameterNames("MATCH (u) WHERE u.id = $id AND u.age > $min_age AND u.id <> $id AND u.name = '$name' // $comment\nRETURN u;"))
+	assert.Equal(t, []string{"b"}, parameterNames("RETURN \"it's $a\" /* $c */, `$d`, $b;"))
+	assert.Nil(t, parameterNames("RETURN 1;"))
+}
+
+func TestNames(t *testing.T) {
+	assert.Equal(t, "UserID", exportedName("user_id"))
+	assert.Equal(t, "ID", exportedName("id"))
+	assert.Equal(t, "Name", exportedName("name"))
+	assert.Equal(t, "userID", unexportedName("user_id"))
+	assert.Equal(t, "getUserByID", unexportedName("GetUserByID"))
+	assert.Equal(t, "idQuery", unexportedName("ID")+"Query")
+	assert.Equal(t, "type_", parameterName("type"))
+	assert.Equal(t, "conn_", parameterName("conn"))
+}
+
+func TestGenerateErrors(t *testing.T) {
+	support := typeSupport()
+	parse := func(content string) []*query {
+		queries, err := parseQueries("q.cypher", content)
+		assert.Nil(t, err)
+		return queries
+	}
+	_, err := generate("q", parse("// name: A :one\n// returns: Row(x NOPE)\nRETURN 1 AS x;"), support)
+	assert.ErrorContains(t, err, "unknown type NOPE")
+	_, err = generate("q", parse("// name: A :exec\nRETURN 1;\n// name: A :exec\nRETURN 2;"), support)
+	assert.ErrorContains(t, err, "already declared")
+	_, err = generate("q", parse("// name: A :one\n// returns: Row(x INT64)\nRETURN 1 AS x;\n// name: B :one\n// returns: Row(y INT64)\nRETURN 1 AS y;"), support)
+	assert.ErrorContains(t, err, "other columns")
+}
+
+// TestValidate validates queries against the sample schema, which catches
+// typos in the queries and in their annotations.
+func TestValidate(t *testing.T) {
+	ddl, err := os.ReadFile(filepath.Join("testdata", "schema.cypher"))
+	assert.Nil(t, err)
+	schema, err := openSchemaDatabase(string(ddl))
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer schema.Close()
+	queries, err := loadQueries(filepath.Join("testdata", "queries"))
+	assert.Nil(t, err)
+	assert.Nil(t, schema.validateAll(queries))
+
+	invalid := map[string]string{
+		"is invalid":                "// name: A :one\n// params: id INT64\n// returns: R(id INT64)\nMATCH (u:Usr) WHERE u.id = $id RETURN u.id AS id;",
+		"idd":                       "// name: A :one\n// params: id INT64\n// returns: R(id INT64)\nMATCH (u:User) WHERE u.idd = $id RETURN u.id AS id;",
+		"declared but not used":     "// name: A :exec\n// params: id INT64, other STRING\nMATCH (u:User) WHERE u.id = $id DELETE u;",
+		"used but not declared":     "// name: A :exec\nMATCH (u:User) WHERE u.id = $id DELETE u;",
+		"returns 2 columns":         "// name: A :many\n// returns: R(id INT64)\nMATCH (u:User) RETURN u.id AS id, u.name AS name;",
+		"declared as ident":         "// name: A :many\n// returns: R(ident INT64)\nMATCH (u:User) RETURN u.id AS id;",
+		"of type STRING":            "// name: A :many\n// returns: R(name INT64)\nMATCH (u:User) RETURN u.name AS name;",
+		"fails on the empty schema": "// name: A :exec\n// params: id STRING\nRETURN CAST($id AS INT64);",
+	}
+	for message, content := range invalid {
+		queries, err := parseQueries("q.cypher", content)
+		if !assert.Nil(t, err, content) {
+			continue
+		}
+		assert.ErrorContains(t, schema.validate(queries[0]), message)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Kinds of queries, after the name of their annotation.
+const (
+	kindOne  = "one"
+	kindMany = "many"
+	kindExec = "exec"
+)
+
+// query is an annotated query of a Cypher file.
+type query struct {
+	// File and Line locate the name annotation of the query.
+	File string
+	Line int
+	Name string
+	Kind string
+	// Doc are the comment lines of the header of the query that are not
+	// annotations.
+	Doc    []string
+	Params []column
+	// Row is the name of the struct type of the rows, and Columns its
+	// columns.
+	Row     string
+	Columns []column
+	Text    string
+}
+
+// column is a typed parameter or returned column.
+type column struct {
+	Name string
+	// Type is the Cypher name of the type, e.g. INT64.
+	Type string
+	// Nullable is set for returned columns declared with a trailing "?".
+	Nullable bool
+}
+
+func (q *query) location() string {
+	return fmt.Sprintf("%s:%d", q.File, q.Line)
+}
+
+var (
+	namePattern    = regexp.MustCompile(`^//\s*name:\s*([A-Za-z][A-Za-z0-9_]*)\s+:(one|many|exec)\s*$`)
+	paramsPattern  = regexp.MustCompile(`^//\s*params:(.*)$`)
+	returnsPattern = regexp.MustCompile(`^//\s*returns:\s*([A-Za-z][A-Za-z0-9_]*)\s*\((.*)\)\s*$`)
+	columnPattern  = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\s+([A-Za-z_][A-Za-z0-9_]*)(\?)?$`)
+)
+
+// parseQueries parses the annotated queries of the content of a Cypher file.
+// A query starts with a header of comment lines:
+//
+//	// name: GetUserByID :one
+//	// Optional documentation of the generated function.
+//	// params: id INT64
+//	// returns: User(id INT64, name STRING, age INT64?)
+//
+// and its text runs until the next name annotation or the end of the file.
+// Queries of kind :one and :many must declare their returned columns, in the
+// order of the RETURN clause; queries of kind :exec must not.
+func parseQueries(file string, content string) ([]*query, error) {
+	var queries []*query
+	var current *query
+	header := false
+	var text []string
+	finish := func() error {
+		if current == nil {
+			return nil
+		}
+		current.Text = strings.TrimSpace(strings.Join(text, "\n"))
+		if current.Text == "" {
+			return fmt.Errorf("%s: query %s has no text", current.location(), current.Name)
+		}
+		if current.Kind == kindExec && current.Row != "" {
+			return fmt.Errorf("%s: query %s of kind :exec cannot declare returns", current.location(), current.Name)
+		}
+		if current.Kind != kindExec && current.Row == "" {
+			return fmt.Errorf("%s: query %s of kind :%s must declare returns", current.location(), current.Name, current.Kind)
+		}
+		queries = append(queries, current)
+		return nil
+	}
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if match := namePattern.FindStringSubmatch(trimmed); match != nil {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			current = &query{File: file, Line: i + 1, Name: match[1], Kind: match[2]}
+			header, text = true, nil
+			continue
+		}
+		if strings.HasPrefix(trimmed, "// name:") {
+			return nil, fmt.Errorf("%s:%d: invalid name annotation %q: expected // name: Name :one|:many|:exec", file, i+1, trimmed)
+		}
+		if current == nil {
+			continue
+		}
+		if header && strings.HasPrefix(trimmed, "//") {
+			var err error
+			switch {
+			case paramsPattern.MatchString(trimmed):
+				current.Params, err = parseColumns(paramsPattern.FindStringSubmatch(trimmed)[1], false)
+			case returnsPattern.MatchString(trimmed):
+				match := returnsPattern.FindStringSubmatch(trimmed)
+				current.Row = match[1]
+				current.Columns, err = parseColumns(match[2], true)
+			case strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(trimmed, "//")), "returns:"):
+				err = fmt.Errorf("invalid returns annotation: expected // returns: Row(name TYPE, ...)")
+			default:
+				current.Doc = append(current.Doc, strings.TrimSpace(strings.TrimPrefix(trimmed, "//")))
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", file, i+1, err)
+			}
+			continue
+		}
+		header = false
+		text = append(text, line)
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// parseColumns parses a comma-separated list of names followed by types.
+// Types may be followed by "?" if nullable is set.
+func parseColumns(list string, nullable bool) ([]column, error) {
+	var columns []column
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		match := columnPattern.FindStringSubmatch(item)
+		if match == nil || match[3] != "" && !nullable {
+			return nil, fmt.Errorf("invalid declaration %q: expected name TYPE", item)
+		}
+		if seen[match[1]] {
+			return nil, fmt.Errorf("%s is declared twice", match[1])
+		}
+		seen[match[1]] = true
+		columns = append(columns, column{Name: match[1], Type: strings.ToUpper(match[2]), Nullable: match[3] != ""})
+	}
+	return columns, nil
+}
+
+// parameterPattern matches the parameters of a query.
+var parameterPattern = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// parameterNames returns the names of the parameters used by the query, in
+// the order of their first use, outside of string literals and comments.
+func parameterNames(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range parameterPattern.FindAllStringSubmatch(stripLiterals(text), -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// stripLiterals blanks the string literals, escaped identifiers and comments
+// of a query.
+func stripLiterals(text string) string {
+	out := []byte(text)
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '\'' || out[i] == '"' || out[i] == '`':
+			quote := out[i]
+			for i++; i < len(out) && out[i] != quote; i++ {
+				if out[i] == '\\' && quote != '`' && i+1 < len(out) {
+					out[i] = ' '
+					i++
+				}
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			for ; i < len(out) && !(out[i] == '*' && i+1 < len(out) && out[i+1] == '/'); i++ {
+				out[i] = ' '
+			}
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,13 @@
+// name: Follow :exec
+// params: from_id INT64, to_id INT64, since TIMESTAMP
+MATCH (a:User), (b:User) WHERE a.id = $from_id AND b.id = $to_id
+CREATE (a)-[:Follows {since: $since}]->(b);
+
+// name: ListFollowers :many
+// Returns the followers of the user, most recent first. The `$` in
+// '$later' is not a parameter.
+// params: user_id INT64
+// returns: Follower(follower_id INT64, follower_name STRING, since TIMESTAMP, note STRING)
+MATCH (f:User)-[r:Follows]->(u:User) WHERE u.id = $user_id
+RETURN f.id AS follower_id, f.name AS follower_name, r.since AS since, '$later' AS note
+ORDER BY r.since DESC;
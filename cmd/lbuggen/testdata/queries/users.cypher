@@ -0,0 +1,17 @@
+// name: GetUserByID :one
+// Returns the user with the id, or lbuggen.ErrNoRows.
+// params: id INT64
+// returns: User(id INT64, name STRING, age INT64?)
+MATCH (u:User) WHERE u.id = $id
+RETURN u.id AS id, u.name AS name, u.age AS age;
+
+// name: ListUsersOlderThan :many
+// params: min_age INT64
+// returns: User(id INT64, name STRING, age INT64?)
+MATCH (u:User) WHERE u.age > $min_age
+RETURN u.id AS id, u.name AS name, u.age AS age
+ORDER BY u.id;
+
+// name: CreateUser :exec
+// params: id INT64, name STRING, email STRING, joined DATE
+CREATE (:User {id: $id, name: $name, email: $email, joined: $joined});
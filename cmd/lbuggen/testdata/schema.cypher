@@ -0,0 +1,2 @@
+CREATE NODE TABLE User(id INT64, name STRING, email STRING, age INT64, joined DATE, PRIMARY KEY(id));
+CREATE REL TABLE Follows(FROM User TO User, since TIMESTAMP);
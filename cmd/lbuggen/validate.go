@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	lbug "github.com/LadybugDB/go-ladybug"
+)
+
+// schemaDatabase is an in-memory database holding the schema the queries
+// are validated against, and no data.
+type schemaDatabase struct {
+	db   *lbug.Database
+	conn *lbug.Connection
+}
+
+// openSchemaDatabase creates the tables of the DDL statements in an empty
+// in-memory database.
+func openSchemaDatabase(ddl string) (*schemaDatabase, error) {
+	db, err := lbug.OpenInMemoryDatabase(lbug.DefaultSystemConfig())
+	if err != nil {
+		return nil, err
+	}
+	conn, err := lbug.OpenConnection(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	schema := &schemaDatabase{db: db, conn: conn}
+	if strings.TrimSpace(ddl) != "" {
+		result, err := conn.Query(ddl)
+		if err != nil {
+			schema.Close()
+			return nil, fmt.Errorf("failed to create the schema: %w", err)
+		}
+		result.Close()
+	}
+	return schema, nil
+}
+
+// Close closes the database.
+func (schema *schemaDatabase) Close() {
+	schema.conn.Close()
+	schema.db.Close()
+}
+
+// validate checks the query against the schema: it must prepare, use the
+// declared parameters and no other, and, run with zero values of the
+// parameters in a transaction that is rolled back, return the declared
+// columns with their declared types.
+func (schema *schemaDatabase) validate(q *query) error {
+	statement, err := schema.conn.Prepare(q.Text)
+	if err != nil {
+		statement.Close()
+		return fmt.Errorf("%s: query %s is invalid: %w", q.location(), q.Name, err)
+	}
+	defer statement.Close()
+	used := parameterNames(q.Text)
+	args := make(map[string]any, len(q.Params))
+	var problems []string
+	for _, p := range q.Params {
+		if !slices.Contains(used, p.Name) {
+			problems = append(problems, fmt.Sprintf("parameter %s is declared but not used", p.Name))
+		}
+		args[p.Name] = zeroValue(p.Type)
+	}
+	for _, name := range used {
+		if _, ok := args[name]; !ok {
+			problems = append(problems, fmt.Sprintf("parameter $%s is used but not declared", name))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: query %s: %s", q.location(), q.Name, strings.Join(problems, "; "))
+	}
+
+	if err := runStatement(schema.conn, "BEGIN TRANSACTION;"); err != nil {
+		return err
+	}
+	defer runStatement(schema.conn, "ROLLBACK;")
+	result, err := schema.conn.Execute(statement, args)
+	if err != nil {
+		return fmt.Errorf("%s: query %s fails on the empty schema: %w", q.location(), q.Name, err)
+	}
+	defer result.Close()
+	names := result.GetColumnNames()
+	types := result.GetColumnDataTypes()
+	if q.Kind == kindExec {
+		return nil
+	}
+	if len(names) != len(q.Columns) {
+		return fmt.Errorf("%s: query %s returns %d columns (%s), %d are declared", q.location(), q.Name, len(names), strings.Join(names, ", "), len(q.Columns))
+	}
+	for i, c := range q.Columns {
+		if names[i] != c.Name {
+			problems = append(problems, fmt.Sprintf("column %d is %s, declared as %s", i, names[i], c.Name))
+		} else if types[i].Name != c.Type {
+			problems = append(problems, fmt.Sprintf("column %s is of type %s, declared as %s", c.Name, types[i].Name, c.Type))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: query %s: %s", q.location(), q.Name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateAll validates the queries and returns all their errors.
+func (schema *schemaDatabase) validateAll(queries []*query) error {
+	var errs []error
+	for _, q := range queries {
+		if err := schema.validate(q); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runStatement runs a query and discards its result.
+func runStatement(conn *lbug.Connection, query string) error {
+	result, err := conn.Query(query)
+	if err != nil {
+		return err
+	}
+	result.Close()
+	return nil
+}
+
+// zeroValue returns the value bound for a parameter of the type when the
+// query is validated.
+func zeroValue(typeName string) any {
+	switch typeName {
+	case "BOOL":
+		return false
+	case "INT64", "SERIAL":
+		return int64(0)
+	case "INT32":
+		return int32(0)
+	case "INT16":
+		return int16(0)
+	case "INT8":
+		return int8(0)
+	case "UINT64":
+		return uint64(0)
+	case "UINT32":
+		return uint32(0)
+	case "UINT16":
+		return uint16(0)
+	case "UINT8":
+		return uint8(0)
+	case "INT128":
+		return big.NewInt(0)
+	case "DOUBLE":
+		return float64(0)
+	case "FLOAT":
+		return float32(0)
+	case "DECIMAL":
+		return decimal.Zero
+	case "STRING":
+		return ""
+	case "BLOB":
+		return []byte{}
+	case "UUID":
+		return uuid.UUID{}
+	case "DATE", "TIMESTAMP", "TIMESTAMP_SEC", "TIMESTAMP_MS", "TIMESTAMP_NS", "TIMESTAMP_TZ":
+		return time.Unix(0, 0).UTC()
+	case "INTERVAL":
+		return time.Duration(0)
+	case "LIST", "ARRAY":
+		return []any{}
+	case "STRUCT":
+		return map[string]any{}
+	case "MAP":
+		return []lbug.MapItem{}
+	}
+	return nil
+}
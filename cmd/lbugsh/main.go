@@ -0,0 +1,336 @@
+// lbugsh is a minimal interactive shell for Lbug built on the public API of
+// the go-ladybug package.
+//
+// Usage:
+//
+//	go run ./cmd/lbugsh -db ./mydb
+//
+// Statements are terminated by a semicolon and may span multiple lines. The
+// following meta commands are supported:
+//
+//	\help                 show the list of meta commands
+//	\quit, \q             exit the shell
+//	\timing               toggle printing of compiling and execution times
+//	\set NAME VALUE       set a query parameter used as $NAME
+//	\unset NAME           remove a query parameter
+//	\params               list the query parameters
+//	\copy FILE QUERY      run QUERY and write the result to FILE as CSV
+//
+// Pressing Ctrl-C while a query is running interrupts the query.
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	lbug "github.com/LadybugDB/go-ladybug"
+)
+
+const helpText = `\help                 show this help
+\quit, \q             exit the shell
+\timing               toggle printing of compiling and execution times
+\set NAME VALUE       set a query parameter used as $NAME
+\unset NAME           remove a query parameter
+\params               list the query parameters
+\copy FILE QUERY      run QUERY and write the result to FILE as CSV`
+
+// shell holds the state of an interactive session.
+type shell struct {
+	conn    *lbug.Connection
+	out     io.Writer
+	params  map[string]any
+	timing  bool
+	running atomic.Bool
+}
+
+func main() {
+	dbPath := flag.String("db", ":memory:", "path of the database to open, or :memory:")
+	bufferPoolSize := flag.String("buffer-pool", "", "buffer pool size, e.g. 1GiB (default: engine default)")
+	threads := flag.Uint64("threads", 0, "maximum number of threads (default: engine default)")
+	readOnly := flag.Bool("read-only", false, "open the database in read-only mode")
+	timeout := flag.String("timeout", "", "query timeout, e.g. 30s (default: no timeout)")
+	flag.Parse()
+
+	systemConfig := lbug.DefaultSystemConfig()
+	if *bufferPoolSize != "" {
+		if err := systemConfig.SetBufferPoolSizeString(*bufferPoolSize); err != nil {
+			fatal(err)
+		}
+	}
+	if *threads != 0 {
+		systemConfig.MaxNumThreads = *threads
+	}
+	systemConfig.ReadOnly = *readOnly
+
+	db, err := lbug.OpenDatabase(*dbPath, systemConfig)
+	if err != nil {
+		fatal(err)
+	}
+	defer db.Close()
+	conn, err := lbug.OpenConnection(db)
+	if err != nil {
+		fatal(err)
+	}
+	defer conn.Close()
+	if *timeout != "" {
+		if err := conn.SetTimeoutString(*timeout); err != nil {
+			fatal(err)
+		}
+	}
+
+	sh := &shell{conn: conn, out: os.Stdout, params: make(map[string]any)}
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		for range interrupts {
+			if sh.running.Load() {
+				conn.Interrupt()
+			}
+		}
+	}()
+	sh.run(os.Stdin)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "lbugsh:", err)
+	os.Exit(1)
+}
+
+// run reads statements and meta commands from in until EOF or \quit.
+func (sh *shell) run(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var statement strings.Builder
+	sh.prompt(statement.Len() > 0)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if statement.Len() == 0 && strings.HasPrefix(trimmed, `\`) {
+			if quit := sh.runMetaCommand(trimmed); quit {
+				return
+			}
+			sh.prompt(false)
+			continue
+		}
+		if trimmed != "" {
+			statement.WriteString(line)
+			statement.WriteString("\n")
+		}
+		if strings.HasSuffix(trimmed, ";") {
+			sh.runQuery(statement.String())
+			statement.Reset()
+		}
+		sh.prompt(statement.Len() > 0)
+	}
+}
+
+func (sh *shell) prompt(continuation bool) {
+	if continuation {
+		fmt.Fprint(sh.out, "   -> ")
+	} else {
+		fmt.Fprint(sh.out, "lbug> ")
+	}
+}
+
+// runMetaCommand executes a meta command and returns true if the shell should
+// exit.
+func (sh *shell) runMetaCommand(line string) bool {
+	command, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+	switch command {
+	case `\q`, `\quit`:
+		return true
+	case `\help`:
+		fmt.Fprintln(sh.out, helpText)
+	case `\timing`:
+		sh.timing = !sh.timing
+		if sh.timing {
+			fmt.Fprintln(sh.out, "Timing is on.")
+		} else {
+			fmt.Fprintln(sh.out, "Timing is off.")
+		}
+	case `\set`:
+		name, value, ok := strings.Cut(rest, " ")
+		if !ok || name == "" {
+			fmt.Fprintln(sh.out, `usage: \set NAME VALUE`)
+			return false
+		}
+		sh.params[name] = parseParameterValue(strings.TrimSpace(value))
+	case `\unset`:
+		delete(sh.params, rest)
+	case `\params`:
+		names := make([]string, 0, len(sh.params))
+		for name := range sh.params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(sh.out, "%s = %#v\n", name, sh.params[name])
+		}
+	case `\copy`:
+		file, query, ok := strings.Cut(rest, " ")
+		if !ok || file == "" || strings.TrimSpace(query) == "" {
+			fmt.Fprintln(sh.out, `usage: \copy FILE QUERY`)
+			return false
+		}
+		if err := sh.copyToCSV(file, query); err != nil {
+			fmt.Fprintln(sh.out, "Error:", err)
+		}
+	default:
+		fmt.Fprintf(sh.out, "unknown command %s, type \\help for help\n", command)
+	}
+	return false
+}
+
+// execute runs the query, binding the parameters it references.
+func (sh *shell) execute(query string) (*lbug.QueryResult, error) {
+	sh.running.Store(true)
+	defer sh.running.Store(false)
+	names := parameterNames(query)
+	if len(names) == 0 {
+		return sh.conn.Query(query)
+	}
+	stmt, err := sh.conn.Prepare(query)
+	if err != nil {
+		stmt.Close()
+		return nil, err
+	}
+	defer stmt.Close()
+	args := make(map[string]any, len(names))
+	for _, name := range names {
+		value, ok := sh.params[name]
+		if !ok {
+			return nil, fmt.Errorf("parameter $%s is not set, use \\set %s VALUE", name, name)
+		}
+		args[name] = value
+	}
+	return sh.conn.Execute(stmt, args)
+}
+
+func (sh *shell) runQuery(query string) {
+	result, err := sh.execute(query)
+	if err != nil {
+		fmt.Fprintln(sh.out, "Error:", err)
+		return
+	}
+	// The results of the subsequent statements of a multi-statement query
+	// are owned by the first result, so it is closed last.
+	defer result.Close()
+	current := result
+	for {
+		fmt.Fprint(sh.out, current.ToString())
+		if sh.timing {
+			fmt.Fprintf(sh.out, "Compiling time: %.2f ms, Execution time: %.2f ms\n",
+				current.GetCompilingTime(), current.GetExecutionTime())
+		}
+		if !current.HasNextQueryResult() {
+			return
+		}
+		next, err := current.NextQueryResult()
+		if err != nil {
+			fmt.Fprintln(sh.out, "Error:", err)
+			return
+		}
+		defer next.Close()
+		current = next
+	}
+}
+
+// copyToCSV runs the query and writes its result, including a header with the
+// column names, to the file at path.
+func (sh *shell) copyToCSV(path string, query string) (err error) {
+	result, err := sh.execute(query)
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, file.Close())
+	}()
+	writer := csv.NewWriter(file)
+	if err := writer.Write(result.GetColumnNames()); err != nil {
+		return err
+	}
+	rows := 0
+	for result.HasNext() {
+		tuple, err := result.Next()
+		if err != nil {
+			tuple.Close()
+			return err
+		}
+		values, err := tuple.GetAsSlice()
+		tuple.Close()
+		if err != nil {
+			return err
+		}
+		record := make([]string, len(values))
+		for i, value := range values {
+			if value != nil {
+				record[i] = fmt.Sprint(value)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+		rows++
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	fmt.Fprintf(sh.out, "Wrote %d rows to %s\n", rows, path)
+	return nil
+}
+
+var parameterPattern = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// parameterNames returns the distinct names of the $parameters referenced in
+// the query, in order of first appearance.
+func parameterNames(query string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range parameterPattern.FindAllStringSubmatch(query, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// parseParameterValue converts the textual value of a \set command to a Go
+// value: integers, floats, booleans and null are recognized, quoted text is
+// unquoted, and anything else is used as a string verbatim.
+func parseParameterValue(text string) any {
+	if text == "null" || text == "NULL" {
+		return nil
+	}
+	if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return value
+	}
+	if value, err := strconv.ParseFloat(text, 64); err == nil {
+		return value
+	}
+	if strings.EqualFold(text, "true") || strings.EqualFold(text, "false") {
+		return strings.EqualFold(text, "true")
+	}
+	if len(text) >= 2 && (text[0] == '\'' || text[0] == '"') && text[len(text)-1] == text[0] {
+		return text[1 : len(text)-1]
+	}
+	return text
+}
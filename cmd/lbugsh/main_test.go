@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParameterNames(t *testing.T) {
+	assert.Equal(t, []string{"name", "age_2"}, parameterNames("MATCH (a) WHERE a.name = $name AND a.age > $age_2 OR a.name = $name RETURN a;"))
+	assert.Nil(t, parameterNames("RETURN 1;"))
+}
+
+func TestParseParameterValue(t *testing.T) {
+	assert.Equal(t, int64(42), parseParameterValue("42"))
+	assert.Equal(t, int64(-7), parseParameterValue("-7"))
+	assert.Equal(t, 1.5, parseParameterValue("1.5"))
+	assert.Equal(t, true, parseParameterValue("TRUE"))
+	assert.Equal(t, false, parseParameterValue("false"))
+	assert.Nil(t, parseParameterValue("null"))
+	assert.Equal(t, "Alice", parseParameterValue("'Alice'"))
+	assert.Equal(t, "42", parseParameterValue("\"42\""))
+	assert.Equal(t, "t", parseParameterValue("t"))
+	assert.Equal(t, "hello world", parseParameterValue("hello world"))
+}
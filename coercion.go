@@ -0,0 +1,115 @@
+package lbug
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CoercionError is returned by the bulk helpers when a value of a row cannot
+// be converted to the type of its column without losing data.
+type CoercionError struct {
+	// Row is the index of the row in the input.
+	Row int
+	// Column is the name of the column.
+	Column string
+	// Value is the value that could not be converted.
+	Value any
+	// Type is the data type of the column.
+	Type string
+}
+
+func (err *CoercionError) Error() string {
+	return fmt.Sprintf("row %d, column %s: cannot convert %T %v to %s without losing data", err.Row, err.Column, err.Value, err.Value, err.Type)
+}
+
+// integerRanges are the ranges of the integer types columns may have.
+var integerRanges = map[string]struct {
+	min int64
+	max uint64
+}{
+	"INT8":   {math.MinInt8, math.MaxInt8},
+	"INT16":  {math.MinInt16, math.MaxInt16},
+	"INT32":  {math.MinInt32, math.MaxInt32},
+	"INT64":  {math.MinInt64, math.MaxInt64},
+	"SERIAL": {math.MinInt64, math.MaxInt64},
+	"UINT8":  {0, math.MaxUint8},
+	"UINT16": {0, math.MaxUint16},
+	"UINT32": {0, math.MaxUint32},
+	"UINT64": {0, math.MaxUint64},
+}
+
+// coerceValue converts a value decoded from a loosely typed source such as
+// JSON to a value of the given column type when this loses no data: integral
+// floats and json.Numbers to integers, RFC 3339 strings to timestamps and
+// dates, and strings to UUIDs. Other values are returned unchanged and left
+// to the engine to cast. ok is false if the value cannot be converted
+// losslessly.
+func coerceValue(value any, dataType string) (coerced any, ok bool) {
+	dataType = strings.ToUpper(strings.TrimSpace(dataType))
+	if integerRange, isInteger := integerRanges[dataType]; isInteger {
+		var number float64
+		switch v := value.(type) {
+		case float64:
+			number = v
+		case float32:
+			number = float64(v)
+		case json.Number:
+			if integer, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+				return integer, integer >= integerRange.min && (integer < 0 || uint64(integer) <= integerRange.max)
+			}
+			if integer, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+				return integer, integer <= integerRange.max
+			}
+			float, err := v.Float64()
+			if err != nil {
+				return value, false
+			}
+			number = float
+		default:
+			return value, true
+		}
+		// float64(max) + 1 is exact for the small types and rounds to the
+		// power of two above max for INT64 and UINT64.
+		if number != math.Trunc(number) || number < float64(integerRange.min) || number >= float64(integerRange.max)+1 {
+			return value, false
+		}
+		if integerRange.min == 0 {
+			return uint64(number), true
+		}
+		return int64(number), true
+	}
+	s, isString := value.(string)
+	if !isString {
+		return value, true
+	}
+	switch {
+	case strings.HasPrefix(dataType, "TIMESTAMP"):
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return value, false
+		}
+		return t, true
+	case dataType == "DATE":
+		if t, err := time.Parse(time.DateOnly, s); err == nil {
+			return t, true
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil || t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0 || t.Nanosecond() != 0 {
+			return value, false
+		}
+		return t, true
+	case dataType == "UUID":
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return value, false
+		}
+		return id, true
+	}
+	return value, true
+}
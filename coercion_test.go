@@ -0,0 +1,91 @@
+package lbug
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoerceValue(t *testing.T) {
+	id := uuid.New()
+	timestamp := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC)
+	for _, test := range []struct {
+		value    any
+		dataType string
+		want     any
+		ok       bool
+	}{
+		{float64(42), "INT64", int64(42), true},
+		{float64(-3), "int32", int64(-3), true},
+		{float64(1.5), "INT64", float64(1.5), false},
+		{float64(128), "INT8", float64(128), false},
+		{float64(-129), "INT8", float64(-129), false},
+		{float64(-1), "UINT8", float64(-1), false},
+		{float64(255), "UINT8", uint64(255), true},
+		{math.NaN(), "INT64", nil, false},
+		{math.Inf(1), "INT64", math.Inf(1), false},
+		{float64(1 << 53), "INT64", int64(1 << 53), true},
+		{float64(math.MaxInt64), "INT64", float64(math.MaxInt64), false},
+		{float64(math.MaxUint64), "UINT64", float64(math.MaxUint64), false},
+		{float32(7), "INT16", int64(7), true},
+		{json.Number("9223372036854775807"), "INT64", int64(math.MaxInt64), true},
+		{json.Number("18446744073709551615"), "UINT64", uint64(math.MaxUint64), true},
+		{json.Number("18446744073709551615"), "INT64", uint64(math.MaxUint64), false},
+		{json.Number("2.0"), "INT64", int64(2), true},
+		{json.Number("2.5"), "INT64", json.Number("2.5"), false},
+		{int64(5), "INT64", int64(5), true},
+		{"2024-05-06T07:08:09.123456Z", "TIMESTAMP", timestamp, true},
+		{"yesterday", "TIMESTAMP", "yesterday", false},
+		{"2024-05-06", "DATE", time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC), true},
+		{"2024-05-06T07:08:09Z", "DATE", "2024-05-06T07:08:09Z", false},
+		{id.String(), "UUID", id, true},
+		{"not a uuid", "UUID", "not a uuid", false},
+		{"text", "STRING", "text", true},
+		{float64(1.5), "DOUBLE", float64(1.5), true},
+	} {
+		got, ok := coerceValue(test.value, test.dataType)
+		assert.Equal(t, test.ok, ok, "%T %v to %s", test.value, test.value, test.dataType)
+		if test.want != nil {
+			assert.Equal(t, test.want, got, "%T %v to %s", test.value, test.value, test.dataType)
+		}
+	}
+}
+
+func TestCoercionError(t *testing.T) {
+	err := &CoercionError{Row: 3, Column: "since", Value: 1.5, Type: "INT64"}
+	assert.Equal(t, "row 3, column since: cannot convert float64 1.5 to INT64 without losing data", err.Error())
+}
+
+func TestCreateRelsCoercion(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE person(id INT64, PRIMARY KEY(id));")
+	mustRun(t, conn, "CREATE REL TABLE knows(FROM person TO person, since INT64, at TIMESTAMP);")
+	mustRun(t, conn, "CREATE (:person {id: 1}), (:person {id: 2});")
+
+	// Values as decoded by encoding/json.
+	var pairs []RelSpec
+	assert.Nil(t, json.Unmarshal([]byte(`[{"FromKey": 1, "ToKey": 2, "Props": {"since": 2020, "at": "2020-01-02T03:04:05Z"}}]`), &pairs))
+	result, err := conn.CreateRels("knows", pairs, CreateRelsOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), result.Created)
+	rows, err := queryRows(conn, "MATCH ()-[k:knows]->() RETURN k.since AS since, k.at AS at;")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2020), rows[0]["since"])
+	assert.True(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC).Equal(rows[0]["at"].(time.Time)))
+
+	pairs = []RelSpec{{FromKey: 1.0, ToKey: 2.0}, {FromKey: 2.0, ToKey: 1.0, Props: map[string]any{"since": 2020.5}}}
+	_, err = conn.CreateRels("knows", pairs, CreateRelsOptions{})
+	var coercionErr *CoercionError
+	assert.ErrorAs(t, err, &coercionErr)
+	assert.Equal(t, 1, coercionErr.Row)
+	assert.Equal(t, "since", coercionErr.Column)
+
+	// Strict pipelines pass the values to the engine unchanged.
+	_, err = conn.CreateRels("knows", pairs[1:], CreateRelsOptions{StrictTypes: true})
+	assert.False(t, errors.As(err, &coercionErr))
+}
@@ -0,0 +1,638 @@
+package lbug
+
+import (
+	"encoding"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// CollectOptions configures CollectWithOptions.
+type CollectOptions struct {
+	// WeakConversion also converts between numbers of any type, numbers and
+	// strings, and booleans and strings, e.g. an INT64 column to a float64 or
+	// string field and a STRING column holding "42" to an int field. Numbers
+	// are still only converted if they fit in the field. Otherwise, a column
+	// is only decoded into a field of its Go type, of a named type with the
+	// same underlying type, or of a wider number type of the same kind.
+	WeakConversion bool
+	// Tags are the struct tags naming the column of a field, by priority: the
+	// first tag of a field that is present names its column, or skips the
+	// field if it is "-", and the name of the field is used if none is
+	// present. The options after a comma, e.g. in `json:"name,omitempty"`,
+	// are ignored, and a tag with an empty name falls back to the next one.
+	// When several fields decode the same column, the field named by the
+	// tag of highest priority decodes it, and a field named by a tag takes
+	// precedence over a field of that name; fields named alike by the same
+	// tag, or by their names, are an error.
+	// Nil means {"lbug", "json"}, so that structs tagged for encoding/json
+	// decode without lbug tags; an empty slice matches fields by name only.
+	Tags []string
+}
+
+// defaultTags are the struct tags of CollectOptions.Tags if it is nil.
+var defaultTags = []string{"lbug", "json"}
+
+// tags returns the struct tags naming the columns of fields, by priority.
+func (opts CollectOptions) tags() []string {
+	if opts.Tags == nil {
+		return defaultTags
+	}
+	return opts.Tags
+}
+
+// FieldMismatch is a field of a struct that cannot hold the values of the
+// column of the same name.
+type FieldMismatch struct {
+	// Field is the name of the field of the struct.
+	Field string
+	// FieldType is the Go type of the field.
+	FieldType string
+	// Column is the name of the column.
+	Column string
+	// ColumnType is the logical type of the column.
+	ColumnType string
+}
+
+// CollectTypeError is returned by Collect when fields of the destination
+// struct are incompatible with the types of their columns. It lists all of
+// them, so that they can be fixed at once.
+type CollectTypeError struct {
+	// Type is the destination struct type.
+	Type string
+	// Mismatches are the incompatible fields, in the order of the struct.
+	Mismatches []FieldMismatch
+}
+
+func (err *CollectTypeError) Error() string {
+	mismatches := make([]string, len(err.Mismatches))
+	for i, mismatch := range err.Mismatches {
+		mismatches[i] = fmt.Sprintf("field %s (%s) cannot hold column %s (%s)", mismatch.Field, mismatch.FieldType, mismatch.Column, mismatch.ColumnType)
+	}
+	return fmt.Sprintf("cannot collect rows into %s: %s", err.Type, strings.Join(mismatches, "; "))
+}
+
+// Collect decodes the remaining rows of the QueryResult into values of the
+// struct type T, see CollectWithOptions.
+func Collect[T any](queryResult *QueryResult) ([]T, error) {
+	return CollectWithOptions[T](queryResult, CollectOptions{})
+}
+
+// CollectWithOptions decodes the remaining rows of the QueryResult into
+// values of the struct type T. Every column is decoded into the exported
+// field of the same name, or whose lbug tag, or json tag if it has no lbug
+// tag, is the name of the column, see CollectOptions.Tags; columns without a
+// field are ignored, and fields without a column or tagged `lbug:"-"` are
+// left unset. A field named by a tag takes precedence over a field of the
+// same name, and two fields named alike otherwise are an error. Pointer
+// fields are nil for NULL values; other fields are set to their zero value.
+//
+// A field whose type implements LbugScanner, with a pointer receiver or not,
+// is set by calling ScanLbug with the value of its column, whatever the type of
+// the column and the lbug tags of the fields of its type. A field whose type
+// implements encoding.TextUnmarshaler, e.g. a UUID or an enumeration type, is
+// set by calling UnmarshalText with the value of a STRING column. The other
+// fields are set by the conversions below. A NODE value decoded into an interface, e.g. a
+// field of type any or an element of a []any, is decoded into the type
+// registered for its label with RegisterNodeType, if any. A NODE or REL value
+// decoded into another struct than Node or Relationship sets its fields from
+// the properties, as for a STRUCT value, and the fields tagged `lbug:"_id"`
+// and `lbug:"_label"`, and `lbug:"_src"` and `lbug:"_dst"` for a REL, from
+// the ID, the label and the IDs of the source and destination nodes.
+//
+// The fields are checked against the types of their columns before the first
+// row is decoded, and a *CollectTypeError lists all the fields that cannot
+// hold the values of their columns. Only the values of the columns decoded
+// into fields are converted from the engine's values, so that collecting a
+// few fields of a wide result does not pay for the other columns. The element types of nested values, e.g.
+// the elements of a LIST decoded into a []int64, are not known before the
+// values are decoded and are checked for every row.
+func CollectWithOptions[T any](queryResult *QueryResult, opts CollectOptions) ([]T, error) {
+	return collectRows[T](queryResult.GetColumnNames(), queryResult.GetColumnDataTypes(), opts, queryResult.HasNext, func(columns []uint64) ([]any, error) {
+		tuple, err := queryResult.Next()
+		if err != nil {
+			return nil, err
+		}
+		defer tuple.Close()
+		return tuple.getColumns(columns)
+	})
+}
+
+// collectRows decodes the rows returned by next while hasNext returns true
+// into values of the struct type T, matching the fields with the columns of
+// the names and types. next is passed the indexes of the columns decoded into
+// fields, and only needs to convert the values of those.
+func collectRows[T any](names []string, types []DataType, opts CollectOptions, hasNext func() bool, next func(columns []uint64) ([]any, error)) ([]T, error) {
+	structType := reflect.TypeOf((*T)(nil)).Elem()
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot collect rows into %s: not a struct type", structType)
+	}
+	fields, err := collectFields(structType, names, types, opts)
+	if err != nil {
+		return nil, err
+	}
+	columns := projectedColumns(fields)
+	var rows []T
+	for hasNext() {
+		values, err := next(columns)
+		if err != nil {
+			return rows, err
+		}
+		var row T
+		dst := reflect.ValueOf(&row).Elem()
+		for col, field := range fields {
+			if field == nil {
+				continue
+			}
+			if err := assignValue(dst.FieldByIndex(field), values[col], opts); err != nil {
+				return rows, conversionError(uint64(col), fmt.Errorf("row %d: %w", len(rows), err))
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// collectFields returns the index of the field of every column, or nil for
+// columns without a field, or a *CollectTypeError if fields cannot hold the
+// values of their columns.
+func collectFields(structType reflect.Type, names []string, types []DataType, opts CollectOptions) ([][]int, error) {
+	columns := make(map[string]int, len(names))
+	for i, name := range names {
+		columns[name] = i
+	}
+	byName, err := decodedFields(structType, opts.tags())
+	if err != nil {
+		return nil, err
+	}
+	fields := make([][]int, len(names))
+	typeErr := &CollectTypeError{Type: structType.String()}
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name, _, ok := taggedFieldName(field, opts.tags())
+		if !ok || byName[name] != i {
+			continue
+		}
+		col, ok := columns[name]
+		if !ok {
+			continue
+		}
+		if col < len(types) && !canHold(field.Type, types[col], opts.WeakConversion) {
+			typeErr.Mismatches = append(typeErr.Mismatches, FieldMismatch{
+				Field:      field.Name,
+				FieldType:  field.Type.String(),
+				Column:     name,
+				ColumnType: types[col].Name,
+			})
+			continue
+		}
+		fields[col] = field.Index
+	}
+	if len(typeErr.Mismatches) > 0 {
+		return nil, typeErr
+	}
+	return fields, nil
+}
+
+// projectedColumns returns the indexes of the columns decoded into fields,
+// which is empty but not nil if there is none.
+func projectedColumns(fields [][]int) []uint64 {
+	columns := make([]uint64, 0, len(fields))
+	for col, field := range fields {
+		if field != nil {
+			columns = append(columns, uint64(col))
+		}
+	}
+	return columns
+}
+
+// taggedFieldName returns the name of the column or STRUCT field decoded
+// into a struct field, which is its first tag present, see
+// CollectOptions.Tags, or its name, and the priority of the name: the index
+// of its tag in tags, or len(tags) for the name of the field. It returns
+// false for unexported and embedded fields and fields skipped by their tag.
+func taggedFieldName(field reflect.StructField, tags []string) (name string, priority int, ok bool) {
+	if !field.IsExported() || field.Anonymous {
+		return "", 0, false
+	}
+	for i, key := range tags {
+		tag, present := field.Tag.Lookup(key)
+		if !present {
+			continue
+		}
+		if tag == "-" {
+			return "", 0, false
+		}
+		if name, _, _ = strings.Cut(tag, ","); name != "" {
+			return name, i, true
+		}
+	}
+	return field.Name, len(tags), true
+}
+
+// decodedFields returns the index of the field of the struct type decoded
+// from every column or STRUCT field name, which is the field whose name has
+// the highest priority, or an error if two fields have the same name at the
+// same priority, from the same tag or as field names.
+func decodedFields(structType reflect.Type, tags []string) (map[string]int, error) {
+	byName := make(map[string]int, structType.NumField())
+	priorities := make(map[string]int, structType.NumField())
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name, priority, ok := taggedFieldName(field, tags)
+		if !ok {
+			continue
+		}
+		other, exists := byName[name]
+		switch {
+		case !exists || priority < priorities[name]:
+			byName[name], priorities[name] = i, priority
+		case priority == priorities[name]:
+			return nil, fmt.Errorf("cannot decode into %s: fields %s and %s both decode %s", structType, structType.Field(other).Name, field.Name, name)
+		}
+	}
+	return byName, nil
+}
+
+// lbugScannerType is the type of the LbugScanner interface, and
+// textUnmarshalerType of encoding.TextUnmarshaler.
+var (
+	lbugScannerType     = reflect.TypeOf((*LbugScanner)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// columnGoTypes are the Go types of the values of the logical types, as
+// returned by FlatTuple.GetValue.
+var columnGoTypes = map[string]reflect.Type{
+	"BOOL":          reflect.TypeOf(false),
+	"INT64":         reflect.TypeOf(int64(0)),
+	"SERIAL":        reflect.TypeOf(int64(0)),
+	"INT32":         reflect.TypeOf(int32(0)),
+	"INT16":         reflect.TypeOf(int16(0)),
+	"INT8":          reflect.TypeOf(int8(0)),
+	"UINT64":        reflect.TypeOf(uint64(0)),
+	"UINT32":        reflect.TypeOf(uint32(0)),
+	"UINT16":        reflect.TypeOf(uint16(0)),
+	"UINT8":         reflect.TypeOf(uint8(0)),
+	"INT128":        reflect.TypeOf((*big.Int)(nil)),
+	"DOUBLE":        reflect.TypeOf(float64(0)),
+	"FLOAT":         reflect.TypeOf(float32(0)),
+	"DECIMAL":       reflect.TypeOf(decimal.Decimal{}),
+	"STRING":        reflect.TypeOf(""),
+	"BLOB":          reflect.TypeOf([]byte(nil)),
+	"UUID":          reflect.TypeOf(uuid.UUID{}),
+	"DATE":          timeType,
+	"TIMESTAMP":     timeType,
+	"TIMESTAMP_SEC": timeType,
+	"TIMESTAMP_MS":  timeType,
+	"TIMESTAMP_NS":  timeType,
+	"TIMESTAMP_TZ":  timeType,
+	"INTERVAL":      durationType,
+	"INTERNAL_ID":   reflect.TypeOf(InternalID{}),
+	"NODE":          reflect.TypeOf(Node{}),
+	"REL":           reflect.TypeOf(Relationship{}),
+	"RECURSIVE_REL": reflect.TypeOf(RecursiveRelationship{}),
+	"LIST":          reflect.TypeOf([]any(nil)),
+	"ARRAY":         reflect.TypeOf([]any(nil)),
+	"STRUCT":        reflect.TypeOf(map[string]any(nil)),
+	"UNION":         reflect.TypeOf(map[string]any(nil)),
+	"MAP":           reflect.TypeOf([]MapItem(nil)),
+}
+
+// canHold returns true if a field of the type can hold the values of a column
+// of the logical type.
+func canHold(fieldType reflect.Type, dataType DataType, weak bool) bool {
+	goType, known := columnGoTypes[dataType.Name]
+	for {
+		if reflect.PointerTo(fieldType).Implements(lbugScannerType) {
+			return true
+		}
+		if dataType.Name == "STRING" && reflect.PointerTo(fieldType).Implements(textUnmarshalerType) {
+			return true
+		}
+		if fieldType.Kind() != reflect.Pointer || known && goType.AssignableTo(fieldType) {
+			break
+		}
+		fieldType = fieldType.Elem()
+	}
+	if fieldType.Kind() == reflect.Interface {
+		// The types of nodes depend on their labels, see RegisterNodeType.
+		return fieldType.NumMethod() == 0 || dataType.Name == "NODE" || known && goType.Implements(fieldType)
+	}
+	if !known {
+		return false
+	}
+	if goType.AssignableTo(fieldType) || goType.Kind() == fieldType.Kind() && goType.ConvertibleTo(fieldType) {
+		return true
+	}
+	switch {
+	case isNumberKind(goType.Kind()) && isNumberKind(fieldType.Kind()):
+		return weak || widens(goType, fieldType)
+	case dataType.Name == "LIST" || dataType.Name == "ARRAY":
+		return fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Array
+	case dataType.Name == "STRUCT" || dataType.Name == "UNION":
+		return fieldType.Kind() == reflect.Struct || fieldType.Kind() == reflect.Map && fieldType.Key().Kind() == reflect.String
+	case dataType.Name == "NODE" || dataType.Name == "REL":
+		return holdsProperties(fieldType)
+	case dataType.Name == "MAP":
+		return fieldType.Kind() == reflect.Map
+	}
+	if !weak {
+		return false
+	}
+	scalar := isNumberKind(goType.Kind()) || goType.Kind() == reflect.Bool
+	switch {
+	case fieldType.Kind() == reflect.String:
+		return scalar || goType == timeType || goType == durationType || dataType.Name == "UUID" || dataType.Name == "INT128" || dataType.Name == "DECIMAL"
+	case goType.Kind() == reflect.String:
+		return isNumberKind(fieldType.Kind()) || fieldType.Kind() == reflect.Bool
+	case goType.Kind() == reflect.Bool:
+		return false
+	}
+	return dataType.Name == "DECIMAL" && isNumberKind(fieldType.Kind())
+}
+
+// holdsProperties returns true if NODE and REL values are decoded into the
+// struct type from their properties, i.e. for structs other than the types of
+// the graph values of the package.
+func holdsProperties(structType reflect.Type) bool {
+	if structType.Kind() != reflect.Struct {
+		return false
+	}
+	for _, name := range []string{"NODE", "REL", "RECURSIVE_REL"} {
+		if structType == columnGoTypes[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// isNumberKind returns true for the kinds of integers and floating point
+// numbers.
+func isNumberKind(kind reflect.Kind) bool {
+	return isIntKind(kind) || isUintKind(kind) || kind == reflect.Float32 || kind == reflect.Float64
+}
+
+func isIntKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Int64
+}
+
+func isUintKind(kind reflect.Kind) bool {
+	return kind >= reflect.Uint && kind <= reflect.Uint64
+}
+
+// widens returns true if every value of the number type from can be
+// represented by the number type to.
+func widens(from, to reflect.Type) bool {
+	switch {
+	case isIntKind(from.Kind()):
+		return isIntKind(to.Kind()) && to.Bits() >= from.Bits()
+	case isUintKind(from.Kind()):
+		return isUintKind(to.Kind()) && to.Bits() >= from.Bits() || isIntKind(to.Kind()) && to.Bits() > from.Bits()
+	}
+	return to.Kind() == reflect.Float64 || from.Kind() == reflect.Float32 && to.Kind() == reflect.Float32
+}
+
+// assignValue sets dst to a value returned by FlatTuple.GetValue, converting
+// it as CollectWithOptions does.
+func assignValue(dst reflect.Value, value any, opts CollectOptions) error {
+	if dst.CanAddr() {
+		if scanner, ok := dst.Addr().Interface().(LbugScanner); ok {
+			return scanner.ScanLbug(value)
+		}
+	}
+	if value == nil {
+		dst.SetZero()
+		return nil
+	}
+	if text, ok := value.(string); ok && dst.CanAddr() {
+		if unmarshaler, ok := dst.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return unmarshaler.UnmarshalText([]byte(text))
+		}
+	}
+	if node, ok := value.(Node); ok && dst.Kind() == reflect.Interface {
+		typed, err := typedNode(node, opts)
+		if err != nil {
+			return err
+		}
+		if !typed.Type().AssignableTo(dst.Type()) {
+			return fmt.Errorf("cannot assign %s of node %s to %s", typed.Type(), node.Label, dst.Type())
+		}
+		dst.Set(typed)
+		return nil
+	}
+	src := reflect.ValueOf(value)
+	if dst.Kind() == reflect.Pointer && !src.Type().AssignableTo(dst.Type()) {
+		elem := reflect.New(dst.Type().Elem())
+		if err := assignValue(elem.Elem(), value, opts); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	}
+	switch {
+	case src.Type().AssignableTo(dst.Type()):
+		dst.Set(src)
+		return nil
+	case isNumberKind(src.Kind()) && isNumberKind(dst.Kind()):
+		return assignNumber(dst, src, opts.WeakConversion)
+	case src.Kind() == dst.Kind() && src.Type().ConvertibleTo(dst.Type()) && src.Kind() != reflect.Slice && src.Kind() != reflect.Map:
+		dst.Set(src.Convert(dst.Type()))
+		return nil
+	}
+	switch v := value.(type) {
+	case Node:
+		if holdsProperties(dst.Type()) {
+			return assignStruct(dst, nodeFields(v), opts)
+		}
+	case Relationship:
+		if holdsProperties(dst.Type()) {
+			return assignStruct(dst, relationshipFields(v), opts)
+		}
+	case []any:
+		if dst.Kind() == reflect.Slice {
+			dst.Set(reflect.MakeSlice(dst.Type(), len(v), len(v)))
+		} else if dst.Kind() != reflect.Array || dst.Len() != len(v) {
+			break
+		}
+		for i, element := range v {
+			if err := assignValue(dst.Index(i), element, opts); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		return nil
+	case map[string]any:
+		if dst.Kind() == reflect.Struct {
+			return assignStruct(dst, v, opts)
+		}
+		if dst.Kind() != reflect.Map || dst.Type().Key().Kind() != reflect.String {
+			break
+		}
+		dst.Set(reflect.MakeMapWithSize(dst.Type(), len(v)))
+		for key, fieldValue := range v {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := assignValue(elem, fieldValue, opts); err != nil {
+				return fmt.Errorf("field %s: %w", key, err)
+			}
+			dst.SetMapIndex(reflect.ValueOf(key).Convert(dst.Type().Key()), elem)
+		}
+		return nil
+	case []MapItem:
+		if dst.Kind() != reflect.Map {
+			break
+		}
+		dst.Set(reflect.MakeMapWithSize(dst.Type(), len(v)))
+		for _, item := range v {
+			key := reflect.New(dst.Type().Key()).Elem()
+			if err := assignValue(key, item.Key, opts); err != nil {
+				return fmt.Errorf("map key: %w", err)
+			}
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := assignValue(elem, item.Value, opts); err != nil {
+				return fmt.Errorf("map value of key %v: %w", item.Key, err)
+			}
+			dst.SetMapIndex(key, elem)
+		}
+		return nil
+	}
+	if opts.WeakConversion {
+		if ok, err := assignWeak(dst, value); ok {
+			return err
+		}
+	}
+	return fmt.Errorf("cannot assign %T to %s", value, dst.Type())
+}
+
+// assignStruct sets the fields of dst to the fields of a STRUCT value.
+func assignStruct(dst reflect.Value, fields map[string]any, opts CollectOptions) error {
+	structType := dst.Type()
+	byName, err := decodedFields(structType, opts.tags())
+	if err != nil {
+		return err
+	}
+	for i := 0; i < structType.NumField(); i++ {
+		name, _, ok := taggedFieldName(structType.Field(i), opts.tags())
+		if !ok || byName[name] != i {
+			continue
+		}
+		if value, ok := fields[name]; ok {
+			if err := assignValue(dst.Field(i), value, opts); err != nil {
+				return fmt.Errorf("field %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// assignNumber sets the number dst to the number src if it fits. Integers and
+// floating point numbers are only converted into each other if weak is true.
+func assignNumber(dst, src reflect.Value, weak bool) error {
+	overflow := fmt.Errorf("%v overflows %s", src.Interface(), dst.Type())
+	switch {
+	case src.CanInt() && dst.CanInt():
+		if dst.OverflowInt(src.Int()) {
+			return overflow
+		}
+		dst.SetInt(src.Int())
+	case src.CanInt() && dst.CanUint():
+		if src.Int() < 0 || dst.OverflowUint(uint64(src.Int())) {
+			return overflow
+		}
+		dst.SetUint(uint64(src.Int()))
+	case src.CanUint() && dst.CanUint():
+		if dst.OverflowUint(src.Uint()) {
+			return overflow
+		}
+		dst.SetUint(src.Uint())
+	case src.CanUint() && dst.CanInt():
+		if src.Uint() > math.MaxInt64 || dst.OverflowInt(int64(src.Uint())) {
+			return overflow
+		}
+		dst.SetInt(int64(src.Uint()))
+	case src.CanFloat() && dst.CanFloat():
+		if dst.OverflowFloat(src.Float()) {
+			return overflow
+		}
+		dst.SetFloat(src.Float())
+	case !weak:
+		return fmt.Errorf("cannot assign %s to %s", src.Type(), dst.Type())
+	case dst.CanFloat():
+		if src.CanInt() {
+			dst.SetFloat(float64(src.Int()))
+		} else {
+			dst.SetFloat(float64(src.Uint()))
+		}
+	default:
+		number := src.Float()
+		if number != math.Trunc(number) {
+			return fmt.Errorf("%v is not an integer", number)
+		}
+		if number >= -(1<<63) && number < 1<<63 {
+			return assignNumber(dst, reflect.ValueOf(int64(number)), weak)
+		}
+		if number >= 0 && number < 1<<64 {
+			return assignNumber(dst, reflect.ValueOf(uint64(number)), weak)
+		}
+		return overflow
+	}
+	return nil
+}
+
+// assignWeak sets dst to the value converted to or from a string. ok is false
+// if the value and the destination have no such conversion.
+func assignWeak(dst reflect.Value, value any) (ok bool, err error) {
+	if dst.Kind() == reflect.String {
+		var text string
+		switch v := value.(type) {
+		case time.Time:
+			text = v.Format(time.RFC3339Nano)
+		case fmt.Stringer:
+			text = v.String()
+		case bool, int64, int32, int16, int8, uint64, uint32, uint16, uint8, float64, float32:
+			text = fmt.Sprint(v)
+		default:
+			return false, nil
+		}
+		dst.SetString(text)
+		return true, nil
+	}
+	switch v := value.(type) {
+	case string:
+		switch {
+		case dst.Kind() == reflect.Bool:
+			parsed, err := strconv.ParseBool(v)
+			if err != nil {
+				return true, err
+			}
+			dst.SetBool(parsed)
+			return true, nil
+		case isNumberKind(dst.Kind()):
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return true, err
+			}
+			if dst.CanFloat() {
+				return true, assignNumber(dst, reflect.ValueOf(parsed), true)
+			}
+			if integer, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return true, assignNumber(dst, reflect.ValueOf(integer), true)
+			}
+			if integer, err := strconv.ParseUint(v, 10, 64); err == nil {
+				return true, assignNumber(dst, reflect.ValueOf(integer), true)
+			}
+			return true, assignNumber(dst, reflect.ValueOf(parsed), true)
+		}
+	case decimal.Decimal:
+		if isNumberKind(dst.Kind()) {
+			return true, assignNumber(dst, reflect.ValueOf(v.InexactFloat64()), true)
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,440 @@
+package lbug
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type collectedPerson struct {
+	Name     string   `lbug:"name"`
+	Age      int      `lbug:"age"`
+	Eyesight *float64 `lbug:"eyesight"`
+	Hours    []int64  `lbug:"hours"`
+	Birth    time.Time
+	Ignored  string `lbug:"-"`
+}
+
+// partialPerson has fields compatible with their columns, Name, and fields
+// that are not, Age and Student.
+type partialPerson struct {
+	Name    string `lbug:"name"`
+	Age     string `lbug:"age"`
+	Student int32  `lbug:"student"`
+	Hours   []int64
+}
+
+var collectNames = []string{"name", "age", "student", "eyesight", "hours", "Birth"}
+
+var collectTypes = []DataType{
+	{Name: "STRING"}, {Name: "INT64"}, {Name: "BOOL"}, {Name: "DOUBLE"}, {Name: "LIST"}, {Name: "DATE"},
+}
+
+func TestCollectFieldsMismatches(t *testing.T) {
+	_, err := collectFields(reflect.TypeOf(partialPerson{}), collectNames, collectTypes, CollectOptions{})
+	var typeErr *CollectTypeError
+	assert.ErrorAs(t, err, &typeErr)
+	assert.Equal(t, []FieldMismatch{
+		{Field: "Age", FieldType: "string", Column: "age", ColumnType: "INT64"},
+		{Field: "Student", FieldType: "int32", Column: "student", ColumnType: "BOOL"},
+	}, typeErr.Mismatches)
+	assert.Equal(t, "cannot collect rows into lbug.partialPerson: field Age (string) cannot hold column age (INT64); field Student (int32) cannot hold column student (BOOL)", err.Error())
+
+	// Weak conversion formats the age, but still cannot convert a BOOL to a
+	// number.
+	_, err = collectFields(reflect.TypeOf(partialPerson{}), collectNames, collectTypes, CollectOptions{WeakConversion: true})
+	assert.ErrorAs(t, err, &typeErr)
+	assert.Equal(t, []FieldMismatch{{Field: "Student", FieldType: "int32", Column: "student", ColumnType: "BOOL"}}, typeErr.Mismatches)
+
+	fields, err := collectFields(reflect.TypeOf(collectedPerson{}), collectNames, collectTypes, CollectOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]int{{0}, {1}, nil, {2}, {3}, {4}}, fields)
+}
+
+// jsonPerson is tagged for encoding/json only, but for Nickname.
+type jsonPerson struct {
+	Name     string `json:"name,omitempty"`
+	Age      int    `json:"age"`
+	Nickname string `lbug:"name" json:"nick"`
+	Secret   string `json:"-"`
+	Hours    []int64
+	Student  bool `json:",omitempty"`
+}
+
+func TestCollectFieldsTags(t *testing.T) {
+	names := []string{"name", "age", "nick", "Secret", "Hours", "Student"}
+	types := []DataType{{Name: "STRING"}, {Name: "INT64"}, {Name: "STRING"}, {Name: "STRING"}, {Name: "LIST"}, {Name: "BOOL"}}
+	// The lbug tag of Nickname takes precedence over its json tag, and over
+	// the json tag of Name, which has a lower priority.
+	fields, err := collectFields(reflect.TypeOf(jsonPerson{}), names, types, CollectOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]int{{2}, {1}, nil, nil, {4}, {5}}, fields)
+
+	// With json first, Nickname decodes nick; json:"-" skips Secret and a
+	// tag without a name falls back to the field name.
+	fields, err = collectFields(reflect.TypeOf(jsonPerson{}), names, types, CollectOptions{Tags: []string{"json", "lbug"}})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]int{{0}, {1}, {2}, nil, {4}, {5}}, fields)
+
+	// Without tags, the fields are matched by name only.
+	stringTypes := []DataType{{Name: "STRING"}, {Name: "STRING"}, {Name: "STRING"}}
+	fields, err = collectFields(reflect.TypeOf(jsonPerson{}), []string{"Name", "Secret", "name"}, stringTypes, CollectOptions{Tags: []string{}})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]int{{0}, {3}, nil}, fields)
+
+	// A field named by a tag takes precedence over a field of the same name.
+	type shadowed struct {
+		Name  string
+		Label string `json:"Name"`
+	}
+	fields, err = collectFields(reflect.TypeOf(shadowed{}), []string{"Name"}, types, CollectOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]int{{1}}, fields)
+	var nested struct{ Inner shadowed }
+	assert.Nil(t, assignValue(reflect.ValueOf(&nested).Elem(), map[string]any{"Inner": map[string]any{"Name": "Alice"}}, CollectOptions{}))
+	assert.Equal(t, shadowed{Label: "Alice"}, nested.Inner)
+
+	// The lbug tag takes precedence over the json tag by default.
+	type prioritized struct {
+		Fourth string `json:"First"`
+		Third  string `lbug:"First"`
+	}
+	var prioritizedNested struct{ Inner prioritized }
+	assert.Nil(t, assignValue(reflect.ValueOf(&prioritizedNested).Elem(), map[string]any{"Inner": map[string]any{"First": "a"}}, CollectOptions{}))
+	assert.Equal(t, prioritized{Third: "a"}, prioritizedNested.Inner)
+
+	type conflicting struct {
+		First  string
+		Second string `lbug:"-" json:"First"`
+		Third  string `lbug:"First"`
+		Fourth string `json:"First"`
+		Fifth  string `lbug:"First"`
+	}
+	var conflict struct{ Inner conflicting }
+	err = assignValue(reflect.ValueOf(&conflict).Elem(), map[string]any{"Inner": map[string]any{"First": "a"}}, CollectOptions{})
+	assert.EqualError(t, err, "field Inner: cannot decode into lbug.conflicting: fields Third and Fifth both decode First")
+}
+
+// level is an enumeration decoded from its name.
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level %q", text)
+	}
+	return nil
+}
+
+func TestTextUnmarshaler(t *testing.T) {
+	assert.True(t, canHold(reflect.TypeOf(level(0)), DataType{Name: "STRING"}, false))
+	assert.True(t, canHold(reflect.TypeOf((*level)(nil)), DataType{Name: "STRING"}, false))
+	assert.False(t, canHold(reflect.TypeOf(level(0)), DataType{Name: "BOOL"}, false))
+	// level is an integer, still decoded from numbers.
+	assert.True(t, canHold(reflect.TypeOf(level(0)), DataType{Name: "INT64"}, false))
+
+	var l level
+	assert.Nil(t, assignValue(reflect.ValueOf(&l).Elem(), "high", CollectOptions{}))
+	assert.Equal(t, level(2), l)
+	assert.EqualError(t, assignValue(reflect.ValueOf(&l).Elem(), "medium", CollectOptions{}), `unknown level "medium"`)
+	assert.Nil(t, assignValue(reflect.ValueOf(&l).Elem(), int64(1), CollectOptions{}))
+	assert.Equal(t, level(1), l)
+	var optional *level
+	assert.Nil(t, assignValue(reflect.ValueOf(&optional).Elem(), "low", CollectOptions{}))
+	assert.Equal(t, level(1), *optional)
+	assert.Nil(t, assignValue(reflect.ValueOf(&optional).Elem(), nil, CollectOptions{}))
+	assert.Nil(t, optional)
+}
+
+func TestCanHold(t *testing.T) {
+	type userID int64
+	for _, test := range []struct {
+		value    any
+		dataType string
+		strict   bool
+		weak     bool
+	}{
+		{int64(0), "INT32", true, true},
+		{int32(0), "INT64", false, true},
+		{userID(0), "INT64", true, true},
+		{uint32(0), "UINT16", true, true},
+		{int32(0), "UINT16", true, true},
+		{int16(0), "UINT16", false, true},
+		{float64(0), "FLOAT", true, true},
+		{float32(0), "DOUBLE", false, true},
+		{float64(0), "INT64", false, true},
+		{"", "INT64", false, true},
+		{int64(0), "STRING", false, true},
+		{false, "STRING", false, true},
+		{"", "UUID", false, true},
+		{int64(0), "BOOL", false, false},
+		{time.Time{}, "TIMESTAMP", true, true},
+		{Date{}, "DATE", true, true},
+		{[]string(nil), "LIST", true, true},
+		{[2]int64{}, "ARRAY", true, true},
+		{struct{ A int64 }{}, "STRUCT", true, true},
+		{map[string]int64(nil), "STRUCT", true, true},
+		{map[int64]string(nil), "MAP", true, true},
+		{Node{}, "NODE", true, true},
+		{Node{}, "REL", false, false},
+	} {
+		fieldType := reflect.TypeOf(test.value)
+		assert.Equal(t, test.strict, canHold(fieldType, DataType{Name: test.dataType}, false), "%s from %s", fieldType, test.dataType)
+		assert.Equal(t, test.weak, canHold(fieldType, DataType{Name: test.dataType}, true), "%s from %s with weak conversion", fieldType, test.dataType)
+	}
+	anyType := reflect.TypeOf((*any)(nil)).Elem()
+	assert.True(t, canHold(anyType, DataType{Name: "TYPE_60"}, false))
+	assert.True(t, canHold(reflect.TypeOf(new(int64)), DataType{Name: "INT64"}, false))
+}
+
+func TestAssignValue(t *testing.T) {
+	var hours []int32
+	assert.Nil(t, assignValue(reflect.ValueOf(&hours).Elem(), []any{int64(1), int64(2)}, CollectOptions{}))
+	assert.Equal(t, []int32{1, 2}, hours)
+	assert.ErrorContains(t, assignValue(reflect.ValueOf(&hours).Elem(), []any{int64(1) << 40}, CollectOptions{}), "element 0: 1099511627776 overflows int32")
+
+	var eyesight *float64
+	assert.Nil(t, assignValue(reflect.ValueOf(&eyesight).Elem(), 5.5, CollectOptions{}))
+	assert.Equal(t, 5.5, *eyesight)
+	assert.Nil(t, assignValue(reflect.ValueOf(&eyesight).Elem(), nil, CollectOptions{}))
+	assert.Nil(t, eyesight)
+
+	var address struct {
+		City string `lbug:"city"`
+		Zip  int
+	}
+	assert.Nil(t, assignValue(reflect.ValueOf(&address).Elem(), map[string]any{"city": "Waterloo", "Zip": int64(200)}, CollectOptions{}))
+	assert.Equal(t, "Waterloo", address.City)
+	assert.Equal(t, 200, address.Zip)
+
+	var scores map[string]float64
+	assert.Nil(t, assignValue(reflect.ValueOf(&scores).Elem(), []MapItem{{Key: "a", Value: 1.5}}, CollectOptions{}))
+	assert.Equal(t, map[string]float64{"a": 1.5}, scores)
+
+	var age int
+	assert.ErrorContains(t, assignValue(reflect.ValueOf(&age).Elem(), "42", CollectOptions{}), "cannot assign string to int")
+	assert.Nil(t, assignValue(reflect.ValueOf(&age).Elem(), "42", CollectOptions{WeakConversion: true}))
+	assert.Equal(t, 42, age)
+	assert.ErrorContains(t, assignValue(reflect.ValueOf(&age).Elem(), 1.5, CollectOptions{WeakConversion: true}), "1.5 is not an integer")
+	var label string
+	assert.Nil(t, assignValue(reflect.ValueOf(&label).Elem(), int64(7), CollectOptions{WeakConversion: true}))
+	assert.Equal(t, "7", label)
+}
+
+func TestCollect(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	result, err := conn.Query("MATCH (a:person) WHERE a.ID < 3 RETURN a.fName AS name, a.age AS age, a.eyeSight AS eyesight, a.workedHours AS hours, a.birthdate AS Birth ORDER BY a.ID;")
+	assert.Nil(t, err)
+	defer result.Close()
+	people, err := Collect[collectedPerson](result)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(people))
+	assert.Equal(t, "Alice", people[0].Name)
+	assert.Equal(t, 35, people[0].Age)
+	assert.Equal(t, 5.0, *people[0].Eyesight)
+	assert.Equal(t, []int64{10, 5}, people[0].Hours)
+	assert.Equal(t, 1900, people[0].Birth.Year())
+	assert.Equal(t, "Bob", people[1].Name)
+}
+
+func TestCollectPartiallyCompatible(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	query := "MATCH (a:person) RETURN a.fName AS name, a.age AS age, a.isStudent AS student ORDER BY a.ID;"
+	result, err := conn.Query(query)
+	assert.Nil(t, err)
+	defer result.Close()
+	_, err = Collect[partialPerson](result)
+	var typeErr *CollectTypeError
+	assert.ErrorAs(t, err, &typeErr)
+	assert.Equal(t, 2, len(typeErr.Mismatches))
+	// No row is read when the types do not match.
+	assert.True(t, result.HasNext())
+
+	type weakPerson struct {
+		Name    string `lbug:"name"`
+		Age     string `lbug:"age"`
+		Student string `lbug:"student"`
+	}
+	people, err := CollectWithOptions[weakPerson](result, CollectOptions{WeakConversion: true})
+	assert.Nil(t, err)
+	assert.Equal(t, weakPerson{Name: "Alice", Age: "35", Student: "true"}, people[0])
+
+	_, err = Collect[int](result)
+	assert.ErrorContains(t, err, "cannot collect rows into int: not a struct type")
+}
+
+// cents decodes amounts from their DOUBLE value in euros or their STRING
+// representation.
+type cents int64
+
+func (c *cents) ScanLbug(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*c = -1
+	case float64:
+		*c = cents(math.Round(v * 100))
+	case string:
+		euros, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*c = cents(math.Round(euros * 100))
+	default:
+		return fmt.Errorf("cannot scan %T into cents", value)
+	}
+	return nil
+}
+
+func TestLbugScanner(t *testing.T) {
+	// A scanner accepts columns of any type, and takes precedence over the
+	// conversion of its underlying type.
+	centsType := reflect.TypeOf(cents(0))
+	assert.True(t, canHold(centsType, DataType{Name: "STRING"}, false))
+	assert.True(t, canHold(reflect.PointerTo(centsType), DataType{Name: "NODE"}, false))
+
+	var price cents
+	assert.Nil(t, assignValue(reflect.ValueOf(&price).Elem(), 12.5, CollectOptions{}))
+	assert.Equal(t, cents(1250), price)
+	assert.Nil(t, assignValue(reflect.ValueOf(&price).Elem(), "0.99", CollectOptions{}))
+	assert.Equal(t, cents(99), price)
+	assert.Nil(t, assignValue(reflect.ValueOf(&price).Elem(), nil, CollectOptions{}))
+	assert.Equal(t, cents(-1), price)
+	assert.ErrorContains(t, assignValue(reflect.ValueOf(&price).Elem(), true, CollectOptions{}), "cannot scan bool into cents")
+
+	// A pointer to a scanner is nil for NULL values, without calling
+	// ScanLbug.
+	optional := new(cents)
+	assert.Nil(t, assignValue(reflect.ValueOf(&optional).Elem(), nil, CollectOptions{}))
+	assert.Nil(t, optional)
+	assert.Nil(t, assignValue(reflect.ValueOf(&optional).Elem(), 1.0, CollectOptions{}))
+	assert.Equal(t, cents(100), *optional)
+
+	type order struct {
+		Price cents            `lbug:"price"`
+		Items map[string]cents `lbug:"items"`
+	}
+	var collected order
+	dst := reflect.ValueOf(&collected).Elem()
+	assert.Nil(t, assignValue(dst, map[string]any{"price": 3.0, "items": map[string]any{"tea": "1.5"}}, CollectOptions{}))
+	assert.Equal(t, order{Price: 300, Items: map[string]cents{"tea": 150}}, collected)
+}
+
+func TestCollectProjection(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE step(id INT64, PRIMARY KEY(id));")
+	mustRun(t, conn, "CREATE REL TABLE next(FROM step TO step);")
+	mustRun(t, conn, "UNWIND range(0, 3) AS i CREATE (:step {id: i});")
+	mustRun(t, conn, "MATCH (a:step), (b:step) WHERE b.id = a.id + 1 CREATE (a)-[:next]->(b);")
+	// The path column cannot be converted: only the columns decoded into
+	// fields are.
+	conn.SetMaxPathElements(2)
+	const query = "MATCH p = (a:step {id: 0})-[:next*3..3]->(b:step) RETURN p, b.id AS last;"
+	type last struct {
+		Last int64 `lbug:"last"`
+	}
+
+	result, err := conn.Query(query)
+	assert.Nil(t, err)
+	rows, err := Collect[last](result)
+	assert.Nil(t, err)
+	assert.Equal(t, []last{{Last: 3}}, rows)
+	result.Close()
+
+	result, err = conn.Query(query)
+	assert.Nil(t, err)
+	_, err = Collect[struct {
+		Path any `lbug:"p"`
+	}](result)
+	assert.ErrorIs(t, err, ErrPathTooLong)
+	result.Close()
+
+	result, err = conn.Query(query)
+	assert.Nil(t, err)
+	defer result.Close()
+	var row last
+	assert.Nil(t, result.ScanStruct(&row))
+	assert.Equal(t, last{Last: 3}, row)
+	// The matching of the fields is kept for the next rows of the result.
+	plan := result.scanPlan
+	assert.Equal(t, []uint64{1}, plan.columns)
+	assert.ErrorContains(t, result.ScanStruct(&row), "no more rows to scan")
+	assert.Same(t, plan, result.scanPlan)
+	assert.ErrorContains(t, result.ScanStructWithOptions(&row, CollectOptions{WeakConversion: true}), "no more rows to scan")
+	assert.NotSame(t, plan, result.scanPlan)
+
+	result, err = conn.Query(query)
+	assert.Nil(t, err)
+	defer result.Close()
+	var id int64
+	assert.Nil(t, result.Scan(nil, &id))
+	assert.Equal(t, int64(3), id)
+}
+
+// wideQuery returns 20 columns of 2000 rows, of which benchmarks decode 3.
+var wideQuery = func() string {
+	columns := make([]string, 20)
+	for i := range columns {
+		if i%2 == 0 {
+			columns[i] = fmt.Sprintf("i + %d AS c%d", i, i)
+		} else {
+			columns[i] = fmt.Sprintf("'value ' + CAST(i AS STRING) AS c%d", i)
+		}
+	}
+	return "UNWIND range(1, 2000) AS i RETURN " + strings.Join(columns, ", ") + ";"
+}()
+
+type narrowRow struct {
+	C0  int64  `lbug:"c0"`
+	C1  string `lbug:"c1"`
+	C10 int64  `lbug:"c10"`
+}
+
+// BenchmarkCollectWide collects 3 of the 20 columns of a wide result, only
+// converting those.
+func BenchmarkCollectWide(b *testing.B) {
+	_, conn := SetupTestDatabase(b)
+	for i := 0; i < b.N; i++ {
+		result, err := conn.Query(wideQuery)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := Collect[narrowRow](result); err != nil {
+			b.Fatal(err)
+		}
+		result.Close()
+	}
+}
+
+// BenchmarkCollectWideAllColumns converts the 20 columns of every row of the
+// wide result before decoding 3, as Collect did before the projection.
+func BenchmarkCollectWideAllColumns(b *testing.B) {
+	_, conn := SetupTestDatabase(b)
+	for i := 0; i < b.N; i++ {
+		result, err := conn.Query(wideQuery)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, err = collectRows[narrowRow](result.GetColumnNames(), result.GetColumnDataTypes(), CollectOptions{}, result.HasNext, func([]uint64) ([]any, error) {
+			tuple, err := result.Next()
+			if err != nil {
+				return nil, err
+			}
+			defer tuple.Close()
+			return tuple.GetAsSlice()
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		result.Close()
+	}
+}
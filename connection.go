@@ -5,41 +5,154 @@ package lbug
 import "C"
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
 // Connection represents a connection to a Lbug database.
 type Connection struct {
-	cConnection C.lbug_connection
-	database    *Database
-	isClosed    bool
+	cConnection      C.lbug_connection
+	database         *Database
+	isClosed         bool
+	autoCloseResults bool
+	requireOrdered   bool
+	unknownType      UnknownTypePolicy
+	timeRange        TimeRangePolicy
+	exportOptions    ExportOptions
+	maxRows          uint64
+	maxParameterSize uint64
+	maxPathElements  uint64
+	rawStrings       bool
+	defaultParams    map[string]any
+	stickyDefaults   bool
+	handleID         uint64
+	clock            Clock
+	// interceptorState is the state of the interceptors of the calls on the
+	// connection.
+	interceptorState
+	// snapshotIdleTimeout is the idle timeout of the snapshots begun on the
+	// connection, see SetSnapshotIdleTimeout.
+	snapshotIdleTimeout time.Duration
+	// closeMutex is held for reading by the operations on the connection and
+	// on the statements, results and tuples created from it, and for writing
+	// while they are closed, so that a handle is never destroyed while it is
+	// in use by another goroutine. It does not lock on a single-threaded
+	// connection, see ConnectionOptions.
+	closeMutex closeGuard
+	// children are the statements, results and tuples created from the
+	// connection and not closed yet, by handle ID, so that closeWithHandles
+	// can close them.
+	childrenMutex sync.Mutex
+	children      map[uint64]any
+	// closing runs the close of CloseWithContext in the background.
+	closing backgroundClose
+	// interruptMutex protects the C connection from being destroyed during
+	// Interrupt, which must not wait for the running query.
+	interruptMutex  sync.Mutex
+	schemaMutex     sync.Mutex
+	tables          map[string]bool
+	tempTablesMutex sync.Mutex
+	tempTables      map[string]bool
+	rowSourcesMutex sync.Mutex
+	rowSources      map[string]*rowSource
+	cgoCalls        cgoCallCounters
+	// snapshotMutex protects the state of the explicit transaction tracked
+	// to advance the snapshot of the database, see SnapshotID.
+	snapshotMutex sync.Mutex
+	inTransaction bool
+	pendingWrites bool
+	// pendingSchemaChanges is set when the explicit transaction changed the
+	// schema, and ownCheckpoints and ownSchemaChanges count the checkpoints
+	// and schema changes made through the connection, see
+	// ErrResultInvalidated.
+	pendingSchemaChanges bool
+	ownCheckpoints       uint64
+	ownSchemaChanges     uint64
+	// transactionResults are the results returned in the explicit
+	// transaction, see checkOpenResults.
+	transactionResults []*QueryResult
+	// transaction is the Transaction begun on the connection, if any.
+	transaction *Transaction
+	// lastCallFailed is set when the engine fails the last query, statement
+	// or iteration on the connection, and cleared when a query succeeds. A
+	// Pool discards the connections released with it set.
+	lastCallFailed atomic.Bool
 }
 
 // OpenConnection opens a connection to the specified database.
 func OpenConnection(database *Database) (*Connection, error) {
 	conn := &Connection{}
 	conn.database = database
+	database.closeMutex.RLock()
+	defer database.closeMutex.RUnlock()
+	if database.isClosed {
+		conn.isClosed = true
+		return conn, &Error{Op: OpOpen, Err: &closedError{"failed to open connection because the database is closed"}}
+	}
+	if failure := database.health.failure(); failure != nil {
+		conn.isClosed = true
+		return conn, &Error{Op: OpOpen, Message: "failed to open connection because the database has failed", Err: failure}
+	}
 	status := C.lbug_connection_init(&database.cDatabase, &conn.cConnection)
 	if status != C.LbugSuccess {
-		return conn, fmt.Errorf("failed to open connection with status %d", status)
+		return conn, &Error{Op: OpOpen, Message: fmt.Sprintf("failed to open connection with status %d", status)}
 	}
+	conn.handleID = handles.register(HandleConnection, conn)
+	database.stats.openConnections.Add(1)
 	return conn, nil
 }
 
 // Close releases the underlying C resources for the connection.
 // MUST be called when done to prevent resource leaks.
+// The temporary tables created with TempTable that have not been dropped yet
+// are dropped first. Close waits for the operations running on the
+// connection in other goroutines to complete.
 func (conn *Connection) Close() {
+	conn.dropTempTables()
+	conn.closeMutex.Lock()
+	defer conn.closeMutex.Unlock()
+	conn.close()
+}
+
+// close destroys the C connection. closeMutex must be held for writing.
+func (conn *Connection) close() {
 	if conn.isClosed {
 		return
 	}
-	C.lbug_connection_destroy(&conn.cConnection)
+	conn.interruptMutex.Lock()
+	conn.countCgoCall(cgoClose)
+	if conn.checkDatabase() == nil {
+		C.lbug_connection_destroy(&conn.cConnection)
+	}
 	conn.isClosed = true
+	conn.interruptMutex.Unlock()
+	conn.endTransaction()
+	handles.unregister(HandleConnection, conn.handleID)
+	if conn.handleID != 0 {
+		conn.stats().openConnections.Add(-1)
+	}
+}
+
+// closed reports whether the connection is closed.
+func (conn *Connection) closed() bool {
+	conn.closeMutex.RLock()
+	defer conn.closeMutex.RUnlock()
+	return conn.isClosed
 }
 
 // GetMaxNumThreads returns the maximum number of threads that can be used for
 // executing a query in parallel.
 func (conn *Connection) GetMaxNumThreads() uint64 {
+	conn.closeMutex.RLock()
+	defer conn.closeMutex.RUnlock()
+	if conn.isClosed {
+		return 0
+	}
 	numThreads := C.uint64_t(0)
 	C.lbug_connection_get_max_num_thread_for_exec(&conn.cConnection, &numThreads)
 	return uint64(numThreads)
@@ -48,11 +161,22 @@ func (conn *Connection) GetMaxNumThreads() uint64 {
 // SetMaxNumThreads sets the maximum number of threads that can be used for
 // executing a query in parallel.
 func (conn *Connection) SetMaxNumThreads(numThreads uint64) {
+	conn.closeMutex.RLock()
+	defer conn.closeMutex.RUnlock()
+	if conn.isClosed {
+		return
+	}
 	C.lbug_connection_set_max_num_thread_for_exec(&conn.cConnection, C.uint64_t(numThreads))
 }
 
 // Interrupt interrupts the execution of the current query on the connection.
+// It may be called from another goroutine while the query is running.
 func (conn *Connection) Interrupt() {
+	conn.interruptMutex.Lock()
+	defer conn.interruptMutex.Unlock()
+	if conn.isClosed {
+		return
+	}
 	C.lbug_connection_interrupt(&conn.cConnection)
 }
 
@@ -60,49 +184,264 @@ func (conn *Connection) Interrupt() {
 // The timeout is specified in milliseconds. A value of 0 means no timeout.
 // If a query takes longer than the specified timeout, it will be interrupted.
 func (conn *Connection) SetTimeout(timeout uint64) {
+	conn.closeMutex.RLock()
+	defer conn.closeMutex.RUnlock()
+	if conn.isClosed {
+		return
+	}
 	C.lbug_connection_set_query_timeout(&conn.cConnection, C.uint64_t(timeout))
 }
 
+// SetQueryTimeout is like SetTimeout with the timeout as a time.Duration,
+// which is rounded up to a whole number of milliseconds. A timeout of zero or
+// less means no timeout.
+func (conn *Connection) SetQueryTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		conn.SetTimeout(0)
+		return
+	}
+	conn.SetTimeout(uint64((timeout + time.Millisecond - 1) / time.Millisecond))
+}
+
+// SetAutoCloseResults enables or disables automatic closing of the query
+// results returned by the connection. When enabled, a QueryResult closes
+// itself as soon as HasNext reports that the result set is exhausted after at
+// least one tuple has been fetched. The tuples fetched from the result are
+// closed with it, so the last one must be read before HasNext is called
+// again. Calling Close explicitly remains safe.
+// Results that need to be rewound with ResetIterator should be obtained with
+// auto-close disabled, because an auto-closed result cannot be iterated again.
+func (conn *Connection) SetAutoCloseResults(enabled bool) {
+	conn.autoCloseResults = enabled
+}
+
+// SetRequireOrderedIteration enables or disables a check on the features that
+// rely on the order of the rows being stable across identical runs, such as
+// resuming iteration from a position. When enabled, these features fail with
+// ErrUnorderedResult if the query has no ORDER BY. Queries whose ordering
+// cannot be determined are not rejected. See QueryResult.IsOrdered.
+func (conn *Connection) SetRequireOrderedIteration(enabled bool) {
+	conn.requireOrdered = enabled
+}
+
+// SetUnknownTypePolicy sets how the values of logical types that the bindings
+// do not support are converted by the results of subsequent queries. The
+// policy applies to GetValue, GetAsSlice and GetAsMap of FlatTuple, including
+// values nested in lists, structs, maps, nodes and relationships.
+func (conn *Connection) SetUnknownTypePolicy(policy UnknownTypePolicy) {
+	conn.unknownType = policy
+}
+
+// SetTimeRangePolicy sets how the DATE and TIMESTAMP values outside the years
+// 0 to 9999 are converted by the results of subsequent queries, see
+// TimeRangePolicy. The default, TimeRangeStrict, fails their conversion.
+func (conn *Connection) SetTimeRangePolicy(policy TimeRangePolicy) {
+	conn.timeRange = policy
+}
+
+// SetMaxPathElements sets the maximum number of nodes and relationships of the
+// RECURSIVE_REL values decoded by the results of subsequent queries, as a
+// safety valve against variable-length paths over dense graphs. A longer path
+// is not decoded, and its conversion fails with a *PathLengthError matching
+// ErrPathTooLong. Zero, the default, disables the limit.
+func (conn *Connection) SetMaxPathElements(maxElements uint64) {
+	conn.maxPathElements = maxElements
+}
+
+// SetMaxRows sets the maximum number of rows returned by the results of
+// subsequent queries on the connection, as a safety valve against runaway
+// queries. Once a result has returned the maximum number of rows, Next
+// returns a *RowLimitError instead of the next row, and the truncation is
+// counted in DatabaseStats.RowLimitsExceeded. Queries with a smaller LIMIT are
+// unaffected. The limit applies to the queries run by the helpers of the
+// package on the connection too. Zero, the default, disables the limit.
+func (conn *Connection) SetMaxRows(maxRows uint64) {
+	conn.maxRows = maxRows
+}
+
+// SetTimeoutString sets the timeout for the queries executed on the connection
+// from a duration string such as "30s" or "250ms". See ParseTimeout for the
+// accepted formats. The system configuration of the engine has no query
+// timeout, which is set on each connection, so SystemConfig has no
+// counterpart of SetTimeoutString.
+func (conn *Connection) SetTimeoutString(timeout string) error {
+	milliseconds, err := ParseTimeout(timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetTimeout(milliseconds)
+	return nil
+}
+
 // Query executes the specified query string and returns the result.
+//
+// In an explicit transaction, a query that may write, as classified for
+// SnapshotID, fails with an *OpenResultError while a result returned in the
+// transaction is open and has tuples left, instead of invalidating the result
+// in the middle of its iteration. Reading and updating nodes one by one in a
+// transaction therefore requires reading the whole result first, for example
+// with QueryResult.Freeze, or closing it. The same applies to Execute and
+// PreparedStatement.Exec. Outside explicit transactions, writes are not
+// restricted. The results remain readable after COMMIT and ROLLBACK.
 func (conn *Connection) Query(query string) (*QueryResult, error) {
+	out, err := conn.intercept(&call{op: traceQuery, query: query})
+	return out.result, err
+}
+
+// query runs the query once it has gone through the interceptors.
+func (conn *Connection) query(query string) (*QueryResult, error) {
+	conn.closeMutex.RLock()
+	defer conn.closeMutex.RUnlock()
+	if conn.isClosed {
+		return nil, &Error{Op: OpExecute, Query: query, Err: &closedError{"failed to execute query because the connection is closed"}}
+	}
+	if err := conn.checkQueryString(query); err != nil {
+		return nil, &Error{Op: OpExecute, Query: query, Err: err}
+	}
+	if err := conn.checkOpenResults(query); err != nil {
+		return nil, &Error{Op: OpExecute, Query: query, Err: err}
+	}
 	cQuery := C.CString(query)
 	defer C.free(unsafe.Pointer(cQuery))
-	queryResult := &QueryResult{}
-	queryResult.connection = conn
+	queryResult := conn.newQueryResult(query)
+	if err := conn.reserveHandle(HandleQueryResult); err != nil {
+		return nil, &Error{Op: OpExecute, Query: query, Err: err}
+	}
+	conn.countCgoCall(cgoQuery)
+	if err := conn.checkDatabase(); err != nil {
+		handles.cancel(HandleQueryResult)
+		return nil, &Error{Op: OpExecute, Query: query, Err: err}
+	}
 	status := C.lbug_connection_query(&conn.cConnection, cQuery, &queryResult.cQueryResult)
+	if changesSchema(query) {
+		conn.InvalidateSchemaCache()
+	}
+	if status == C.LbugSuccess {
+		queryResult.handleID = conn.trackChild(HandleQueryResult, queryResult)
+		conn.stats().openQueryResults.Add(1)
+	} else {
+		handles.cancel(HandleQueryResult)
+	}
 	if status != C.LbugSuccess || !C.lbug_query_result_is_success(&queryResult.cQueryResult) {
 		cErrMsg := C.lbug_query_result_get_error_message(&queryResult.cQueryResult)
 		defer C.lbug_destroy_string(cErrMsg)
-		queryResult.Close()
-		return nil, fmt.Errorf("%s", C.GoString(cErrMsg))
+		queryResult.close()
+		return nil, conn.engineError(OpExecute, query, C.GoString(cErrMsg))
 	}
+	conn.lastCallFailed.Store(false)
+	queryResult.succeeded = true
+	queryResult.snapshotID = conn.recordWrites(query)
+	queryResult.invalidation = conn.invalidationMark()
+	conn.trackTransactionResult(queryResult)
+	conn.summarizeResultInTransaction(queryResult)
 	return queryResult, nil
 }
 
+// newQueryResult returns a result of the query, not created in C yet, with
+// the settings of the connection.
+func (conn *Connection) newQueryResult(query string) *QueryResult {
+	return &QueryResult{
+		connection:     conn,
+		query:          query,
+		autoClose:      conn.autoCloseResults,
+		requireOrdered: conn.requireOrdered,
+		ordering:       detectOrdering(query),
+		converter: valueConverter{
+			policy:          conn.unknownType,
+			timeRange:       conn.timeRange,
+			maxPathElements: conn.maxPathElements,
+		},
+		exportOptions: conn.exportOptions,
+		maxRows:       conn.maxRows,
+	}
+}
+
 // Execute executes the specified prepared statement with the specified arguments and returns the result.
 // The arguments are a map of parameter names to values.
 func (conn *Connection) Execute(preparedStatement *PreparedStatement, args map[string]any) (*QueryResult, error) {
-	queryResult := &QueryResult{}
-	queryResult.connection = conn
+	return conn.executeSensitive(preparedStatement, args, nil)
+}
+
+// executeSensitive is Execute with the names of the parameters marked
+// sensitive for the call, in addition to those of the connection.
+func (conn *Connection) executeSensitive(preparedStatement *PreparedStatement, args map[string]any, sensitive []string) (*QueryResult, error) {
+	out, err := conn.intercept(&call{op: traceExecute, query: preparedStatement.query, statement: preparedStatement, args: args, sensitive: sensitive})
+	return out.result, err
+}
+
+// execute executes the statement once the call has gone through the
+// interceptors.
+func (conn *Connection) execute(preparedStatement *PreparedStatement, args map[string]any) (*QueryResult, error) {
+	conn.closeMutex.RLock()
+	defer conn.closeMutex.RUnlock()
+	if conn.isClosed {
+		return nil, &Error{Op: OpExecute, Query: preparedStatement.query, Err: &closedError{"failed to execute because the connection is closed"}}
+	}
+	if preparedStatement.isClosed {
+		return nil, &Error{Op: OpExecute, Query: preparedStatement.query, Err: &closedError{"failed to execute because the prepared statement is closed"}}
+	}
+	if err := conn.checkOpenResults(preparedStatement.query); err != nil {
+		return nil, &Error{Op: OpExecute, Query: preparedStatement.query, Err: err}
+	}
+	queryResult := conn.newQueryResult(preparedStatement.query)
 	for key, value := range args {
 		err := conn.bindParameter(preparedStatement, key, value)
 		if err != nil {
-			queryResult.Close()
+			queryResult.close()
 			return nil, err
 		}
 	}
+	if err := conn.bindDefaultParams(preparedStatement, args); err != nil {
+		queryResult.close()
+		return nil, err
+	}
+	if err := conn.bindRegexes(preparedStatement, args, queryResult); err != nil {
+		queryResult.close()
+		return nil, err
+	}
+	if err := conn.reserveHandle(HandleQueryResult); err != nil {
+		queryResult.close()
+		return nil, &Error{Op: OpExecute, Query: preparedStatement.query, Err: err}
+	}
+	conn.countCgoCall(cgoExecute)
+	if err := conn.checkDatabase(); err != nil {
+		handles.cancel(HandleQueryResult)
+		queryResult.close()
+		return nil, &Error{Op: OpExecute, Query: preparedStatement.query, Err: err}
+	}
 	status := C.lbug_connection_execute(&conn.cConnection, &preparedStatement.cPreparedStatement, &queryResult.cQueryResult)
+	if preparedStatement.changesSchema {
+		conn.InvalidateSchemaCache()
+	}
+	if status == C.LbugSuccess {
+		queryResult.handleID = conn.trackChild(HandleQueryResult, queryResult)
+		conn.stats().openQueryResults.Add(1)
+	} else {
+		handles.cancel(HandleQueryResult)
+	}
 	if status != C.LbugSuccess || !C.lbug_query_result_is_success(&queryResult.cQueryResult) {
 		cErrMsg := C.lbug_query_result_get_error_message(&queryResult.cQueryResult)
 		defer C.lbug_destroy_string(cErrMsg)
-		queryResult.Close()
-		return nil, fmt.Errorf("%s", C.GoString(cErrMsg))
+		queryResult.close()
+		return nil, conn.engineError(OpExecute, preparedStatement.query, C.GoString(cErrMsg))
 	}
+	conn.lastCallFailed.Store(false)
+	queryResult.succeeded = true
+	queryResult.snapshotID = conn.recordWrites(preparedStatement.query)
+	queryResult.invalidation = conn.invalidationMark()
+	conn.trackTransactionResult(queryResult)
+	conn.summarizeResultInTransaction(queryResult)
 	return queryResult, nil
 }
 
 // BindParameter binds a parameter to the prepared statement.
 func (conn *Connection) bindParameter(preparedStatement *PreparedStatement, key string, value any) error {
+	if err := conn.checkParameterSize(key, value); err != nil {
+		return &Error{Op: OpBind, Query: preparedStatement.query, Parameter: key, Err: err}
+	}
+	if err := conn.checkParameterStrings(key, value); err != nil {
+		return &Error{Op: OpBind, Query: preparedStatement.query, Parameter: key, Err: err}
+	}
 	cKey := C.CString(key)
 	defer C.free(unsafe.Pointer(cKey))
 	var status C.lbug_state
@@ -110,12 +449,13 @@ func (conn *Connection) bindParameter(preparedStatement *PreparedStatement, key
 	var valueConversionError error
 	cValue, valueConversionError = goValueToLbugValue(value)
 	if valueConversionError != nil {
-		return fmt.Errorf("failed to convert Go value to Lbug value: %v", valueConversionError)
+		return &Error{Op: OpBind, Query: preparedStatement.query, Parameter: key, Message: fmt.Sprintf("failed to convert Go value of type %T to Lbug value for parameter %s", value, key), Err: valueConversionError}
 	}
 	defer C.lbug_value_destroy(cValue)
+	conn.countCgoCall(cgoBind)
 	status = C.lbug_prepared_statement_bind_value(&preparedStatement.cPreparedStatement, cKey, cValue)
 	if status != C.LbugSuccess {
-		return fmt.Errorf("failed to bind value with status %d", status)
+		return &Error{Op: OpBind, Query: preparedStatement.query, Parameter: key, Message: fmt.Sprintf("failed to bind value with status %d", status)}
 	}
 	return nil
 }
@@ -123,15 +463,160 @@ func (conn *Connection) bindParameter(preparedStatement *PreparedStatement, key
 // Prepare returns a prepared statement for the specified query string.
 // The prepared statement can be used to execute the query with parameters.
 func (conn *Connection) Prepare(query string) (*PreparedStatement, error) {
-	cQuery := C.CString(query)
-	defer C.free(unsafe.Pointer(cQuery))
+	out, err := conn.intercept(&call{op: tracePrepare, query: query})
+	if out.statement == nil {
+		// The call failed before the statement was created.
+		out.statement = &PreparedStatement{connection: conn, isClosed: true}
+	}
+	return out.statement, err
+}
+
+// prepare prepares the statement once the call has gone through the
+// interceptors.
+func (conn *Connection) prepare(query string) (*PreparedStatement, error) {
 	preparedStatement := &PreparedStatement{}
 	preparedStatement.connection = conn
+	conn.closeMutex.RLock()
+	defer conn.closeMutex.RUnlock()
+	if conn.isClosed {
+		preparedStatement.isClosed = true
+		return preparedStatement, &Error{Op: OpPrepare, Query: query, Err: &closedError{"failed to prepare because the connection is closed"}}
+	}
+	if err := conn.checkQueryString(query); err != nil {
+		preparedStatement.isClosed = true
+		return preparedStatement, &Error{Op: OpPrepare, Query: query, Err: err}
+	}
+	preparedStatement.policyVersion = conn.policyVersion
+	cQuery := C.CString(query)
+	defer C.free(unsafe.Pointer(cQuery))
+	preparedStatement.query = query
+	preparedStatement.changesSchema = changesSchema(query)
+	preparedStatement.parameters = queryParameters(query)
+	if err := conn.reserveHandle(HandlePreparedStatement); err != nil {
+		preparedStatement.isClosed = true
+		return preparedStatement, &Error{Op: OpPrepare, Query: query, Err: err}
+	}
+	conn.countCgoCall(cgoPrepare)
+	if err := conn.checkDatabase(); err != nil {
+		handles.cancel(HandlePreparedStatement)
+		preparedStatement.isClosed = true
+		return preparedStatement, &Error{Op: OpPrepare, Query: query, Err: err}
+	}
 	status := C.lbug_connection_prepare(&conn.cConnection, cQuery, &preparedStatement.cPreparedStatement)
+	if status == C.LbugSuccess {
+		preparedStatement.handleID = conn.trackChild(HandlePreparedStatement, preparedStatement)
+		conn.stats().openPreparedStatements.Add(1)
+	} else {
+		handles.cancel(HandlePreparedStatement)
+	}
 	if status != C.LbugSuccess || !C.lbug_prepared_statement_is_success(&preparedStatement.cPreparedStatement) {
 		cErrMsg := C.lbug_prepared_statement_get_error_message(&preparedStatement.cPreparedStatement)
 		defer C.lbug_destroy_string(cErrMsg)
-		return preparedStatement, fmt.Errorf("%s", C.GoString(cErrMsg))
+		return preparedStatement, conn.engineError(OpPrepare, query, C.GoString(cErrMsg))
 	}
 	return preparedStatement, nil
 }
+
+// QueryWithContext is like Query, but interrupts the query with Interrupt when
+// the context is done. It returns once the query has stopped, so that the
+// connection can be used again right away. If the query fails because it has
+// been interrupted, the returned *Error wraps the error of the context, so
+// errors.Is tells a deadline (context.DeadlineExceeded) from a cancellation
+// (context.Canceled). A query that completes before it is interrupted returns
+// its result even if the context is done by then.
+func (conn *Connection) QueryWithContext(ctx context.Context, query string) (*QueryResult, error) {
+	return runWithContext(ctx, conn, query, func() (*QueryResult, error) {
+		return conn.Query(query)
+	})
+}
+
+// ExecuteWithContext is like Execute, but interrupts the execution when the
+// context is done, as described for QueryWithContext. The parameters marked
+// on the context with WithSensitive are redacted like those marked with
+// MarkSensitiveParams.
+func (conn *Connection) ExecuteWithContext(ctx context.Context, preparedStatement *PreparedStatement, args map[string]any) (*QueryResult, error) {
+	return runWithContext(ctx, conn, preparedStatement.query, func() (*QueryResult, error) {
+		return conn.executeSensitive(preparedStatement, args, sensitiveParamsFromContext(ctx))
+	})
+}
+
+// runWithContext runs the query of the connection on the calling goroutine
+// and interrupts it when the context is done while it is running.
+func runWithContext[T any](ctx context.Context, conn *Connection, query string, run func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, &Error{Op: OpExecute, Query: query, Err: err}
+	}
+	// mutex makes sure that the query is only interrupted while it runs, and
+	// not the next query of the connection.
+	var mutex sync.Mutex
+	running, interrupted := true, false
+	stop := context.AfterFunc(ctx, func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if running {
+			conn.Interrupt()
+			interrupted = true
+		}
+	})
+	result, err := run()
+	mutex.Lock()
+	running = false
+	mutex.Unlock()
+	stop()
+	if err != nil && interrupted {
+		var lbugErr *Error
+		if errors.As(err, &lbugErr) {
+			return zero, &Error{Op: lbugErr.Op, Query: query, Message: lbugErr.Message, Err: ctx.Err()}
+		}
+		return zero, &Error{Op: OpExecute, Query: query, Message: err.Error(), Err: ctx.Err()}
+	}
+	return result, err
+}
+
+// beforePrepareForTesting, if set, is called by PrepareWithContext before the
+// statement is prepared, so that tests can abandon a preparation in progress.
+var beforePrepareForTesting func()
+
+// PrepareWithContext is like Prepare, but returns the error of the context as
+// soon as the context is done, without waiting for the preparation to
+// complete. The engine cannot interrupt the compilation of a statement, so an
+// abandoned preparation keeps running in the background and the statement is
+// closed as soon as it completes. Other calls on the connection may block
+// until then. On a single-threaded connection, the preparation is not
+// abandoned: PrepareWithContext returns once it completes. Otherwise, it
+// runs on a goroutine counted toward the limit set with
+// SetMaxBackgroundTasks.
+func (conn *Connection) PrepareWithContext(ctx context.Context, query string) (*PreparedStatement, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &Error{Op: OpPrepare, Query: query, Err: err}
+	}
+	if conn.closeMutex.singleThreaded {
+		return conn.Prepare(query)
+	}
+	type prepared struct {
+		statement *PreparedStatement
+		err       error
+	}
+	done := make(chan prepared, 1)
+	err := background.spawn("PrepareWithContext", conn, func() {
+		if beforePrepareForTesting != nil {
+			beforePrepareForTesting()
+		}
+		statement, err := conn.Prepare(query)
+		done <- prepared{statement, err}
+	})
+	if err != nil {
+		return nil, &Error{Op: OpPrepare, Query: query, Err: err}
+	}
+	select {
+	case result := <-done:
+		return result.statement, result.err
+	case <-ctx.Done():
+		background.spawnUnbounded("PrepareWithContext cleanup", conn, func() {
+			result := <-done
+			result.statement.Close()
+		})
+		return nil, &Error{Op: OpPrepare, Query: query, Err: ctx.Err()}
+	}
+}
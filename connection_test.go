@@ -1,6 +1,8 @@
 package lbug
 
 import (
+	"context"
+	"errors"
 	"runtime"
 	"sync"
 	"testing"
@@ -52,11 +54,12 @@ func TestInterrupt(t *testing.T) {
 	}
 	db, _ := SetupTestDatabase(t)
 	conn, _ := OpenConnection(db)
+	var result *QueryResult
 	var err error
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
-		_, err = conn.Query(largeQuery)
+		result, err = conn.Query(largeQuery)
 		wg.Done()
 	}()
 	time.Sleep(100 * time.Millisecond)
@@ -64,6 +67,8 @@ func TestInterrupt(t *testing.T) {
 	wg.Wait()
 	if err != nil {
 		assert.Equal(t, "Interrupted.", err.Error())
+	} else {
+		result.Close()
 	}
 	conn.Close()
 }
@@ -72,9 +77,11 @@ func TestSetTimeout(t *testing.T) {
 	db, _ := SetupTestDatabase(t)
 	conn, _ := OpenConnection(db)
 	conn.SetTimeout(100)
-	_, err := conn.Query(largeQuery)
+	result, err := conn.Query(largeQuery)
 	if err != nil {
 		assert.Equal(t, "Interrupted.", err.Error())
+	} else {
+		result.Close()
 	}
 	conn.Close()
 }
@@ -91,6 +98,7 @@ func TestQuery(t *testing.T) {
 	assert.True(t, result.HasNext())
 	flatTuple, err := result.Next()
 	assert.Nil(t, err)
+	defer flatTuple.Close()
 	assert.NotNil(t, flatTuple)
 	slice, err := flatTuple.GetAsSlice()
 	assert.Nil(t, err)
@@ -135,6 +143,61 @@ func TestPrepareError(t *testing.T) {
 	conn.Close()
 }
 
+func TestPrepareWithContext(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, _ := SetupTestDatabase(t)
+	conn, _ := OpenConnection(db)
+	defer conn.Close()
+	stmt, err := conn.PrepareWithContext(context.Background(), "RETURN $a;")
+	assert.Nil(t, err)
+	assert.NotNil(t, stmt)
+	stmt.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stmt, err = conn.PrepareWithContext(ctx, "RETURN $a;")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, stmt)
+}
+
+func TestPrepareWithContextAbandoned(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, _ := SetupTestDatabase(t)
+	conn, _ := OpenConnection(db)
+	defer conn.Close()
+	openStatements := db.Stats().OpenPreparedStatements
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	beforePrepareForTesting = func() {
+		close(started)
+		<-release
+	}
+	defer func() { beforePrepareForTesting = nil }()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	stmt, err := conn.PrepareWithContext(ctx, "RETURN $a;")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, stmt)
+	<-started
+	tasks := BackgroundTasks()
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, "PrepareWithContext", tasks[0].Name)
+		assert.Equal(t, conn.handleID, tasks[0].ConnectionID)
+	}
+	close(release)
+
+	// The statement prepared in the background is closed once it completes.
+	deadline := time.Now().Add(10 * time.Second)
+	for db.Stats().OpenPreparedStatements != openStatements && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, openStatements, db.Stats().OpenPreparedStatements)
+	stmt, err = conn.Prepare("RETURN $a;")
+	assert.Nil(t, err)
+	stmt.Close()
+}
+
 func TestExecute(t *testing.T) {
 	query := "RETURN $a;"
 	db, _ := SetupTestDatabase(t)
@@ -149,6 +212,7 @@ func TestExecute(t *testing.T) {
 	assert.True(t, result.HasNext())
 	flatTuple, err := result.Next()
 	assert.Nil(t, err)
+	defer flatTuple.Close()
 	assert.NotNil(t, flatTuple)
 	slice, err := flatTuple.GetAsSlice()
 	assert.Nil(t, err)
@@ -170,3 +234,67 @@ func TestExecuteError(t *testing.T) {
 	stmt.Close()
 	conn.Close()
 }
+
+func TestQueryWithContext(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	conn, _ := OpenConnection(db)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err := conn.QueryWithContext(ctx, largeQuery)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	var lbugErr *Error
+	assert.True(t, errors.As(err, &lbugErr))
+	assert.Equal(t, "Interrupted.", lbugErr.Message)
+	assert.Less(t, time.Since(started), 10*time.Second)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	stmt, err := conn.Prepare(largeQuery)
+	assert.Nil(t, err)
+	defer stmt.Close()
+	_, err = conn.ExecuteWithContext(ctx, stmt, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// A done context is not used.
+	_, err = conn.QueryWithContext(ctx, "RETURN 1;")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestQueryWithContextCancelledAfterCompletion(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	conn, _ := OpenConnection(db)
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	result, err := conn.QueryWithContext(ctx, "RETURN 1;")
+	assert.Nil(t, err)
+	cancel()
+	// The result is complete and the connection is not interrupted.
+	assert.True(t, result.HasNext())
+	result.Close()
+	result, err = conn.Query("RETURN 2;")
+	assert.Nil(t, err)
+	result.Close()
+}
+
+func TestSetQueryTimeout(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	conn, _ := OpenConnection(db)
+	defer conn.Close()
+	conn.SetQueryTimeout(100 * time.Microsecond)
+	result, err := conn.Query(largeQuery)
+	if err != nil {
+		assert.Equal(t, "Interrupted.", err.Error())
+	} else {
+		result.Close()
+	}
+	conn.SetQueryTimeout(0)
+	result, err = conn.Query("RETURN 1;")
+	assert.Nil(t, err)
+	result.Close()
+}
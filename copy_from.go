@@ -0,0 +1,234 @@
+package lbug
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RetryPolicy configures the retries of an operation that fails with a
+// transient error.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// Zero or one disables retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. If it is zero,
+	// 100ms is used.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts. If it is zero, the
+	// delay is not capped.
+	MaxBackoff time.Duration
+	// Multiplier is the factor the delay grows by after every retry. If it is
+	// smaller than 1, 2 is used.
+	Multiplier float64
+	// OnRetry, if set, is called before every retry with the number of the
+	// failed attempt, its error and the delay before the next attempt.
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// CopyOptions configures CopyFrom.
+type CopyOptions struct {
+	// Options are the options of the COPY statement, such as "HEADER" or
+	// "DELIM". Their values may be booleans, integers or strings.
+	Options map[string]any
+	// Retry configures the retries of a COPY that fails with an IO error.
+	Retry RetryPolicy
+	// CollectDiagnostics is the maximum number of diagnostics retained in a
+	// *LoadError or returned by CopyFromWithWarnings. If it is zero, 100 are
+	// retained.
+	CollectDiagnostics int
+	// Idempotency, if it has a batch ID, runs the COPY in a transaction that
+	// records the ID, and a COPY of a batch that has already been applied
+	// does nothing and returns the diagnostics of the first one. Every
+	// attempt of Retry is a transaction.
+	Idempotency IdempotencyOptions
+}
+
+// CopyRetryError is returned by CopyFrom when the COPY failed after retrying.
+type CopyRetryError struct {
+	// Attempts is the number of attempts made.
+	Attempts int
+	// Err is the error of the last attempt.
+	Err error
+}
+
+func (err *CopyRetryError) Error() string {
+	return fmt.Sprintf("copy failed after %d attempts: %v", err.Attempts, err.Err)
+}
+
+func (err *CopyRetryError) Unwrap() error {
+	return err.Err
+}
+
+// retryableCopyErrors are fragments of the error messages of the engine for IO
+// failures, which are often transient on network filesystems.
+var retryableCopyErrors = []string{
+	"IO exception",
+	"Input/output error",
+	"Stale file handle",
+	"Resource temporarily unavailable",
+	"Connection timed out",
+}
+
+// isRetryableCopyError returns true if the error of a COPY is an IO error that
+// may succeed on retry. Binder, parser, conversion and other errors are never
+// retried.
+func isRetryableCopyError(err error) bool {
+	message := err.Error()
+	for _, fragment := range retryableCopyErrors {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// CopyFrom bulk loads the file at path into the table with a COPY FROM
+// statement. Since a COPY is transactional, a COPY that fails with an IO error
+// is retried as a whole according to opts.Retry; other errors are returned
+// immediately. If the COPY still fails after the last attempt, the error is a
+// *CopyRetryError wrapping the error of the last attempt. If the COPY failed
+// because of the content of the file, e.g. a value that cannot be converted,
+// the error is a *LoadError describing the problem. The table name is
+// resolved as by Connection.ResolveTableName.
+func CopyFrom(conn *Connection, table string, path string, opts CopyOptions) error {
+	_, err := copyFrom(conn, table, path, opts, false)
+	return err
+}
+
+// CopyFromWithWarnings runs CopyFrom and returns the problems the loader
+// skipped, which it does when the IGNORE_ERRORS option is set, e.g. to write
+// the lines that failed to be parsed to a dead-letter file. At most
+// CopyOptions.CollectDiagnostics problems are returned. See
+// QueryResult.Warnings.
+func CopyFromWithWarnings(conn *Connection, table string, path string, opts CopyOptions) ([]LoadDiagnostic, error) {
+	return copyFrom(conn, table, path, opts, true)
+}
+
+func copyFrom(conn *Connection, table string, path string, opts CopyOptions, withWarnings bool) ([]LoadDiagnostic, error) {
+	if opts.CollectDiagnostics < 0 {
+		return nil, fmt.Errorf("invalid number of diagnostics %d: must not be negative", opts.CollectDiagnostics)
+	}
+	if opts.CollectDiagnostics == 0 {
+		opts.CollectDiagnostics = defaultCollectDiagnostics
+	}
+	name, err := conn.ResolveTableName(table)
+	if err != nil {
+		return nil, err
+	}
+	statement, err := copyFromStatement(name, path, opts.Options)
+	if err != nil {
+		return nil, err
+	}
+	var hasWarnings bool
+	copyOnce := func() error {
+		result, err := conn.Query(statement)
+		if err != nil {
+			return err
+		}
+		hasWarnings = result.hasLoadWarnings()
+		result.Close()
+		return nil
+	}
+	run := copyOnce
+	var warnings []LoadDiagnostic
+	idempotent := opts.Idempotency.BatchID != ""
+	if idempotent {
+		run = func() error {
+			_, err := conn.applyBatch(opts.Idempotency, &warnings, func() error {
+				warnings = nil
+				if err := copyOnce(); err != nil || !hasWarnings {
+					return err
+				}
+				var err error
+				warnings, err = readLoadWarnings(conn, opts.CollectDiagnostics)
+				return err
+			})
+			return err
+		}
+	}
+	err = retryCopy(opts.Retry, run)
+	if err != nil {
+		err = loadError(err)
+		var loadErr *LoadError
+		if errors.As(err, &loadErr) && len(loadErr.Diagnostics) > opts.CollectDiagnostics {
+			loadErr.Diagnostics = loadErr.Diagnostics[:opts.CollectDiagnostics]
+		}
+		return nil, err
+	}
+	if !withWarnings {
+		return nil, nil
+	}
+	if idempotent || !hasWarnings {
+		return warnings, nil
+	}
+	return readLoadWarnings(conn, opts.CollectDiagnostics)
+}
+
+// copyFromStatement returns the COPY FROM statement loading the file at path
+// into the table with the given options.
+func copyFromStatement(table string, path string, options map[string]any) (string, error) {
+	statement := fmt.Sprintf("COPY %s FROM %s", QuoteIdentifier(table), quoteStringLiteral(path))
+	if len(options) > 0 {
+		names := make([]string, 0, len(options))
+		for name := range options {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		rendered := make([]string, len(names))
+		for i, name := range names {
+			if !isOptionName(name) {
+				return "", fmt.Errorf("invalid COPY option name %q", name)
+			}
+			value, err := optionLiteral(options[name])
+			if err != nil {
+				return "", fmt.Errorf("%w for COPY option %s", err, name)
+			}
+			rendered[i] = name + "=" + value
+		}
+		statement += " (" + strings.Join(rendered, ", ") + ")"
+	}
+	return statement + ";", nil
+}
+
+// retryCopy runs the COPY until it succeeds, fails with an error that is not
+// retryable, or the attempts of the policy are exhausted.
+func retryCopy(policy RetryPolicy, run func() error) error {
+	maxAttempts := max(policy.MaxAttempts, 1)
+	delay := policy.InitialBackoff
+	if delay <= 0 {
+		delay = 100 * time.Millisecond
+	}
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	for attempt := 1; ; attempt++ {
+		err := run()
+		if err == nil {
+			return nil
+		}
+		if !isRetryableCopyError(err) {
+			if attempt == 1 {
+				return err
+			}
+			return &CopyRetryError{Attempts: attempt, Err: err}
+		}
+		if attempt >= maxAttempts {
+			if maxAttempts == 1 {
+				return err
+			}
+			return &CopyRetryError{Attempts: attempt, Err: err}
+		}
+		if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+			delay = policy.MaxBackoff
+		}
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, delay)
+		}
+		time.Sleep(delay)
+		delay = time.Duration(float64(delay) * multiplier)
+	}
+}
@@ -0,0 +1,114 @@
+package lbug
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryCopy(t *testing.T) {
+	var delays []time.Duration
+	policy := RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     3 * time.Millisecond,
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			delays = append(delays, delay)
+		},
+	}
+	ioErr := errors.New("IO exception: Cannot read from file")
+	attempts := 0
+	err := retryCopy(policy, func() error {
+		attempts++
+		if attempts < 3 {
+			return ioErr
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond}, delays)
+
+	delays = nil
+	attempts = 0
+	err = retryCopy(policy, func() error {
+		attempts++
+		return ioErr
+	})
+	var retryErr *CopyRetryError
+	assert.True(t, errors.As(err, &retryErr))
+	assert.Equal(t, 4, retryErr.Attempts)
+	assert.ErrorIs(t, err, ioErr)
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}, delays)
+}
+
+func TestRetryCopyNonRetryable(t *testing.T) {
+	parseErr := errors.New("Copy exception: Error in file data.csv on line 2: Conversion exception")
+	attempts := 0
+	err := retryCopy(RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}, func() error {
+		attempts++
+		return parseErr
+	})
+	assert.Equal(t, parseErr, err)
+	assert.Equal(t, 1, attempts)
+
+	// Without a retry policy, an IO error is returned as is.
+	ioErr := errors.New("IO exception: Stale file handle")
+	err = retryCopy(RetryPolicy{}, func() error { return ioErr })
+	assert.Equal(t, ioErr, err)
+}
+
+func TestCopyFromStatement(t *testing.T) {
+	statement, err := copyFromStatement("person", "/data/it's.csv", map[string]any{"HEADER": true, "DELIM": "|", "SKIP": 2})
+	assert.Nil(t, err)
+	assert.Equal(t, "COPY `person` FROM '/data/it\\'s.csv' (DELIM='|', HEADER=true, SKIP=2);", statement)
+	_, err = copyFromStatement("person", "x.csv", map[string]any{"HEADER": []int{1}})
+	assert.NotNil(t, err)
+	for _, name := range []string{"", "1SKIP", "HEADER=true); MATCH (n) DELETE n; //", "DE LIM"} {
+		_, err = copyFromStatement("person", "x.csv", map[string]any{name: true})
+		assert.ErrorContains(t, err, "invalid COPY option name", name)
+	}
+	_, err = copyFromStatement("person", "x.csv", map[string]any{"_ignore_errors2": true})
+	assert.Nil(t, err)
+}
+
+func TestCopyFrom(t *testing.T) {
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	result, err := conn.Query("CREATE NODE TABLE item(id INT64, name STRING, PRIMARY KEY(id));")
+	assert.Nil(t, err)
+	result.Close()
+
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.csv")
+	assert.Nil(t, os.WriteFile(valid, []byte("id,name\n1,a\n2,b\n"), 0o644))
+	invalid := filepath.Join(dir, "invalid.csv")
+	assert.Nil(t, os.WriteFile(invalid, []byte("id,name\nnot a number,a\n"), 0o644))
+
+	retries := 0
+	opts := CopyOptions{
+		Options: map[string]any{"HEADER": true},
+		Retry: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			OnRetry:        func(int, error, time.Duration) { retries++ },
+		},
+	}
+	assert.Nil(t, CopyFrom(conn, "Item", valid, opts))
+	result, err = conn.Query("MATCH (i:item) RETURN i.id;")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), result.GetNumberOfRows())
+	result.Close()
+
+	err = CopyFrom(conn, "item", invalid, opts)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, retries)
+}
@@ -0,0 +1,209 @@
+package lbug
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// defaultCopyRowsBatchSize is the number of rows of a batch of CopyFromRows when
+// CopyRowsOptions.BatchSize is zero.
+const defaultCopyRowsBatchSize = 100000
+
+// CopyRowsOptions configures CopyFromRows.
+type CopyRowsOptions struct {
+	// Copy configures the COPY of every batch, as for CopyFrom. If it has an
+	// idempotency batch ID, the ID of a batch is the ID followed by "/" and
+	// the number of the batch, so that running CopyFromRows again with the
+	// same rows skips the batches already loaded.
+	Copy CopyOptions
+	// BatchSize is the maximum number of rows loaded by a COPY. If it is
+	// zero, 100000 rows are loaded at a time.
+	BatchSize int
+	// Progress, if set, is called after every batch is loaded.
+	Progress func(CopyProgress)
+}
+
+// CopyProgress reports the rows loaded by CopyFromRows.
+type CopyProgress struct {
+	// Batches is the number of batches loaded.
+	Batches int
+	// Rows is the number of rows loaded.
+	Rows uint64
+}
+
+// CopyBatchError is returned by CopyFromRows when a batch fails to be read or
+// loaded. The batches before it are loaded.
+type CopyBatchError struct {
+	// Batch is the number of the batch, starting at 0.
+	Batch int
+	// FirstRow is the index of the first row of the batch.
+	FirstRow uint64
+	// Err is the error of the source, or of CopyFrom, e.g. a *LoadError.
+	Err error
+}
+
+func (err *CopyBatchError) Error() string {
+	return fmt.Sprintf("batch %d, from row %d: %v", err.Batch, err.FirstRow, err.Err)
+}
+
+func (err *CopyBatchError) Unwrap() error {
+	return err.Err
+}
+
+// CopyFromRows bulk loads the rows returned by next into the table. Every
+// batch of opts.BatchSize rows is spooled to a temporary CSV file and loaded
+// by its own COPY FROM statement, as by CopyFrom, so that the rows are
+// streamed through the native loader without holding them all in memory.
+//
+// next is called until it returns io.EOF. Each row holds the values of the
+// columns of the table, in the order COPY FROM reads them from a CSV file, and
+// every row must have as many values as the first one. The values must be
+// nil, booleans, integers, floats, strings without line breaks or time.Time
+// values, as for RegisterRowSource. nil values and empty strings are loaded
+// as NULL.
+//
+// Since every batch is a transaction, the batches loaded before an error stay
+// loaded. The error is a *CopyBatchError wrapping the error of next or of the
+// COPY, and the progress reports the batches loaded.
+func CopyFromRows(conn *Connection, table string, next func() ([]any, error), opts CopyRowsOptions) (CopyProgress, error) {
+	var progress CopyProgress
+	if opts.BatchSize < 0 {
+		return progress, fmt.Errorf("invalid batch size %d: must not be negative", opts.BatchSize)
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultCopyRowsBatchSize
+	}
+	batchID := opts.Copy.Idempotency.BatchID
+	fail := func(err error) (CopyProgress, error) {
+		return progress, &CopyBatchError{Batch: progress.Batches, FirstRow: progress.Rows, Err: err}
+	}
+	width := -1
+	for done := false; !done; {
+		path, rows, err := spoolCopyBatch(next, opts.BatchSize, progress.Rows, &width)
+		if err != nil {
+			if path != "" {
+				os.Remove(path)
+			}
+			return fail(err)
+		}
+		done = rows < opts.BatchSize
+		if rows == 0 {
+			os.Remove(path)
+			break
+		}
+		copyOpts := opts.Copy
+		if batchID != "" {
+			copyOpts.Idempotency.BatchID = batchID + "/" + strconv.Itoa(progress.Batches)
+		}
+		err = CopyFrom(conn, table, path, copyOpts)
+		os.Remove(path)
+		if err != nil {
+			return fail(err)
+		}
+		progress.Batches++
+		progress.Rows += uint64(rows)
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+	return progress, nil
+}
+
+// spoolCopyBatch writes at most size rows returned by next to a temporary CSV
+// file, and returns its path, which is set if the file was created even if an
+// error occurred, and the number of rows written, which is smaller than size
+// once next has returned io.EOF. width is the number of values of the rows,
+// or -1 before the first row.
+func spoolCopyBatch(next func() ([]any, error), size int, firstRow uint64, width *int) (string, int, error) {
+	file, err := os.CreateTemp("", "lbug-copy-*.csv")
+	if err != nil {
+		return "", 0, err
+	}
+	writer := csv.NewWriter(file)
+	rows, err := writeCopyBatch(writer, next, size, firstRow, width)
+	if err == nil {
+		writer.Flush()
+		err = writer.Error()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return file.Name(), rows, err
+}
+
+// writeCopyBatch writes at most size rows returned by next with the writer.
+func writeCopyBatch(writer *csv.Writer, next func() ([]any, error), size int, firstRow uint64, width *int) (int, error) {
+	var record []string
+	for rows := 0; rows < size; rows++ {
+		rowIndex := firstRow + uint64(rows)
+		row, err := next()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+		if *width < 0 {
+			if len(row) == 0 {
+				return rows, fmt.Errorf("row %d has no values", rowIndex)
+			}
+			*width = len(row)
+		}
+		if len(row) != *width {
+			return rows, fmt.Errorf("row %d has %d values, expected %d", rowIndex, len(row), *width)
+		}
+		record = record[:0]
+		for i, value := range row {
+			field, err := rowSourceField(value)
+			if err != nil {
+				return rows, fmt.Errorf("row %d, column %d: %w", rowIndex, i, err)
+			}
+			record = append(record, field)
+		}
+		if err := writer.Write(record); err != nil {
+			return rows, err
+		}
+	}
+	return size, nil
+}
+
+// RowsFromChannel returns a function that returns the rows received from the
+// channel, and io.EOF once it is closed, to be passed to CopyFromRows or
+// RegisterRowSource.
+func RowsFromChannel(rows <-chan []any) func() ([]any, error) {
+	return func() ([]any, error) {
+		row, ok := <-rows
+		if !ok {
+			return nil, io.EOF
+		}
+		return row, nil
+	}
+}
+
+// CopyFromReader bulk loads the data read from the reader into the table, as
+// CopyFrom does for a file. The engine only loads files, so the data is first
+// written to a temporary file, which is removed before CopyFromReader returns.
+// format is the extension of the files of the format of the data, such as
+// "csv" or "parquet", from which the engine detects it.
+func CopyFromReader(conn *Connection, table string, reader io.Reader, format string, opts CopyOptions) error {
+	if format == "" {
+		return errors.New("the format of the data to copy must be set")
+	}
+	file, err := os.CreateTemp("", "lbug-copy-*."+format)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the data to copy: %w", err)
+	}
+	return CopyFrom(conn, table, file.Name(), opts)
+}
@@ -0,0 +1,112 @@
+package lbug
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpoolCopyBatch(t *testing.T) {
+	next := sliceSource([][]any{{int64(1), "a"}, {int64(2), nil}, {int64(3), "c"}})
+	width := -1
+	path, rows, err := spoolCopyBatch(next, 2, 0, &width)
+	defer os.Remove(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, rows)
+	assert.Equal(t, 2, width)
+	content, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "1,a\n2,\n", string(content))
+
+	path, rows, err = spoolCopyBatch(next, 2, 2, &width)
+	defer os.Remove(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, rows)
+
+	path, _, err = spoolCopyBatch(sliceSource([][]any{{int64(1)}, {int64(2), "b"}}), 10, 5, &width)
+	defer os.Remove(path)
+	assert.ErrorContains(t, err, "row 5 has 1 values, expected 2")
+	width = -1
+	path, _, err = spoolCopyBatch(sliceSource([][]any{{"a\nb"}}), 10, 0, &width)
+	defer os.Remove(path)
+	assert.ErrorContains(t, err, "row 0, column 0: strings with line breaks cannot be spooled")
+}
+
+func TestRowsFromChannel(t *testing.T) {
+	rows := make(chan []any, 2)
+	rows <- []any{int64(1)}
+	close(rows)
+	next := RowsFromChannel(rows)
+	row, err := next()
+	assert.Nil(t, err)
+	assert.Equal(t, []any{int64(1)}, row)
+	_, err = next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestCopyFromRows(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE item(id INT64, name STRING, PRIMARY KEY(id));")
+
+	rows := make(chan []any)
+	go func() {
+		defer close(rows)
+		for i := range 5 {
+			rows <- []any{int64(i), "item"}
+		}
+	}()
+	var reported []CopyProgress
+	progress, err := CopyFromRows(conn, "item", RowsFromChannel(rows), CopyRowsOptions{
+		BatchSize: 2,
+		Progress:  func(progress CopyProgress) { reported = append(reported, progress) },
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, CopyProgress{Batches: 3, Rows: 5}, progress)
+	assert.Equal(t, []CopyProgress{{1, 2}, {2, 4}, {3, 5}}, reported)
+	count, err := queryCount(conn, "MATCH (i:item) RETURN count(i);")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(5), count)
+
+	// The batches before the failing one stay loaded.
+	failure := errors.New("source failed")
+	sent := 0
+	progress, err = CopyFromRows(conn, "item", func() ([]any, error) {
+		if sent == 3 {
+			return nil, failure
+		}
+		sent++
+		return []any{int64(10 + sent), "item"}, nil
+	}, CopyRowsOptions{BatchSize: 2})
+	var batchErr *CopyBatchError
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.Equal(t, 1, batchErr.Batch)
+		assert.Equal(t, uint64(2), batchErr.FirstRow)
+	}
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, CopyProgress{Batches: 1, Rows: 2}, progress)
+
+	// A duplicate primary key fails the COPY of its batch.
+	_, err = CopyFromRows(conn, "item", sliceSource([][]any{{int64(20), "new"}, {int64(0), "duplicate"}}), CopyRowsOptions{BatchSize: 1})
+	if assert.ErrorAs(t, err, &batchErr) {
+		assert.Equal(t, 1, batchErr.Batch)
+	}
+	count, err = queryCount(conn, "MATCH (i:item) RETURN count(i);")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(8), count)
+}
+
+func TestCopyFromReader(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE item(id INT64, name STRING, PRIMARY KEY(id));")
+
+	opts := CopyOptions{Options: map[string]any{"HEADER": true}}
+	assert.Nil(t, CopyFromReader(conn, "item", strings.NewReader("id,name\n1,a\n2,b\n"), "csv", opts))
+	count, err := queryCount(conn, "MATCH (i:item) RETURN count(i);")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), count)
+	assert.ErrorContains(t, CopyFromReader(conn, "item", strings.NewReader(""), "", opts), "format of the data to copy must be set")
+}
@@ -0,0 +1,496 @@
+package lbug
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// defaultCopyBatchSize is the number of rows inserted per statement by
+// CopyTables when CopyTablesOptions.BatchSize is not set.
+const defaultCopyBatchSize = 1000
+
+// CopyTablesOptions configures CopyTables.
+type CopyTablesOptions struct {
+	// DropExisting drops the tables that already exist on the destination
+	// before recreating them. If it is false, CopyTables fails when one of the
+	// tables already exists on the destination.
+	DropExisting bool
+	// BatchSize is the maximum number of rows inserted per statement. If it is
+	// zero, 1000 rows are inserted per statement. With Adaptive, it is the
+	// size of the first batch.
+	BatchSize int
+	// Adaptive, if set, adapts the batch size to the latency of the batches,
+	// see AdaptiveBatching.
+	Adaptive *AdaptiveBatching
+	// Report, if set, receives the batches inserted, with the sizes chosen
+	// with Adaptive.
+	Report *IngestionReport
+	// Progress, if set, is called after every inserted batch with the name of
+	// the table being copied and the number of rows copied so far.
+	Progress func(table string, rowsCopied uint64)
+}
+
+// CopyTables copies the given node and relationship tables from the database
+// of src to the database of dst and returns the number of rows copied per
+// table. If tables is empty, all node and relationship tables are copied.
+// Table names are resolved as by Connection.ResolveTableName.
+//
+// The schema of every table is read from the source and the table is created
+// on the destination. Rows are read from the source as a stream and inserted
+// on the destination in batches with UNWIND, so the tables do not have to fit
+// in memory. Node tables are copied before relationship tables, and
+// relationships are reconnected to the copied nodes by their primary keys, so
+// the node tables a relationship table connects must either be copied too or
+// already exist on the destination. Tables with SERIAL columns are not
+// supported because their values cannot be inserted explicitly.
+//
+// The source and destination must be connections to different databases,
+// also when they are different Database handles of the same path. Every
+// batch is inserted in its own transaction, so if CopyTables fails, the
+// batches inserted before the error are kept, and the returned counts hold
+// the rows copied so far.
+func CopyTables(src *Connection, dst *Connection, tables []string, opts CopyTablesOptions) (map[string]uint64, error) {
+	if src == dst || src.database.sameDatabase(dst.database) {
+		return nil, fmt.Errorf("source and destination must be connections to different databases")
+	}
+	if opts.BatchSize < 0 {
+		return nil, fmt.Errorf("invalid batch size %d: must not be negative", opts.BatchSize)
+	}
+	if err := opts.Adaptive.validate(); err != nil {
+		return nil, err
+	}
+	if opts.BatchSize == 0 && opts.Adaptive == nil {
+		opts.BatchSize = defaultCopyBatchSize
+	}
+	srcTables, err := src.catalogTables()
+	if err != nil {
+		return nil, err
+	}
+	dstTables, err := dst.catalogTables()
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		for name := range srcTables {
+			tables = append(tables, name)
+		}
+		sort.Strings(tables)
+	}
+
+	schemas := make(map[string]*tableSchema)
+	var nodeTables, relTables []*tableSchema
+	for _, name := range tables {
+		name, err := resolveTableName(srcTables, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy tables from the source: %w", err)
+		}
+		if _, ok := schemas[name]; ok {
+			continue
+		}
+		isRel := srcTables[name]
+		schema, err := describeTable(src, name, isRel)
+		if err != nil {
+			return nil, err
+		}
+		for _, column := range schema.columns {
+			if strings.EqualFold(column.dataType, "SERIAL") {
+				return nil, fmt.Errorf("table %s has the SERIAL column %s, which cannot be copied", name, column.name)
+			}
+		}
+		schemas[name] = schema
+		if isRel {
+			relTables = append(relTables, schema)
+		} else {
+			nodeTables = append(nodeTables, schema)
+		}
+	}
+	for _, relTable := range relTables {
+		for _, connection := range relTable.connections {
+			for _, endpoint := range []string{connection.from, connection.to} {
+				if schema, ok := schemas[endpoint]; ok && !schema.isRel {
+					continue
+				}
+				if existing, err := resolveTableName(dstTables, endpoint); err != nil || dstTables[existing] {
+					return nil, fmt.Errorf("table %s connects node table %s, which is neither copied nor present on the destination", relTable.name, endpoint)
+				}
+			}
+		}
+	}
+
+	// Relationship tables must be dropped before the node tables they connect.
+	ordered := append(append([]*tableSchema{}, nodeTables...), relTables...)
+	for i := len(ordered) - 1; i >= 0; i-- {
+		name, err := resolveTableName(dstTables, ordered[i].name)
+		if err != nil {
+			continue
+		}
+		if !opts.DropExisting {
+			return nil, fmt.Errorf("table %s already exists on the destination", name)
+		}
+		if err := runStatement(dst, "DROP TABLE "+QuoteIdentifier(name)+";"); err != nil {
+			return nil, fmt.Errorf("failed to drop table %s on the destination: %w", name, err)
+		}
+	}
+	for _, schema := range ordered {
+		if err := runStatement(dst, schema.createTableStatement()); err != nil {
+			return nil, fmt.Errorf("failed to create table %s on the destination: %w", schema.name, err)
+		}
+	}
+
+	counts := make(map[string]uint64, len(ordered))
+	for _, schema := range nodeTables {
+		copied := uint64(0)
+		err := copyRows(src, dst, nodeCopyJob(schema), &opts, &copied)
+		counts[schema.name] = copied
+		if err != nil {
+			return counts, fmt.Errorf("failed to copy table %s after %d rows: %w", schema.name, copied, err)
+		}
+	}
+	for _, schema := range relTables {
+		copied := uint64(0)
+		for _, connection := range schema.connections {
+			job, err := relCopyJob(src, schema, connection, schemas)
+			if err == nil {
+				err = copyRows(src, dst, job, &opts, &copied)
+			}
+			counts[schema.name] = copied
+			if err != nil {
+				return counts, fmt.Errorf("failed to copy table %s after %d rows: %w", schema.name, copied, err)
+			}
+		}
+	}
+	return counts, nil
+}
+
+// runStatement runs a query and discards its result.
+func runStatement(conn *Connection, query string) error {
+	result, err := conn.Query(query)
+	if err != nil {
+		return err
+	}
+	result.Close()
+	return nil
+}
+
+// copyJob describes how the rows of a table are read from the source and
+// inserted on the destination. The values of every row read by readQuery are
+// passed to the insert query as the fields c0, c1, ... of the structs in the
+// $rows list. Since all elements of a list must have the same type, rows are
+// batched by the set of non-null values they have and insertQuery is called
+// with that set to build the matching insert query.
+type copyJob struct {
+	table       string
+	readQuery   string
+	columns     []tableColumn
+	insertQuery func(present []bool) string
+	// countsRows is true if the insert query returns the number of inserted
+	// rows, which may be lower than the size of the batch.
+	countsRows bool
+}
+
+// nodeCopyJob returns the job copying the rows of a node table.
+func nodeCopyJob(schema *tableSchema) *copyJob {
+	projections := make([]string, len(schema.columns))
+	for i, column := range schema.columns {
+		projections[i] = "n." + QuoteIdentifier(column.name)
+	}
+	return &copyJob{
+		table:     schema.name,
+		readQuery: fmt.Sprintf("MATCH (n:%s) RETURN %s;", QuoteIdentifier(schema.name), strings.Join(projections, ", ")),
+		columns:   schema.columns,
+		insertQuery: func(present []bool) string {
+			return fmt.Sprintf("UNWIND $rows AS row CREATE (n:%s%s);",
+				QuoteIdentifier(schema.name), copyProperties(schema.columns, present, 0))
+		},
+	}
+}
+
+// relCopyJob returns the job copying the relationships of a relationship table
+// between the node tables of one of its connections. The relationships are
+// attached to the nodes of the destination with the same primary keys as
+// their endpoints on the source.
+func relCopyJob(src *Connection, schema *tableSchema, connection relConnection, schemas map[string]*tableSchema) (*copyJob, error) {
+	fromKey, err := endpointPrimaryKey(src, connection.from, connection.fromPrimaryKey, schemas)
+	if err != nil {
+		return nil, err
+	}
+	toKey, err := endpointPrimaryKey(src, connection.to, connection.toPrimaryKey, schemas)
+	if err != nil {
+		return nil, err
+	}
+	columns := append([]tableColumn{fromKey, toKey}, schema.columns...)
+	projections := []string{"a." + QuoteIdentifier(fromKey.name), "b." + QuoteIdentifier(toKey.name)}
+	for _, column := range schema.columns {
+		projections = append(projections, "r."+QuoteIdentifier(column.name))
+	}
+	return &copyJob{
+		table: schema.name,
+		readQuery: fmt.Sprintf("MATCH (a:%s)-[r:%s]->(b:%s) RETURN %s;",
+			QuoteIdentifier(connection.from), QuoteIdentifier(schema.name), QuoteIdentifier(connection.to),
+			strings.Join(projections, ", ")),
+		columns: columns,
+		insertQuery: func(present []bool) string {
+			return fmt.Sprintf("UNWIND $rows AS row MATCH (a:%s), (b:%s) WHERE a.%s = %s AND b.%s = %s CREATE (a)-[r:%s%s]->(b) RETURN count(r);",
+				QuoteIdentifier(connection.from), QuoteIdentifier(connection.to),
+				QuoteIdentifier(fromKey.name), copyField(0, fromKey.dataType),
+				QuoteIdentifier(toKey.name), copyField(1, toKey.dataType),
+				QuoteIdentifier(schema.name), copyProperties(schema.columns, present[2:], 2))
+		},
+		countsRows: true,
+	}, nil
+}
+
+// endpointPrimaryKey returns the primary key column of the node table at one
+// end of a relationship table.
+func endpointPrimaryKey(src *Connection, table string, name string, schemas map[string]*tableSchema) (tableColumn, error) {
+	schema, ok := schemas[table]
+	if !ok {
+		var err error
+		schema, err = describeTable(src, table, false)
+		if err != nil {
+			return tableColumn{}, err
+		}
+		schemas[table] = schema
+	}
+	if name != "" {
+		if column, ok := schema.column(name); ok {
+			return column, nil
+		}
+	}
+	if column, ok := schema.primaryKey(); ok {
+		return column, nil
+	}
+	return tableColumn{}, fmt.Errorf("node table %s has no primary key", table)
+}
+
+// copyField returns the expression reading field c<index> of the current row
+// as a value of the given type.
+func copyField(index int, dataType string) string {
+	return fmt.Sprintf("CAST(row.c%d, %s)", index, quoteStringLiteral(dataType))
+}
+
+// copyProperties returns the property map assigning the present fields of the
+// current row to the columns, where the field of the first column is
+// c<offset>.
+func copyProperties(columns []tableColumn, present []bool, offset int) string {
+	var properties []string
+	for i, column := range columns {
+		if present[i] {
+			properties = append(properties, QuoteIdentifier(column.name)+": "+copyField(offset+i, column.dataType))
+		}
+	}
+	if len(properties) == 0 {
+		return ""
+	}
+	return " {" + strings.Join(properties, ", ") + "}"
+}
+
+// copyRows runs the job, adding the number of copied rows to copied.
+func copyRows(src *Connection, dst *Connection, job *copyJob, opts *CopyTablesOptions, copied *uint64) error {
+	result, err := src.Query(job.readQuery)
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+	return copyResult(result, dst, job, opts, copied)
+}
+
+// copyResult inserts the rows of the result as described by the job, adding
+// the number of copied rows to copied. The readQuery of the job is not used.
+func copyResult(result *QueryResult, dst *Connection, job *copyJob, opts *CopyTablesOptions, copied *uint64) error {
+	statements := make(map[string]*PreparedStatement)
+	defer func() {
+		for _, statement := range statements {
+			statement.Close()
+		}
+	}()
+	batches := make(map[string][]any)
+	batchBytes := make(map[string]uint64)
+	masks := make(map[string][]bool)
+	sizer := newBatchSizer(dst, opts.BatchSize, opts.Adaptive, job.table, opts.Report)
+
+	flush := func(key string) error {
+		rows := batches[key]
+		if len(rows) == 0 {
+			return nil
+		}
+		statement, ok := statements[key]
+		if !ok {
+			var err error
+			statement, err = dst.Prepare(job.insertQuery(masks[key]))
+			if err != nil {
+				statement.Close()
+				return err
+			}
+			statements[key] = statement
+		}
+		sizer.begin()
+		if err := insertBatch(dst, statement, rows, job.countsRows); err != nil {
+			return err
+		}
+		inserted := uint64(len(rows))
+		sizer.done(len(rows), batchBytes[key])
+		*copied += inserted
+		batches[key] = rows[:0]
+		batchBytes[key] = 0
+		if opts.Progress != nil {
+			opts.Progress(job.table, *copied)
+		}
+		return nil
+	}
+
+	for result.HasNext() {
+		tuple, err := result.Next()
+		if err != nil {
+			tuple.Close()
+			return err
+		}
+		values, err := tuple.GetAsSlice()
+		tuple.Close()
+		if err != nil {
+			return err
+		}
+		present := make([]bool, len(values))
+		row := make(map[string]any, len(values))
+		for i, value := range values {
+			if value == nil {
+				continue
+			}
+			present[i] = true
+			row[fmt.Sprintf("c%d", i)], err = copyParameterValue(value, job.columns[i].dataType)
+			if err != nil {
+				return fmt.Errorf("column %s: %w", job.columns[i].name, err)
+			}
+		}
+		key := copyMaskKey(present)
+		masks[key] = present
+		batches[key] = append(batches[key], row)
+		batchBytes[key] += sizer.rowBytes(row)
+		if sizer.full(len(batches[key]), batchBytes[key]) {
+			if err := flush(key); err != nil {
+				return err
+			}
+		}
+	}
+	keys := make([]string, 0, len(batches))
+	for key := range batches {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := flush(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertBatch inserts a batch of rows with the insert statement of a job. The
+// relationships whose nodes do not exist on the destination are not created,
+// so when countsRows is set, the batch is inserted in a transaction that is
+// rolled back unless all rows are created, unless the destination is in an
+// explicit transaction already.
+func insertBatch(dst *Connection, statement *PreparedStatement, rows []any, countsRows bool) error {
+	dst.snapshotMutex.Lock()
+	transactional := countsRows && !dst.inTransaction && dst.transaction == nil
+	dst.snapshotMutex.Unlock()
+	if transactional {
+		if err := runStatement(dst, "BEGIN TRANSACTION;"); err != nil {
+			return err
+		}
+	}
+	err := func() error {
+		result, err := dst.Execute(statement, map[string]any{"rows": rows})
+		if err != nil {
+			return err
+		}
+		defer result.Close()
+		if !countsRows {
+			return nil
+		}
+		count, err := readCount(result)
+		if err != nil {
+			return err
+		}
+		if inserted := uint64(len(rows)); count != inserted {
+			return fmt.Errorf("%d of %d rows of a batch could not be copied because a node they connect does not exist on the destination", inserted-count, inserted)
+		}
+		return nil
+	}()
+	if !transactional {
+		return err
+	}
+	if err != nil {
+		runStatement(dst, "ROLLBACK;")
+		return err
+	}
+	return runStatement(dst, "COMMIT;")
+}
+
+// readCount reads the single integer returned by a count query.
+func readCount(result *QueryResult) (uint64, error) {
+	if !result.HasNext() {
+		return 0, fmt.Errorf("count query returned no rows")
+	}
+	tuple, err := result.Next()
+	defer tuple.Close()
+	if err != nil {
+		return 0, err
+	}
+	value, err := tuple.GetValue(0)
+	if err != nil {
+		return 0, err
+	}
+	count, ok := value.(int64)
+	if !ok || count < 0 {
+		return 0, fmt.Errorf("count query returned %v", value)
+	}
+	return uint64(count), nil
+}
+
+// copyMaskKey returns the key of the batch of rows with the given set of
+// non-null values.
+func copyMaskKey(present []bool) string {
+	key := make([]byte, len(present))
+	for i, ok := range present {
+		if ok {
+			key[i] = '1'
+		} else {
+			key[i] = '0'
+		}
+	}
+	return string(key)
+}
+
+// copyParameterValue converts a value read from a column of the given type to
+// a value that can be bound as a parameter and cast back to the type of the
+// column. Values of types that cannot be bound directly are passed as their
+// string representation.
+func copyParameterValue(value any, dataType string) (any, error) {
+	switch v := value.(type) {
+	case []byte:
+		if strings.EqualFold(dataType, "BLOB") {
+			return string(appendEscapedBlob(make([]byte, 0, 4*len(v)), v)), nil
+		}
+	case decimal.Decimal:
+		return v.String(), nil
+	case uuid.UUID:
+		return v.String(), nil
+	case *big.Int:
+		return v.String(), nil
+	case []any:
+		if len(v) == 0 {
+			return "[]", nil
+		}
+	case []MapItem:
+		if len(v) == 0 {
+			return "{}", nil
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,200 @@
+package lbug
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openCopyTestConnection(t *testing.T) *Connection {
+	t.Helper()
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	t.Cleanup(db.Close)
+	conn, err := OpenConnection(db)
+	if err != nil {
+		t.Fatalf("Error opening connection: %v", err)
+	}
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+func mustRun(t *testing.T, conn *Connection, query string) {
+	t.Helper()
+	result, err := conn.Query(query)
+	if err != nil {
+		t.Fatalf("Error running %q: %v", query, err)
+	}
+	result.Close()
+}
+
+func TestCopyTables(t *testing.T) {
+	src := openCopyTestConnection(t)
+	dst := openCopyTestConnection(t)
+	mustRun(t, src, "CREATE NODE TABLE person(name STRING, age INT64, tags STRING[], PRIMARY KEY(name));")
+	mustRun(t, src, "CREATE REL TABLE knows(FROM person TO person, since DATE);")
+	for i := 0; i < 25; i++ {
+		mustRun(t, src, fmt.Sprintf("CREATE (:person {name: 'p%d', age: %d, tags: ['a', 'b']});", i, i))
+	}
+	mustRun(t, src, "CREATE (:person {name: 'nobody'});")
+	mustRun(t, src, "MATCH (a:person), (b:person) WHERE a.age + 1 = b.age CREATE (a)-[:knows {since: date('2020-01-01')}]->(b);")
+	mustRun(t, src, "MATCH (a:person {name: 'p0'}), (b:person {name: 'nobody'}) CREATE (a)-[:knows]->(b);")
+
+	var progress []string
+	counts, err := CopyTables(src, dst, []string{"knows", "person"}, CopyTablesOptions{
+		BatchSize: 10,
+		Progress: func(table string, rowsCopied uint64) {
+			progress = append(progress, fmt.Sprintf("%s:%d", table, rowsCopied))
+		},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]uint64{"person": 26, "knows": 25}, counts)
+	assert.Equal(t, "person:10", progress[0])
+	assert.Equal(t, "knows:25", progress[len(progress)-1])
+
+	result, err := dst.Query("MATCH (a:person)-[k:knows]->(b:person) WHERE a.name = 'p3' RETURN b.name, b.age, b.tags, k.since;")
+	assert.Nil(t, err)
+	defer result.Close()
+	assert.True(t, result.HasNext())
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	values, err := tuple.GetAsSlice()
+	assert.Nil(t, err)
+	assert.Equal(t, "p4", values[0])
+	assert.Equal(t, int64(4), values[1])
+	assert.Equal(t, []any{"a", "b"}, values[2])
+	assert.NotNil(t, values[3])
+
+	result, err = dst.Query("MATCH (:person {name: 'p0'})-[k:knows]->(b:person {name: 'nobody'}) RETURN k.since, b.age, b.tags;")
+	assert.Nil(t, err)
+	defer result.Close()
+	assert.True(t, result.HasNext())
+	tuple, err = result.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	values, err = tuple.GetAsSlice()
+	assert.Nil(t, err)
+	assert.Equal(t, []any{nil, nil, nil}, values)
+}
+
+func TestCopyTablesExistingTable(t *testing.T) {
+	src := openCopyTestConnection(t)
+	dst := openCopyTestConnection(t)
+	mustRun(t, src, "CREATE NODE TABLE item(id INT64, PRIMARY KEY(id));")
+	mustRun(t, src, "CREATE (:item {id: 1});")
+	mustRun(t, dst, "CREATE NODE TABLE item(id INT64, PRIMARY KEY(id));")
+	mustRun(t, dst, "CREATE (:item {id: 2});")
+
+	_, err := CopyTables(src, dst, nil, CopyTablesOptions{})
+	assert.ErrorContains(t, err, "table item already exists on the destination")
+
+	counts, err := CopyTables(src, dst, nil, CopyTablesOptions{DropExisting: true})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]uint64{"item": 1}, counts)
+	result, err := dst.Query("MATCH (i:item) RETURN i.id;")
+	assert.Nil(t, err)
+	defer result.Close()
+	assert.Equal(t, uint64(1), result.GetNumberOfRows())
+}
+
+func TestCopyTablesErrors(t *testing.T) {
+	src := openCopyTestConnection(t)
+	dst := openCopyTestConnection(t)
+	mustRun(t, src, "CREATE NODE TABLE a(id INT64, PRIMARY KEY(id));")
+	mustRun(t, src, "CREATE REL TABLE link(FROM a TO a);")
+	mustRun(t, src, "CREATE NODE TABLE s(id SERIAL, PRIMARY KEY(id));")
+
+	_, err := CopyTables(src, src, nil, CopyTablesOptions{})
+	assert.ErrorContains(t, err, "connections to different databases")
+	other, err := OpenConnection(src.database)
+	assert.Nil(t, err)
+	defer other.Close()
+	_, err = CopyTables(src, other, nil, CopyTablesOptions{DropExisting: true})
+	assert.ErrorContains(t, err, "connections to different databases")
+	_, err = CopyTables(src, dst, []string{"missing"}, CopyTablesOptions{})
+	assert.ErrorIs(t, err, ErrNoSuchTable)
+	_, err = CopyTables(src, dst, []string{"link"}, CopyTablesOptions{})
+	assert.ErrorContains(t, err, "neither copied nor present on the destination")
+	_, err = CopyTables(src, dst, []string{"s"}, CopyTablesOptions{})
+	assert.ErrorContains(t, err, "SERIAL column id")
+}
+
+func TestCopyTablesSameDatabasePath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	first, err := OpenDatabase(path, DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer first.Close()
+	second, err := OpenDatabase(path, DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer second.Close()
+	src, err := OpenConnection(first)
+	assert.Nil(t, err)
+	defer src.Close()
+	dst, err := OpenConnection(second)
+	assert.Nil(t, err)
+	defer dst.Close()
+	mustRun(t, src, "CREATE NODE TABLE a(id INT64, PRIMARY KEY(id));")
+	mustRun(t, src, "CREATE (:a {id: 1});")
+
+	_, err = CopyTables(src, dst, nil, CopyTablesOptions{DropExisting: true})
+	assert.ErrorContains(t, err, "connections to different databases")
+	count, err := queryCount(src, "MATCH (n:a) RETURN count(n);")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), count)
+}
+
+func TestCopyTablesMissingEndpointRollsBackBatch(t *testing.T) {
+	src := openCopyTestConnection(t)
+	dst := openCopyTestConnection(t)
+	mustRun(t, src, "CREATE NODE TABLE a(id INT64, PRIMARY KEY(id));")
+	mustRun(t, src, "CREATE REL TABLE link(FROM a TO a);")
+	mustRun(t, src, "CREATE (:a {id: 1})-[:link]->(:a {id: 2}), (:a {id: 3})-[:link]->(:a {id: 4});")
+	mustRun(t, dst, "CREATE NODE TABLE a(id INT64, PRIMARY KEY(id));")
+	mustRun(t, dst, "CREATE (:a {id: 1}), (:a {id: 2}), (:a {id: 3});")
+
+	counts, err := CopyTables(src, dst, []string{"link"}, CopyTablesOptions{BatchSize: 2})
+	assert.ErrorContains(t, err, "1 of 2 rows of a batch could not be copied")
+	assert.ErrorContains(t, err, "after 0 rows")
+	assert.Equal(t, uint64(0), counts["link"])
+	// The batch is rolled back rather than partially inserted.
+	count, err := queryCount(dst, "MATCH ()-[l:link]->() RETURN count(l);")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), count)
+}
+
+func TestCreateTableStatement(t *testing.T) {
+	node := &tableSchema{name: "person", columns: []tableColumn{
+		{name: "id", dataType: "INT64", primaryKey: true},
+		{name: "full name", dataType: "STRING"},
+		{name: "score", dataType: "DOUBLE", defaultExpression: "1.5"},
+	}}
+	assert.Equal(t, "CREATE NODE TABLE `person`(`id` INT64, `full name` STRING, `score` DOUBLE DEFAULT 1.5, PRIMARY KEY(`id`));", node.createTableStatement())
+	rel := &tableSchema{name: "knows", isRel: true,
+		columns:     []tableColumn{{name: "since", dataType: "DATE"}},
+		connections: []relConnection{{from: "person", to: "person"}},
+	}
+	assert.Equal(t, "CREATE REL TABLE `knows`(FROM `person` TO `person`, `since` DATE);", rel.createTableStatement())
+}
+
+func TestQuoting(t *testing.T) {
+	assert.Equal(t, "`a``b`", QuoteIdentifier("a`b"))
+	assert.Equal(t, `'it\'s \\'`, quoteStringLiteral(`it's \`))
+}
+
+func TestCopyParameterValue(t *testing.T) {
+	value, err := copyParameterValue([]byte{0x01, 0xAB}, "BLOB")
+	assert.Nil(t, err)
+	assert.Equal(t, `\x01\xAB`, value)
+	value, err = copyParameterValue([]any{}, "INT64[]")
+	assert.Nil(t, err)
+	assert.Equal(t, "[]", value)
+	value, err = copyParameterValue(int64(3), "INT64")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), value)
+	assert.Equal(t, "101", copyMaskKey([]bool{true, false, true}))
+}
@@ -0,0 +1,156 @@
+package lbug
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// crashTraceSize is the number of recent operations kept per connection for
+// crash reports.
+const crashTraceSize = 16
+
+// The operations recorded in the trace of a connection.
+const (
+	traceQuery uint32 = iota + 1
+	tracePrepare
+	traceExecute
+	traceExec
+)
+
+var traceOperationNames = [...]string{
+	traceQuery:   recordedQuery,
+	tracePrepare: recordedPrepare,
+	traceExecute: recordedExecute,
+	traceExec:    recordedExec,
+}
+
+// traceEntry is an operation in the trace of a connection. Its fields are
+// written without synchronizing with the readers, so an entry that is being
+// overwritten while a report is written may mix two operations.
+type traceEntry struct {
+	op        atomic.Uint32
+	queryHash atomic.Uint64
+	handleID  atomic.Uint64
+	time      atomic.Int64
+}
+
+// operationTrace is a ring buffer of the recent operations of a connection.
+type operationTrace struct {
+	next    atomic.Uint64
+	entries [crashTraceSize]traceEntry
+}
+
+// add records an operation on the prepared statement with the given handle
+// ID, or zero for a query.
+func (trace *operationTrace) add(op uint32, query string, handleID uint64) {
+	entry := &trace.entries[(trace.next.Add(1)-1)%crashTraceSize]
+	entry.op.Store(op)
+	entry.queryHash.Store(queryHash(query))
+	entry.handleID.Store(handleID)
+	entry.time.Store(time.Now().UnixNano())
+}
+
+// queryHash returns the 64-bit FNV-1a hash of the query, which identifies the
+// query in crash reports without revealing it.
+func queryHash(query string) uint64 {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(query); i++ {
+		hash ^= uint64(query[i])
+		hash *= 1099511628211
+	}
+	return hash
+}
+
+// crashReporter writes crash reports to a writer.
+type crashReporter struct {
+	w io.Writer
+}
+
+var crashReports atomic.Pointer[crashReporter]
+
+// EnableCrashReports enables crash reports, which are written to w, or to
+// standard error if w is nil. While crash reports are enabled, every
+// connection keeps the hash, prepared statement handle ID and start time of
+// its last 16 Query, Prepare, Execute and Exec calls, and these calls turn
+// faults in Go code into panics with debug.SetPanicOnFault. When such a call
+// panics, a report of the recent operations of all open connections and of
+// the open handles is written before the panic continues.
+//
+// Faults inside the native library terminate the process before any Go code
+// can run, so they cannot be reported. To have the state at hand when
+// investigating such crashes, call WriteCrashReport, e.g. from a signal
+// handler or an admin endpoint, while the process is still alive.
+func EnableCrashReports(w io.Writer) {
+	if w == nil {
+		w = os.Stderr
+	}
+	crashReports.Store(&crashReporter{w: w})
+}
+
+// DisableCrashReports disables crash reports. The operations recorded so far
+// are kept until they are overwritten.
+func DisableCrashReports() {
+	crashReports.Store(nil)
+}
+
+// recoverFault is deferred by the operations traced for crash reports. It
+// restores the previous setting of debug.SetPanicOnFault and, if the
+// operation panics, writes a crash report before continuing to panic.
+func (reporter *crashReporter) recoverFault(panicOnFault bool) {
+	debug.SetPanicOnFault(panicOnFault)
+	if r := recover(); r != nil {
+		fmt.Fprintf(reporter.w, "lbug: panic: %v\n", r)
+		WriteCrashReport(reporter.w)
+		panic(r)
+	}
+}
+
+// WriteCrashReport writes the recent operations of the open connections,
+// recorded while crash reports are enabled, the number of open handles by
+// kind and, if handle tracking is enabled, the creation stacks of the open
+// handles.
+func WriteCrashReport(w io.Writer) error {
+	var connections []*Connection
+	for _, object := range handles.openObjects() {
+		if conn, ok := object.(*Connection); ok {
+			connections = append(connections, conn)
+		}
+	}
+	sort.Slice(connections, func(i, j int) bool { return connections[i].handleID < connections[j].handleID })
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "lbug crash report")
+	for _, conn := range connections {
+		fmt.Fprintf(out, "%v %d of %v %d:\n", HandleConnection, conn.handleID, HandleDatabase, conn.database.handleID)
+		next := conn.trace.next.Load()
+		for n := uint64(min(next, crashTraceSize)); n > 0; n-- {
+			entry := &conn.trace.entries[(next-n)%crashTraceSize]
+			op := entry.op.Load()
+			if op == 0 || int(op) >= len(traceOperationNames) {
+				continue
+			}
+			fmt.Fprintf(out, "  %s %-7s query %016x", time.Unix(0, entry.time.Load()).UTC().Format(time.RFC3339Nano),
+				traceOperationNames[op], entry.queryHash.Load())
+			if id := entry.handleID.Load(); id != 0 {
+				fmt.Fprintf(out, " %v %d", HandlePreparedStatement, id)
+			}
+			fmt.Fprintln(out)
+		}
+	}
+	counts := OpenHandleCounts()
+	fmt.Fprint(out, "open handles:")
+	for kind := HandleKind(0); kind < numHandleKinds; kind++ {
+		fmt.Fprintf(out, " %v=%d", kind, counts[kind])
+	}
+	fmt.Fprintln(out)
+	for _, info := range OpenHandles() {
+		fmt.Fprintf(out, "%v %d created at:\n%s", info.Kind, info.ID, info.Stack)
+	}
+	return out.Flush()
+}
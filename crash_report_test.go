@@ -0,0 +1,64 @@
+package lbug
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperationTraceWrapsAround(t *testing.T) {
+	var trace operationTrace
+	for i := 0; i < crashTraceSize+3; i++ {
+		trace.add(traceQuery, fmt.Sprintf("RETURN %d", i), uint64(i))
+	}
+	assert.Equal(t, uint64(crashTraceSize+3), trace.next.Load())
+	// The oldest entries are overwritten.
+	assert.Equal(t, uint64(crashTraceSize), trace.entries[0].handleID.Load())
+	assert.Equal(t, queryHash(fmt.Sprintf("RETURN %d", crashTraceSize+2)), trace.entries[2].queryHash.Load())
+	assert.Equal(t, uint64(3), trace.entries[3].handleID.Load())
+}
+
+func TestQueryHash(t *testing.T) {
+	assert.Equal(t, uint64(14695981039346656037), queryHash(""))
+	assert.Equal(t, uint64(0xaf63dc4c8601ec8c), queryHash("a"))
+	assert.NotEqual(t, queryHash("RETURN 1"), queryHash("RETURN 2"))
+}
+
+func TestRecoverFaultWritesReport(t *testing.T) {
+	var report bytes.Buffer
+	reporter := &crashReporter{w: &report}
+	func() {
+		defer func() {
+			assert.Equal(t, "boom", recover())
+		}()
+		defer reporter.recoverFault(false)
+		panic("boom")
+	}()
+	assert.Contains(t, report.String(), "lbug: panic: boom\nlbug crash report\n")
+	assert.Contains(t, report.String(), "open handles:")
+}
+
+func TestCrashReportTracesOperations(t *testing.T) {
+	var report bytes.Buffer
+	EnableCrashReports(&report)
+	defer DisableCrashReports()
+	_, conn := openTempTableTestConnection(t)
+	result, err := conn.Query("RETURN 1")
+	assert.Nil(t, err)
+	result.Close()
+	stmt, err := conn.Prepare("RETURN $x")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	_, err = stmt.Exec(map[string]any{"x": 1})
+	assert.Nil(t, err)
+
+	var out bytes.Buffer
+	assert.Nil(t, WriteCrashReport(&out))
+	assert.Contains(t, out.String(), fmt.Sprintf("Connection %d of Database %d:\n", conn.handleID, conn.database.handleID))
+	assert.Contains(t, out.String(), fmt.Sprintf("query   query %016x\n", queryHash("RETURN 1")))
+	assert.Contains(t, out.String(), fmt.Sprintf("exec    query %016x PreparedStatement %d\n", queryHash("RETURN $x"), stmt.handleID))
+	assert.NotContains(t, out.String(), "RETURN")
+	assert.Empty(t, report.String())
+}
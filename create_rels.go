@@ -0,0 +1,351 @@
+package lbug
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RelSpec describes a relationship created by Connection.CreateRels between
+// the nodes with the given primary keys.
+type RelSpec struct {
+	FromKey any
+	ToKey   any
+	// Props are the properties of the relationship by column name.
+	Props map[string]any
+}
+
+// CreateRelsOptions configures Connection.CreateRels.
+type CreateRelsOptions struct {
+	// BatchSize is the number of relationships created per statement. If it
+	// is zero, 1000 is used. With Adaptive, it is the size of the first
+	// batch.
+	BatchSize int
+	// Adaptive, if set, adapts the batch size to the latency of the batches,
+	// see AdaptiveBatching.
+	Adaptive *AdaptiveBatching
+	// From and To are the node tables the relationships connect. They may be
+	// omitted if the relationship table connects a single pair of tables.
+	From string
+	To   string
+	// Merge creates the relationships with MERGE instead of CREATE, so that a
+	// relationship with the same endpoints and properties is not created
+	// twice.
+	Merge bool
+	// StrictTypes disables the conversion of keys and properties to the types
+	// of their columns, e.g. of integral float64 values decoded from JSON to
+	// INT64. Without it, values that cannot be converted without losing data
+	// fail with a *CoercionError.
+	StrictTypes bool
+	// Idempotency, if it has a batch ID, records the ID in the transaction of
+	// the relationships, and the submission of a batch that has already been
+	// applied creates nothing and returns the result of the first one, with
+	// the unmatched pairs taken from pairs by their index.
+	Idempotency IdempotencyOptions
+}
+
+// CreateRelsResult is the result of Connection.CreateRels.
+type CreateRelsResult struct {
+	// Created is the number of relationships created or, with Merge, created
+	// or matched.
+	Created uint64
+	// Unmatched are the pairs for which no node with FromKey or no node with
+	// ToKey exists, in the order they were passed.
+	Unmatched []RelSpec
+	// Report reports the batches inserted, with the sizes chosen with
+	// Adaptive.
+	Report IngestionReport
+}
+
+// CreateRels creates relationships of the relationship table between the
+// nodes identified by their primary keys. The pairs are created in batches
+// with UNWIND, MATCH and CREATE statements inside a single transaction, which
+// is rolled back if a statement fails, so CreateRels must not be called while
+// a transaction of the connection is open. Pairs whose endpoints do not both
+// exist are skipped and returned in CreateRelsResult.Unmatched. The table
+// names are resolved as by Connection.ResolveTableName.
+func (conn *Connection) CreateRels(relTable string, pairs []RelSpec, opts CreateRelsOptions) (CreateRelsResult, error) {
+	var result CreateRelsResult
+	if err := opts.Adaptive.validate(); err != nil {
+		return result, err
+	}
+	if opts.BatchSize <= 0 && opts.Adaptive == nil {
+		opts.BatchSize = defaultCopyBatchSize
+	}
+	job, err := conn.createRelsJob(relTable, &opts)
+	if err != nil {
+		return result, err
+	}
+	if len(pairs) == 0 {
+		return result, nil
+	}
+
+	statements := make(map[string]*PreparedStatement)
+	defer func() {
+		for _, statement := range statements {
+			statement.Close()
+		}
+	}()
+	batches := make(map[string][]any)
+	batchBytes := make(map[string]uint64)
+	masks := make(map[string][]bool)
+	matched := make([]bool, len(pairs))
+	sizer := newBatchSizer(conn, opts.BatchSize, opts.Adaptive, job.table, &result.Report)
+	flush := func(key string) error {
+		rows := batches[key]
+		if len(rows) == 0 {
+			return nil
+		}
+		statement, ok := statements[key]
+		if !ok {
+			var err error
+			statement, err = conn.Prepare(job.insertQuery(masks[key]))
+			if err != nil {
+				statement.Close()
+				return err
+			}
+			statements[key] = statement
+		}
+		sizer.begin()
+		queryResult, err := conn.Execute(statement, map[string]any{"rows": rows})
+		if err != nil {
+			return err
+		}
+		defer queryResult.Close()
+		for queryResult.HasNext() {
+			tuple, err := queryResult.Next()
+			if err != nil {
+				tuple.Close()
+				return err
+			}
+			value, err := tuple.GetValue(0)
+			tuple.Close()
+			if err != nil {
+				return err
+			}
+			index, ok := value.(int64)
+			if !ok || index < 0 || index >= int64(len(pairs)) {
+				return fmt.Errorf("create query returned unexpected row index %v", value)
+			}
+			if !matched[index] {
+				matched[index] = true
+				result.Created++
+			}
+		}
+		sizer.done(len(rows), batchBytes[key])
+		batches[key] = rows[:0]
+		batchBytes[key] = 0
+		return nil
+	}
+
+	insert := func() error {
+		for i, pair := range pairs {
+			values := make([]any, len(job.columns))
+			values[0], values[1] = pair.FromKey, pair.ToKey
+			for name, value := range pair.Props {
+				index, ok := job.propertyIndex[name]
+				if !ok {
+					return fmt.Errorf("relationship table %s has no property %s", job.table, name)
+				}
+				values[index] = value
+			}
+			if values[0] == nil || values[1] == nil {
+				continue
+			}
+			present := make([]bool, len(values))
+			row := map[string]any{"i": int64(i)}
+			for j, value := range values {
+				if value == nil {
+					continue
+				}
+				present[j] = true
+				if !opts.StrictTypes {
+					var ok bool
+					if value, ok = coerceValue(value, job.columns[j].dataType); !ok {
+						return &CoercionError{Row: i, Column: job.columns[j].name, Value: value, Type: job.columns[j].dataType}
+					}
+				}
+				var err error
+				row[fmt.Sprintf("c%d", j)], err = copyParameterValue(value, job.columns[j].dataType)
+				if err != nil {
+					return fmt.Errorf("column %s: %w", job.columns[j].name, err)
+				}
+			}
+			key := copyMaskKey(present)
+			masks[key] = present
+			batches[key] = append(batches[key], row)
+			batchBytes[key] += sizer.rowBytes(row)
+			if sizer.full(len(batches[key]), batchBytes[key]) {
+				if err := flush(key); err != nil {
+					return err
+				}
+			}
+		}
+		keys := make([]string, 0, len(batches))
+		for key := range batches {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := flush(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if opts.Idempotency.BatchID != "" {
+		var summary createRelsSummary
+		_, err := conn.applyBatch(opts.Idempotency, &summary, func() error {
+			if err := insert(); err != nil {
+				return err
+			}
+			summary = createRelsSummary{Created: result.Created, Report: result.Report}
+			for i := range pairs {
+				if !matched[i] {
+					summary.Unmatched = append(summary.Unmatched, i)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return CreateRelsResult{}, err
+		}
+		result = CreateRelsResult{Created: summary.Created, Report: summary.Report}
+		for _, i := range summary.Unmatched {
+			if i < len(pairs) {
+				result.Unmatched = append(result.Unmatched, pairs[i])
+			}
+		}
+		return result, nil
+	}
+	if err := runStatement(conn, "BEGIN TRANSACTION;"); err != nil {
+		return result, err
+	}
+	if err := insert(); err != nil {
+		runStatement(conn, "ROLLBACK;")
+		return CreateRelsResult{}, err
+	}
+	if err := runStatement(conn, "COMMIT;"); err != nil {
+		return CreateRelsResult{}, err
+	}
+	for i, pair := range pairs {
+		if !matched[i] {
+			result.Unmatched = append(result.Unmatched, pair)
+		}
+	}
+	return result, nil
+}
+
+// createRelsSummary is the summary of a batch of CreateRels recorded with
+// IdempotencyOptions, with the indexes of the unmatched pairs, from which the
+// result of a submission of the batch that has already been applied is made.
+type createRelsSummary struct {
+	Created   uint64
+	Unmatched []int
+	Report    IngestionReport
+}
+
+// createRelsJob describes how CreateRels inserts the relationships of a
+// table. The fields c0 and c1 of the rows are the primary keys of the
+// endpoints, followed by the properties of the relationship, and the field i
+// is the index of the pair, which the insert query returns for every created
+// relationship.
+type createRelsJob struct {
+	table         string
+	columns       []tableColumn
+	propertyIndex map[string]int
+	insertQuery   func(present []bool) string
+}
+
+// createRelsJob returns the job creating relationships of the table between
+// the node tables selected by the options.
+func (conn *Connection) createRelsJob(relTable string, opts *CreateRelsOptions) (*createRelsJob, error) {
+	tables, err := conn.catalogTables()
+	if err != nil {
+		return nil, err
+	}
+	name, err := resolveTableName(tables, relTable)
+	if err != nil {
+		return nil, err
+	}
+	if !tables[name] {
+		return nil, fmt.Errorf("table %s is not a relationship table", name)
+	}
+	schema, err := describeTable(conn, name, true)
+	if err != nil {
+		return nil, err
+	}
+	connection, err := selectRelConnection(tables, schema, opts.From, opts.To)
+	if err != nil {
+		return nil, err
+	}
+	schemas := make(map[string]*tableSchema)
+	fromKey, err := endpointPrimaryKey(conn, connection.from, connection.fromPrimaryKey, schemas)
+	if err != nil {
+		return nil, err
+	}
+	toKey, err := endpointPrimaryKey(conn, connection.to, connection.toPrimaryKey, schemas)
+	if err != nil {
+		return nil, err
+	}
+	columns := append([]tableColumn{fromKey, toKey}, schema.columns...)
+	propertyIndex := make(map[string]int, len(schema.columns))
+	for i, column := range schema.columns {
+		propertyIndex[column.name] = i + 2
+	}
+	clause := "CREATE"
+	if opts.Merge {
+		clause = "MERGE"
+	}
+	return &createRelsJob{
+		table:         name,
+		columns:       columns,
+		propertyIndex: propertyIndex,
+		insertQuery: func(present []bool) string {
+			return fmt.Sprintf("UNWIND $rows AS row MATCH (a:%s), (b:%s) WHERE a.%s = %s AND b.%s = %s %s (a)-[r:%s%s]->(b) RETURN row.i;",
+				QuoteIdentifier(connection.from), QuoteIdentifier(connection.to),
+				QuoteIdentifier(fromKey.name), copyField(0, fromKey.dataType),
+				QuoteIdentifier(toKey.name), copyField(1, toKey.dataType),
+				clause, QuoteIdentifier(name), copyProperties(schema.columns, present[2:], 2))
+		},
+	}, nil
+}
+
+// selectRelConnection returns the pair of node tables of the relationship
+// table named by from and to, which may be empty if the table connects a
+// single pair.
+func selectRelConnection(tables map[string]bool, schema *tableSchema, from string, to string) (relConnection, error) {
+	if from == "" && to == "" {
+		if len(schema.connections) != 1 {
+			return relConnection{}, fmt.Errorf("relationship table %s connects %d pairs of node tables; From and To must be set", schema.name, len(schema.connections))
+		}
+		return schema.connections[0], nil
+	}
+	var err error
+	if from != "" {
+		if from, err = resolveTableName(tables, from); err != nil {
+			return relConnection{}, err
+		}
+	}
+	if to != "" {
+		if to, err = resolveTableName(tables, to); err != nil {
+			return relConnection{}, err
+		}
+	}
+	var candidates []relConnection
+	for _, connection := range schema.connections {
+		if (from == "" || connection.from == from) && (to == "" || connection.to == to) {
+			candidates = append(candidates, connection)
+		}
+	}
+	if len(candidates) != 1 {
+		pairs := make([]string, len(schema.connections))
+		for i, connection := range schema.connections {
+			pairs[i] = connection.from + " -> " + connection.to
+		}
+		return relConnection{}, fmt.Errorf("relationship table %s does not connect a single pair of node tables matching %q to %q (pairs: %s)",
+			schema.name, from, to, strings.Join(pairs, ", "))
+	}
+	return candidates[0], nil
+}
@@ -0,0 +1,137 @@
+package lbug
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateRels(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE person(id INT64, PRIMARY KEY(id));")
+	mustRun(t, conn, "CREATE REL TABLE knows(FROM person TO person, since INT64, note STRING);")
+	mustRun(t, conn, "UNWIND range(0, 9) AS i CREATE (:person {id: i});")
+
+	var pairs []RelSpec
+	for i := 0; i < 9; i++ {
+		pairs = append(pairs, RelSpec{FromKey: int64(i), ToKey: int64(i + 1), Props: map[string]any{"since": int64(2000 + i)}})
+	}
+	pairs = append(pairs,
+		RelSpec{FromKey: int64(0), ToKey: int64(42)},
+		RelSpec{FromKey: int64(3), ToKey: int64(4), Props: map[string]any{"note": "no since"}},
+		RelSpec{FromKey: int64(99), ToKey: int64(1)},
+	)
+	result, err := conn.CreateRels("Knows", pairs, CreateRelsOptions{BatchSize: 4})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(10), result.Created)
+	assert.Equal(t, []RelSpec{pairs[9], pairs[11]}, result.Unmatched)
+
+	rows, err := queryRows(conn, "MATCH (a:person)-[k:knows]->(b:person) WHERE a.id = 3 RETURN b.id AS id, k.since AS since, k.note AS note ORDER BY k.since;")
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{
+		{"id": int64(4), "since": int64(2003), "note": nil},
+		{"id": int64(4), "since": nil, "note": "no since"},
+	}, rows)
+}
+
+func TestCreateRelsMerge(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE person(id INT64, PRIMARY KEY(id));")
+	mustRun(t, conn, "CREATE REL TABLE knows(FROM person TO person);")
+	mustRun(t, conn, "UNWIND range(0, 2) AS i CREATE (:person {id: i});")
+	pairs := []RelSpec{{FromKey: int64(0), ToKey: int64(1)}, {FromKey: int64(1), ToKey: int64(2)}}
+	for i := 0; i < 2; i++ {
+		result, err := conn.CreateRels("knows", pairs, CreateRelsOptions{Merge: true})
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(2), result.Created)
+	}
+	rows, err := queryRows(conn, "MATCH ()-[k:knows]->() RETURN count(k) AS n;")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), rows[0]["n"])
+}
+
+func TestCreateRelsErrors(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE person(id INT64, PRIMARY KEY(id));")
+	mustRun(t, conn, "CREATE NODE TABLE city(name STRING, PRIMARY KEY(name));")
+	mustRun(t, conn, "CREATE REL TABLE visits(FROM person TO person, FROM person TO city);")
+	mustRun(t, conn, "CREATE (:person {id: 1}), (:person {id: 2});")
+
+	_, err := conn.CreateRels("visits", nil, CreateRelsOptions{})
+	assert.ErrorContains(t, err, "From and To must be set")
+	_, err = conn.CreateRels("person", nil, CreateRelsOptions{})
+	assert.ErrorContains(t, err, "not a relationship table")
+	_, err = conn.CreateRels("visit", nil, CreateRelsOptions{})
+	assert.ErrorIs(t, err, ErrNoSuchTable)
+
+	pairs := []RelSpec{{FromKey: int64(1), ToKey: int64(2)}, {FromKey: int64(2), ToKey: int64(1), Props: map[string]any{"missing": 1}}}
+	_, err = conn.CreateRels("visits", pairs, CreateRelsOptions{From: "person", To: "person"})
+	assert.ErrorContains(t, err, "has no property missing")
+	// The transaction is rolled back, so the first pair was not created.
+	rows, err := queryRows(conn, "MATCH ()-[v:visits]->() RETURN count(v) AS n;")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), rows[0]["n"])
+
+	result, err := conn.CreateRels("visits", pairs[:1], CreateRelsOptions{From: "person", To: "person"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), result.Created)
+}
+
+const benchmarkRels = 100000
+
+func openCreateRelsBenchmarkConnection(b *testing.B) *Connection {
+	b.Helper()
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	if err != nil {
+		b.Fatalf("Error opening database: %v", err)
+	}
+	b.Cleanup(db.Close)
+	conn, err := OpenConnection(db)
+	if err != nil {
+		b.Fatalf("Error opening connection: %v", err)
+	}
+	b.Cleanup(conn.Close)
+	for _, query := range []string{
+		"CREATE NODE TABLE item(id INT64, PRIMARY KEY(id));",
+		"CREATE REL TABLE links(FROM item TO item, weight INT64);",
+		fmt.Sprintf("UNWIND range(0, %d) AS i CREATE (:item {id: i});", benchmarkRels),
+	} {
+		if err := runStatement(conn, query); err != nil {
+			b.Fatalf("Error running %q: %v", query, err)
+		}
+	}
+	return conn
+}
+
+func BenchmarkCreateRels(b *testing.B) {
+	conn := openCreateRelsBenchmarkConnection(b)
+	pairs := make([]RelSpec, benchmarkRels)
+	for i := range pairs {
+		pairs[i] = RelSpec{FromKey: int64(i), ToKey: int64(i + 1), Props: map[string]any{"weight": int64(i)}}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := conn.CreateRels("links", pairs, CreateRelsOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCreateRelsNaive(b *testing.B) {
+	conn := openCreateRelsBenchmarkConnection(b)
+	stmt, err := conn.Prepare("MATCH (a:item {id: $from}), (b:item {id: $to}) CREATE (a)-[:links {weight: $weight}]->(b);")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer stmt.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < benchmarkRels; j++ {
+			args := map[string]any{"from": int64(j), "to": int64(j + 1), "weight": int64(j)}
+			if _, err := stmt.Exec(args); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
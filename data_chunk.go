@@ -0,0 +1,561 @@
+package lbug
+
+/*
+#include "lbug.h"
+#include <stdlib.h>
+#include <string.h>
+
+// lbug_go_is_fixed_width returns true if the values of the type are fetched
+// by lbug_go_fetch_fixed_width.
+static bool lbug_go_is_fixed_width(lbug_data_type_id type) {
+	switch (type) {
+	case LBUG_BOOL:
+	case LBUG_INT64:
+	case LBUG_SERIAL:
+	case LBUG_INT32:
+	case LBUG_INT16:
+	case LBUG_INT8:
+	case LBUG_UINT64:
+	case LBUG_UINT32:
+	case LBUG_UINT16:
+	case LBUG_UINT8:
+	case LBUG_DOUBLE:
+	case LBUG_FLOAT:
+		return true;
+	default:
+		return false;
+	}
+}
+
+// lbug_go_fetch_fixed_width fetches up to max_rows tuples of a result whose
+// columns all have fixed-width types into values and nulls, which hold
+// max_rows entries per column, column after column. Signed integers are
+// stored sign-extended, floating point numbers as the bits of a double.
+static lbug_state lbug_go_fetch_fixed_width(lbug_query_result *result, uint64_t max_rows,
+		uint64_t num_columns, const lbug_data_type_id *types, uint64_t *values, bool *nulls,
+		uint64_t *num_rows) {
+	*num_rows = 0;
+	while (*num_rows < max_rows && lbug_query_result_has_next(result)) {
+		lbug_flat_tuple tuple;
+		if (lbug_query_result_get_next(result, &tuple) != LbugSuccess) {
+			return LbugError;
+		}
+		for (uint64_t column = 0; column < num_columns; column++) {
+			uint64_t index = column * max_rows + *num_rows;
+			lbug_value value;
+			if (lbug_flat_tuple_get_value(&tuple, column, &value) != LbugSuccess) {
+				lbug_flat_tuple_destroy(&tuple);
+				return LbugError;
+			}
+			values[index] = 0;
+			nulls[index] = lbug_value_is_null(&value);
+			if (nulls[index]) {
+				continue;
+			}
+			switch (types[column]) {
+			case LBUG_BOOL: {
+				bool v = false;
+				lbug_value_get_bool(&value, &v);
+				values[index] = v;
+				break;
+			}
+			case LBUG_INT64:
+			case LBUG_SERIAL: {
+				int64_t v = 0;
+				lbug_value_get_int64(&value, &v);
+				values[index] = (uint64_t)v;
+				break;
+			}
+			case LBUG_INT32: {
+				int32_t v = 0;
+				lbug_value_get_int32(&value, &v);
+				values[index] = (uint64_t)(int64_t)v;
+				break;
+			}
+			case LBUG_INT16: {
+				int16_t v = 0;
+				lbug_value_get_int16(&value, &v);
+				values[index] = (uint64_t)(int64_t)v;
+				break;
+			}
+			case LBUG_INT8: {
+				int8_t v = 0;
+				lbug_value_get_int8(&value, &v);
+				values[index] = (uint64_t)(int64_t)v;
+				break;
+			}
+			case LBUG_UINT64: {
+				uint64_t v = 0;
+				lbug_value_get_uint64(&value, &v);
+				values[index] = v;
+				break;
+			}
+			case LBUG_UINT32: {
+				uint32_t v = 0;
+				lbug_value_get_uint32(&value, &v);
+				values[index] = v;
+				break;
+			}
+			case LBUG_UINT16: {
+				uint16_t v = 0;
+				lbug_value_get_uint16(&value, &v);
+				values[index] = v;
+				break;
+			}
+			case LBUG_UINT8: {
+				uint8_t v = 0;
+				lbug_value_get_uint8(&value, &v);
+				values[index] = v;
+				break;
+			}
+			case LBUG_DOUBLE: {
+				double v = 0;
+				lbug_value_get_double(&value, &v);
+				memcpy(&values[index], &v, sizeof(v));
+				break;
+			}
+			case LBUG_FLOAT: {
+				float f = 0;
+				lbug_value_get_float(&value, &f);
+				double v = f;
+				memcpy(&values[index], &v, sizeof(v));
+				break;
+			}
+			default:
+				break;
+			}
+		}
+		lbug_flat_tuple_destroy(&tuple);
+		(*num_rows)++;
+	}
+	return LbugSuccess;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"math"
+	"unsafe"
+)
+
+// DataChunk is a batch of rows of a query result returned by
+// QueryResult.NextChunk. Its values are copied into Go memory, so a DataChunk
+// remains valid after the QueryResult is closed and can be read from several
+// goroutines.
+type DataChunk struct {
+	columnNames []string
+	columns     []chunkColumn
+	numRows     int
+}
+
+// chunkColumn holds the values of a column of a DataChunk. Depending on the
+// type of the column, the values are in int64s (BOOL and the signed integer
+// types), uint64s (the unsigned integer types), float64s (FLOAT and DOUBLE),
+// strings (STRING) or values (all others, converted as by
+// FlatTuple.GetValue).
+type chunkColumn struct {
+	typeID   C.lbug_data_type_id
+	int64s   []int64
+	uint64s  []uint64
+	float64s []float64
+	strings  []string
+	values   []any
+	nulls    []bool
+}
+
+// NumRows returns the number of rows of the chunk.
+func (chunk *DataChunk) NumRows() int {
+	return chunk.numRows
+}
+
+// NumColumns returns the number of columns of the chunk.
+func (chunk *DataChunk) NumColumns() int {
+	return len(chunk.columns)
+}
+
+// ColumnNames returns the names of the columns of the chunk.
+func (chunk *DataChunk) ColumnNames() []string {
+	return chunk.columnNames
+}
+
+// Nulls returns, for every row of the chunk, whether the value of the column
+// is NULL. The slice is owned by the chunk and must not be modified.
+func (chunk *DataChunk) Nulls(col int) []bool {
+	return chunk.columns[col].nulls
+}
+
+// IsNull returns true if the value at the row and column is NULL.
+func (chunk *DataChunk) IsNull(row, col int) bool {
+	return chunk.columns[col].nulls[row]
+}
+
+// Int64Column returns the values of a column of type INT64, SERIAL, INT32,
+// INT16 or INT8, widened to int64. NULL values are 0, see Nulls. The slice is
+// owned by the chunk and must not be modified.
+func (chunk *DataChunk) Int64Column(col int) ([]int64, error) {
+	column, err := chunk.column(col)
+	if err != nil {
+		return nil, err
+	}
+	switch column.typeID {
+	case C.LBUG_INT64, C.LBUG_SERIAL, C.LBUG_INT32, C.LBUG_INT16, C.LBUG_INT8:
+		return column.int64s, nil
+	}
+	return nil, chunk.columnTypeError(col, "a signed integer")
+}
+
+// Uint64Column returns the values of a column of type UINT64, UINT32, UINT16
+// or UINT8, widened to uint64. NULL values are 0, see Nulls. The slice is
+// owned by the chunk and must not be modified.
+func (chunk *DataChunk) Uint64Column(col int) ([]uint64, error) {
+	column, err := chunk.column(col)
+	if err != nil {
+		return nil, err
+	}
+	switch column.typeID {
+	case C.LBUG_UINT64, C.LBUG_UINT32, C.LBUG_UINT16, C.LBUG_UINT8:
+		return column.uint64s, nil
+	}
+	return nil, chunk.columnTypeError(col, "an unsigned integer")
+}
+
+// Float64Column returns the values of a column of type DOUBLE or FLOAT,
+// widened to float64. NULL values are 0, see Nulls. The slice is owned by the
+// chunk and must not be modified.
+func (chunk *DataChunk) Float64Column(col int) ([]float64, error) {
+	column, err := chunk.column(col)
+	if err != nil {
+		return nil, err
+	}
+	if column.typeID != C.LBUG_DOUBLE && column.typeID != C.LBUG_FLOAT {
+		return nil, chunk.columnTypeError(col, "a floating point")
+	}
+	return column.float64s, nil
+}
+
+// BoolColumn returns the values of a column of type BOOL. NULL values are
+// false, see Nulls.
+func (chunk *DataChunk) BoolColumn(col int) ([]bool, error) {
+	column, err := chunk.column(col)
+	if err != nil {
+		return nil, err
+	}
+	if column.typeID != C.LBUG_BOOL {
+		return nil, chunk.columnTypeError(col, "a BOOL")
+	}
+	values := make([]bool, len(column.int64s))
+	for i, value := range column.int64s {
+		values[i] = value != 0
+	}
+	return values, nil
+}
+
+// StringColumn returns the values of a column of type STRING. NULL values are
+// empty, see Nulls. The slice is owned by the chunk and must not be modified.
+func (chunk *DataChunk) StringColumn(col int) ([]string, error) {
+	column, err := chunk.column(col)
+	if err != nil {
+		return nil, err
+	}
+	if column.typeID != C.LBUG_STRING {
+		return nil, chunk.columnTypeError(col, "a STRING")
+	}
+	return column.strings, nil
+}
+
+// Value returns the value at the row and column, of the same Go type as
+// returned by FlatTuple.GetValue, or nil if it is NULL.
+func (chunk *DataChunk) Value(row, col int) any {
+	column := &chunk.columns[col]
+	if column.nulls[row] {
+		return nil
+	}
+	switch column.typeID {
+	case C.LBUG_BOOL:
+		return column.int64s[row] != 0
+	case C.LBUG_INT64, C.LBUG_SERIAL:
+		return column.int64s[row]
+	case C.LBUG_INT32:
+		return int32(column.int64s[row])
+	case C.LBUG_INT16:
+		return int16(column.int64s[row])
+	case C.LBUG_INT8:
+		return int8(column.int64s[row])
+	case C.LBUG_UINT64:
+		return column.uint64s[row]
+	case C.LBUG_UINT32:
+		return uint32(column.uint64s[row])
+	case C.LBUG_UINT16:
+		return uint16(column.uint64s[row])
+	case C.LBUG_UINT8:
+		return uint8(column.uint64s[row])
+	case C.LBUG_DOUBLE:
+		return column.float64s[row]
+	case C.LBUG_FLOAT:
+		return float32(column.float64s[row])
+	case C.LBUG_STRING:
+		return column.strings[row]
+	}
+	return column.values[row]
+}
+
+// column returns the column at the index, or an error wrapping
+// ErrColumnIndexOutOfRange.
+func (chunk *DataChunk) column(col int) (*chunkColumn, error) {
+	if col < 0 || col >= len(chunk.columns) {
+		return nil, fmt.Errorf("%w: column %d of a chunk with %d columns", ErrColumnIndexOutOfRange, col, len(chunk.columns))
+	}
+	return &chunk.columns[col], nil
+}
+
+// columnTypeError returns the error of a typed accessor called for a column
+// of another type.
+func (chunk *DataChunk) columnTypeError(col int, kind string) error {
+	return fmt.Errorf("column %d (%s) is not %s column", col, chunk.columnNames[col], kind)
+}
+
+// NextChunk fetches up to maxRows of the next tuples of the result into a
+// DataChunk, which is empty if there are no more tuples. Fetching a chunk
+// costs one call into the C API per tuple and column rather than several, and
+// if all columns have BOOL, integer or floating point types, the whole chunk
+// is fetched with a single call. NextChunk and Next can be mixed, each
+// continuing where the other stopped, and the row limit set with
+// Connection.SetMaxRows applies as for Next.
+func (queryResult *QueryResult) NextChunk(maxRows int) (*DataChunk, error) {
+	if maxRows <= 0 {
+		return nil, &Error{Op: OpIterate, Message: fmt.Sprintf("invalid chunk size %d: must be positive", maxRows)}
+	}
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	if queryResult.isClosed {
+		return nil, &Error{Op: OpIterate, Err: &closedError{"failed to get next chunk because the query result is closed"}}
+	}
+	if err := queryResult.checkInvalidated(); err != nil {
+		return nil, &Error{Op: OpIterate, Err: err}
+	}
+	if err := queryResult.checkRowLimit(); err != nil {
+		return nil, err
+	}
+	// Bound the buffers by the number of tuples left.
+	if numTuples := uint64(C.lbug_query_result_get_num_tuples(&queryResult.cQueryResult)); queryResult.numFetched <= numTuples {
+		maxRows = int(min(uint64(maxRows), numTuples-queryResult.numFetched))
+	}
+	if queryResult.maxRows > 0 {
+		maxRows = int(min(uint64(maxRows), queryResult.maxRows-queryResult.numFetched))
+	}
+	chunk := &DataChunk{columnNames: queryResult.getColumnNames()}
+	chunk.columns = make([]chunkColumn, len(chunk.columnNames))
+	fixedWidth := true
+	for i := range chunk.columns {
+		var logicalType C.lbug_logical_type
+		C.lbug_query_result_get_column_data_type(&queryResult.cQueryResult, C.uint64_t(i), &logicalType)
+		chunk.columns[i].typeID = C.lbug_data_type_get_id(&logicalType)
+		C.lbug_data_type_destroy(&logicalType)
+		fixedWidth = fixedWidth && bool(C.lbug_go_is_fixed_width(chunk.columns[i].typeID))
+	}
+	var err error
+	if fixedWidth && len(chunk.columns) > 0 {
+		err = queryResult.fetchFixedWidth(chunk, maxRows)
+	} else {
+		err = queryResult.fetchRows(chunk, maxRows)
+	}
+	if chunk.numRows > 0 {
+		stats := queryResult.connection.stats()
+		stats.tuplesFetched.Add(uint64(chunk.numRows))
+		queryResult.hasFetched = true
+		queryResult.numFetched += uint64(chunk.numRows)
+	}
+	return chunk, err
+}
+
+// fetchFixedWidth fetches up to maxRows tuples into the chunk, whose columns
+// all have fixed-width types, with lbug_go_fetch_fixed_width.
+func (queryResult *QueryResult) fetchFixedWidth(chunk *DataChunk, maxRows int) error {
+	numColumns := len(chunk.columns)
+	types := make([]C.lbug_data_type_id, numColumns)
+	for i := range chunk.columns {
+		types[i] = chunk.columns[i].typeID
+	}
+	// The buffers are allocated in C memory, since C must not keep pointers to
+	// Go memory and the call does not return until they are filled.
+	cTypes := (*C.lbug_data_type_id)(C.malloc(C.size_t(numColumns) * C.size_t(unsafe.Sizeof(types[0]))))
+	defer C.free(unsafe.Pointer(cTypes))
+	copy(unsafe.Slice(cTypes, numColumns), types)
+	cValues := (*C.uint64_t)(C.malloc(C.size_t(numColumns*maxRows) * C.size_t(unsafe.Sizeof(C.uint64_t(0)))))
+	defer C.free(unsafe.Pointer(cValues))
+	cNulls := (*C.bool)(C.malloc(C.size_t(numColumns * maxRows)))
+	defer C.free(unsafe.Pointer(cNulls))
+
+	var numRows C.uint64_t
+	queryResult.connection.countCgoCall(cgoNext)
+	if err := queryResult.connection.checkConnection(HandleQueryResult); err != nil {
+		return &Error{Op: OpIterate, Err: err}
+	}
+	fetch := func() C.lbug_state {
+		return C.lbug_go_fetch_fixed_width(&queryResult.cQueryResult, C.uint64_t(maxRows), C.uint64_t(numColumns),
+			cTypes, cValues, cNulls, &numRows)
+	}
+	var status C.lbug_state
+	if profiler := queryResult.connection.profiler; profiler != nil {
+		profiler.run(profileDecode, queryResult.profileHash, func() { status = fetch() })
+	} else {
+		status = fetch()
+	}
+	chunk.numRows = int(numRows)
+	values := unsafe.Slice(cValues, numColumns*maxRows)
+	nulls := unsafe.Slice(cNulls, numColumns*maxRows)
+	for i := range chunk.columns {
+		column := &chunk.columns[i]
+		columnValues := values[i*maxRows : i*maxRows+chunk.numRows]
+		column.nulls = make([]bool, chunk.numRows)
+		for row, null := range nulls[i*maxRows : i*maxRows+chunk.numRows] {
+			column.nulls[row] = bool(null)
+		}
+		switch column.typeID {
+		case C.LBUG_UINT64, C.LBUG_UINT32, C.LBUG_UINT16, C.LBUG_UINT8:
+			column.uint64s = make([]uint64, chunk.numRows)
+			for row, value := range columnValues {
+				column.uint64s[row] = uint64(value)
+			}
+		case C.LBUG_DOUBLE, C.LBUG_FLOAT:
+			column.float64s = make([]float64, chunk.numRows)
+			for row, value := range columnValues {
+				column.float64s[row] = math.Float64frombits(uint64(value))
+			}
+		default:
+			column.int64s = make([]int64, chunk.numRows)
+			for row, value := range columnValues {
+				column.int64s[row] = int64(value)
+			}
+		}
+	}
+	if status != C.LbugSuccess {
+		queryResult.connection.lastCallFailed.Store(true)
+		return &Error{Op: OpIterate, Message: fmt.Sprintf("failed to get next chunk with status %d", status)}
+	}
+	return nil
+}
+
+// fetchRows fetches up to maxRows tuples into the chunk one at a time,
+// converting the values of the columns that do not have fixed-width types
+// with lbugValueToGoValue.
+func (queryResult *QueryResult) fetchRows(chunk *DataChunk, maxRows int) error {
+	for i := range chunk.columns {
+		column := &chunk.columns[i]
+		column.nulls = make([]bool, 0, maxRows)
+		switch column.typeID {
+		case C.LBUG_UINT64, C.LBUG_UINT32, C.LBUG_UINT16, C.LBUG_UINT8:
+			column.uint64s = make([]uint64, 0, maxRows)
+		case C.LBUG_DOUBLE, C.LBUG_FLOAT:
+			column.float64s = make([]float64, 0, maxRows)
+		case C.LBUG_BOOL, C.LBUG_INT64, C.LBUG_SERIAL, C.LBUG_INT32, C.LBUG_INT16, C.LBUG_INT8:
+			column.int64s = make([]int64, 0, maxRows)
+		case C.LBUG_STRING:
+			column.strings = make([]string, 0, maxRows)
+		default:
+			column.values = make([]any, 0, maxRows)
+		}
+	}
+	for chunk.numRows < maxRows {
+		queryResult.connection.countCgoCall(cgoNext)
+		if err := queryResult.connection.checkConnection(HandleQueryResult); err != nil {
+			return &Error{Op: OpIterate, Err: err}
+		}
+		if !bool(C.lbug_query_result_has_next(&queryResult.cQueryResult)) {
+			return nil
+		}
+		var cFlatTuple C.lbug_flat_tuple
+		queryResult.connection.countCgoCall(cgoNext)
+		if status := queryResult.fetchNext(&cFlatTuple); status != C.LbugSuccess {
+			queryResult.connection.lastCallFailed.Store(true)
+			return &Error{Op: OpIterate, Message: fmt.Sprintf("failed to get next chunk with status %d", status)}
+		}
+		err := queryResult.appendRow(chunk, &cFlatTuple)
+		queryResult.connection.countCgoCall(cgoClose)
+		C.lbug_flat_tuple_destroy(&cFlatTuple)
+		if err != nil {
+			return err
+		}
+		chunk.numRows++
+	}
+	return nil
+}
+
+// appendRow appends the values of the C flat tuple to the columns of the
+// chunk. The values are only appended if all of them are converted.
+func (queryResult *QueryResult) appendRow(chunk *DataChunk, cFlatTuple *C.lbug_flat_tuple) error {
+	values := make([]any, len(chunk.columns))
+	for i := range chunk.columns {
+		var cValue C.lbug_value
+		queryResult.connection.countCgoCall(cgoGetValue)
+		if status := C.lbug_flat_tuple_get_value(cFlatTuple, C.uint64_t(i), &cValue); status != C.LbugSuccess {
+			return conversionError(uint64(i), fmt.Errorf("failed to get value with status: %d", status))
+		}
+		value, err := lbugValueToGoValue(cValue, &queryResult.converter)
+		if err != nil {
+			return conversionError(uint64(i), err)
+		}
+		values[i] = value
+	}
+	for i, value := range values {
+		column := &chunk.columns[i]
+		column.nulls = append(column.nulls, value == nil)
+		switch column.typeID {
+		case C.LBUG_BOOL:
+			var i int64
+			if value == true {
+				i = 1
+			}
+			column.int64s = append(column.int64s, i)
+		case C.LBUG_INT64, C.LBUG_SERIAL, C.LBUG_INT32, C.LBUG_INT16, C.LBUG_INT8:
+			column.int64s = append(column.int64s, signedInteger(value))
+		case C.LBUG_UINT64, C.LBUG_UINT32, C.LBUG_UINT16, C.LBUG_UINT8:
+			column.uint64s = append(column.uint64s, unsignedInteger(value))
+		case C.LBUG_DOUBLE:
+			f, _ := value.(float64)
+			column.float64s = append(column.float64s, f)
+		case C.LBUG_FLOAT:
+			f, _ := value.(float32)
+			column.float64s = append(column.float64s, float64(f))
+		case C.LBUG_STRING:
+			s, _ := value.(string)
+			column.strings = append(column.strings, s)
+		default:
+			column.values = append(column.values, value)
+		}
+	}
+	return nil
+}
+
+// signedInteger widens a value converted from a signed integer type, or
+// returns 0 for NULL.
+func signedInteger(value any) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int8:
+		return int64(v)
+	}
+	return 0
+}
+
+// unsignedInteger widens a value converted from an unsigned integer type, or
+// returns 0 for NULL.
+func unsignedInteger(value any) uint64 {
+	switch v := value.(type) {
+	case uint64:
+		return v
+	case uint32:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint8:
+		return uint64(v)
+	}
+	return 0
+}
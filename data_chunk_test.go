@@ -0,0 +1,179 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextChunkFixedWidth(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	conn.SetCgoCallCounting(true)
+	result, err := conn.Query("UNWIND range(1, 10) AS i RETURN i, CAST(i AS INT32), CAST(i AS UINT8), i * 1.5, i % 2 = 0, CASE WHEN i = 3 THEN NULL ELSE i END AS n ORDER BY i;")
+	assert.Nil(t, err)
+	defer result.Close()
+
+	var sizes []int
+	var ids []int64
+	for result.HasNext() {
+		chunk, err := result.NextChunk(4)
+		assert.Nil(t, err)
+		sizes = append(sizes, chunk.NumRows())
+		column, err := chunk.Int64Column(0)
+		assert.Nil(t, err)
+		ids = append(ids, column...)
+		if len(sizes) == 1 {
+			assert.Equal(t, 6, chunk.NumColumns())
+			assert.Equal(t, "n", chunk.ColumnNames()[5])
+			assert.Equal(t, int32(2), chunk.Value(1, 1))
+			unsigned, err := chunk.Uint64Column(2)
+			assert.Nil(t, err)
+			assert.Equal(t, []uint64{1, 2, 3, 4}, unsigned)
+			floats, err := chunk.Float64Column(3)
+			assert.Nil(t, err)
+			assert.Equal(t, []float64{1.5, 3, 4.5, 6}, floats)
+			bools, err := chunk.BoolColumn(4)
+			assert.Nil(t, err)
+			assert.Equal(t, []bool{false, true, false, true}, bools)
+			assert.Equal(t, []bool{false, false, true, false}, chunk.Nulls(5))
+			assert.Nil(t, chunk.Value(2, 5))
+			assert.Equal(t, int64(4), chunk.Value(3, 5))
+			_, err = chunk.StringColumn(0)
+			assert.ErrorContains(t, err, "column 0 (i) is not a STRING column")
+			_, err = chunk.Int64Column(6)
+			assert.ErrorIs(t, err, ErrColumnIndexOutOfRange)
+		}
+	}
+	assert.Equal(t, []int{4, 4, 2}, sizes)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, ids)
+	// Every chunk of fixed-width columns is fetched with a single call.
+	assert.Equal(t, uint64(3+4), conn.Stats().CgoCalls.Next)
+	assert.Equal(t, uint64(0), conn.Stats().CgoCalls.GetValue)
+
+	chunk, err := result.NextChunk(4)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, chunk.NumRows())
+}
+
+func TestNextChunkMixedTypes(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	result, err := conn.Query("UNWIND [1, 2, 3] AS i RETURN i, 'v' + CAST(i AS STRING) AS s, [i, i] AS l, CAST(NULL AS STRING) AS e ORDER BY i;")
+	assert.Nil(t, err)
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+	tuple.Close()
+	chunk, err := result.NextChunk(10)
+	assert.Nil(t, err)
+	result.Close()
+
+	// The chunk stays valid after the result is closed.
+	assert.Equal(t, 2, chunk.NumRows())
+	ids, err := chunk.Int64Column(0)
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{2, 3}, ids)
+	strings, err := chunk.StringColumn(1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"v2", "v3"}, strings)
+	assert.Equal(t, []any{int64(3), int64(3)}, chunk.Value(1, 2))
+	assert.Equal(t, []bool{true, true}, chunk.Nulls(3))
+	assert.Nil(t, chunk.Value(0, 3))
+}
+
+func TestNextChunkRowLimit(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	conn.SetMaxRows(5)
+	result, err := conn.Query("UNWIND range(1, 10) AS i RETURN i;")
+	assert.Nil(t, err)
+	defer result.Close()
+	chunk, err := result.NextChunk(100)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, chunk.NumRows())
+	_, err = result.NextChunk(100)
+	var limitErr *RowLimitError
+	assert.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, uint64(10), limitErr.Rows)
+}
+
+func TestNextChunkErrors(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	result, err := conn.Query("RETURN 1;")
+	assert.Nil(t, err)
+	_, err = result.NextChunk(0)
+	assert.ErrorContains(t, err, "invalid chunk size 0")
+	result.Close()
+	_, err = result.NextChunk(1)
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+// benchmarkIterationTable returns a connection to a database with a table of
+// 1M nodes.
+func benchmarkIterationTable(b *testing.B) *Connection {
+	b.Helper()
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(db.Close)
+	conn, err := OpenConnection(db)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(conn.Close)
+	for _, query := range []string{
+		"CREATE NODE TABLE item(id INT64, score DOUBLE, PRIMARY KEY(id));",
+		"UNWIND range(1, 1000000) AS i CREATE (:item {id: i, score: i / 3.0});",
+	} {
+		result, err := conn.Query(query)
+		if err != nil {
+			b.Fatal(err)
+		}
+		result.Close()
+	}
+	return conn
+}
+
+const benchmarkIterationQuery = "MATCH (n:item) RETURN n.id, n.score;"
+
+func BenchmarkIterate_Rows(b *testing.B) {
+	conn := benchmarkIterationTable(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result, err := conn.Query(benchmarkIterationQuery)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var sum float64
+		for result.HasNext() {
+			tuple, _ := result.Next()
+			id, _ := tuple.GetValue(0)
+			score, _ := tuple.GetValue(1)
+			sum += float64(id.(int64)) + score.(float64)
+			tuple.Close()
+		}
+		result.Close()
+	}
+}
+
+func BenchmarkIterate_Chunks(b *testing.B) {
+	conn := benchmarkIterationTable(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result, err := conn.Query(benchmarkIterationQuery)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var sum float64
+		for result.HasNext() {
+			chunk, err := result.NextChunk(2048)
+			if err != nil {
+				b.Fatal(err)
+			}
+			ids, _ := chunk.Int64Column(0)
+			scores, _ := chunk.Float64Column(1)
+			for row := range ids {
+				sum += float64(ids[row]) + scores[row]
+			}
+		}
+		result.Close()
+	}
+}
@@ -0,0 +1,97 @@
+package lbug
+
+// #include "lbug.h"
+import "C"
+
+import "fmt"
+
+// DataType is the logical type of a column of a QueryResult.
+type DataType struct {
+	// TypeID is the logical type id of the type in the C API.
+	TypeID int
+	// Name is the Cypher name of the type without its parameters, e.g.
+	// "INT64", "LIST" for STRING[] or "DECIMAL" for DECIMAL(18, 3). The C API
+	// does not expose the element types of nested types.
+	Name string
+}
+
+// dataTypeNames are the Cypher names of the logical type ids.
+var dataTypeNames = map[C.lbug_data_type_id]string{
+	C.LBUG_ANY:           "ANY",
+	C.LBUG_NODE:          "NODE",
+	C.LBUG_REL:           "REL",
+	C.LBUG_RECURSIVE_REL: "RECURSIVE_REL",
+	C.LBUG_SERIAL:        "SERIAL",
+	C.LBUG_BOOL:          "BOOL",
+	C.LBUG_INT64:         "INT64",
+	C.LBUG_INT32:         "INT32",
+	C.LBUG_INT16:         "INT16",
+	C.LBUG_INT8:          "INT8",
+	C.LBUG_UINT64:        "UINT64",
+	C.LBUG_UINT32:        "UINT32",
+	C.LBUG_UINT16:        "UINT16",
+	C.LBUG_UINT8:         "UINT8",
+	C.LBUG_INT128:        "INT128",
+	C.LBUG_DOUBLE:        "DOUBLE",
+	C.LBUG_FLOAT:         "FLOAT",
+	C.LBUG_DATE:          "DATE",
+	C.LBUG_TIMESTAMP:     "TIMESTAMP",
+	C.LBUG_TIMESTAMP_SEC: "TIMESTAMP_SEC",
+	C.LBUG_TIMESTAMP_MS:  "TIMESTAMP_MS",
+	C.LBUG_TIMESTAMP_NS:  "TIMESTAMP_NS",
+	C.LBUG_TIMESTAMP_TZ:  "TIMESTAMP_TZ",
+	C.LBUG_INTERVAL:      "INTERVAL",
+	C.LBUG_DECIMAL:       "DECIMAL",
+	C.LBUG_INTERNAL_ID:   "INTERNAL_ID",
+	C.LBUG_STRING:        "STRING",
+	C.LBUG_BLOB:          "BLOB",
+	C.LBUG_LIST:          "LIST",
+	C.LBUG_ARRAY:         "ARRAY",
+	C.LBUG_STRUCT:        "STRUCT",
+	C.LBUG_MAP:           "MAP",
+	C.LBUG_UNION:         "UNION",
+	C.LBUG_POINTER:       "POINTER",
+	C.LBUG_UUID:          "UUID",
+}
+
+// newDataType returns the DataType of a logical type id. Ids unknown to the
+// bindings are named after their number, e.g. "TYPE_60".
+func newDataType(typeID C.lbug_data_type_id) DataType {
+	name, ok := dataTypeNames[typeID]
+	if !ok {
+		name = fmt.Sprintf("TYPE_%d", int(typeID))
+	}
+	return DataType{TypeID: int(typeID), Name: name}
+}
+
+// isKnown returns true if the type id is known to the bindings.
+func (dataType DataType) isKnown() bool {
+	_, ok := dataTypeNames[C.lbug_data_type_id(dataType.TypeID)]
+	return ok
+}
+
+// GetColumnDataTypes returns the logical types of the columns of the
+// QueryResult, in the order of GetColumnNames. It returns nil if the
+// QueryResult is closed.
+func (queryResult *QueryResult) GetColumnDataTypes() []DataType {
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	return queryResult.getColumnDataTypes()
+}
+
+// getColumnDataTypes returns the logical types of the columns. closeMutex of
+// the connection must be held.
+func (queryResult *QueryResult) getColumnDataTypes() []DataType {
+	if queryResult.isClosed {
+		return nil
+	}
+	numColumns := uint64(C.lbug_query_result_get_num_columns(&queryResult.cQueryResult))
+	types := make([]DataType, numColumns)
+	for i := uint64(0); i < numColumns; i++ {
+		var logicalType C.lbug_logical_type
+		C.lbug_query_result_get_column_data_type(&queryResult.cQueryResult, C.uint64_t(i), &logicalType)
+		types[i] = newDataType(C.lbug_data_type_get_id(&logicalType))
+		C.lbug_data_type_destroy(&logicalType)
+	}
+	return types
+}
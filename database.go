@@ -6,7 +6,10 @@ package lbug
 // #include <stdlib.h>
 import "C"
 import (
+	"errors"
 	"fmt"
+	"runtime"
+	"sync"
 	"unsafe"
 )
 
@@ -16,12 +19,20 @@ import (
 // EnableCompression is a boolean flag to enable or disable compression.
 // ReadOnly is a boolean flag to open the database in read-only mode.
 // MaxDbSize is the maximum size of the database in bytes.
+// ExclusiveOpen is a boolean flag to make OpenDatabase fail with ErrAlreadyOpen
+// instead of sharing the database when it is already open in the process.
+// OnFatal, if set, is called once with the first error matching
+// ErrStorageCorrupted or ErrStorageUnavailable returned by an operation on the
+// database, after which the database refuses new connections. It is called
+// synchronously by the operation that failed, so it should not block.
 type SystemConfig struct {
 	BufferPoolSize    uint64
 	MaxNumThreads     uint64
 	EnableCompression bool
 	ReadOnly          bool
 	MaxDbSize         uint64
+	ExclusiveOpen     bool
+	OnFatal           func(error)
 }
 
 // DefaultSystemConfig returns the default system configuration.
@@ -42,6 +53,78 @@ func DefaultSystemConfig() SystemConfig {
 	}
 }
 
+// SystemConfigLowMemory returns a system configuration for memory-constrained
+// environments: a 64 MiB buffer pool, a single thread and compression enabled.
+func SystemConfigLowMemory() SystemConfig {
+	config := DefaultSystemConfig()
+	config.BufferPoolSize = 64 * 1024 * 1024
+	config.MaxNumThreads = 1
+	config.EnableCompression = true
+	return config
+}
+
+// SystemConfigAnalytics returns a system configuration for analytical
+// workloads: the default buffer pool (80% of the system memory), one thread per
+// CPU core and compression enabled.
+func SystemConfigAnalytics() SystemConfig {
+	config := DefaultSystemConfig()
+	config.MaxNumThreads = uint64(runtime.NumCPU())
+	config.EnableCompression = true
+	return config
+}
+
+// SystemConfigEmbeddedTest returns a system configuration for tests, typically
+// used with OpenInMemoryDatabase: a 16 MiB buffer pool and a single thread.
+func SystemConfigEmbeddedTest() SystemConfig {
+	config := DefaultSystemConfig()
+	config.BufferPoolSize = 16 * 1024 * 1024
+	config.MaxNumThreads = 1
+	return config
+}
+
+// Validate checks the system configuration for invalid combinations of
+// values and returns an error describing every problem found. A zero
+// BufferPoolSize, MaxNumThreads or MaxDbSize lets the engine choose the value.
+// Validate is called by OpenDatabase.
+func (config SystemConfig) Validate() error {
+	var problems []error
+	if config.MaxDbSize != 0 && config.BufferPoolSize > config.MaxDbSize {
+		problems = append(problems, fmt.Errorf("BufferPoolSize (%d bytes) is larger than MaxDbSize (%d bytes)", config.BufferPoolSize, config.MaxDbSize))
+	}
+	if config.MaxNumThreads > maxNumThreads {
+		problems = append(problems, fmt.Errorf("MaxNumThreads (%d) exceeds the maximum of %d", config.MaxNumThreads, maxNumThreads))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid system config: %w", errors.Join(problems...))
+	}
+	return nil
+}
+
+// maxNumThreads is the upper bound accepted by Validate for MaxNumThreads.
+const maxNumThreads = 1 << 16
+
+// SetBufferPoolSizeString sets BufferPoolSize from a human-readable byte size
+// such as "4GiB" or "512MB". See ParseByteSize for the accepted formats.
+func (config *SystemConfig) SetBufferPoolSizeString(size string) error {
+	bytes, err := ParseByteSize(size)
+	if err != nil {
+		return err
+	}
+	config.BufferPoolSize = bytes
+	return nil
+}
+
+// SetMaxDbSizeString sets MaxDbSize from a human-readable byte size such as
+// "1TiB". See ParseByteSize for the accepted formats.
+func (config *SystemConfig) SetMaxDbSizeString(size string) error {
+	bytes, err := ParseByteSize(size)
+	if err != nil {
+		return err
+	}
+	config.MaxDbSize = bytes
+	return nil
+}
+
 // toC converts the SystemConfig Go struct to the C struct.
 func (config SystemConfig) toC() C.lbug_system_config {
 	cSystemConfig := C.lbug_default_system_config()
@@ -56,34 +139,143 @@ func (config SystemConfig) toC() C.lbug_system_config {
 // Database represents a Lbug database instance.
 type Database struct {
 	cDatabase C.lbug_database
+	shared    *sharedDatabase
 	isClosed  bool
+	inMemory  bool
+	handleID  uint64
+	stats     databaseStats
+	health    *databaseHealth
+	snapshot  *snapshotCounter
+	// singletonPath is the key of the database in singletonDatabases if it
+	// was opened with OpenShared.
+	singletonPath string
+	// capabilities caches the result of Capabilities.
+	capabilities capabilityCache
+	// closeMutex is held for reading while connections are opened and for
+	// writing while the database is closed.
+	closeMutex sync.RWMutex
+	// closing runs the close of CloseWithContext in the background.
+	closing backgroundClose
+	// batches is held while a batch is applied with IdempotencyOptions.
+	batches *sync.Mutex
+	// writer holds a value while a write Transaction is in progress on a
+	// connection of the database.
+	writer chan struct{}
 }
 
 // OpenDatabase opens a Lbug database at the given path with the given system configuration.
+//
+// Opening the same on-disk database more than once in a process is not
+// supported by the engine, so OpenDatabase keeps track of the databases open
+// in the process by their absolute path with symbolic links resolved. If the
+// database is already open, the new Database shares the existing engine
+// instance, and OpenDatabase returns an error matching ErrConfigMismatch that
+// lists the differing fields if the system configuration differs from the
+// one the database was opened with, as OpenShared does. The engine instance
+// is destroyed when the last Database sharing it is closed. If ExclusiveOpen
+// is set on either handle, OpenDatabase returns ErrAlreadyOpen instead.
+//
+// The path ":memory:", compared case-insensitively and ignoring surrounding
+// whitespace, opens an in-memory database, which is never shared. An empty
+// path is rejected with ErrEmptyPath; any other path, such as "memory:", is a
+// filesystem path.
 func OpenDatabase(path string, systemConfig SystemConfig) (*Database, error) {
 	db := &Database{}
+	if err := systemConfig.Validate(); err != nil {
+		return db, &Error{Op: OpOpen, Err: err}
+	}
+	canonicalPath, err := canonicalDatabasePath(path)
+	if errors.Is(err, ErrEmptyPath) {
+		return db, &Error{Op: OpOpen, Message: "failed to open database", Err: err}
+	}
+	if err != nil {
+		return db, &Error{Op: OpOpen, Message: "failed to resolve database path " + path, Err: err}
+	}
+	if isInMemoryPath(path) {
+		path = inMemoryPath
+		db.inMemory = true
+	}
+	var state *sharedDatabase
+	if canonicalPath != "" {
+		state, err = openSharedDatabase(canonicalPath, path, systemConfig)
+	} else {
+		state, err = initDatabase(path, systemConfig)
+	}
+	if err != nil {
+		return db, err
+	}
+	if canonicalPath != "" {
+		db.shared = state
+	}
+	db.cDatabase = state.cDatabase
+	db.health = state.health
+	db.snapshot = state.snapshot
+	db.batches = state.batches
+	db.writer = state.writer
+	db.handleID = handles.register(HandleDatabase, db)
+	return db, nil
+}
+
+// initDatabase initializes the C database at the path and returns it with the
+// state shared by its Database handles.
+func initDatabase(path string, systemConfig SystemConfig) (*sharedDatabase, error) {
 	cPath := C.CString(path)
 	defer C.free(unsafe.Pointer(cPath))
-	cSystemConfig := systemConfig.toC()
-	status := C.lbug_database_init(cPath, cSystemConfig, &db.cDatabase)
+	state := &sharedDatabase{
+		refs:      1,
+		exclusive: systemConfig.ExclusiveOpen,
+		config:    systemConfig,
+		health:    &databaseHealth{onFatal: systemConfig.OnFatal},
+		snapshot:  &snapshotCounter{},
+		batches:   &sync.Mutex{},
+		writer:    make(chan struct{}, 1),
+	}
+	status := C.lbug_database_init(cPath, systemConfig.toC(), &state.cDatabase)
 	if status != C.LbugSuccess {
-		return db, fmt.Errorf("failed to open database with status %d", status)
+		return nil, &Error{Op: OpOpen, Message: fmt.Sprintf("failed to open database with status %d", status)}
 	}
-	return db, nil
+	return state, nil
 }
 
 // OpenInMemoryDatabase opens a Lbug database in in-memory mode with the given system configuration.
 func OpenInMemoryDatabase(systemConfig SystemConfig) (*Database, error) {
-	return OpenDatabase(":memory:", systemConfig)
+	return OpenDatabase(inMemoryPath, systemConfig)
+}
+
+// IsInMemory returns true if the database was opened in in-memory mode.
+func (db *Database) IsInMemory() bool {
+	return db.inMemory
+}
+
+// closed returns true if the database has been closed.
+func (db *Database) closed() bool {
+	db.closeMutex.RLock()
+	defer db.closeMutex.RUnlock()
+	return db.isClosed
+}
+
+// sameDatabase returns true if the handles refer to the same C database,
+// which Database handles opened with the same path share.
+func (db *Database) sameDatabase(other *Database) bool {
+	return db == other || (db.shared != nil && db.shared == other.shared)
 }
 
 // Close releases the underlying C resources for the database.
 // MUST be called when done to prevent resource leaks.
 // Use defer to ensure cleanup: defer db.Close()
+// If the database is shared with other Database handles of the process, the
+// underlying C resources are released when the last of them is closed.
 func (db *Database) Close() {
+	db.closeMutex.Lock()
+	defer db.closeMutex.Unlock()
 	if db.isClosed {
 		return
 	}
-	C.lbug_database_destroy(&db.cDatabase)
+	if db.shared != nil {
+		db.shared.release()
+	} else {
+		C.lbug_database_destroy(&db.cDatabase)
+	}
+	handles.unregister(HandleDatabase, db.handleID)
 	db.isClosed = true
 }
@@ -0,0 +1,254 @@
+package lbug
+
+// #include "lbug.h"
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrAlreadyOpen is returned by OpenDatabase when the database at the path is
+// already open in the process and either the new or the existing handle was
+// opened with SystemConfig.ExclusiveOpen.
+var ErrAlreadyOpen = errors.New("database is already open in this process")
+
+// ErrConfigMismatch is returned by OpenDatabase and OpenShared when the
+// database is already open with a different system configuration.
+var ErrConfigMismatch = errors.New("database is already open with a different system configuration")
+
+// ErrEmptyPath is returned by OpenDatabase when the path is empty.
+var ErrEmptyPath = errors.New("database path is empty")
+
+// inMemoryPath is the path of in-memory databases.
+const inMemoryPath = ":memory:"
+
+// isInMemoryPath returns true if the path denotes an in-memory database, which
+// is the case for ":memory:" in any case and with surrounding whitespace.
+func isInMemoryPath(path string) bool {
+	return strings.EqualFold(strings.TrimSpace(path), inMemoryPath)
+}
+
+// sharedDatabase is the state of a C database shared by its Database handles:
+// an on-disk database may be opened by several handles of the process, and an
+// in-memory one by a single handle.
+type sharedDatabase struct {
+	cDatabase C.lbug_database
+	path      string
+	refs      int
+	exclusive bool
+	config    SystemConfig
+	// busy is set while the C database is initialized or destroyed, which is
+	// done without holding the lock of openDatabases, and closed once it is
+	// done, so that the openers of the path wait for it.
+	busy     chan struct{}
+	health   *databaseHealth
+	snapshot *snapshotCounter
+	batches  *sync.Mutex
+	writer   chan struct{}
+}
+
+// openDatabases maps the canonical paths of the on-disk databases open in the
+// process to their shared state. The C database is destroyed when the last
+// handle referencing it is closed.
+var openDatabases = struct {
+	sync.Mutex
+	byPath map[string]*sharedDatabase
+}{byPath: make(map[string]*sharedDatabase)}
+
+// canonicalDatabasePath returns the absolute path of a database with symbolic
+// links resolved, so that different paths to the same database map to the
+// same key. If the database does not exist yet, the links in its parent
+// directory are resolved. It returns an empty string for in-memory databases
+// and ErrEmptyPath for an empty or blank path.
+func canonicalDatabasePath(path string) (string, error) {
+	if isInMemoryPath(path) {
+		return "", nil
+	}
+	if strings.TrimSpace(path) == "" {
+		return "", ErrEmptyPath
+	}
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(absolute); err == nil {
+		return resolved, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(absolute))
+	if err != nil {
+		// Let the engine report that the directory does not exist.
+		return absolute, nil
+	}
+	return filepath.Join(parent, filepath.Base(absolute)), nil
+}
+
+// openSharedDatabase adds a reference to the database open at the canonical
+// path, or initializes it at path if it is not open.
+func openSharedDatabase(canonicalPath string, path string, systemConfig SystemConfig) (*sharedDatabase, error) {
+	openDatabases.Lock()
+	for {
+		shared, ok := openDatabases.byPath[canonicalPath]
+		if !ok {
+			break
+		}
+		if busy := shared.busy; busy != nil {
+			openDatabases.Unlock()
+			<-busy
+			openDatabases.Lock()
+			continue
+		}
+		err := shared.join(path, systemConfig)
+		openDatabases.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		return shared, nil
+	}
+	busy := make(chan struct{})
+	openDatabases.byPath[canonicalPath] = &sharedDatabase{path: canonicalPath, busy: busy}
+	openDatabases.Unlock()
+	shared, err := initDatabase(path, systemConfig)
+	openDatabases.Lock()
+	defer openDatabases.Unlock()
+	close(busy)
+	if err != nil {
+		delete(openDatabases.byPath, canonicalPath)
+		return nil, err
+	}
+	shared.path = canonicalPath
+	openDatabases.byPath[canonicalPath] = shared
+	return shared, nil
+}
+
+// join adds a reference to the shared database for a Database opened at path
+// with the system configuration, unless either is exclusive or their system
+// configurations differ. openDatabases must be locked.
+func (shared *sharedDatabase) join(path string, systemConfig SystemConfig) error {
+	if shared.exclusive || systemConfig.ExclusiveOpen {
+		return &Error{Op: OpOpen, Message: "failed to open database " + path, Err: ErrAlreadyOpen}
+	}
+	if diff := diffSystemConfigs(shared.config, systemConfig); len(diff) > 0 {
+		return &Error{Op: OpOpen, Message: "failed to open database " + path, Err: fmt.Errorf("%w: %s", ErrConfigMismatch, strings.Join(diff, ", "))}
+	}
+	shared.refs++
+	return nil
+}
+
+// release drops a reference to the shared database and destroys the C
+// database when it was the last one.
+func (shared *sharedDatabase) release() {
+	openDatabases.Lock()
+	shared.refs--
+	if shared.refs > 0 {
+		openDatabases.Unlock()
+		return
+	}
+	busy := make(chan struct{})
+	shared.busy = busy
+	openDatabases.Unlock()
+	C.lbug_database_destroy(&shared.cDatabase)
+	openDatabases.Lock()
+	delete(openDatabases.byPath, shared.path)
+	close(busy)
+	openDatabases.Unlock()
+}
+
+// singletonDatabase is a Database returned by OpenShared, with the number of
+// OpenShared calls that have not been released with CloseShared yet.
+type singletonDatabase struct {
+	db     *Database
+	config SystemConfig
+	refs   int
+}
+
+// singletonDatabases maps the canonical paths of the databases opened with
+// OpenShared, or ":memory:" for the in-memory one, to their Database.
+var singletonDatabases = struct {
+	sync.Mutex
+	byPath map[string]*singletonDatabase
+}{byPath: make(map[string]*singletonDatabase)}
+
+// OpenShared returns the Database of the process for the path, opening it
+// with OpenDatabase on the first call. Subsequent calls with the same path,
+// or another path to the same database, return the same Database and must
+// each be released with CloseShared; the database is closed when the last
+// reference is released. Since the Database is shared, it must not be closed
+// with Close. The path ":memory:" refers to a single in-memory database
+// shared by the callers of OpenShared.
+//
+// If the database is already open with a different system configuration,
+// OpenShared returns an error matching ErrConfigMismatch that lists the
+// differing fields.
+func OpenShared(path string, systemConfig SystemConfig) (*Database, error) {
+	key, err := canonicalDatabasePath(path)
+	if errors.Is(err, ErrEmptyPath) {
+		return nil, &Error{Op: OpOpen, Message: "failed to open database", Err: err}
+	}
+	if err != nil {
+		return nil, &Error{Op: OpOpen, Message: "failed to resolve database path " + path, Err: err}
+	}
+	if key == "" {
+		key = inMemoryPath
+	}
+	singletonDatabases.Lock()
+	defer singletonDatabases.Unlock()
+	if singleton, ok := singletonDatabases.byPath[key]; ok && !singleton.db.closed() {
+		if diff := diffSystemConfigs(singleton.config, systemConfig); len(diff) > 0 {
+			return nil, &Error{Op: OpOpen, Message: "failed to open database " + path, Err: fmt.Errorf("%w: %s", ErrConfigMismatch, strings.Join(diff, ", "))}
+		}
+		singleton.refs++
+		return singleton.db, nil
+	}
+	db, err := OpenDatabase(path, systemConfig)
+	if err != nil {
+		return nil, err
+	}
+	db.singletonPath = key
+	singletonDatabases.byPath[key] = &singletonDatabase{db: db, config: systemConfig, refs: 1}
+	return db, nil
+}
+
+// CloseShared releases a reference to a Database returned by OpenShared and
+// closes it when it was the last one. It returns an error if the Database was
+// not returned by OpenShared or all its references have been released.
+func CloseShared(db *Database) error {
+	singletonDatabases.Lock()
+	defer singletonDatabases.Unlock()
+	singleton, ok := singletonDatabases.byPath[db.singletonPath]
+	if db.singletonPath == "" || !ok || singleton.db != db {
+		return &Error{Op: OpClose, Message: "failed to close database: the database was not opened with OpenShared or is already released"}
+	}
+	singleton.refs--
+	if singleton.refs > 0 {
+		return nil
+	}
+	delete(singletonDatabases.byPath, db.singletonPath)
+	db.Close()
+	return nil
+}
+
+// diffSystemConfigs describes the fields that differ between the
+// configuration of an open database and a requested one. Callbacks, which
+// cannot be compared, are ignored.
+func diffSystemConfigs(open SystemConfig, requested SystemConfig) []string {
+	var diff []string
+	openValue, requestedValue := reflect.ValueOf(open), reflect.ValueOf(requested)
+	for i := 0; i < openValue.NumField(); i++ {
+		if openValue.Field(i).Kind() == reflect.Func {
+			continue
+		}
+		a, b := openValue.Field(i).Interface(), requestedValue.Field(i).Interface()
+		if a != b {
+			diff = append(diff, fmt.Sprintf("%s is %v, requested %v", openValue.Type().Field(i).Name, a, b))
+		}
+	}
+	return diff
+}
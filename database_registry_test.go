@@ -0,0 +1,201 @@
+package lbug
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalDatabasePath(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	assert.Nil(t, err)
+	target := filepath.Join(dir, "target")
+	assert.Nil(t, os.Mkdir(target, 0o755))
+	link := filepath.Join(dir, "link")
+	assert.Nil(t, os.Symlink(target, link))
+
+	for _, path := range []string{":memory:", " :MEMORY: \n"} {
+		canonical, err := canonicalDatabasePath(path)
+		assert.Nil(t, err)
+		assert.Equal(t, "", canonical)
+	}
+	for _, path := range []string{"", "  "} {
+		_, err := canonicalDatabasePath(path)
+		assert.ErrorIs(t, err, ErrEmptyPath)
+	}
+	// A database that does not exist yet resolves through its parent.
+	canonical, err := canonicalDatabasePath(filepath.Join(link, "db"))
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(target, "db"), canonical)
+	canonical, err = canonicalDatabasePath(filepath.Join(target, "..", "target", "db"))
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(target, "db"), canonical)
+	// An existing database that is itself a symbolic link resolves to its target.
+	assert.Nil(t, os.Symlink(target, filepath.Join(dir, "db-link")))
+	canonical, err = canonicalDatabasePath(filepath.Join(dir, "db-link"))
+	assert.Nil(t, err)
+	assert.Equal(t, target, canonical)
+}
+
+func TestOpenDatabaseTwiceShares(t *testing.T) {
+	path := getDatabasePath(t)
+	first, err := OpenDatabase(path, DefaultSystemConfig())
+	assert.Nil(t, err)
+	link := filepath.Join(t.TempDir(), "link")
+	assert.Nil(t, os.Symlink(filepath.Dir(path), link))
+	second, err := OpenDatabase(filepath.Join(link, filepath.Base(path)), DefaultSystemConfig())
+	assert.Nil(t, err)
+	assert.Same(t, first.shared, second.shared)
+	assert.Equal(t, 2, first.shared.refs)
+
+	conn, err := OpenConnection(first)
+	assert.Nil(t, err)
+	result, err := conn.Query("CREATE NODE TABLE t(id INT64, PRIMARY KEY(id));")
+	assert.Nil(t, err)
+	result.Close()
+	conn.Close()
+	first.Close()
+	first.Close()
+	assert.Equal(t, 1, second.shared.refs)
+
+	// The engine instance is still alive for the second handle.
+	conn, err = OpenConnection(second)
+	assert.Nil(t, err)
+	result, err = conn.Query("MATCH (n:t) RETURN count(n);")
+	assert.Nil(t, err)
+	result.Close()
+	conn.Close()
+	second.Close()
+
+	openDatabases.Lock()
+	assert.Len(t, openDatabases.byPath, 0)
+	openDatabases.Unlock()
+}
+
+func TestOpenDatabaseConfigMismatch(t *testing.T) {
+	path := getDatabasePath(t)
+	config := DefaultSystemConfig()
+	first, err := OpenDatabase(path, config)
+	assert.Nil(t, err)
+	defer first.Close()
+	other := config
+	other.BufferPoolSize = config.BufferPoolSize / 2
+	_, err = OpenDatabase(path, other)
+	assert.ErrorIs(t, err, ErrConfigMismatch)
+	assert.ErrorContains(t, err, "BufferPoolSize is")
+	assert.Equal(t, 1, first.shared.refs)
+}
+
+func TestOpenDatabaseConcurrently(t *testing.T) {
+	path := getDatabasePath(t)
+	databases := make([]*Database, 8)
+	errs := make([]error, len(databases))
+	var wg sync.WaitGroup
+	for i := range databases {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			databases[i], errs[i] = OpenDatabase(path, DefaultSystemConfig())
+		}()
+	}
+	wg.Wait()
+	for i, db := range databases {
+		assert.Nil(t, errs[i])
+		assert.Same(t, databases[0].shared, db.shared)
+	}
+	assert.Equal(t, len(databases), databases[0].shared.refs)
+	for _, db := range databases {
+		db.Close()
+	}
+	openDatabases.Lock()
+	assert.Len(t, openDatabases.byPath, 0)
+	openDatabases.Unlock()
+}
+
+func TestOpenDatabaseExclusive(t *testing.T) {
+	path := getDatabasePath(t)
+	systemConfig := DefaultSystemConfig()
+	systemConfig.ExclusiveOpen = true
+	first, err := OpenDatabase(path, systemConfig)
+	assert.Nil(t, err)
+	_, err = OpenDatabase(path, DefaultSystemConfig())
+	assert.ErrorIs(t, err, ErrAlreadyOpen)
+	first.Close()
+
+	first, err = OpenDatabase(path, DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer first.Close()
+	_, err = OpenDatabase(path, systemConfig)
+	assert.ErrorIs(t, err, ErrAlreadyOpen)
+}
+
+func TestOpenShared(t *testing.T) {
+	path := getDatabasePath(t)
+	first, err := OpenShared(path, DefaultSystemConfig())
+	assert.Nil(t, err)
+	link := filepath.Join(t.TempDir(), "link")
+	assert.Nil(t, os.Symlink(filepath.Dir(path), link))
+	second, err := OpenShared(filepath.Join(link, filepath.Base(path)), DefaultSystemConfig())
+	assert.Nil(t, err)
+	assert.Same(t, first, second)
+
+	assert.Nil(t, CloseShared(first))
+	conn, err := OpenConnection(second)
+	assert.Nil(t, err)
+	conn.Close()
+	assert.Nil(t, CloseShared(second))
+	assert.True(t, second.isClosed)
+	assert.NotNil(t, CloseShared(second))
+
+	third, err := OpenShared(path, DefaultSystemConfig())
+	assert.Nil(t, err)
+	assert.NotSame(t, first, third)
+	assert.Nil(t, CloseShared(third))
+}
+
+func TestOpenSharedConfigMismatch(t *testing.T) {
+	path := getDatabasePath(t)
+	config := DefaultSystemConfig()
+	db, err := OpenShared(path, config)
+	assert.Nil(t, err)
+	defer CloseShared(db)
+	other := config
+	other.BufferPoolSize = config.BufferPoolSize / 2
+	_, err = OpenShared(path, other)
+	assert.ErrorIs(t, err, ErrConfigMismatch)
+	assert.ErrorContains(t, err, "BufferPoolSize is")
+}
+
+func TestOpenSharedReopensClosedDatabase(t *testing.T) {
+	path := getDatabasePath(t)
+	db, err := OpenShared(path, DefaultSystemConfig())
+	assert.Nil(t, err)
+	db.Close()
+	reopened, err := OpenShared(path, DefaultSystemConfig())
+	assert.Nil(t, err)
+	assert.NotSame(t, db, reopened)
+	assert.NotNil(t, CloseShared(db))
+	assert.Nil(t, CloseShared(reopened))
+}
+
+func TestCloseSharedRejectsUnsharedDatabase(t *testing.T) {
+	db, err := OpenInMemoryDatabase(SystemConfigEmbeddedTest())
+	assert.Nil(t, err)
+	defer db.Close()
+	assert.NotNil(t, CloseShared(db))
+}
+
+func TestDiffSystemConfigs(t *testing.T) {
+	open := SystemConfig{BufferPoolSize: 1024, MaxNumThreads: 4}
+	assert.Empty(t, diffSystemConfigs(open, open))
+	requested := open
+	requested.BufferPoolSize = 2048
+	requested.ReadOnly = true
+	assert.Equal(t, []string{"BufferPoolSize is 1024, requested 2048", "ReadOnly is false, requested true"}, diffSystemConfigs(open, requested))
+	requested = open
+	requested.OnFatal = func(error) {}
+	assert.Empty(t, diffSystemConfigs(open, requested))
+}
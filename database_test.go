@@ -1,6 +1,7 @@
 package lbug
 
 import (
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -47,17 +48,15 @@ func TestOpenDatabaseInMemory(t *testing.T) {
 	assert.Nil(t, err)
 	assert.NotNil(t, conn)
 	assert.NotNil(t, conn.cConnection)
-	_, err = conn.Query("CREATE NODE TABLE person(name STRING, age INT64, PRIMARY KEY(name));")
-	assert.Nil(t, err)
-	_, err = conn.Query("CREATE (:person {name: 'Alice', age: 30});")
-	assert.Nil(t, err)
-	_, err = conn.Query("CREATE (:person {name: 'Bob', age: 40});")
-	assert.Nil(t, err)
+	mustRun(t, conn, "CREATE NODE TABLE person(name STRING, age INT64, PRIMARY KEY(name));")
+	mustRun(t, conn, "CREATE (:person {name: 'Alice', age: 30});")
+	mustRun(t, conn, "CREATE (:person {name: 'Bob', age: 40});")
 	res, err := conn.Query("MATCH (a:person) RETURN a.name, a.age;")
 	assert.Nil(t, err)
 	assert.True(t, res.HasNext())
 	tuple, err := res.Next()
 	assert.Nil(t, err)
+	defer tuple.Close()
 	values, err := tuple.GetAsSlice()
 	assert.Nil(t, err)
 	assert.Equal(t, 2, len(values))
@@ -66,6 +65,7 @@ func TestOpenDatabaseInMemory(t *testing.T) {
 	assert.True(t, res.HasNext())
 	tuple, err = res.Next()
 	assert.Nil(t, err)
+	defer tuple.Close()
 	values, err = tuple.GetAsSlice()
 	assert.Nil(t, err)
 	assert.Equal(t, 2, len(values))
@@ -88,3 +88,69 @@ func TestCloseDatabase(t *testing.T) {
 	db.Close()
 	assert.True(t, db.isClosed)
 }
+
+func TestSystemConfigPresets(t *testing.T) {
+	for _, systemConfig := range []SystemConfig{
+		SystemConfigLowMemory(),
+		SystemConfigAnalytics(),
+		SystemConfigEmbeddedTest(),
+	} {
+		assert.Nil(t, systemConfig.Validate())
+		db, err := OpenInMemoryDatabase(systemConfig)
+		assert.Nil(t, err)
+		conn, err := OpenConnection(db)
+		assert.Nil(t, err)
+		assert.Equal(t, systemConfig.MaxNumThreads, conn.GetMaxNumThreads())
+		conn.Close()
+		db.Close()
+	}
+}
+
+func TestSystemConfigPresetsAreCopies(t *testing.T) {
+	systemConfig := SystemConfigLowMemory()
+	systemConfig.MaxNumThreads = 8
+	assert.Equal(t, uint64(1), SystemConfigLowMemory().MaxNumThreads)
+}
+
+func TestSystemConfigValidate(t *testing.T) {
+	systemConfig := DefaultSystemConfig()
+	systemConfig.BufferPoolSize = 1 << 30
+	systemConfig.MaxDbSize = 1 << 20
+	systemConfig.MaxNumThreads = 1 << 20
+	err := systemConfig.Validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "BufferPoolSize")
+	assert.Contains(t, err.Error(), "MaxNumThreads")
+	db, err := OpenDatabase(getDatabasePath(t), systemConfig)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "invalid system config")
+	db.Close()
+	// Zero values let the engine choose
+	assert.Nil(t, SystemConfig{}.Validate())
+}
+
+func TestOpenDatabaseSpecialPaths(t *testing.T) {
+	for _, path := range []string{":memory:", ":MEMORY:", " :memory:\t"} {
+		db, err := OpenDatabase(path, DefaultSystemConfig())
+		assert.Nil(t, err, path)
+		assert.True(t, db.IsInMemory(), path)
+		db.Close()
+	}
+
+	for _, path := range []string{"", "   "} {
+		_, err := OpenDatabase(path, DefaultSystemConfig())
+		assert.ErrorIs(t, err, ErrEmptyPath)
+	}
+
+	// Anything else is a filesystem path.
+	dir := t.TempDir()
+	path := filepath.Join(dir, "memory:")
+	db, err := OpenDatabase(path, DefaultSystemConfig())
+	assert.Nil(t, err)
+	assert.False(t, db.IsInMemory())
+	db.Close()
+	_, err = os.Stat(path)
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(dir, ":memory:"))
+	assert.True(t, os.IsNotExist(err))
+}
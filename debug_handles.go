@@ -0,0 +1,89 @@
+package lbug
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// debugHandlesEnv is the environment variable that enables handle debugging
+// when the package is initialized: any value other than empty and "0"
+// enables it, with the reports written to standard error.
+const debugHandlesEnv = "LBUG_DEBUG_HANDLES"
+
+// handleDebugger writes the reports of handle debugging.
+type handleDebugger struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+var handleDebugging atomic.Pointer[handleDebugger]
+
+func init() {
+	if value := os.Getenv(debugHandlesEnv); value != "" && value != "0" {
+		EnableHandleDebugging(nil)
+	}
+}
+
+// EnableHandleDebugging reports the misuses of C handles to w, or to standard
+// error if w is nil, for debugging leaks and uses after close. It is also
+// enabled by setting the LBUG_DEBUG_HANDLES environment variable to 1 before
+// the process starts. While it is enabled:
+//
+//   - the creation stacks of new handles are recorded, as with
+//     SetHandleTracking(true);
+//   - the calls into the C API on a handle whose parent has been closed,
+//     which are uses after free in the C library, are reported with their
+//     stacks, as with SetLifetimeChecks, whose function it replaces;
+//   - CloseAll reports the handles it closes with their creation stacks,
+//     since the handles still open at shutdown have leaked.
+//
+// The calls on a handle that has been closed itself return an error matching
+// ErrClosed whether debugging is enabled or not. The package releases C
+// handles in Close, CloseAll and the closes of their parents, never in
+// finalizers, so that no handle is released while a call on it is running.
+func EnableHandleDebugging(w io.Writer) {
+	if w == nil {
+		w = os.Stderr
+	}
+	debugger := &handleDebugger{w: w}
+	handleDebugging.Store(debugger)
+	SetHandleTracking(true)
+	SetLifetimeChecks(debugger.reportViolation)
+}
+
+// DisableHandleDebugging disables the reports enabled by
+// EnableHandleDebugging, as well as handle tracking and lifetime checks.
+func DisableHandleDebugging() {
+	handleDebugging.Store(nil)
+	SetHandleTracking(false)
+	SetLifetimeChecks(nil)
+}
+
+// reportViolation writes a lifetime violation.
+func (debugger *handleDebugger) reportViolation(violation LifetimeViolation) {
+	debugger.mutex.Lock()
+	defer debugger.mutex.Unlock()
+	fmt.Fprintf(debugger.w, "lbug: %v", violation)
+}
+
+// reportLeaks writes the handles open when CloseAll is called, if handle
+// debugging is enabled.
+func reportLeaks() {
+	debugger := handleDebugging.Load()
+	if debugger == nil {
+		return
+	}
+	infos := OpenHandles()
+	if len(infos) == 0 {
+		return
+	}
+	debugger.mutex.Lock()
+	defer debugger.mutex.Unlock()
+	fmt.Fprintf(debugger.w, "lbug: CloseAll closes %d leaked handles\n", len(infos))
+	for _, info := range infos {
+		fmt.Fprintf(debugger.w, "%v %d created at:\n%s", info.Kind, info.ID, info.Stack)
+	}
+}
@@ -0,0 +1,48 @@
+package lbug
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnableHandleDebugging(t *testing.T) {
+	defer SetHandleTracking(IsHandleTrackingEnabled())
+	var out bytes.Buffer
+	EnableHandleDebugging(&out)
+	defer DisableHandleDebugging()
+	assert.True(t, IsHandleTrackingEnabled())
+	assert.NotNil(t, lifetimeHooks.report.Load())
+
+	handleDebugging.Load().reportViolation(LifetimeViolation{Kind: HandleQueryResult, Parent: HandleConnection, Stack: "main.main\n"})
+	assert.Equal(t, "lbug: QueryResult used after its Connection was closed at:\nmain.main\n", out.String())
+
+	DisableHandleDebugging()
+	assert.False(t, IsHandleTrackingEnabled())
+	assert.Nil(t, lifetimeHooks.report.Load())
+	out.Reset()
+	reportLeaks()
+	assert.Empty(t, out.String())
+}
+
+func TestHandleDebuggingReportsLeaks(t *testing.T) {
+	defer SetHandleTracking(IsHandleTrackingEnabled())
+	var out bytes.Buffer
+	EnableHandleDebugging(&out)
+	defer DisableHandleDebugging()
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	_, err = conn.Query("RETURN 1;")
+	assert.Nil(t, err)
+	// CloseAll would also close the shared database of the other tests, so
+	// the leaks are reported as it does and only the handles of db closed.
+	reportLeaks()
+	assert.Nil(t, closeObjects(context.Background(), objectsOf(db)))
+	assert.Contains(t, out.String(), "leaked handles")
+	assert.Contains(t, out.String(), "QueryResult")
+	assert.Contains(t, out.String(), "TestHandleDebuggingReportsLeaks")
+}
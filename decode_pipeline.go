@@ -0,0 +1,259 @@
+package lbug
+
+// #include "lbug.h"
+import "C"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// ErrStopDecoding is returned by the consumer of QueryResult.DecodePipeline to
+// stop the pipeline early without an error.
+var ErrStopDecoding = errors.New("stop decoding")
+
+// DecodeOptions configures QueryResult.DecodePipeline.
+type DecodeOptions struct {
+	// Workers is the number of goroutines converting the values of the rows
+	// to Go values. Zero means runtime.GOMAXPROCS(0).
+	Workers int
+	// Ordered delivers the rows to the consumer in the order of the result.
+	// Otherwise they are delivered as soon as they are converted.
+	Ordered bool
+	// Buffer is the maximum number of rows fetched but not delivered yet,
+	// which bounds the memory used to convert and reorder the rows. Zero
+	// means four rows per worker.
+	Buffer int
+}
+
+// rawRow is a row fetched from the result whose values have not been
+// converted to Go values yet. The values are clones owned by the row.
+type rawRow struct {
+	seq    uint64
+	values []*C.lbug_value
+	err    error
+}
+
+// destroy releases the C values of the row.
+func (row *rawRow) destroy() {
+	for _, value := range row.values {
+		C.lbug_value_destroy(value)
+	}
+	row.values = nil
+}
+
+// decodedRow is a row converted by a worker of a pipeline.
+type decodedRow struct {
+	seq      uint64
+	values   []any
+	warnings []ConversionWarning
+	err      error
+}
+
+// DecodePipeline converts the remaining rows of the QueryResult on a pool of
+// worker goroutines and calls consume with the values of every row, as
+// returned by FlatTuple.GetAsSlice, on the calling goroutine. The rows are
+// fetched one at a time under the lock of the connection and their values
+// cloned, so that the conversion, which dominates the cost of iterating over
+// results with nested values such as STRUCTs, runs in parallel without
+// holding the lock. The goroutines of the pipeline, the workers and two
+// more, count toward the limit set with SetMaxBackgroundTasks.
+//
+// With DecodeOptions.Ordered, the rows are delivered in the order of the
+// result, and an error converting a row is returned after the rows before it
+// have been delivered. consume must not retain the row after it returns, nor
+// call methods of the QueryResult. If consume returns an error, the pipeline
+// stops and DecodePipeline returns the error, or nil for ErrStopDecoding. If
+// the context is done, the pipeline stops and returns the error of the
+// context. In all cases, the goroutines of the pipeline have exited and the
+// cloned values have been released when DecodePipeline returns.
+func (queryResult *QueryResult) DecodePipeline(ctx context.Context, opts DecodeOptions, consume func(row []any) error) error {
+	if opts.Workers < 0 || opts.Buffer < 0 {
+		return fmt.Errorf("invalid decode options: the number of workers %d and the buffer size %d must not be negative", opts.Workers, opts.Buffer)
+	}
+	if opts.Workers == 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	if opts.Buffer == 0 {
+		opts.Buffer = 4 * opts.Workers
+	}
+	// The fetcher, the workers and the goroutine closing the channel of the
+	// decoded rows are started together.
+	if err := background.reserve("DecodePipeline", opts.Workers+2); err != nil {
+		return err
+	}
+	conn := queryResult.connection
+	// The rows are fetched by a goroutine of the pipeline, which takes a
+	// single-threaded connection over until the pipeline returns.
+	owner := &queryResult.connection.closeMutex.owner
+	owner.release()
+	defer owner.release()
+	pipelineCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// A token is held by every row between its fetch and its delivery.
+	tokens := make(chan struct{}, opts.Buffer)
+	raw := make(chan rawRow)
+	decoded := make(chan decodedRow, opts.Buffer)
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	background.start("DecodePipeline fetcher", conn, func() {
+		defer wg.Done()
+		defer close(raw)
+		for seq := uint64(0); ; seq++ {
+			select {
+			case tokens <- struct{}{}:
+			case <-pipelineCtx.Done():
+				return
+			}
+			row, ok := queryResult.fetchRaw(seq)
+			if !ok {
+				return
+			}
+			select {
+			case raw <- row:
+			case <-pipelineCtx.Done():
+				row.destroy()
+				return
+			}
+			if row.err != nil {
+				return
+			}
+		}
+	})
+	settings := valueConverter{
+		policy:          queryResult.converter.policy,
+		timeRange:       queryResult.converter.timeRange,
+		maxPathElements: queryResult.converter.maxPathElements,
+	}
+	for range opts.Workers {
+		wg.Add(1)
+		background.start("DecodePipeline worker", conn, func() {
+			defer wg.Done()
+			for row := range raw {
+				if pipelineCtx.Err() != nil {
+					row.destroy()
+					continue
+				}
+				decoded <- decodeRawRow(row, settings)
+			}
+		})
+	}
+	background.start("DecodePipeline", conn, func() {
+		wg.Wait()
+		close(decoded)
+	})
+
+	var err error
+	deliver := func(row decodedRow) {
+		<-tokens
+		if err != nil || pipelineCtx.Err() != nil {
+			return
+		}
+		if row.err == nil {
+			queryResult.converter.warnings = append(queryResult.converter.warnings, row.warnings...)
+			row.err = consume(row.values)
+		}
+		if row.err != nil {
+			err = row.err
+			cancel()
+		}
+	}
+	next := uint64(0)
+	pending := make(map[uint64]decodedRow)
+	for row := range decoded {
+		if !opts.Ordered {
+			deliver(row)
+			continue
+		}
+		pending[row.seq] = row
+		for row, ok := pending[next]; ok; row, ok = pending[next] {
+			delete(pending, next)
+			deliver(row)
+			next++
+		}
+	}
+	if errors.Is(err, ErrStopDecoding) {
+		return nil
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return err
+}
+
+// fetchRaw fetches the next row of the result and clones its values. It
+// returns false if there is no more row, and a row with an error if fetching
+// failed.
+func (queryResult *QueryResult) fetchRaw(seq uint64) (rawRow, bool) {
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	row := rawRow{seq: seq}
+	if queryResult.isClosed {
+		row.err = &Error{Op: OpIterate, Err: &closedError{"failed to get next tuple because the query result is closed"}}
+		return row, true
+	}
+	queryResult.connection.countCgoCall(cgoNext)
+	if err := queryResult.connection.checkConnection(HandleQueryResult); err != nil {
+		row.err = &Error{Op: OpIterate, Err: err}
+		return row, true
+	}
+	if err := queryResult.checkInvalidated(); err != nil {
+		row.err = &Error{Op: OpIterate, Err: err}
+		return row, true
+	}
+	if !bool(C.lbug_query_result_has_next(&queryResult.cQueryResult)) {
+		return row, false
+	}
+	if row.err = queryResult.checkRowLimit(); row.err != nil {
+		return row, true
+	}
+	var cFlatTuple C.lbug_flat_tuple
+	queryResult.connection.countCgoCall(cgoNext)
+	if status := queryResult.fetchNext(&cFlatTuple); status != C.LbugSuccess {
+		queryResult.connection.lastCallFailed.Store(true)
+		row.err = &Error{Op: OpIterate, Message: fmt.Sprintf("failed to get next tuple with status %d", status)}
+		return row, true
+	}
+	defer C.lbug_flat_tuple_destroy(&cFlatTuple)
+	numColumns := queryResult.getNumberOfColumns()
+	row.values = make([]*C.lbug_value, 0, numColumns)
+	for i := uint64(0); i < numColumns; i++ {
+		var cValue C.lbug_value
+		queryResult.connection.countCgoCall(cgoGetValue)
+		if status := C.lbug_flat_tuple_get_value(&cFlatTuple, C.uint64_t(i), &cValue); status != C.LbugSuccess {
+			row.destroy()
+			row.err = conversionError(i, fmt.Errorf("failed to get value with status: %d", status))
+			return row, true
+		}
+		row.values = append(row.values, C.lbug_value_clone(&cValue))
+	}
+	queryResult.hasFetched = true
+	queryResult.numFetched++
+	queryResult.connection.stats().tuplesFetched.Add(1)
+	return row, true
+}
+
+// decodeRawRow converts the values of the row with a converter of the given
+// settings and releases them.
+func decodeRawRow(row rawRow, settings valueConverter) decodedRow {
+	defer row.destroy()
+	result := decodedRow{seq: row.seq, err: row.err}
+	if row.err != nil {
+		return result
+	}
+	converter := settings
+	result.values = make([]any, len(row.values))
+	for i, value := range row.values {
+		var err error
+		if result.values[i], err = lbugValueToGoValue(*value, &converter); err != nil {
+			result.values, result.err = nil, conversionError(uint64(i), err)
+			return result
+		}
+	}
+	result.warnings = converter.warnings
+	return result
+}
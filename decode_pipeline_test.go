@@ -0,0 +1,175 @@
+package lbug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pipelineResult returns the result of a query with numRows rows of an
+// integer and a STRUCT.
+func pipelineResult(t testing.TB, numRows int) *QueryResult {
+	t.Helper()
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	t.Cleanup(db.Close)
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	t.Cleanup(conn.Close)
+	result, err := conn.Query(fmt.Sprintf(
+		"UNWIND range(1, %d) AS i RETURN i, {id: i, name: CAST(i, 'STRING'), tags: [i, i + 1], nested: {x: i * 2, y: 'n'}} AS s;", numRows))
+	assert.Nil(t, err)
+	t.Cleanup(result.Close)
+	return result
+}
+
+// waitForGoroutines waits for the number of goroutines to drop to at most n.
+func waitForGoroutines(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, runtime.NumGoroutine() <= n, "leaked goroutines")
+}
+
+func TestDecodePipelineOrdered(t *testing.T) {
+	checkBackgroundTasks(t)
+	result := pipelineResult(t, 1000)
+	var ids []int64
+	err := result.DecodePipeline(context.Background(), DecodeOptions{Workers: 4, Ordered: true, Buffer: 8}, func(row []any) error {
+		id := row[0].(int64)
+		s := row[1].(map[string]any)
+		assert.Equal(t, id, s["id"])
+		assert.Equal(t, map[string]any{"x": 2 * id, "y": "n"}, s["nested"])
+		ids = append(ids, id)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Len(t, ids, 1000)
+	for i, id := range ids {
+		assert.Equal(t, int64(i+1), id)
+	}
+	assert.False(t, result.HasNext())
+}
+
+func TestDecodePipelineUnordered(t *testing.T) {
+	checkBackgroundTasks(t)
+	result := pipelineResult(t, 500)
+	var ids []int
+	err := result.DecodePipeline(context.Background(), DecodeOptions{Workers: 8}, func(row []any) error {
+		ids = append(ids, int(row[0].(int64)))
+		return nil
+	})
+	assert.Nil(t, err)
+	sort.Ints(ids)
+	assert.Len(t, ids, 500)
+	for i, id := range ids {
+		assert.Equal(t, i+1, id)
+	}
+}
+
+func TestDecodePipelineStopsEarly(t *testing.T) {
+	checkBackgroundTasks(t)
+	result := pipelineResult(t, 1000)
+	goroutines := runtime.NumGoroutine()
+	delivered := 0
+	err := result.DecodePipeline(context.Background(), DecodeOptions{Workers: 4, Ordered: true}, func(row []any) error {
+		delivered++
+		if delivered == 10 {
+			return ErrStopDecoding
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 10, delivered)
+	waitForGoroutines(t, goroutines)
+
+	// The result can be consumed further.
+	consumerErr := errors.New("consumer failed")
+	err = result.DecodePipeline(context.Background(), DecodeOptions{Workers: 4}, func(row []any) error {
+		return consumerErr
+	})
+	assert.Equal(t, consumerErr, err)
+	waitForGoroutines(t, goroutines)
+}
+
+func TestDecodePipelineCancel(t *testing.T) {
+	checkBackgroundTasks(t)
+	result := pipelineResult(t, 1000)
+	goroutines := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	delivered := 0
+	err := result.DecodePipeline(ctx, DecodeOptions{Workers: 2, Ordered: true}, func(row []any) error {
+		delivered++
+		if delivered == 5 {
+			cancel()
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 5, delivered)
+	waitForGoroutines(t, goroutines)
+
+	result.Close()
+	err = result.DecodePipeline(context.Background(), DecodeOptions{}, func(row []any) error { return nil })
+	assert.ErrorIs(t, err, ErrClosed)
+	err = result.DecodePipeline(context.Background(), DecodeOptions{Workers: -1}, func(row []any) error { return nil })
+	assert.ErrorContains(t, err, "must not be negative")
+}
+
+func TestDecodePipelineConversionError(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	unsupportedTypeIDsForTesting = map[int]bool{int64TypeID: true}
+	defer func() { unsupportedTypeIDsForTesting = nil }()
+	result, err := conn.Query("UNWIND range(1, 100) AS i RETURN CAST(i, 'INT32'), CASE WHEN i = 50 THEN i ELSE NULL END;")
+	assert.Nil(t, err)
+	defer result.Close()
+
+	goroutines := runtime.NumGoroutine()
+	delivered := 0
+	err = result.DecodePipeline(context.Background(), DecodeOptions{Workers: 4, Ordered: true}, func(row []any) error {
+		delivered++
+		return nil
+	})
+	// The rows before the failing one are delivered first.
+	var lbugErr *Error
+	assert.ErrorAs(t, err, &lbugErr)
+	assert.Equal(t, OpConvert, lbugErr.Op)
+	assert.Equal(t, uint64(1), lbugErr.Column)
+	assert.Equal(t, 49, delivered)
+	waitForGoroutines(t, goroutines)
+}
+
+// BenchmarkDecodePipeline measures the decoding of a result with STRUCT
+// values by the number of workers, e.g. with -bench DecodePipeline -cpu 8.
+func BenchmarkDecodePipeline(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			result := pipelineResult(b, 20000)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				result.ResetIterator()
+				err := result.DecodePipeline(context.Background(), DecodeOptions{Workers: workers, Ordered: true}, func(row []any) error {
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package lbug
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+)
+
+// SetDefaultParams sets parameters bound to the prepared statements executed
+// on the connection with Execute and Exec, e.g. the date range and tenant ID
+// shared by a family of queries. A default is bound only to the statements
+// using its parameter, and the arguments of the call win over the defaults of
+// the same names. The map is copied; a nil or empty map clears the defaults.
+//
+// On a connection of a Pool, the defaults are cleared when the connection is
+// released, so that they do not leak to the next user of the connection; use
+// SetStickyDefaultParams to keep them.
+func (conn *Connection) SetDefaultParams(params map[string]any) {
+	conn.setDefaultParams(params, false)
+}
+
+// SetStickyDefaultParams is like SetDefaultParams, but the defaults are kept
+// when the connection is released to its Pool, e.g. for defaults set by
+// PoolConfig.OnConnect.
+func (conn *Connection) SetStickyDefaultParams(params map[string]any) {
+	conn.setDefaultParams(params, true)
+}
+
+func (conn *Connection) setDefaultParams(params map[string]any, sticky bool) {
+	if len(params) == 0 {
+		conn.defaultParams = nil
+		conn.stickyDefaults = false
+		return
+	}
+	conn.defaultParams = maps.Clone(params)
+	conn.stickyDefaults = sticky
+}
+
+// DefaultParams returns a copy of the default parameters of the connection,
+// or nil if it has none.
+func (conn *Connection) DefaultParams() map[string]any {
+	return maps.Clone(conn.defaultParams)
+}
+
+// resetDefaultParams clears the default parameters when the connection is
+// released to its pool, unless they are sticky.
+func (conn *Connection) resetDefaultParams() {
+	if !conn.stickyDefaults {
+		conn.defaultParams = nil
+	}
+}
+
+// effectiveParams returns the parameters bound to the statement for the
+// arguments: the arguments, and the defaults of the connection used by the
+// statement and not among the arguments. It returns args itself if no
+// default applies.
+func (conn *Connection) effectiveParams(stmt *PreparedStatement, args map[string]any) map[string]any {
+	var effective map[string]any
+	for key, value := range conn.defaultParams {
+		if _, ok := args[key]; ok || !stmt.parameters[key] {
+			continue
+		}
+		if effective == nil {
+			effective = maps.Clone(args)
+			if effective == nil {
+				effective = make(map[string]any)
+			}
+		}
+		effective[key] = value
+	}
+	if effective == nil {
+		return args
+	}
+	return effective
+}
+
+// bindDefaultParams binds the defaults of the connection used by the statement
+// and not among the arguments. A default that cannot be bound, e.g. because it
+// is of a type the statement does not accept for the parameter, fails with an
+// error naming it as a default.
+func (conn *Connection) bindDefaultParams(stmt *PreparedStatement, args map[string]any) error {
+	for key, value := range conn.defaultParams {
+		if _, ok := args[key]; ok || !stmt.parameters[key] {
+			continue
+		}
+		if err := conn.bindParameter(stmt, key, value); err != nil {
+			var lbugErr *Error
+			if errors.As(err, &lbugErr) {
+				if lbugErr.Message == "" {
+					lbugErr.Message = fmt.Sprintf("failed to bind default parameter %s", key)
+				}
+				lbugErr.Message = "default set with SetDefaultParams: " + lbugErr.Message
+			}
+			return err
+		}
+	}
+	return nil
+}
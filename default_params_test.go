@@ -0,0 +1,98 @@
+package lbug
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEffectiveParams(t *testing.T) {
+	conn := &Connection{}
+	stmt := &PreparedStatement{parameters: queryParameters("MATCH (p) WHERE p.tenant = $tenant AND p.since >= $since RETURN p LIMIT $limit")}
+	args := map[string]any{"limit": 10}
+	assert.Equal(t, args, conn.effectiveParams(stmt, args))
+
+	defaults := map[string]any{"tenant": "acme", "since": 2020, "unused": true}
+	conn.SetDefaultParams(defaults)
+	defaults["tenant"] = "changed"
+	assert.Equal(t, map[string]any{"tenant": "acme", "since": 2020, "unused": true}, conn.DefaultParams())
+	// The arguments win over the defaults, and the defaults of parameters
+	// the statement does not use are not bound.
+	effective := conn.effectiveParams(stmt, map[string]any{"since": 2024, "limit": 10})
+	assert.Equal(t, map[string]any{"tenant": "acme", "since": 2024, "limit": 10}, effective)
+	assert.Equal(t, map[string]any{"tenant": "acme", "since": 2020}, conn.effectiveParams(stmt, nil))
+
+	conn.resetDefaultParams()
+	assert.Nil(t, conn.DefaultParams())
+	conn.SetStickyDefaultParams(map[string]any{"tenant": "acme"})
+	conn.resetDefaultParams()
+	assert.Equal(t, map[string]any{"tenant": "acme"}, conn.DefaultParams())
+	conn.SetDefaultParams(nil)
+	assert.Nil(t, conn.DefaultParams())
+}
+
+func TestDefaultParams(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	conn.SetDefaultParams(map[string]any{"minAge": int64(30), "name": "unused"})
+	stmt, err := conn.Prepare("MATCH (p:person) WHERE p.age >= $minAge AND p.ID <= $maxID RETURN p.ID ORDER BY p.ID;")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	ids := func(args map[string]any) []any {
+		res, err := conn.Execute(stmt, args)
+		if !assert.Nil(t, err) {
+			return nil
+		}
+		defer res.Close()
+		var ids []any
+		for res.HasNext() {
+			tuple, err := res.Next()
+			assert.Nil(t, err)
+			id, _ := tuple.GetValue(0)
+			tuple.Close()
+			ids = append(ids, id)
+		}
+		return ids
+	}
+	all := ids(map[string]any{"maxID": int64(10), "minAge": int64(0)})
+	older := ids(map[string]any{"maxID": int64(10)})
+	assert.Less(t, len(older), len(all))
+
+	_, err = stmt.Exec(map[string]any{"maxID": int64(10)})
+	assert.Nil(t, err)
+
+	conn.SetDefaultParams(map[string]any{"minAge": []any{}})
+	_, err = conn.Execute(stmt, map[string]any{"maxID": int64(10)})
+	var lbugErr *Error
+	assert.ErrorAs(t, err, &lbugErr)
+	assert.Equal(t, "minAge", lbugErr.Parameter)
+	assert.ErrorContains(t, err, "default set with SetDefaultParams: failed to convert Go value of type []interface {} to Lbug value for parameter minAge")
+}
+
+func TestPoolResetsDefaultParams(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	pool, err := NewPool(db, PoolConfig{
+		MaxConns: 1,
+		OnConnect: func(conn *Connection) error {
+			conn.SetStickyDefaultParams(map[string]any{"tenant": "acme"})
+			return nil
+		},
+	})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+
+	pooled, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"tenant": "acme"}, pooled.DefaultParams())
+	pooled.Release()
+	pooled, err = pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"tenant": "acme"}, pooled.DefaultParams())
+	pooled.SetDefaultParams(map[string]any{"since": 2024})
+	pooled.Release()
+
+	pooled, err = pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	defer pooled.Release()
+	assert.Nil(t, pooled.DefaultParams())
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 )
 
@@ -18,11 +19,19 @@ func init() {
 	var _ SQLStatement = new(statement)
 	var _ SQLConnector = new(connector)
 	var _ driver.DriverContext = new(sqlDriver)
-	sql.Register(Name, &sqlDriver{cc: map[string]driver.Connector{}})
+	var _ driver.NamedValueChecker = new(connection)
+	sql.Register(Name, NewDriver())
 }
 
 const Name = "lbug"
 
+// NewDriver returns a database/sql driver for Lbug databases, such as the one
+// registered under Name. It lets the driver be registered under another name
+// or used with sql.OpenDB through its OpenConnector method.
+func NewDriver() driver.Driver {
+	return &sqlDriver{cc: map[string]driver.Connector{}}
+}
+
 type Finalizer interface {
 	Close()
 }
@@ -51,15 +60,18 @@ type sqlDriver struct {
 	cc map[string]driver.Connector
 }
 
-// OpenConnector lbug://path?poolSize=1024&threads=1024&dbSize=1024&compression=1&readOnly=1
+// OpenConnector lbug://path?poolSize=1GiB&threads=1024&dbSize=1TiB&compression=1&readOnly=1&timeout=30s
+// poolSize and dbSize accept either a number of bytes or a size with a unit
+// (see ParseByteSize), and timeout is a query timeout duration (see ParseTimeout).
+// The scheme is optional, so a DSN can also be a plain path such as ./mydb or
+// :memory:, with or without options.
 func (that *sqlDriver) OpenConnector(dsn string) (driver.Connector, error) {
-	u, err := url.Parse(dsn)
+	path, q, err := parseDSN(dsn)
 	if nil != err {
 		return nil, err
 	}
-	q := u.Query()
 	systemConfig := DefaultSystemConfig()
-	if err = parse(q.Get("poolSize"), func(v uint64) {
+	if err = parseByteSize(q.Get("poolSize"), func(v uint64) {
 		systemConfig.BufferPoolSize = v
 	}); nil != err {
 		return nil, err
@@ -69,7 +81,7 @@ func (that *sqlDriver) OpenConnector(dsn string) (driver.Connector, error) {
 	}); nil != err {
 		return nil, err
 	}
-	if err = parse(q.Get("dbSize"), func(v uint64) {
+	if err = parseByteSize(q.Get("dbSize"), func(v uint64) {
 		systemConfig.MaxDbSize = v
 	}); nil != err {
 		return nil, err
@@ -84,15 +96,22 @@ func (that *sqlDriver) OpenConnector(dsn string) (driver.Connector, error) {
 	}); nil != err {
 		return nil, err
 	}
-	db, err := OpenDatabase(u.Path, systemConfig)
+	var timeout uint64
+	if v := q.Get("timeout"); "" != v {
+		if timeout, err = ParseTimeout(v); nil != err {
+			return nil, err
+		}
+	}
+	db, err := OpenDatabase(path, systemConfig)
 	if nil != err {
 		release(db)
 		return nil, err
 	}
 	return &connector{
-		d:   that,
-		dsn: dsn,
-		db:  db,
+		d:       that,
+		dsn:     dsn,
+		db:      db,
+		timeout: timeout,
 	}, nil
 }
 
@@ -117,9 +136,10 @@ func (that *sqlDriver) Open(dsn string) (driver.Conn, error) {
 }
 
 type connector struct {
-	dsn string
-	d   driver.Driver
-	db  *Database
+	dsn     string
+	d       driver.Driver
+	db      *Database
+	timeout uint64
 }
 
 func (that *connector) Close() error {
@@ -137,6 +157,9 @@ func (that *connector) Connect(ctx context.Context) (driver.Conn, error) {
 		release(conn)
 		return nil, err
 	}
+	if that.timeout > 0 {
+		conn.SetTimeout(that.timeout)
+	}
 	return &connection{
 		conn: conn,
 	}, nil
@@ -150,6 +173,13 @@ func (that *connection) Ping(ctx context.Context) error {
 	return nil
 }
 
+// CheckNamedValue accepts every argument as is, so that lists, maps, structs
+// and the other values supported by the parameter binding are not rejected by
+// the default conversion of database/sql.
+func (that *connection) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
 func (that *connection) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
 	stmt, err := that.prepareContext(ctx, query)
 	if nil != err {
@@ -218,11 +248,11 @@ func (that *statement) NumInput() int {
 }
 
 func (that *statement) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
-	raw := make(map[string]any, len(args))
-	for _, arg := range args {
-		raw[arg.Name] = arg.Value
+	raw, err := that.namedArgs(args)
+	if nil != err {
+		return nil, err
 	}
-	rs, err := that.conn.Execute(that.stmt, raw)
+	rs, err := that.conn.ExecuteWithContext(ctx, that.stmt, raw)
 	if nil != err {
 		release(rs)
 		return nil, err
@@ -240,7 +270,7 @@ func (that *statement) Exec(args []driver.Value) (driver.Result, error) {
 	for i, v := range args {
 		na, ok := v.(sql.NamedArg)
 		if !ok {
-			return nil, fmt.Errorf("only support named arguments")
+			return nil, &Error{Op: OpBind, Query: that.query, Message: "only support named arguments"}
 		}
 		list[i] = driver.NamedValue{
 			Name:    na.Name,
@@ -252,11 +282,11 @@ func (that *statement) Exec(args []driver.Value) (driver.Result, error) {
 }
 
 func (that *statement) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	raw := make(map[string]any, len(args))
-	for _, arg := range args {
-		raw[arg.Name] = arg.Value
+	raw, err := that.namedArgs(args)
+	if nil != err {
+		return nil, err
 	}
-	rs, err := that.conn.Execute(that.stmt, raw)
+	rs, err := that.conn.ExecuteWithContext(ctx, that.stmt, raw)
 	if nil != err {
 		release(rs)
 		return nil, err
@@ -269,7 +299,7 @@ func (that *statement) Query(args []driver.Value) (driver.Rows, error) {
 	for i, v := range args {
 		na, ok := v.(sql.NamedArg)
 		if !ok {
-			return nil, fmt.Errorf("only support named arguments")
+			return nil, &Error{Op: OpBind, Query: that.query, Message: "only support named arguments"}
 		}
 		list[i] = driver.NamedValue{
 			Name:    na.Name,
@@ -280,6 +310,18 @@ func (that *statement) Query(args []driver.Value) (driver.Rows, error) {
 	return that.QueryContext(nextContext(), list)
 }
 
+// namedArgs maps the arguments to the $name parameters of the statement.
+func (that *statement) namedArgs(args []driver.NamedValue) (map[string]any, error) {
+	raw := make(map[string]any, len(args))
+	for _, arg := range args {
+		if "" == arg.Name {
+			return nil, &Error{Op: OpBind, Query: that.query, Message: fmt.Sprintf("only support named arguments, got positional argument %d", arg.Ordinal)}
+		}
+		raw[arg.Name] = arg.Value
+	}
+	return raw, nil
+}
+
 // transaction is not support by now.
 type transaction struct {
 	conn SQLConnection
@@ -358,6 +400,26 @@ func closeQuiet(closer io.Closer) {
 	_ = closer.Close()
 }
 
+// parseDSN splits a DSN into the database path and the options. The lbug://
+// or ladybug:// scheme is optional, and the path is unescaped only if it is
+// present.
+func parseDSN(dsn string) (string, url.Values, error) {
+	path, rawQuery, _ := strings.Cut(dsn, "?")
+	q, err := url.ParseQuery(rawQuery)
+	if nil != err {
+		return "", nil, err
+	}
+	for _, scheme := range []string{"lbug://", "ladybug://"} {
+		if rest, ok := strings.CutPrefix(path, scheme); ok {
+			if path, err = url.PathUnescape(rest); nil != err {
+				return "", nil, err
+			}
+			break
+		}
+	}
+	return path, q, nil
+}
+
 func parse(v string, fn func(v uint64)) error {
 	if "" == v {
 		return nil
@@ -369,3 +431,15 @@ func parse(v string, fn func(v uint64)) error {
 	fn(iv)
 	return nil
 }
+
+func parseByteSize(v string, fn func(v uint64)) error {
+	if "" == v {
+		return nil
+	}
+	iv, err := ParseByteSize(v)
+	if nil != err {
+		return err
+	}
+	fn(iv)
+	return nil
+}
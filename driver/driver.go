@@ -0,0 +1,29 @@
+// Package driver registers the Lbug database/sql driver under the name
+// "ladybug". Import it for its side effect:
+//
+//	import _ "github.com/LadybugDB/go-ladybug/driver"
+//
+//	db, err := sql.Open("ladybug", "./mydb?poolSize=1GiB&threads=4&readOnly=1")
+//
+// The DSN is the path of the database, or :memory: for an in-memory
+// database, optionally followed by the options described by the
+// OpenConnector method of the driver returned by lbug.NewDriver. Queries take
+// their parameters as named arguments, e.g. sql.Named("name", "Adam") for
+// $name, and the values of the rows are converted as by FlatTuple.GetValue.
+//
+// Closing the sql.DB closes the database; the connections and statements of
+// the sql.DB must be closed before.
+package driver
+
+import (
+	"database/sql"
+
+	lbug "github.com/LadybugDB/go-ladybug"
+)
+
+// Name is the name the driver is registered under.
+const Name = "ladybug"
+
+func init() {
+	sql.Register(Name, lbug.NewDriver())
+}
@@ -0,0 +1,49 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	lbug "github.com/LadybugDB/go-ladybug"
+	"github.com/LadybugDB/go-ladybug/lbugtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriver(t *testing.T) {
+	lbugtest.VerifyNoLeaks(t)
+	ctx := context.Background()
+	db, err := sql.Open(Name, ":memory:?threads=1")
+	assert.Nil(t, err)
+	for _, query := range []string{
+		"CREATE NODE TABLE User(name STRING, age INT64, PRIMARY KEY (name))",
+		"CREATE REL TABLE Follows(FROM User TO User, since INT64)",
+		"CREATE (:User {name: 'Adam', age: 30})-[:Follows {since: 2020}]->(:User {name: 'Karissa', age: 40})",
+	} {
+		_, err := db.ExecContext(ctx, query)
+		assert.Nil(t, err)
+	}
+
+	var age int64
+	assert.Nil(t, db.QueryRowContext(ctx, "MATCH (u:User) WHERE u.name = $name RETURN u.age", sql.Named("name", "Adam")).Scan(&age))
+	assert.Equal(t, int64(30), age)
+
+	var names []any
+	assert.Nil(t, db.QueryRowContext(ctx, "UNWIND $names AS n RETURN collect(n)", sql.Named("names", []any{"a", "b"})).Scan(&names))
+	assert.Equal(t, []any{"a", "b"}, names)
+
+	rows, err := db.QueryContext(ctx, "MATCH (a:User)-[f:Follows]->(b:User) RETURN a, f, {name: b.name, since: f.since}")
+	assert.Nil(t, err)
+	assert.True(t, rows.Next())
+	var node, rel, row any
+	assert.Nil(t, rows.Scan(&node, &rel, &row))
+	assert.Equal(t, "Adam", node.(lbug.Node).Properties["name"])
+	assert.Equal(t, int64(2020), rel.(lbug.Relationship).Properties["since"])
+	assert.Equal(t, map[string]any{"name": "Karissa", "since": int64(2020)}, row)
+	assert.False(t, rows.Next())
+	assert.Nil(t, rows.Close())
+
+	_, err = db.QueryContext(ctx, "RETURN $1", 1)
+	assert.ErrorContains(t, err, "only support named arguments")
+	assert.Nil(t, db.Close())
+}
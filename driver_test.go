@@ -2,10 +2,14 @@ package lbug
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestDriver(t *testing.T) {
@@ -93,3 +97,32 @@ func TestDriver(t *testing.T) {
 		t.Log("Rows:" + fmt.Sprint(rs))
 	}
 }
+
+func TestParseDSN(t *testing.T) {
+	for dsn, expected := range map[string]string{
+		"./mydb":                    "./mydb",
+		":memory:":                  ":memory:",
+		":memory:?threads=1":        ":memory:",
+		"lbug:///tmp/db?readOnly=1": "/tmp/db",
+		"ladybug://./my%20db":       "./my db",
+		"/tmp/my%20db":              "/tmp/my%20db",
+	} {
+		path, _, err := parseDSN(dsn)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, path, dsn)
+	}
+	_, options, err := parseDSN("./mydb?poolSize=1GiB&readOnly=1")
+	assert.Nil(t, err)
+	assert.Equal(t, url.Values{"poolSize": {"1GiB"}, "readOnly": {"1"}}, options)
+	_, _, err = parseDSN("./mydb?threads=%zz")
+	assert.NotNil(t, err)
+}
+
+func TestStatementNamedArgs(t *testing.T) {
+	stmt := &statement{query: "RETURN $x"}
+	args, err := stmt.namedArgs([]driver.NamedValue{{Name: "x", Ordinal: 1, Value: []any{int64(1)}}})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"x": []any{int64(1)}}, args)
+	_, err = stmt.namedArgs([]driver.NamedValue{{Ordinal: 1, Value: int64(1)}})
+	assert.ErrorContains(t, err, "positional argument 1")
+}
@@ -0,0 +1,468 @@
+package lbug
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The operations reported by Error.Op.
+const (
+	OpOpen    = "open"
+	OpPrepare = "prepare"
+	OpBind    = "bind"
+	OpExecute = "execute"
+	OpIterate = "iterate"
+	OpConvert = "convert"
+	OpClose   = "close"
+)
+
+// Error is returned when an operation of the engine fails: opening a database
+// or connection, preparing a statement, binding a parameter, executing a
+// query, iterating over a result, converting a value or closing handles. The
+// errors of these operations can always be retrieved with errors.As, also when
+// they are wrapped by the helpers of this package, and the errors they
+// originate from, such as ErrClosed, remain in the chain.
+type Error struct {
+	// Op is the operation that failed, one of the Op constants.
+	Op string
+	// Query is the query being prepared or executed, if any.
+	Query string
+	// Parameter is the name of the parameter that failed to be bound.
+	Parameter string
+	// Column is the index of the column whose value failed to be converted.
+	Column uint64
+	// Message describes the failure. If the engine reported it, Message is
+	// the error message of the engine, verbatim.
+	Message string
+	// Err is the error that caused the failure, if any.
+	Err error
+}
+
+func (err *Error) Error() string {
+	switch {
+	case err.Err == nil:
+		return err.Message
+	case err.Message == "":
+		return err.Err.Error()
+	}
+	return err.Message + ": " + err.Err.Error()
+}
+
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+// ErrLossyIntervalConversion is returned when an INTERVAL value cannot be
+// converted to a time.Duration without losing information, because it has a
+// months component whose length in days is not fixed.
+var ErrLossyIntervalConversion = errors.New("lossy interval conversion")
+
+// ErrUnorderedResult is returned by the features that rely on a stable order
+// of the rows when ordered iteration is required on the connection and the
+// query has no ORDER BY. See Connection.SetRequireOrderedIteration.
+var ErrUnorderedResult = errors.New("query result is not ordered: add an ORDER BY to the final RETURN clause")
+
+// ErrClosed is matched with errors.Is by the errors returned by the operations
+// on a Database, Connection, PreparedStatement, QueryResult or FlatTuple that
+// has been closed, e.g. by CloseAll.
+var ErrClosed = errors.New("handle is closed")
+
+// closedError is an error returned by an operation on a closed handle. It
+// matches ErrClosed with errors.Is.
+type closedError struct {
+	message string
+}
+
+func (err *closedError) Error() string {
+	return err.message
+}
+
+func (err *closedError) Unwrap() error {
+	return ErrClosed
+}
+
+// ErrColumnIndexOutOfRange is returned when a column index passed to an
+// accessor of FlatTuple is not smaller than the number of columns.
+var ErrColumnIndexOutOfRange = errors.New("column index out of range")
+
+// ColumnIndexError is returned when a column index passed to an accessor of
+// FlatTuple is out of range. It matches ErrColumnIndexOutOfRange with
+// errors.Is.
+type ColumnIndexError struct {
+	Index      uint64
+	NumColumns uint64
+}
+
+func (err *ColumnIndexError) Error() string {
+	if err.NumColumns == 0 {
+		return fmt.Sprintf("column index %d out of range: the tuple has no columns", err.Index)
+	}
+	return fmt.Sprintf("column index %d out of range [0, %d]", err.Index, err.NumColumns-1)
+}
+
+func (err *ColumnIndexError) Unwrap() error {
+	return ErrColumnIndexOutOfRange
+}
+
+// IntervalConversionError is returned when an INTERVAL value cannot be
+// converted to a time.Duration. The original Interval is attached so that
+// callers can fall back to handling it themselves. It matches
+// ErrLossyIntervalConversion with errors.Is when the conversion failed
+// because of a months component.
+type IntervalConversionError struct {
+	Interval Interval
+	// Overflow is true if the interval is out of the range of time.Duration.
+	Overflow bool
+}
+
+func (err *IntervalConversionError) Error() string {
+	if err.Overflow {
+		return fmt.Sprintf("interval %v is out of the range of time.Duration", err.Interval)
+	}
+	return fmt.Sprintf("interval %v has a months component and cannot be converted to time.Duration exactly", err.Interval)
+}
+
+func (err *IntervalConversionError) Unwrap() error {
+	if err.Overflow {
+		return nil
+	}
+	return ErrLossyIntervalConversion
+}
+
+// GroupQueryError is returned by Connection.QueryGroup when one of its queries
+// fails. The whole group fails with it.
+type GroupQueryError struct {
+	// Index is the index of the failed query in the group.
+	Index int
+	Query string
+	Err   error
+}
+
+func (err *GroupQueryError) Error() string {
+	return fmt.Sprintf("query %d of the group failed: %v", err.Index, err.Err)
+}
+
+func (err *GroupQueryError) Unwrap() error {
+	return err.Err
+}
+
+// ErrTimeOutOfRange is matched with errors.Is by the error returned when a
+// DATE or TIMESTAMP value is outside the years 0 to 9999 converted to
+// time.Time, and when a time.Time parameter is outside the range of the type
+// it is bound as.
+var ErrTimeOutOfRange = errors.New("time out of range")
+
+// TimeRangeError is returned instead of a DATE or TIMESTAMP value outside the
+// years 0 to 9999 when the TimeRangePolicy is TimeRangeStrict. The raw value
+// is attached so that callers can handle it themselves. It matches
+// ErrTimeOutOfRange with errors.Is.
+type TimeRangeError struct {
+	Raw RawTime
+}
+
+func (err *TimeRangeError) Error() string {
+	return fmt.Sprintf("%s value of %d %s since the epoch is outside the years 0 to 9999 converted to time.Time",
+		err.Raw.Type, err.Raw.Value, err.Raw.unitName())
+}
+
+func (err *TimeRangeError) Unwrap() error {
+	return ErrTimeOutOfRange
+}
+
+// ErrRowLimitExceeded is matched with errors.Is by the error returned by
+// QueryResult.Next when the result has more rows than the limit set with
+// Connection.SetMaxRows.
+var ErrRowLimitExceeded = errors.New("row limit exceeded")
+
+// RowLimitError is returned by QueryResult.Next instead of the row after the
+// last one allowed by Connection.SetMaxRows. The rows beyond the limit are
+// not returned, so the result is truncated. It matches ErrRowLimitExceeded
+// with errors.Is.
+type RowLimitError struct {
+	// Limit is the maximum number of rows of the connection.
+	Limit uint64
+	// Rows is the number of rows of the complete result.
+	Rows uint64
+}
+
+func (err *RowLimitError) Error() string {
+	return fmt.Sprintf("query result has %d rows, more than the limit of %d: the result is truncated", err.Rows, err.Limit)
+}
+
+func (err *RowLimitError) Unwrap() error {
+	return ErrRowLimitExceeded
+}
+
+// ErrParameterTooLarge is matched with errors.Is by the error returned when a
+// parameter is larger than the limit set with Connection.SetMaxParameterSize.
+var ErrParameterTooLarge = errors.New("parameter too large")
+
+// ParameterSizeError is returned when a parameter is larger than the limit set
+// with Connection.SetMaxParameterSize. It matches ErrParameterTooLarge with
+// errors.Is.
+type ParameterSizeError struct {
+	Name string
+	// Size is the estimated size of the parameter in bytes, or a lower bound
+	// of it larger than the limit.
+	Size  uint64
+	Limit uint64
+}
+
+func (err *ParameterSizeError) Error() string {
+	return fmt.Sprintf("parameter $%s has at least %d bytes, more than the limit of %d: load large data with COPY FROM, or spool large lists to a file with Connection.QueryWithSpill", err.Name, err.Size, err.Limit)
+}
+
+func (err *ParameterSizeError) Unwrap() error {
+	return ErrParameterTooLarge
+}
+
+// ErrPathTooLong is matched with errors.Is by the error returned when a
+// RECURSIVE_REL value has more nodes and relationships than the limit set with
+// Connection.SetMaxPathElements.
+var ErrPathTooLong = errors.New("path too long")
+
+// PathLengthError is returned instead of a RECURSIVE_REL value with more
+// elements than the limit set with Connection.SetMaxPathElements. The value is
+// not decoded. It matches ErrPathTooLong with errors.Is.
+type PathLengthError struct {
+	// Elements is the number of nodes and relationships of the path.
+	Elements uint64
+	Limit    uint64
+}
+
+func (err *PathLengthError) Error() string {
+	return fmt.Sprintf("path has %d nodes and relationships, more than the limit of %d", err.Elements, err.Limit)
+}
+
+func (err *PathLengthError) Unwrap() error {
+	return ErrPathTooLong
+}
+
+// ErrTooManyOpenHandles is matched with errors.Is by the error returned when a
+// C handle would exceed a limit set with SetMaxOpenHandles.
+var ErrTooManyOpenHandles = errors.New("too many open handles")
+
+// HandleLimitError is returned instead of creating a C handle that would
+// exceed a limit set with SetMaxOpenHandles. It matches ErrTooManyOpenHandles
+// with errors.Is.
+type HandleLimitError struct {
+	// Kind is the kind of the handle that was not created.
+	Kind HandleKind
+	// Limit is the limit reached, of the handles of the kind, or of the
+	// limited handles together if Total is true.
+	Limit int64
+	Total bool
+	// Open are the counts of the open handles by kind when the handle was
+	// rejected, as returned by OpenHandleCounts.
+	Open map[HandleKind]int64
+}
+
+func (err *HandleLimitError) Error() string {
+	limited := err.Kind.String() + " handles"
+	if err.Total {
+		limited = "PreparedStatement, QueryResult and FlatTuple handles together"
+	}
+	counts := make([]string, 0, len(err.Open))
+	for kind := HandleKind(0); kind < numHandleKinds; kind++ {
+		counts = append(counts, fmt.Sprintf("%s=%d", kind, err.Open[kind]))
+	}
+	return fmt.Sprintf("cannot open a %s: the limit of %d open %s is reached (open handles: %s); close the handles that are no longer used", err.Kind, err.Limit, limited, strings.Join(counts, ", "))
+}
+
+func (err *HandleLimitError) Unwrap() error {
+	return ErrTooManyOpenHandles
+}
+
+// ErrInvalidString is matched with errors.Is by the error returned when a
+// query or a parameter has a string that is not valid UTF-8 or has a NUL
+// byte, see Connection.SetValidateStrings.
+var ErrInvalidString = errors.New("invalid string")
+
+// InvalidStringError is returned instead of passing an invalid string to the C
+// API, see Connection.SetValidateStrings. It matches ErrInvalidString with
+// errors.Is.
+type InvalidStringError struct {
+	// Parameter is the name of the parameter with the string, or empty for
+	// the text of a query.
+	Parameter string
+	// Path locates the string in the value of the parameter, e.g. "[2].name"
+	// for the name field of its third element, or is empty for the value
+	// itself. The names of fields and parameters and the keys of maps are
+	// located in braces, e.g. [2]{field "name"} for the name of the field
+	// itself.
+	Path string
+	// Offset is the offset of the invalid byte in the string.
+	Offset int
+	// NUL is true for a NUL byte, and false for invalid UTF-8.
+	NUL bool
+}
+
+func (err *InvalidStringError) Error() string {
+	problem := "invalid UTF-8"
+	if err.NUL {
+		problem = "a NUL byte"
+	}
+	if err.Parameter == "" {
+		return fmt.Sprintf("query has %s at byte %d", problem, err.Offset)
+	}
+	location := "parameter $" + err.Parameter
+	if err.Path != "" {
+		location += " at " + err.Path
+	}
+	return fmt.Sprintf("%s has %s at byte %d: wrap strings of arbitrary bytes in RawString", location, problem, err.Offset)
+}
+
+func (err *InvalidStringError) Unwrap() error {
+	return ErrInvalidString
+}
+
+// ErrStatementDenied is matched with errors.Is by the error returned when the
+// statement policy of the connection denies a statement of a query, see
+// Connection.SetStatementPolicy.
+var ErrStatementDenied = errors.New("statement denied")
+
+// StatementDeniedError is returned instead of running a query with a statement
+// denied by the statement policy of the connection. It matches
+// ErrStatementDenied with errors.Is.
+type StatementDeniedError struct {
+	// Statement is the denied statement.
+	Statement Statement
+	// Reason is the reason given by the policy.
+	Reason string
+}
+
+func (err *StatementDeniedError) Error() string {
+	message := fmt.Sprintf("%s statement %d of the query is denied by the statement policy", err.Statement.Type, err.Statement.Index+1)
+	if err.Reason != "" {
+		message += ": " + err.Reason
+	}
+	return message
+}
+
+func (err *StatementDeniedError) Unwrap() error {
+	return ErrStatementDenied
+}
+
+// ErrOpenResultInTransaction is matched with errors.Is by the error returned
+// when a statement that may write is executed in an explicit transaction while
+// a result of the transaction has not been fully iterated, see
+// Connection.Query.
+var ErrOpenResultInTransaction = errors.New("write with an open result in the transaction")
+
+// OpenResultError is returned instead of executing a statement that may write
+// in an explicit transaction while a result of the transaction is open and has
+// tuples left. It identifies the open result, which must be fully iterated or
+// closed first. It matches ErrOpenResultInTransaction with errors.Is.
+type OpenResultError struct {
+	// Query is the query of the open result.
+	Query string
+	// Fetched is the number of tuples fetched from the open result.
+	Fetched uint64
+	// Rows is the number of tuples of the open result.
+	Rows uint64
+}
+
+func (err *OpenResultError) Error() string {
+	return fmt.Sprintf("cannot write in the transaction while the result of %q is open with %d of its %d tuples fetched: iterate it fully or close it first",
+		err.Query, err.Fetched, err.Rows)
+}
+
+func (err *OpenResultError) Unwrap() error {
+	return ErrOpenResultInTransaction
+}
+
+// ErrResultInvalidated is matched with errors.Is by the error returned when a
+// query result is iterated after its connection has been closed, or after
+// another connection to the database has run a CHECKPOINT or changed the
+// schema, see ResultInvalidatedError.
+var ErrResultInvalidated = errors.New("query result invalidated")
+
+// InvalidationCause is the event that invalidated a query result.
+type InvalidationCause int
+
+const (
+	// InvalidatedByCheckpoint means that another connection ran a
+	// CHECKPOINT statement.
+	InvalidatedByCheckpoint InvalidationCause = iota + 1
+	// InvalidatedBySchemaChange means that another connection changed the
+	// schema, e.g. with ALTER TABLE or DROP TABLE, and committed it.
+	InvalidatedBySchemaChange
+	// InvalidatedByClose means that the connection of the result was closed.
+	InvalidatedByClose
+)
+
+func (cause InvalidationCause) String() string {
+	switch cause {
+	case InvalidatedByCheckpoint:
+		return "checkpoint"
+	case InvalidatedBySchemaChange:
+		return "schema change"
+	case InvalidatedByClose:
+		return "connection closed"
+	}
+	return fmt.Sprintf("InvalidationCause(%d)", int(cause))
+}
+
+// ResultInvalidatedError is returned instead of fetching the next tuple of a
+// query result invalidated by a checkpoint, a schema change or the closing of
+// its connection, rather than letting the engine read storage that may have
+// been rewritten. The tuples already delivered remain valid, and the
+// connection of the result remains usable: the query can be run again, or
+// materialized with QueryResult.Freeze before the invalidating statements run.
+// Checkpoints and schema changes are detected when they are run through the
+// connections of the process, not when the engine checkpoints on its own, and
+// do not invalidate the results of their own connection. It matches
+// ErrResultInvalidated, and ErrClosed if the connection was closed, with
+// errors.Is.
+type ResultInvalidatedError struct {
+	// Query is the query of the result.
+	Query string
+	// Cause is the event that invalidated the result.
+	Cause InvalidationCause
+	// Delivered is the number of tuples fetched from the result before it
+	// was invalidated.
+	Delivered uint64
+}
+
+func (err *ResultInvalidatedError) Error() string {
+	return fmt.Sprintf("result of %q invalidated (%s) after %d tuples delivered", err.Query, err.Cause, err.Delivered)
+}
+
+func (err *ResultInvalidatedError) Unwrap() []error {
+	if err.Cause == InvalidatedByClose {
+		return []error{ErrResultInvalidated, ErrClosed}
+	}
+	return []error{ErrResultInvalidated}
+}
+
+// ErrSnapshotExpired is returned by the methods of a SnapshotConn released
+// because it was not used for the idle timeout of its connection.
+var ErrSnapshotExpired = errors.New("snapshot expired")
+
+// ErrCloseTimedOut is matched with errors.Is by the errors returned when the
+// context passed to Connection.CloseWithContext, Database.CloseWithContext or
+// CloseAll is done before the handles are closed. The close continues in the
+// background.
+var ErrCloseTimedOut = errors.New("close timed out")
+
+// UnsupportedRegexError is returned by TranslateRegex and
+// PreparedStatement.BindRegex for a Go regular expression using constructs
+// that the regular expressions of the engine do not support or interpret
+// differently.
+type UnsupportedRegexError struct {
+	// Pattern is the Go regular expression.
+	Pattern string
+	// Constructs are the unsupported constructs, as written in the pattern,
+	// e.g. "(?U)".
+	Constructs []string
+}
+
+func (err *UnsupportedRegexError) Error() string {
+	return fmt.Sprintf("regular expression %q uses constructs not supported by the engine: %s", err.Pattern, strings.Join(err.Constructs, ", "))
+}
+
+// ErrTooManyBackgroundTasks is matched with errors.Is by the error returned
+// by an operation that would start goroutines exceeding the limit set with
+// SetMaxBackgroundTasks. The operation is not run.
+var ErrTooManyBackgroundTasks = errors.New("too many background tasks")
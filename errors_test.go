@@ -0,0 +1,116 @@
+package lbug
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorMessage(t *testing.T) {
+	assert.Equal(t, "Interrupted.", (&Error{Op: OpExecute, Message: "Interrupted."}).Error())
+	closed := &Error{Op: OpIterate, Err: &closedError{"failed to get next tuple because the query result is closed"}}
+	assert.Equal(t, "failed to get next tuple because the query result is closed", closed.Error())
+	assert.ErrorIs(t, closed, ErrClosed)
+	wrapped := &Error{Op: OpOpen, Message: "failed to open database", Err: ErrEmptyPath}
+	assert.Equal(t, "failed to open database: database path is empty", wrapped.Error())
+	assert.ErrorIs(t, wrapped, ErrEmptyPath)
+}
+
+// assertError asserts that err has an *Error of the operation in its chain
+// and returns it.
+func assertError(t *testing.T, err error, op string) *Error {
+	t.Helper()
+	var lbugErr *Error
+	if !assert.True(t, errors.As(err, &lbugErr), "%v", err) {
+		return &Error{}
+	}
+	assert.Equal(t, op, lbugErr.Op)
+	return lbugErr
+}
+
+func TestErrorOpen(t *testing.T) {
+	_, err := OpenDatabase("", SystemConfigEmbeddedTest())
+	assert.ErrorIs(t, err, ErrEmptyPath)
+	assertError(t, err, OpOpen)
+
+	config := SystemConfigEmbeddedTest()
+	config.MaxNumThreads = 1 << 20
+	_, err = OpenDatabase(filepath.Join(t.TempDir(), "db"), config)
+	assertError(t, err, OpOpen)
+
+	db, err := OpenInMemoryDatabase(SystemConfigEmbeddedTest())
+	assert.Nil(t, err)
+	db.Close()
+	_, err = OpenConnection(db)
+	assert.ErrorIs(t, err, ErrClosed)
+	assertError(t, err, OpOpen)
+}
+
+func TestErrorPrepareAndExecute(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	const invalid = "MATCH (p:missing) RETURN p"
+	invalidStmt, err := conn.Prepare(invalid)
+	defer invalidStmt.Close()
+	lbugErr := assertError(t, err, OpPrepare)
+	assert.Equal(t, invalid, lbugErr.Query)
+	assert.NotEqual(t, "", lbugErr.Message)
+	assert.Equal(t, lbugErr.Message, err.Error())
+
+	_, err = conn.Query(invalid)
+	lbugErr = assertError(t, err, OpExecute)
+	assert.Equal(t, invalid, lbugErr.Query)
+	assert.Equal(t, lbugErr.Message, err.Error())
+
+	stmt, err := conn.Prepare("RETURN $value")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	_, err = conn.Execute(stmt, map[string]any{"value": struct{}{}})
+	lbugErr = assertError(t, err, OpBind)
+	assert.Equal(t, "value", lbugErr.Parameter)
+	assert.Equal(t, "RETURN $value", lbugErr.Query)
+	assert.NotNil(t, lbugErr.Err)
+
+	conn.SetMaxParameterSize(4)
+	_, err = stmt.Exec(map[string]any{"value": "too long"})
+	assert.ErrorIs(t, err, ErrParameterTooLarge)
+	assertError(t, err, OpBind)
+	conn.SetMaxParameterSize(0)
+
+	stmt.Close()
+	_, err = conn.Execute(stmt, nil)
+	assert.ErrorIs(t, err, ErrClosed)
+	assertError(t, err, OpExecute)
+}
+
+func TestErrorIterateAndConvert(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	result, err := conn.Query("RETURN 1, 2")
+	assert.Nil(t, err)
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+	_, err = tuple.GetValue(5)
+	assert.ErrorIs(t, err, ErrColumnIndexOutOfRange)
+	assert.Equal(t, uint64(5), assertError(t, err, OpConvert).Column)
+	_, err = tuple.GetInterval(1)
+	assert.Equal(t, uint64(1), assertError(t, err, OpConvert).Column)
+	tuple.Close()
+	_, err = tuple.GetAsSlice()
+	assert.ErrorIs(t, err, ErrClosed)
+	assertError(t, err, OpConvert)
+
+	result.Close()
+	_, err = result.Next()
+	assert.ErrorIs(t, err, ErrClosed)
+	assertError(t, err, OpIterate)
+	_, err = result.NextQueryResult()
+	assertError(t, err, OpIterate)
+}
+
+func TestErrorClose(t *testing.T) {
+	db, err := OpenInMemoryDatabase(SystemConfigEmbeddedTest())
+	assert.Nil(t, err)
+	defer db.Close()
+	assertError(t, CloseShared(db), OpClose)
+}
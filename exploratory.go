@@ -0,0 +1,154 @@
+package lbug
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ExploratoryMode bounds the results of the read queries of a connection
+// used interactively, e.g. by a query console, see
+// ConnectionOptions.Exploratory.
+type ExploratoryMode struct {
+	// DefaultLimit is the LIMIT appended to the read queries run with
+	// Connection.Query whose final RETURN has none. Zero disables the mode.
+	DefaultLimit int
+	// OnSkip, if set, is called with the reason when a read query is run
+	// without a LIMIT because its structure is not understood well enough to
+	// append one, e.g. for UNION queries, whose LIMIT would only apply to the
+	// last part.
+	OnSkip func(query string, reason string)
+}
+
+// InjectedLimit returns the LIMIT appended to the query of the QueryResult
+// by the ExploratoryMode of its connection, or zero if none was appended.
+func (queryResult *QueryResult) InjectedLimit() int {
+	return queryResult.injectedLimit
+}
+
+// applyExploratoryMode returns the query with the default LIMIT of the
+// exploratory mode of the connection appended if it applies, and the limit
+// appended.
+func (conn *Connection) applyExploratoryMode(query string) (string, int) {
+	mode := conn.exploratory
+	if mode.DefaultLimit <= 0 {
+		return query, 0
+	}
+	limited, reason := injectLimit(query, mode.DefaultLimit)
+	if reason != "" {
+		if mode.OnSkip != nil {
+			mode.OnSkip(query, reason)
+		}
+		return query, 0
+	}
+	if limited == query {
+		return query, 0
+	}
+	return limited, mode.DefaultLimit
+}
+
+// limitToken is a word or punctuation of a query scanned by injectLimit,
+// with the nesting depth of brackets and braces it appears at and the offset
+// of the rune following it.
+type limitToken struct {
+	text  string
+	depth int
+	end   int
+}
+
+// injectLimit returns the query with a LIMIT appended to its final RETURN,
+// before the trailing semicolons and comments, if it is a single read
+// statement without one. Other queries are returned unchanged, with a reason
+// if they are read queries whose limit cannot be determined safely.
+func injectLimit(query string, limit int) (string, string) {
+	keywords := queryKeywords(query)
+	statements := splitStatements(keywords)
+	if len(statements) == 0 {
+		return query, ""
+	}
+	for _, statement := range statements {
+		if statementType, _ := classifyStatement(statement); statementType != StatementRead {
+			return query, ""
+		}
+	}
+	if statements[0][0] == "EXPLAIN" || statements[0][0] == "PROFILE" {
+		return query, ""
+	}
+	if len(statements) > 1 {
+		return query, "the query has several statements"
+	}
+
+	runes := []rune(query)
+	tokens := scanLimitTokens(runes)
+	lastReturn := -1
+	for i, token := range tokens {
+		if token.depth > 0 {
+			continue
+		}
+		switch token.text {
+		case "UNION":
+			return query, "a LIMIT after UNION applies only to its last part"
+		case "RETURN":
+			lastReturn = i
+		}
+	}
+	if lastReturn < 0 {
+		return query, ""
+	}
+	end := 0
+	for _, token := range tokens[lastReturn:] {
+		if token.depth == 0 && token.text == "LIMIT" {
+			return query, ""
+		}
+		if token.text != ";" {
+			end = token.end
+		}
+	}
+	return string(runes[:end]) + fmt.Sprintf(" LIMIT %d", limit) + string(runes[end:]), ""
+}
+
+// scanLimitTokens splits a query into upper-cased words, other significant
+// runes and literals, skipping comments, as queryKeywords does but keeping
+// the nesting depth and position of the tokens.
+func scanLimitTokens(runes []rune) []limitToken {
+	var tokens []limitToken
+	depth := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			continue
+		case r == '\'' || r == '"' || r == '`':
+			for i++; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && r != '`' {
+					i++
+				}
+			}
+			tokens = append(tokens, limitToken{text: string(r), depth: depth, end: min(i+1, len(runes))})
+			continue
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			continue
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/'); i++ {
+			}
+			i++
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$':
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '_') {
+				i++
+			}
+		case r == '(' || r == '[' || r == '{':
+			depth++
+		case r == ')' || r == ']' || r == '}':
+			depth = max(depth-1, 0)
+			tokens = append(tokens, limitToken{text: string(r), depth: depth, end: i + 1})
+			continue
+		}
+		tokens = append(tokens, limitToken{text: strings.ToUpper(string(runes[start : i+1])), depth: depth, end: i + 1})
+	}
+	return tokens
+}
@@ -0,0 +1,121 @@
+package lbug
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInjectLimit(t *testing.T) {
+	limited := map[string]string{
+		"MATCH (n) RETURN n":                                          "MATCH (n) RETURN n LIMIT 10",
+		"MATCH (n) RETURN n;":                                         "MATCH (n) RETURN n LIMIT 10;",
+		"MATCH (n) RETURN n ;; ":                                      "MATCH (n) RETURN n LIMIT 10 ;; ",
+		"MATCH (n) RETURN n // all of them\n":                         "MATCH (n) RETURN n LIMIT 10 // all of them\n",
+		"MATCH (n) RETURN n; /* done */":                              "MATCH (n) RETURN n LIMIT 10; /* done */",
+		"MATCH (n) RETURN n ORDER BY n.id DESC SKIP 5":                "MATCH (n) RETURN n ORDER BY n.id DESC SKIP 5 LIMIT 10",
+		"MATCH (n) RETURN n.name = 'LIMIT'":                           "MATCH (n) RETURN n.name = 'LIMIT' LIMIT 10",
+		"MATCH (n) RETURN n.`limit`":                                  "MATCH (n) RETURN n.`limit` LIMIT 10",
+		"MATCH (n) RETURN n /* LIMIT 5 */":                            "MATCH (n) RETURN n LIMIT 10 /* LIMIT 5 */",
+		"MATCH (n) RETURN count(*)":                                   "MATCH (n) RETURN count(*) LIMIT 10",
+		"MATCH (n) WITH n LIMIT 5 RETURN n":                           "MATCH (n) WITH n LIMIT 5 RETURN n LIMIT 10",
+		"MATCH (n) WHERE EXISTS { MATCH (n)-->() } RETURN n":          "MATCH (n) WHERE EXISTS { MATCH (n)-->() } RETURN n LIMIT 10",
+		"UNWIND [1, 2] AS x RETURN [y IN range(1, x) | y]":            "UNWIND [1, 2] AS x RETURN [y IN range(1, x) | y] LIMIT 10",
+		"MATCH (n) RETURN {name: n.name, ids: [n.id]}":                "MATCH (n) RETURN {name: n.name, ids: [n.id]} LIMIT 10",
+		"MATCH (n) RETURN n.name AS `a ; b`":                          "MATCH (n) RETURN n.name AS `a ; b` LIMIT 10",
+		"RETURN 'it''s'":                                              "RETURN 'it''s' LIMIT 10",
+		"MATCH (n) RETURN n.name = \"RETURN \\\" x\"":                 "MATCH (n) RETURN n.name = \"RETURN \\\" x\" LIMIT 10",
+		"MATCH (n) CALL { WITH n MATCH (n)-->(m) RETURN m } RETURN m": "MATCH (n) CALL { WITH n MATCH (n)-->(m) RETURN m } RETURN m LIMIT 10",
+	}
+	for query, expected := range limited {
+		actual, reason := injectLimit(query, 10)
+		assert.Equal(t, expected, actual, query)
+		assert.Empty(t, reason, query)
+	}
+
+	untouched := []string{
+		"MATCH (n) RETURN n LIMIT 5",
+		"MATCH (n) RETURN n limit $n;",
+		"MATCH (n) RETURN n SKIP 1 LIMIT 5 // more",
+		"MATCH (n) SET n.x = 1 RETURN n",
+		"CREATE (:person {id: 1})",
+		"MERGE (n:person {id: 1}) RETURN n",
+		"CALL show_tables() RETURN *",
+		"CREATE NODE TABLE t(id INT64, PRIMARY KEY(id))",
+		"COPY person FROM 'people.csv'",
+		"EXPLAIN MATCH (n) RETURN n",
+		"PROFILE MATCH (n) RETURN n",
+		"MATCH (n) RETURN n; CREATE (:person {id: 2})",
+		"BEGIN TRANSACTION",
+		"// just a comment",
+		"",
+	}
+	for _, query := range untouched {
+		actual, reason := injectLimit(query, 10)
+		assert.Equal(t, query, actual, query)
+		assert.Empty(t, reason, query)
+	}
+
+	skipped := map[string]string{
+		"MATCH (a:A) RETURN a.x UNION MATCH (b:B) RETURN b.x": "UNION",
+		"RETURN 1 UNION ALL RETURN 2;":                        "UNION",
+		"MATCH (n) RETURN n; MATCH (m) RETURN m;":             "several statements",
+	}
+	for query, reason := range skipped {
+		actual, actualReason := injectLimit(query, 10)
+		assert.Equal(t, query, actual, query)
+		assert.Contains(t, actualReason, reason, query)
+	}
+}
+
+func TestExploratoryMode(t *testing.T) {
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	var skipped []string
+	conn, err := OpenConnectionWithOptions(db, ConnectionOptions{Exploratory: ExploratoryMode{
+		DefaultLimit: 3,
+		OnSkip:       func(query string, reason string) { skipped = append(skipped, query) },
+	}})
+	assert.Nil(t, err)
+	defer conn.Close()
+	var recording bytes.Buffer
+	conn.EnableRecording(&recording)
+
+	mustRun(t, conn, "CREATE NODE TABLE item(id INT64, PRIMARY KEY(id));")
+	mustRun(t, conn, "UNWIND range(1, 10) AS i CREATE (:item {id: i});")
+	result, err := conn.Query("MATCH (i:item) RETURN i.id;")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), result.GetNumberOfRows())
+	assert.Equal(t, 3, result.InjectedLimit())
+	result.Close()
+	result, err = conn.Query("MATCH (i:item) RETURN i.id LIMIT 5;")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(5), result.GetNumberOfRows())
+	assert.Equal(t, 0, result.InjectedLimit())
+	result.Close()
+	result, err = conn.Query("MATCH (i:item) RETURN i.id UNION MATCH (i:item) RETURN i.id + 10;")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(20), result.GetNumberOfRows())
+	result.Close()
+	assert.Len(t, skipped, 1)
+
+	assert.Nil(t, conn.DisableRecording())
+	var operations []RecordedOperation
+	decoder := json.NewDecoder(&recording)
+	for decoder.More() {
+		var operation RecordedOperation
+		assert.Nil(t, decoder.Decode(&operation))
+		operations = append(operations, operation)
+	}
+	if assert.Len(t, operations, 5) {
+		assert.Equal(t, "MATCH (i:item) RETURN i.id;", operations[2].Query)
+		assert.Equal(t, 3, operations[2].InjectedLimit)
+		assert.Equal(t, 0, operations[3].InjectedLimit)
+	}
+
+	_, err = OpenConnectionWithOptions(db, ConnectionOptions{Exploratory: ExploratoryMode{DefaultLimit: -1}})
+	assert.ErrorContains(t, err, "must not be negative")
+}
@@ -0,0 +1,475 @@
+package lbug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The files of a database exported by EXPORT DATABASE or ExportTo.
+const (
+	exportSchemaFile = "schema.cypher"
+	exportCopyFile   = "copy.cypher"
+)
+
+// ExportDatabaseOptions configures Database.ExportTo.
+type ExportDatabaseOptions struct {
+	// Format is the format of the data files, "csv" or "parquet". The
+	// default is "csv".
+	Format string
+	// PerTable drives the export table by table from the bindings instead
+	// of running a single EXPORT DATABASE statement, which reports no
+	// progress until it completes. The files have the layout of EXPORT
+	// DATABASE, without the macros, and tables with SERIAL columns are not
+	// supported.
+	PerTable bool
+	// OnProgress, if set, is called before and after the export of every
+	// table with PerTable, and once the export completes otherwise.
+	OnProgress func(ExportProgress)
+}
+
+// ImportDatabaseOptions configures Database.ImportDatabase.
+type ImportDatabaseOptions struct {
+	// PerTable runs the statements of the exported files one by one from
+	// the bindings instead of running a single IMPORT DATABASE statement,
+	// which reports no progress until it completes.
+	PerTable bool
+	// OnProgress, if set, is called before and after the import of every
+	// table with PerTable, and once the import completes otherwise.
+	OnProgress func(ExportProgress)
+}
+
+// ExportProgress is the progress of an ExportTo or ImportDatabase, passed to
+// the OnProgress callback of its options.
+type ExportProgress struct {
+	// Table is the table being exported or imported, or empty once all are.
+	Table string
+	// TablesDone and TablesTotal are the numbers of data files exported or
+	// imported and to export or import: one per table, or per FROM/TO pair
+	// of a relationship table with several.
+	TablesDone  int
+	TablesTotal int
+	// Rows is the number of rows of the table exported or imported so far,
+	// or -1 if it is not known.
+	Rows int64
+	// Bytes is the size of the data files written or read so far.
+	Bytes int64
+}
+
+// TableExportReport reports the export or import of a table.
+type TableExportReport struct {
+	Table string
+	// File is the data file, relative to the directory for ExportTo and as
+	// named by copy.cypher for ImportDatabase.
+	File string
+	// Rows is the number of rows exported or imported, or -1 if it is not
+	// known.
+	Rows     int64
+	Bytes    int64
+	Duration time.Duration
+	// Warnings are the problems skipped by the loader of the engine while
+	// importing the table, see QueryResult.Warnings.
+	Warnings []LoadDiagnostic
+}
+
+// ExportReport reports a completed ExportTo or ImportDatabase.
+type ExportReport struct {
+	// Tables are the reports of the tables in export order. They are only
+	// reported with PerTable.
+	Tables   []TableExportReport
+	Bytes    int64
+	Duration time.Duration
+}
+
+// ExportTo exports the schema and the data of the database to the directory
+// with the layout of EXPORT DATABASE, so that it can be imported with
+// ImportDatabase or IMPORT DATABASE. The directory must not exist or be
+// empty.
+//
+// The copy.cypher file listing the data files is written last: if ExportTo
+// fails or the context is done before the export completes, ExportTo returns
+// an error, matching the error of the context if it is done, and leaves the
+// directory without copy.cypher, which marks it as incomplete; it must be
+// removed before the export is retried.
+func (db *Database) ExportTo(ctx context.Context, dir string, opts ExportDatabaseOptions) (ExportReport, error) {
+	format := strings.ToLower(opts.Format)
+	switch format {
+	case "":
+		format = "csv"
+	case "csv", "parquet":
+	default:
+		return ExportReport{}, fmt.Errorf("unsupported export format %q: must be csv or parquet", opts.Format)
+	}
+	if err := checkExportDirectory(dir); err != nil {
+		return ExportReport{}, err
+	}
+	conn, err := OpenConnection(db)
+	if err != nil {
+		return ExportReport{}, err
+	}
+	defer conn.Close()
+	export := &databaseTransfer{conn: conn, ctx: ctx, dir: dir, onProgress: opts.OnProgress, start: conn.now()}
+	if !opts.PerTable {
+		err = export.run(fmt.Sprintf("EXPORT DATABASE %s (format=%s);", quoteStringLiteral(dir), quoteStringLiteral(format)))
+		if err == nil {
+			err = export.finishWhole()
+		}
+	} else {
+		err = export.exportTables(format)
+	}
+	if err != nil {
+		return ExportReport{}, fmt.Errorf("failed to export the database to %s: %w", dir, err)
+	}
+	return export.report, nil
+}
+
+// ImportDatabase imports the schema and the data of a database exported to
+// the directory by ExportTo or EXPORT DATABASE into the database, which must
+// be empty. If the import fails or the context is done before it completes,
+// ImportDatabase returns an error, matching the error of the context if it
+// is done; with PerTable, the tables imported so far are kept.
+func (db *Database) ImportDatabase(ctx context.Context, dir string, opts ImportDatabaseOptions) (ExportReport, error) {
+	conn, err := OpenConnection(db)
+	if err != nil {
+		return ExportReport{}, err
+	}
+	defer conn.Close()
+	imp := &databaseTransfer{conn: conn, ctx: ctx, dir: dir, onProgress: opts.OnProgress, start: conn.now()}
+	if !opts.PerTable {
+		err = imp.run(fmt.Sprintf("IMPORT DATABASE %s;", quoteStringLiteral(dir)))
+		if err == nil {
+			err = imp.finishWhole()
+		}
+	} else {
+		err = imp.importTables()
+	}
+	if err != nil {
+		return ExportReport{}, fmt.Errorf("failed to import the database from %s: %w", dir, err)
+	}
+	return imp.report, nil
+}
+
+// checkExportDirectory checks that the directory does not exist or is empty.
+func checkExportDirectory(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("failed to export the database to %s: %w: the directory is not empty", dir, os.ErrExist)
+	}
+	return nil
+}
+
+// databaseTransfer is an ExportTo or ImportDatabase in progress.
+type databaseTransfer struct {
+	conn       *Connection
+	ctx        context.Context
+	dir        string
+	onProgress func(ExportProgress)
+	start      time.Time
+	report     ExportReport
+}
+
+// run runs a statement of the transfer.
+func (transfer *databaseTransfer) run(statement string) error {
+	result, err := transfer.conn.QueryWithContext(transfer.ctx, statement)
+	if err != nil {
+		return err
+	}
+	result.Close()
+	return nil
+}
+
+// progress reports the progress of the transfer.
+func (transfer *databaseTransfer) progress(table string, total int, rows int64) {
+	if transfer.onProgress != nil {
+		transfer.onProgress(ExportProgress{Table: table, TablesDone: len(transfer.report.Tables), TablesTotal: total, Rows: rows, Bytes: transfer.report.Bytes})
+	}
+}
+
+// finishWhole completes the report of a transfer run by a single statement
+// with the size of the data files of the directory.
+func (transfer *databaseTransfer) finishWhole() error {
+	entries, err := os.ReadDir(transfer.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".cypher") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		transfer.report.Bytes += info.Size()
+	}
+	transfer.report.Duration = transfer.conn.now().Sub(transfer.start)
+	transfer.progress("", 0, -1)
+	return nil
+}
+
+// exportFile is a data file of a table exported with PerTable.
+type exportFile struct {
+	table string
+	// The rows of the file are the values of the columns of the matches of
+	// the pattern, loaded back with the COPY FROM options.
+	pattern  string
+	columns  []string
+	copyFrom map[string]any
+	file     string
+}
+
+// exportTables exports the database table by table.
+func (transfer *databaseTransfer) exportTables(format string) error {
+	statements, err := transfer.conn.schemaStatements()
+	if err != nil {
+		return err
+	}
+	files, err := exportFiles(transfer.conn, format)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(transfer.dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(transfer.dir, exportSchemaFile), []byte(strings.Join(statements, "\n")+"\n"), 0o644); err != nil {
+		return err
+	}
+	options := ""
+	if format == "csv" {
+		options = " (header=true)"
+	}
+	var copies []string
+	for _, file := range files {
+		if err := transfer.ctx.Err(); err != nil {
+			return err
+		}
+		rows, err := queryCount(transfer.conn, "MATCH "+file.pattern+" RETURN count(*);")
+		if err != nil {
+			return err
+		}
+		transfer.progress(file.table, len(files), 0)
+		start := transfer.conn.now()
+		path := filepath.Join(transfer.dir, file.file)
+		query := fmt.Sprintf("COPY (MATCH %s RETURN %s) TO %s%s;", file.pattern, strings.Join(file.columns, ", "), quoteStringLiteral(path), options)
+		if err := transfer.run(query); err != nil {
+			return err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		transfer.report.Bytes += info.Size()
+		transfer.report.Tables = append(transfer.report.Tables, TableExportReport{
+			Table: file.table, File: file.file, Rows: int64(rows), Bytes: info.Size(), Duration: transfer.conn.now().Sub(start),
+		})
+		transfer.progress(file.table, len(files), int64(rows))
+		absolute, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		statement, err := copyFromStatement(file.table, absolute, file.copyFrom)
+		if err != nil {
+			return err
+		}
+		copies = append(copies, statement)
+	}
+	transfer.report.Duration = transfer.conn.now().Sub(transfer.start)
+	transfer.progress("", len(files), -1)
+	// Written last, see ExportTo.
+	return os.WriteFile(filepath.Join(transfer.dir, exportCopyFile), []byte(strings.Join(copies, "\n")+"\n"), 0o644)
+}
+
+// exportFiles returns the data files of the tables of the catalog, in the
+// order of ExportDDL.
+func exportFiles(conn *Connection, format string) ([]exportFile, error) {
+	tables, err := listTables(conn)
+	if err != nil {
+		return nil, err
+	}
+	var files []exportFile
+	used := make(map[string]bool)
+	fileName := func(parts ...string) string {
+		base := exportFilePattern.ReplaceAllString(strings.Join(parts, "_"), "_")
+		name := base + "." + format
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s_%d.%s", base, i, format)
+		}
+		used[name] = true
+		return name
+	}
+	for _, name := range sortedTableNames(tables) {
+		schema, err := describeTable(conn, name, tables[name])
+		if err != nil {
+			return nil, err
+		}
+		var properties []string
+		variable := "n."
+		if schema.isRel {
+			variable = "r."
+		}
+		for _, column := range schema.columns {
+			if column.dataType == "SERIAL" {
+				return nil, fmt.Errorf("table %s has SERIAL column %s, which cannot be exported table by table", name, column.name)
+			}
+			properties = append(properties, variable+QuoteIdentifier(column.name))
+		}
+		var copyFrom map[string]any
+		if format == "csv" {
+			copyFrom = map[string]any{"header": true}
+		}
+		if !schema.isRel {
+			files = append(files, exportFile{table: name, pattern: "(n:" + QuoteIdentifier(name) + ")", columns: properties, copyFrom: copyFrom, file: fileName(name)})
+			continue
+		}
+		sortConnections(schema.connections)
+		for _, connection := range schema.connections {
+			file := exportFile{
+				table:    name,
+				pattern:  fmt.Sprintf("(a:%s)-[r:%s]->(b:%s)", QuoteIdentifier(connection.from), QuoteIdentifier(name), QuoteIdentifier(connection.to)),
+				columns:  append([]string{"a." + QuoteIdentifier(connection.fromPrimaryKey), "b." + QuoteIdentifier(connection.toPrimaryKey)}, properties...),
+				copyFrom: map[string]any{},
+				file:     fileName(name),
+			}
+			for key, value := range copyFrom {
+				file.copyFrom[key] = value
+			}
+			if len(schema.connections) > 1 {
+				file.file = fileName(name, connection.from, connection.to)
+				file.copyFrom["from"] = connection.from
+				file.copyFrom["to"] = connection.to
+			}
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// exportFilePattern matches the characters of table names replaced in the
+// names of the data files.
+var exportFilePattern = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// copyStatementPattern matches the table and the path of a statement of
+// copy.cypher.
+var copyStatementPattern = regexp.MustCompile("(?i)^COPY\\s+(`(?:[^`]|``)+`|\\w+)\\s+FROM\\s+(?:'((?:[^'\\\\]|\\\\.)*)'|\"((?:[^\"\\\\]|\\\\.)*)\")")
+
+// unescapeStringLiteral returns the string of the content of a quoted Cypher
+// string literal.
+func unescapeStringLiteral(content string) string {
+	var builder strings.Builder
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\\' && i+1 < len(content) {
+			i++
+		}
+		builder.WriteByte(content[i])
+	}
+	return builder.String()
+}
+
+// importTables imports the exported files statement by statement.
+func (transfer *databaseTransfer) importTables() error {
+	content, err := os.ReadFile(filepath.Join(transfer.dir, exportCopyFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("the directory has no %s, the export is incomplete", exportCopyFile)
+	}
+	if err != nil {
+		return err
+	}
+	var copies []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			copies = append(copies, line)
+		}
+	}
+	schema, err := os.ReadFile(filepath.Join(transfer.dir, exportSchemaFile))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(schema)) != "" {
+		if err := transfer.run(string(schema)); err != nil {
+			return fmt.Errorf("failed to create the schema: %w", err)
+		}
+	}
+	tables, err := listTables(transfer.conn)
+	if err != nil {
+		return err
+	}
+	for _, statement := range copies {
+		if err := transfer.ctx.Err(); err != nil {
+			return err
+		}
+		match := copyStatementPattern.FindStringSubmatchIndex(statement)
+		if match == nil {
+			return fmt.Errorf("unexpected statement in %s: %s", exportCopyFile, statement)
+		}
+		table := statement[match[2]:match[3]]
+		if strings.HasPrefix(table, "`") {
+			table = strings.ReplaceAll(table[1:len(table)-1], "``", "`")
+		}
+		// The path is the single- or double-quoted literal.
+		literal := match[4:6]
+		if literal[0] < 0 {
+			literal = match[6:8]
+		}
+		file := unescapeStringLiteral(statement[literal[0]:literal[1]])
+		path := file
+		if !filepath.IsAbs(path) {
+			// Relative to the directory rather than to the working
+			// directory of the process.
+			path = filepath.Join(transfer.dir, path)
+			statement = statement[:literal[0]-1] + quoteStringLiteral(path) + statement[literal[1]+1:]
+		}
+		var size int64
+		if info, err := os.Stat(path); err == nil {
+			size = info.Size()
+		}
+		count := "MATCH (n:" + QuoteIdentifier(table) + ") RETURN count(*);"
+		if tables[table] {
+			count = "MATCH ()-[r:" + QuoteIdentifier(table) + "]->() RETURN count(*);"
+		}
+		before, err := queryCount(transfer.conn, count)
+		if err != nil {
+			return err
+		}
+		transfer.progress(table, len(copies), 0)
+		start := transfer.conn.now()
+		result, err := transfer.conn.QueryWithContext(transfer.ctx, statement)
+		if err != nil {
+			return loadError(err)
+		}
+		warnings, err := result.Warnings()
+		result.Close()
+		if err != nil {
+			return err
+		}
+		after, err := queryCount(transfer.conn, count)
+		if err != nil {
+			return err
+		}
+		rows := int64(after) - int64(before)
+		transfer.report.Bytes += size
+		transfer.report.Tables = append(transfer.report.Tables, TableExportReport{
+			Table: table, File: file, Rows: rows, Bytes: size, Duration: transfer.conn.now().Sub(start), Warnings: warnings,
+		})
+		transfer.progress(table, len(copies), rows)
+	}
+	// The macros of EXPORT DATABASE, which may use the tables.
+	if macros, err := os.ReadFile(filepath.Join(transfer.dir, "macro.cypher")); err == nil && strings.TrimSpace(string(macros)) != "" {
+		if err := transfer.run(string(macros)); err != nil {
+			return fmt.Errorf("failed to create the macros: %w", err)
+		}
+	}
+	transfer.report.Duration = transfer.conn.now().Sub(transfer.start)
+	transfer.progress("", len(copies), -1)
+	return nil
+}
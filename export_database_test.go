@@ -0,0 +1,176 @@
+package lbug
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// openExportTestDatabase returns a database with node tables and a
+// relationship table with two FROM/TO pairs.
+func openExportTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	t.Cleanup(db.Close)
+	conn, err := OpenConnection(db)
+	if err != nil {
+		t.Fatalf("Error opening connection: %v", err)
+	}
+	defer conn.Close()
+	for _, query := range []string{
+		"CREATE NODE TABLE User(id INT64, name STRING, PRIMARY KEY(id));",
+		"CREATE NODE TABLE `City of`(name STRING, PRIMARY KEY(name));",
+		"CREATE REL TABLE Likes(FROM User TO User, FROM User TO `City of`, since INT64);",
+		"UNWIND range(1, 20) AS i CREATE (:User {id: i, name: 'user' + CAST(i AS STRING)});",
+		"CREATE (:`City of` {name: 'Paris'}), (:`City of` {name: 'Oslo'});",
+		"MATCH (a:User), (b:User) WHERE b.id = a.id + 1 CREATE (a)-[:Likes {since: a.id}]->(b);",
+		"MATCH (a:User), (c:`City of`) WHERE a.id <= 3 CREATE (a)-[:Likes {since: 0}]->(c);",
+	} {
+		mustRun(t, conn, query)
+	}
+	return db
+}
+
+func exportTestCounts(t *testing.T, db *Database) []uint64 {
+	t.Helper()
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	var counts []uint64
+	for _, query := range []string{
+		"MATCH (n:User) RETURN count(*);",
+		"MATCH (n:`City of`) RETURN count(*);",
+		"MATCH ()-[r:Likes]->() RETURN count(*);",
+	} {
+		count, err := queryCount(conn, query)
+		assert.Nil(t, err)
+		counts = append(counts, count)
+	}
+	return counts
+}
+
+func TestExportToPerTable(t *testing.T) {
+	db := openExportTestDatabase(t)
+	dir := filepath.Join(t.TempDir(), "export")
+	var progress []ExportProgress
+	report, err := db.ExportTo(context.Background(), dir, ExportDatabaseOptions{
+		PerTable:   true,
+		OnProgress: func(p ExportProgress) { progress = append(progress, p) },
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, report.Tables, 4) {
+		assert.Equal(t, "City of", report.Tables[0].Table)
+		assert.Equal(t, "City_of.csv", report.Tables[0].File)
+		assert.Equal(t, int64(2), report.Tables[0].Rows)
+		assert.Equal(t, int64(20), report.Tables[1].Rows)
+		assert.Equal(t, "Likes_User_City_of.csv", report.Tables[2].File)
+		assert.Equal(t, int64(3), report.Tables[2].Rows)
+		assert.Equal(t, "Likes_User_User.csv", report.Tables[3].File)
+		assert.Equal(t, int64(19), report.Tables[3].Rows)
+	}
+	var total int64
+	for _, table := range report.Tables {
+		assert.Greater(t, table.Bytes, int64(0))
+		total += table.Bytes
+	}
+	assert.Equal(t, total, report.Bytes)
+	// Before and after every table, and once at the end.
+	if assert.Len(t, progress, 9) {
+		assert.Equal(t, ExportProgress{Table: "City of", TablesDone: 0, TablesTotal: 4, Rows: 0, Bytes: 0}, progress[0])
+		assert.Equal(t, ExportProgress{Table: "City of", TablesDone: 1, TablesTotal: 4, Rows: 2, Bytes: report.Tables[0].Bytes}, progress[1])
+		assert.Equal(t, ExportProgress{TablesDone: 4, TablesTotal: 4, Rows: -1, Bytes: total}, progress[8])
+	}
+	assert.FileExists(t, filepath.Join(dir, "copy.cypher"))
+
+	imported, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer imported.Close()
+	progress = nil
+	importReport, err := imported.ImportDatabase(context.Background(), dir, ImportDatabaseOptions{
+		PerTable:   true,
+		OnProgress: func(p ExportProgress) { progress = append(progress, p) },
+	})
+	assert.Nil(t, err)
+	assert.Len(t, importReport.Tables, 4)
+	assert.Equal(t, report.Bytes, importReport.Bytes)
+	assert.Len(t, progress, 9)
+	assert.Equal(t, exportTestCounts(t, db), exportTestCounts(t, imported))
+	var original, copied bytes.Buffer
+	assert.Nil(t, db.ExportDDL(&original))
+	assert.Nil(t, imported.ExportDDL(&copied))
+	assert.Equal(t, original.String(), copied.String())
+}
+
+func TestExportToWhole(t *testing.T) {
+	db := openExportTestDatabase(t)
+	dir := filepath.Join(t.TempDir(), "export")
+	calls := 0
+	report, err := db.ExportTo(context.Background(), dir, ExportDatabaseOptions{OnProgress: func(ExportProgress) { calls++ }})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Greater(t, report.Bytes, int64(0))
+	assert.Empty(t, report.Tables)
+
+	imported, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer imported.Close()
+	_, err = imported.ImportDatabase(context.Background(), dir, ImportDatabaseOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, exportTestCounts(t, db), exportTestCounts(t, imported))
+}
+
+func TestExportToCancelled(t *testing.T) {
+	db := openExportTestDatabase(t)
+	dir := filepath.Join(t.TempDir(), "export")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := db.ExportTo(ctx, dir, ExportDatabaseOptions{PerTable: true})
+	assert.ErrorIs(t, err, context.Canceled)
+	// The directory is incomplete: it has no copy.cypher.
+	assert.FileExists(t, filepath.Join(dir, "schema.cypher"))
+	assert.NoFileExists(t, filepath.Join(dir, "copy.cypher"))
+	imported, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer imported.Close()
+	_, err = imported.ImportDatabase(context.Background(), dir, ImportDatabaseOptions{PerTable: true})
+	assert.ErrorContains(t, err, "the export is incomplete")
+
+	// The directory must be removed before the export is retried.
+	_, err = db.ExportTo(context.Background(), dir, ExportDatabaseOptions{PerTable: true})
+	assert.ErrorIs(t, err, os.ErrExist)
+}
+
+func TestExportToErrors(t *testing.T) {
+	db := openExportTestDatabase(t)
+	_, err := db.ExportTo(context.Background(), t.TempDir(), ExportDatabaseOptions{Format: "xml"})
+	assert.ErrorContains(t, err, "unsupported export format")
+
+	serial, conn := SetupTestDatabase(t)
+	defer serial.Close()
+	defer conn.Close()
+	_, err = serial.ExportTo(context.Background(), filepath.Join(t.TempDir(), "export"), ExportDatabaseOptions{PerTable: true})
+	assert.ErrorContains(t, err, "SERIAL column")
+}
+
+func TestCopyStatementPattern(t *testing.T) {
+	for statement, expected := range map[string][]string{
+		`COPY User FROM "/data/User.csv" (header=true);`:           {"User", "/data/User.csv"},
+		"COPY `City of` FROM 'City_of.csv';":                       {"`City of`", "City_of.csv"},
+		`copy Likes from 'it\'s here.parquet' (from='a', to='b');`: {"Likes", `it\'s here.parquet`},
+	} {
+		match := copyStatementPattern.FindStringSubmatch(statement)
+		if assert.NotNil(t, match, statement) {
+			assert.Equal(t, expected[0], match[1])
+			assert.Equal(t, expected[1], match[2]+match[3])
+		}
+	}
+	assert.Nil(t, copyStatementPattern.FindStringSubmatch("CREATE NODE TABLE A(id INT64);"))
+	assert.Equal(t, `it's a \ path`, unescapeStringLiteral(`it\'s a \\ path`))
+}
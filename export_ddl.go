@@ -0,0 +1,138 @@
+package lbug
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ExportDDL writes the schema of the database to w as the DDL statements
+// creating it, one per line: the sequences, the node tables, the
+// relationship tables and the indexes, each sorted by name, with the columns
+// of the tables in declaration order. Identifiers are always quoted and
+// options are always spelled out, so databases with the same schema export
+// the same text, e.g. to detect schema drift in CI, and running the exported
+// statements on an empty database creates a database exporting the same
+// text again. Indexes of extensions are exported with their definition, so
+// the extensions must be loaded to run them.
+func (db *Database) ExportDDL(w io.Writer) error {
+	conn, err := OpenConnection(db)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	statements, err := conn.schemaStatements()
+	if err != nil {
+		return fmt.Errorf("failed to export the schema: %w", err)
+	}
+	for _, statement := range statements {
+		if _, err := io.WriteString(w, statement+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaStatements returns the DDL statements exported by ExportDDL.
+func (conn *Connection) schemaStatements() ([]string, error) {
+	tables, err := listTables(conn)
+	if err != nil {
+		return nil, err
+	}
+	var tableStatements []string
+	serialSequences := make(map[string]bool)
+	for _, name := range sortedTableNames(tables) {
+		schema, err := describeTable(conn, name, tables[name])
+		if err != nil {
+			return nil, err
+		}
+		sortConnections(schema.connections)
+		for _, column := range schema.columns {
+			if column.dataType == "SERIAL" {
+				serialSequences[serialSequenceName(name, column.name)] = true
+			}
+		}
+		tableStatements = append(tableStatements, schema.createTableStatement())
+	}
+	// The sequences first, since DEFAULT expressions may use them.
+	statements, err := sequenceStatements(conn, serialSequences)
+	if err != nil {
+		return nil, err
+	}
+	statements = append(statements, tableStatements...)
+	indexes, err := conn.ListIndexes()
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		definition := strings.TrimSpace(index.Definition)
+		if definition == "" {
+			return nil, fmt.Errorf("index %s of table %s has no definition", index.Name, index.Table)
+		}
+		if !strings.HasSuffix(definition, ";") {
+			definition += ";"
+		}
+		statements = append(statements, definition)
+	}
+	return statements, nil
+}
+
+// sortedTableNames returns the names of the tables sorted by name, the node
+// tables first, since the relationship tables depend on them.
+func sortedTableNames(tables map[string]bool) []string {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if tables[names[i]] != tables[names[j]] {
+			return !tables[names[i]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// sortConnections sorts the FROM/TO pairs of a relationship table.
+func sortConnections(connections []relConnection) {
+	sort.Slice(connections, func(i, j int) bool {
+		if connections[i].from != connections[j].from {
+			return connections[i].from < connections[j].from
+		}
+		return connections[i].to < connections[j].to
+	})
+}
+
+// sequenceStatements returns the statements creating the sequences of the
+// catalog, sorted by name, except the implicit sequences of SERIAL columns,
+// which are created with their tables.
+func sequenceStatements(conn *Connection, serialSequences map[string]bool) ([]string, error) {
+	rows, err := queryRows(conn, "CALL show_sequences() RETURN *;")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sequences: %w", err)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return fmt.Sprint(rows[i]["name"]) < fmt.Sprint(rows[j]["name"])
+	})
+	statements := make([]string, 0, len(rows))
+	for _, row := range rows {
+		name, _ := row["name"].(string)
+		if serialSequences[name] {
+			continue
+		}
+		cycle := "NO CYCLE"
+		if cycles, _ := row["cycle"].(bool); cycles {
+			cycle = "CYCLE"
+		}
+		statements = append(statements, fmt.Sprintf("CREATE SEQUENCE %s INCREMENT BY %v MINVALUE %v MAXVALUE %v START WITH %v %s;",
+			QuoteIdentifier(name), row["increment"], row["min value"], row["max value"], row["start value"], cycle))
+	}
+	return statements, nil
+}
+
+// serialSequenceName returns the name of the implicit sequence generating
+// the values of a SERIAL column.
+func serialSequenceName(table string, column string) string {
+	return table + "_" + column + "_serial"
+}
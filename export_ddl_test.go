@@ -0,0 +1,58 @@
+package lbug
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ddlSchema creates tables of every supported column type, with exotic
+// identifiers, a sequence and a relationship table group.
+var ddlSchema = []string{
+	"CREATE SEQUENCE `order seq` START 10 INCREMENT 5;",
+	"CREATE NODE TABLE `we``ird table`(`名前` STRING, `order` INT64 DEFAULT 7, PRIMARY KEY(`名前`));",
+	"CREATE NODE TABLE Scalars(id SERIAL, b BOOL, i8 INT8, i16 INT16, i32 INT32, i64 INT64, i128 INT128, u8 UINT8, u16 UINT16, u32 UINT32, u64 UINT64, f FLOAT, d DOUBLE, dec DECIMAL(18, 3), s STRING, bl BLOB, u UUID, PRIMARY KEY(id));",
+	"CREATE NODE TABLE Temporal(id INT64, d DATE, ts TIMESTAMP, ts_sec TIMESTAMP_SEC, ts_ms TIMESTAMP_MS, ts_ns TIMESTAMP_NS, ts_tz TIMESTAMP_TZ, iv INTERVAL, PRIMARY KEY(id));",
+	"CREATE NODE TABLE Nested(id INT64, l INT64[], a DOUBLE[3], st STRUCT(x INT64, `y z` STRING), m MAP(STRING, INT64), un UNION(n INT64, s STRING), PRIMARY KEY(id));",
+	"CREATE REL TABLE Links(FROM Temporal TO Nested, FROM Scalars TO Nested, weight DOUBLE);",
+	"CREATE REL TABLE `rel with space`(FROM `we``ird table` TO `we``ird table`);",
+}
+
+func exportDDL(t *testing.T, statements []string) string {
+	t.Helper()
+	db, conn := SetupTestDatabase(t)
+	defer db.Close()
+	defer conn.Close()
+	for _, statement := range statements {
+		mustRun(t, conn, statement)
+	}
+	var ddl bytes.Buffer
+	assert.Nil(t, db.ExportDDL(&ddl))
+	return ddl.String()
+}
+
+func TestExportDDL(t *testing.T) {
+	ddl := exportDDL(t, ddlSchema)
+	lines := strings.Split(strings.TrimSuffix(ddl, "\n"), "\n")
+	if assert.Len(t, lines, 7) {
+		assert.Equal(t, "CREATE SEQUENCE `order seq` INCREMENT BY 5 MINVALUE 1 MAXVALUE 9223372036854775807 START WITH 10 NO CYCLE;", lines[0])
+		assert.Equal(t, "CREATE NODE TABLE `Nested`(`id` INT64, `l` INT64[], `a` DOUBLE[3], `st` STRUCT(x INT64, `y z` STRING), `m` MAP(STRING, INT64), `un` UNION(n INT64, s STRING), PRIMARY KEY(`id`));", lines[1])
+		assert.True(t, strings.HasPrefix(lines[2], "CREATE NODE TABLE `Scalars`(`id` SERIAL, `b` BOOL,"))
+		assert.Equal(t, "CREATE NODE TABLE `we``ird table`(`名前` STRING, `order` INT64 DEFAULT 7, PRIMARY KEY(`名前`));", lines[4])
+		assert.Equal(t, "CREATE REL TABLE `Links`(FROM `Scalars` TO `Nested`, FROM `Temporal` TO `Nested`, `weight` DOUBLE);", lines[5])
+		assert.Equal(t, "CREATE REL TABLE `rel with space`(FROM `we``ird table` TO `we``ird table`);", lines[6])
+	}
+
+	// The output does not depend on the order the schema was created in.
+	reordered := []string{ddlSchema[4], ddlSchema[3], ddlSchema[2], ddlSchema[1], ddlSchema[0], ddlSchema[6], ddlSchema[5]}
+	assert.Equal(t, ddl, exportDDL(t, reordered))
+
+	// The exported DDL round-trips.
+	assert.Equal(t, ddl, exportDDL(t, lines))
+}
+
+func TestExportDDLEmpty(t *testing.T) {
+	assert.Equal(t, "", exportDDL(t, nil))
+}
@@ -0,0 +1,140 @@
+package lbug
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// KeyCase is a transformation of the column names used as keys by
+// FlatTuple.GetAsMap and FlatTuple.ToJSON.
+type KeyCase int
+
+const (
+	// KeyCasePreserve uses the column names verbatim, as returned by the
+	// engine, e.g. userName for RETURN n.name AS userName. It is the default.
+	KeyCasePreserve KeyCase = iota
+	// KeyCaseLower converts the column names to lower case, e.g. username.
+	KeyCaseLower
+	// KeyCaseCamel converts the column names to lower camel case, e.g.
+	// userName for user_name or UserName.
+	KeyCaseCamel
+	// KeyCaseSnake converts the column names to snake case, e.g. user_name
+	// for userName or UserName.
+	KeyCaseSnake
+)
+
+// ExportOptions configures how the rows of the results are exported as maps
+// and JSON documents.
+type ExportOptions struct {
+	// KeyCase transforms the column names used as keys. The parts of a
+	// column name separated by dots, e.g. the variable and the property of
+	// n.userName, are transformed separately, and leading underscores are
+	// kept. The keys of STRUCT values and the properties of nodes and
+	// relationships are not transformed.
+	KeyCase KeyCase
+}
+
+// SetExportOptions sets how the rows of the results of subsequent queries on
+// the connection are exported by FlatTuple.GetAsMap and FlatTuple.ToJSON.
+func (conn *Connection) SetExportOptions(opts ExportOptions) {
+	conn.exportOptions = opts
+}
+
+// key returns the key of the column name.
+func (opts ExportOptions) key(column string) string {
+	if opts.KeyCase == KeyCasePreserve {
+		return column
+	}
+	parts := strings.Split(column, ".")
+	for i, part := range parts {
+		parts[i] = opts.KeyCase.apply(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// keys returns the keys of the column names, and an error if two columns have
+// the same key.
+func (opts ExportOptions) keys(columns []string) ([]string, error) {
+	if opts.KeyCase == KeyCasePreserve {
+		return columns, nil
+	}
+	keys := make([]string, len(columns))
+	seen := make(map[string]string, len(columns))
+	for i, column := range columns {
+		keys[i] = opts.key(column)
+		if other, ok := seen[keys[i]]; ok {
+			return nil, fmt.Errorf("columns %s and %s both have the key %s", other, column, keys[i])
+		}
+		seen[keys[i]] = column
+	}
+	return keys, nil
+}
+
+// apply transforms a part of a column name without dots.
+func (keyCase KeyCase) apply(name string) string {
+	trimmed := strings.TrimLeft(name, "_")
+	prefix := name[:len(name)-len(trimmed)]
+	switch keyCase {
+	case KeyCaseLower:
+		return strings.ToLower(name)
+	case KeyCaseSnake:
+		words := splitWords(trimmed)
+		for i, word := range words {
+			words[i] = strings.ToLower(word)
+		}
+		return prefix + strings.Join(words, "_")
+	case KeyCaseCamel:
+		words := splitWords(trimmed)
+		for i, word := range words {
+			word = strings.ToLower(word)
+			if i > 0 {
+				runes := []rune(word)
+				runes[0] = unicode.ToUpper(runes[0])
+				word = string(runes)
+			}
+			words[i] = word
+		}
+		return prefix + strings.Join(words, "")
+	}
+	return name
+}
+
+// splitWords splits a name into words at underscores, hyphens and spaces,
+// and before an upper case letter following a lower case letter or a digit,
+// or starting a word after an acronym, e.g. HTTP and Server in HTTPServer.
+func splitWords(name string) []string {
+	runes := []rune(name)
+	var words []string
+	start := 0
+	for i := 0; i <= len(runes); i++ {
+		if i == len(runes) || runes[i] == '_' || runes[i] == '-' || runes[i] == ' ' {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+		if i > start && unicode.IsUpper(runes[i]) {
+			previous := runes[i-1]
+			acronymEnd := unicode.IsUpper(previous) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || acronymEnd {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+	}
+	return words
+}
+
+// ToJSON returns the values of the FlatTuple as a JSON object whose keys are
+// the keys of GetAsMap. The values are encoded with encoding/json, e.g.
+// TIMESTAMP values as RFC 3339 strings and BLOB values as base64 strings.
+func (tuple *FlatTuple) ToJSON() ([]byte, error) {
+	row, err := tuple.GetAsMap()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(row)
+}
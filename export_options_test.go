@@ -0,0 +1,85 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyCase(t *testing.T) {
+	for _, test := range []struct {
+		column string
+		lower  string
+		camel  string
+		snake  string
+	}{
+		{"userName", "username", "userName", "user_name"},
+		{"UserName", "username", "userName", "user_name"},
+		{"user_name", "user_name", "userName", "user_name"},
+		{"n.fName", "n.fname", "n.fName", "n.f_name"},
+		{"HTTPServer2Port", "httpserver2port", "httpServer2Port", "http_server2_port"},
+		{"userID", "userid", "userId", "user_id"},
+		{"_ID", "_id", "_id", "_id"},
+		{"count(*)", "count(*)", "count(*)", "count(*)"},
+		{"Größe_Ärger", "größe_ärger", "größeÄrger", "größe_ärger"},
+		{"名前Value", "名前value", "名前value", "名前value"},
+		{"first name", "first name", "firstName", "first_name"},
+	} {
+		assert.Equal(t, test.column, ExportOptions{}.key(test.column))
+		assert.Equal(t, test.lower, ExportOptions{KeyCase: KeyCaseLower}.key(test.column), test.column)
+		assert.Equal(t, test.camel, ExportOptions{KeyCase: KeyCaseCamel}.key(test.column), test.column)
+		assert.Equal(t, test.snake, ExportOptions{KeyCase: KeyCaseSnake}.key(test.column), test.column)
+	}
+}
+
+func TestKeyCaseCollision(t *testing.T) {
+	keys, err := ExportOptions{KeyCase: KeyCaseSnake}.keys([]string{"userName", "id"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"user_name", "id"}, keys)
+	_, err = ExportOptions{KeyCase: KeyCaseSnake}.keys([]string{"userName", "user_name"})
+	assert.ErrorContains(t, err, "columns userName and user_name both have the key user_name")
+}
+
+func TestGetAsMapKeyCase(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	query := "MATCH (a:person) RETURN a.fName AS userName, a.age AS Age_In_Years, a.gender AS `Geschlecht_Ü` ORDER BY a.fName LIMIT 1;"
+	row := func() (map[string]any, []byte) {
+		t.Helper()
+		result, err := conn.Query(query)
+		assert.Nil(t, err)
+		defer result.Close()
+		tuple, err := result.Next()
+		assert.Nil(t, err)
+		defer tuple.Close()
+		m, err := tuple.GetAsMap()
+		assert.Nil(t, err)
+		document, err := tuple.ToJSON()
+		assert.Nil(t, err)
+		return m, document
+	}
+	defer conn.SetExportOptions(ExportOptions{})
+
+	// The aliases are kept verbatim by default.
+	m, document := row()
+	assert.Equal(t, map[string]any{"userName": "Alice", "Age_In_Years": int64(35), "Geschlecht_Ü": int64(1)}, m)
+	assert.Equal(t, `{"Age_In_Years":35,"Geschlecht_Ü":1,"userName":"Alice"}`, string(document))
+
+	conn.SetExportOptions(ExportOptions{KeyCase: KeyCaseSnake})
+	m, document = row()
+	assert.Equal(t, map[string]any{"user_name": "Alice", "age_in_years": int64(35), "geschlecht_ü": int64(1)}, m)
+	assert.Equal(t, `{"age_in_years":35,"geschlecht_ü":1,"user_name":"Alice"}`, string(document))
+
+	conn.SetExportOptions(ExportOptions{KeyCase: KeyCaseCamel})
+	m, _ = row()
+	assert.Equal(t, map[string]any{"userName": "Alice", "ageInYears": int64(35), "geschlechtÜ": int64(1)}, m)
+
+	conn.SetExportOptions(ExportOptions{KeyCase: KeyCaseLower})
+	m, _ = row()
+	assert.Equal(t, map[string]any{"username": "Alice", "age_in_years": int64(35), "geschlecht_ü": int64(1)}, m)
+
+	// The helpers of the package are not affected.
+	conn.InvalidateSchemaCache()
+	table, err := conn.ResolveTableName("person")
+	assert.Nil(t, err)
+	assert.Equal(t, "person", table)
+}
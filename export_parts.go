@@ -0,0 +1,215 @@
+package lbug
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// defaultPartSize is the size of the parts of a PartWriter when
+// PartWriterOptions.PartSize is not set, above the minimum part size of the
+// multipart uploads of the common object stores.
+const defaultPartSize = 8 << 20
+
+// ChecksumAlgorithm is the algorithm of the checksums of the parts of a
+// PartWriter.
+type ChecksumAlgorithm int
+
+const (
+	// ChecksumCRC32C is CRC-32 with the Castagnoli polynomial, the checksum
+	// of Google Cloud Storage and one of the checksums of Amazon S3.
+	ChecksumCRC32C ChecksumAlgorithm = iota
+	// ChecksumSHA256 is SHA-256.
+	ChecksumSHA256
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func (algorithm ChecksumAlgorithm) newHash() (hash.Hash, error) {
+	switch algorithm {
+	case ChecksumCRC32C:
+		return crc32.New(crc32cTable), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	}
+	return nil, fmt.Errorf("unknown checksum algorithm %d", algorithm)
+}
+
+// PartPosition is the position of a PartWriter in its output: the index of
+// the next part and the offset at which it starts.
+type PartPosition struct {
+	Index  int   `json:"index"`
+	Offset int64 `json:"offset"`
+}
+
+// Part describes a part flushed by a PartWriter.
+type Part struct {
+	// Index is the index of the part, from 0. Multipart uploads usually
+	// number their parts from 1.
+	Index int
+	// Offset is the offset of the first byte of the part in the output, and
+	// Length the number of bytes of the part.
+	Offset int64
+	Length int64
+	// Checksum is the checksum of the bytes of the part.
+	Checksum []byte
+	// Data holds the bytes of the part. It is only valid until OnPart
+	// returns.
+	Data []byte
+}
+
+// PartWriterOptions configures a PartWriter created with NewPartWriter.
+type PartWriterOptions struct {
+	// PartSize is the number of bytes buffered before a part is flushed. All
+	// parts have this size except the parts flushed by Flush, which may be
+	// shorter. Zero means 8 MiB.
+	PartSize int
+	// Checksum is the algorithm of the checksums of the parts.
+	Checksum ChecksumAlgorithm
+	// OnPart, if set, is called after each part is written to the output,
+	// e.g. to upload it as a part of a multipart upload. If it returns an
+	// error, the write that flushed the part fails with it. Since the part
+	// is flushed by the goroutine writing to the PartWriter, a slow OnPart
+	// slows down the writes instead of letting the buffered data grow.
+	OnPart func(part Part) error
+	// Start is the position the output starts at, e.g. the position saved in
+	// Checkpoint.Output when resuming an export.
+	Start PartPosition
+}
+
+// PartWriter buffers the bytes written to it and writes them to an output in
+// parts of bounded size, with one Write call per part, so that the output
+// needs neither to buffer the whole data nor to implement io.Seeker. The
+// checksum of a part is computed as its bytes are written, without reading
+// them again. A PartWriter is not safe for concurrent use.
+type PartWriter struct {
+	w        io.Writer
+	opts     PartWriterOptions
+	buffer   []byte
+	checksum hash.Hash
+	position PartPosition
+	err      error
+}
+
+// NewPartWriter returns a PartWriter writing its parts to w. The output may
+// be nil if the parts are only handled by PartWriterOptions.OnPart.
+func NewPartWriter(w io.Writer, opts PartWriterOptions) (*PartWriter, error) {
+	if opts.PartSize < 0 {
+		return nil, fmt.Errorf("part size must not be negative")
+	}
+	if opts.PartSize == 0 {
+		opts.PartSize = defaultPartSize
+	}
+	checksum, err := opts.Checksum.newHash()
+	if err != nil {
+		return nil, err
+	}
+	return &PartWriter{
+		w:        w,
+		opts:     opts,
+		buffer:   make([]byte, 0, opts.PartSize),
+		checksum: checksum,
+		position: opts.Start,
+	}, nil
+}
+
+// Write buffers the bytes, flushing a part each time the buffer reaches the
+// part size. Once a part fails to be written or handled by OnPart, all later
+// writes fail with the same error.
+func (pw *PartWriter) Write(data []byte) (int, error) {
+	if pw.err != nil {
+		return 0, pw.err
+	}
+	written := 0
+	for len(data) > 0 {
+		n := min(len(data), pw.opts.PartSize-len(pw.buffer))
+		pw.buffer = append(pw.buffer, data[:n]...)
+		pw.checksum.Write(data[:n])
+		data = data[n:]
+		written += n
+		if len(pw.buffer) == pw.opts.PartSize {
+			if err := pw.flushPart(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Flush flushes the buffered bytes as a part, unless there are none, and
+// returns the position of the next part. The signature lets Flush be used as
+// ExportSpec.Flush, so that the checkpoints of an export fall on part
+// boundaries.
+func (pw *PartWriter) Flush() (PartPosition, error) {
+	if pw.err == nil && len(pw.buffer) > 0 {
+		pw.flushPart()
+	}
+	return pw.position, pw.err
+}
+
+// Position returns the position of the next part.
+func (pw *PartWriter) Position() PartPosition {
+	return pw.position
+}
+
+func (pw *PartWriter) flushPart() error {
+	part := Part{
+		Index:    pw.position.Index,
+		Offset:   pw.position.Offset,
+		Length:   int64(len(pw.buffer)),
+		Checksum: pw.checksum.Sum(nil),
+		Data:     pw.buffer,
+	}
+	if pw.w != nil {
+		if _, err := pw.w.Write(pw.buffer); err != nil {
+			pw.err = fmt.Errorf("failed to write part %d: %w", part.Index, err)
+			return pw.err
+		}
+	}
+	if pw.opts.OnPart != nil {
+		if err := pw.opts.OnPart(part); err != nil {
+			pw.err = fmt.Errorf("failed to handle part %d: %w", part.Index, err)
+			return pw.err
+		}
+	}
+	pw.position.Index++
+	pw.position.Offset += part.Length
+	pw.buffer = pw.buffer[:0]
+	pw.checksum.Reset()
+	return nil
+}
+
+// CSVExportWriter returns a function writing the rows of an export as CSV to
+// w, to be used as ExportSpec.Write. If header is true, the names of the
+// columns are written before the first batch; an export resumed from a
+// checkpoint should pass false, since the header was written before. The
+// values are formatted as by RegisterRowSource.
+func CSVExportWriter(w io.Writer, header bool) func(columns []string, rows [][]any) error {
+	writer := csv.NewWriter(w)
+	return func(columns []string, rows [][]any) error {
+		if header {
+			if err := writer.Write(columns); err != nil {
+				return err
+			}
+			header = false
+		}
+		record := make([]string, len(columns))
+		for _, row := range rows {
+			for i, value := range row {
+				field, err := rowSourceField(value)
+				if err != nil {
+					return fmt.Errorf("column %s: %w", columns[i], err)
+				}
+				record[i] = field
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+}
@@ -0,0 +1,148 @@
+package lbug
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartWriter(t *testing.T) {
+	var output bytes.Buffer
+	var parts []Part
+	var data [][]byte
+	pw, err := NewPartWriter(&output, PartWriterOptions{
+		PartSize: 4,
+		OnPart: func(part Part) error {
+			data = append(data, bytes.Clone(part.Data))
+			part.Data = nil
+			parts = append(parts, part)
+			return nil
+		},
+	})
+	assert.Nil(t, err)
+	n, err := pw.Write([]byte("abcdefghij"))
+	assert.Nil(t, err)
+	assert.Equal(t, 10, n)
+	assert.Equal(t, "abcdefgh", output.String())
+	position, err := pw.Flush()
+	assert.Nil(t, err)
+	assert.Equal(t, PartPosition{Index: 3, Offset: 10}, position)
+	// Flushing with nothing buffered emits no part.
+	position, err = pw.Flush()
+	assert.Nil(t, err)
+	assert.Equal(t, PartPosition{Index: 3, Offset: 10}, position)
+	assert.Equal(t, "abcdefghij", output.String())
+	assert.Equal(t, 3, len(parts))
+	for i, chunk := range []string{"abcd", "efgh", "ij"} {
+		assert.Equal(t, chunk, string(data[i]))
+		assert.Equal(t, i, parts[i].Index)
+		assert.Equal(t, int64(4*i), parts[i].Offset)
+		assert.Equal(t, int64(len(chunk)), parts[i].Length)
+		assert.Equal(t, crc32.Checksum([]byte(chunk), crc32cTable), binaryUint32(parts[i].Checksum))
+	}
+
+	// SHA-256, resuming at a position.
+	var checksums [][]byte
+	pw, err = NewPartWriter(nil, PartWriterOptions{
+		PartSize: 3,
+		Checksum: ChecksumSHA256,
+		Start:    PartPosition{Index: 5, Offset: 100},
+		OnPart: func(part Part) error {
+			checksums = append(checksums, part.Checksum)
+			return nil
+		},
+	})
+	assert.Nil(t, err)
+	pw.Write([]byte("xy"))
+	pw.Write([]byte("z"))
+	assert.Equal(t, PartPosition{Index: 6, Offset: 103}, pw.Position())
+	sum := sha256.Sum256([]byte("xyz"))
+	assert.Equal(t, sum[:], checksums[0])
+
+	// A failed part fails the later writes.
+	errUpload := errors.New("upload failed")
+	pw, err = NewPartWriter(nil, PartWriterOptions{PartSize: 2, OnPart: func(Part) error { return errUpload }})
+	assert.Nil(t, err)
+	n, err = pw.Write([]byte("abc"))
+	assert.ErrorIs(t, err, errUpload)
+	assert.Equal(t, 2, n)
+	_, err = pw.Write([]byte("d"))
+	assert.ErrorContains(t, err, "failed to handle part 0: upload failed")
+	_, err = pw.Flush()
+	assert.ErrorIs(t, err, errUpload)
+
+	_, err = NewPartWriter(nil, PartWriterOptions{Checksum: ChecksumAlgorithm(7)})
+	assert.ErrorContains(t, err, "unknown checksum algorithm 7")
+}
+
+func binaryUint32(b []byte) uint32 {
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+func TestCSVExportWriter(t *testing.T) {
+	var output strings.Builder
+	write := CSVExportWriter(&output, true)
+	assert.Nil(t, write([]string{"id", "name"}, [][]any{{int64(1), "a,b"}, {int64(2), nil}}))
+	assert.Nil(t, write([]string{"id", "name"}, [][]any{{int64(3), "c"}}))
+	assert.Equal(t, "id,name\n1,\"a,b\"\n2,\n3,c\n", output.String())
+}
+
+func TestExportResumableParts(t *testing.T) {
+	conn := setupExportTestConnection(t, 95)
+	store := &FileCheckpointStore{Dir: t.TempDir()}
+	var uploaded []Part
+	errCrash := errors.New("simulated crash")
+	crashAt := 3
+	export := func() (uint64, error) {
+		checkpoint, err := store.Load("items")
+		assert.Nil(t, err)
+		opts := PartWriterOptions{
+			PartSize: 64,
+			OnPart: func(part Part) error {
+				if part.Index == crashAt {
+					return errCrash
+				}
+				part.Data = bytes.Clone(part.Data)
+				// A part uploaded again replaces the earlier upload.
+				uploaded = append(uploaded[:part.Index], part)
+				return nil
+			},
+		}
+		if checkpoint != nil {
+			opts.Start = *checkpoint.Output
+		}
+		pw, err := NewPartWriter(nil, opts)
+		assert.Nil(t, err)
+		return ExportResumable(conn, ExportSpec{
+			Name:      "items",
+			Table:     "item",
+			Key:       "id",
+			Columns:   []string{"id", "name"},
+			BatchSize: 10,
+			Write:     CSVExportWriter(pw, checkpoint == nil),
+			Flush:     pw.Flush,
+		}, store)
+	}
+	_, err := export()
+	assert.ErrorIs(t, err, errCrash)
+	crashAt = -1
+	total, err := export()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(95), total)
+
+	// The parts form the export without duplicate or missing rows.
+	var output bytes.Buffer
+	for i, part := range uploaded {
+		assert.Equal(t, int64(output.Len()), part.Offset, "part %d", i)
+		output.Write(part.Data)
+	}
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	assert.Equal(t, 96, len(lines))
+	assert.Equal(t, "id,name", lines[0])
+	assert.Equal(t, "95,item95", lines[95])
+}
@@ -0,0 +1,363 @@
+package lbug
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// defaultExportBatchSize is the number of rows fetched per page by
+// ExportResumable when ExportSpec.BatchSize is not set.
+const defaultExportBatchSize = 10000
+
+// ErrCheckpointSchemaMismatch is returned by ExportResumable when the schema
+// of the exported table has changed since the checkpoint was written, so the
+// export cannot be resumed from it.
+var ErrCheckpointSchemaMismatch = errors.New("checkpoint does not match the schema of the table")
+
+// ExportSpec describes an export of a node table run by ExportResumable.
+type ExportSpec struct {
+	// Name identifies the export in the checkpoint store.
+	Name string
+	// Table is the node table to export.
+	Table string
+	// Key is the property the rows are ordered and paginated by. It must be
+	// unique, e.g. the primary key, and of an integer, floating point or
+	// string type. Rows whose key is NULL are not exported.
+	Key string
+	// Columns are the properties to export. If it is empty, all properties
+	// are exported.
+	Columns []string
+	// BatchSize is the number of rows fetched and written per batch. If it
+	// is zero, 10000 rows are fetched per batch.
+	BatchSize int
+	// CheckpointEvery is the number of batches written between two
+	// checkpoints. If it is zero, a checkpoint is saved after every batch.
+	CheckpointEvery int
+	// Write writes a batch of rows to the output. The values of each row are
+	// in the order of columns.
+	Write func(columns []string, rows [][]any) error
+	// Flush, if set, is called before each checkpoint is saved, so that the
+	// rows written up to the checkpoint reach the output, e.g. with
+	// PartWriter.Flush. The position it returns is saved as the Output of
+	// the checkpoint.
+	Flush func() (PartPosition, error)
+}
+
+// Checkpoint records the progress of an export.
+type Checkpoint struct {
+	// LastKey is the key of the last row written, or nil if no row was
+	// written yet.
+	LastKey any `json:"last_key"`
+	// RowsWritten is the number of rows written up to LastKey.
+	RowsWritten uint64 `json:"rows_written"`
+	// Schema lists the exported columns and their types, used to verify that
+	// the table has not changed when the export is resumed.
+	Schema []string `json:"schema"`
+	// Completed is true if all rows have been written.
+	Completed bool `json:"completed"`
+	// Output is the position of the output returned by ExportSpec.Flush,
+	// or nil if the export has no Flush function. A resumed export writing
+	// to a PartWriter should start it at this position.
+	Output *PartPosition `json:"output,omitempty"`
+}
+
+// CheckpointStore persists the checkpoints of exports.
+type CheckpointStore interface {
+	// Load returns the checkpoint saved for the export with the given name,
+	// or nil if there is none.
+	Load(name string) (*Checkpoint, error)
+	// Save persists the checkpoint of the export with the given name,
+	// replacing the previous one.
+	Save(name string, checkpoint *Checkpoint) error
+}
+
+// FileCheckpointStore is a CheckpointStore that saves every checkpoint as a
+// JSON file named after the export in a directory. Checkpoints are replaced
+// atomically, so a crash while saving leaves the previous checkpoint intact.
+type FileCheckpointStore struct {
+	Dir string
+}
+
+func (store *FileCheckpointStore) path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid checkpoint name %q", name)
+	}
+	return filepath.Join(store.Dir, name+".json"), nil
+}
+
+// Load returns the checkpoint saved for the export with the given name, or
+// nil if there is none.
+func (store *FileCheckpointStore) Load(name string) (*Checkpoint, error) {
+	path, err := store.path(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	checkpoint := &Checkpoint{}
+	if err := decoder.Decode(checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint %s: %w", path, err)
+	}
+	return checkpoint, nil
+}
+
+// Save persists the checkpoint of the export with the given name.
+func (store *FileCheckpointStore) Save(name string, checkpoint *Checkpoint) error {
+	path, err := store.path(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(store.Dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	err = errors.Join(err, file.Close())
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+// ExportResumable exports the rows of a node table in batches ordered by a
+// key, saving a checkpoint with the last key written to store as it goes. If
+// a checkpoint exists for the export, it is resumed after the last key of the
+// checkpoint instead of starting over, provided the exported columns and
+// their types are unchanged. ExportResumable returns the total number of rows
+// written, including those written before resuming.
+//
+// A checkpoint is saved only after the batches it covers have been written
+// successfully, so the output is at-least-once: if the process dies after
+// writing a batch but before saving the checkpoint that covers it, the batch
+// is written again when the export is resumed. Rows are never skipped. To get
+// exactly-once output, Write must be idempotent, e.g. by keying the output on
+// the exported key, or the output must be truncated to RowsWritten of the
+// checkpoint before resuming. With a Flush function, the output can instead be
+// truncated to the Output of the checkpoint, e.g. by starting a PartWriter
+// at it so that the parts after it are written again.
+//
+// The batches are read by separate queries, which see the writes committed
+// in between. To export a consistent view of the table, run the export with
+// SnapshotConn.ExportResumable.
+func ExportResumable(conn *Connection, spec ExportSpec, store CheckpointStore) (uint64, error) {
+	if spec.Write == nil {
+		return 0, fmt.Errorf("export %s has no Write function", spec.Name)
+	}
+	if spec.BatchSize < 0 || spec.CheckpointEvery < 0 {
+		return 0, fmt.Errorf("export %s: batch size and checkpoint interval must not be negative", spec.Name)
+	}
+	if spec.BatchSize == 0 {
+		spec.BatchSize = defaultExportBatchSize
+	}
+	if spec.CheckpointEvery == 0 {
+		spec.CheckpointEvery = 1
+	}
+	table, err := conn.ResolveTableName(spec.Table)
+	if err != nil {
+		return 0, err
+	}
+	schema, err := describeTable(conn, table, false)
+	if err != nil {
+		return 0, err
+	}
+	keyColumn, ok := schema.column(spec.Key)
+	if !ok {
+		return 0, fmt.Errorf("table %s has no property %s", spec.Table, spec.Key)
+	}
+	if !isExportKeyType(keyColumn.dataType) {
+		return 0, fmt.Errorf("property %s of type %s cannot be used as an export key", spec.Key, keyColumn.dataType)
+	}
+	columns := spec.Columns
+	if len(columns) == 0 {
+		for _, column := range schema.columns {
+			columns = append(columns, column.name)
+		}
+	}
+	exportSchema := make([]string, len(columns))
+	projections := []string{"n." + QuoteIdentifier(spec.Key)}
+	for i, name := range columns {
+		column, ok := schema.column(name)
+		if !ok {
+			return 0, fmt.Errorf("table %s has no property %s", spec.Table, name)
+		}
+		exportSchema[i] = column.name + " " + column.dataType
+		projections = append(projections, "n."+QuoteIdentifier(name))
+	}
+
+	checkpoint, err := store.Load(spec.Name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load checkpoint of export %s: %w", spec.Name, err)
+	}
+	if checkpoint == nil {
+		checkpoint = &Checkpoint{Schema: exportSchema}
+	} else {
+		if !slices.Equal(checkpoint.Schema, exportSchema) {
+			return 0, fmt.Errorf("%w: checkpoint of export %s has columns %v, table %s has %v",
+				ErrCheckpointSchemaMismatch, spec.Name, checkpoint.Schema, spec.Table, exportSchema)
+		}
+		checkpoint.LastKey, err = normalizeExportKey(checkpoint.LastKey, keyColumn.dataType)
+		if err != nil {
+			return 0, fmt.Errorf("invalid checkpoint of export %s: %w", spec.Name, err)
+		}
+	}
+	if checkpoint.Completed {
+		return checkpoint.RowsWritten, nil
+	}
+
+	match := fmt.Sprintf("MATCH (n:%s)", QuoteIdentifier(table))
+	page := fmt.Sprintf("RETURN %s ORDER BY n.%s LIMIT %d;", strings.Join(projections, ", "), QuoteIdentifier(spec.Key), spec.BatchSize)
+	firstPage, err := conn.Prepare(fmt.Sprintf("%s WHERE n.%s IS NOT NULL %s", match, QuoteIdentifier(spec.Key), page))
+	if err != nil {
+		firstPage.Close()
+		return checkpoint.RowsWritten, err
+	}
+	defer firstPage.Close()
+	nextPage, err := conn.Prepare(fmt.Sprintf("%s WHERE n.%s > $lastKey %s", match, QuoteIdentifier(spec.Key), page))
+	if err != nil {
+		nextPage.Close()
+		return checkpoint.RowsWritten, err
+	}
+	defer nextPage.Close()
+
+	save := func() error {
+		if spec.Flush != nil {
+			position, err := spec.Flush()
+			if err != nil {
+				return fmt.Errorf("failed to flush rows of export %s: %w", spec.Name, err)
+			}
+			checkpoint.Output = &position
+		}
+		if err := store.Save(spec.Name, checkpoint); err != nil {
+			return fmt.Errorf("failed to save checkpoint of export %s: %w", spec.Name, err)
+		}
+		return nil
+	}
+	for batches := 1; ; batches++ {
+		var result *QueryResult
+		if checkpoint.LastKey == nil {
+			result, err = conn.Execute(firstPage, nil)
+		} else {
+			result, err = conn.Execute(nextPage, map[string]any{"lastKey": checkpoint.LastKey})
+		}
+		if err != nil {
+			return checkpoint.RowsWritten, err
+		}
+		keys, rows, err := readExportPage(result)
+		result.Close()
+		if err != nil {
+			return checkpoint.RowsWritten, err
+		}
+		if len(rows) > 0 {
+			if err := spec.Write(columns, rows); err != nil {
+				return checkpoint.RowsWritten, fmt.Errorf("failed to write rows of export %s: %w", spec.Name, err)
+			}
+			checkpoint.LastKey = keys[len(keys)-1]
+			checkpoint.RowsWritten += uint64(len(rows))
+		}
+		if len(rows) < spec.BatchSize {
+			checkpoint.Completed = true
+			return checkpoint.RowsWritten, save()
+		}
+		if batches%spec.CheckpointEvery == 0 {
+			if err := save(); err != nil {
+				return checkpoint.RowsWritten, err
+			}
+		}
+	}
+}
+
+// readExportPage reads a page of an export, returning the keys and the
+// exported values of the rows separately.
+func readExportPage(result *QueryResult) ([]any, [][]any, error) {
+	var keys []any
+	var rows [][]any
+	for result.HasNext() {
+		tuple, err := result.Next()
+		if err != nil {
+			tuple.Close()
+			return nil, nil, err
+		}
+		values, err := tuple.GetAsSlice()
+		tuple.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, values[0])
+		rows = append(rows, values[1:])
+	}
+	return keys, rows, nil
+}
+
+// exportKeyTypes maps the types that can be used as export keys to the kind
+// of Go value a key of that type is converted to.
+var exportKeyTypes = map[string]string{
+	"INT8": "int", "INT16": "int", "INT32": "int", "INT64": "int",
+	"UINT8": "uint", "UINT16": "uint", "UINT32": "uint", "UINT64": "uint",
+	"FLOAT": "float", "DOUBLE": "float",
+	"STRING": "string",
+}
+
+func isExportKeyType(dataType string) bool {
+	_, ok := exportKeyTypes[strings.ToUpper(dataType)]
+	return ok
+}
+
+// normalizeExportKey converts a key loaded from a checkpoint store, which may
+// have lost its Go type in serialization, back to a value of a type that can
+// be compared with a key of the given type.
+func normalizeExportKey(key any, dataType string) (any, error) {
+	if key == nil {
+		return nil, nil
+	}
+	number, isNumber := key.(json.Number)
+	switch exportKeyTypes[strings.ToUpper(dataType)] {
+	case "int":
+		if isNumber {
+			return number.Int64()
+		}
+		if value, ok := key.(float64); ok {
+			return int64(value), nil
+		}
+	case "uint":
+		if isNumber {
+			var value uint64
+			_, err := fmt.Sscan(number.String(), &value)
+			return value, err
+		}
+		if value, ok := key.(float64); ok {
+			return uint64(value), nil
+		}
+	case "float":
+		if isNumber {
+			return number.Float64()
+		}
+	case "string":
+		if value, ok := key.(string); ok {
+			return value, nil
+		}
+	}
+	return key, nil
+}
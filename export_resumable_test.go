@@ -0,0 +1,129 @@
+package lbug
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileCheckpointStore(t *testing.T) {
+	store := &FileCheckpointStore{Dir: t.TempDir()}
+	checkpoint, err := store.Load("export")
+	assert.Nil(t, err)
+	assert.Nil(t, checkpoint)
+
+	saved := &Checkpoint{LastKey: int64(1 << 60), RowsWritten: 42, Schema: []string{"id INT64"}}
+	assert.Nil(t, store.Save("export", saved))
+	checkpoint, err = store.Load("export")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(42), checkpoint.RowsWritten)
+	assert.Equal(t, []string{"id INT64"}, checkpoint.Schema)
+	key, err := normalizeExportKey(checkpoint.LastKey, "INT64")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1<<60), key)
+
+	assert.NotNil(t, store.Save("../escape", saved))
+}
+
+func TestNormalizeExportKey(t *testing.T) {
+	key, err := normalizeExportKey(json.Number("18446744073709551615"), "UINT64")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1<<64-1), key)
+	key, err = normalizeExportKey(json.Number("1.5"), "DOUBLE")
+	assert.Nil(t, err)
+	assert.Equal(t, 1.5, key)
+	key, err = normalizeExportKey("abc", "STRING")
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", key)
+	key, err = normalizeExportKey(nil, "INT64")
+	assert.Nil(t, err)
+	assert.Nil(t, key)
+}
+
+func setupExportTestConnection(t *testing.T, numRows int) *Connection {
+	t.Helper()
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	t.Cleanup(db.Close)
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	t.Cleanup(conn.Close)
+	result, err := conn.Query("CREATE NODE TABLE item(id INT64, name STRING, PRIMARY KEY(id));")
+	assert.Nil(t, err)
+	result.Close()
+	result, err = conn.Query(fmt.Sprintf("UNWIND range(1, %d) AS i CREATE (:item {id: i, name: 'item' + cast(i, 'STRING')});", numRows))
+	assert.Nil(t, err)
+	result.Close()
+	return conn
+}
+
+func TestExportResumableAfterCrash(t *testing.T) {
+	conn := setupExportTestConnection(t, 95)
+	store := &FileCheckpointStore{Dir: t.TempDir()}
+	var written []int64
+	batches := 0
+	errCrash := errors.New("simulated crash")
+	spec := ExportSpec{
+		Name:            "items",
+		Table:           "item",
+		Key:             "id",
+		Columns:         []string{"id", "name"},
+		BatchSize:       10,
+		CheckpointEvery: 2,
+		Write: func(columns []string, rows [][]any) error {
+			assert.Equal(t, []string{"id", "name"}, columns)
+			batches++
+			if batches == 4 {
+				return errCrash
+			}
+			for _, row := range rows {
+				written = append(written, row[0].(int64))
+			}
+			return nil
+		},
+	}
+	total, err := ExportResumable(conn, spec, store)
+	assert.ErrorIs(t, err, errCrash)
+	assert.Equal(t, uint64(30), total)
+	assert.Len(t, written, 30)
+
+	// The checkpoint was saved after the second batch, so the third batch is
+	// written again when the export resumes.
+	total, err = ExportResumable(conn, spec, store)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(95), total)
+	assert.Len(t, written, 105)
+	seen := make(map[int64]int)
+	for _, id := range written {
+		seen[id]++
+	}
+	for id := int64(1); id <= 95; id++ {
+		if id > 20 && id <= 30 {
+			assert.Equal(t, 2, seen[id], "row %d", id)
+		} else {
+			assert.Equal(t, 1, seen[id], "row %d", id)
+		}
+	}
+
+	// A completed export is not run again.
+	total, err = ExportResumable(conn, spec, store)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(95), total)
+	assert.Len(t, written, 105)
+}
+
+func TestExportResumableSchemaMismatch(t *testing.T) {
+	conn := setupExportTestConnection(t, 5)
+	store := &FileCheckpointStore{Dir: t.TempDir()}
+	assert.Nil(t, store.Save("items", &Checkpoint{LastKey: int64(2), Schema: []string{"id INT64"}}))
+	_, err := ExportResumable(conn, ExportSpec{
+		Name:  "items",
+		Table: "item",
+		Key:   "id",
+		Write: func([]string, [][]any) error { return nil },
+	}, store)
+	assert.ErrorIs(t, err, ErrCheckpointSchemaMismatch)
+}
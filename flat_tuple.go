@@ -3,28 +3,54 @@ package lbug
 // #include "lbug.h"
 // #include <stdlib.h>
 import "C"
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 // FlatTuple represents a row in the result set of a query.
 type FlatTuple struct {
 	cFlatTuple  C.lbug_flat_tuple
 	queryResult *QueryResult
+	numColumns  uint64
 	isClosed    bool
+	handleID    uint64
 }
 
 // Close releases the underlying C resources for the FlatTuple.
 // MUST be called when done to prevent resource leaks.
 func (tuple *FlatTuple) Close() {
+	tuple.queryResult.connection.closeMutex.Lock()
+	defer tuple.queryResult.connection.closeMutex.Unlock()
+	tuple.close()
+}
+
+// close destroys the C flat tuple. closeMutex of the connection must be held
+// for writing.
+func (tuple *FlatTuple) close() {
 	if tuple.isClosed {
 		return
 	}
-	C.lbug_flat_tuple_destroy(&tuple.cFlatTuple)
+	tuple.queryResult.connection.countCgoCall(cgoClose)
+	if tuple.queryResult.connection.checkConnection(HandleFlatTuple) == nil {
+		C.lbug_flat_tuple_destroy(&tuple.cFlatTuple)
+	}
+	tuple.queryResult.connection.untrackChild(HandleFlatTuple, tuple.handleID)
+	if tuple.handleID != 0 {
+		tuple.queryResult.connection.stats().openFlatTuples.Add(-1)
+	}
 	tuple.isClosed = true
 }
 
 // GetAsString returns the string representation of the FlatTuple.
 // The string representation contains the values of the tuple separated by vertical bars.
 func (tuple *FlatTuple) GetAsString() string {
+	tuple.queryResult.connection.closeMutex.RLock()
+	defer tuple.queryResult.connection.closeMutex.RUnlock()
+	if tuple.isClosed {
+		return ""
+	}
 	cString := C.lbug_flat_tuple_to_string(&tuple.cFlatTuple)
 	defer C.lbug_destroy_string(cString)
 	return C.GoString(cString)
@@ -32,28 +58,85 @@ func (tuple *FlatTuple) GetAsString() string {
 
 // GetAsSlice returns the values of the FlatTuple as a slice.
 // The order of the values in the slice is the same as the order of the columns
-// in the query result.
+// in the query result. The values are fetched with one call into the C API
+// for the whole tuple.
 func (tuple *FlatTuple) GetAsSlice() ([]any, error) {
-	length := uint64(tuple.queryResult.GetNumberOfColumns())
-	values := make([]any, 0, length)
-	var errors []error
-	for i := uint64(0); i < length; i++ {
-		value, err := tuple.GetValue(i)
+	return tuple.getColumns(nil)
+}
+
+// getColumns returns the values of the columns at the indexes, or of all the
+// columns if columns is nil, in a slice of the values of all the columns in
+// which the others are nil, so that only the values used are converted.
+func (tuple *FlatTuple) getColumns(columns []uint64) ([]any, error) {
+	tuple.queryResult.connection.closeMutex.RLock()
+	defer tuple.queryResult.connection.closeMutex.RUnlock()
+	if tuple.isClosed {
+		return nil, &Error{Op: OpConvert, Err: &closedError{"failed to get values because the tuple is closed"}}
+	}
+	defer tuple.queryResult.decodeTimer.since(time.Now())
+	tuple.queryResult.connection.countCgoCall(cgoGetValue)
+	if err := tuple.queryResult.connection.checkConnection(HandleFlatTuple); err != nil {
+		return nil, &Error{Op: OpConvert, Err: err}
+	}
+	row, err := fetchRowValues(&tuple.cFlatTuple, tuple.numColumns)
+	if err != nil {
+		return nil, &Error{Op: OpConvert, Err: err}
+	}
+	defer row.free()
+	values := make([]any, len(row.values))
+	var errs []error
+	convert := func(i uint64) {
+		if row.nulls[i] != 0 {
+			return
+		}
+		value, err := lbugValueToGoValue(row.values[i], &tuple.queryResult.converter)
 		if err != nil {
-			errors = append(errors, err)
+			errs = append(errs, conversionError(i, err))
+		}
+		values[i] = value
+	}
+	if columns == nil {
+		for i := range values {
+			convert(uint64(i))
 		}
-		values = append(values, value)
 	}
-	if len(errors) > 0 {
-		return values, fmt.Errorf("failed to get values: %v", errors)
+	for _, i := range columns {
+		convert(i)
+	}
+	if len(errs) > 0 {
+		return values, fmt.Errorf("failed to get values: %w", errors.Join(errs...))
 	}
 	return values, nil
 }
 
+// Nulls returns, for every column of the tuple, whether its value is NULL,
+// without converting the values.
+func (tuple *FlatTuple) Nulls() ([]bool, error) {
+	tuple.queryResult.connection.closeMutex.RLock()
+	defer tuple.queryResult.connection.closeMutex.RUnlock()
+	if tuple.isClosed {
+		return nil, &Error{Op: OpConvert, Err: &closedError{"failed to get values because the tuple is closed"}}
+	}
+	tuple.queryResult.connection.countCgoCall(cgoGetValue)
+	if err := tuple.queryResult.connection.checkConnection(HandleFlatTuple); err != nil {
+		return nil, &Error{Op: OpConvert, Err: err}
+	}
+	nulls, err := fetchNullMask(&tuple.cFlatTuple, tuple.numColumns)
+	if err != nil {
+		return nil, &Error{Op: OpConvert, Err: err}
+	}
+	return nulls, nil
+}
+
 // GetAsMap returns the values of the FlatTuple as a map.
-// The keys of the map are the column names in the query result.
+// The keys of the map are the column names in the query result, verbatim
+// unless a KeyCase is set with Connection.SetExportOptions. It returns an
+// error if two columns have the same key.
 func (tuple *FlatTuple) GetAsMap() (map[string]any, error) {
-	columnNames := tuple.queryResult.GetColumnNames()
+	columnNames, err := tuple.queryResult.exportOptions.keys(tuple.queryResult.GetColumnNames())
+	if err != nil {
+		return nil, err
+	}
 	values, err := tuple.GetAsSlice()
 	if err != nil {
 		if len(columnNames) != len(values) {
@@ -69,10 +152,220 @@ func (tuple *FlatTuple) GetAsMap() (map[string]any, error) {
 
 // GetValue returns the value at the given index in the FlatTuple.
 func (tuple *FlatTuple) GetValue(index uint64) (any, error) {
+	tuple.queryResult.connection.closeMutex.RLock()
+	defer tuple.queryResult.connection.closeMutex.RUnlock()
+	defer tuple.queryResult.decodeTimer.since(time.Now())
+	return tuple.getValue(index)
+}
+
+// getValue returns the value at the given index. closeMutex of the connection
+// must be held.
+func (tuple *FlatTuple) getValue(index uint64) (any, error) {
+	cValue, err := tuple.getCValue(index)
+	if err != nil {
+		return nil, conversionError(index, err)
+	}
+	value, err := lbugValueToGoValue(cValue, &tuple.queryResult.converter)
+	return value, conversionError(index, err)
+}
+
+// conversionError wraps an error of the conversion of the value at the given
+// index in an *Error, or returns nil if err is nil.
+func conversionError(index uint64, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Op: OpConvert, Column: index, Err: err}
+}
+
+// getCValue returns the C value at the given index in the FlatTuple. The value
+// is owned by the FlatTuple and must not be destroyed.
+func (tuple *FlatTuple) getCValue(index uint64) (C.lbug_value, error) {
 	var cValue C.lbug_value
+	if err := tuple.checkIndex(index); err != nil {
+		return cValue, err
+	}
+	tuple.queryResult.connection.countCgoCall(cgoGetValue)
+	if err := tuple.queryResult.connection.checkConnection(HandleFlatTuple); err != nil {
+		return cValue, err
+	}
 	status := C.lbug_flat_tuple_get_value(&tuple.cFlatTuple, C.uint64_t(index), &cValue)
 	if status != C.LbugSuccess {
-		return nil, fmt.Errorf("failed to get value with status: %d", status)
+		return cValue, fmt.Errorf("failed to get value with status: %d", status)
+	}
+	return cValue, nil
+}
+
+// checkIndex returns an error wrapping ErrColumnIndexOutOfRange if the index is
+// not a valid column index of the tuple, because the C API does not validate
+// it.
+func (tuple *FlatTuple) checkIndex(index uint64) error {
+	if tuple.isClosed {
+		return &closedError{"failed to get value because the tuple is closed"}
+	}
+	if index >= tuple.numColumns {
+		return &ColumnIndexError{Index: index, NumColumns: tuple.numColumns}
+	}
+	return nil
+}
+
+// GetInterval returns the INTERVAL value at the given index in the FlatTuple
+// with its months, days and microseconds components kept separate.
+func (tuple *FlatTuple) GetInterval(index uint64) (Interval, error) {
+	tuple.queryResult.connection.closeMutex.RLock()
+	defer tuple.queryResult.connection.closeMutex.RUnlock()
+	interval, err := tuple.getInterval(index)
+	return interval, conversionError(index, err)
+}
+
+func (tuple *FlatTuple) getInterval(index uint64) (Interval, error) {
+	cValue, err := tuple.getCValue(index)
+	if err != nil {
+		return Interval{}, err
+	}
+	if C.lbug_value_is_null(&cValue) {
+		return Interval{}, fmt.Errorf("value at index %d is NULL", index)
+	}
+	var logicalType C.lbug_logical_type
+	defer C.lbug_data_type_destroy(&logicalType)
+	C.lbug_value_get_data_type(&cValue, &logicalType)
+	logicalTypeId := C.lbug_data_type_get_id(&logicalType)
+	if logicalTypeId != C.LBUG_INTERVAL {
+		return Interval{}, fmt.Errorf("value at index %d is not an interval, type id: %d", index, logicalTypeId)
+	}
+	var value C.lbug_interval_t
+	status := C.lbug_value_get_interval(&cValue, &value)
+	if status != C.LbugSuccess {
+		return Interval{}, fmt.Errorf("failed to get interval value with status: %d", status)
+	}
+	return lbugIntervalToInterval(value), nil
+}
+
+// GetNode returns the NODE value at the given index in the FlatTuple. It
+// returns an error if the value is NULL or of another type.
+func (tuple *FlatTuple) GetNode(index uint64) (Node, error) {
+	tuple.queryResult.connection.closeMutex.RLock()
+	defer tuple.queryResult.connection.closeMutex.RUnlock()
+	cValue, err := tuple.getCValueOfType(index, C.LBUG_NODE, "a node")
+	if err != nil {
+		return Node{}, conversionError(index, err)
+	}
+	node, err := lbugNodeValueToGoValue(cValue, &tuple.queryResult.converter)
+	return node, conversionError(index, err)
+}
+
+// GetRelationship returns the REL value at the given index in the FlatTuple.
+// It returns an error if the value is NULL or of another type.
+func (tuple *FlatTuple) GetRelationship(index uint64) (Relationship, error) {
+	tuple.queryResult.connection.closeMutex.RLock()
+	defer tuple.queryResult.connection.closeMutex.RUnlock()
+	cValue, err := tuple.getCValueOfType(index, C.LBUG_REL, "a relationship")
+	if err != nil {
+		return Relationship{}, conversionError(index, err)
+	}
+	rel, err := lbugRelValueToGoValue(cValue, &tuple.queryResult.converter)
+	return rel, conversionError(index, err)
+}
+
+// GetRecursiveRelationship returns the RECURSIVE_REL value at the given index
+// in the FlatTuple, with the nodes and the relationships in the order of the
+// path. The nodes of a path variable, e.g. p in MATCH p=(a)-[*1..3]->(b),
+// include both ends, while those of a relationship variable, e.g. e in
+// (a)-[e*1..3]->(b), are the intermediate nodes only. It returns an error if
+// the value is NULL or of another type.
+func (tuple *FlatTuple) GetRecursiveRelationship(index uint64) (RecursiveRelationship, error) {
+	tuple.queryResult.connection.closeMutex.RLock()
+	defer tuple.queryResult.connection.closeMutex.RUnlock()
+	cValue, err := tuple.getCValueOfType(index, C.LBUG_RECURSIVE_REL, "a recursive relationship")
+	if err != nil {
+		return RecursiveRelationship{}, conversionError(index, err)
+	}
+	path, err := lbugRecursiveRelValueToGoValue(cValue, &tuple.queryResult.converter)
+	return path, conversionError(index, err)
+}
+
+// getCValueOfType returns the C value at the given index, or an error if it is
+// NULL or not of the logical type, described by kind in the error.
+func (tuple *FlatTuple) getCValueOfType(index uint64, typeID C.lbug_data_type_id, kind string) (C.lbug_value, error) {
+	cValue, err := tuple.getCValue(index)
+	if err != nil {
+		return cValue, err
+	}
+	if C.lbug_value_is_null(&cValue) {
+		return cValue, fmt.Errorf("value at index %d is NULL", index)
+	}
+	var logicalType C.lbug_logical_type
+	defer C.lbug_data_type_destroy(&logicalType)
+	C.lbug_value_get_data_type(&cValue, &logicalType)
+	logicalTypeId := C.lbug_data_type_get_id(&logicalType)
+	if logicalTypeId != typeID {
+		return cValue, fmt.Errorf("value at index %d is not %s, type id: %d", index, kind, logicalTypeId)
+	}
+	return cValue, nil
+}
+
+// GetDuration returns the value at the given index in the FlatTuple as a
+// time.Duration. INTERVAL values are converted with Interval.ToDuration, so an
+// interval with a months component results in an IntervalConversionError
+// carrying the original Interval. Integer values are interpreted as a number
+// of microseconds.
+func (tuple *FlatTuple) GetDuration(index uint64) (time.Duration, error) {
+	tuple.queryResult.connection.closeMutex.RLock()
+	defer tuple.queryResult.connection.closeMutex.RUnlock()
+	duration, err := tuple.getDuration(index)
+	return duration, conversionError(index, err)
+}
+
+func (tuple *FlatTuple) getDuration(index uint64) (time.Duration, error) {
+	cValue, err := tuple.getCValue(index)
+	if err != nil {
+		return 0, err
+	}
+	if C.lbug_value_is_null(&cValue) {
+		return 0, fmt.Errorf("value at index %d is NULL", index)
+	}
+	var logicalType C.lbug_logical_type
+	defer C.lbug_data_type_destroy(&logicalType)
+	C.lbug_value_get_data_type(&cValue, &logicalType)
+	logicalTypeId := C.lbug_data_type_get_id(&logicalType)
+	if logicalTypeId == C.LBUG_INTERVAL {
+		var value C.lbug_interval_t
+		status := C.lbug_value_get_interval(&cValue, &value)
+		if status != C.LbugSuccess {
+			return 0, fmt.Errorf("failed to get interval value with status: %d", status)
+		}
+		return lbugIntervalToInterval(value).ToDuration()
+	}
+	goValue, err := lbugValueToGoValue(cValue, &tuple.queryResult.converter)
+	if err != nil {
+		return 0, err
+	}
+	var micros int64
+	switch v := goValue.(type) {
+	case int64:
+		micros = v
+	case int32:
+		micros = int64(v)
+	case int16:
+		micros = int64(v)
+	case int8:
+		micros = int64(v)
+	case uint64:
+		if v > uint64(maxDurationMicros) {
+			return 0, fmt.Errorf("value at index %d is out of the range of time.Duration: %d microseconds", index, v)
+		}
+		micros = int64(v)
+	case uint32:
+		micros = int64(v)
+	case uint16:
+		micros = int64(v)
+	case uint8:
+		micros = int64(v)
+	default:
+		return 0, fmt.Errorf("value at index %d cannot be converted to time.Duration, type id: %d", index, logicalTypeId)
+	}
+	if micros > maxDurationMicros || micros < minDurationMicros {
+		return 0, fmt.Errorf("value at index %d is out of the range of time.Duration: %d microseconds", index, micros)
 	}
-	return lbugValueToGoValue(cValue)
+	return time.Duration(micros) * time.Microsecond, nil
 }
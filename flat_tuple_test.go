@@ -1,6 +1,9 @@
 package lbug
 
 import (
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -10,6 +13,7 @@ func TestTupleClose(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	res, err := conn.Query("MATCH (a:person) RETURN a.fName;")
 	assert.Nil(t, err)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	tuple, err := res.Next()
 	assert.Nil(t, err)
@@ -20,11 +24,40 @@ func TestTupleClose(t *testing.T) {
 	assert.True(t, tuple.isClosed)
 }
 
+func TestTupleCloseConcurrent(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	res, err := conn.Query("MATCH (a:person) RETURN a.fName;")
+	assert.Nil(t, err)
+	defer res.Close()
+	assert.True(t, res.HasNext())
+	tuple, err := res.Next()
+	assert.Nil(t, err)
+	// Closing while other goroutines read or close the tuple destroys it once.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			tuple.Close()
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := tuple.GetValue(0); err != nil {
+				assert.ErrorIs(t, err, ErrClosed)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.True(t, tuple.isClosed)
+	assert.Equal(t, int64(0), conn.stats().openFlatTuples.Load())
+}
+
 func TestTupleGetAsString(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	query := "MATCH (a:person) RETURN a.fName, a.age ORDER BY a.fName LIMIT 1;"
 	res, err := conn.Query(query)
 	assert.Nil(t, err)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	tuple, err := res.Next()
 	assert.Nil(t, err)
@@ -37,6 +70,7 @@ func TestTupleGetAsSlice(t *testing.T) {
 	query := "MATCH (a:person) RETURN a.fName, a.gender, a.age ORDER BY a.fName LIMIT 1;"
 	res, err := conn.Query(query)
 	assert.Nil(t, err)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	tuple, err := res.Next()
 	assert.Nil(t, err)
@@ -54,6 +88,7 @@ func TestTupleGetAsMap(t *testing.T) {
 	query := "MATCH (a:person) RETURN a.fName, a.gender, a.age ORDER BY a.fName LIMIT 1;"
 	res, err := conn.Query(query)
 	assert.Nil(t, err)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	tuple, err := res.Next()
 	assert.Nil(t, err)
@@ -74,6 +109,7 @@ func TestGetValue(t *testing.T) {
 	query := "MATCH (a:person) RETURN a.fName, a.gender, a.age ORDER BY a.fName LIMIT 1;"
 	res, err := conn.Query(query)
 	assert.Nil(t, err)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	tuple, err := res.Next()
 	assert.Nil(t, err)
@@ -88,3 +124,217 @@ func TestGetValue(t *testing.T) {
 	assert.Equal(t, int64(35), value)
 	tuple.Close()
 }
+
+func TestTupleGetValueOutOfRange(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	res, err := conn.Query("MATCH (a:person) RETURN a.fName, a.age, a.lastJobDuration LIMIT 1;")
+	assert.Nil(t, err)
+	defer res.Close()
+	assert.True(t, res.HasNext())
+	tuple, err := res.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	_, err = tuple.GetValue(3)
+	assert.ErrorIs(t, err, ErrColumnIndexOutOfRange)
+	var indexErr *ColumnIndexError
+	assert.True(t, errors.As(err, &indexErr))
+	assert.Equal(t, uint64(3), indexErr.Index)
+	assert.Equal(t, uint64(3), indexErr.NumColumns)
+	assert.Equal(t, "column index 3 out of range [0, 2]", err.Error())
+	_, err = tuple.GetInterval(1 << 63)
+	assert.ErrorIs(t, err, ErrColumnIndexOutOfRange)
+	_, err = tuple.GetDuration(3)
+	assert.ErrorIs(t, err, ErrColumnIndexOutOfRange)
+	_, err = tuple.GetValue(2)
+	assert.Nil(t, err)
+}
+
+func TestTupleGetValueAfterClose(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	res, err := conn.Query("RETURN 1;")
+	assert.Nil(t, err)
+	defer res.Close()
+	tuple, err := res.Next()
+	assert.Nil(t, err)
+	tuple.Close()
+	_, err = tuple.GetValue(0)
+	assert.ErrorContains(t, err, "closed")
+}
+
+func TestTupleGetGraphValues(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	res, err := conn.Query("MATCH (p:person)-[r:workAt]->(o:organisation) WHERE p.ID = 5 RETURN p, r, o, p.fName;")
+	assert.Nil(t, err)
+	defer res.Close()
+	tuple, err := res.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	src, err := tuple.GetNode(0)
+	assert.Nil(t, err)
+	assert.Equal(t, "person", src.Label)
+	rel, err := tuple.GetRelationship(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "workAt", rel.Label)
+	assert.Equal(t, src.ID, rel.SourceID)
+	dst, err := tuple.GetNode(2)
+	assert.Nil(t, err)
+	assert.Equal(t, dst.ID, rel.DestinationID)
+
+	_, err = tuple.GetNode(1)
+	assert.ErrorContains(t, err, "value at index 1 is not a node")
+	_, err = tuple.GetRelationship(3)
+	assert.ErrorContains(t, err, "value at index 3 is not a relationship")
+	var convertErr *Error
+	assert.ErrorAs(t, err, &convertErr)
+	assert.Equal(t, uint64(3), convertErr.Column)
+	_, err = tuple.GetRecursiveRelationship(4)
+	assert.ErrorIs(t, err, ErrColumnIndexOutOfRange)
+}
+
+func TestTupleGetRecursiveRelationship(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	res, err := conn.Query("MATCH p = (a:person)-[:knows*2..2]->(b:person) WHERE a.ID = 0 RETURN p ORDER BY b.ID LIMIT 1;")
+	assert.Nil(t, err)
+	defer res.Close()
+	tuple, err := res.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	path, err := tuple.GetRecursiveRelationship(0)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(path.Nodes))
+	assert.Equal(t, 2, len(path.Relationships))
+	assert.Equal(t, "Alice", path.Nodes[0].Properties["fName"])
+	for i, rel := range path.Relationships {
+		assert.Equal(t, "knows", rel.Label)
+		assert.Equal(t, path.Nodes[i].ID, rel.SourceID)
+		assert.Equal(t, path.Nodes[i+1].ID, rel.DestinationID)
+	}
+
+	res, err = conn.Query("RETURN CAST(NULL, 'INT64');")
+	assert.Nil(t, err)
+	defer res.Close()
+	tuple, err = res.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	_, err = tuple.GetRecursiveRelationship(0)
+	assert.ErrorContains(t, err, "value at index 0 is NULL")
+}
+
+// openPathTestConnection returns a connection to a database with a chain of
+// steps 0 to length connected by next relationships, and the query of its only
+// path of the given length.
+func openPathTestConnection(tb testing.TB, length int) (*Connection, string) {
+	tb.Helper()
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(tb, err)
+	tb.Cleanup(db.Close)
+	conn, err := OpenConnection(db)
+	assert.Nil(tb, err)
+	tb.Cleanup(conn.Close)
+	for _, query := range []string{
+		"CREATE NODE TABLE step(id INT64, PRIMARY KEY(id));",
+		"CREATE REL TABLE next(FROM step TO step, weight DOUBLE);",
+		fmt.Sprintf("UNWIND range(0, %d) AS i CREATE (:step {id: i});", length),
+		fmt.Sprintf("UNWIND range(0, %d) AS i MATCH (a:step {id: i}), (b:step {id: i + 1}) CREATE (a)-[:next {weight: 0.5}]->(b);", length-1),
+		fmt.Sprintf("CALL var_length_extend_max_depth=%d;", length),
+	} {
+		result, err := conn.Query(query)
+		assert.Nil(tb, err, query)
+		result.Close()
+	}
+	return conn, fmt.Sprintf("MATCH p = (a:step {id: 0})-[:next*%d..%d]->(b:step) RETURN p;", length, length)
+}
+
+func TestMaxPathElements(t *testing.T) {
+	conn, query := openPathTestConnection(t, 3)
+	getPath := func() (RecursiveRelationship, error) {
+		result, err := conn.Query(query)
+		assert.Nil(t, err)
+		defer result.Close()
+		tuple, err := result.Next()
+		assert.Nil(t, err)
+		defer tuple.Close()
+		return tuple.GetRecursiveRelationship(0)
+	}
+
+	// The path has 4 nodes and 3 relationships.
+	conn.SetMaxPathElements(7)
+	path, err := getPath()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(path.Nodes))
+	assert.Equal(t, 3, len(path.Relationships))
+	for i, node := range path.Nodes {
+		assert.Equal(t, "step", node.Label)
+		assert.Equal(t, int64(i), node.Properties["id"])
+	}
+	for i, rel := range path.Relationships {
+		assert.Equal(t, "next", rel.Label)
+		assert.Equal(t, 0.5, rel.Properties["weight"])
+		assert.Equal(t, path.Nodes[i].ID, rel.SourceID)
+		assert.Equal(t, path.Nodes[i+1].ID, rel.DestinationID)
+	}
+
+	conn.SetMaxPathElements(6)
+	_, err = getPath()
+	assert.ErrorIs(t, err, ErrPathTooLong)
+	var lengthErr *PathLengthError
+	assert.ErrorAs(t, err, &lengthErr)
+	assert.Equal(t, PathLengthError{Elements: 7, Limit: 6}, *lengthErr)
+
+	// The limit applies to paths nested in other values too.
+	result, err := conn.Query("MATCH p = (a:step {id: 0})-[:next*3..3]->(b:step) RETURN [p];")
+	assert.Nil(t, err)
+	defer result.Close()
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	_, err = tuple.GetValue(0)
+	assert.ErrorIs(t, err, ErrPathTooLong)
+}
+
+// BenchmarkRecursiveRelDecoding measures the decoding of a path of 10001
+// elements, which spans many batches of elements.
+func BenchmarkRecursiveRelDecoding(b *testing.B) {
+	conn, query := openPathTestConnection(b, 5000)
+	result, err := conn.Query(query)
+	assert.Nil(b, err)
+	defer result.Close()
+	tuple, err := result.Next()
+	assert.Nil(b, err)
+	defer tuple.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		path, err := tuple.GetRecursiveRelationship(0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(path.Nodes)+len(path.Relationships) != 10001 {
+			b.Fatalf("path has %d elements", len(path.Nodes)+len(path.Relationships))
+		}
+	}
+}
+
+func FuzzTupleGetValue(f *testing.F) {
+	for _, index := range []uint64{0, 1, 2, 3, 1 << 32, 1<<64 - 1} {
+		f.Add(index)
+	}
+	f.Fuzz(func(t *testing.T, index uint64) {
+		_, conn := SetupTestDatabase(t)
+		res, err := conn.Query("RETURN 1, 'a';")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Close()
+		tuple, err := res.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tuple.Close()
+		_, err = tuple.GetValue(index)
+		if index < 2 {
+			assert.Nil(t, err)
+		} else {
+			assert.ErrorIs(t, err, ErrColumnIndexOutOfRange)
+		}
+	})
+}
@@ -0,0 +1,236 @@
+package lbug
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"slices"
+	"time"
+	"unsafe"
+)
+
+// freezeChunkSize is the number of rows fetched at a time by Freeze.
+const freezeChunkSize = 4096
+
+// FrozenResult is an immutable copy of all the rows of a query result in Go
+// memory, returned by QueryResult.Freeze. The values are stored column by
+// column as in a DataChunk, so that a large reference dataset can be computed
+// once and read by many goroutines. All its methods are safe for concurrent
+// use without locks, since nothing is modified after Freeze returns.
+type FrozenResult struct {
+	chunk       DataChunk
+	columnTypes []DataType
+	index       map[string]int
+	memoryUsage uint64
+}
+
+// Freeze fetches the remaining rows of the QueryResult into a FrozenResult
+// and closes the QueryResult, which must not be used afterwards. The
+// QueryResult is closed even if Freeze fails. The row limit set with
+// Connection.SetMaxRows applies as for Next.
+func (queryResult *QueryResult) Freeze() (*FrozenResult, error) {
+	defer queryResult.Close()
+	frozen := &FrozenResult{columnTypes: queryResult.GetColumnDataTypes()}
+	for {
+		chunk, err := queryResult.NextChunk(freezeChunkSize)
+		if err != nil {
+			return nil, err
+		}
+		if frozen.chunk.columns == nil {
+			frozen.chunk.columnNames = chunk.columnNames
+			frozen.chunk.columns = make([]chunkColumn, len(chunk.columns))
+			for i := range chunk.columns {
+				frozen.chunk.columns[i].typeID = chunk.columns[i].typeID
+			}
+		}
+		if chunk.numRows == 0 {
+			break
+		}
+		for i := range chunk.columns {
+			frozen.chunk.columns[i].appendColumn(&chunk.columns[i])
+		}
+		frozen.chunk.numRows += chunk.numRows
+	}
+	frozen.index = make(map[string]int, len(frozen.chunk.columnNames))
+	for i, name := range frozen.chunk.columnNames {
+		frozen.index[name] = i
+	}
+	for i := range frozen.chunk.columns {
+		column := &frozen.chunk.columns[i]
+		column.clip()
+		frozen.memoryUsage += column.memoryUsage()
+	}
+	return frozen, nil
+}
+
+// Len returns the number of rows.
+func (frozen *FrozenResult) Len() int {
+	return frozen.chunk.numRows
+}
+
+// ColumnNames returns the names of the columns. The slice is owned by the
+// FrozenResult and must not be modified.
+func (frozen *FrozenResult) ColumnNames() []string {
+	return frozen.chunk.columnNames
+}
+
+// ColumnDataTypes returns the logical types of the columns, in the order of
+// ColumnNames.
+func (frozen *FrozenResult) ColumnDataTypes() []DataType {
+	return slices.Clone(frozen.columnTypes)
+}
+
+// Row returns the values of the row at the index, of the same Go types as
+// returned by FlatTuple.GetAsSlice. It panics if the index is out of range.
+// The returned slice belongs to the caller, but the nested values, such as
+// lists, structs and nodes, are shared and must not be modified.
+func (frozen *FrozenResult) Row(i int) []any {
+	if i < 0 || i >= frozen.chunk.numRows {
+		panic(fmt.Sprintf("row index %d out of range [0, %d)", i, frozen.chunk.numRows))
+	}
+	row := make([]any, len(frozen.chunk.columns))
+	for col := range row {
+		row[col] = frozen.chunk.Value(i, col)
+	}
+	return row
+}
+
+// Column returns the values of the column, of the same Go types as returned
+// by FlatTuple.GetValue, or an error wrapping ErrNoSuchColumn if the result
+// has no such column. The slice is allocated on every call, with the nested
+// values shared as for Row; the typed accessors of Chunk avoid the
+// allocation.
+func (frozen *FrozenResult) Column(name string) ([]any, error) {
+	col, ok := frozen.index[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: the result has no column %s", ErrNoSuchColumn, name)
+	}
+	values := make([]any, frozen.chunk.numRows)
+	for row := range values {
+		values[row] = frozen.chunk.Value(row, col)
+	}
+	return values, nil
+}
+
+// Chunk returns the rows as a single DataChunk, whose typed accessors, e.g.
+// Int64Column, return the stored columns without copying them. The chunk is
+// owned by the FrozenResult and must not be modified.
+func (frozen *FrozenResult) Chunk() *DataChunk {
+	return &frozen.chunk
+}
+
+// MemoryUsage returns an estimate of the number of bytes of Go memory held by
+// the values of the FrozenResult.
+func (frozen *FrozenResult) MemoryUsage() uint64 {
+	return frozen.memoryUsage
+}
+
+// appendColumn appends the values of another column of the same type.
+func (column *chunkColumn) appendColumn(other *chunkColumn) {
+	column.int64s = append(column.int64s, other.int64s...)
+	column.uint64s = append(column.uint64s, other.uint64s...)
+	column.float64s = append(column.float64s, other.float64s...)
+	column.strings = append(column.strings, other.strings...)
+	column.values = append(column.values, other.values...)
+	column.nulls = append(column.nulls, other.nulls...)
+}
+
+// clip reallocates the slices of the column to their length, releasing the
+// capacity left by appending.
+func (column *chunkColumn) clip() {
+	column.int64s = clipSlice(column.int64s)
+	column.uint64s = clipSlice(column.uint64s)
+	column.float64s = clipSlice(column.float64s)
+	column.strings = clipSlice(column.strings)
+	column.values = clipSlice(column.values)
+	column.nulls = clipSlice(column.nulls)
+}
+
+// clipSlice returns a copy of the slice without spare capacity, or the slice
+// itself if it has none.
+func clipSlice[T any](s []T) []T {
+	if len(s) == cap(s) {
+		return s
+	}
+	return slices.Clone(s)
+}
+
+// memoryUsage returns an estimate of the number of bytes held by the column.
+func (column *chunkColumn) memoryUsage() uint64 {
+	size := uint64(len(column.nulls)) + 8*uint64(len(column.int64s)+len(column.uint64s)+len(column.float64s))
+	for _, s := range column.strings {
+		size += uint64(unsafe.Sizeof(s)) + uint64(len(s))
+	}
+	for _, value := range column.values {
+		size += valueMemoryUsage(value)
+	}
+	return size
+}
+
+// valueMemoryUsage returns an estimate of the number of bytes held by a value
+// converted from the C API, including the interface holding it.
+func valueMemoryUsage(value any) uint64 {
+	const interfaceSize = uint64(unsafe.Sizeof(any(nil)))
+	switch v := value.(type) {
+	case nil:
+		return interfaceSize
+	case string:
+		return interfaceSize + uint64(unsafe.Sizeof(v)) + uint64(len(v))
+	case []byte:
+		return interfaceSize + uint64(unsafe.Sizeof(v)) + uint64(cap(v))
+	case *big.Int:
+		return interfaceSize + uint64(unsafe.Sizeof(*v)) + uint64(len(v.Bits()))*uint64(unsafe.Sizeof(big.Word(0)))
+	case time.Time:
+		return interfaceSize + uint64(unsafe.Sizeof(v))
+	case []any:
+		size := interfaceSize + uint64(unsafe.Sizeof(v))
+		for _, element := range v {
+			size += valueMemoryUsage(element)
+		}
+		return size
+	case map[string]any:
+		return interfaceSize + propertiesMemoryUsage(v)
+	case []MapItem:
+		size := interfaceSize + uint64(unsafe.Sizeof(v))
+		for _, item := range v {
+			size += valueMemoryUsage(item.Key) + valueMemoryUsage(item.Value)
+		}
+		return size
+	case Node:
+		return interfaceSize + nodeMemoryUsage(v)
+	case Relationship:
+		return interfaceSize + relationshipMemoryUsage(v)
+	case RecursiveRelationship:
+		size := interfaceSize + uint64(unsafe.Sizeof(v))
+		for _, node := range v.Nodes {
+			size += nodeMemoryUsage(node)
+		}
+		for _, rel := range v.Relationships {
+			size += relationshipMemoryUsage(rel)
+		}
+		return size
+	}
+	return interfaceSize + uint64(reflect.TypeOf(value).Size())
+}
+
+// propertiesMemoryUsage returns an estimate of the number of bytes held by a
+// map of properties or a struct value.
+func propertiesMemoryUsage(properties map[string]any) uint64 {
+	// The map header and its buckets.
+	size := uint64(48)
+	for key, value := range properties {
+		size += uint64(unsafe.Sizeof(key)) + uint64(len(key)) + valueMemoryUsage(value)
+	}
+	return size
+}
+
+// nodeMemoryUsage returns an estimate of the number of bytes held by a node.
+func nodeMemoryUsage(node Node) uint64 {
+	return uint64(unsafe.Sizeof(node)) + uint64(len(node.Label)) + propertiesMemoryUsage(node.Properties)
+}
+
+// relationshipMemoryUsage returns an estimate of the number of bytes held by
+// a relationship.
+func relationshipMemoryUsage(rel Relationship) uint64 {
+	return uint64(unsafe.Sizeof(rel)) + uint64(len(rel.Label)) + propertiesMemoryUsage(rel.Properties)
+}
@@ -0,0 +1,70 @@
+package lbug
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreeze(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	// More rows than a chunk, to check that the chunks are concatenated.
+	result, err := conn.Query("UNWIND range(1, 10000) AS i RETURN i, 'v' + CAST(i AS STRING) AS s, [i, i] AS l, CASE WHEN i % 2 = 0 THEN NULL ELSE i * 1.5 END AS f ORDER BY i;")
+	assert.Nil(t, err)
+	frozen, err := result.Freeze()
+	assert.Nil(t, err)
+	assert.True(t, result.isClosed)
+	assert.Equal(t, 10000, frozen.Len())
+	assert.Equal(t, []string{"i", "s", "l", "f"}, frozen.ColumnNames())
+	assert.Equal(t, "LIST", frozen.ColumnDataTypes()[2].Name)
+	assert.Equal(t, []any{int64(5000), "v5000", []any{int64(5000), int64(5000)}, nil}, frozen.Row(4999))
+	assert.Equal(t, []any{int64(9999), "v9999", []any{int64(9999), int64(9999)}, 14998.5}, frozen.Row(9998))
+	assert.Panics(t, func() { frozen.Row(10000) })
+
+	column, err := frozen.Column("s")
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", column[0])
+	assert.Equal(t, "v10000", column[9999])
+	_, err = frozen.Column("missing")
+	assert.ErrorIs(t, err, ErrNoSuchColumn)
+	ids, err := frozen.Chunk().Int64Column(0)
+	assert.Nil(t, err)
+	assert.Equal(t, 10000, len(ids))
+	assert.Equal(t, 10000, cap(ids))
+	assert.True(t, frozen.MemoryUsage() > 10000*(8+1+16+2))
+
+	// Concurrent readers need no locks; the race detector checks it.
+	var wg sync.WaitGroup
+	for worker := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := worker; i < frozen.Len(); i += 8 {
+				assert.Equal(t, int64(i+1), frozen.Row(i)[0])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFreezeEmptyAndClosed(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	result, err := conn.Query("UNWIND [] AS i RETURN CAST(i AS INT64) AS i;")
+	assert.Nil(t, err)
+	frozen, err := result.Freeze()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, frozen.Len())
+	assert.Equal(t, []string{"i"}, frozen.ColumnNames())
+
+	_, err = result.Freeze()
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestValueMemoryUsage(t *testing.T) {
+	assert.Equal(t, uint64(16+16+5), valueMemoryUsage("hello"))
+	assert.Equal(t, uint64(16+24+2*16), valueMemoryUsage([]any{nil, nil}))
+	node := Node{Label: "person", Properties: map[string]any{"name": "Alice"}}
+	assert.True(t, valueMemoryUsage(node) > valueMemoryUsage(Node{}))
+	assert.Equal(t, uint64(16+8), valueMemoryUsage(int64(1)))
+}
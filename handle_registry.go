@@ -0,0 +1,342 @@
+package lbug
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// HandleKind identifies the type of a C handle owned by the package.
+type HandleKind int
+
+const (
+	HandleDatabase HandleKind = iota
+	HandleConnection
+	HandlePreparedStatement
+	HandleQueryResult
+	HandleFlatTuple
+	numHandleKinds
+)
+
+// String returns the name of the Go type that owns handles of this kind.
+func (kind HandleKind) String() string {
+	switch kind {
+	case HandleDatabase:
+		return "Database"
+	case HandleConnection:
+		return "Connection"
+	case HandlePreparedStatement:
+		return "PreparedStatement"
+	case HandleQueryResult:
+		return "QueryResult"
+	case HandleFlatTuple:
+		return "FlatTuple"
+	default:
+		return fmt.Sprintf("HandleKind(%d)", int(kind))
+	}
+}
+
+// HandleInfo describes an open C handle that was created while handle
+// tracking was enabled.
+type HandleInfo struct {
+	// ID is a process-wide unique identifier of the handle.
+	ID uint64
+	// Kind is the type of the handle.
+	Kind HandleKind
+	// Stack is the stack trace of the goroutine that created the handle.
+	Stack string
+}
+
+// limited returns true if SetMaxOpenHandles can limit the handles of the kind.
+func (kind HandleKind) limited() bool {
+	return kind == HandlePreparedStatement || kind == HandleQueryResult || kind == HandleFlatTuple
+}
+
+// handleRegistry keeps exact counts of the open C handles by kind, the
+// databases and connections owning them so that CloseAll can find them and,
+// when tracking is enabled, the creation stack of every handle. The
+// statements, results and tuples, which are created far more often, are kept
+// by their connection, see Connection.trackChild, so that creating them does
+// not take the lock of the registry.
+type handleRegistry struct {
+	nextID   atomic.Uint64
+	counts   [numHandleKinds]atomic.Int64
+	tracking atomic.Bool
+	mu       sync.Mutex
+	tracked  map[uint64]HandleInfo
+	objects  map[uint64]any
+	// numTracked is the number of entries of tracked, so that the handles of
+	// the limited kinds are unregistered without taking mu when there is none.
+	numTracked atomic.Int64
+	// limitedOpen counts the open handles of the limited kinds together.
+	limitedOpen atomic.Int64
+	// limits and totalLimit are the limits set with SetMaxOpenHandles, by
+	// kind and for the limited kinds together, or zero for no limit.
+	limits     [numHandleKinds]atomic.Int64
+	totalLimit atomic.Int64
+}
+
+var handles = &handleRegistry{tracked: make(map[uint64]HandleInfo)}
+
+// register records a newly created C handle owned by the object and returns
+// its ID.
+func (registry *handleRegistry) register(kind HandleKind, object any) uint64 {
+	registry.counts[kind].Add(1)
+	if kind.limited() {
+		registry.limitedOpen.Add(1)
+	}
+	return registry.track(kind, object)
+}
+
+// reserve counts a C handle of a limited kind that is about to be created, or
+// returns a *HandleLimitError if it would exceed the limits set with
+// SetMaxOpenHandles. The counts are checked and incremented atomically, so
+// that concurrent creations never exceed the limits. Once the handle is
+// created, it is registered with track; if its creation fails, the
+// reservation is undone with cancel.
+func (registry *handleRegistry) reserve(kind HandleKind) error {
+	if limit := registry.limits[kind].Load(); !incrementBelow(&registry.counts[kind], limit) {
+		return registry.limitError(kind, limit, false)
+	}
+	if limit := registry.totalLimit.Load(); !incrementBelow(&registry.limitedOpen, limit) {
+		registry.counts[kind].Add(-1)
+		return registry.limitError(kind, limit, true)
+	}
+	return nil
+}
+
+// cancel undoes a reservation of a C handle whose creation failed.
+func (registry *handleRegistry) cancel(kind HandleKind) {
+	registry.counts[kind].Add(-1)
+	registry.limitedOpen.Add(-1)
+}
+
+// incrementBelow increments the counter unless it has reached the limit, and
+// returns true if it was incremented. A limit of zero is no limit.
+func incrementBelow(counter *atomic.Int64, limit int64) bool {
+	for {
+		count := counter.Load()
+		if limit > 0 && count >= limit {
+			return false
+		}
+		if counter.CompareAndSwap(count, count+1) {
+			return true
+		}
+	}
+}
+
+// limitError returns the error of a handle of the kind exceeding the limit.
+func (registry *handleRegistry) limitError(kind HandleKind, limit int64, total bool) error {
+	return &HandleLimitError{Kind: kind, Limit: limit, Total: total, Open: registry.openCounts()}
+}
+
+// track records the object owning a newly created C handle, which has been
+// counted already, and returns its ID. The object is nil for the handles of
+// the limited kinds, which are kept by their connection.
+func (registry *handleRegistry) track(kind HandleKind, object any) uint64 {
+	id := registry.nextID.Add(1)
+	var info *HandleInfo
+	if registry.tracking.Load() {
+		info = &HandleInfo{ID: id, Kind: kind, Stack: callerStack()}
+	}
+	if info == nil && object == nil {
+		return id
+	}
+	registry.mu.Lock()
+	if info != nil {
+		registry.tracked[id] = *info
+		registry.numTracked.Add(1)
+	}
+	if object != nil {
+		if registry.objects == nil {
+			registry.objects = make(map[uint64]any)
+		}
+		registry.objects[id] = object
+	}
+	registry.mu.Unlock()
+	return id
+}
+
+// unregister records that the C handle with the given ID has been destroyed.
+// IDs of zero belong to handles that were never registered and are ignored.
+func (registry *handleRegistry) unregister(kind HandleKind, id uint64) {
+	if id == 0 {
+		return
+	}
+	registry.counts[kind].Add(-1)
+	if kind.limited() {
+		registry.limitedOpen.Add(-1)
+		if registry.numTracked.Load() == 0 {
+			return
+		}
+	}
+	registry.mu.Lock()
+	if _, ok := registry.tracked[id]; ok {
+		delete(registry.tracked, id)
+		registry.numTracked.Add(-1)
+	}
+	delete(registry.objects, id)
+	registry.mu.Unlock()
+}
+
+// openObjects returns the databases and connections owning open C handles.
+func (registry *handleRegistry) openObjects() []any {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	objects := make([]any, 0, len(registry.objects))
+	for _, object := range registry.objects {
+		objects = append(objects, object)
+	}
+	return objects
+}
+
+// trackChild records a newly created C handle of a statement, result or
+// tuple of the connection, which has been counted already, and returns its ID.
+func (conn *Connection) trackChild(kind HandleKind, object any) uint64 {
+	id := handles.track(kind, nil)
+	conn.childrenMutex.Lock()
+	if conn.children == nil {
+		conn.children = make(map[uint64]any)
+	}
+	conn.children[id] = object
+	conn.childrenMutex.Unlock()
+	return id
+}
+
+// untrackChild records that the C handle of a statement, result or tuple of
+// the connection with the given ID has been destroyed. IDs of zero are
+// ignored, as by unregister.
+func (conn *Connection) untrackChild(kind HandleKind, id uint64) {
+	if id == 0 {
+		return
+	}
+	handles.unregister(kind, id)
+	conn.childrenMutex.Lock()
+	delete(conn.children, id)
+	conn.childrenMutex.Unlock()
+}
+
+// openChildren returns the statements, results and tuples of the connection
+// that are not closed yet.
+func (conn *Connection) openChildren() []any {
+	conn.childrenMutex.Lock()
+	defer conn.childrenMutex.Unlock()
+	children := make([]any, 0, len(conn.children))
+	for _, child := range conn.children {
+		children = append(children, child)
+	}
+	return children
+}
+
+// callerStack returns the stack trace of the caller, omitting the frames of
+// the handle registry itself.
+func callerStack() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var builder strings.Builder
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&builder, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return builder.String()
+}
+
+// SetHandleTracking enables or disables recording of creation stacks for newly
+// created C handles. Tracking is disabled by default because capturing a stack
+// for every FlatTuple is expensive. Open handle counts are always maintained.
+func SetHandleTracking(enabled bool) {
+	handles.tracking.Store(enabled)
+}
+
+// IsHandleTrackingEnabled returns true if creation stacks are being recorded.
+func IsHandleTrackingEnabled() bool {
+	return handles.tracking.Load()
+}
+
+// OpenHandleCounts returns the number of open C handles by kind. The counts
+// include handles created while tracking was disabled, and are the counts
+// checked against the limits set with SetMaxOpenHandles.
+func OpenHandleCounts() map[HandleKind]int64 {
+	return handles.openCounts()
+}
+
+// openCounts returns the number of open C handles by kind.
+func (registry *handleRegistry) openCounts() map[HandleKind]int64 {
+	counts := make(map[HandleKind]int64, numHandleKinds)
+	for kind := HandleKind(0); kind < numHandleKinds; kind++ {
+		counts[kind] = registry.counts[kind].Load()
+	}
+	return counts
+}
+
+// HandleLimits are the maximum numbers of C handles open at the same time in
+// the process. Zero is no limit. The handles of databases and connections,
+// which are opened once and kept, are not limited.
+type HandleLimits struct {
+	// Total limits the prepared statements, query results and flat tuples
+	// together.
+	Total              int64
+	PreparedStatements int64
+	QueryResults       int64
+	FlatTuples         int64
+}
+
+// SetMaxOpenHandles sets the maximum numbers of C handles open at the same
+// time in the process, as a safety valve against callers that do not close
+// their results: native memory may run out long before the garbage collector
+// finalizes them. Once a limit is reached, Prepare, Query, Execute,
+// NextQueryResult and Next fail with a *HandleLimitError matching
+// ErrTooManyOpenHandles, without creating the handle, until handles are
+// closed. Handles open already are not closed when the limits are lowered.
+// The rejections are counted in DatabaseStats.HandleLimitsExceeded. No limit
+// is set by default.
+func SetMaxOpenHandles(limits HandleLimits) {
+	handles.totalLimit.Store(limits.Total)
+	handles.limits[HandlePreparedStatement].Store(limits.PreparedStatements)
+	handles.limits[HandleQueryResult].Store(limits.QueryResults)
+	handles.limits[HandleFlatTuple].Store(limits.FlatTuples)
+}
+
+// MaxOpenHandles returns the limits set with SetMaxOpenHandles, so that
+// dashboards can compare them with OpenHandleCounts and alert before they are
+// reached.
+func MaxOpenHandles() HandleLimits {
+	return HandleLimits{
+		Total:              handles.totalLimit.Load(),
+		PreparedStatements: handles.limits[HandlePreparedStatement].Load(),
+		QueryResults:       handles.limits[HandleQueryResult].Load(),
+		FlatTuples:         handles.limits[HandleFlatTuple].Load(),
+	}
+}
+
+// reserveHandle reserves a C handle of the kind for the connection, counting
+// the rejection in the stats of its database if a limit is exceeded.
+func (conn *Connection) reserveHandle(kind HandleKind) error {
+	err := handles.reserve(kind)
+	if err != nil {
+		conn.stats().handleLimitsExceeded.Add(1)
+	}
+	return err
+}
+
+// OpenHandles returns the open C handles that were created while tracking was
+// enabled, ordered by creation.
+func OpenHandles() []HandleInfo {
+	handles.mu.Lock()
+	infos := make([]HandleInfo, 0, len(handles.tracked))
+	for _, info := range handles.tracked {
+		infos = append(infos, info)
+	}
+	handles.mu.Unlock()
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
@@ -0,0 +1,173 @@
+package lbug
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleRegistryCounts(t *testing.T) {
+	registry := &handleRegistry{tracked: make(map[uint64]HandleInfo)}
+	first := registry.register(HandleQueryResult, nil)
+	second := registry.register(HandleQueryResult, nil)
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, int64(2), registry.counts[HandleQueryResult].Load())
+	// Untracked handles are counted but not enumerated
+	assert.Equal(t, 0, len(registry.tracked))
+	registry.unregister(HandleQueryResult, first)
+	registry.unregister(HandleQueryResult, 0)
+	assert.Equal(t, int64(1), registry.counts[HandleQueryResult].Load())
+}
+
+func TestHandleRegistryTracking(t *testing.T) {
+	registry := &handleRegistry{tracked: make(map[uint64]HandleInfo)}
+	registry.tracking.Store(true)
+	id := registry.register(HandleFlatTuple, nil)
+	info, ok := registry.tracked[id]
+	assert.True(t, ok)
+	assert.Equal(t, HandleFlatTuple, info.Kind)
+	assert.Contains(t, info.Stack, "TestHandleRegistryTracking")
+	registry.unregister(HandleFlatTuple, id)
+	assert.Equal(t, 0, len(registry.tracked))
+}
+
+func TestConnectionChildren(t *testing.T) {
+	conn := &Connection{}
+	before := len(handles.openObjects())
+	assert.Nil(t, handles.reserve(HandleFlatTuple))
+	tuple := &FlatTuple{}
+	id := conn.trackChild(HandleFlatTuple, tuple)
+	// The tuple is kept by its connection, not by the registry.
+	assert.Equal(t, []any{tuple}, conn.openChildren())
+	assert.Equal(t, before, len(handles.openObjects()))
+	conn.untrackChild(HandleFlatTuple, id)
+	conn.untrackChild(HandleFlatTuple, 0)
+	assert.Empty(t, conn.openChildren())
+}
+
+func TestOpenHandles(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	defer SetHandleTracking(IsHandleTrackingEnabled())
+	SetHandleTracking(true)
+	before := OpenHandleCounts()
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	res, err := conn.Query("RETURN 1;")
+	assert.Nil(t, err)
+	counts := OpenHandleCounts()
+	assert.Equal(t, before[HandleConnection]+1, counts[HandleConnection])
+	assert.Equal(t, before[HandleQueryResult]+1, counts[HandleQueryResult])
+	found := false
+	for _, info := range OpenHandles() {
+		if info.ID == res.handleID {
+			found = true
+			assert.Equal(t, HandleQueryResult, info.Kind)
+		}
+	}
+	assert.True(t, found)
+	res.Close()
+	conn.Close()
+	assert.Equal(t, before, OpenHandleCounts())
+}
+
+func TestHandleRegistryLimits(t *testing.T) {
+	registry := &handleRegistry{tracked: make(map[uint64]HandleInfo)}
+	registry.limits[HandleQueryResult].Store(2)
+	registry.totalLimit.Store(3)
+	assert.Nil(t, registry.reserve(HandleQueryResult))
+	first := registry.track(HandleQueryResult, nil)
+	assert.Nil(t, registry.reserve(HandleQueryResult))
+	registry.cancel(HandleQueryResult)
+	assert.Nil(t, registry.reserve(HandleQueryResult))
+	registry.track(HandleQueryResult, nil)
+	err := registry.reserve(HandleQueryResult)
+	var limitErr *HandleLimitError
+	assert.ErrorAs(t, err, &limitErr)
+	assert.ErrorIs(t, err, ErrTooManyOpenHandles)
+	assert.Equal(t, HandleQueryResult, limitErr.Kind)
+	assert.Equal(t, int64(2), limitErr.Limit)
+	assert.False(t, limitErr.Total)
+	assert.Equal(t, int64(2), limitErr.Open[HandleQueryResult])
+	assert.Equal(t, "cannot open a QueryResult: the limit of 2 open QueryResult handles is reached (open handles: Database=0, Connection=0, PreparedStatement=0, QueryResult=2, FlatTuple=0); close the handles that are no longer used", err.Error())
+
+	// The total limit covers the limited kinds together, and the handles of
+	// other kinds are not counted in it.
+	registry.register(HandleConnection, nil)
+	assert.Nil(t, registry.reserve(HandleFlatTuple))
+	registry.track(HandleFlatTuple, nil)
+	err = registry.reserve(HandleFlatTuple)
+	assert.ErrorAs(t, err, &limitErr)
+	assert.True(t, limitErr.Total)
+	assert.Equal(t, int64(3), limitErr.Limit)
+	assert.Equal(t, int64(1), registry.counts[HandleFlatTuple].Load())
+
+	registry.unregister(HandleQueryResult, first)
+	assert.Nil(t, registry.reserve(HandleFlatTuple))
+}
+
+func TestHandleRegistryLimitsConcurrent(t *testing.T) {
+	registry := &handleRegistry{tracked: make(map[uint64]HandleInfo)}
+	registry.limits[HandleFlatTuple].Store(10)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if registry.reserve(HandleFlatTuple) == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 10, reserved)
+	assert.Equal(t, int64(10), registry.counts[HandleFlatTuple].Load())
+	assert.Equal(t, int64(10), registry.limitedOpen.Load())
+}
+
+func TestSetMaxOpenHandles(t *testing.T) {
+	db, conn := SetupTestDatabase(t)
+	defer SetMaxOpenHandles(HandleLimits{})
+	open := OpenHandleCounts()
+	SetMaxOpenHandles(HandleLimits{QueryResults: open[HandleQueryResult] + 1, FlatTuples: open[HandleFlatTuple] + 1})
+	assert.Equal(t, open[HandleQueryResult]+1, MaxOpenHandles().QueryResults)
+	exceeded := db.Stats().HandleLimitsExceeded
+
+	res, err := conn.Query("UNWIND [1, 2] AS i RETURN i;")
+	assert.Nil(t, err)
+	_, err = conn.Query("RETURN 1;")
+	assert.ErrorIs(t, err, ErrTooManyOpenHandles)
+	tuple, err := res.Next()
+	assert.Nil(t, err)
+	_, err = res.Next()
+	var limitErr *HandleLimitError
+	assert.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, HandleFlatTuple, limitErr.Kind)
+	assert.Equal(t, OpenHandleCounts(), limitErr.Open)
+	assert.Equal(t, exceeded+2, db.Stats().HandleLimitsExceeded)
+
+	// Closing handles makes room for new ones. The rejected tuple was not
+	// fetched, so the result still has it.
+	tuple.Close()
+	tuple, err = res.Next()
+	assert.Nil(t, err)
+	value, err := tuple.GetValue(0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), value)
+	tuple.Close()
+	res.Close()
+	res, err = conn.Query("RETURN 1;")
+	assert.Nil(t, err)
+	res.Close()
+	assert.Equal(t, open, OpenHandleCounts())
+}
+
+func TestHandleKindString(t *testing.T) {
+	assert.Equal(t, "Database", HandleDatabase.String())
+	assert.Equal(t, "FlatTuple", HandleFlatTuple.String())
+	assert.Equal(t, "HandleKind(42)", HandleKind(42).String())
+}
@@ -39,15 +39,20 @@ func initTinySNB(conn *Connection) error {
 		return err
 	}
 
-	conn.Query("create node table moviesSerial (ID SERIAL, name STRING, length INT32, note STRING, PRIMARY KEY (ID));")
+	result, err := conn.Query("create node table moviesSerial (ID SERIAL, name STRING, length INT32, note STRING, PRIMARY KEY (ID));")
+	if err != nil {
+		return err
+	}
+	result.Close()
 	moviesSerialPath := filepath.Join(tinySnbPath, "vMoviesSerial.csv")
 	// Normalize the path for Windows
 	moviesSerialPath = strings.ReplaceAll(moviesSerialPath, "\\", "/")
 	moviesSerialCopyQuery := fmt.Sprintf("copy moviesSerial from \"%s\"", moviesSerialPath)
-	_, err = conn.Query(moviesSerialCopyQuery)
+	result, err = conn.Query(moviesSerialCopyQuery)
 	if err != nil {
 		return err
 	}
+	result.Close()
 	return nil
 }
 
@@ -67,10 +72,11 @@ func executeCypherFromFile(filePath string, conn *Connection, originalString *st
 		if originalString != nil && replaceString != nil {
 			line = strings.ReplaceAll(line, *originalString, *replaceString)
 		}
-		_, err := conn.Query(line)
+		result, err := conn.Query(line)
 		if err != nil {
 			return err
 		}
+		result.Close()
 	}
 
 	if err := scanner.Err(); err != nil {
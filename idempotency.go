@@ -0,0 +1,192 @@
+package lbug
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// defaultBatchTable is the node table recording the applied batches when
+// IdempotencyOptions.Table is not set.
+const defaultBatchTable = "lbug_batches"
+
+// afterBatchApplyForTesting, if set, is called by the ingestion helpers after
+// a batch has been applied and recorded and before its transaction is
+// committed. If it returns an error, the transaction is rolled back.
+var afterBatchApplyForTesting func(batchID string) error
+
+// afterBatchCommitForTesting, if set, is called by the ingestion helpers after
+// the transaction of a batch has been committed. If it returns an error, the
+// helper returns it, as if the acknowledgment of the batch had been lost.
+var afterBatchCommitForTesting func(batchID string) error
+
+// IdempotencyOptions makes an ingestion helper apply a batch exactly once, so
+// that a batch retried after a failure whose outcome is unknown is not applied
+// twice.
+type IdempotencyOptions struct {
+	// BatchID identifies the batch. If it is empty, the batch is applied
+	// without bookkeeping.
+	BatchID string
+	// Table is the node table recording the IDs of the applied batches, which
+	// is created when a batch is first applied. If it is empty,
+	// "lbug_batches" is used.
+	Table string
+	// Retention is how long the ID of an applied batch is kept. The IDs
+	// recorded more than Retention before a batch is applied are deleted in
+	// its transaction, after which a batch with such an ID is applied again.
+	// If it is zero, the IDs are kept until Connection.PruneBatches deletes
+	// them.
+	Retention time.Duration
+}
+
+func (opts IdempotencyOptions) table() string {
+	if opts.Table == "" {
+		return defaultBatchTable
+	}
+	return opts.Table
+}
+
+// ApplyOnce runs apply in a transaction that records opts.BatchID, and
+// returns true. If a batch with the ID has already been applied, apply is not
+// run and ApplyOnce returns false. If apply fails, the transaction is rolled
+// back and the batch may be submitted again. apply runs the statements of the
+// batch on the connection and must not begin or end a transaction.
+//
+// The batches of the connections of a database are applied one at a time, so
+// that of two concurrent submissions of a batch, one applies it and the other
+// returns false.
+func (conn *Connection) ApplyOnce(opts IdempotencyOptions, apply func() error) (bool, error) {
+	if opts.BatchID == "" {
+		return false, errors.New("batch ID must not be empty")
+	}
+	return conn.applyBatch(opts, nil, apply)
+}
+
+// PruneBatches deletes the IDs of the batches of the table recorded before
+// the time, after which batches with these IDs are applied again, and returns
+// their number. If the table is empty, "lbug_batches" is used.
+func (conn *Connection) PruneBatches(table string, before time.Time) (uint64, error) {
+	table = IdempotencyOptions{Table: table}.table()
+	tables, err := listTables(conn)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := tables[table]; !ok {
+		return 0, nil
+	}
+	summary, err := execStatement(conn, fmt.Sprintf("MATCH (b:%s) WHERE b.applied_at < $before DELETE b RETURN 1;", QuoteIdentifier(table)),
+		map[string]any{"before": before})
+	return summary.NumTuples, err
+}
+
+// applyBatch runs apply in a transaction recording the batch and the summary,
+// which apply sets and which is encoded as JSON, and returns true. If the
+// batch has already been applied, apply is not run, the recorded summary is
+// decoded into summary and applyBatch returns false.
+func (conn *Connection) applyBatch(opts IdempotencyOptions, summary any, apply func() error) (bool, error) {
+	conn.database.batches.Lock()
+	defer conn.database.batches.Unlock()
+	table := QuoteIdentifier(opts.table())
+	if err := runStatement(conn, fmt.Sprintf("CREATE NODE TABLE IF NOT EXISTS %s(id STRING, applied_at TIMESTAMP, summary STRING, PRIMARY KEY(id));", table)); err != nil {
+		return false, err
+	}
+	if err := runStatement(conn, "BEGIN TRANSACTION;"); err != nil {
+		return false, err
+	}
+	applied, err := conn.appliedBatch(table, opts.BatchID, summary)
+	if err != nil || applied {
+		runStatement(conn, "ROLLBACK;")
+		return false, err
+	}
+	err = func() error {
+		if err := apply(); err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(summary)
+		if err != nil {
+			return fmt.Errorf("failed to encode the summary of batch %s: %w", opts.BatchID, err)
+		}
+		now := conn.now()
+		if opts.Retention > 0 {
+			if _, err := execStatement(conn, fmt.Sprintf("MATCH (b:%s) WHERE b.applied_at < $before DELETE b;", table),
+				map[string]any{"before": now.Add(-opts.Retention)}); err != nil {
+				return err
+			}
+		}
+		if _, err := execStatement(conn, fmt.Sprintf("CREATE (:%s {id: $id, applied_at: $appliedAt, summary: $summary});", table),
+			map[string]any{"id": opts.BatchID, "appliedAt": now, "summary": string(encoded)}); err != nil {
+			return err
+		}
+		if afterBatchApplyForTesting != nil {
+			return afterBatchApplyForTesting(opts.BatchID)
+		}
+		return nil
+	}()
+	if err == nil {
+		err = runStatement(conn, "COMMIT;")
+	} else {
+		runStatement(conn, "ROLLBACK;")
+	}
+	if err != nil {
+		// The batch may have been recorded by another process, in which case
+		// the record conflicts with it.
+		if applied, lookupErr := conn.appliedBatch(table, opts.BatchID, summary); lookupErr == nil && applied {
+			return false, nil
+		}
+		return false, err
+	}
+	if afterBatchCommitForTesting != nil {
+		if err := afterBatchCommitForTesting(opts.BatchID); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// appliedBatch returns true if the batch is recorded in the quoted table, and
+// decodes its summary into summary.
+func (conn *Connection) appliedBatch(table string, batchID string, summary any) (bool, error) {
+	statement, err := conn.Prepare(fmt.Sprintf("MATCH (b:%s) WHERE b.id = $id RETURN b.summary;", table))
+	if err != nil {
+		statement.Close()
+		return false, err
+	}
+	defer statement.Close()
+	result, err := conn.Execute(statement, map[string]any{"id": batchID})
+	if err != nil {
+		return false, err
+	}
+	defer result.Close()
+	if !result.HasNext() {
+		return false, nil
+	}
+	tuple, err := result.Next()
+	if err != nil {
+		tuple.Close()
+		return false, err
+	}
+	value, err := tuple.GetValue(0)
+	tuple.Close()
+	if err != nil {
+		return false, err
+	}
+	encoded, _ := value.(string)
+	if summary != nil && encoded != "" {
+		if err := json.Unmarshal([]byte(encoded), summary); err != nil {
+			return true, fmt.Errorf("failed to decode the summary of batch %s: %w", batchID, err)
+		}
+	}
+	return true, nil
+}
+
+// execStatement prepares and executes the query with the arguments and
+// discards its result.
+func execStatement(conn *Connection, query string, args map[string]any) (WriteSummary, error) {
+	statement, err := conn.Prepare(query)
+	defer statement.Close()
+	if err != nil {
+		return WriteSummary{}, err
+	}
+	return statement.Exec(args)
+}
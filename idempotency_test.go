@@ -0,0 +1,194 @@
+package lbug
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func countNodes(t *testing.T, conn *Connection, table string) uint64 {
+	t.Helper()
+	count, err := queryCount(conn, "MATCH (n:"+QuoteIdentifier(table)+") RETURN count(n);")
+	assert.Nil(t, err)
+	return count
+}
+
+func TestApplyOnce(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE item(id INT64, PRIMARY KEY(id));")
+	insert := func() error { return runStatement(conn, "CREATE (:item {id: 1});") }
+
+	applied, err := conn.ApplyOnce(IdempotencyOptions{BatchID: "b1"}, insert)
+	assert.Nil(t, err)
+	assert.True(t, applied)
+	applied, err = conn.ApplyOnce(IdempotencyOptions{BatchID: "b1"}, insert)
+	assert.Nil(t, err)
+	assert.False(t, applied)
+	assert.Equal(t, uint64(1), countNodes(t, conn, "item"))
+	assert.Equal(t, uint64(1), countNodes(t, conn, defaultBatchTable))
+
+	// A failed batch is rolled back with its record and may be submitted
+	// again.
+	applied, err = conn.ApplyOnce(IdempotencyOptions{BatchID: "b2"}, insert)
+	assert.NotNil(t, err)
+	assert.False(t, applied)
+	assert.Equal(t, uint64(1), countNodes(t, conn, defaultBatchTable))
+
+	_, err = conn.ApplyOnce(IdempotencyOptions{}, insert)
+	assert.ErrorContains(t, err, "batch ID must not be empty")
+}
+
+func TestApplyOnceInjectedFailures(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE item(id INT64, PRIMARY KEY(id));")
+	opts := IdempotencyOptions{BatchID: "b1", Table: "ingested"}
+	insert := func() error { return runStatement(conn, "CREATE (:item {id: 1});") }
+	injected := errors.New("injected")
+
+	// A failure before the commit rolls back the data with the record.
+	afterBatchApplyForTesting = func(string) error { return injected }
+	_, err := conn.ApplyOnce(opts, insert)
+	afterBatchApplyForTesting = nil
+	assert.ErrorIs(t, err, injected)
+	assert.Equal(t, uint64(0), countNodes(t, conn, "item"))
+	assert.Equal(t, uint64(0), countNodes(t, conn, "ingested"))
+
+	// A failure after the commit loses the acknowledgment, and the retry
+	// does nothing.
+	afterBatchCommitForTesting = func(string) error { return injected }
+	applied, err := conn.ApplyOnce(opts, insert)
+	afterBatchCommitForTesting = nil
+	assert.ErrorIs(t, err, injected)
+	assert.True(t, applied)
+	applied, err = conn.ApplyOnce(opts, insert)
+	assert.Nil(t, err)
+	assert.False(t, applied)
+	assert.Equal(t, uint64(1), countNodes(t, conn, "item"))
+}
+
+func TestApplyOnceConcurrent(t *testing.T) {
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	setup, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer setup.Close()
+	mustRun(t, setup, "CREATE NODE TABLE item(id SERIAL, PRIMARY KEY(id));")
+
+	const submissions = 4
+	var wg sync.WaitGroup
+	results := make([]bool, submissions)
+	errs := make([]error, submissions)
+	for i := range submissions {
+		conn, err := OpenConnection(db)
+		assert.Nil(t, err)
+		defer conn.Close()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = conn.ApplyOnce(IdempotencyOptions{BatchID: "b1"}, func() error {
+				return runStatement(conn, "CREATE (:item);")
+			})
+		}()
+	}
+	wg.Wait()
+	applied := 0
+	for i := range submissions {
+		assert.Nil(t, errs[i])
+		if results[i] {
+			applied++
+		}
+	}
+	assert.Equal(t, 1, applied)
+	assert.Equal(t, uint64(1), countNodes(t, setup, "item"))
+}
+
+func TestBatchRetention(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	conn.SetClock(ClockFunc(func() time.Time { return now }))
+	noop := func() error { return nil }
+
+	for _, id := range []string{"b1", "b2"} {
+		_, err := conn.ApplyOnce(IdempotencyOptions{BatchID: id}, noop)
+		assert.Nil(t, err)
+		now = now.Add(time.Hour)
+	}
+	// Applying b3 two hours after b1 deletes the record of b1, which is then
+	// applied again.
+	opts := IdempotencyOptions{BatchID: "b3", Retention: 90 * time.Minute}
+	_, err := conn.ApplyOnce(opts, noop)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), countNodes(t, conn, defaultBatchTable))
+	applied, err := conn.ApplyOnce(IdempotencyOptions{BatchID: "b1"}, noop)
+	assert.Nil(t, err)
+	assert.True(t, applied)
+
+	pruned, err := conn.PruneBatches("", now)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), pruned)
+	assert.Equal(t, uint64(1), countNodes(t, conn, defaultBatchTable))
+	pruned, err = conn.PruneBatches("missing", now)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), pruned)
+}
+
+func TestCopyFromIdempotent(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE item(id INT64, PRIMARY KEY(id));")
+	path := filepath.Join(t.TempDir(), "items.csv")
+	assert.Nil(t, os.WriteFile(path, []byte("id\n1\n2\nnot a number\n"), 0o644))
+	opts := CopyOptions{
+		Options:     map[string]any{"HEADER": true, "IGNORE_ERRORS": true},
+		Idempotency: IdempotencyOptions{BatchID: "items-1"},
+	}
+
+	injected := errors.New("injected")
+	afterBatchCommitForTesting = func(string) error { return injected }
+	_, err := CopyFromWithWarnings(conn, "item", path, opts)
+	afterBatchCommitForTesting = nil
+	assert.ErrorIs(t, err, injected)
+
+	// The retry returns the warnings of the first COPY without loading the
+	// file again, which would fail on the duplicate keys.
+	warnings, err := CopyFromWithWarnings(conn, "item", path, opts)
+	assert.Nil(t, err)
+	if assert.Len(t, warnings, 1) {
+		assert.Equal(t, uint64(4), warnings[0].Line)
+	}
+	assert.Nil(t, CopyFrom(conn, "item", path, opts))
+	assert.Equal(t, uint64(2), countNodes(t, conn, "item"))
+}
+
+func TestCreateRelsIdempotent(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE person(id INT64, PRIMARY KEY(id));")
+	mustRun(t, conn, "CREATE REL TABLE knows(FROM person TO person);")
+	mustRun(t, conn, "UNWIND range(0, 2) AS i CREATE (:person {id: i});")
+	pairs := []RelSpec{{FromKey: int64(0), ToKey: int64(1)}, {FromKey: int64(1), ToKey: int64(42)}, {FromKey: int64(1), ToKey: int64(2)}}
+	opts := CreateRelsOptions{Idempotency: IdempotencyOptions{BatchID: "knows-1"}}
+
+	injected := errors.New("injected")
+	afterBatchApplyForTesting = func(string) error { return injected }
+	_, err := conn.CreateRels("knows", pairs, opts)
+	afterBatchApplyForTesting = nil
+	assert.ErrorIs(t, err, injected)
+	assert.Equal(t, uint64(0), countNodes(t, conn, defaultBatchTable))
+
+	first, err := conn.CreateRels("knows", pairs, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), first.Created)
+	assert.Equal(t, []RelSpec{pairs[1]}, first.Unmatched)
+	again, err := conn.CreateRels("knows", pairs, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, first, again)
+
+	rows, err := queryRows(conn, "MATCH ()-[k:knows]->() RETURN count(k) AS n;")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), rows[0]["n"])
+}
@@ -0,0 +1,218 @@
+package lbug
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrUnsupportedIndexType is returned by Connection.CreateIndex for index
+// types that the engine cannot create.
+var ErrUnsupportedIndexType = errors.New("unsupported index type")
+
+// ErrNoSuchIndex is returned by Connection.DropIndex when no index has the
+// name.
+var ErrNoSuchIndex = errors.New("no such index")
+
+// IndexType is the type of an index, as reported by ListIndexes.
+type IndexType string
+
+// The index types that CreateIndex can create. The primary key of a node
+// table is indexed by the engine and does not need an index.
+const (
+	// IndexFTS is a full-text search index over STRING columns, created by
+	// the fts extension.
+	IndexFTS IndexType = "FTS"
+	// IndexVector is an HNSW vector similarity index over a single column of
+	// fixed-size FLOAT or DOUBLE arrays, created by the vector extension.
+	IndexVector IndexType = "HNSW"
+)
+
+// indexProcedures are the extension and the procedures creating and dropping
+// the indexes of each type.
+var indexProcedures = map[IndexType]struct {
+	extension string
+	create    string
+	drop      string
+}{
+	IndexFTS:    {"fts", "CREATE_FTS_INDEX", "DROP_FTS_INDEX"},
+	IndexVector: {"vector", "CREATE_VECTOR_INDEX", "DROP_VECTOR_INDEX"},
+}
+
+// IndexSpec describes an index created by Connection.CreateIndex.
+type IndexSpec struct {
+	Table string
+	// Name is the name of the index. If it is empty, the index is named after
+	// the table and the columns, e.g. book_title_abstract_idx.
+	Name    string
+	Columns []string
+	Type    IndexType
+	// Options are the options of the procedure creating the index, e.g.
+	// "stemmer" for IndexFTS or "metric" for IndexVector. The values must be
+	// bools, integers, floats or strings.
+	Options map[string]any
+	// IfNotExists makes CreateIndex do nothing if the table already has an
+	// index with the name.
+	IfNotExists bool
+}
+
+// DropIndexOptions configures Connection.DropIndex.
+type DropIndexOptions struct {
+	// Table is the table of the index. It only needs to be set if indexes of
+	// several tables have the name.
+	Table string
+	// IfExists makes DropIndex do nothing if there is no index with the name.
+	IfExists bool
+}
+
+// Index is an index of the catalog, as returned by Connection.ListIndexes.
+type Index struct {
+	Table   string
+	Name    string
+	Type    IndexType
+	Columns []string
+	// ExtensionLoaded reports whether the extension that created the index is
+	// loaded, which is needed to use or drop it.
+	ExtensionLoaded bool
+	// Definition is the statement that recreates the index.
+	Definition string
+}
+
+// indexName returns the name of the index of the spec.
+func (spec IndexSpec) indexName() string {
+	if spec.Name != "" {
+		return spec.Name
+	}
+	return strings.Join(append(append([]string{spec.Table}, spec.Columns...), "idx"), "_")
+}
+
+// CreateIndex creates the index described by the spec and returns its name.
+// It returns an error matching ErrUnsupportedIndexType for other types than
+// IndexFTS and IndexVector, and an error matching ErrExtensionNotLoaded if
+// the extension creating the index is not loaded.
+func (conn *Connection) CreateIndex(spec IndexSpec) (string, error) {
+	procedures, ok := indexProcedures[spec.Type]
+	if !ok {
+		return "", fmt.Errorf("%w %q: the engine only creates %s indexes with the fts extension and %s indexes with the vector extension, and indexes the primary keys of node tables itself",
+			ErrUnsupportedIndexType, spec.Type, IndexFTS, IndexVector)
+	}
+	if len(spec.Columns) == 0 {
+		return "", fmt.Errorf("no columns to index in table %s", spec.Table)
+	}
+	if spec.Type == IndexVector && len(spec.Columns) != 1 {
+		return "", fmt.Errorf("a %s index is over a single column, got %d", IndexVector, len(spec.Columns))
+	}
+	for option := range spec.Options {
+		if !isOptionName(option) {
+			return "", fmt.Errorf("invalid index option name %q", option)
+		}
+	}
+	name := spec.indexName()
+	if spec.IfNotExists {
+		indexes, err := conn.ListIndexes()
+		if err != nil {
+			return "", err
+		}
+		for _, index := range indexes {
+			if index.Table == spec.Table && index.Name == name {
+				return name, nil
+			}
+		}
+	}
+
+	args := []string{quoteStringLiteral(spec.Table), quoteStringLiteral(name)}
+	if spec.Type == IndexVector {
+		args = append(args, quoteStringLiteral(spec.Columns[0]))
+	} else {
+		columns := make([]string, len(spec.Columns))
+		for i, column := range spec.Columns {
+			columns[i] = quoteStringLiteral(column)
+		}
+		args = append(args, "["+strings.Join(columns, ", ")+"]")
+	}
+	options := make([]string, 0, len(spec.Options))
+	for option := range spec.Options {
+		options = append(options, option)
+	}
+	sort.Strings(options)
+	for _, option := range options {
+		value, err := optionLiteral(spec.Options[option])
+		if err != nil {
+			return "", fmt.Errorf("%w for index option %s", err, option)
+		}
+		args = append(args, option+" := "+value)
+	}
+	query := fmt.Sprintf("CALL %s(%s);", procedures.create, strings.Join(args, ", "))
+	if err := runStatement(conn, query); err != nil {
+		return "", extensionError(procedures.extension, procedures.create, err)
+	}
+	return name, nil
+}
+
+// DropIndex drops the index with the name. It returns an error matching
+// ErrNoSuchIndex if there is no such index, unless opts.IfExists is set.
+func (conn *Connection) DropIndex(name string, opts DropIndexOptions) error {
+	indexes, err := conn.ListIndexes()
+	if err != nil {
+		return err
+	}
+	var matches []Index
+	for _, index := range indexes {
+		if index.Name == name && (opts.Table == "" || index.Table == opts.Table) {
+			matches = append(matches, index)
+		}
+	}
+	switch {
+	case len(matches) == 0 && opts.IfExists:
+		return nil
+	case len(matches) == 0 && opts.Table != "":
+		return fmt.Errorf("%w: table %s has no index %s", ErrNoSuchIndex, opts.Table, name)
+	case len(matches) == 0:
+		return fmt.Errorf("%w: %s", ErrNoSuchIndex, name)
+	case len(matches) > 1:
+		return fmt.Errorf("indexes of %d tables are named %s: set the table in the options", len(matches), name)
+	}
+	index := matches[0]
+	procedures, ok := indexProcedures[index.Type]
+	if !ok {
+		return fmt.Errorf("%w %q: index %s of table %s cannot be dropped", ErrUnsupportedIndexType, index.Type, name, index.Table)
+	}
+	query := fmt.Sprintf("CALL %s(%s, %s);", procedures.drop, quoteStringLiteral(index.Table), quoteStringLiteral(name))
+	if err := runStatement(conn, query); err != nil {
+		return extensionError(procedures.extension, procedures.drop, err)
+	}
+	return nil
+}
+
+// ListIndexes returns the indexes of the catalog, sorted by table and name.
+func (conn *Connection) ListIndexes() ([]Index, error) {
+	rows, err := queryRows(conn, "CALL SHOW_INDEXES() RETURN *;")
+	if err != nil {
+		return nil, err
+	}
+	indexes := make([]Index, 0, len(rows))
+	for _, row := range rows {
+		index := Index{}
+		index.Table, _ = row["table name"].(string)
+		index.Name, _ = row["index name"].(string)
+		indexType, _ := row["index type"].(string)
+		index.Type = IndexType(indexType)
+		properties, _ := row["property names"].([]any)
+		for _, property := range properties {
+			if column, ok := property.(string); ok {
+				index.Columns = append(index.Columns, column)
+			}
+		}
+		index.ExtensionLoaded, _ = row["extension loaded"].(bool)
+		index.Definition, _ = row["index definition"].(string)
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		if indexes[i].Table != indexes[j].Table {
+			return indexes[i].Table < indexes[j].Table
+		}
+		return indexes[i].Name < indexes[j].Name
+	})
+	return indexes, nil
+}
@@ -0,0 +1,50 @@
+//go:build fts_extension
+
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The tests in this file need the fts extension, which is downloaded by
+// INSTALL. Run them with:
+//
+//	go test -tags fts_extension -run 'Index'
+
+func TestIndexRoundTrip(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE book(id INT64, title STRING, abstract STRING, PRIMARY KEY(id));")
+	mustRun(t, conn, "CREATE (:book {id: 1, title: 'Graphs', abstract: 'On graph databases'});")
+	spec := IndexSpec{Table: "book", Columns: []string{"title", "abstract"}, Type: IndexFTS, Options: map[string]any{"stemmer": "porter"}}
+	_, err := conn.CreateIndex(spec)
+	assert.ErrorIs(t, err, ErrExtensionNotLoaded)
+
+	assert.Nil(t, runStatement(conn, "INSTALL fts;"))
+	assert.Nil(t, runStatement(conn, "LOAD fts;"))
+	name, err := conn.CreateIndex(spec)
+	assert.Nil(t, err)
+	assert.Equal(t, "book_title_abstract_idx", name)
+	_, err = conn.CreateIndex(spec)
+	assert.NotNil(t, err)
+	spec.IfNotExists = true
+	_, err = conn.CreateIndex(spec)
+	assert.Nil(t, err)
+
+	indexes, err := conn.ListIndexes()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(indexes))
+	assert.Equal(t, "book", indexes[0].Table)
+	assert.Equal(t, name, indexes[0].Name)
+	assert.Equal(t, IndexFTS, indexes[0].Type)
+	assert.Equal(t, []string{"title", "abstract"}, indexes[0].Columns)
+	assert.True(t, indexes[0].ExtensionLoaded)
+
+	assert.Nil(t, conn.DropIndex(name, DropIndexOptions{}))
+	indexes, err = conn.ListIndexes()
+	assert.Nil(t, err)
+	assert.Empty(t, indexes)
+	assert.ErrorIs(t, conn.DropIndex(name, DropIndexOptions{Table: "book"}), ErrNoSuchIndex)
+	assert.Nil(t, conn.DropIndex(name, DropIndexOptions{IfExists: true}))
+}
@@ -0,0 +1,61 @@
+package lbug
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIndexName(t *testing.T) {
+	assert.Equal(t, "book_title_abstract_idx", IndexSpec{Table: "book", Columns: []string{"title", "abstract"}}.indexName())
+	assert.Equal(t, "titles", IndexSpec{Table: "book", Name: "titles", Columns: []string{"title"}}.indexName())
+}
+
+func TestCreateIndexValidation(t *testing.T) {
+	conn := &Connection{}
+	_, err := conn.CreateIndex(IndexSpec{Table: "book", Columns: []string{"title"}, Type: "BTREE"})
+	assert.ErrorIs(t, err, ErrUnsupportedIndexType)
+	assert.ErrorContains(t, err, "fts extension")
+	_, err = conn.CreateIndex(IndexSpec{Table: "book", Type: IndexFTS})
+	assert.ErrorContains(t, err, "no columns to index")
+	_, err = conn.CreateIndex(IndexSpec{Table: "book", Columns: []string{"a", "b"}, Type: IndexVector})
+	assert.ErrorContains(t, err, "single column")
+	_, err = conn.CreateIndex(IndexSpec{Table: "book", Columns: []string{"title"}, Type: IndexFTS,
+		Options: map[string]any{"stemmer := 'porter'); MATCH (n) DELETE n; //": "none"}})
+	assert.ErrorContains(t, err, "invalid index option name")
+}
+
+func TestOptionLiteral(t *testing.T) {
+	for value, expected := range map[any]string{
+		true:         "true",
+		int64(3):     "3",
+		0.5:          "0.5",
+		"porter":     "'porter'",
+		"it's":       `'it\'s'`,
+		uint32(7):    "7",
+		0.1:          "0.1",
+		1e21:         "1e21",
+		-2.5e-8:      "-2.5e-08",
+		float32(0.1): "0.1",
+	} {
+		literal, err := optionLiteral(value)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, literal)
+	}
+	_, err := optionLiteral([]string{"a"})
+	assert.ErrorContains(t, err, "unsupported value of type []string")
+	for _, value := range []any{math.NaN(), math.Inf(1), math.Inf(-1), float32(math.Inf(1))} {
+		_, err := optionLiteral(value)
+		assert.ErrorContains(t, err, "non-finite", value)
+	}
+}
+
+func TestListIndexesEmpty(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	indexes, err := conn.ListIndexes()
+	assert.Nil(t, err)
+	assert.Empty(t, indexes)
+	assert.ErrorIs(t, conn.DropIndex("missing", DropIndexOptions{}), ErrNoSuchIndex)
+	assert.Nil(t, conn.DropIndex("missing", DropIndexOptions{IfExists: true}))
+}
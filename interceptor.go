@@ -0,0 +1,237 @@
+package lbug
+
+import (
+	"os"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// call is a Query, Prepare, Execute or Exec call on a connection. The steps
+// that apply to every call, such as the crash report trace, the recorder and
+// the usage counters, are the interceptors of the call, run in order around
+// the call itself, rather than steps of each operation.
+type call struct {
+	conn *Connection
+	// op is the operation of the call, traceQuery, tracePrepare,
+	// traceExecute or traceExec.
+	op    uint32
+	query string
+	// statement is the statement executed by Execute and Exec.
+	statement *PreparedStatement
+	args      map[string]any
+	// sensitive are the names of the parameters marked sensitive for the
+	// call, in addition to those of the connection.
+	sensitive []string
+	// interceptor is the index of the next interceptor to run.
+	interceptor int
+}
+
+// outcome is what a call returns: the result of Query and Execute, the
+// statement of Prepare or the summary of Exec.
+type outcome struct {
+	result    *QueryResult
+	statement *PreparedStatement
+	summary   WriteSummary
+}
+
+// interceptorState is the state of the interceptors kept by a connection.
+type interceptorState struct {
+	policy          Policy
+	policyVersion   uint64
+	sensitiveParams map[string]bool
+	recorder        atomic.Pointer[recorder]
+	trace           operationTrace
+	// profiler is nil unless profiling is enabled by ConnectionOptions.
+	profiler *cgoProfiler
+	// exploratory is the ExploratoryMode set by ConnectionOptions.
+	exploratory ExploratoryMode
+}
+
+// interceptor is a step run around the calls on connections. It continues the
+// call with next, and may change the call before and the outcome after.
+type interceptor func(call *call) (outcome, error)
+
+// interceptors are the interceptors of the calls, from the outermost. They
+// are set by init, since they refer to the chain through next.
+var interceptors []interceptor
+
+func init() {
+	interceptors = []interceptor{
+		traceCall,
+		recordCall,
+		countCall,
+		checkPolicy,
+		rewriteQuery,
+		profileCall,
+	}
+}
+
+// intercept runs the call through the interceptors.
+func (conn *Connection) intercept(call *call) (outcome, error) {
+	call.conn = conn
+	return call.next()
+}
+
+// next runs the next interceptor of the call, or the call itself once all
+// have run.
+func (call *call) next() (outcome, error) {
+	if call.interceptor < len(interceptors) {
+		interceptor := interceptors[call.interceptor]
+		call.interceptor++
+		return interceptor(call)
+	}
+	conn := call.conn
+	switch call.op {
+	case traceQuery:
+		result, err := conn.query(call.query)
+		return outcome{result: result}, err
+	case tracePrepare:
+		statement, err := conn.prepare(call.query)
+		return outcome{statement: statement}, err
+	case traceExecute:
+		result, err := conn.execute(call.statement, call.args)
+		return outcome{result: result}, err
+	default:
+		summary, err := call.statement.exec(call.args)
+		return outcome{summary: summary}, err
+	}
+}
+
+// errorOp returns the Op of the errors of the call.
+func (call *call) errorOp() string {
+	if call.op == tracePrepare {
+		return OpPrepare
+	}
+	return OpExecute
+}
+
+// traceCall adds the call to the trace of the connection and turns the faults
+// in Go code into panics reported by the crash reporter, while crash reports
+// are enabled.
+func traceCall(call *call) (outcome, error) {
+	reporter := crashReports.Load()
+	if reporter == nil {
+		return call.next()
+	}
+	var handleID uint64
+	if call.statement != nil {
+		handleID = call.statement.handleID
+	}
+	call.conn.trace.add(call.op, call.query, handleID)
+	defer reporter.recoverFault(debug.SetPanicOnFault(true))
+	return call.next()
+}
+
+// recordCall redacts the sensitive parameters of the call from its error and
+// records the call while recording is enabled.
+func recordCall(call *call) (outcome, error) {
+	conn := call.conn
+	recorder := conn.recorder.Load()
+	redacts := call.statement != nil && (len(conn.sensitiveParams) > 0 || len(call.sensitive) > 0)
+	if recorder == nil && !redacts {
+		return call.next()
+	}
+	var params map[string]any
+	var redaction *redaction
+	if call.statement != nil {
+		params = conn.effectiveParams(call.statement, call.args)
+		redaction = conn.redaction(params, call.sensitive)
+	}
+	start := time.Now()
+	out, err := call.next()
+	err = redaction.error(err)
+	if recorder == nil {
+		return out, err
+	}
+	op := traceOperationNames[call.op]
+	switch call.op {
+	case traceQuery, traceExecute:
+		recorder.recordResult(op, call.query, redaction.args(params), start, out.result, err)
+	default:
+		recorder.record(op, call.query, redaction.args(params), start, out.summary.NumTuples, 0, err)
+	}
+	return out, err
+}
+
+// countCall counts the call and its failure in the usage counters of the
+// database.
+func countCall(call *call) (outcome, error) {
+	stats := call.conn.stats()
+	calls, failures := &stats.queriesExecuted, &stats.queryErrors
+	if call.op == tracePrepare {
+		calls, failures = &stats.statementsPrepared, &stats.prepareErrors
+	}
+	calls.Add(1)
+	out, err := call.next()
+	if err != nil {
+		failures.Add(1)
+	}
+	return out, err
+}
+
+// checkPolicy fails the call if the statement policy of the connection denies
+// its query. A prepared statement is checked again only if the policy was set
+// since it was last checked.
+func checkPolicy(call *call) (outcome, error) {
+	conn := call.conn
+	stmt := call.statement
+	if stmt != nil && stmt.policyVersion == conn.policyVersion {
+		return call.next()
+	}
+	if err := conn.checkStatementPolicy(call.query); err != nil {
+		return outcome{}, &Error{Op: call.errorOp(), Query: call.query, Err: err}
+	}
+	if stmt != nil {
+		stmt.policyVersion = conn.policyVersion
+	}
+	return call.next()
+}
+
+// rewriteQuery appends the default LIMIT of the exploratory mode to the query
+// of Query and rewrites its row sources to the files they are spooled to. The
+// row sources are read before the connection is locked, so that they may use
+// it.
+func rewriteQuery(call *call) (outcome, error) {
+	if call.op != traceQuery {
+		return call.next()
+	}
+	conn := call.conn
+	query, injectedLimit := conn.applyExploratoryMode(call.query)
+	query, files, err := conn.spoolRowSources(query)
+	defer func() {
+		for _, file := range files {
+			os.Remove(file)
+		}
+	}()
+	if err != nil {
+		return outcome{}, err
+	}
+	call.query = query
+	out, err := call.next()
+	if out.result != nil {
+		out.result.injectedLimit = injectedLimit
+	}
+	return out, err
+}
+
+// profileCall runs Query and Execute with the profiling labels of the query
+// and samples their wall time, if profiling is enabled on the connection.
+func profileCall(call *call) (outcome, error) {
+	profiler := call.conn.profiler
+	if profiler == nil || (call.op != traceQuery && call.op != traceExecute) {
+		return call.next()
+	}
+	operation := profileExecute
+	if call.op == traceQuery {
+		operation = queryProfileOperation(call.query)
+	}
+	hash := queryHash(call.query)
+	var out outcome
+	var err error
+	profiler.run(operation, hash, func() { out, err = call.next() })
+	if out.result != nil {
+		out.result.profileHash = hash
+	}
+	return out, err
+}
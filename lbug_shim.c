@@ -0,0 +1,157 @@
+// lbug_shim.c implements the helpers declared in lbug_shim.h.
+
+#include <stdlib.h>
+#include <string.h>
+
+#include "lbug_shim.h"
+
+// lbug_go_shim_alloc allocates a zeroed buffer of the kind with size bytes of
+// fixed fields followed by extra bytes, aligned for lbug_value.
+static void *lbug_go_shim_alloc(lbug_go_shim_kind kind, size_t size, size_t extra) {
+	size = (size + sizeof(lbug_value) - 1) / sizeof(lbug_value) * sizeof(lbug_value);
+	lbug_go_shim_header *header = calloc(1, size + extra);
+	if (header == NULL) {
+		return NULL;
+	}
+	header->version = LBUG_GO_SHIM_VERSION;
+	header->kind = kind;
+	return header;
+}
+
+// lbug_go_shim_data returns the extra bytes of a buffer allocated with
+// lbug_go_shim_alloc with size bytes of fixed fields.
+static void *lbug_go_shim_data(void *buffer, size_t size) {
+	size = (size + sizeof(lbug_value) - 1) / sizeof(lbug_value) * sizeof(lbug_value);
+	return (char *)buffer + size;
+}
+
+lbug_state lbug_go_fetch_row_values(lbug_flat_tuple *tuple, uint64_t num_columns,
+		lbug_go_row_values **out) {
+	*out = NULL;
+	lbug_go_row_values *row = lbug_go_shim_alloc(LBUG_GO_SHIM_ROW_VALUES, sizeof(*row),
+			num_columns * (sizeof(lbug_value) + 1));
+	if (row == NULL) {
+		return LbugError;
+	}
+	row->values = lbug_go_shim_data(row, sizeof(*row));
+	row->nulls = (uint8_t *)(row->values + num_columns);
+	for (uint64_t i = 0; i < num_columns; i++) {
+		if (lbug_flat_tuple_get_value(tuple, i, &row->values[i]) != LbugSuccess) {
+			lbug_go_shim_free(row);
+			return LbugError;
+		}
+		row->num_values++;
+		row->nulls[i] = lbug_value_is_null(&row->values[i]);
+	}
+	*out = row;
+	return LbugSuccess;
+}
+
+lbug_state lbug_go_fetch_null_mask(lbug_flat_tuple *tuple, uint64_t num_columns,
+		lbug_go_null_mask **out) {
+	*out = NULL;
+	uint64_t num_words = (num_columns + 63) / 64;
+	lbug_go_null_mask *mask = lbug_go_shim_alloc(LBUG_GO_SHIM_NULL_MASK, sizeof(*mask),
+			num_words * sizeof(uint64_t));
+	if (mask == NULL) {
+		return LbugError;
+	}
+	mask->num_columns = num_columns;
+	mask->words = lbug_go_shim_data(mask, sizeof(*mask));
+	for (uint64_t i = 0; i < num_columns; i++) {
+		lbug_value value;
+		if (lbug_flat_tuple_get_value(tuple, i, &value) != LbugSuccess) {
+			lbug_go_shim_free(mask);
+			return LbugError;
+		}
+		if (lbug_value_is_null(&value)) {
+			mask->words[i / 64] |= (uint64_t)1 << (i % 64);
+		}
+		lbug_value_destroy(&value);
+	}
+	*out = mask;
+	return LbugSuccess;
+}
+
+lbug_state lbug_go_fetch_path_elements(lbug_value *path, uint64_t max_elements,
+		lbug_go_path_elements **out) {
+	*out = NULL;
+	lbug_value lists[2];
+	if (lbug_value_get_recursive_rel_node_list(path, &lists[0]) != LbugSuccess) {
+		return LbugError;
+	}
+	if (lbug_value_get_recursive_rel_rel_list(path, &lists[1]) != LbugSuccess) {
+		lbug_value_destroy(&lists[0]);
+		return LbugError;
+	}
+	uint64_t num_nodes = 0, num_rels = 0;
+	lbug_value_get_list_size(&lists[0], &num_nodes);
+	lbug_value_get_list_size(&lists[1], &num_rels);
+	bool truncated = max_elements > 0 && num_nodes + num_rels > max_elements;
+	size_t extra = truncated ? 0 : (num_nodes + num_rels) * sizeof(lbug_value);
+	lbug_go_path_elements *elements = lbug_go_shim_alloc(LBUG_GO_SHIM_PATH_ELEMENTS,
+			sizeof(*elements), extra);
+	if (elements == NULL) {
+		lbug_value_destroy(&lists[0]);
+		lbug_value_destroy(&lists[1]);
+		return LbugError;
+	}
+	memcpy(elements->lists, lists, sizeof(lists));
+	if (truncated) {
+		elements->num_nodes = num_nodes;
+		elements->num_rels = num_rels;
+		*out = elements;
+		return LbugSuccess;
+	}
+	elements->nodes = lbug_go_shim_data(elements, sizeof(*elements));
+	elements->rels = elements->nodes + num_nodes;
+	// The numbers are advanced as the elements are gotten, so that
+	// lbug_go_shim_free destroys only the elements gotten on failure.
+	for (uint64_t i = 0; i < num_nodes; i++, elements->num_nodes++) {
+		if (lbug_value_get_list_element(&elements->lists[0], i, &elements->nodes[i]) != LbugSuccess) {
+			lbug_go_shim_free(elements);
+			return LbugError;
+		}
+	}
+	for (uint64_t i = 0; i < num_rels; i++, elements->num_rels++) {
+		if (lbug_value_get_list_element(&elements->lists[1], i, &elements->rels[i]) != LbugSuccess) {
+			lbug_go_shim_free(elements);
+			return LbugError;
+		}
+	}
+	*out = elements;
+	return LbugSuccess;
+}
+
+void lbug_go_shim_free(void *buffer) {
+	if (buffer == NULL) {
+		return;
+	}
+	lbug_go_shim_header *header = buffer;
+	switch (header->kind) {
+	case LBUG_GO_SHIM_ROW_VALUES: {
+		lbug_go_row_values *row = buffer;
+		for (uint64_t i = 0; i < row->num_values; i++) {
+			lbug_value_destroy(&row->values[i]);
+		}
+		break;
+	}
+	case LBUG_GO_SHIM_PATH_ELEMENTS: {
+		lbug_go_path_elements *elements = buffer;
+		if (elements->nodes != NULL) {
+			for (uint64_t i = 0; i < elements->num_nodes; i++) {
+				lbug_value_destroy(&elements->nodes[i]);
+			}
+			for (uint64_t i = 0; i < elements->num_rels; i++) {
+				lbug_value_destroy(&elements->rels[i]);
+			}
+		}
+		lbug_value_destroy(&elements->lists[0]);
+		lbug_value_destroy(&elements->lists[1]);
+		break;
+	}
+	default:
+		break;
+	}
+	free(buffer);
+}
@@ -0,0 +1,90 @@
+// lbug_shim.h declares the coarse-grained C helpers of the Go bindings. Each
+// helper makes many calls to the C API on the C side and returns its results
+// packed in a single buffer, so that Go makes one cgo transition instead of
+// one per value.
+//
+// Every packed buffer starts with a lbug_go_shim_header holding
+// LBUG_GO_SHIM_VERSION and the kind of the buffer. The layout of a kind only
+// changes with the version, and the Go side rejects buffers of another
+// version. A buffer is a single allocation owned by the caller, which must
+// release it with lbug_go_shim_free, and only with it: the values it holds are
+// destroyed with it. The values are borrowed from the tuple or the value the
+// buffer was fetched from, which must outlive the buffer.
+
+#ifndef LBUG_GO_SHIM_H
+#define LBUG_GO_SHIM_H
+
+#include <stdbool.h>
+#include <stdint.h>
+
+#include "lbug.h"
+
+// LBUG_GO_SHIM_VERSION is the version of the layout of the packed buffers.
+#define LBUG_GO_SHIM_VERSION 1
+
+// The kinds of the packed buffers.
+typedef enum {
+	LBUG_GO_SHIM_ROW_VALUES = 1,
+	LBUG_GO_SHIM_NULL_MASK = 2,
+	LBUG_GO_SHIM_PATH_ELEMENTS = 3,
+} lbug_go_shim_kind;
+
+typedef struct {
+	uint32_t version;
+	uint32_t kind;
+} lbug_go_shim_header;
+
+// lbug_go_row_values holds the values of the columns of a tuple, and whether
+// they are NULL.
+typedef struct {
+	lbug_go_shim_header header;
+	uint64_t num_values;
+	lbug_value *values;
+	// nulls holds a byte per value, 1 if the value is NULL.
+	uint8_t *nulls;
+} lbug_go_row_values;
+
+// lbug_go_null_mask holds whether the values of the columns of a tuple are
+// NULL, a bit per column: column i is NULL if bit i % 64 of words[i / 64] is
+// set.
+typedef struct {
+	lbug_go_shim_header header;
+	uint64_t num_columns;
+	uint64_t *words;
+} lbug_go_null_mask;
+
+// lbug_go_path_elements holds the nodes and relationships of a RECURSIVE_REL
+// value. If the path has more elements than the limit passed to
+// lbug_go_fetch_path_elements, only the numbers of elements are set and nodes
+// and rels are NULL.
+typedef struct {
+	lbug_go_shim_header header;
+	uint64_t num_nodes;
+	uint64_t num_rels;
+	lbug_value *nodes;
+	lbug_value *rels;
+	// lists are the node and relationship lists the elements are borrowed
+	// from.
+	lbug_value lists[2];
+} lbug_go_path_elements;
+
+// lbug_go_fetch_row_values fetches the num_columns values of the tuple.
+lbug_state lbug_go_fetch_row_values(lbug_flat_tuple *tuple, uint64_t num_columns,
+		lbug_go_row_values **out);
+
+// lbug_go_fetch_null_mask fetches whether the num_columns values of the tuple
+// are NULL.
+lbug_state lbug_go_fetch_null_mask(lbug_flat_tuple *tuple, uint64_t num_columns,
+		lbug_go_null_mask **out);
+
+// lbug_go_fetch_path_elements fetches the nodes and relationships of a
+// RECURSIVE_REL value, unless they are more than max_elements. Zero means no
+// limit.
+lbug_state lbug_go_fetch_path_elements(lbug_value *path, uint64_t max_elements,
+		lbug_go_path_elements **out);
+
+// lbug_go_shim_free destroys the values of a packed buffer and frees it. It
+// does nothing for NULL.
+void lbug_go_shim_free(void *buffer);
+
+#endif
@@ -0,0 +1,123 @@
+// Package lbuggen is the runtime support of the code generated by
+// cmd/lbuggen from annotated Cypher files. The generated functions run their
+// queries through a Conn, which prepares every query once and caches the
+// prepared statement, and decode the rows into the generated structs with
+// ScanStruct.
+//
+// The package can also be used directly, without generated code:
+//
+//	conn := lbuggen.New(lbugConn)
+//	defer conn.Close()
+//	user, err := lbuggen.One[User](ctx, conn, "MATCH (u:User) WHERE u.id = $id RETURN u.id AS id, u.name AS name;", map[string]any{"id": id})
+package lbuggen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	lbug "github.com/LadybugDB/go-ladybug"
+)
+
+// ErrNoRows is returned by One when the query returns no row.
+var ErrNoRows = errors.New("lbuggen: no rows in result")
+
+// Conn runs the queries of the generated code on a connection, caching their
+// prepared statements. It is safe for concurrent use; the queries run one at a
+// time, as on the connection. The connection remains owned by the caller, and
+// must not be closed before the Conn.
+type Conn struct {
+	conn       *lbug.Connection
+	mutex      sync.Mutex
+	statements map[string]*lbug.PreparedStatement
+}
+
+// New returns a Conn running queries on the connection.
+func New(conn *lbug.Connection) *Conn {
+	return &Conn{conn: conn, statements: make(map[string]*lbug.PreparedStatement)}
+}
+
+// Connection returns the connection of the Conn.
+func (conn *Conn) Connection() *lbug.Connection {
+	return conn.conn
+}
+
+// Close closes the cached prepared statements. The connection is not closed.
+func (conn *Conn) Close() {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	for query, statement := range conn.statements {
+		statement.Close()
+		delete(conn.statements, query)
+	}
+}
+
+// Query runs the query with the parameters, preparing it on first use. A
+// statement that fails to execute is evicted from the cache, so that it is
+// prepared again, e.g. against a changed schema, by the next call.
+func (conn *Conn) Query(ctx context.Context, query string, args map[string]any) (*lbug.QueryResult, error) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	statement, ok := conn.statements[query]
+	if !ok {
+		var err error
+		statement, err = conn.conn.PrepareWithContext(ctx, query)
+		if err != nil {
+			statement.Close()
+			return nil, err
+		}
+		conn.statements[query] = statement
+	}
+	result, err := conn.conn.ExecuteWithContext(ctx, statement, args)
+	if err != nil {
+		statement.Close()
+		delete(conn.statements, query)
+		return nil, err
+	}
+	return result, nil
+}
+
+// ScanStruct decodes the remaining rows of the result into values of the
+// struct type T, see lbug.Collect, and closes the result.
+func ScanStruct[T any](result *lbug.QueryResult) ([]T, error) {
+	defer result.Close()
+	return lbug.Collect[T](result)
+}
+
+// One runs the query and returns its first row decoded into a T. It returns
+// ErrNoRows if the query returns no row, and an error if it returns more
+// than one.
+func One[T any](ctx context.Context, conn *Conn, query string, args map[string]any) (T, error) {
+	var row T
+	rows, err := All[T](ctx, conn, query, args)
+	if err != nil {
+		return row, err
+	}
+	switch len(rows) {
+	case 0:
+		return row, ErrNoRows
+	case 1:
+		return rows[0], nil
+	}
+	return row, fmt.Errorf("lbuggen: query returned %d rows, expected one", len(rows))
+}
+
+// All runs the query and returns its rows decoded into values of T.
+func All[T any](ctx context.Context, conn *Conn, query string, args map[string]any) ([]T, error) {
+	result, err := conn.Query(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	return ScanStruct[T](result)
+}
+
+// Exec runs the query and discards its rows.
+func Exec(ctx context.Context, conn *Conn, query string, args map[string]any) error {
+	result, err := conn.Query(ctx, query, args)
+	if err != nil {
+		return err
+	}
+	result.Close()
+	return nil
+}
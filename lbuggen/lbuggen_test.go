@@ -0,0 +1,73 @@
+package lbuggen
+
+import (
+	"context"
+	"testing"
+
+	lbug "github.com/LadybugDB/go-ladybug"
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	ID   int64   `lbug:"id"`
+	Name string  `lbug:"name"`
+	Age  *int64  `lbug:"age"`
+	Tags []any   `lbug:"tags"`
+	Skip float64 `lbug:"-"`
+}
+
+func openConn(t *testing.T) *Conn {
+	t.Helper()
+	db, err := lbug.OpenInMemoryDatabase(lbug.DefaultSystemConfig())
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	t.Cleanup(db.Close)
+	conn, err := lbug.OpenConnection(db)
+	if err != nil {
+		t.Fatalf("Error opening connection: %v", err)
+	}
+	t.Cleanup(conn.Close)
+	genConn := New(conn)
+	t.Cleanup(genConn.Close)
+	ctx := context.Background()
+	assert.Nil(t, Exec(ctx, genConn, "CREATE NODE TABLE User(id INT64, name STRING, age INT64, tags STRING[], PRIMARY KEY(id));", nil))
+	return genConn
+}
+
+func TestQueries(t *testing.T) {
+	conn := openConn(t)
+	ctx := context.Background()
+	const create = "CREATE (:User {id: $id, name: $name, age: $age, tags: ['a']});"
+	assert.Nil(t, Exec(ctx, conn, create, map[string]any{"id": int64(1), "name": "Alice", "age": int64(30)}))
+	assert.Nil(t, Exec(ctx, conn, create, map[string]any{"id": int64(2), "name": "Bob", "age": nil}))
+	// The statement is prepared once.
+	assert.Len(t, conn.statements, 2)
+
+	const get = "MATCH (u:User) WHERE u.id = $id RETURN u.id AS id, u.name AS name, u.age AS age, u.tags AS tags;"
+	alice, err := One[user](ctx, conn, get, map[string]any{"id": int64(1)})
+	assert.Nil(t, err)
+	assert.Equal(t, "Alice", alice.Name)
+	if assert.NotNil(t, alice.Age) {
+		assert.Equal(t, int64(30), *alice.Age)
+	}
+	assert.Equal(t, []any{"a"}, alice.Tags)
+	bob, err := One[user](ctx, conn, get, map[string]any{"id": int64(2)})
+	assert.Nil(t, err)
+	assert.Nil(t, bob.Age)
+	_, err = One[user](ctx, conn, get, map[string]any{"id": int64(3)})
+	assert.ErrorIs(t, err, ErrNoRows)
+
+	users, err := All[user](ctx, conn, "MATCH (u:User) RETURN u.id AS id, u.name AS name ORDER BY u.id;", nil)
+	assert.Nil(t, err)
+	assert.Len(t, users, 2)
+	_, err = One[user](ctx, conn, "MATCH (u:User) RETURN u.id AS id;", nil)
+	assert.ErrorContains(t, err, "returned 2 rows")
+
+	// A duplicate primary key fails the statement, which is evicted.
+	before := len(conn.statements)
+	assert.NotNil(t, Exec(ctx, conn, create, map[string]any{"id": int64(1), "name": "Alice", "age": int64(30)}))
+	assert.Len(t, conn.statements, before-1)
+	conn.Close()
+	assert.Len(t, conn.statements, 0)
+}
@@ -0,0 +1,103 @@
+package lbugproto_test
+
+import (
+	"context"
+	"fmt"
+
+	lbug "github.com/LadybugDB/go-ladybug"
+	"github.com/LadybugDB/go-ladybug/lbugproto"
+	"github.com/LadybugDB/go-ladybug/lbugproto/internal/testpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// personService implements the PersonService of person.proto, as a gRPC
+// server would, by decoding the rows of the person table into the messages.
+type personService struct {
+	conn *lbug.Connection
+}
+
+func (service *personService) GetPerson(ctx context.Context, request *testpb.GetPersonRequest) (*testpb.Person, error) {
+	statement, err := service.conn.Prepare("MATCH (p:person) WHERE p.id = $id RETURN p.id, p.full_name, p.age, p.scores;")
+	if err != nil {
+		return nil, err
+	}
+	defer statement.Close()
+	result, err := service.conn.Execute(statement, map[string]any{"id": request.GetId()})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	if !result.HasNext() {
+		return nil, fmt.Errorf("no person with id %d", request.GetId())
+	}
+	tuple, err := result.Next()
+	if err != nil {
+		return nil, err
+	}
+	defer tuple.Close()
+	person := &testpb.Person{}
+	if err := lbugproto.UnmarshalTuple(tuple, person); err != nil {
+		return nil, err
+	}
+	return person, nil
+}
+
+func (service *personService) ListPeople(ctx context.Context, request *testpb.ListPeopleRequest) (*testpb.ListPeopleResponse, error) {
+	statement, err := service.conn.Prepare("MATCH (p:person) WHERE p.age >= $min_age RETURN p ORDER BY p.id;")
+	if err != nil {
+		return nil, err
+	}
+	defer statement.Close()
+	result, err := service.conn.Execute(statement, map[string]any{"min_age": request.GetMinAge()})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	// RETURN p sets the fields from the properties of the nodes.
+	people, err := lbugproto.UnmarshalAll[*testpb.Person](result, lbugproto.UnmarshalOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &testpb.ListPeopleResponse{People: people}, nil
+}
+
+func ExampleUnmarshalAll() {
+	db, err := lbug.OpenInMemoryDatabase(lbug.DefaultSystemConfig())
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+	conn, err := lbug.OpenConnection(db)
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+	for _, query := range []string{
+		"CREATE NODE TABLE person(id INT64, full_name STRING, age UINT32, scores INT64[], PRIMARY KEY(id));",
+		"CREATE (:person {id: 1, full_name: 'Alice', age: 35, scores: [96, 54]});",
+		"CREATE (:person {id: 2, full_name: 'Bob', age: 30, scores: [98, 42, 93]});",
+	} {
+		result, err := conn.Query(query)
+		if err != nil {
+			panic(err)
+		}
+		result.Close()
+	}
+
+	service := &personService{conn: conn}
+	person, err := service.GetPerson(context.Background(), &testpb.GetPersonRequest{Id: proto.Int64(2)})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(person.GetFullName(), person.GetAge(), person.GetScores())
+	response, err := service.ListPeople(context.Background(), &testpb.ListPeopleRequest{MinAge: proto.Uint32(32)})
+	if err != nil {
+		panic(err)
+	}
+	for _, person := range response.GetPeople() {
+		fmt.Println(person.GetId(), person.GetFullName())
+	}
+	// Output:
+	// Bob 30 [98 42 93]
+	// 1 Alice
+}
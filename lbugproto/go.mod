@@ -0,0 +1,21 @@
+module github.com/LadybugDB/go-ladybug/lbugproto
+
+go 1.25
+
+// The module builds against the bindings of the same checkout until it is
+// released with a tagged version of them.
+replace github.com/LadybugDB/go-ladybug => ../
+
+require (
+	github.com/LadybugDB/go-ladybug v0.0.0
+	github.com/google/uuid v1.6.0
+	github.com/shopspring/decimal v1.4.0
+	github.com/stretchr/testify v1.9.0
+	google.golang.org/protobuf v1.36.12
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
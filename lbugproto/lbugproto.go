@@ -0,0 +1,716 @@
+// Package lbugproto decodes the rows of Lbug query results into protobuf
+// messages, e.g. to return them from gRPC services without copying them field
+// by field:
+//
+//	result, err := conn.Query("MATCH (p:person) RETURN p.id, p.full_name, p.scores;")
+//	if err != nil {
+//		return nil, err
+//	}
+//	defer result.Close()
+//	people, err := lbugproto.UnmarshalAll[*pb.Person](result, lbugproto.UnmarshalOptions{})
+//
+// The columns set the fields of the message with the same name, ignoring the
+// case and underscores, so that a column full_name or fullName sets the field
+// full_name. A column named after a property of a variable, e.g. p.full_name,
+// sets the field named after the property if no field is named after the
+// whole column. A row with a single column holding a node or a relationship
+// that is not named after a field, e.g. the result of RETURN p, sets the
+// fields from the properties of the node or relationship.
+//
+// The values are converted to the kind of the field, and an error is returned
+// if they cannot be converted without loss:
+//
+//   - integers and floats set the numeric fields whose range holds them, and
+//     DECIMAL values the float fields;
+//   - strings set the string and bytes fields, and the enum fields with the
+//     name of an enum value; integers also set the enum fields;
+//   - UUID, DECIMAL and INT128 values set the string fields with their string
+//     representation, and BLOB values the bytes fields;
+//   - TIMESTAMP and DATE values set the google.protobuf.Timestamp fields, and
+//     INTERVAL values without months the google.protobuf.Duration fields;
+//   - STRUCT values, nodes and relationships set the google.protobuf.Struct
+//     fields and the fields of other message types, whose fields are set from
+//     the keys of the struct or the properties as for the columns of a row;
+//   - scalar values set the fields of the wrapper types, e.g.
+//     google.protobuf.StringValue, and any value the google.protobuf.Value
+//     fields;
+//   - LIST and ARRAY values set the repeated fields, element by element, and
+//     STRUCT and MAP values the map fields.
+//
+// NULL values leave the field unset. After a row is decoded, the required
+// fields of the message and of its nested messages that are not set are
+// reported with a RequiredFieldsError.
+//
+// The package is a separate module, so that programs using the lbug package
+// without protobuf do not depend on it.
+package lbugproto
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	lbug "github.com/LadybugDB/go-ladybug"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ErrUnknownColumn is returned when a column of a row, or a key of a STRUCT
+// value or a property decoded into a message, does not map to a field of the
+// message, unless UnmarshalOptions.DiscardUnknown is set.
+var ErrUnknownColumn = errors.New("column does not map to a field")
+
+// ErrRequiredFieldNotSet is matched with errors.Is by RequiredFieldsError.
+var ErrRequiredFieldNotSet = errors.New("required field not set")
+
+// RequiredFieldsError is returned when required fields are not set after a
+// row is decoded, because no column maps to them or because the columns are
+// NULL. It matches ErrRequiredFieldNotSet with errors.Is.
+type RequiredFieldsError struct {
+	// Message is the full name of the message type the row is decoded into.
+	Message protoreflect.FullName
+	// Fields are the paths of the required fields that are not set, e.g.
+	// full_name or address.city for the field of a nested message.
+	Fields []string
+}
+
+func (err *RequiredFieldsError) Error() string {
+	return fmt.Sprintf("required fields of %s not set: %s", err.Message, strings.Join(err.Fields, ", "))
+}
+
+func (err *RequiredFieldsError) Unwrap() error {
+	return ErrRequiredFieldNotSet
+}
+
+// UnmarshalOptions configures the decoding of rows into messages.
+type UnmarshalOptions struct {
+	// DiscardUnknown ignores the columns, keys of STRUCT values and
+	// properties that do not map to a field instead of returning an error
+	// matching ErrUnknownColumn.
+	DiscardUnknown bool
+	// AllowPartial does not report the required fields that are not set.
+	AllowPartial bool
+}
+
+// Unmarshal decodes a row, as returned by lbug.FlatTuple.GetAsMap, into msg
+// with the default options.
+func Unmarshal(row map[string]any, msg proto.Message) error {
+	return UnmarshalOptions{}.Unmarshal(row, msg)
+}
+
+// UnmarshalTuple decodes the row of the tuple into msg with the default
+// options.
+func UnmarshalTuple(tuple *lbug.FlatTuple, msg proto.Message) error {
+	return UnmarshalOptions{}.UnmarshalTuple(tuple, msg)
+}
+
+// Unmarshal decodes a row, as returned by lbug.FlatTuple.GetAsMap, into msg.
+// The fields of msg that no column maps to are left unchanged, so msg is
+// usually a new message.
+func (opts UnmarshalOptions) Unmarshal(row map[string]any, msg proto.Message) error {
+	message := msg.ProtoReflect()
+	if len(row) == 1 {
+		for column, value := range row {
+			if _, ok := lookupField(message.Descriptor(), column); !ok {
+				if properties, ok := propertiesOf(value); ok {
+					row = properties
+				}
+			}
+		}
+	}
+	if err := opts.decodeFields(message, row, ""); err != nil {
+		return err
+	}
+	if opts.AllowPartial {
+		return nil
+	}
+	var missing []string
+	missingRequired(message, "", &missing)
+	if len(missing) > 0 {
+		return &RequiredFieldsError{Message: message.Descriptor().FullName(), Fields: missing}
+	}
+	return nil
+}
+
+// UnmarshalTuple decodes the row of the tuple into msg.
+func (opts UnmarshalOptions) UnmarshalTuple(tuple *lbug.FlatTuple, msg proto.Message) error {
+	row, err := tuple.GetAsMap()
+	if err != nil {
+		return err
+	}
+	return opts.Unmarshal(row, msg)
+}
+
+// UnmarshalAll decodes the remaining rows of the result into new messages of
+// type M, which is the pointer type of a generated message, e.g. *pb.Person.
+func UnmarshalAll[M proto.Message](result *lbug.QueryResult, opts UnmarshalOptions) ([]M, error) {
+	var zero M
+	messageType := zero.ProtoReflect().Type()
+	var messages []M
+	for result.HasNext() {
+		tuple, err := result.Next()
+		if err != nil {
+			return messages, err
+		}
+		msg := messageType.New().Interface().(M)
+		err = opts.UnmarshalTuple(tuple, msg)
+		tuple.Close()
+		if err != nil {
+			return messages, fmt.Errorf("row %d: %w", len(messages), err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// fieldIndexes caches the fieldIndex of each message descriptor.
+var fieldIndexes sync.Map
+
+// fieldIndex maps the normalized names of the fields of a message to the
+// fields. Names shared by several fields map to nil.
+type fieldIndex map[string]protoreflect.FieldDescriptor
+
+// normalizeName returns the name in lower case and without underscores.
+func normalizeName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// indexOf returns the fieldIndex of the message descriptor.
+func indexOf(descriptor protoreflect.MessageDescriptor) fieldIndex {
+	if index, ok := fieldIndexes.Load(descriptor); ok {
+		return index.(fieldIndex)
+	}
+	fields := descriptor.Fields()
+	index := make(fieldIndex, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		for _, name := range []string{string(field.Name()), field.JSONName()} {
+			key := normalizeName(name)
+			if other, ok := index[key]; ok && other != field {
+				index[key] = nil
+				continue
+			}
+			index[key] = field
+		}
+	}
+	fieldIndexes.Store(descriptor, index)
+	return index
+}
+
+// lookupField returns the field of the message that the column maps to.
+func lookupField(descriptor protoreflect.MessageDescriptor, column string) (protoreflect.FieldDescriptor, bool) {
+	index := indexOf(descriptor)
+	field, ok := index[normalizeName(column)]
+	if !ok {
+		if dot := strings.LastIndexByte(column, '.'); dot >= 0 {
+			field, ok = index[normalizeName(column[dot+1:])]
+		}
+	}
+	return field, ok
+}
+
+// propertiesOf returns the entries of a STRUCT value or the properties of a
+// node or relationship.
+func propertiesOf(value any) (map[string]any, bool) {
+	switch value := value.(type) {
+	case map[string]any:
+		return value, true
+	case lbug.Node:
+		return value.Properties, true
+	case lbug.Relationship:
+		return value.Properties, true
+	}
+	return nil, false
+}
+
+// decodeFields sets the fields of the message from the entries of the row.
+// path is the path of the message in the decoded message, used in errors.
+func (opts UnmarshalOptions) decodeFields(message protoreflect.Message, row map[string]any, path string) error {
+	descriptor := message.Descriptor()
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	setBy := make(map[protoreflect.FieldNumber]string, len(columns))
+	for _, column := range columns {
+		field, ok := lookupField(descriptor, column)
+		if !ok {
+			if opts.DiscardUnknown {
+				continue
+			}
+			return fmt.Errorf("%w: %s has no field for column %s", ErrUnknownColumn, descriptor.FullName(), path+column)
+		}
+		if field == nil {
+			return fmt.Errorf("column %s matches several fields of %s", path+column, descriptor.FullName())
+		}
+		if other, ok := setBy[field.Number()]; ok {
+			return fmt.Errorf("columns %s and %s both map to field %s", path+other, path+column, field.FullName())
+		}
+		setBy[field.Number()] = column
+		if err := opts.setField(message, field, row[column], path+string(field.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setField sets the field of the message to the value.
+func (opts UnmarshalOptions) setField(message protoreflect.Message, field protoreflect.FieldDescriptor, value any, path string) error {
+	if value == nil {
+		message.Clear(field)
+		return nil
+	}
+	switch {
+	case field.IsList():
+		return opts.setList(message, field, value, path)
+	case field.IsMap():
+		return opts.setMap(message, field, value, path)
+	case isMessage(field):
+		element := message.NewField(field)
+		if err := opts.decodeMessage(element.Message(), value, path); err != nil {
+			return err
+		}
+		message.Set(field, element)
+		return nil
+	}
+	scalar, err := scalarValue(field, value)
+	if err != nil {
+		return fmt.Errorf("field %s: %w", path, err)
+	}
+	message.Set(field, scalar)
+	return nil
+}
+
+// isMessage reports whether the field holds messages.
+func isMessage(field protoreflect.FieldDescriptor) bool {
+	return field.Kind() == protoreflect.MessageKind || field.Kind() == protoreflect.GroupKind
+}
+
+// setList sets the repeated field of the message to the elements of a list.
+func (opts UnmarshalOptions) setList(message protoreflect.Message, field protoreflect.FieldDescriptor, value any, path string) error {
+	elements, ok := value.([]any)
+	if !ok {
+		return fmt.Errorf("field %s: cannot set a repeated field from %T", path, value)
+	}
+	list := message.NewField(field).List()
+	for i, element := range elements {
+		elementPath := fmt.Sprintf("%s[%d]", path, i)
+		if element == nil {
+			return fmt.Errorf("field %s: repeated fields cannot hold NULL", elementPath)
+		}
+		if isMessage(field) {
+			item := list.NewElement()
+			if err := opts.decodeMessage(item.Message(), element, elementPath); err != nil {
+				return err
+			}
+			list.Append(item)
+			continue
+		}
+		item, err := scalarValue(field, element)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", elementPath, err)
+		}
+		list.Append(item)
+	}
+	message.Set(field, protoreflect.ValueOfList(list))
+	return nil
+}
+
+// setMap sets the map field of the message to the entries of a STRUCT or MAP
+// value.
+func (opts UnmarshalOptions) setMap(message protoreflect.Message, field protoreflect.FieldDescriptor, value any, path string) error {
+	var entries []lbug.MapItem
+	switch value := value.(type) {
+	case []lbug.MapItem:
+		entries = value
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			entries = append(entries, lbug.MapItem{Key: key, Value: value[key]})
+		}
+	default:
+		return fmt.Errorf("field %s: cannot set a map field from %T", path, value)
+	}
+	entryMap := message.NewField(field).Map()
+	valueField := field.MapValue()
+	for _, entry := range entries {
+		entryPath := fmt.Sprintf("%s[%v]", path, entry.Key)
+		key, err := scalarValue(field.MapKey(), entry.Key)
+		if err != nil {
+			return fmt.Errorf("field %s: key: %w", entryPath, err)
+		}
+		if entry.Value == nil {
+			return fmt.Errorf("field %s: map fields cannot hold NULL", entryPath)
+		}
+		if isMessage(valueField) {
+			item := entryMap.NewValue()
+			if err := opts.decodeMessage(item.Message(), entry.Value, entryPath); err != nil {
+				return err
+			}
+			entryMap.Set(key.MapKey(), item)
+			continue
+		}
+		item, err := scalarValue(valueField, entry.Value)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", entryPath, err)
+		}
+		entryMap.Set(key.MapKey(), item)
+	}
+	message.Set(field, protoreflect.ValueOfMap(entryMap))
+	return nil
+}
+
+// wrapperTypes are the well-known types wrapping a scalar in their field
+// value.
+var wrapperTypes = map[protoreflect.FullName]bool{
+	"google.protobuf.DoubleValue": true,
+	"google.protobuf.FloatValue":  true,
+	"google.protobuf.Int64Value":  true,
+	"google.protobuf.UInt64Value": true,
+	"google.protobuf.Int32Value":  true,
+	"google.protobuf.UInt32Value": true,
+	"google.protobuf.BoolValue":   true,
+	"google.protobuf.StringValue": true,
+	"google.protobuf.BytesValue":  true,
+}
+
+// decodeMessage sets the message, which is empty, from the value.
+func (opts UnmarshalOptions) decodeMessage(message protoreflect.Message, value any, path string) error {
+	name := message.Descriptor().FullName()
+	var known proto.Message
+	switch name {
+	case "google.protobuf.Timestamp":
+		timestamp, ok := value.(time.Time)
+		if !ok {
+			return fmt.Errorf("field %s: cannot set a %s from %T", path, name, value)
+		}
+		known = timestamppb.New(timestamp)
+	case "google.protobuf.Duration":
+		duration, err := toDuration(value)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", path, err)
+		}
+		known = durationpb.New(duration)
+	case "google.protobuf.Struct":
+		properties, ok := propertiesOf(value)
+		if !ok {
+			return fmt.Errorf("field %s: cannot set a %s from %T", path, name, value)
+		}
+		normalized, err := toStructValue(properties)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", path, err)
+		}
+		structValue, err := structpb.NewStruct(normalized.(map[string]any))
+		if err != nil {
+			return fmt.Errorf("field %s: %w", path, err)
+		}
+		known = structValue
+	case "google.protobuf.ListValue", "google.protobuf.Value":
+		normalized, err := toStructValue(value)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", path, err)
+		}
+		structValue, err := structpb.NewValue(normalized)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", path, err)
+		}
+		known = structValue
+		if name == "google.protobuf.ListValue" {
+			if structValue.GetListValue() == nil {
+				return fmt.Errorf("field %s: cannot set a %s from %T", path, name, value)
+			}
+			known = structValue.GetListValue()
+		}
+	default:
+		if wrapperTypes[name] {
+			field := message.Descriptor().Fields().ByName("value")
+			scalar, err := scalarValue(field, value)
+			if err != nil {
+				return fmt.Errorf("field %s: %w", path, err)
+			}
+			message.Set(field, scalar)
+			return nil
+		}
+		properties, ok := propertiesOf(value)
+		if !ok {
+			return fmt.Errorf("field %s: cannot set a %s from %T", path, name, value)
+		}
+		return opts.decodeFields(message, properties, path+".")
+	}
+	return setKnown(message, known)
+}
+
+// setKnown sets the message to the well-known message of the same type.
+func setKnown(message protoreflect.Message, known proto.Message) error {
+	if message.Descriptor() == known.ProtoReflect().Descriptor() {
+		proto.Merge(message.Interface(), known)
+		return nil
+	}
+	// The message is a dynamic message with its own descriptor of the type.
+	encoded, err := proto.Marshal(known)
+	if err != nil {
+		return err
+	}
+	return proto.UnmarshalOptions{Merge: true}.Unmarshal(encoded, message.Interface())
+}
+
+// toDuration converts an INTERVAL value to a time.Duration.
+func toDuration(value any) (time.Duration, error) {
+	switch value := value.(type) {
+	case time.Duration:
+		return value, nil
+	case lbug.Interval:
+		if value.Months != 0 {
+			return 0, fmt.Errorf("interval %v has a months component and cannot be converted to a google.protobuf.Duration exactly", value)
+		}
+		return time.Duration(value.Days)*24*time.Hour + time.Duration(value.Micros)*time.Microsecond, nil
+	}
+	return 0, fmt.Errorf("cannot set a google.protobuf.Duration from %T", value)
+}
+
+// toStructValue converts a value to the types accepted by structpb.NewValue.
+func toStructValue(value any) (any, error) {
+	switch value := value.(type) {
+	case map[string]any, lbug.Node, lbug.Relationship:
+		properties, _ := propertiesOf(value)
+		normalized := make(map[string]any, len(properties))
+		for key, property := range properties {
+			converted, err := toStructValue(property)
+			if err != nil {
+				return nil, err
+			}
+			normalized[key] = converted
+		}
+		return normalized, nil
+	case []lbug.MapItem:
+		normalized := make(map[string]any, len(value))
+		for _, entry := range value {
+			converted, err := toStructValue(entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			normalized[fmt.Sprint(entry.Key)] = converted
+		}
+		return normalized, nil
+	case []any:
+		normalized := make([]any, len(value))
+		for i, element := range value {
+			converted, err := toStructValue(element)
+			if err != nil {
+				return nil, err
+			}
+			normalized[i] = converted
+		}
+		return normalized, nil
+	case time.Time:
+		return value.Format(time.RFC3339Nano), nil
+	case time.Duration:
+		return value.String(), nil
+	case uuid.UUID, decimal.Decimal, *big.Int, lbug.Interval:
+		return fmt.Sprint(value), nil
+	case nil, bool, string, []byte, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return value, nil
+	}
+	return nil, fmt.Errorf("cannot convert %T to a google.protobuf.Value", value)
+}
+
+// scalarValue converts the value to the kind of the field, which does not
+// hold messages.
+func scalarValue(field protoreflect.FieldDescriptor, value any) (protoreflect.Value, error) {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		if value, ok := value.(bool); ok {
+			return protoreflect.ValueOfBool(value), nil
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if value, ok := toInt64(value); ok && value >= math.MinInt32 && value <= math.MaxInt32 {
+			return protoreflect.ValueOfInt32(int32(value)), nil
+		}
+		return rangeError(field, value)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if value, ok := toInt64(value); ok {
+			return protoreflect.ValueOfInt64(value), nil
+		}
+		return rangeError(field, value)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if value, ok := toUint64(value); ok && value <= math.MaxUint32 {
+			return protoreflect.ValueOfUint32(uint32(value)), nil
+		}
+		return rangeError(field, value)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if value, ok := toUint64(value); ok {
+			return protoreflect.ValueOfUint64(value), nil
+		}
+		return rangeError(field, value)
+	case protoreflect.FloatKind:
+		if value, ok := toFloat64(value); ok {
+			return protoreflect.ValueOfFloat32(float32(value)), nil
+		}
+	case protoreflect.DoubleKind:
+		if value, ok := toFloat64(value); ok {
+			return protoreflect.ValueOfFloat64(value), nil
+		}
+	case protoreflect.StringKind:
+		switch value := value.(type) {
+		case string:
+			return protoreflect.ValueOfString(value), nil
+		case uuid.UUID, decimal.Decimal, *big.Int:
+			return protoreflect.ValueOfString(fmt.Sprint(value)), nil
+		}
+	case protoreflect.BytesKind:
+		switch value := value.(type) {
+		case []byte:
+			return protoreflect.ValueOfBytes(value), nil
+		case string:
+			return protoreflect.ValueOfBytes([]byte(value)), nil
+		}
+	case protoreflect.EnumKind:
+		return enumValue(field, value)
+	}
+	return protoreflect.Value{}, fmt.Errorf("cannot set a %s field from %T", field.Kind(), value)
+}
+
+// rangeError returns the error of an integer field that cannot be set from
+// the value.
+func rangeError(field protoreflect.FieldDescriptor, value any) (protoreflect.Value, error) {
+	if _, isInt := toInt64(value); isInt {
+		return protoreflect.Value{}, fmt.Errorf("%v is out of the range of a %s field", value, field.Kind())
+	}
+	if _, isUint := toUint64(value); isUint {
+		return protoreflect.Value{}, fmt.Errorf("%v is out of the range of a %s field", value, field.Kind())
+	}
+	return protoreflect.Value{}, fmt.Errorf("cannot set a %s field from %T", field.Kind(), value)
+}
+
+// enumValue converts the name or the number of an enum value.
+func enumValue(field protoreflect.FieldDescriptor, value any) (protoreflect.Value, error) {
+	values := field.Enum().Values()
+	if name, ok := value.(string); ok {
+		if enumValue := values.ByName(protoreflect.Name(name)); enumValue != nil {
+			return protoreflect.ValueOfEnum(enumValue.Number()), nil
+		}
+		return protoreflect.Value{}, fmt.Errorf("%s has no value %s", field.Enum().FullName(), name)
+	}
+	number, ok := toInt64(value)
+	if !ok {
+		return protoreflect.Value{}, fmt.Errorf("cannot set an enum field from %T", value)
+	}
+	if number < math.MinInt32 || number > math.MaxInt32 || (field.Enum().IsClosed() && values.ByNumber(protoreflect.EnumNumber(number)) == nil) {
+		return protoreflect.Value{}, fmt.Errorf("%s has no value %d", field.Enum().FullName(), number)
+	}
+	return protoreflect.ValueOfEnum(protoreflect.EnumNumber(number)), nil
+}
+
+// toInt64 converts an integer that fits in an int64.
+func toInt64(value any) (int64, bool) {
+	switch value := value.(type) {
+	case int:
+		return int64(value), true
+	case int8:
+		return int64(value), true
+	case int16:
+		return int64(value), true
+	case int32:
+		return int64(value), true
+	case int64:
+		return value, true
+	case *big.Int:
+		return value.Int64(), value.IsInt64()
+	}
+	if value, ok := toUint64(value); ok && value <= math.MaxInt64 {
+		return int64(value), true
+	}
+	return 0, false
+}
+
+// toUint64 converts a non-negative integer that fits in an uint64.
+func toUint64(value any) (uint64, bool) {
+	switch value := value.(type) {
+	case uint:
+		return uint64(value), true
+	case uint8:
+		return uint64(value), true
+	case uint16:
+		return uint64(value), true
+	case uint32:
+		return uint64(value), true
+	case uint64:
+		return value, true
+	case *big.Int:
+		return value.Uint64(), value.IsUint64()
+	case int, int8, int16, int32, int64:
+		if signed, _ := toInt64(value); signed >= 0 {
+			return uint64(signed), true
+		}
+	}
+	return 0, false
+}
+
+// toFloat64 converts a float, an integer or a DECIMAL value.
+func toFloat64(value any) (float64, bool) {
+	switch value := value.(type) {
+	case float32:
+		return float64(value), true
+	case float64:
+		return value, true
+	case decimal.Decimal:
+		return value.InexactFloat64(), true
+	}
+	if value, ok := toInt64(value); ok {
+		return float64(value), true
+	}
+	if value, ok := toUint64(value); ok {
+		return float64(value), true
+	}
+	return 0, false
+}
+
+// missingRequired appends to missing the paths of the required fields of the
+// message and of its nested messages that are not set.
+func missingRequired(message protoreflect.Message, path string, missing *[]string) {
+	fields := message.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		fieldPath := path + string(field.Name())
+		if !message.Has(field) {
+			if field.Cardinality() == protoreflect.Required {
+				*missing = append(*missing, fieldPath)
+			}
+			continue
+		}
+		switch {
+		case field.IsList() && isMessage(field):
+			list := message.Get(field).List()
+			for j := 0; j < list.Len(); j++ {
+				missingRequired(list.Get(j).Message(), fmt.Sprintf("%s[%d].", fieldPath, j), missing)
+			}
+		case field.IsMap() && isMessage(field.MapValue()):
+			var keys []protoreflect.MapKey
+			message.Get(field).Map().Range(func(key protoreflect.MapKey, _ protoreflect.Value) bool {
+				keys = append(keys, key)
+				return true
+			})
+			sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+			for _, key := range keys {
+				missingRequired(message.Get(field).Map().Get(key).Message(), fmt.Sprintf("%s[%v].", fieldPath, key.Interface()), missing)
+			}
+		case !field.IsList() && !field.IsMap() && isMessage(field):
+			missingRequired(message.Get(field).Message(), fieldPath+".", missing)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package lbugproto
+
+import (
+	"testing"
+	"time"
+
+	lbug "github.com/LadybugDB/go-ladybug"
+	"github.com/LadybugDB/go-ladybug/lbugproto/internal/testpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+var registeredAt = time.Date(2011, 8, 20, 11, 25, 30, 0, time.UTC)
+
+// alice returns the row of the person table of the tests, with the values of
+// the types returned by lbug.FlatTuple.GetAsMap.
+func alice() map[string]any {
+	return map[string]any{
+		"p.id":            int64(1),
+		"p.fullName":      "Alice",
+		"p.age":           uint32(35),
+		"p.eye_sight":     5.0,
+		"p.is_student":    true,
+		"p.role":          "ROLE_ADMIN",
+		"p.avatar":        []byte{0xAA, 0xBB},
+		"p.registered_at": registeredAt,
+		"p.last_session":  3*time.Hour + 2*time.Minute,
+		"p.attributes":    map[string]any{"level": int64(3), "team": "graph"},
+		"p.nickname":      "Al",
+		"p.scores":        []any{int64(96), int64(54)},
+		"p.emailAddresses": []any{
+			"alice@example.com",
+		},
+		"p.address": map[string]any{"city": "Berlin", "zip_code": int32(10115)},
+		"p.previous_addresses": []any{
+			map[string]any{"city": "Paris", "zip_code": nil},
+		},
+		"p.counters": []lbug.MapItem{{Key: "logins", Value: int64(12)}},
+	}
+}
+
+// alicePerson returns the message decoded from alice.
+func alicePerson() *testpb.Person {
+	attributes, _ := structpb.NewStruct(map[string]any{"level": 3, "team": "graph"})
+	return &testpb.Person{
+		Id:                proto.Int64(1),
+		FullName:          proto.String("Alice"),
+		Age:               proto.Uint32(35),
+		EyeSight:          proto.Float64(5.0),
+		IsStudent:         proto.Bool(true),
+		Role:              testpb.Role_ROLE_ADMIN.Enum(),
+		Avatar:            []byte{0xAA, 0xBB},
+		RegisteredAt:      timestamppb.New(registeredAt),
+		LastSession:       durationpb.New(3*time.Hour + 2*time.Minute),
+		Attributes:        attributes,
+		Nickname:          wrapperspb.String("Al"),
+		Scores:            []int64{96, 54},
+		EmailAddresses:    []string{"alice@example.com"},
+		Address:           &testpb.Address{City: proto.String("Berlin"), ZipCode: proto.Int32(10115)},
+		PreviousAddresses: []*testpb.Address{{City: proto.String("Paris")}},
+		Counters:          map[string]int64{"logins": 12},
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	person := &testpb.Person{}
+	assert.Nil(t, Unmarshal(alice(), person))
+	assert.True(t, proto.Equal(alicePerson(), person), "got %v", person)
+
+	// NULL values leave the fields unset.
+	row := alice()
+	row["p.age"] = nil
+	row["p.address"] = nil
+	person = &testpb.Person{}
+	assert.Nil(t, Unmarshal(row, person))
+	assert.Nil(t, person.Age)
+	assert.Nil(t, person.GetAddress())
+
+	// Integers set the enum fields and the MAP values can be STRUCT values.
+	person = &testpb.Person{}
+	assert.Nil(t, Unmarshal(map[string]any{"id": int64(2), "full_name": "Bob", "role": int64(1), "counters": map[string]any{"logins": int64(1)}}, person))
+	assert.Equal(t, testpb.Role_ROLE_MEMBER, person.GetRole())
+	assert.Equal(t, map[string]int64{"logins": 1}, person.GetCounters())
+}
+
+func TestUnmarshalNode(t *testing.T) {
+	node := lbug.Node{Label: "person", Properties: map[string]any{"id": int64(1), "full_name": "Alice", "age": int64(35)}}
+	person := &testpb.Person{}
+	assert.Nil(t, Unmarshal(map[string]any{"p": node}, person))
+	assert.True(t, proto.Equal(&testpb.Person{Id: proto.Int64(1), FullName: proto.String("Alice"), Age: proto.Uint32(35)}, person), "got %v", person)
+
+	// A node sets the fields of a nested message and of a Struct.
+	response := &testpb.ListPeopleResponse{}
+	assert.Nil(t, Unmarshal(map[string]any{"people": []any{node}}, response))
+	assert.Equal(t, "Alice", response.GetPeople()[0].GetFullName())
+	person = &testpb.Person{}
+	assert.Nil(t, Unmarshal(map[string]any{"id": int64(1), "full_name": "Alice", "attributes": node}, person))
+	assert.Equal(t, "Alice", person.GetAttributes().GetFields()["full_name"].GetStringValue())
+}
+
+func TestUnmarshalDynamic(t *testing.T) {
+	person := dynamicpb.NewMessage((&testpb.Person{}).ProtoReflect().Descriptor())
+	assert.Nil(t, Unmarshal(alice(), person))
+	encoded, err := proto.Marshal(person)
+	assert.Nil(t, err)
+	decoded := &testpb.Person{}
+	assert.Nil(t, proto.Unmarshal(encoded, decoded))
+	assert.True(t, proto.Equal(alicePerson(), decoded), "got %v", decoded)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	row := alice()
+	row["p.height"] = 1.7
+	err := Unmarshal(row, &testpb.Person{})
+	assert.ErrorIs(t, err, ErrUnknownColumn)
+	assert.ErrorContains(t, err, "p.height")
+	assert.Nil(t, UnmarshalOptions{DiscardUnknown: true}.Unmarshal(row, &testpb.Person{}))
+
+	row = alice()
+	row["p.address"] = map[string]any{"street": "Unter den Linden"}
+	assert.ErrorContains(t, Unmarshal(row, &testpb.Person{}), "column address.street")
+
+	for _, test := range []struct {
+		column  string
+		value   any
+		message string
+	}{
+		{"p.age", int64(-1), "-1 is out of the range of a uint32 field"},
+		{"p.age", "35", "cannot set a uint32 field from string"},
+		{"p.id", uint64(1 << 63), "out of the range of a int64 field"},
+		{"p.role", "ROLE_OWNER", "lbugproto.test.Role has no value ROLE_OWNER"},
+		{"p.role", int64(7), "lbugproto.test.Role has no value 7"},
+		{"p.scores", []any{int64(1), nil}, "field scores[1]: repeated fields cannot hold NULL"},
+		{"p.scores", int64(1), "cannot set a repeated field from int64"},
+		{"p.registered_at", "2011-08-20", "cannot set a google.protobuf.Timestamp from string"},
+		{"p.last_session", lbug.Interval{Months: 1}, "has a months component"},
+		{"p.previous_addresses", []any{"Paris"}, "field previous_addresses[0]: cannot set a lbugproto.test.Address from string"},
+	} {
+		row := alice()
+		row[test.column] = test.value
+		assert.ErrorContains(t, Unmarshal(row, &testpb.Person{}), test.message, test.column)
+	}
+
+	err = Unmarshal(map[string]any{"p.id": int64(1), "q.id": int64(2), "full_name": "Alice"}, &testpb.Person{})
+	assert.ErrorContains(t, err, "columns p.id and q.id both map to field lbugproto.test.Person.id")
+}
+
+func TestRequiredFields(t *testing.T) {
+	err := Unmarshal(map[string]any{"age": int64(35), "full_name": nil}, &testpb.Person{})
+	var requiredErr *RequiredFieldsError
+	assert.ErrorAs(t, err, &requiredErr)
+	assert.ErrorIs(t, err, ErrRequiredFieldNotSet)
+	assert.Equal(t, protoreflect.FullName("lbugproto.test.Person"), requiredErr.Message)
+	assert.Equal(t, []string{"id", "full_name"}, requiredErr.Fields)
+
+	err = Unmarshal(map[string]any{"people": []any{map[string]any{"id": int64(1)}}}, &testpb.ListPeopleResponse{})
+	assert.ErrorAs(t, err, &requiredErr)
+	assert.Equal(t, []string{"people[0].full_name"}, requiredErr.Fields)
+
+	person := &testpb.Person{}
+	assert.Nil(t, UnmarshalOptions{AllowPartial: true}.Unmarshal(map[string]any{"age": int64(35)}, person))
+	assert.Equal(t, uint32(35), person.GetAge())
+}
+
+func TestNormalizeName(t *testing.T) {
+	for _, name := range []string{"full_name", "fullName", "FullName", "FULL_NAME"} {
+		field, ok := lookupField((&testpb.Person{}).ProtoReflect().Descriptor(), name)
+		assert.True(t, ok, name)
+		assert.Equal(t, "full_name", string(field.Name()), name)
+	}
+	_, ok := lookupField((&testpb.Person{}).ProtoReflect().Descriptor(), "p.full_names")
+	assert.False(t, ok)
+}
+
+// openTestConnection opens a connection to a new in-memory database with the
+// person table of the tests.
+func openTestConnection(t *testing.T) *lbug.Connection {
+	t.Helper()
+	db, err := lbug.OpenInMemoryDatabase(lbug.DefaultSystemConfig())
+	assert.Nil(t, err)
+	t.Cleanup(db.Close)
+	conn, err := lbug.OpenConnection(db)
+	assert.Nil(t, err)
+	t.Cleanup(conn.Close)
+	result, err := conn.Query(`CREATE NODE TABLE person(id INT64, full_name STRING, age UINT32, eye_sight DOUBLE,
+		is_student BOOL, role STRING, avatar BLOB, registered_at TIMESTAMP, last_session INTERVAL,
+		attributes STRUCT(level INT64, team STRING), nickname STRING, scores INT64[], email_addresses STRING[],
+		address STRUCT(city STRING, zip_code INT32), previous_addresses STRUCT(city STRING, zip_code INT32)[],
+		counters MAP(STRING, INT64), PRIMARY KEY(id));`)
+	assert.Nil(t, err)
+	result.Close()
+
+	statement, err := conn.Prepare(`CREATE (:person {id: $id, full_name: $full_name, age: $age, eye_sight: $eye_sight,
+		is_student: $is_student, role: $role, avatar: $avatar, registered_at: $registered_at,
+		last_session: $last_session, attributes: $attributes, nickname: $nickname, scores: $scores,
+		email_addresses: $email_addresses, address: $address, previous_addresses: $previous_addresses,
+		counters: $counters});`)
+	assert.Nil(t, err)
+	defer statement.Close()
+	args := map[string]any{}
+	for column, value := range alice() {
+		args[column[len("p."):]] = value
+	}
+	args["full_name"] = args["fullName"]
+	args["email_addresses"] = args["emailAddresses"]
+	delete(args, "fullName")
+	delete(args, "emailAddresses")
+	result, err = conn.Execute(statement, args)
+	assert.Nil(t, err)
+	result.Close()
+	return conn
+}
+
+func TestUnmarshalAllRoundTrip(t *testing.T) {
+	conn := openTestConnection(t)
+	result, err := conn.Query(`MATCH (p:person) RETURN p.id, p.full_name, p.age, p.eye_sight, p.is_student, p.role,
+		p.avatar, p.registered_at, p.last_session, p.attributes, p.nickname, p.scores, p.email_addresses,
+		p.address, p.previous_addresses, p.counters ORDER BY p.id;`)
+	assert.Nil(t, err)
+	defer result.Close()
+	people, err := UnmarshalAll[*testpb.Person](result, UnmarshalOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(people))
+	assert.True(t, proto.Equal(alicePerson(), people[0]), "got %v", people[0])
+
+	// The properties of a node set the fields, and the encoded message
+	// decodes to the same message.
+	result, err = conn.Query("MATCH (p:person) RETURN p;")
+	assert.Nil(t, err)
+	defer result.Close()
+	people, err = UnmarshalAll[*testpb.Person](result, UnmarshalOptions{DiscardUnknown: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(people))
+	encoded, err := proto.Marshal(people[0])
+	assert.Nil(t, err)
+	decoded := &testpb.Person{}
+	assert.Nil(t, proto.Unmarshal(encoded, decoded))
+	assert.True(t, proto.Equal(alicePerson(), decoded), "got %v", decoded)
+
+	result, err = conn.Query("MATCH (p:person) RETURN p.age;")
+	assert.Nil(t, err)
+	defer result.Close()
+	_, err = UnmarshalAll[*testpb.Person](result, UnmarshalOptions{})
+	assert.ErrorIs(t, err, ErrRequiredFieldNotSet)
+	assert.ErrorContains(t, err, "row 0")
+}
@@ -0,0 +1,52 @@
+// Package lbugtest provides helpers for testing code that uses the lbug
+// package.
+package lbugtest
+
+import (
+	"strings"
+	"testing"
+
+	lbug "github.com/LadybugDB/go-ladybug"
+)
+
+// VerifyNoLeaks enables handle tracking for the duration of the test and
+// fails the test at cleanup if any Database, Connection, PreparedStatement,
+// QueryResult or FlatTuple created after VerifyNoLeaks was called is still
+// open. The creation stack of every leaked handle is included in the failure
+// message.
+//
+// The lbug package does not rely on finalizers to release C handles, so a
+// handle is open exactly until its Close method is called and no garbage
+// collection or waiting is involved. Because the handle registry is
+// process-wide, VerifyNoLeaks must not be used in tests that run in parallel
+// with other tests creating lbug handles.
+func VerifyNoLeaks(t testing.TB) {
+	t.Helper()
+	wasTracking := lbug.IsHandleTrackingEnabled()
+	existing := make(map[uint64]bool)
+	for _, info := range lbug.OpenHandles() {
+		existing[info.ID] = true
+	}
+	lbug.SetHandleTracking(true)
+	t.Cleanup(func() {
+		t.Helper()
+		lbug.SetHandleTracking(wasTracking)
+		var leaked []lbug.HandleInfo
+		for _, info := range lbug.OpenHandles() {
+			if !existing[info.ID] {
+				leaked = append(leaked, info)
+			}
+		}
+		if len(leaked) == 0 {
+			return
+		}
+		var builder strings.Builder
+		for _, info := range leaked {
+			builder.WriteString("\n")
+			builder.WriteString(info.Kind.String())
+			builder.WriteString(" created at:\n")
+			builder.WriteString(info.Stack)
+		}
+		t.Errorf("lbugtest: %d handle(s) still open at the end of the test:%s", len(leaked), builder.String())
+	})
+}
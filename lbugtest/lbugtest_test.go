@@ -0,0 +1,85 @@
+package lbugtest
+
+import (
+	"fmt"
+	"testing"
+
+	lbug "github.com/LadybugDB/go-ladybug"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingTB records failures and cleanups instead of reporting them to the
+// enclosing test, so that VerifyNoLeaks can be tested against itself.
+type recordingTB struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (tb *recordingTB) Helper() {}
+
+func (tb *recordingTB) Cleanup(f func()) {
+	tb.cleanups = append(tb.cleanups, f)
+}
+
+func (tb *recordingTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func (tb *recordingTB) runCleanups() {
+	for i := len(tb.cleanups) - 1; i >= 0; i-- {
+		tb.cleanups[i]()
+	}
+}
+
+func openTestConnection(t *testing.T) (*lbug.Database, *lbug.Connection) {
+	t.Helper()
+	db, err := lbug.OpenInMemoryDatabase(lbug.DefaultSystemConfig())
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	conn, err := lbug.OpenConnection(db)
+	if err != nil {
+		db.Close()
+		t.Fatalf("failed to open connection: %v", err)
+	}
+	return db, conn
+}
+
+func TestVerifyNoLeaksPasses(t *testing.T) {
+	db, conn := openTestConnection(t)
+	defer db.Close()
+	defer conn.Close()
+	tb := &recordingTB{TB: t}
+	VerifyNoLeaks(tb)
+	res, err := conn.Query("RETURN 1;")
+	assert.Nil(t, err)
+	for res.HasNext() {
+		tuple, err := res.Next()
+		assert.Nil(t, err)
+		tuple.Close()
+	}
+	res.Close()
+	tb.runCleanups()
+	assert.Equal(t, 0, len(tb.errors))
+}
+
+func TestVerifyNoLeaksReportsLeaks(t *testing.T) {
+	db, conn := openTestConnection(t)
+	defer db.Close()
+	defer conn.Close()
+	tb := &recordingTB{TB: t}
+	VerifyNoLeaks(tb)
+	res, err := conn.Query("RETURN 1;")
+	assert.Nil(t, err)
+	stmt, err := conn.Prepare("RETURN $a;")
+	assert.Nil(t, err)
+	tb.runCleanups()
+	assert.Equal(t, 1, len(tb.errors))
+	assert.Contains(t, tb.errors[0], "2 handle(s)")
+	assert.Contains(t, tb.errors[0], "QueryResult created at:")
+	assert.Contains(t, tb.errors[0], "PreparedStatement created at:")
+	assert.Contains(t, tb.errors[0], "TestVerifyNoLeaksReportsLeaks")
+	res.Close()
+	stmt.Close()
+}
@@ -0,0 +1,89 @@
+package lbugtest
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"testing"
+	"time"
+
+	lbug "github.com/LadybugDB/go-ladybug"
+)
+
+// quiesceTimeout bounds the wait of Quiesce for the pending finalizers and
+// cleanups, in case one of them blocks on a lock held by the caller.
+const quiesceTimeout = time.Second
+
+// Quiesce runs a garbage collection and waits until the finalizers and
+// cleanups queued by it have run, so that objects that became unreachable
+// before the call have been finalized when it returns. The wait is bounded by
+// a second, in case a finalizer blocks.
+func Quiesce() {
+	runtime.GC()
+	finalized := make(chan struct{})
+	cleaned := make(chan struct{})
+	sentinel := new([16]byte)
+	runtime.SetFinalizer(sentinel, func(*[16]byte) { close(finalized) })
+	runtime.AddCleanup(new([16]byte), func(done chan struct{}) { close(done) }, cleaned)
+	sentinel = nil
+	// The sentinels are queued by this collection, after the finalizers and
+	// cleanups of the objects found unreachable by the previous one.
+	runtime.GC()
+	timeout := time.After(quiesceTimeout)
+	for _, done := range []chan struct{}{finalized, cleaned} {
+		select {
+		case <-done:
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// CheckLifetimes fails the test as soon as a call into the C API is made on a
+// handle whose parent has been destroyed, e.g. on a QueryResult whose
+// Connection was closed, with the stack of the call, see
+// lbug.SetLifetimeChecks. The checks are process-wide, so CheckLifetimes must
+// not be used in tests that run in parallel with other tests using lbug.
+func CheckLifetimes(t testing.TB) {
+	t.Helper()
+	lbug.SetLifetimeChecks(func(violation lbug.LifetimeViolation) {
+		t.Errorf("lbugtest: %s", violation)
+	})
+	t.Cleanup(func() { lbug.SetLifetimeChecks(nil) })
+}
+
+// CollectEveryCall runs Quiesce before every call into the C API made by the
+// lbug package until the end of the test, see lbug.SetCgoCallHook, so that a
+// handle whose Go owner is unreachable is finalized before the next call
+// rather than when incidental GC pressure happens to collect it. It makes the
+// test much slower. The hook is process-wide, so CollectEveryCall must not be
+// used in tests that run in parallel with other tests using lbug.
+func CollectEveryCall(t testing.TB) {
+	t.Helper()
+	lbug.SetCgoCallHook(Quiesce)
+	t.Cleanup(func() { lbug.SetCgoCallHook(nil) })
+}
+
+// DefaultGCPercents are the values of SweepGCPercent for a nil list: frequent
+// collections, about one collection per doubling of the heap, the default,
+// and rare collections.
+var DefaultGCPercents = []int{1, 10, 100, 1000}
+
+// SweepGCPercent runs the test function as a subtest for each of the
+// percents, named after it, with the garbage collection target percentage
+// set to it with debug.SetGCPercent, and restores the previous percentage
+// afterwards. A nil list sweeps DefaultGCPercents. The percentage is
+// process-wide, so the subtests must not run in parallel.
+func SweepGCPercent(t *testing.T, percents []int, test func(t *testing.T)) {
+	t.Helper()
+	if percents == nil {
+		percents = DefaultGCPercents
+	}
+	for _, percent := range percents {
+		t.Run("GOGC="+strconv.Itoa(percent), func(t *testing.T) {
+			previous := debug.SetGCPercent(percent)
+			defer debug.SetGCPercent(previous)
+			test(t)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package lbugtest
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"testing"
+
+	lbug "github.com/LadybugDB/go-ladybug"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuiesce(t *testing.T) {
+	finalized := make(chan struct{}, 1)
+	func() {
+		object := new([64]byte)
+		runtime.SetFinalizer(object, func(*[64]byte) { finalized <- struct{}{} })
+	}()
+	Quiesce()
+	select {
+	case <-finalized:
+	default:
+		t.Fatal("the finalizer of the unreachable object has not run")
+	}
+}
+
+func TestSweepGCPercent(t *testing.T) {
+	var seen []int
+	SweepGCPercent(t, []int{5, 50}, func(t *testing.T) {
+		percent := debug.SetGCPercent(-1)
+		debug.SetGCPercent(percent)
+		seen = append(seen, percent)
+	})
+	assert.Equal(t, []int{5, 50}, seen)
+}
+
+func TestCollectEveryCall(t *testing.T) {
+	_, conn := openTestConnection(t)
+	defer conn.Close()
+	finalized := 0
+	tb := &recordingTB{TB: t}
+	CollectEveryCall(tb)
+	for i := 0; i < 3; i++ {
+		object := new([64]byte)
+		runtime.SetFinalizer(object, func(*[64]byte) { finalized++ })
+		object = nil
+		res, err := conn.Query("RETURN 1;")
+		assert.Nil(t, err)
+		res.Close()
+		// The object was finalized before the query was run.
+		assert.Equal(t, i+1, finalized)
+	}
+	tb.runCleanups()
+}
+
+func TestCheckLifetimes(t *testing.T) {
+	db, conn := openTestConnection(t)
+	defer db.Close()
+	res, err := conn.Query("UNWIND range(1, 3) AS i RETURN i;")
+	assert.Nil(t, err)
+	tb := &recordingTB{TB: t}
+	CheckLifetimes(tb)
+	tuple, err := res.Next()
+	assert.Nil(t, err)
+	assert.Empty(t, tb.errors)
+	conn.Close()
+	// The checked calls fail instead of reaching the destroyed connection.
+	_, err = tuple.GetValue(0)
+	assert.ErrorIs(t, err, lbug.ErrClosed)
+	assert.False(t, res.HasNext())
+	tuple.Close()
+	res.Close()
+	tb.runCleanups()
+	if assert.Equal(t, 4, len(tb.errors)) {
+		assert.True(t, strings.HasPrefix(tb.errors[0], "lbugtest: FlatTuple used after its Connection was closed at:"), tb.errors[0])
+		assert.Contains(t, tb.errors[0], "TestCheckLifetimes")
+		assert.True(t, strings.HasPrefix(tb.errors[1], "lbugtest: QueryResult used after its Connection was closed"), tb.errors[1])
+		assert.True(t, strings.HasPrefix(tb.errors[2], "lbugtest: FlatTuple used after its Connection was closed"), tb.errors[2])
+		assert.True(t, strings.HasPrefix(tb.errors[3], "lbugtest: QueryResult used after its Connection was closed"), tb.errors[3])
+	}
+}
+
+// openGraphConnection returns a connection to a graph of nodes connected to
+// the next three nodes.
+func openGraphConnection(t *testing.T, numNodes int) *lbug.Connection {
+	t.Helper()
+	db, conn := openTestConnection(t)
+	t.Cleanup(db.Close)
+	t.Cleanup(conn.Close)
+	for _, query := range []string{
+		"CREATE NODE TABLE Node(id INT64, name STRING, fqn STRING, file_path STRING, PRIMARY KEY(id));",
+		"CREATE REL TABLE CONNECTS(FROM Node TO Node, label STRING);",
+		fmt.Sprintf("UNWIND range(0, %d) AS i CREATE (:Node {id: i, name: 'item_' + cast(i, 'STRING'), fqn: 'src/module' + cast(i / 10, 'STRING') + '.item_' + cast(i, 'STRING'), file_path: 'src/module' + cast(i / 10, 'STRING') + '.ext'});", numNodes-1),
+		"MATCH (a:Node), (b:Node) WHERE b.id > a.id AND b.id <= a.id + 3 CREATE (a)-[:CONNECTS {label: 'links'}]->(b);",
+	} {
+		res, err := conn.Query(query)
+		if err != nil {
+			t.Fatalf("failed to set up the graph: %v", err)
+		}
+		res.Close()
+	}
+	return conn
+}
+
+// iterateConnections iterates the relationships of the graph, reading all the
+// columns of every row.
+func iterateConnections(conn *lbug.Connection, limit int) error {
+	res, err := conn.Query(fmt.Sprintf(`
+		MATCH (source:Node)-[r:CONNECTS]->(target:Node)
+		RETURN source.file_path, source.fqn, source.id, target.file_path, target.fqn, target.id, r.label
+		LIMIT %d`, limit))
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	defer res.Close()
+	for row := 0; res.HasNext(); row++ {
+		tuple, err := res.Next()
+		if err != nil {
+			return fmt.Errorf("Next failed at row %d: %w", row, err)
+		}
+		for col := range uint64(7) {
+			if _, err := tuple.GetValue(col); err != nil {
+				tuple.Close()
+				return fmt.Errorf("GetValue(%d) failed at row %d: %w", col, row, err)
+			}
+		}
+		tuple.Close()
+	}
+	return nil
+}
+
+// TestFinalizerRaceCondition guards against the use after free that happened
+// when a QueryResult was finalized while its tuples were still read, which
+// only showed when incidental GC pressure from earlier tests collected the
+// result at the wrong moment. The harness collects garbage before every call
+// into the C API, so that a handle released by a finalizer would be
+// destroyed before its next use in this test alone, and fails the test on any
+// call on a handle whose parent is destroyed. The package releases handles
+// only on Close, so the test passes under every GC percentage.
+func TestFinalizerRaceCondition(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping lifetime stress test in short mode")
+	}
+	conn := openGraphConnection(t, 100)
+	SweepGCPercent(t, []int{1, 100}, func(t *testing.T) {
+		CheckLifetimes(t)
+		CollectEveryCall(t)
+		var wg sync.WaitGroup
+		errs := make(chan error, 4)
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := iterateConnections(conn, 20); err != nil {
+					errs <- err
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package lbug
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// LifetimeViolation describes a call into the C API on a handle whose parent
+// handle has been destroyed, e.g. iterating a QueryResult after its
+// Connection was closed, reported to the function set with
+// SetLifetimeChecks.
+type LifetimeViolation struct {
+	// Kind is the kind of the handle called.
+	Kind HandleKind
+	// Parent is the kind of its destroyed parent: the Database of a
+	// Connection, and the Connection of a PreparedStatement, QueryResult or
+	// FlatTuple.
+	Parent HandleKind
+	// Stack is the stack of the call.
+	Stack string
+}
+
+func (violation LifetimeViolation) String() string {
+	return fmt.Sprintf("%s used after its %s was closed at:\n%s", violation.Kind, violation.Parent, violation.Stack)
+}
+
+// lifetimeHooks are the hooks set for tests with SetLifetimeChecks and
+// SetCgoCallHook.
+var lifetimeHooks struct {
+	report     atomic.Pointer[func(LifetimeViolation)]
+	beforeCall atomic.Pointer[func()]
+}
+
+// SetLifetimeChecks makes the package call report, from the goroutine making
+// the call, before every call into the C API that operates on a handle whose
+// parent has been destroyed, which is a use after free in the C library. A
+// FlatTuple may outlive its QueryResult, but not the Connection of the
+// result. The call is then not made: the operation returns an error matching
+// ErrClosed, and a Close marks the handle closed without destroying it. The
+// checks are meant for tests, see lbugtest.CheckLifetimes; a nil report, the
+// default, disables them.
+func SetLifetimeChecks(report func(LifetimeViolation)) {
+	if report == nil {
+		lifetimeHooks.report.Store(nil)
+		return
+	}
+	lifetimeHooks.report.Store(&report)
+}
+
+// SetCgoCallHook makes the package call hook before the calls into the C API
+// counted by SetCgoCallCounting: queries, preparations, executions, bindings,
+// fetches, value reads and closes. It is meant for tests provoking lifetime
+// bugs, e.g. by collecting garbage before every call, see
+// lbugtest.CollectEveryCall. A nil hook, the default, disables it.
+func SetCgoCallHook(hook func()) {
+	if hook == nil {
+		lifetimeHooks.beforeCall.Store(nil)
+		return
+	}
+	lifetimeHooks.beforeCall.Store(&hook)
+}
+
+// checkParent reports a violation if a lifetime check is set and the parent
+// of the handle is closed, as told by parentClosed. It then returns an error
+// matching ErrClosed, which the caller returns instead of making the call, so
+// that the checks do not cause the use after free they report.
+func checkParent(kind HandleKind, parent HandleKind, parentClosed func() bool) error {
+	report := lifetimeHooks.report.Load()
+	if report == nil || !parentClosed() {
+		return nil
+	}
+	(*report)(LifetimeViolation{Kind: kind, Parent: parent, Stack: callerStack()})
+	return &closedError{fmt.Sprintf("%s used after its %s was closed", kind, parent)}
+}
+
+// checkDatabase checks that the database of the connection is open.
+func (conn *Connection) checkDatabase() error {
+	if conn.database == nil {
+		return nil
+	}
+	return checkParent(HandleConnection, HandleDatabase, conn.database.closed)
+}
+
+// checkConnection checks that the connection of a handle of the kind is open.
+// closeMutex of the connection must be held.
+func (conn *Connection) checkConnection(kind HandleKind) error {
+	return checkParent(kind, HandleConnection, func() bool { return conn.isClosed })
+}
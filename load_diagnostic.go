@@ -0,0 +1,233 @@
+package lbug
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultCollectDiagnostics is the number of diagnostics retained by
+// CopyFromWithWarnings when CopyOptions.CollectDiagnostics is not set.
+const defaultCollectDiagnostics = 100
+
+// LoadDiagnosticKind is the category of a problem reported by the CSV, JSON
+// or Parquet loader of the engine.
+type LoadDiagnosticKind int
+
+const (
+	// LoadDiagnosticUnknown is a problem whose message has a format the
+	// bindings do not recognize. LoadDiagnostic.Raw holds the message.
+	LoadDiagnosticUnknown LoadDiagnosticKind = iota
+	// LoadDiagnosticConversion is a value that cannot be converted to the
+	// type of its column.
+	LoadDiagnosticConversion
+	// LoadDiagnosticColumnCount is a line with more or fewer values than the
+	// table has columns.
+	LoadDiagnosticColumnCount
+	// LoadDiagnosticQuoting is a line with unterminated or misplaced quotes.
+	LoadDiagnosticQuoting
+	// LoadDiagnosticConstraint is a value violating a constraint of the
+	// table, e.g. a duplicated or NULL primary key.
+	LoadDiagnosticConstraint
+)
+
+func (kind LoadDiagnosticKind) String() string {
+	switch kind {
+	case LoadDiagnosticUnknown:
+		return "Unknown"
+	case LoadDiagnosticConversion:
+		return "Conversion"
+	case LoadDiagnosticColumnCount:
+		return "ColumnCount"
+	case LoadDiagnosticQuoting:
+		return "Quoting"
+	case LoadDiagnosticConstraint:
+		return "Constraint"
+	default:
+		return fmt.Sprintf("LoadDiagnosticKind(%d)", int(kind))
+	}
+}
+
+// LoadDiagnostic is a problem with a line of a file loaded with COPY FROM,
+// parsed from an error or warning message of the engine.
+type LoadDiagnostic struct {
+	// File is the path of the file, if the engine reported it.
+	File string
+	// Line is the number of the line or record, starting at 1, or zero if the
+	// engine did not report it.
+	Line uint64
+	// Column is the number of the column, starting at 1, or zero if the
+	// engine did not report it.
+	Column int
+	// Message describes the problem, without the file and line.
+	Message string
+	// Kind is the category of the problem.
+	Kind LoadDiagnosticKind
+	// Record is the line or record containing the problem, if the engine
+	// reported it, e.g. to write it to a dead-letter file.
+	Record string
+	// Raw is the message of the engine, verbatim.
+	Raw string
+}
+
+// LoadError is returned by CopyFrom and CopyFromWithWarnings when the COPY
+// fails because of the content of the file. It wraps the error of the COPY,
+// which remains in the chain, e.g. an *Error or a *CopyRetryError.
+type LoadError struct {
+	// Diagnostics are the problems reported by the engine, at most
+	// CopyOptions.CollectDiagnostics of them.
+	Diagnostics []LoadDiagnostic
+	Err         error
+}
+
+func (err *LoadError) Error() string {
+	return err.Err.Error()
+}
+
+func (err *LoadError) Unwrap() error {
+	return err.Err
+}
+
+var (
+	// loadMessagePrefix is the prefix of the messages of the errors of COPY.
+	loadMessagePrefix = "Copy exception: "
+	// loadLocationPattern matches the location the engine puts before the
+	// messages of the loader.
+	loadLocationPattern = regexp.MustCompile(`(?s)^Error in file (.+?) on (?:line|row|record) (\d+)(?:,? column (\d+))?: (.*)$`)
+	// loadRecordPattern matches the line or record the engine appends to the
+	// messages of the loader.
+	loadRecordPattern = regexp.MustCompile(`(?s)^(.*?)\s*Line/record containing the error: '(.*)'\s*$`)
+	// loadWarningCountPattern matches the number of warnings in the result of
+	// a COPY run with IGNORE_ERRORS.
+	loadWarningCountPattern = regexp.MustCompile(`(\d+) warnings? encountered`)
+)
+
+// loadDiagnosticKinds are the fragments, in lower case, of the messages of the
+// loader classified as each kind. They are checked in order.
+var loadDiagnosticKinds = []struct {
+	kind      LoadDiagnosticKind
+	fragments []string
+}{
+	{LoadDiagnosticConstraint, []string{"primary key", "violates", "constraint"}},
+	{LoadDiagnosticColumnCount, []string{"values per row", "number of columns", "too many values", "too few values"}},
+	{LoadDiagnosticQuoting, []string{"quote"}},
+	{LoadDiagnosticConversion, []string{"conversion exception", "could not convert", "cast failed", "overflow", "invalid input"}},
+}
+
+// parseLoadDiagnostic parses the error message of a COPY and returns false if
+// it is not an error of the loader. Messages of the loader with an unknown
+// format are returned with the kind LoadDiagnosticUnknown.
+func parseLoadDiagnostic(raw string) (LoadDiagnostic, bool) {
+	diagnostic := LoadDiagnostic{Raw: raw}
+	message, isCopy := strings.CutPrefix(raw, loadMessagePrefix)
+	if match := loadLocationPattern.FindStringSubmatch(message); match != nil {
+		diagnostic.File = match[1]
+		diagnostic.Line, _ = strconv.ParseUint(match[2], 10, 64)
+		diagnostic.Column, _ = strconv.Atoi(match[3])
+		message = match[4]
+	} else if !isCopy {
+		return LoadDiagnostic{}, false
+	}
+	if match := loadRecordPattern.FindStringSubmatch(message); match != nil {
+		message = match[1]
+		diagnostic.Record = match[2]
+	}
+	diagnostic.Message = strings.TrimSpace(message)
+	diagnostic.Kind = loadDiagnosticKind(diagnostic.Message)
+	return diagnostic, true
+}
+
+// loadDiagnosticKind classifies the message of the loader.
+func loadDiagnosticKind(message string) LoadDiagnosticKind {
+	message = strings.ToLower(message)
+	for _, kind := range loadDiagnosticKinds {
+		for _, fragment := range kind.fragments {
+			if strings.Contains(message, fragment) {
+				return kind.kind
+			}
+		}
+	}
+	return LoadDiagnosticUnknown
+}
+
+// loadError returns a *LoadError wrapping the error of a COPY if the engine
+// failed it because of the content of the file, or the error otherwise.
+func loadError(err error) error {
+	var engineErr *Error
+	if !errors.As(err, &engineErr) {
+		return err
+	}
+	diagnostic, ok := parseLoadDiagnostic(engineErr.Message)
+	if !ok {
+		return err
+	}
+	return &LoadError{Diagnostics: []LoadDiagnostic{diagnostic}, Err: err}
+}
+
+// Warnings returns the problems the loader of the engine skipped while
+// running the COPY FROM of the QueryResult with IGNORE_ERRORS, e.g. the
+// lines that failed to be parsed. It returns nil if the QueryResult is not
+// the result of such a COPY or if no problem was skipped. The warnings are
+// read with CALL show_warnings() on the connection of the QueryResult, so
+// they must be read before another COPY runs on it; the engine retains a
+// limited number of them.
+func (queryResult *QueryResult) Warnings() ([]LoadDiagnostic, error) {
+	if !queryResult.hasLoadWarnings() {
+		return nil, nil
+	}
+	return readLoadWarnings(queryResult.connection, 0)
+}
+
+// hasLoadWarnings returns true if the QueryResult is the result of a COPY
+// that skipped problems.
+func (queryResult *QueryResult) hasLoadWarnings() bool {
+	match := loadWarningCountPattern.FindStringSubmatch(queryResult.ToString())
+	return match != nil && match[1] != "0"
+}
+
+// readLoadWarnings returns up to limit warnings of the last query of the
+// connection that reported warnings, or all of them if limit is zero.
+func readLoadWarnings(conn *Connection, limit int) ([]LoadDiagnostic, error) {
+	rows, err := queryRows(conn, "CALL show_warnings() RETURN *;")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the warnings of the copy: %w", err)
+	}
+	// The warnings of earlier queries are kept as well.
+	var lastQuery uint64
+	for _, row := range rows {
+		lastQuery = max(lastQuery, loadWarningUint(row["query_id"]))
+	}
+	var warnings []LoadDiagnostic
+	for _, row := range rows {
+		if loadWarningUint(row["query_id"]) != lastQuery {
+			continue
+		}
+		if limit > 0 && len(warnings) == limit {
+			break
+		}
+		message, _ := row["message"].(string)
+		warning := LoadDiagnostic{Line: loadWarningUint(row["line_number"]), Message: message, Raw: message}
+		warning.File, _ = row["file_path"].(string)
+		warning.Record, _ = row["skipped_line_or_record"].(string)
+		warning.Kind = loadDiagnosticKind(message)
+		warnings = append(warnings, warning)
+	}
+	return warnings, nil
+}
+
+// loadWarningUint returns the integer in a column of show_warnings.
+func loadWarningUint(value any) uint64 {
+	switch v := value.(type) {
+	case uint64:
+		return v
+	case int64:
+		return uint64(max(v, 0))
+	case uint32:
+		return uint64(v)
+	case int32:
+		return uint64(max(v, 0))
+	}
+	return 0
+}
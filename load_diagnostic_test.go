@@ -0,0 +1,117 @@
+package lbug
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLoadDiagnostic(t *testing.T) {
+	for _, test := range []struct {
+		raw      string
+		expected LoadDiagnostic
+	}{
+		{
+			`Copy exception: Error in file /data/person.csv on line 3: Conversion exception: Cast failed. Could not convert "abc" to INT64. Line/record containing the error: 'abc,Alice'`,
+			LoadDiagnostic{File: "/data/person.csv", Line: 3, Message: `Conversion exception: Cast failed. Could not convert "abc" to INT64.`, Kind: LoadDiagnosticConversion, Record: "abc,Alice"},
+		},
+		{
+			"Copy exception: Error in file person.csv on line 2: expected 2 values per row, but got more. Line/record containing the error: '1,a,b'",
+			LoadDiagnostic{File: "person.csv", Line: 2, Message: "expected 2 values per row, but got more.", Kind: LoadDiagnosticColumnCount, Record: "1,a,b"},
+		},
+		{
+			"Copy exception: Error in file person.csv on line 7, column 2: unterminated quotes.",
+			LoadDiagnostic{File: "person.csv", Line: 7, Column: 2, Message: "unterminated quotes.", Kind: LoadDiagnosticQuoting},
+		},
+		{
+			"Copy exception: Error in file /data/my file.parquet on row 12: Overflow exception: Value 300 is not within INT8 range.",
+			LoadDiagnostic{File: "/data/my file.parquet", Line: 12, Message: "Overflow exception: Value 300 is not within INT8 range.", Kind: LoadDiagnosticConversion},
+		},
+		{
+			"Copy exception: Found duplicated primary key value 1, which violates the uniqueness constraint of the primary key column.",
+			LoadDiagnostic{Message: "Found duplicated primary key value 1, which violates the uniqueness constraint of the primary key column.", Kind: LoadDiagnosticConstraint},
+		},
+		{
+			"Copy exception: Found NULL, which violates the non-null constraint of the primary key column.",
+			LoadDiagnostic{Message: "Found NULL, which violates the non-null constraint of the primary key column.", Kind: LoadDiagnosticConstraint},
+		},
+		// A format the bindings do not know keeps the raw message.
+		{
+			"Copy exception: the loader failed in a new way",
+			LoadDiagnostic{Message: "the loader failed in a new way", Kind: LoadDiagnosticUnknown},
+		},
+	} {
+		test.expected.Raw = test.raw
+		diagnostic, ok := parseLoadDiagnostic(test.raw)
+		assert.True(t, ok, test.raw)
+		assert.Equal(t, test.expected, diagnostic, test.raw)
+	}
+
+	for _, raw := range []string{
+		"Binder exception: Table person does not exist.",
+		"IO exception: Cannot open file missing.csv: No such file or directory",
+		"Parser exception: Invalid input <COPY person FROM>",
+	} {
+		_, ok := parseLoadDiagnostic(raw)
+		assert.False(t, ok, raw)
+	}
+}
+
+func TestLoadError(t *testing.T) {
+	engineErr := &Error{Op: OpExecute, Message: "Copy exception: Error in file a.csv on line 2: unterminated quotes."}
+	err := loadError(&CopyRetryError{Attempts: 2, Err: engineErr})
+	var loadErr *LoadError
+	assert.ErrorAs(t, err, &loadErr)
+	assert.Equal(t, []LoadDiagnostic{{File: "a.csv", Line: 2, Message: "unterminated quotes.", Kind: LoadDiagnosticQuoting, Raw: engineErr.Message}}, loadErr.Diagnostics)
+	var retryErr *CopyRetryError
+	assert.ErrorAs(t, err, &retryErr)
+	assert.Equal(t, retryErr.Error(), err.Error())
+
+	other := &Error{Op: OpExecute, Message: "Binder exception: Table x does not exist."}
+	assert.Equal(t, error(other), loadError(other))
+	plain := errors.New("failed")
+	assert.Equal(t, plain, loadError(plain))
+	assert.Equal(t, "Constraint", LoadDiagnosticConstraint.String())
+	assert.Equal(t, "LoadDiagnosticKind(9)", LoadDiagnosticKind(9).String())
+}
+
+func TestCopyFromDiagnostics(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE item(id INT64, name STRING, PRIMARY KEY(id));")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "items.csv")
+	assert.Nil(t, os.WriteFile(path, []byte("id,name\n1,a\nx,b\n3,c\n4,d,e\n"), 0o644))
+
+	err := CopyFrom(conn, "item", path, CopyOptions{Options: map[string]any{"HEADER": true}})
+	var loadErr *LoadError
+	assert.ErrorAs(t, err, &loadErr)
+	assert.Len(t, loadErr.Diagnostics, 1)
+	assert.Equal(t, LoadDiagnosticConversion, loadErr.Diagnostics[0].Kind)
+	assert.Equal(t, path, loadErr.Diagnostics[0].File)
+	assert.NotEmpty(t, loadErr.Diagnostics[0].Raw)
+	var engineErr *Error
+	assert.ErrorAs(t, err, &engineErr)
+
+	opts := CopyOptions{Options: map[string]any{"HEADER": true, "IGNORE_ERRORS": true}, CollectDiagnostics: 1}
+	warnings, err := CopyFromWithWarnings(conn, "item", path, opts)
+	assert.Nil(t, err)
+	assert.Len(t, warnings, 1)
+	assert.Equal(t, path, warnings[0].File)
+	assert.True(t, warnings[0].Line > 0)
+	assert.Equal(t, LoadDiagnosticConversion, warnings[0].Kind)
+
+	mustRun(t, conn, "MATCH (i:item) DELETE i;")
+	result, err := conn.Query("COPY item FROM '" + path + "' (HEADER=true, IGNORE_ERRORS=true);")
+	assert.Nil(t, err)
+	defer result.Close()
+	warnings, err = result.Warnings()
+	assert.Nil(t, err)
+	assert.Len(t, warnings, 2)
+	assert.Equal(t, LoadDiagnosticColumnCount, warnings[1].Kind)
+
+	_, err = CopyFromWithWarnings(conn, "item", path, CopyOptions{CollectDiagnostics: -1})
+	assert.ErrorContains(t, err, "invalid number of diagnostics -1")
+}
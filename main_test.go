@@ -0,0 +1,55 @@
+package lbug
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+// leakCheckedKinds are the kinds of the handles that the tests must close:
+// the databases and connections shared by the tests stay open.
+var leakCheckedKinds = []HandleKind{HandlePreparedStatement, HandleQueryResult, HandleFlatTuple}
+
+// TestMain runs the tests with handle tracking enabled, and fails if they
+// leave statements, results or tuples open.
+func TestMain(m *testing.M) {
+	SetHandleTracking(true)
+	code := m.Run()
+	if code == 0 && reportLeakedHandles() {
+		code = 1
+	}
+	os.Exit(code)
+}
+
+// reportLeakedHandles writes the statements, results and tuples still open to
+// standard error, with the creation stacks of those created while tracking
+// was enabled, and returns true if there are any.
+func reportLeakedHandles() bool {
+	// The calls abandoned by their context close their handles in the
+	// background.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := background.wait(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "lbug: background tasks still running after the tests: %v\n", err)
+	}
+	counts := OpenHandleCounts()
+	leaked := false
+	for _, kind := range leakCheckedKinds {
+		if counts[kind] > 0 {
+			fmt.Fprintf(os.Stderr, "lbug: %d %v handles left open by the tests\n", counts[kind], kind)
+			leaked = true
+		}
+	}
+	if !leaked {
+		return false
+	}
+	for _, info := range OpenHandles() {
+		if slices.Contains(leakCheckedKinds, info.Kind) {
+			fmt.Fprintf(os.Stderr, "%v %d created at:\n%s", info.Kind, info.ID, info.Stack)
+		}
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+package lbug
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultMaterializeKey is the name of the synthetic primary key column of
+// the tables created by MaterializeQuery when MaterializeOptions.PrimaryKey
+// is not set.
+const defaultMaterializeKey = "id"
+
+// MaterializeOptions configures Connection.MaterializeQuery.
+type MaterializeOptions struct {
+	// PrimaryKey is the column of the result that becomes the primary key of
+	// the table. If it is empty, a SERIAL column named SyntheticKey is added
+	// as the primary key.
+	PrimaryKey string
+	// SyntheticKey is the name of the SERIAL primary key column added when
+	// PrimaryKey is empty. If it is empty, "id" is used.
+	SyntheticKey string
+	// ColumnTypes are the Cypher types of columns of the result by column
+	// name, e.g. "STRING[]" or "DECIMAL(18, 3)". They override the types
+	// inferred from the result and are required for the columns of nested
+	// and DECIMAL types, whose parameters the C API does not expose.
+	ColumnTypes map[string]string
+	// BatchSize is the number of rows inserted per statement. If it is zero,
+	// 1000 is used.
+	BatchSize int
+}
+
+// MaterializeQuery executes the query with the parameters and stores its
+// result in a new node table with one column per column of the result, and
+// returns the number of rows stored. If params is empty, the query is
+// executed directly, otherwise it is prepared and executed with params.
+//
+// The engine cannot create a table from the result of a query, so the types
+// of the columns are inferred from the result with
+// QueryResult.GetColumnDataTypes, the table is created, and the rows are
+// inserted in batches with UNWIND inside a single transaction, so
+// MaterializeQuery must not be called while a transaction of the connection
+// is open. If inserting the rows fails, the transaction is rolled back and
+// the table is dropped. Columns of the types NODE, REL and RECURSIVE_REL
+// cannot be stored in a table; they fail before the table is created, with an
+// error listing them, and should be replaced by their properties in the
+// query.
+func (conn *Connection) MaterializeQuery(table string, query string, params map[string]any, opts MaterializeOptions) (uint64, error) {
+	if opts.BatchSize < 0 {
+		return 0, fmt.Errorf("invalid batch size %d: must not be negative", opts.BatchSize)
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultCopyBatchSize
+	}
+	var result *QueryResult
+	var err error
+	if len(params) == 0 {
+		result, err = conn.Query(query)
+	} else {
+		var statement *PreparedStatement
+		statement, err = conn.Prepare(query)
+		defer statement.Close()
+		if err == nil {
+			result, err = conn.Execute(statement, params)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer result.Close()
+	// The rows are inserted from the result, not read by a query.
+	result.maxRows = 0
+	result.autoClose = false
+
+	schema, err := materializedSchema(table, result.GetColumnNames(), result.GetColumnDataTypes(), &opts)
+	if err != nil {
+		return 0, fmt.Errorf("failed to materialize query into table %s: %w", table, err)
+	}
+	if err := runStatement(conn, schema.createTableStatement()); err != nil {
+		return 0, fmt.Errorf("failed to create table %s: %w", table, err)
+	}
+	columns := schema.columns
+	if opts.PrimaryKey == "" {
+		columns = columns[1:]
+	}
+	job := &copyJob{
+		table:   table,
+		columns: columns,
+		insertQuery: func(present []bool) string {
+			return fmt.Sprintf("UNWIND $rows AS row CREATE (n:%s%s);",
+				QuoteIdentifier(table), copyProperties(columns, present, 0))
+		},
+	}
+	copyOptions := CopyTablesOptions{BatchSize: opts.BatchSize}
+	copied := uint64(0)
+	err = runStatement(conn, "BEGIN TRANSACTION;")
+	if err == nil {
+		err = copyResult(result, conn, job, &copyOptions, &copied)
+		if err != nil {
+			runStatement(conn, "ROLLBACK;")
+		} else {
+			err = runStatement(conn, "COMMIT;")
+		}
+	}
+	if err != nil {
+		runStatement(conn, "DROP TABLE "+QuoteIdentifier(schema.name)+";")
+		return 0, fmt.Errorf("failed to materialize query into table %s: %w", table, err)
+	}
+	return copied, nil
+}
+
+// materializedSchema returns the schema of the table storing a result with
+// the columns and types. The synthetic primary key, if any, is the first
+// column.
+func materializedSchema(table string, names []string, types []DataType, opts *MaterializeOptions) (*tableSchema, error) {
+	schema := &tableSchema{name: table}
+	key := opts.PrimaryKey
+	if key == "" {
+		key = opts.SyntheticKey
+		if key == "" {
+			key = defaultMaterializeKey
+		}
+		for _, name := range names {
+			if name == key {
+				return nil, fmt.Errorf("the result has a column %s, which is the name of the synthetic primary key", key)
+			}
+		}
+		schema.columns = append(schema.columns, tableColumn{name: key, dataType: "SERIAL", primaryKey: true})
+	}
+	var unsupported, unknown []string
+	for i, name := range names {
+		dataType, ok := opts.ColumnTypes[name]
+		if !ok {
+			dataType, ok = materializedType(types[i])
+		}
+		if !ok {
+			offender := fmt.Sprintf("%s (%s)", name, types[i].Name)
+			switch types[i].Name {
+			case "NODE", "REL", "RECURSIVE_REL":
+				unsupported = append(unsupported, offender)
+			default:
+				unknown = append(unknown, offender)
+			}
+			continue
+		}
+		if _, exists := schema.column(name); exists {
+			return nil, fmt.Errorf("the result has more than one column %s", name)
+		}
+		schema.columns = append(schema.columns, tableColumn{name: name, dataType: dataType, primaryKey: name == opts.PrimaryKey})
+	}
+	if len(unsupported) > 0 {
+		return nil, fmt.Errorf("columns %s cannot be stored in a table", strings.Join(unsupported, ", "))
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("the types of columns %s cannot be inferred and must be set in MaterializeOptions.ColumnTypes", strings.Join(unknown, ", "))
+	}
+	for name := range opts.ColumnTypes {
+		if _, ok := schema.column(name); !ok {
+			return nil, fmt.Errorf("the result has no column %s of MaterializeOptions.ColumnTypes", name)
+		}
+	}
+	if _, ok := schema.primaryKey(); !ok {
+		return nil, fmt.Errorf("primary key %s is not a column of the result", key)
+	}
+	return schema, nil
+}
+
+// materializedType returns the Cypher type of a table column storing values
+// of the logical type, and false if it cannot be inferred from the type id
+// alone or the values cannot be stored.
+func materializedType(dataType DataType) (string, bool) {
+	switch dataType.Name {
+	case "SERIAL":
+		return "INT64", true
+	case "ANY", "NODE", "REL", "RECURSIVE_REL", "INTERNAL_ID", "POINTER",
+		"DECIMAL", "LIST", "ARRAY", "STRUCT", "MAP", "UNION":
+		return "", false
+	}
+	return dataType.Name, dataType.isKnown()
+}
@@ -0,0 +1,83 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaterializedSchema(t *testing.T) {
+	names := []string{"name", "age", "tags"}
+	types := []DataType{{TypeID: 50, Name: "STRING"}, {TypeID: 13, Name: "SERIAL"}, {TypeID: 52, Name: "LIST"}}
+	opts := &MaterializeOptions{ColumnTypes: map[string]string{"tags": "STRING[]"}}
+	schema, err := materializedSchema("adult", names, types, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "CREATE NODE TABLE `adult`(`id` SERIAL, `name` STRING, `age` INT64, `tags` STRING[], PRIMARY KEY(`id`));", schema.createTableStatement())
+
+	opts = &MaterializeOptions{PrimaryKey: "name", ColumnTypes: map[string]string{"tags": "STRING[]"}}
+	schema, err = materializedSchema("adult", names, types, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "CREATE NODE TABLE `adult`(`name` STRING, `age` INT64, `tags` STRING[], PRIMARY KEY(`name`));", schema.createTableStatement())
+
+	_, err = materializedSchema("adult", names, types, &MaterializeOptions{})
+	assert.ErrorContains(t, err, "the types of columns tags (LIST) cannot be inferred and must be set in MaterializeOptions.ColumnTypes")
+	_, err = materializedSchema("adult", names, types, &MaterializeOptions{PrimaryKey: "nickname", ColumnTypes: opts.ColumnTypes})
+	assert.ErrorContains(t, err, "primary key nickname is not a column of the result")
+	_, err = materializedSchema("adult", names, types, &MaterializeOptions{SyntheticKey: "age"})
+	assert.ErrorContains(t, err, "the result has a column age, which is the name of the synthetic primary key")
+	_, err = materializedSchema("adult", names, types, &MaterializeOptions{ColumnTypes: map[string]string{"tags": "STRING[]", "other": "INT64"}})
+	assert.ErrorContains(t, err, "the result has no column other of MaterializeOptions.ColumnTypes")
+
+	graphTypes := []DataType{{TypeID: 10, Name: "NODE"}, {TypeID: 50, Name: "STRING"}, {TypeID: 11, Name: "REL"}}
+	_, err = materializedSchema("adult", []string{"a", "name", "k"}, graphTypes, &MaterializeOptions{})
+	assert.ErrorContains(t, err, "columns a (NODE), k (REL) cannot be stored in a table")
+}
+
+func TestMaterializeQuery(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	count, err := conn.MaterializeQuery("adult",
+		"MATCH (a:person) WHERE a.age > $age RETURN a.fName AS name, a.age AS age, a.isStudent AS student;",
+		map[string]any{"age": int64(30)}, MaterializeOptions{BatchSize: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), count)
+	result, err := conn.Query("MATCH (a:adult) RETURN a.id, a.name, a.age, a.student ORDER BY a.name;")
+	assert.Nil(t, err)
+	defer result.Close()
+	var rows [][]any
+	for result.HasNext() {
+		tuple, err := result.Next()
+		assert.Nil(t, err)
+		row, err := tuple.GetAsSlice()
+		assert.Nil(t, err)
+		tuple.Close()
+		rows = append(rows, row[1:])
+	}
+	assert.Equal(t, [][]any{
+		{"Alice", int64(35), true},
+		{"Carol", int64(45), false},
+		{"Hubert Blaine Wolfeschlegelsteinhausenbergerdorff", int64(83), false},
+	}, rows)
+
+	count, err = conn.MaterializeQuery("names", "MATCH (a:person) RETURN a.fName AS name, a.workedHours AS hours;", nil,
+		MaterializeOptions{PrimaryKey: "name", ColumnTypes: map[string]string{"hours": "INT64[]"}})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(8), count)
+}
+
+func TestMaterializeQueryErrors(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	_, err := conn.MaterializeQuery("pairs", "MATCH (a:person)-[k:knows]->(b:person) RETURN a, k, b.fName;", nil, MaterializeOptions{})
+	assert.ErrorContains(t, err, "columns a (NODE), k (REL) cannot be stored in a table")
+	conn.InvalidateSchemaCache()
+	_, err = conn.ResolveTableName("pairs")
+	var noSuchTable *NoSuchTableError
+	assert.ErrorAs(t, err, &noSuchTable)
+
+	// A primary key with duplicate values fails the insert, which drops the
+	// table.
+	_, err = conn.MaterializeQuery("genders", "MATCH (a:person) RETURN a.gender AS gender;", nil, MaterializeOptions{PrimaryKey: "gender"})
+	assert.NotNil(t, err)
+	conn.InvalidateSchemaCache()
+	_, err = conn.ResolveTableName("genders")
+	assert.ErrorAs(t, err, &noSuchTable)
+}
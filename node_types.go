@@ -0,0 +1,167 @@
+package lbug
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// nodeTypes maps the lower-cased labels registered with RegisterNodeType to
+// their Go types.
+var nodeTypes struct {
+	sync.RWMutex
+	types map[string]reflect.Type
+}
+
+// RegisterNodeType registers the Go type of the nodes of a node table, given
+// by a prototype value of a struct type or of a pointer to a struct type, e.g.
+// RegisterNodeType("Person", PersonNode{}). Collect and CollectNodes decode the
+// nodes with the label, compared case-insensitively, into values of the type
+// when they are decoded into an interface, e.g. a field of type any or an
+// element of a []any, instead of into a Node; nodes with labels that are not
+// registered are still decoded into a Node. The properties of a node are
+// decoded into the fields of the same names, or whose lbug tags are the names,
+// as for a STRUCT value, and the fields tagged `lbug:"_id"` and `lbug:"_label"`
+// are set to the ID and the label of the node.
+//
+// RegisterNodeType is typically called at package initialization. It panics if
+// the prototype is not a struct or if the label is registered already with
+// another type.
+func RegisterNodeType(label string, prototype any) {
+	nodeType := reflect.TypeOf(prototype)
+	structType := nodeType
+	if structType != nil && structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	if structType == nil || structType.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("lbug: node type of label %s is %T, not a struct", label, prototype))
+	}
+	key := strings.ToLower(label)
+	nodeTypes.Lock()
+	defer nodeTypes.Unlock()
+	if registered, ok := nodeTypes.types[key]; ok && registered != nodeType {
+		panic(fmt.Sprintf("lbug: label %s is registered already with node type %s", label, registered))
+	}
+	if nodeTypes.types == nil {
+		nodeTypes.types = make(map[string]reflect.Type)
+	}
+	nodeTypes.types[key] = nodeType
+}
+
+// registeredNodeType returns the type registered for the label, if any.
+func registeredNodeType(label string) (reflect.Type, bool) {
+	nodeTypes.RLock()
+	defer nodeTypes.RUnlock()
+	nodeType, ok := nodeTypes.types[strings.ToLower(label)]
+	return nodeType, ok
+}
+
+// typedNode returns the node decoded into the type registered for its label,
+// or the node itself if its label is not registered.
+func typedNode(node Node, opts CollectOptions) (reflect.Value, error) {
+	nodeType, ok := registeredNodeType(node.Label)
+	if !ok {
+		return reflect.ValueOf(node), nil
+	}
+	structType := nodeType
+	if nodeType.Kind() == reflect.Pointer {
+		structType = nodeType.Elem()
+	}
+	typed := reflect.New(structType)
+	if err := assignStruct(typed.Elem(), nodeFields(node), opts); err != nil {
+		return reflect.Value{}, fmt.Errorf("node %s: %w", node.Label, err)
+	}
+	if nodeType.Kind() == reflect.Pointer {
+		return typed, nil
+	}
+	return typed.Elem(), nil
+}
+
+// nodeFields returns the properties of the node with its ID and label, as
+// the fields of a STRUCT value decoded into a struct.
+func nodeFields(node Node) map[string]any {
+	fields := make(map[string]any, len(node.Properties)+2)
+	for name, value := range node.Properties {
+		fields[name] = value
+	}
+	fields["_id"] = node.ID
+	fields["_label"] = node.Label
+	return fields
+}
+
+// relationshipFields returns the properties of the relationship with its ID,
+// label and the IDs of its source and destination nodes, as the fields of a
+// STRUCT value decoded into a struct.
+func relationshipFields(rel Relationship) map[string]any {
+	fields := make(map[string]any, len(rel.Properties)+4)
+	for name, value := range rel.Properties {
+		fields[name] = value
+	}
+	fields["_id"] = rel.ID
+	fields["_label"] = rel.Label
+	fields["_src"] = rel.SourceID
+	fields["_dst"] = rel.DestinationID
+	return fields
+}
+
+// CollectNodes decodes the NODE values of the column of the remaining rows of
+// the QueryResult into values of the type T, typically an interface
+// implemented by the types registered with RegisterNodeType: every node is
+// decoded into the type registered for its label, or into a Node if its label
+// is not registered. It returns an error if a decoded node is not a T, or if
+// the column has values that are not nodes. NULL values are decoded into the
+// zero value of T.
+func CollectNodes[T any](queryResult *QueryResult, index uint64) ([]T, error) {
+	var nodes []T
+	for queryResult.HasNext() {
+		tuple, err := queryResult.Next()
+		if err != nil {
+			return nodes, err
+		}
+		value, err := tuple.GetValue(index)
+		tuple.Close()
+		if err != nil {
+			return nodes, err
+		}
+		var node T
+		if value != nil {
+			if _, ok := value.(Node); !ok {
+				return nodes, conversionError(index, fmt.Errorf("row %d: value is a %T, not a node", len(nodes), value))
+			}
+			if err := assignValue(reflect.ValueOf(&node).Elem(), value, CollectOptions{}); err != nil {
+				return nodes, conversionError(index, fmt.Errorf("row %d: %w", len(nodes), err))
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// GroupNodesByLabel reads the NODE values of the column of the remaining rows
+// of the QueryResult, and returns them grouped by label, in the order of the
+// rows. NULL values are skipped. It returns an error if the column has values
+// that are not nodes.
+func GroupNodesByLabel(queryResult *QueryResult, index uint64) (map[string][]Node, error) {
+	groups := make(map[string][]Node)
+	for row := 0; queryResult.HasNext(); row++ {
+		tuple, err := queryResult.Next()
+		if err != nil {
+			return groups, err
+		}
+		value, err := tuple.GetValue(index)
+		tuple.Close()
+		if err != nil {
+			return groups, err
+		}
+		if value == nil {
+			continue
+		}
+		node, ok := value.(Node)
+		if !ok {
+			return groups, conversionError(index, fmt.Errorf("row %d: value is a %T, not a node", row, value))
+		}
+		groups[node.Label] = append(groups[node.Label], node)
+	}
+	return groups, nil
+}
@@ -0,0 +1,178 @@
+package lbug
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type entity interface {
+	entityName() string
+}
+
+type personNode struct {
+	ID    InternalID `lbug:"_id"`
+	Label string     `lbug:"_label"`
+	Name  string     `lbug:"name"`
+	Age   int        `lbug:"age"`
+}
+
+func (person personNode) entityName() string { return person.Name }
+
+type companyNode struct {
+	Name string `lbug:"name"`
+}
+
+func (company *companyNode) entityName() string { return company.Name }
+
+// registerTestNodeTypes registers the node types of the tables of
+// openEntityTestConnection until the end of the test.
+func registerTestNodeTypes(t *testing.T) {
+	RegisterNodeType("Human", personNode{})
+	RegisterNodeType("Company", &companyNode{})
+	t.Cleanup(func() {
+		nodeTypes.Lock()
+		delete(nodeTypes.types, "human")
+		delete(nodeTypes.types, "company")
+		nodeTypes.Unlock()
+	})
+}
+
+func TestRegisterNodeType(t *testing.T) {
+	registerTestNodeTypes(t)
+	nodeType, ok := registeredNodeType("HUMAN")
+	assert.True(t, ok)
+	assert.Equal(t, reflect.TypeOf(personNode{}), nodeType)
+	// Registering the same type again is allowed.
+	RegisterNodeType("human", personNode{})
+	assert.Panics(t, func() { RegisterNodeType("Human", companyNode{}) })
+	assert.Panics(t, func() { RegisterNodeType("Number", 1) })
+	assert.Panics(t, func() { RegisterNodeType("Nothing", nil) })
+}
+
+func TestTypedNode(t *testing.T) {
+	registerTestNodeTypes(t)
+	id := InternalID{TableID: 1, Offset: 2}
+	typed, err := typedNode(Node{ID: id, Label: "Human", Properties: map[string]any{"name": "Alice", "age": int64(35)}}, CollectOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, personNode{ID: id, Label: "Human", Name: "Alice", Age: 35}, typed.Interface())
+	typed, err = typedNode(Node{Label: "Company", Properties: map[string]any{"name": "Acme"}}, CollectOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, &companyNode{Name: "Acme"}, typed.Interface())
+	typed, err = typedNode(Node{Label: "Robot"}, CollectOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, Node{Label: "Robot"}, typed.Interface())
+	_, err = typedNode(Node{Label: "Human", Properties: map[string]any{"age": "old"}}, CollectOptions{})
+	assert.ErrorContains(t, err, "node Human: field age: cannot assign string to int")
+
+	// Nodes decoded into interfaces take their registered types, and are
+	// checked against the interface.
+	var entities []entity
+	assert.Nil(t, assignValue(reflect.ValueOf(&entities).Elem(), []any{
+		Node{Label: "Human", Properties: map[string]any{"name": "Bob"}},
+		Node{Label: "Company", Properties: map[string]any{"name": "Acme"}},
+	}, CollectOptions{}))
+	assert.Equal(t, "Bob", entities[0].entityName())
+	assert.Equal(t, "Acme", entities[1].entityName())
+	var one entity
+	assert.ErrorContains(t, assignValue(reflect.ValueOf(&one).Elem(), Node{Label: "Robot"}, CollectOptions{}), "cannot assign lbug.Node of node Robot to lbug.entity")
+	assert.True(t, canHold(reflect.TypeOf(&one).Elem(), DataType{Name: "NODE"}, false))
+	assert.False(t, canHold(reflect.TypeOf(&one).Elem(), DataType{Name: "STRING"}, false))
+}
+
+// openEntityTestConnection returns a connection to a database with the node
+// tables Human and Company.
+func openEntityTestConnection(t *testing.T) *Connection {
+	_, conn := openTempTableTestConnection(t)
+	for _, query := range []string{
+		"CREATE NODE TABLE Human(name STRING, age INT64, PRIMARY KEY(name));",
+		"CREATE NODE TABLE Company(name STRING, PRIMARY KEY(name));",
+		"CREATE (:Human {name: 'Alice', age: 35}), (:Human {name: 'Bob', age: 30}), (:Company {name: 'Acme'});",
+	} {
+		mustRun(t, conn, query)
+	}
+	return conn
+}
+
+const entitiesQuery = "MATCH (h:Human) RETURN h AS n, h.name AS name UNION ALL MATCH (c:Company) RETURN c AS n, c.name AS name;"
+
+func TestGroupNodesByLabel(t *testing.T) {
+	conn := openEntityTestConnection(t)
+	result, err := conn.Query(entitiesQuery)
+	assert.Nil(t, err)
+	defer result.Close()
+	groups, err := GroupNodesByLabel(result, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(groups))
+	assert.Equal(t, 2, len(groups["Human"]))
+	assert.Equal(t, 1, len(groups["Company"]))
+	assert.Equal(t, "Acme", groups["Company"][0].Properties["name"])
+	assert.NotEqual(t, groups["Human"][0].ID.TableID, groups["Company"][0].ID.TableID)
+
+	result, err = conn.Query("MATCH (n:Human:Company) RETURN n.name, n;")
+	assert.Nil(t, err)
+	defer result.Close()
+	_, err = GroupNodesByLabel(result, 0)
+	assert.ErrorContains(t, err, "value is a string, not a node")
+	groups, err = GroupNodesByLabel(result, 1)
+	assert.Nil(t, err)
+	// The first row was read by the failed call.
+	total := 0
+	for label, nodes := range groups {
+		assert.True(t, label == "Human" || label == "Company")
+		total += len(nodes)
+	}
+	assert.Equal(t, 2, total)
+}
+
+func TestCollectNodes(t *testing.T) {
+	registerTestNodeTypes(t)
+	conn := openEntityTestConnection(t)
+	result, err := conn.Query(entitiesQuery)
+	assert.Nil(t, err)
+	defer result.Close()
+	entities, err := CollectNodes[entity](result, 0)
+	assert.Nil(t, err)
+	names := make([]string, len(entities))
+	for i, entity := range entities {
+		names[i] = entity.entityName()
+	}
+	sort.Strings(names)
+	assert.Equal(t, "Acme Alice Bob", strings.Join(names, " "))
+
+	// Unregistered labels are decoded into a Node, which is not an entity but
+	// is an any.
+	mustRun(t, conn, "CREATE NODE TABLE Robot(name STRING, PRIMARY KEY(name));")
+	mustRun(t, conn, "CREATE (:Robot {name: 'R2'});")
+	result, err = conn.Query("MATCH (n:Robot:Human) RETURN n ORDER BY n.name;")
+	assert.Nil(t, err)
+	defer result.Close()
+	values, err := CollectNodes[any](result, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(values))
+	assert.Equal(t, "Alice", values[0].(personNode).Name)
+	assert.Equal(t, "R2", values[2].(Node).Properties["name"])
+	result, err = conn.Query("MATCH (n:Robot) RETURN n;")
+	assert.Nil(t, err)
+	defer result.Close()
+	_, err = CollectNodes[entity](result, 0)
+	assert.ErrorContains(t, err, "cannot assign lbug.Node of node Robot to lbug.entity")
+
+	// Collect decodes the nodes of interface fields the same way.
+	type row struct {
+		Entity entity `lbug:"n"`
+		Name   string `lbug:"name"`
+	}
+	result, err = conn.Query(entitiesQuery)
+	assert.Nil(t, err)
+	defer result.Close()
+	rows, err := Collect[row](result)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(rows))
+	for _, row := range rows {
+		assert.Equal(t, row.Name, row.Entity.entityName())
+	}
+}
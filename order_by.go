@@ -0,0 +1,76 @@
+package lbug
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoSuchColumn is returned when a column passed to a helper is not a
+// property of the table.
+var ErrNoSuchColumn = errors.New("no such column")
+
+// OrderSpec describes one sort key of an ORDER BY clause.
+type OrderSpec struct {
+	// Column is the name of the property, or of the result column if the
+	// spec is rendered without a variable.
+	Column string
+	// Desc sorts in descending order.
+	Desc bool
+	// NullsLast sorts NULL values after all other values regardless of the
+	// direction. If it is false, the engine's default placement is used.
+	NullsLast bool
+}
+
+// Render returns the sort keys of the spec for the property Column of the
+// given variable, such as "n.`name` DESC". If variable is empty, Column is
+// rendered as a result column. Identifiers are quoted with QuoteIdentifier,
+// so Render is safe to use with user-selected columns, but it does not check
+// that the column exists; use BuildOrderBy for that.
+func (spec OrderSpec) Render(variable string) string {
+	expression := QuoteIdentifier(spec.Column)
+	if variable != "" {
+		expression = QuoteIdentifier(variable) + "." + expression
+	}
+	key := expression
+	if spec.Desc {
+		key += " DESC"
+	}
+	if spec.NullsLast {
+		// false sorts before true, so this key puts the NULLs last.
+		return expression + " IS NULL, " + key
+	}
+	return key
+}
+
+// BuildOrderBy returns an ORDER BY clause sorting the nodes or relationships
+// of the given table, bound to variable, by the specs. Every column must be a
+// property of the table; otherwise an error wrapping ErrNoSuchColumn is
+// returned before any query reaches the engine. The table name is resolved as
+// by Connection.ResolveTableName. An empty list of specs yields an empty
+// clause.
+func BuildOrderBy(conn *Connection, table string, variable string, specs []OrderSpec) (string, error) {
+	if len(specs) == 0 {
+		return "", nil
+	}
+	name, err := conn.ResolveTableName(table)
+	if err != nil {
+		return "", err
+	}
+	tables, err := conn.catalogTables()
+	if err != nil {
+		return "", err
+	}
+	schema, err := describeTable(conn, name, tables[name])
+	if err != nil {
+		return "", err
+	}
+	keys := make([]string, len(specs))
+	for i, spec := range specs {
+		if _, ok := schema.column(spec.Column); !ok {
+			return "", fmt.Errorf("%w: table %s has no property %s", ErrNoSuchColumn, name, spec.Column)
+		}
+		keys[i] = spec.Render(variable)
+	}
+	return "ORDER BY " + strings.Join(keys, ", "), nil
+}
@@ -0,0 +1,62 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, "`name`", QuoteIdentifier("name"))
+	assert.Equal(t, "`ORDER`", QuoteIdentifier("ORDER"))
+	assert.Equal(t, "`we``ird`", QuoteIdentifier("we`ird"))
+	assert.Equal(t, "`a b`", QuoteIdentifier("a b"))
+}
+
+func TestOrderSpecRender(t *testing.T) {
+	assert.Equal(t, "`n`.`name`", OrderSpec{Column: "name"}.Render("n"))
+	assert.Equal(t, "`n`.`age` DESC", OrderSpec{Column: "age", Desc: true}.Render("n"))
+	assert.Equal(t, "`n`.`age` IS NULL, `n`.`age` DESC", OrderSpec{Column: "age", Desc: true, NullsLast: true}.Render("n"))
+	assert.Equal(t, "`limit`", OrderSpec{Column: "limit"}.Render(""))
+	assert.Equal(t, "`n`.```; DROP TABLE x; //` DESC", OrderSpec{Column: "`; DROP TABLE x; //", Desc: true}.Render("n"))
+}
+
+func TestBuildOrderBy(t *testing.T) {
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	result, err := conn.Query("CREATE NODE TABLE item(id INT64, `order` INT64, `we``ird` STRING, PRIMARY KEY(id));")
+	assert.Nil(t, err)
+	result.Close()
+	result, err = conn.Query("CREATE (:item {id: 1, `order`: 2, `we``ird`: 'b'}), (:item {id: 2, `we``ird`: 'a'}), (:item {id: 3, `order`: 1});")
+	assert.Nil(t, err)
+	result.Close()
+
+	orderBy, err := BuildOrderBy(conn, "Item", "n", []OrderSpec{{Column: "order", Desc: true, NullsLast: true}, {Column: "we`ird"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "ORDER BY `n`.`order` IS NULL, `n`.`order` DESC, `n`.`we``ird`", orderBy)
+	result, err = conn.Query("MATCH (n:item) RETURN n.id " + orderBy + ";")
+	assert.Nil(t, err)
+	defer result.Close()
+	var ids []any
+	for result.HasNext() {
+		tuple, err := result.Next()
+		assert.Nil(t, err)
+		id, err := tuple.GetValue(0)
+		assert.Nil(t, err)
+		ids = append(ids, id)
+		tuple.Close()
+	}
+	assert.Equal(t, []any{int64(1), int64(3), int64(2)}, ids)
+
+	_, err = BuildOrderBy(conn, "item", "n", []OrderSpec{{Column: "missing"}})
+	assert.ErrorIs(t, err, ErrNoSuchColumn)
+	_, err = BuildOrderBy(conn, "missing", "n", []OrderSpec{{Column: "id"}})
+	assert.ErrorIs(t, err, ErrNoSuchTable)
+	orderBy, err = BuildOrderBy(conn, "item", "n", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "", orderBy)
+}
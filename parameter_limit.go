@@ -0,0 +1,374 @@
+package lbug
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SetMaxParameterSize sets the maximum estimated size in bytes of a parameter
+// bound by Execute and Exec on the connection. Larger parameters fail with a
+// *ParameterSizeError before they are converted. The size of a string, byte
+// slice or Blob is its length, the size of a number is its width and the size
+// of a list, map or struct is the sum of the sizes of its elements. Zero, the
+// default, disables the limit.
+func (conn *Connection) SetMaxParameterSize(maxBytes uint64) {
+	conn.maxParameterSize = maxBytes
+}
+
+// parameterSize returns the estimated size in bytes of a parameter value. It
+// stops counting once the size exceeds limit, if limit is not zero, so the
+// size returned for a value larger than the limit is only a lower bound.
+func parameterSize(value any, limit uint64) uint64 {
+	var size uint64
+	var add func(value any)
+	add = func(value any) {
+		if limit > 0 && size > limit {
+			return
+		}
+		switch v := value.(type) {
+		case nil:
+		case string:
+			size += uint64(len(v))
+		case []byte:
+			size += uint64(len(v))
+		case json.RawMessage:
+			size += uint64(len(v))
+		case Blob:
+			size += uint64(max(v.Length, 0))
+		case bool, int8, uint8:
+			size++
+		case int16, uint16:
+			size += 2
+		case int32, uint32, float32:
+			size += 4
+		case map[string]any:
+			for key, item := range v {
+				size += uint64(len(key))
+				add(item)
+			}
+		case []MapItem:
+			for _, item := range v {
+				add(item.Key)
+				add(item.Value)
+			}
+		case []any:
+			for _, item := range v {
+				add(item)
+			}
+		default:
+			reflectValue := reflect.ValueOf(value)
+			if reflectValue.Kind() != reflect.Slice {
+				size += 8
+				return
+			}
+			for i := 0; i < reflectValue.Len() && (limit == 0 || size <= limit); i++ {
+				add(reflectValue.Index(i).Interface())
+			}
+		}
+	}
+	add(value)
+	return size
+}
+
+// checkParameterSize returns a *ParameterSizeError if the parameter is larger
+// than the maximum parameter size of the connection.
+func (conn *Connection) checkParameterSize(name string, value any) error {
+	if conn.maxParameterSize == 0 {
+		return nil
+	}
+	if size := parameterSize(value, conn.maxParameterSize); size > conn.maxParameterSize {
+		return &ParameterSizeError{Name: name, Size: size, Limit: conn.maxParameterSize}
+	}
+	return nil
+}
+
+// SpillOptions configures Connection.QueryWithSpill.
+type SpillOptions struct {
+	// Threshold is the estimated size in bytes above which a list parameter
+	// is spooled to a file, as computed for SetMaxParameterSize. If it is
+	// zero, the maximum parameter size of the connection is used.
+	Threshold uint64
+	// Dir is the directory of the temporary files. If it is empty, the
+	// default directory for temporary files is used.
+	Dir string
+}
+
+// QueryWithSpill executes the query with the arguments like Execute, but
+// spools every list argument larger than the threshold to a temporary CSV
+// file instead of binding it, and rewrites the query to read the file with
+// LOAD FROM. This changes the plan of the query: the engine scans the file
+// instead of unwinding a parameter.
+//
+// A spooled parameter must be unwound by the first clause of the query, as in
+// "UNWIND $ids AS id MATCH ...", and not be used anywhere else. The clause
+// is rewritten to "LOAD WITH HEADERS (id INT64) FROM '<file>' (HEADER=true)".
+// The elements of the list must be booleans, integers, floats or strings of
+// the same type, or maps of such values keyed by field name, which are read
+// back as STRUCTs, e.g. for "UNWIND $rows AS row ... row.name". nil values
+// are written as empty fields and read as NULL; empty strings are read as
+// NULL too. Strings must not contain line breaks. The temporary files are
+// removed before QueryWithSpill returns, including on error.
+func (conn *Connection) QueryWithSpill(query string, args map[string]any, opts SpillOptions) (*QueryResult, error) {
+	threshold := opts.Threshold
+	if threshold == 0 {
+		threshold = conn.maxParameterSize
+	}
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	bound := make(map[string]any, len(args))
+	var files []string
+	defer func() {
+		for _, file := range files {
+			os.Remove(file)
+		}
+	}()
+	for _, name := range names {
+		value := args[name]
+		if threshold == 0 || parameterSize(value, threshold) <= threshold {
+			bound[name] = value
+			continue
+		}
+		file, err := os.CreateTemp(opts.Dir, "lbug-spill-*.csv")
+		if err != nil {
+			return nil, fmt.Errorf("failed to spool parameter $%s: %w", name, err)
+		}
+		files = append(files, file.Name())
+		columns, err := writeSpilledList(file, value)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to spool parameter $%s: %w", name, err)
+		}
+		query, err = rewriteSpilledUnwind(query, name, columns, file.Name())
+		if err != nil {
+			return nil, err
+		}
+	}
+	statement, err := conn.Prepare(query)
+	if err != nil {
+		statement.Close()
+		return nil, err
+	}
+	// The result is complete when Execute returns, so the statement and the
+	// files are no longer needed.
+	defer statement.Close()
+	return conn.Execute(statement, bound)
+}
+
+// spilledColumn is a column of a spooled list parameter. For a list of
+// scalars, there is a single column without field.
+type spilledColumn struct {
+	field    string
+	dataType string
+}
+
+// writeSpilledList writes the elements of a list parameter to the file as CSV
+// with a header and returns the columns of the file.
+func writeSpilledList(file *os.File, value any) ([]spilledColumn, error) {
+	reflectValue := reflect.ValueOf(value)
+	if reflectValue.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("only list parameters can be spooled, got %T", value)
+	}
+	elements := make([]any, reflectValue.Len())
+	for i := range elements {
+		elements[i] = reflectValue.Index(i).Interface()
+	}
+
+	// The columns are the fields of all maps, or a single column for scalars,
+	// and their types are those of the first non-nil values.
+	var columns []spilledColumn
+	_, isStruct := firstNonNil(elements).(map[string]any)
+	if isStruct {
+		types := make(map[string]string)
+		for _, element := range elements {
+			fields, ok := element.(map[string]any)
+			if !ok && element != nil {
+				return nil, fmt.Errorf("list mixes maps and %T values", element)
+			}
+			for field, item := range fields {
+				if field == "" {
+					return nil, fmt.Errorf("maps with an empty key cannot be spooled")
+				}
+				if _, ok := types[field]; ok || item == nil {
+					continue
+				}
+				dataType, err := spilledType(item)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", field, err)
+				}
+				types[field] = dataType
+			}
+		}
+		for field, dataType := range types {
+			columns = append(columns, spilledColumn{field: field, dataType: dataType})
+		}
+		sort.Slice(columns, func(i, j int) bool { return columns[i].field < columns[j].field })
+	} else if first := firstNonNil(elements); first != nil {
+		dataType, err := spilledType(first)
+		if err != nil {
+			return nil, err
+		}
+		columns = []spilledColumn{{dataType: dataType}}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("the type of the elements cannot be determined because they are all nil")
+	}
+
+	writer := csv.NewWriter(file)
+	header := make([]string, len(columns))
+	for i := range columns {
+		header[i] = fmt.Sprintf("c%d", i)
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	record := make([]string, len(columns))
+	for _, element := range elements {
+		for i, column := range columns {
+			item := element
+			if column.field != "" {
+				fields, _ := element.(map[string]any)
+				item = fields[column.field]
+			}
+			field, err := spilledField(item, column.dataType)
+			if err != nil {
+				return nil, err
+			}
+			record[i] = field
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return columns, writer.Error()
+}
+
+// firstNonNil returns the first element that is not nil, or nil.
+func firstNonNil(elements []any) any {
+	for _, element := range elements {
+		if element != nil {
+			return element
+		}
+	}
+	return nil
+}
+
+// spilledType returns the column type of a spooled value.
+func spilledType(value any) (string, error) {
+	switch value.(type) {
+	case bool:
+		return "BOOL", nil
+	case int, int64:
+		return "INT64", nil
+	case int32:
+		return "INT32", nil
+	case int16:
+		return "INT16", nil
+	case int8:
+		return "INT8", nil
+	case uint, uint64:
+		return "UINT64", nil
+	case uint32:
+		return "UINT32", nil
+	case uint16:
+		return "UINT16", nil
+	case uint8:
+		return "UINT8", nil
+	case float64:
+		return "DOUBLE", nil
+	case float32:
+		return "FLOAT", nil
+	case string:
+		return "STRING", nil
+	}
+	return "", fmt.Errorf("values of type %T cannot be spooled", value)
+}
+
+// spilledField formats a spooled value of the column type as a CSV field.
+func spilledField(value any, dataType string) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	if actual, err := spilledType(value); err != nil {
+		return "", err
+	} else if actual != dataType {
+		return "", fmt.Errorf("list mixes %s and %s values", dataType, actual)
+	}
+	switch v := value.(type) {
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("value %v cannot be spooled", v)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return "", fmt.Errorf("value %v cannot be spooled", v)
+		}
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case string:
+		if strings.ContainsAny(v, "\r\n") {
+			return "", fmt.Errorf("strings with line breaks cannot be spooled")
+		}
+		return v, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// spilledUnwindPattern matches the UNWIND clause at the start of a query,
+// with the name of the parameter in the first group and the alias in the
+// second.
+var spilledUnwindPattern = regexp.MustCompile("^\\s*(?i:UNWIND)\\s+\\$(\\w+)\\s+(?i:AS)\\s+(\\w+|`[^`]+`)")
+
+// rewriteSpilledUnwind rewrites the UNWIND clause of the spooled parameter at
+// the start of the query to read the file.
+func rewriteSpilledUnwind(query string, name string, columns []spilledColumn, path string) (string, error) {
+	match := spilledUnwindPattern.FindStringSubmatchIndex(query)
+	if match == nil || query[match[2]:match[3]] != name {
+		return "", fmt.Errorf("cannot spool parameter $%s: the query must start with UNWIND $%s AS <alias>", name, name)
+	}
+	alias := query[match[4]:match[5]]
+	rest := query[match[1]:]
+	if regexp.MustCompile(`\$` + regexp.QuoteMeta(name) + `\b`).MatchString(rest) {
+		return "", fmt.Errorf("cannot spool parameter $%s: it is used outside of the UNWIND clause", name)
+	}
+	var clause strings.Builder
+	clause.WriteString("LOAD WITH HEADERS (")
+	for i, column := range columns {
+		if i > 0 {
+			clause.WriteString(", ")
+		}
+		if column.field == "" {
+			// The column of a list of scalars is named after the alias.
+			fmt.Fprintf(&clause, "%s %s", alias, column.dataType)
+		} else {
+			fmt.Fprintf(&clause, "c%d %s", i, column.dataType)
+		}
+	}
+	fmt.Fprintf(&clause, ") FROM %s (HEADER=true)", quoteStringLiteral(path))
+	if columns[0].field != "" {
+		clause.WriteString(" WITH {")
+		for i, column := range columns {
+			if i > 0 {
+				clause.WriteString(", ")
+			}
+			fmt.Fprintf(&clause, "%s: c%d", QuoteIdentifier(column.field), i)
+		}
+		clause.WriteString("} AS " + alias)
+	}
+	return clause.String() + rest, nil
+}
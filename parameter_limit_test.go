@@ -0,0 +1,132 @@
+package lbug
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParameterSize(t *testing.T) {
+	assert.Equal(t, uint64(5), parameterSize("hello", 0))
+	assert.Equal(t, uint64(8), parameterSize(int64(1), 0))
+	assert.Equal(t, uint64(3), parameterSize([]int8{1, 2, 3}, 0))
+	assert.Equal(t, uint64(1+8+1+2), parameterSize(map[string]any{"a": int64(1), "b": "xy"}, 0))
+	assert.Equal(t, uint64(3), parameterSize(Blob{Reader: strings.NewReader("abc"), Length: 3}, 0))
+	// Counting stops once the limit is exceeded.
+	assert.Equal(t, uint64(16), parameterSize(make([]int64, 1000), 10))
+}
+
+func TestMaxParameterSize(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	conn.SetMaxParameterSize(16)
+	stmt, err := conn.Prepare("RETURN size($v)")
+	assert.Nil(t, err)
+	defer stmt.Close()
+
+	result, err := conn.Execute(stmt, map[string]any{"v": []int64{1, 2}})
+	assert.Nil(t, err)
+	result.Close()
+	_, err = conn.Execute(stmt, map[string]any{"v": []int64{1, 2, 3}})
+	assert.ErrorIs(t, err, ErrParameterTooLarge)
+	var sizeErr *ParameterSizeError
+	assert.ErrorAs(t, err, &sizeErr)
+	assert.Equal(t, ParameterSizeError{Name: "v", Size: 24, Limit: 16}, *sizeErr)
+	_, err = stmt.Exec(map[string]any{"v": strings.Repeat("x", 17)})
+	assert.ErrorIs(t, err, ErrParameterTooLarge)
+}
+
+func TestRewriteSpilledUnwind(t *testing.T) {
+	query, err := rewriteSpilledUnwind("UNWIND $ids AS id MATCH (n) WHERE n.id = id RETURN n", "ids",
+		[]spilledColumn{{dataType: "INT64"}}, "/tmp/ids.csv")
+	assert.Nil(t, err)
+	assert.Equal(t, "LOAD WITH HEADERS (id INT64) FROM '/tmp/ids.csv' (HEADER=true) MATCH (n) WHERE n.id = id RETURN n", query)
+
+	query, err = rewriteSpilledUnwind(" unwind $rows as row CREATE (:t {a: row.a})", "rows",
+		[]spilledColumn{{field: "a", dataType: "STRING"}, {field: "b", dataType: "DOUBLE"}}, "/tmp/rows.csv")
+	assert.Nil(t, err)
+	assert.Equal(t, "LOAD WITH HEADERS (c0 STRING, c1 DOUBLE) FROM '/tmp/rows.csv' (HEADER=true) WITH {`a`: c0, `b`: c1} AS row CREATE (:t {a: row.a})", query)
+
+	_, err = rewriteSpilledUnwind("MATCH (n) UNWIND $ids AS id RETURN id", "ids", []spilledColumn{{dataType: "INT64"}}, "f")
+	assert.ErrorContains(t, err, "must start with UNWIND $ids")
+	_, err = rewriteSpilledUnwind("UNWIND $ids AS id RETURN id, size($ids)", "ids", []spilledColumn{{dataType: "INT64"}}, "f")
+	assert.ErrorContains(t, err, "used outside")
+	_, err = rewriteSpilledUnwind("UNWIND $idsx AS id RETURN id", "ids", []spilledColumn{{dataType: "INT64"}}, "f")
+	assert.NotNil(t, err)
+}
+
+func TestWriteSpilledList(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "spill-*.csv")
+	assert.Nil(t, err)
+	columns, err := writeSpilledList(file, []any{
+		map[string]any{"name": "Alice, \"A\"", "age": int64(30)},
+		map[string]any{"name": "Bob"},
+		nil,
+	})
+	assert.Nil(t, err)
+	file.Close()
+	assert.Equal(t, []spilledColumn{{field: "age", dataType: "INT64"}, {field: "name", dataType: "STRING"}}, columns)
+	contents, err := os.ReadFile(file.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, "c0,c1\n30,\"Alice, \"\"A\"\"\"\n,Bob\n,\n", string(contents))
+
+	for _, value := range []any{
+		[]any{int64(1), "two"},
+		[]any{nil, nil},
+		[]string{"line\nbreak"},
+		[]any{map[string]any{"a": []any{int64(1)}}},
+		"not a list",
+	} {
+		file, err := os.CreateTemp(t.TempDir(), "spill-*.csv")
+		assert.Nil(t, err)
+		_, err = writeSpilledList(file, value)
+		assert.NotNil(t, err, "%#v", value)
+		file.Close()
+	}
+}
+
+func TestQueryWithSpill(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE item(id INT64, name STRING, PRIMARY KEY(id));")
+	dir := t.TempDir()
+	rows := make([]any, 100)
+	for i := range rows {
+		rows[i] = map[string]any{"id": int64(i), "name": "item"}
+	}
+	result, err := conn.QueryWithSpill("UNWIND $rows AS row CREATE (:item {id: row.id, name: row.name})",
+		map[string]any{"rows": rows}, SpillOptions{Threshold: 64, Dir: dir})
+	assert.Nil(t, err)
+	result.Close()
+
+	// Lists at the threshold are bound, larger ones are spooled.
+	ids := []int64{1, 2, 3, 4, 5, 6, 7, 8}
+	for _, threshold := range []uint64{64, 63} {
+		result, err = conn.QueryWithSpill("UNWIND $ids AS id MATCH (n:item) WHERE n.id = id RETURN count(*)",
+			map[string]any{"ids": ids}, SpillOptions{Threshold: threshold, Dir: dir})
+		assert.Nil(t, err)
+		count, err := readCount(result)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(8), count)
+		result.Close()
+	}
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestQueryWithSpillRemovesFilesOnError(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	dir := t.TempDir()
+	ids := []int64{1, 2, 3}
+	_, err := conn.QueryWithSpill("MATCH (n) UNWIND $ids AS id RETURN id", map[string]any{"ids": ids}, SpillOptions{Threshold: 1, Dir: dir})
+	assert.NotNil(t, err)
+	_, err = conn.QueryWithSpill("UNWIND $ids AS id RETURN nonexistent", map[string]any{"ids": ids}, SpillOptions{Threshold: 1, Dir: dir})
+	assert.NotNil(t, err)
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+}
@@ -1,10 +1,15 @@
 package lbug
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"regexp"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,10 +20,13 @@ func BasicParamTestHelper(t *testing.T, param any) {
 	}
 	preparedStatement, err := conn.Prepare("RETURN $1")
 	assert.Nil(t, err)
+	defer preparedStatement.Close()
 	res, err := conn.Execute(preparedStatement, params)
 	assert.Nil(t, err)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, param, value)
 }
@@ -30,10 +38,13 @@ func FloatParamTestHelper(t *testing.T, param any) {
 	}
 	preparedStatement, err := conn.Prepare("RETURN $1")
 	assert.Nil(t, err)
+	defer preparedStatement.Close()
 	res, err := conn.Execute(preparedStatement, params)
 	assert.Nil(t, err)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.InDelta(t, param, value, floatEpsilon)
 }
@@ -45,10 +56,13 @@ func TimeParamTestHelper(t *testing.T, param any) {
 	}
 	preparedStatement, err := conn.Prepare("RETURN $1")
 	assert.Nil(t, err)
+	defer preparedStatement.Close()
 	res, err := conn.Execute(preparedStatement, params)
 	assert.Nil(t, err)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	valueTime := value.(time.Time).UTC()
 	paramTime := param.(time.Time).UTC()
@@ -119,6 +133,10 @@ func TestDurationParam(t *testing.T) {
 	BasicParamTestHelper(t, duration)
 }
 
+func TestNegativeDurationParam(t *testing.T) {
+	BasicParamTestHelper(t, -90*time.Minute)
+}
+
 func TestNilParam(t *testing.T) {
 	BasicParamTestHelper(t, nil)
 }
@@ -151,6 +169,7 @@ func TestStructWithUnsupportedTypeParam(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	preparedStatement, err := conn.Prepare("RETURN $1")
 	assert.Nil(t, err)
+	defer preparedStatement.Close()
 	_, err = conn.Execute(preparedStatement, map[string]any{"1": goMap})
 	assert.NotNil(t, err)
 	expected := "failed to convert value in the map with error: unsupported type"
@@ -162,6 +181,7 @@ func TestEmptyMapParam(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	preparedStatement, err := conn.Prepare("RETURN $1")
 	assert.Nil(t, err)
+	defer preparedStatement.Close()
 	_, err = conn.Execute(preparedStatement, map[string]any{"1": goMap})
 	assert.NotNil(t, err)
 	expected := "failed to create STRUCT value because the map is empty"
@@ -202,6 +222,7 @@ func TestMapParamWithUnsupportedType(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	preparedStatement, err := conn.Prepare("RETURN $1")
 	assert.Nil(t, err)
+	defer preparedStatement.Close()
 	_, err = conn.Execute(preparedStatement, map[string]any{"1": goMap})
 	assert.NotNil(t, err)
 	expected := "failed to convert value in the slice with error: unsupported type:"
@@ -218,6 +239,7 @@ func TestMapWithMixedTypesParam(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	preparedStatement, err := conn.Prepare("RETURN $1")
 	assert.Nil(t, err)
+	defer preparedStatement.Close()
 	_, err = conn.Execute(preparedStatement, map[string]any{"1": goMap})
 	assert.NotNil(t, err)
 	expected := "failed to create MAP value with status: 1"
@@ -252,6 +274,7 @@ func TestSliceParamWithUnsupportedType(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	preparedStatement, err := conn.Prepare("RETURN $1")
 	assert.Nil(t, err)
+	defer preparedStatement.Close()
 	_, err = conn.Execute(preparedStatement, map[string]any{"1": goSlice})
 	assert.NotNil(t, err)
 	expected := "failed to convert value in the slice with error: unsupported type:"
@@ -263,6 +286,7 @@ func TestSliceWithMixedTypesParam(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	preparedStatement, err := conn.Prepare("RETURN $1")
 	assert.Nil(t, err)
+	defer preparedStatement.Close()
 	_, err = conn.Execute(preparedStatement, map[string]any{"1": goSlice})
 	assert.NotNil(t, err)
 	expected := "failed to create LIST value with status: 1"
@@ -275,10 +299,12 @@ func TestInt64SliceParam(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	preparedStatement, err := conn.Prepare("RETURN $1")
 	assert.Nil(t, err)
+	defer preparedStatement.Close()
 	res, err := conn.Execute(preparedStatement, map[string]any{"1": goSlice})
 	assert.Nil(t, err)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, expected, value)
 	assert.False(t, res.HasNext())
@@ -291,11 +317,13 @@ func TestStringSliceParam(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	preparedStatement, err := conn.Prepare("RETURN $1")
 	assert.Nil(t, err)
+	defer preparedStatement.Close()
 	res, err := conn.Execute(preparedStatement, map[string]any{"1": goSlice})
 	defer res.Close()
 	assert.Nil(t, err)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, expected, value)
 	assert.False(t, res.HasNext())
@@ -313,12 +341,258 @@ func TestNestedInt64SliceParam(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	preparedStatement, err := conn.Prepare("RETURN $1")
 	assert.Nil(t, err)
+	defer preparedStatement.Close()
 	res, err := conn.Execute(preparedStatement, map[string]any{"1": goSlice})
 	defer res.Close()
 	assert.Nil(t, err)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, expected, value)
 	assert.False(t, res.HasNext())
 }
+
+type jsonPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func (point jsonPoint) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"x":%d,"y":%d}`, point.X, point.Y)), nil
+}
+
+func TestJSONRawMessageParam(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	preparedStatement, err := conn.Prepare("RETURN $1")
+	assert.Nil(t, err)
+	defer preparedStatement.Close()
+	document := json.RawMessage(`{"tags":["a","b"],"n":1}`)
+	res, err := conn.Execute(preparedStatement, map[string]any{"1": document})
+	assert.Nil(t, err)
+	defer res.Close()
+	next, err := res.Next()
+	assert.Nil(t, err)
+	defer next.Close()
+	value, _ := next.GetValue(0)
+	assert.Equal(t, string(document), value)
+}
+
+func TestJSONMarshalerParam(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	preparedStatement, err := conn.Prepare("RETURN $1")
+	assert.Nil(t, err)
+	defer preparedStatement.Close()
+	res, err := conn.Execute(preparedStatement, map[string]any{"1": jsonPoint{X: 1, Y: 2}})
+	assert.Nil(t, err)
+	defer res.Close()
+	next, err := res.Next()
+	assert.Nil(t, err)
+	defer next.Close()
+	value, _ := next.GetValue(0)
+	assert.Equal(t, `{"x":1,"y":2}`, value)
+}
+
+// roundTripParam binds the value to $p, returns the query and reads back the
+// single value of its result.
+func roundTripParam(t *testing.T, query string, value any) any {
+	t.Helper()
+	_, conn := SetupTestDatabase(t)
+	preparedStatement, err := conn.Prepare(query)
+	assert.Nil(t, err)
+	defer preparedStatement.Close()
+	res, err := conn.Execute(preparedStatement, map[string]any{"p": value})
+	if !assert.Nil(t, err) {
+		return nil
+	}
+	defer res.Close()
+	next, err := res.Next()
+	assert.Nil(t, err)
+	defer next.Close()
+	got, err := next.GetValue(0)
+	assert.Nil(t, err)
+	return got
+}
+
+type paramAddress struct {
+	City    string
+	Country string `lbug:"country"`
+	Secret  string `lbug:"-"`
+	hidden  int
+}
+
+type paramPerson struct {
+	Name    string
+	Age     *int64
+	Address paramAddress
+	Tags    []string
+}
+
+type paramUserID int64
+
+func TestRichParamsRoundTrip(t *testing.T) {
+	age := int64(30)
+	paris := time.FixedZone("CET", 3600)
+	cases := []struct {
+		name     string
+		query    string
+		value    any
+		expected any
+	}{
+		{"typed slice", "RETURN $p", []float64{1.5, 2.5}, []any{1.5, 2.5}},
+		{"array", "RETURN $p", [2]string{"a", "b"}, []any{"a", "b"}},
+		{"empty typed slice", "RETURN $p", []int64{}, []any{}},
+		{"empty nested slice", "RETURN $p", [][]string{}, []any{}},
+		{"slice of slices", "RETURN $p", [][]int64{{1}, {2, 3}}, []any{[]any{int64(1)}, []any{int64(2), int64(3)}}},
+		{"slice of maps", "RETURN $p", []map[string]any{{"a": int64(1), "b": nil}, {"a": nil, "b": "x"}},
+			[]any{map[string]any{"a": int64(1), "b": nil}, map[string]any{"a": nil, "b": "x"}}},
+		{"typed map", "RETURN $p", map[string]int64{"x": 1, "y": 2}, map[string]any{"x": int64(1), "y": int64(2)}},
+		{"map with integer keys", "RETURN $p", map[int64]string{2: "b", 1: "a"}, []MapItem{{int64(1), "a"}, {int64(2), "b"}}},
+		{"struct", "RETURN $p",
+			paramPerson{Name: "Alice", Age: &age, Address: paramAddress{City: "Paris", Country: "FR", Secret: "s"}, Tags: []string{"x"}},
+			map[string]any{"Name": "Alice", "Age": int64(30), "Address": map[string]any{"City": "Paris", "country": "FR"}, "Tags": []any{"x"}}},
+		{"struct with NULL fields", "RETURN $p", &paramPerson{Name: "Bob"},
+			map[string]any{"Name": "Bob", "Age": nil, "Address": map[string]any{"City": "", "country": ""}, "Tags": nil}},
+		{"typed NULL pointer", "RETURN $p + 1", (*int64)(nil), nil},
+		{"typed NULL slice", "RETURN size($p)", []string(nil), nil},
+		{"named integer type", "RETURN $p", paramUserID(7), int64(7)},
+		{"date", "RETURN $p", Date(time.Date(2024, 3, 1, 23, 30, 0, 0, paris)), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"interval", "RETURN $p", Interval{Months: 1, Days: 2, Micros: 3}, Interval{Months: 1, Days: 2, Micros: 3}},
+		{"bytes", "RETURN $p", []byte{0x00, 0xff, 'a'}, []any{uint8(0x00), uint8(0xff), uint8('a')}},
+		{"typed NULL bytes", "RETURN size($p)", []byte(nil), nil},
+		{"blob", "RETURN CAST($p AS BLOB)", Blob{Reader: bytes.NewReader([]byte{0x00, 0xff, 'a'}), Length: 3}, []byte{0x00, 0xff, 'a'}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, roundTripParam(t, c.query, c.value))
+		})
+	}
+}
+
+func TestTimeZoneParams(t *testing.T) {
+	instant := time.Date(2024, 3, 1, 23, 30, 0, 0, time.FixedZone("CET", 3600))
+	value := roundTripParam(t, "RETURN $p", instant)
+	assert.Equal(t, instant.UTC(), value)
+	value = roundTripParam(t, "RETURN $p", TimestampTZ(instant))
+	got, ok := value.(time.Time)
+	assert.True(t, ok)
+	assert.True(t, instant.Equal(got))
+}
+
+func TestUnsupportedParamError(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	preparedStatement, err := conn.Prepare("RETURN $p")
+	assert.Nil(t, err)
+	defer preparedStatement.Close()
+	_, err = conn.Execute(preparedStatement, map[string]any{"p": []any{make(chan int)}})
+	assert.ErrorContains(t, err, "failed to convert Go value of type []interface {} to Lbug value for parameter p")
+	assert.ErrorContains(t, err, "unsupported type: chan int")
+	var lbugErr *Error
+	assert.ErrorAs(t, err, &lbugErr)
+	assert.Equal(t, "p", lbugErr.Parameter)
+	_, err = conn.Execute(preparedStatement, map[string]any{"p": []any{}})
+	assert.ErrorContains(t, err, "the slice is empty")
+}
+
+// money is bound with LbugValuer as its amount in cents, although it is a
+// struct and implements encoding.TextMarshaler.
+type money struct {
+	Cents    int64  `lbug:"amount"`
+	Currency string `lbug:"currency"`
+}
+
+func (m money) LbugValue() (any, error) {
+	if m.Currency == "" {
+		return nil, errors.New("money without currency")
+	}
+	return m.Cents, nil
+}
+
+func (m money) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d %s", m.Cents, m.Currency)), nil
+}
+
+// ticket is bound with encoding.TextMarshaler.
+type ticket struct {
+	project string
+	number  int
+}
+
+func (t ticket) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%s-%d", t.project, t.number)), nil
+}
+
+// loopValuer returns itself from LbugValue.
+type loopValuer struct{}
+
+func (v loopValuer) LbugValue() (any, error) {
+	return v, nil
+}
+
+func TestValuerParams(t *testing.T) {
+	assert.Equal(t, int64(1250), roundTripParam(t, "RETURN $p", money{Cents: 1250, Currency: "EUR"}))
+	assert.Equal(t, "LBUG-42", roundTripParam(t, "RETURN $p", ticket{project: "LBUG", number: 42}))
+	// Valuers nested in lists and structs are converted as well.
+	assert.Equal(t, []any{int64(1), int64(2)}, roundTripParam(t, "RETURN $p", []money{{1, "EUR"}, {2, "EUR"}}))
+	assert.Equal(t, map[string]any{"price": int64(5), "ticket": "A-1"},
+		roundTripParam(t, "RETURN $p", map[string]any{"price": money{5, "USD"}, "ticket": ticket{"A", 1}}))
+	id := uuid.MustParse("a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11")
+	assert.Equal(t, id, roundTripParam(t, "RETURN CAST($p AS UUID)", id))
+	var missing *money
+	assert.Nil(t, roundTripParam(t, "RETURN $p", missing))
+
+	_, conn := SetupTestDatabase(t)
+	preparedStatement, err := conn.Prepare("RETURN $p")
+	assert.Nil(t, err)
+	defer preparedStatement.Close()
+	_, err = conn.Execute(preparedStatement, map[string]any{"p": money{Cents: 1}})
+	assert.ErrorContains(t, err, "failed to convert lbug.money with LbugValue: money without currency")
+	_, err = conn.Execute(preparedStatement, map[string]any{"p": loopValuer{}})
+	assert.ErrorContains(t, err, "LbugValue of lbug.loopValuer returned a LbugValuer")
+}
+
+func TestGraphValueParams(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE person(name STRING, PRIMARY KEY(name));")
+	mustRun(t, conn, "CREATE REL TABLE knows(FROM person TO person);")
+	mustRun(t, conn, "CREATE (:person {name: 'Alice'})-[:knows]->(:person {name: 'Bob'});")
+	result, err := conn.Query("MATCH (a:person)-[k:knows]->(b:person) RETURN a, k;")
+	assert.Nil(t, err)
+	defer result.Close()
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	row, err := tuple.GetAsSlice()
+	assert.Nil(t, err)
+	alice, knows := row[0].(Node), row[1].(Relationship)
+
+	// Nodes and relationships are bound as their IDs.
+	assert.Equal(t, alice.ID, roundTripParam(t, "RETURN $p", alice.ID))
+	statement, err := conn.Prepare("MATCH (n:person) WHERE id(n) = $node RETURN n.name;")
+	assert.Nil(t, err)
+	defer statement.Close()
+	for _, param := range []any{alice, alice.ID, &alice} {
+		names, err := conn.Execute(statement, map[string]any{"node": param})
+		assert.Nil(t, err)
+		frozen, err := names.Freeze()
+		assert.Nil(t, err)
+		names.Close()
+		assert.Equal(t, []any{"Alice"}, frozen.Row(0))
+	}
+	statement, err = conn.Prepare("MATCH ()-[k:knows]->(b) WHERE id(k) = $rel RETURN b.name;")
+	assert.Nil(t, err)
+	defer statement.Close()
+	names, err := conn.Execute(statement, map[string]any{"rel": knows})
+	assert.Nil(t, err)
+	frozen, err := names.Freeze()
+	assert.Nil(t, err)
+	names.Close()
+	assert.Equal(t, []any{"Bob"}, frozen.Row(0))
+	ids := roundTripParam(t, "RETURN $p", []Node{alice})
+	assert.Equal(t, []any{alice.ID}, ids)
+	var missing *Node
+	assert.Nil(t, roundTripParam(t, "RETURN $p", missing))
+
+	_, err = conn.Execute(statement, map[string]any{"rel": RecursiveRelationship{}})
+	assert.ErrorContains(t, err, "a RecursiveRelationship cannot be bound as a parameter")
+}
@@ -0,0 +1,674 @@
+package lbug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PoolConfig configures a Pool created with NewPool or OpenPool.
+type PoolConfig struct {
+	// MaxConns is the maximum number of connections of the pool, idle or in
+	// use. Zero means runtime.GOMAXPROCS(0).
+	MaxConns int
+	// MaxIdle is the maximum number of idle connections kept by the pool:
+	// the connections released while MaxIdle connections are idle are
+	// closed. Zero means MaxConns.
+	MaxIdle int
+	// MinIdle is the number of idle connections the pool keeps open, so that
+	// the first requests do not pay for opening and warming up connections.
+	// They are opened in the background once the pool is created, and
+	// replaced as idle connections are acquired or closed, e.g. because a
+	// query failed, while fewer than MaxConns connections are open. The
+	// MinIdle most recently released idle connections are not closed after
+	// MaxIdleTime. MinIdle must not exceed MaxIdle; zero opens connections
+	// only when they are acquired.
+	MinIdle int
+	// MaxIdleTime is the time after which an idle connection is closed. Zero
+	// means that idle connections are kept until the pool is closed.
+	MaxIdleTime time.Duration
+	// MaxLifetime is the time after which a connection is closed once
+	// released, counted from its opening, e.g. to release the memory the
+	// engine caches per connection. Zero means that connections are reused
+	// until the pool is closed.
+	MaxLifetime time.Duration
+	// OnConnect is called with every connection opened by the pool, e.g. to
+	// set a query timeout. If it returns an error, the connection is closed
+	// and Acquire returns the error.
+	OnConnect func(conn *Connection) error
+	// WarmUpQuery, if set, is run on every connection opened by the pool,
+	// after OnConnect, e.g. to load the catalog before the connection is
+	// used. If it fails, the connection is closed and Acquire returns the
+	// error.
+	WarmUpQuery string
+	// FailWhenPaused makes Acquire return ErrPoolPaused while the pool is
+	// paused with Pause, instead of waiting for Resume.
+	FailWhenPaused bool
+	// Settings, if set, are applied to every connection acquired from the
+	// pool, so that the changes made with Pool.UpdateConfig reach the
+	// connections opened before them.
+	Settings *ConnectionSettings
+	// StatementCacheSize is the number of query texts whose statements
+	// PooledConnection.PrepareCached and Pool.Execute keep prepared on the
+	// connections of the pool, from the most recently used. Each connection
+	// prepares a text the first time it is used on it. Zero disables the
+	// cache: the statements are closed when their connection is released.
+	StatementCacheSize int
+}
+
+// ErrPoolPaused is returned by Pool.Acquire while the pool is paused if
+// PoolConfig.FailWhenPaused is set.
+var ErrPoolPaused = errors.New("pool is paused")
+
+// PoolStats is a snapshot of the usage of a Pool.
+type PoolStats struct {
+	MaxConns int
+	// Open is the number of connections of the pool, idle or in use.
+	Open  int
+	InUse int
+	Idle  int
+	// WaitCount is the number of calls to Acquire that waited for a
+	// connection to be released, and WaitDuration the total time they
+	// waited.
+	WaitCount    uint64
+	WaitDuration time.Duration
+	// IdleTimeClosed is the number of connections closed because they were
+	// idle for longer than PoolConfig.MaxIdleTime.
+	IdleTimeClosed uint64
+	// IdleClosed is the number of connections closed on release because
+	// PoolConfig.MaxIdle connections were idle, and LifetimeClosed the
+	// number of connections closed because they were open for longer than
+	// PoolConfig.MaxLifetime.
+	IdleClosed     uint64
+	LifetimeClosed uint64
+	// FailedClosed is the number of connections closed on release because
+	// their last query failed in the engine, because they were closed or
+	// because they were in a transaction.
+	FailedClosed uint64
+	// Paused is true between Pause and Resume.
+	Paused bool
+}
+
+// Pool is a pool of connections to a database that can be used concurrently
+// by many goroutines, each of which acquires a connection of its own. The
+// connections are opened when they are needed, up to PoolConfig.MaxConns,
+// and reused once they are released. A connection whose last query, statement
+// or iteration was failed by the engine is closed on release instead of being
+// reused, so that a later query never runs on a connection in an unknown
+// state.
+//
+// The settings changed on an acquired connection, e.g. with SetMaxRows, are
+// kept when the connection is reused, except the defaults set with
+// SetDefaultParams; use PoolConfig.OnConnect to configure all the connections
+// of the pool the same way, and PoolConfig.Settings for the settings that may
+// be changed with UpdateConfig while the pool is used.
+//
+// When the pool closes a connection, the query results, flat tuples and
+// prepared statements created from it that are still open are closed first,
+// so that a result that outlives the release of its connection fails with an
+// error matching ErrClosed instead of using a destroyed C connection.
+type Pool struct {
+	database *Database
+	// config is guarded by mutex, since UpdateConfig replaces it, and
+	// updating serializes the calls to UpdateConfig.
+	config   PoolConfig
+	updating sync.Mutex
+	// ownsDatabase is true for a pool opened with OpenPool, whose database is
+	// closed with the pool.
+	ownsDatabase bool
+	// slots holds a value per acquired connection, so that the sends block
+	// while MaxConns connections are in use.
+	slots chan struct{}
+	// done is closed when the pool is closed, to wake up the goroutines
+	// waiting in Acquire.
+	done      chan struct{}
+	checkouts sync.WaitGroup
+	// statements is the statement cache of the pool.
+	statements *statementCache
+
+	mutex  sync.Mutex
+	closed bool
+	paused bool
+	// resumed is closed by Resume, to wake up the goroutines waiting in
+	// Acquire while the pool is paused.
+	resumed chan struct{}
+	// drained is closed when the last acquired connection is released while
+	// the pool is paused, to wake up the goroutines waiting in Pause.
+	drained chan struct{}
+	// idle are the idle connections, from the least recently released.
+	idle  []idleConnection
+	open  int
+	inUse int
+	// refill wakes up the goroutine keeping PoolConfig.MinIdle connections
+	// idle, and maintained is closed once it has returned; both are nil if
+	// MinIdle is zero.
+	refill     chan struct{}
+	maintained chan struct{}
+
+	waitCount      atomic.Uint64
+	waitDuration   atomic.Int64
+	idleTimeClosed atomic.Uint64
+	idleClosed     atomic.Uint64
+	lifetimeClosed atomic.Uint64
+	failedClosed   atomic.Uint64
+}
+
+// idleConnection is an idle connection of a Pool, with the state the pool
+// keeps for it across acquisitions.
+type idleConnection struct {
+	conn     *Connection
+	opened   time.Time
+	released time.Time
+	// statements are the statements cached on the connection, nil until one
+	// is prepared with PrepareCached.
+	statements *connStatements
+}
+
+// PooledConnection is a connection acquired from a Pool with Acquire. It must
+// be given back to the pool with Release, or Close, which releases it as well
+// instead of closing the connection, and must not be used after.
+type PooledConnection struct {
+	*Connection
+	pool       *Pool
+	opened     time.Time
+	statements *connStatements
+	// affinity is the affinity the connection is pinned to, if it was
+	// acquired in a context returned by WithAffinity.
+	affinity *poolAffinity
+	released atomic.Bool
+}
+
+// normalize checks the numbers of connections of the configuration and
+// replaces their zero values by their defaults. Settings is copied, so that
+// the pool does not share it with the caller.
+func (config *PoolConfig) normalize() error {
+	if config.MaxConns < 0 || config.MaxIdle < 0 || config.MinIdle < 0 {
+		return errors.New("the maximum and minimum numbers of connections of a pool cannot be negative")
+	}
+	if config.StatementCacheSize < 0 {
+		return errors.New("the statement cache size of a pool cannot be negative")
+	}
+	if config.MaxConns == 0 {
+		config.MaxConns = runtime.GOMAXPROCS(0)
+	}
+	if config.MaxIdle == 0 {
+		config.MaxIdle = config.MaxConns
+	}
+	if config.MinIdle > config.MaxIdle {
+		return fmt.Errorf("the minimum number of idle connections of a pool, %d, exceeds the maximum, %d", config.MinIdle, config.MaxIdle)
+	}
+	if config.Settings != nil {
+		settings := *config.Settings
+		config.Settings = &settings
+	}
+	return nil
+}
+
+// NewPool returns a pool of connections to the database. The pool opens no
+// connection until one is acquired, unless PoolConfig.MinIdle is set. The
+// pool must be closed with Close before the database.
+func NewPool(database *Database, config PoolConfig) (*Pool, error) {
+	if err := config.normalize(); err != nil {
+		return nil, err
+	}
+	if database.closed() {
+		return nil, &Error{Op: OpOpen, Err: &closedError{"failed to create pool because the database is closed"}}
+	}
+	pool := &Pool{
+		database:   database,
+		config:     config,
+		slots:      make(chan struct{}, config.MaxConns),
+		done:       make(chan struct{}),
+		statements: newStatementCache(database, config.StatementCacheSize),
+	}
+	if config.MinIdle > 0 {
+		pool.refill = make(chan struct{}, 1)
+		pool.maintained = make(chan struct{})
+		background.spawnUnbounded("Pool maintainer", nil, pool.maintainIdle)
+	}
+	return pool, nil
+}
+
+// OpenPool opens the database at the path with the system configuration, as
+// OpenDatabase does, and returns a pool of connections to it, see NewPool.
+// The database is closed by Pool.Close once the acquired connections are
+// released.
+func OpenPool(path string, systemConfig SystemConfig, config PoolConfig) (*Pool, error) {
+	database, err := OpenDatabase(path, systemConfig)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := NewPool(database, config)
+	if err != nil {
+		database.Close()
+		return nil, err
+	}
+	pool.ownsDatabase = true
+	return pool, nil
+}
+
+// Acquire returns a connection of the pool, opening one if no connection is
+// idle. If MaxConns connections are in use, Acquire waits for one to be
+// released, and returns the error of the context if it is done first. While
+// the pool is paused, Acquire waits for Resume in the same way, or returns
+// ErrPoolPaused if PoolConfig.FailWhenPaused is set. Once the pool is closed,
+// Acquire returns an error matching ErrClosed. In a context returned by
+// WithAffinity, Acquire returns the connection pinned to the context.
+func (pool *Pool) Acquire(ctx context.Context) (*PooledConnection, error) {
+	if affinity, ok := ctx.Value(affinityKey{pool}).(*poolAffinity); ok {
+		return affinity.acquire(ctx)
+	}
+	return pool.acquire(ctx)
+}
+
+// acquire returns a connection of the pool, ignoring the affinity of the
+// context.
+func (pool *Pool) acquire(ctx context.Context) (*PooledConnection, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := pool.waitResumed(ctx); err != nil {
+			return nil, err
+		}
+		select {
+		case pool.slots <- struct{}{}:
+		default:
+			pool.waitCount.Add(1)
+			start := time.Now()
+			select {
+			case pool.slots <- struct{}{}:
+				pool.waitDuration.Add(int64(time.Since(start)))
+			case <-ctx.Done():
+				pool.waitDuration.Add(int64(time.Since(start)))
+				return nil, ctx.Err()
+			case <-pool.done:
+				return nil, pool.closedError()
+			}
+		}
+		taken, err := pool.take()
+		if err != nil {
+			<-pool.slots
+			if errors.Is(err, ErrPoolPaused) && !pool.currentConfig().FailWhenPaused {
+				// The pool was paused while waiting for a slot.
+				continue
+			}
+			return nil, err
+		}
+		return &PooledConnection{Connection: taken.conn, pool: pool, opened: taken.opened, statements: taken.statements}, nil
+	}
+}
+
+// waitResumed waits until the pool is not paused, or returns ErrPoolPaused
+// if PoolConfig.FailWhenPaused is set.
+func (pool *Pool) waitResumed(ctx context.Context) error {
+	pool.mutex.Lock()
+	paused, resumed, failWhenPaused := pool.paused, pool.resumed, pool.config.FailWhenPaused
+	pool.mutex.Unlock()
+	if !paused {
+		return nil
+	}
+	if failWhenPaused {
+		return ErrPoolPaused
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-pool.done:
+		return pool.closedError()
+	}
+}
+
+// closedError returns the error of Acquire on a closed pool.
+func (pool *Pool) closedError() error {
+	return &Error{Op: OpOpen, Err: &closedError{"failed to acquire connection because the pool is closed"}}
+}
+
+// take returns the most recently released idle connection that has not
+// expired, or opens a new one, and applies PoolConfig.Settings to it. A slot
+// must have been acquired.
+func (pool *Pool) take() (idleConnection, error) {
+	pool.mutex.Lock()
+	if pool.closed {
+		pool.mutex.Unlock()
+		return idleConnection{}, pool.closedError()
+	}
+	if pool.paused {
+		pool.mutex.Unlock()
+		return idleConnection{}, ErrPoolPaused
+	}
+	expired := pool.expire(time.Now())
+	var taken idleConnection
+	for taken.conn == nil && len(pool.idle) > 0 {
+		last := pool.idle[len(pool.idle)-1]
+		pool.idle = pool.idle[:len(pool.idle)-1]
+		if last.conn.closed() {
+			// The connection has been closed by CloseAll.
+			pool.open--
+			pool.failedClosed.Add(1)
+			continue
+		}
+		taken = last
+	}
+	if taken.conn == nil {
+		pool.open++
+	}
+	pool.inUse++
+	pool.checkouts.Add(1)
+	settings := pool.config.Settings
+	pool.mutex.Unlock()
+	closeConnections(expired)
+	pool.wakeMaintainer()
+	if taken.conn == nil {
+		taken.opened = time.Now()
+		conn, err := pool.openConnection()
+		if err != nil {
+			pool.mutex.Lock()
+			pool.open--
+			pool.inUse--
+			pool.mutex.Unlock()
+			pool.checkouts.Done()
+			return idleConnection{}, err
+		}
+		taken.conn = conn
+	}
+	if settings != nil {
+		settings.apply(taken.conn)
+	}
+	return taken, nil
+}
+
+// openConnection opens a connection of the pool, configured by OnConnect and
+// warmed up by WarmUpQuery.
+func (pool *Pool) openConnection() (*Connection, error) {
+	config := pool.currentConfig()
+	conn, err := OpenConnection(pool.database)
+	if err != nil {
+		return nil, err
+	}
+	if config.OnConnect != nil {
+		if err := config.OnConnect(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if config.WarmUpQuery != "" {
+		if err := runStatement(conn, config.WarmUpQuery); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// expire removes the idle connections released before MaxIdleTime, but the
+// MinIdle most recently released, and returns them to be closed. pool.mutex
+// must be held.
+func (pool *Pool) expire(now time.Time) []*Connection {
+	if pool.config.MaxIdleTime <= 0 {
+		return nil
+	}
+	var expired []*Connection
+	for len(pool.idle) > pool.config.MinIdle && now.Sub(pool.idle[0].released) >= pool.config.MaxIdleTime {
+		expired = append(expired, pool.idle[0].conn)
+		pool.idle = pool.idle[1:]
+	}
+	pool.open -= len(expired)
+	pool.idleTimeClosed.Add(uint64(len(expired)))
+	return expired
+}
+
+// closeConnections closes the connections with the handles created from
+// them.
+func closeConnections(conns []*Connection) {
+	for _, conn := range conns {
+		conn.dropTempTables()
+		conn.closeWithHandles()
+	}
+}
+
+// Release gives the connection back to its pool. The connection is closed
+// instead if the engine failed its last query, statement or iteration, if it
+// is in a transaction, which is rolled back, or if the pool is closed.
+// Calling Release again has no effect.
+func (pooled *PooledConnection) Release() {
+	if !pooled.released.CompareAndSwap(false, true) {
+		return
+	}
+	if pooled.affinity != nil {
+		pooled.affinity.release(pooled)
+		return
+	}
+	pooled.pool.put(pooled.Connection, pooled.opened, pooled.statements)
+}
+
+// Close releases the connection like Release: the connections of a pool are
+// closed by the pool, when it no longer keeps them.
+func (pooled *PooledConnection) Close() {
+	pooled.Release()
+}
+
+// CloseWithContext releases the connection like Release and returns nil, for
+// the same reason as Close.
+func (pooled *PooledConnection) CloseWithContext(ctx context.Context) error {
+	pooled.Release()
+	return nil
+}
+
+// put gives back an acquired connection opened at the given time, with the
+// statements cached on it, closing those that are no longer cached.
+func (pool *Pool) put(conn *Connection, opened time.Time, statements *connStatements) {
+	pool.statements.sync(statements)
+	failed := conn.lastCallFailed.Load() || conn.closed()
+	if !failed && conn.rollbackForRelease() {
+		failed = true
+	}
+	now := time.Now()
+	pool.mutex.Lock()
+	pool.inUse--
+	if pool.inUse == 0 && pool.drained != nil {
+		close(pool.drained)
+		pool.drained = nil
+	}
+	var toClose []*Connection
+	expired := pool.config.MaxLifetime > 0 && now.Sub(opened) >= pool.config.MaxLifetime
+	if failed || pool.closed || expired || len(pool.idle) >= pool.config.MaxIdle {
+		pool.open--
+		toClose = append(toClose, conn)
+		switch {
+		case failed:
+			pool.failedClosed.Add(1)
+		case pool.closed:
+		case expired:
+			pool.lifetimeClosed.Add(1)
+		default:
+			pool.idleClosed.Add(1)
+		}
+	} else {
+		conn.resetDefaultParams()
+		pool.idle = append(pool.idle, idleConnection{conn: conn, opened: opened, released: now, statements: statements})
+		toClose = pool.expire(now)
+	}
+	pool.mutex.Unlock()
+	closeConnections(toClose)
+	<-pool.slots
+	pool.checkouts.Done()
+	pool.wakeMaintainer()
+}
+
+// rollbackForRelease rolls back the transaction a connection given back to
+// its pool is in, so that the writer of the database is given back, and
+// returns true if it was in one.
+func (conn *Connection) rollbackForRelease() bool {
+	conn.snapshotMutex.Lock()
+	tx, inTransaction := conn.transaction, conn.inTransaction
+	conn.snapshotMutex.Unlock()
+	switch {
+	case tx != nil:
+		tx.Close()
+		return true
+	case inTransaction:
+		runStatement(conn, "ROLLBACK;")
+		return true
+	}
+	return false
+}
+
+// Query acquires a connection, runs the query on it with QueryWithContext and
+// returns the result, whose Close method releases the connection. The results
+// of the next statements of the query, if any, must be closed before.
+func (pool *Pool) Query(ctx context.Context, query string) (*QueryResult, error) {
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := pooled.QueryWithContext(ctx, query)
+	if err != nil {
+		pooled.Release()
+		return nil, err
+	}
+	result.release = pooled.Release
+	return result, nil
+}
+
+// Stats returns a snapshot of the usage of the pool.
+func (pool *Pool) Stats() PoolStats {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	return PoolStats{
+		MaxConns:       pool.config.MaxConns,
+		Open:           pool.open,
+		InUse:          pool.inUse,
+		Idle:           len(pool.idle),
+		WaitCount:      pool.waitCount.Load(),
+		WaitDuration:   time.Duration(pool.waitDuration.Load()),
+		IdleTimeClosed: pool.idleTimeClosed.Load(),
+		IdleClosed:     pool.idleClosed.Load(),
+		LifetimeClosed: pool.lifetimeClosed.Load(),
+		FailedClosed:   pool.failedClosed.Load(),
+		Paused:         pool.paused,
+	}
+}
+
+// Pause stops handing out connections, e.g. before a checkpoint or a schema
+// migration, and waits for the acquired connections to be released. While the
+// pool is paused, Acquire waits for Resume or fails as described by Acquire,
+// and the maintenance runs on a connection returned by MaintenanceConn. If
+// the context is done before all the connections are released, Pause returns
+// the error of the context and the pool stays paused until Resume is called.
+// Pausing a paused pool waits for the same connections.
+func (pool *Pool) Pause(ctx context.Context) error {
+	pool.mutex.Lock()
+	if pool.closed {
+		pool.mutex.Unlock()
+		return &Error{Op: OpOpen, Err: &closedError{"failed to pause pool because it is closed"}}
+	}
+	if !pool.paused {
+		pool.paused = true
+		pool.resumed = make(chan struct{})
+	}
+	if pool.inUse == 0 {
+		pool.mutex.Unlock()
+		return nil
+	}
+	if pool.drained == nil {
+		pool.drained = make(chan struct{})
+	}
+	drained := pool.drained
+	pool.mutex.Unlock()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Resume hands out connections again after Pause and wakes up the calls to
+// Acquire waiting for it. It does nothing if the pool is not paused.
+func (pool *Pool) Resume() {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	if !pool.paused {
+		return
+	}
+	pool.paused = false
+	close(pool.resumed)
+	pool.resumed = nil
+	pool.wakeMaintainer()
+}
+
+// MaintenanceConn opens a dedicated connection to the database of the pool,
+// configured by PoolConfig.OnConnect, on which the maintenance runs while the
+// pool is paused. The connection does not count towards MaxConns and is not
+// affected by the pause. It must be closed by the caller, before the
+// database is closed.
+func (pool *Pool) MaintenanceConn() (*Connection, error) {
+	pool.mutex.Lock()
+	closed, onConnect := pool.closed, pool.config.OnConnect
+	pool.mutex.Unlock()
+	if closed {
+		return nil, &Error{Op: OpOpen, Err: &closedError{"failed to open maintenance connection because the pool is closed"}}
+	}
+	conn, err := OpenConnection(pool.database)
+	if err != nil {
+		return nil, err
+	}
+	if onConnect != nil {
+		if err := onConnect(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Close closes the idle connections of the pool and waits for the acquired
+// connections to be released, closing them as they are, and for the
+// connections opened to keep PoolConfig.MinIdle connections idle, and then
+// closes the database of a pool opened with OpenPool. Acquire fails once Close has been
+// called. If the context is done before all the connections are released,
+// Close returns the error of the context, and the remaining connections and
+// the database are closed when they are released. Calling Close again waits
+// for the same connections.
+func (pool *Pool) Close(ctx context.Context) error {
+	pool.mutex.Lock()
+	first := !pool.closed
+	if first {
+		pool.closed = true
+		close(pool.done)
+	}
+	idle := pool.idle
+	pool.idle = nil
+	pool.open -= len(idle)
+	pool.mutex.Unlock()
+	conns := make([]*Connection, len(idle))
+	for i, idleConn := range idle {
+		conns[i] = idleConn.conn
+	}
+	closeConnections(conns)
+
+	released := make(chan struct{})
+	background.spawnUnbounded("Pool close", nil, func() {
+		pool.checkouts.Wait()
+		if pool.maintained != nil {
+			<-pool.maintained
+		}
+		if first && pool.ownsDatabase {
+			pool.database.Close()
+		}
+		close(released)
+	})
+	select {
+	case <-released:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,112 @@
+package lbug
+
+import (
+	"context"
+	"sync"
+)
+
+// affinityKey is the key of the poolAffinity of a context returned by
+// Pool.WithAffinity, one per pool.
+type affinityKey struct {
+	pool *Pool
+}
+
+// poolAffinity pins the connections acquired in a context to one connection
+// of a pool.
+type poolAffinity struct {
+	pool *Pool
+	// turn holds a value while the pinned connection is acquired, so that
+	// the uses of the connection in the context follow each other.
+	turn chan struct{}
+
+	mutex sync.Mutex
+	// pinned is the pinned connection, whose conn is nil until it is first
+	// acquired and once it has been given back to the pool.
+	pinned idleConnection
+	ended  bool
+}
+
+// WithAffinity returns a context in which Acquire and Query return the same
+// connection of the pool, from the first time one is acquired until the
+// returned function is called, so that the reads made in the context see its
+// writes: a transaction begun on the connection with BEGIN TRANSACTION or
+// BeginTransaction stays open when the connection is released, and the
+// connection settings are kept. A connection acquired in the context must be
+// released before it is acquired again; Acquire waits for it meanwhile, or
+// returns the error of the context if it is done first.
+//
+// The returned function gives the connection back to the pool once it is
+// released, which closes it if it is in a transaction, as Release does. It
+// must be called: the pinned connection counts as in use until then, for
+// MaxConns, Pause and Close. Acquire fails with an error matching ErrClosed in
+// the context after that. A connection whose query failed in the engine is
+// closed on release, as a connection acquired without affinity, and the next
+// Acquire in the context pins another one.
+func (pool *Pool) WithAffinity(ctx context.Context) (context.Context, func()) {
+	affinity := &poolAffinity{pool: pool, turn: make(chan struct{}, 1)}
+	return context.WithValue(ctx, affinityKey{pool}, affinity), affinity.end
+}
+
+// acquire returns the pinned connection, acquiring it from the pool the first
+// time.
+func (affinity *poolAffinity) acquire(ctx context.Context) (*PooledConnection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	select {
+	case affinity.turn <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	affinity.mutex.Lock()
+	pinned, ended := affinity.pinned, affinity.ended
+	affinity.mutex.Unlock()
+	if ended {
+		<-affinity.turn
+		return nil, &Error{Op: OpOpen, Err: &closedError{"failed to acquire connection because the affinity has ended"}}
+	}
+	if pinned.conn == nil {
+		pooled, err := affinity.pool.acquire(ctx)
+		if err != nil {
+			<-affinity.turn
+			return nil, err
+		}
+		pinned = idleConnection{conn: pooled.Connection, opened: pooled.opened, statements: pooled.statements}
+	}
+	pooled := &PooledConnection{Connection: pinned.conn, pool: affinity.pool, opened: pinned.opened, statements: pinned.statements, affinity: affinity}
+	return pooled, nil
+}
+
+// release ends the use of the pinned connection, keeping the statements
+// cached on it by PrepareCached that are still cached by the pool. The
+// connection is given back to the pool, which closes it, if its last query
+// failed or it was closed.
+func (affinity *poolAffinity) release(pooled *PooledConnection) {
+	if pooled.lastCallFailed.Load() || pooled.closed() {
+		affinity.mutex.Lock()
+		affinity.pinned = idleConnection{}
+		affinity.mutex.Unlock()
+		affinity.pool.put(pooled.Connection, pooled.opened, pooled.statements)
+	} else {
+		affinity.pool.statements.sync(pooled.statements)
+		affinity.mutex.Lock()
+		affinity.pinned = idleConnection{conn: pooled.Connection, opened: pooled.opened, statements: pooled.statements}
+		affinity.mutex.Unlock()
+	}
+	<-affinity.turn
+}
+
+// end gives the pinned connection back to the pool once it is released. It
+// may be called more than once.
+func (affinity *poolAffinity) end() {
+	affinity.turn <- struct{}{}
+	defer func() { <-affinity.turn }()
+	affinity.mutex.Lock()
+	pinned := affinity.pinned
+	affinity.pinned = idleConnection{}
+	affinity.ended = true
+	affinity.mutex.Unlock()
+	if pinned.conn != nil {
+		affinity.pool.put(pinned.conn, pinned.opened, pinned.statements)
+	}
+}
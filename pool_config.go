@@ -0,0 +1,102 @@
+package lbug
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrImmutableOption is matched by the error of Pool.UpdateConfig when the
+// update changes a setting that is fixed for the lifetime of the pool.
+var ErrImmutableOption = errors.New("option cannot be changed while the pool is open")
+
+// ConnectionSettings are the settings of the connections of a Pool that can be
+// changed while the pool is used, see PoolConfig.Settings. Each one is
+// applied with the setter of Connection of the same name; their zero values
+// disable the limits.
+type ConnectionSettings struct {
+	QueryTimeout     time.Duration
+	MaxRows          uint64
+	MaxParameterSize uint64
+	MaxPathElements  uint64
+}
+
+// apply sets the settings on a connection that is not in use.
+func (settings *ConnectionSettings) apply(conn *Connection) {
+	conn.SetQueryTimeout(settings.QueryTimeout)
+	conn.SetMaxRows(settings.MaxRows)
+	conn.SetMaxParameterSize(settings.MaxParameterSize)
+	conn.SetMaxPathElements(settings.MaxPathElements)
+}
+
+// currentConfig returns the configuration of the pool.
+func (pool *Pool) currentConfig() PoolConfig {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	return pool.config
+}
+
+// UpdateConfig changes the configuration of the pool while it is used, e.g.
+// to lower the row limit of its connections. update is called with a copy of
+// the configuration, which replaces it at once if it is valid, so that the
+// calls to Acquire see either the old or the new configuration, never a mix
+// of both. The calls to UpdateConfig are serialized, and update must not use
+// the pool.
+//
+// The new configuration applies to the connections acquired after
+// UpdateConfig returns, including the connections opened before: their
+// Settings are set when they are acquired, never while they are in use, and
+// clearing Settings resets them to the zero ConnectionSettings, which disables
+// the limits, rather than leaving them as they were last set. The
+// idle connections beyond MaxIdle or idle for longer than MaxIdleTime are
+// closed at once. OnConnect and WarmUpQuery apply to the connections opened
+// afterwards only. MaxConns, MinIdle and StatementCacheSize size the pool and
+// cannot be changed: UpdateConfig then returns an error matching
+// ErrImmutableOption and leaves the configuration unchanged, as it does if the
+// new configuration is not valid for NewPool or the pool is closed.
+func (pool *Pool) UpdateConfig(update func(config *PoolConfig)) error {
+	pool.updating.Lock()
+	defer pool.updating.Unlock()
+	current := pool.currentConfig()
+	config := current
+	if config.Settings != nil {
+		settings := *config.Settings
+		config.Settings = &settings
+	}
+	update(&config)
+	if current.Settings != nil && config.Settings == nil {
+		// The connections opened before keep the settings applied last
+		// unless they are reset.
+		config.Settings = &ConnectionSettings{}
+	}
+	switch {
+	case config.MaxConns != current.MaxConns:
+		return fmt.Errorf("failed to update MaxConns from %d to %d: %w", current.MaxConns, config.MaxConns, ErrImmutableOption)
+	case config.MinIdle != current.MinIdle:
+		return fmt.Errorf("failed to update MinIdle from %d to %d: %w", current.MinIdle, config.MinIdle, ErrImmutableOption)
+	case config.StatementCacheSize != current.StatementCacheSize:
+		return fmt.Errorf("failed to update StatementCacheSize from %d to %d: %w", current.StatementCacheSize, config.StatementCacheSize, ErrImmutableOption)
+	}
+	if err := config.normalize(); err != nil {
+		return err
+	}
+	pool.mutex.Lock()
+	if pool.closed {
+		pool.mutex.Unlock()
+		return &Error{Op: OpOpen, Err: &closedError{"failed to update pool configuration because the pool is closed"}}
+	}
+	pool.config = config
+	var toClose []*Connection
+	if excess := len(pool.idle) - config.MaxIdle; excess > 0 {
+		for _, idleConn := range pool.idle[:excess] {
+			toClose = append(toClose, idleConn.conn)
+		}
+		pool.idle = pool.idle[excess:]
+		pool.open -= excess
+		pool.idleClosed.Add(uint64(excess))
+	}
+	toClose = append(toClose, pool.expire(time.Now())...)
+	pool.mutex.Unlock()
+	closeConnections(toClose)
+	return nil
+}
@@ -0,0 +1,83 @@
+package lbug
+
+import "time"
+
+// poolRefillRetry is the time the pool waits before opening connections again
+// to keep PoolConfig.MinIdle connections idle once opening one has failed.
+const poolRefillRetry = time.Second
+
+// wakeMaintainer wakes up the goroutine keeping PoolConfig.MinIdle
+// connections idle, if any, after idle connections may have been acquired or
+// closed.
+func (pool *Pool) wakeMaintainer() {
+	if pool.refill == nil {
+		return
+	}
+	select {
+	case pool.refill <- struct{}{}:
+	default:
+	}
+}
+
+// maintainIdle opens connections until PoolConfig.MinIdle connections are
+// idle each time it is woken up, until the pool is closed.
+func (pool *Pool) maintainIdle() {
+	defer close(pool.maintained)
+	for {
+		wait := pool.refill
+		var retry <-chan time.Time
+		if err := pool.refillIdle(); err != nil {
+			// Opening connections is retried later, or as soon as a
+			// connection is opened or released.
+			retry = time.After(poolRefillRetry)
+		}
+		select {
+		case <-pool.done:
+			return
+		case <-wait:
+		case <-retry:
+		}
+	}
+}
+
+// refillIdle opens connections until PoolConfig.MinIdle connections are idle,
+// MaxConns connections are open or the pool is paused or closed. A slot is
+// held while a connection is opened, so that the connections opened by
+// Acquire and refillIdle never exceed MaxConns together.
+func (pool *Pool) refillIdle() error {
+	for {
+		pool.mutex.Lock()
+		if pool.closed || pool.paused || len(pool.idle) >= pool.config.MinIdle || pool.open >= pool.config.MaxConns {
+			pool.mutex.Unlock()
+			return nil
+		}
+		select {
+		case pool.slots <- struct{}{}:
+		default:
+			// The connections are all in use.
+			pool.mutex.Unlock()
+			return nil
+		}
+		pool.open++
+		pool.mutex.Unlock()
+
+		opened := time.Now()
+		conn, err := pool.openConnection()
+		pool.mutex.Lock()
+		closed := pool.closed
+		if err != nil || closed {
+			pool.open--
+		} else {
+			pool.idle = append(pool.idle, idleConnection{conn: conn, opened: opened, released: time.Now()})
+		}
+		pool.mutex.Unlock()
+		<-pool.slots
+		if err != nil {
+			return err
+		}
+		if closed {
+			closeConnections([]*Connection{conn})
+			return nil
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package lbug
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// StatementCacheStats is a snapshot of the statement cache of a Pool.
+type StatementCacheStats struct {
+	// Size is the number of query texts cached.
+	Size int
+	// Hits is the number of calls to PrepareCached that reused a statement
+	// prepared on the same connection, and Misses the number of calls that
+	// prepared one.
+	Hits   uint64
+	Misses uint64
+	// Evictions is the number of query texts evicted because the cache was
+	// full, and Invalidations the number of times the whole cache was
+	// dropped because the schema of the database changed.
+	Evictions     uint64
+	Invalidations uint64
+	// ByQuery are the hits and misses of the query texts cached, by the hash
+	// of their text, so that the texts, which may hold literal values, are
+	// not kept in the statistics.
+	ByQuery map[uint64]QueryCacheStats
+}
+
+// QueryCacheStats are the hits and misses of a query text in the statement
+// cache of a Pool.
+type QueryCacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// statementCache is the cache of the query texts whose statements are
+// prepared on the connections of a pool. The statements themselves are kept
+// per connection, in connStatements, and prepared on each connection the
+// first time the text is used on it. Evicting a text bumps the epoch, which
+// makes every connection close its statements of the texts no longer cached
+// when it is released.
+type statementCache struct {
+	capacity int
+	database *Database
+
+	mutex sync.Mutex
+	// entries are the cached texts, whose elements in lru hold their
+	// *cachedQuery, from the most recently used.
+	entries map[string]*list.Element
+	lru     list.List
+	epoch   uint64
+	// schemaChanges is the number of schema changes of the database when the
+	// cache was last dropped, see invalidate.
+	schemaChanges uint64
+
+	hits, misses, evictions, invalidations uint64
+}
+
+// cachedQuery is a query text cached by a statementCache.
+type cachedQuery struct {
+	query  string
+	hash   uint64
+	hits   uint64
+	misses uint64
+}
+
+// connStatements are the statements cached on a connection of a pool, by
+// query text, as of the epoch and the invalidations of the cache. stale is set
+// when a statement may have been prepared for a text that is no longer
+// cached, and retired holds the statements prepared before the schema
+// changed, which are closed on release. Only the goroutine that acquired the
+// connection uses them.
+type connStatements struct {
+	epoch         uint64
+	invalidations uint64
+	stale         bool
+	byQuery       map[string]*PreparedStatement
+	retired       []*PreparedStatement
+}
+
+func newStatementCache(database *Database, capacity int) *statementCache {
+	cache := &statementCache{capacity: capacity, database: database, entries: make(map[string]*list.Element)}
+	cache.schemaChanges = cache.currentSchemaChanges()
+	return cache
+}
+
+// currentSchemaChanges returns the number of committed schema changes of the
+// database.
+func (cache *statementCache) currentSchemaChanges() uint64 {
+	if cache.database.snapshot == nil {
+		return 0
+	}
+	return cache.database.snapshot.schemaChanges.Load()
+}
+
+// invalidate drops the cache if the schema of the database has changed since
+// it was last dropped, since the statements prepared before may no longer
+// match the catalog. cache.mutex must be held.
+func (cache *statementCache) invalidate() {
+	schemaChanges := cache.currentSchemaChanges()
+	if schemaChanges == cache.schemaChanges {
+		return
+	}
+	cache.schemaChanges = schemaChanges
+	if len(cache.entries) == 0 {
+		return
+	}
+	clear(cache.entries)
+	cache.lru.Init()
+	cache.epoch++
+	cache.invalidations++
+}
+
+// lookup counts a use of the query text, caching it if it is not, and returns
+// whether it is cached and the epoch of the cache.
+func (cache *statementCache) lookup(query string, hit bool) (bool, uint64) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.invalidate()
+	if hit {
+		cache.hits++
+	} else {
+		cache.misses++
+	}
+	element, ok := cache.entries[query]
+	if !ok {
+		if cache.capacity == 0 {
+			return false, cache.epoch
+		}
+		if cache.lru.Len() >= cache.capacity {
+			oldest := cache.lru.Back()
+			cache.lru.Remove(oldest)
+			delete(cache.entries, oldest.Value.(*cachedQuery).query)
+			cache.epoch++
+			cache.evictions++
+		}
+		element = cache.lru.PushFront(&cachedQuery{query: query, hash: queryHash(query)})
+		cache.entries[query] = element
+	}
+	cache.lru.MoveToFront(element)
+	entry := element.Value.(*cachedQuery)
+	if hit {
+		entry.hits++
+	} else {
+		entry.misses++
+	}
+	return true, cache.epoch
+}
+
+// retire moves the statements of the connection to retired if the schema of
+// the database has changed since they were prepared, so that they are no
+// longer used but stay valid until the connection is released.
+func (cache *statementCache) retire(statements *connStatements) {
+	cache.mutex.Lock()
+	cache.invalidate()
+	invalidations := cache.invalidations
+	cache.mutex.Unlock()
+	if statements.invalidations == invalidations {
+		return
+	}
+	for query, statement := range statements.byQuery {
+		statements.retired = append(statements.retired, statement)
+		delete(statements.byQuery, query)
+	}
+	statements.invalidations = invalidations
+}
+
+// sync closes the retired statements of a released connection, and those
+// whose texts are no longer cached if the cache has evicted texts since they
+// were last synced or one of them is stale.
+func (cache *statementCache) sync(statements *connStatements) {
+	if statements == nil {
+		return
+	}
+	cache.retire(statements)
+	stale := statements.retired
+	statements.retired = nil
+	cache.mutex.Lock()
+	epoch := cache.epoch
+	if statements.stale || statements.epoch != epoch {
+		for query, statement := range statements.byQuery {
+			if _, ok := cache.entries[query]; !ok {
+				stale = append(stale, statement)
+				delete(statements.byQuery, query)
+			}
+		}
+		statements.epoch, statements.stale = epoch, false
+	}
+	cache.mutex.Unlock()
+	for _, statement := range stale {
+		statement.Close()
+	}
+}
+
+// stats returns a snapshot of the cache.
+func (cache *statementCache) stats() StatementCacheStats {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	stats := StatementCacheStats{
+		Size:          cache.lru.Len(),
+		Hits:          cache.hits,
+		Misses:        cache.misses,
+		Evictions:     cache.evictions,
+		Invalidations: cache.invalidations,
+		ByQuery:       make(map[uint64]QueryCacheStats, cache.lru.Len()),
+	}
+	for element := cache.lru.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*cachedQuery)
+		byQuery := stats.ByQuery[entry.hash]
+		byQuery.Hits += entry.hits
+		byQuery.Misses += entry.misses
+		stats.ByQuery[entry.hash] = byQuery
+	}
+	return stats
+}
+
+// PrepareCached returns a statement of the query prepared on the connection,
+// reusing the one prepared by an earlier call on the same connection if the
+// query text is still in the statement cache of the pool, see
+// PoolConfig.StatementCacheSize, and the schema of the database has not
+// changed since. The statement belongs to the cache: it must not be closed,
+// and must not be used once the connection is released. The statements of
+// the texts evicted from the cache, or prepared before the schema changed,
+// are closed on every connection when it is released.
+func (pooled *PooledConnection) PrepareCached(query string) (*PreparedStatement, error) {
+	cache := pooled.pool.statements
+	if pooled.statements == nil {
+		pooled.statements = &connStatements{byQuery: make(map[string]*PreparedStatement)}
+	}
+	statements := pooled.statements
+	cache.retire(statements)
+	statement, hit := statements.byQuery[query]
+	if hit && statement.closed() {
+		// The statement was closed with the handles of the connection.
+		delete(statements.byQuery, query)
+		hit = false
+	}
+	cached, epoch := cache.lookup(query, hit)
+	if hit {
+		return statement, nil
+	}
+	statement, err := pooled.Prepare(query)
+	if err != nil {
+		statement.Close()
+		return nil, err
+	}
+	if !cached || epoch != statements.epoch {
+		// The text is not cached, or texts were evicted meanwhile: the next
+		// sync closes the statement if it is no longer cached.
+		statements.stale = true
+	}
+	statements.byQuery[query] = statement
+	return statement, nil
+}
+
+// Execute acquires a connection, executes the query on it with the arguments
+// using a statement of the statement cache, see PrepareCached, and returns
+// the result, whose Close method releases the connection.
+func (pool *Pool) Execute(ctx context.Context, query string, args map[string]any) (*QueryResult, error) {
+	pooled, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statement, err := pooled.PrepareCached(query)
+	if err != nil {
+		pooled.Release()
+		return nil, err
+	}
+	result, err := pooled.ExecuteWithContext(ctx, statement, args)
+	if err != nil {
+		pooled.Release()
+		return nil, err
+	}
+	result.release = pooled.Release
+	return result, nil
+}
+
+// StatementCacheStats returns a snapshot of the statement cache of the pool.
+func (pool *Pool) StatementCacheStats() StatementCacheStats {
+	return pool.statements.stats()
+}
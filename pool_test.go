@@ -0,0 +1,947 @@
+package lbug
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolConcurrentQueries(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, conn := setupTestDatabase(t)
+	defer db.Close()
+	defer conn.Close()
+	createTestData(t, conn, 100)
+
+	pool, err := NewPool(db, PoolConfig{MaxConns: 8})
+	assert.Nil(t, err)
+	const numGoroutines = 100
+	const queriesPerGoroutine = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, numGoroutines*queriesPerGoroutine)
+	for g := range numGoroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queriesPerGoroutine {
+				if (g+i)%2 == 0 {
+					pooled, err := pool.Acquire(context.Background())
+					if err != nil {
+						errs <- err
+						return
+					}
+					err = runQueryAndIterate(pooled.Connection)
+					pooled.Release()
+					if err != nil {
+						errs <- err
+						return
+					}
+					continue
+				}
+				result, err := pool.Query(context.Background(), "MATCH (n:Node) RETURN count(*);")
+				if err != nil {
+					errs <- err
+					return
+				}
+				tuple, err := result.Next()
+				if err == nil {
+					var count any
+					count, err = tuple.GetValue(0)
+					tuple.Close()
+					if err == nil && count != int64(100) {
+						t.Errorf("got count %v", count)
+					}
+				}
+				result.Close()
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	stats := pool.Stats()
+	assert.Equal(t, 8, stats.MaxConns)
+	assert.Equal(t, 0, stats.InUse)
+	assert.Equal(t, stats.Open, stats.Idle)
+	assert.True(t, stats.Open > 0 && stats.Open <= 8, stats.Open)
+	assert.Nil(t, pool.Close(context.Background()))
+	assert.Equal(t, 0, pool.Stats().Open)
+}
+
+func TestPoolAcquireWaits(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 1})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+
+	pooled, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = pool.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, uint64(1), pool.Stats().WaitCount)
+
+	acquired := make(chan *PooledConnection)
+	go func() {
+		second, err := pool.Acquire(context.Background())
+		assert.Nil(t, err)
+		acquired <- second
+	}()
+	time.Sleep(10 * time.Millisecond)
+	first := pooled.Connection
+	pooled.Release()
+	pooled.Release()
+	second := <-acquired
+	// The released connection is reused.
+	assert.Equal(t, first, second.Connection)
+	stats := pool.Stats()
+	assert.Equal(t, 1, stats.Open)
+	assert.Equal(t, 1, stats.InUse)
+	assert.Equal(t, 0, stats.Idle)
+	second.Release()
+}
+
+func TestPooledConnectionCloseReleases(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 1})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+
+	pooled, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	first := pooled.Connection
+	pooled.Close()
+	pooled.Close()
+	// The connection is given back to the pool instead of being closed.
+	assert.False(t, first.closed())
+	stats := pool.Stats()
+	assert.Equal(t, 1, stats.Open)
+	assert.Equal(t, 0, stats.InUse)
+	assert.Equal(t, 1, stats.Idle)
+
+	second, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, first, second.Connection)
+	assert.Nil(t, second.CloseWithContext(context.Background()))
+	assert.False(t, first.closed())
+	assert.Equal(t, 1, pool.Stats().Idle)
+}
+
+func TestPoolDiscardsFailedConnections(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	var opened int
+	pool, err := NewPool(db, PoolConfig{MaxConns: 2, OnConnect: func(conn *Connection) error {
+		opened++
+		conn.SetMaxRows(10)
+		return nil
+	}})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+
+	pooled, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(10), pooled.maxRows)
+	_, err = pooled.Query("MATCH (n:missing) RETURN n;")
+	assert.NotNil(t, err)
+	pooled.Release()
+	assert.Equal(t, PoolStats{MaxConns: 2, FailedClosed: 1}, pool.Stats())
+
+	// A successful query after a failed one keeps the connection.
+	pooled, err = pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	_, err = pooled.Query("RETURN $missing;")
+	assert.NotNil(t, err)
+	result, err := pooled.Query("RETURN 1;")
+	assert.Nil(t, err)
+	result.Close()
+	pooled.Release()
+	assert.Equal(t, 1, pool.Stats().Idle)
+	assert.Equal(t, 2, opened)
+
+	_, err = pool.Query(context.Background(), "RETURN")
+	assert.NotNil(t, err)
+	assert.Equal(t, PoolStats{MaxConns: 2, FailedClosed: 2}, pool.Stats())
+}
+
+func TestPoolMaxIdleTime(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 2, MaxIdleTime: 10 * time.Millisecond})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+
+	pooled, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	pooled.Release()
+	time.Sleep(20 * time.Millisecond)
+	pooled, err = pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	pooled.Release()
+	stats := pool.Stats()
+	assert.Equal(t, uint64(1), stats.IdleTimeClosed)
+	assert.Equal(t, 1, stats.Open)
+}
+
+func TestPoolMaxIdleAndLifetime(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 3, MaxIdle: 1, MaxLifetime: 50 * time.Millisecond})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+
+	var acquired []*PooledConnection
+	for range 3 {
+		pooled, err := pool.Acquire(context.Background())
+		assert.Nil(t, err)
+		acquired = append(acquired, pooled)
+	}
+	for _, pooled := range acquired {
+		pooled.Release()
+	}
+	stats := pool.Stats()
+	assert.Equal(t, uint64(2), stats.IdleClosed)
+	assert.Equal(t, 1, stats.Idle)
+
+	// The idle connection is reused until its lifetime is over.
+	pooled, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	time.Sleep(60 * time.Millisecond)
+	pooled.Release()
+	stats = pool.Stats()
+	assert.Equal(t, uint64(1), stats.LifetimeClosed)
+	assert.Equal(t, 0, stats.Open)
+}
+
+func TestPoolReleaseInTransaction(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 2})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+
+	pooled, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	tx, err := pooled.BeginTransaction(context.Background())
+	assert.Nil(t, err)
+	pooled.Release()
+	assert.ErrorIs(t, tx.Commit(), ErrClosed)
+	pooled, err = pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	result, err := pooled.Query("BEGIN TRANSACTION;")
+	assert.Nil(t, err)
+	result.Close()
+	pooled.Release()
+	stats := pool.Stats()
+	assert.Equal(t, uint64(2), stats.FailedClosed)
+	assert.Equal(t, 0, stats.Idle)
+
+	// The writer of the database has been given back.
+	pooled, err = pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	defer pooled.Release()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	tx, err = pooled.BeginTransaction(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Commit())
+}
+
+// waitForIdle waits for n connections of the pool to be idle.
+func waitForIdle(t *testing.T, pool *Pool, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Idle != n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, n, pool.Stats().Idle)
+}
+
+func TestPoolMinIdle(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	var warmed atomic.Int64
+	pool, err := NewPool(db, PoolConfig{MaxConns: 3, MinIdle: 2, WarmUpQuery: "CALL show_tables() RETURN *;",
+		OnConnect: func(conn *Connection) error {
+			warmed.Add(1)
+			return nil
+		}})
+	assert.Nil(t, err)
+	// The idle connections are opened in the background.
+	waitForIdle(t, pool, 2)
+	assert.Equal(t, int64(2), warmed.Load())
+
+	// A connection closed because its query failed is replaced.
+	first, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	second, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	_, err = first.Query("MATCH (n:missing) RETURN n;")
+	assert.NotNil(t, err)
+	first.Release()
+	second.Release()
+	waitForIdle(t, pool, 2)
+	stats := pool.Stats()
+	assert.Equal(t, uint64(1), stats.FailedClosed)
+	assert.LessOrEqual(t, stats.Open, 3)
+
+	// Closing the pool stops the maintainer.
+	assert.Nil(t, pool.Close(context.Background()))
+	assert.Equal(t, 0, pool.Stats().Open)
+}
+
+func TestPoolWarmUpQueryFails(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 1, WarmUpQuery: "MATCH (n:missing) RETURN n;"})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+	_, err = pool.Acquire(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, PoolStats{MaxConns: 1}, pool.Stats())
+
+	_, err = NewPool(db, PoolConfig{MaxConns: 2, MinIdle: 3})
+	assert.ErrorContains(t, err, "exceeds the maximum")
+	_, err = NewPool(db, PoolConfig{MinIdle: -1})
+	assert.ErrorContains(t, err, "cannot be negative")
+}
+
+func TestOpenPool(t *testing.T) {
+	checkBackgroundTasks(t)
+	pool, err := OpenPool(":memory:", DefaultSystemConfig(), PoolConfig{MaxConns: 1, MaxIdle: 1, MaxLifetime: time.Nanosecond})
+	assert.Nil(t, err)
+	pooled, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	result, err := pooled.Query("UNWIND range(1, 3) AS i RETURN i;")
+	assert.Nil(t, err)
+	// The connection is closed on release, with the result outliving it.
+	pooled.Release()
+	assert.True(t, pooled.Connection.closed())
+	assert.True(t, result.isClosed)
+	_, err = result.Next()
+	assert.ErrorIs(t, err, ErrClosed)
+	result.Close()
+
+	database := pool.database
+	assert.Nil(t, pool.Close(context.Background()))
+	assert.True(t, database.closed())
+
+	_, err = OpenPool(":memory:", DefaultSystemConfig(), PoolConfig{MaxIdle: -1})
+	assert.ErrorContains(t, err, "cannot be negative")
+}
+
+func TestPoolClose(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 1})
+	assert.Nil(t, err)
+
+	result, err := pool.Query(context.Background(), "RETURN 1;")
+	assert.Nil(t, err)
+	waiting := make(chan error)
+	go func() {
+		_, err := pool.Acquire(context.Background())
+		waiting <- err
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	// The result holds the connection.
+	assert.ErrorIs(t, pool.Close(ctx), context.DeadlineExceeded)
+	assert.ErrorIs(t, <-waiting, ErrClosed)
+	_, err = pool.Acquire(context.Background())
+	assert.ErrorIs(t, err, ErrClosed)
+
+	conn := result.connection
+	result.Close()
+	assert.Nil(t, pool.Close(context.Background()))
+	assert.True(t, conn.closed())
+	assert.Equal(t, PoolStats{MaxConns: 1}, pool.Stats())
+}
+
+func TestPoolQueryAutoClose(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 1, OnConnect: func(conn *Connection) error {
+		conn.SetAutoCloseResults(true)
+		return nil
+	}})
+	assert.Nil(t, err)
+
+	result, err := pool.Query(context.Background(), "UNWIND range(1, 3) AS i RETURN i;")
+	assert.Nil(t, err)
+	for result.HasNext() {
+		tuple, err := result.Next()
+		assert.Nil(t, err)
+		tuple.Close()
+	}
+	// The auto-closed result has given its connection back.
+	assert.True(t, result.isClosed)
+	assert.Equal(t, 0, pool.Stats().InUse)
+	result.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Nil(t, pool.Close(ctx))
+}
+
+func TestNewPoolErrors(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	_, err = NewPool(db, PoolConfig{MaxConns: -1})
+	assert.ErrorContains(t, err, "cannot be negative")
+	db.Close()
+	_, err = NewPool(db, PoolConfig{})
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestPoolPauseUnderLoad(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, conn := setupTestDatabase(t)
+	defer db.Close()
+	defer conn.Close()
+	createTestData(t, conn, 100)
+	pool, err := NewPool(db, PoolConfig{MaxConns: 4})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+
+	var active atomic.Int64
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				pooled, err := pool.Acquire(context.Background())
+				if err != nil {
+					errs <- err
+					return
+				}
+				active.Add(1)
+				result, err := pooled.Query("MATCH (n:Node) RETURN count(*);")
+				if err == nil {
+					result.Close()
+				}
+				active.Add(-1)
+				pooled.Release()
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	for range 3 {
+		time.Sleep(5 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		assert.Nil(t, pool.Pause(ctx))
+		cancel()
+		stats := pool.Stats()
+		assert.True(t, stats.Paused)
+		assert.Equal(t, 0, stats.InUse)
+		assert.Equal(t, int64(0), active.Load())
+
+		maintenance, err := pool.MaintenanceConn()
+		assert.Nil(t, err)
+		result, err := maintenance.Query("CHECKPOINT;")
+		assert.Nil(t, err)
+		result.Close()
+		// No pooled connection is handed out during the maintenance.
+		assert.Equal(t, int64(0), active.Load())
+		maintenance.Close()
+		pool.Resume()
+	}
+	close(stop)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	assert.False(t, pool.Stats().Paused)
+	assert.Equal(t, uint64(0), pool.Stats().FailedClosed)
+}
+
+func TestPoolPause(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 2})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+
+	pooled, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, pool.Pause(ctx), context.DeadlineExceeded)
+	// The pool stays paused.
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = pool.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	paused := make(chan error)
+	go func() {
+		paused <- pool.Pause(context.Background())
+	}()
+	acquired := make(chan *PooledConnection)
+	go func() {
+		pooled, err := pool.Acquire(context.Background())
+		assert.Nil(t, err)
+		acquired <- pooled
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pooled.Release()
+	assert.Nil(t, <-paused)
+	select {
+	case <-acquired:
+		t.Fatal("acquired a connection while the pool is paused")
+	case <-time.After(10 * time.Millisecond):
+	}
+	pool.Resume()
+	pooled = <-acquired
+	assert.False(t, pool.Stats().Paused)
+	pooled.Release()
+	pool.Resume()
+}
+
+func TestPoolPauseFailFast(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	var opened int
+	pool, err := NewPool(db, PoolConfig{MaxConns: 1, FailWhenPaused: true, OnConnect: func(conn *Connection) error {
+		opened++
+		return nil
+	}})
+	assert.Nil(t, err)
+
+	assert.Nil(t, pool.Pause(context.Background()))
+	_, err = pool.Acquire(context.Background())
+	assert.ErrorIs(t, err, ErrPoolPaused)
+	_, err = pool.Query(context.Background(), "RETURN 1;")
+	assert.ErrorIs(t, err, ErrPoolPaused)
+	// The maintenance connection bypasses the pause and MaxConns.
+	maintenance, err := pool.MaintenanceConn()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, opened)
+	assert.Equal(t, 0, pool.Stats().Open)
+	pool.Resume()
+	pooled, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	pooled.Release()
+	maintenance.Close()
+
+	assert.Nil(t, pool.Close(context.Background()))
+	assert.ErrorIs(t, pool.Pause(context.Background()), ErrClosed)
+	_, err = pool.MaintenanceConn()
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestPoolAffinity(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 2})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+	res, err := pool.Query(context.Background(), "CREATE NODE TABLE Item(id INT64, PRIMARY KEY(id));")
+	assert.Nil(t, err)
+	res.Close()
+	count := func(ctx context.Context) any {
+		res, err := pool.Query(ctx, "MATCH (i:Item) RETURN count(*);")
+		if !assert.Nil(t, err) {
+			return nil
+		}
+		defer res.Close()
+		tuple, err := res.Next()
+		assert.Nil(t, err)
+		defer tuple.Close()
+		value, err := tuple.GetValue(0)
+		assert.Nil(t, err)
+		return value
+	}
+	write := func(ctx context.Context) {
+		pooled, err := pool.Acquire(ctx)
+		assert.Nil(t, err)
+		defer pooled.Release()
+		for _, query := range []string{"BEGIN TRANSACTION;", "CREATE (:Item {id: 1});"} {
+			res, err := pooled.Query(query)
+			assert.Nil(t, err)
+			res.Close()
+		}
+	}
+
+	// Without affinity, the next read may use another connection, and the
+	// write made in a transaction is rolled back on release.
+	write(context.Background())
+	assert.Equal(t, int64(0), count(context.Background()))
+
+	ctx, end := pool.WithAffinity(context.Background())
+	write(ctx)
+	first, err := pool.Acquire(ctx)
+	assert.Nil(t, err)
+	conn := first.Connection
+	first.Release()
+	assert.Equal(t, int64(1), count(ctx))
+	second, err := pool.Acquire(ctx)
+	assert.Nil(t, err)
+	assert.Same(t, conn, second.Connection)
+	assert.Equal(t, 1, pool.Stats().InUse)
+	res, err = second.Query("COMMIT;")
+	assert.Nil(t, err)
+	res.Close()
+	second.Release()
+	assert.Equal(t, 1, pool.Stats().InUse)
+	end()
+	end()
+	assert.Equal(t, 0, pool.Stats().InUse)
+	assert.Equal(t, int64(1), count(context.Background()))
+	_, err = pool.Acquire(ctx)
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestPoolAffinityWaits(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 2})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+	ctx, end := pool.WithAffinity(context.Background())
+	pooled, err := pool.Acquire(ctx)
+	assert.Nil(t, err)
+
+	// The pinned connection is acquired once at a time.
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = pool.Acquire(timeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// A failed connection is closed on release and another one is pinned.
+	_, err = pooled.Query("MATCH (n:missing) RETURN n;")
+	assert.NotNil(t, err)
+	conn := pooled.Connection
+	pooled.Release()
+	assert.Equal(t, uint64(1), pool.Stats().FailedClosed)
+	pooled, err = pool.Acquire(ctx)
+	assert.Nil(t, err)
+	assert.NotSame(t, conn, pooled.Connection)
+
+	// end waits for the pinned connection to be released.
+	ended := make(chan struct{})
+	go func() {
+		end()
+		close(ended)
+	}()
+	select {
+	case <-ended:
+		t.Fatal("end returned before the connection was released")
+	case <-time.After(20 * time.Millisecond):
+	}
+	pooled.Release()
+	<-ended
+	stats := pool.Stats()
+	assert.Equal(t, 0, stats.InUse)
+	assert.Equal(t, 1, stats.Idle)
+}
+
+func TestPoolUpdateConfig(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	settings := &ConnectionSettings{MaxRows: 5}
+	pool, err := NewPool(db, PoolConfig{MaxConns: 3, Settings: settings})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+	// The pool does not share the settings with the caller.
+	settings.MaxRows = 1
+
+	var pooled [3]*PooledConnection
+	for i := range pooled {
+		pooled[i], err = pool.Acquire(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(5), pooled[i].maxRows)
+	}
+	pooled[0].Release()
+	pooled[1].Release()
+
+	assert.Nil(t, pool.UpdateConfig(func(config *PoolConfig) {
+		config.MaxIdle = 1
+		config.Settings.MaxRows = 2
+		config.Settings.QueryTimeout = time.Second
+	}))
+	stats := pool.Stats()
+	assert.Equal(t, 1, stats.Idle)
+	assert.Equal(t, uint64(1), stats.IdleClosed)
+	// The connection in use keeps its settings until it is acquired again.
+	assert.Equal(t, uint64(5), pooled[2].maxRows)
+	pooled[2].Release()
+	assert.Equal(t, uint64(2), pool.Stats().IdleClosed)
+	// The idle connection opened before the update gets the new settings.
+	reacquired, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), reacquired.maxRows)
+	reacquired.Release()
+
+	// Clearing the settings resets them on the connections opened before.
+	assert.Nil(t, pool.UpdateConfig(func(config *PoolConfig) { config.Settings = nil }))
+	assert.Equal(t, &ConnectionSettings{}, pool.currentConfig().Settings)
+	reacquired, err = pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), reacquired.maxRows)
+	reacquired.Release()
+	assert.Nil(t, pool.UpdateConfig(func(config *PoolConfig) { config.Settings = &ConnectionSettings{MaxRows: 2} }))
+
+	err = pool.UpdateConfig(func(config *PoolConfig) { config.MaxConns = 4 })
+	assert.ErrorIs(t, err, ErrImmutableOption)
+	err = pool.UpdateConfig(func(config *PoolConfig) { config.MinIdle = 1 })
+	assert.ErrorIs(t, err, ErrImmutableOption)
+	err = pool.UpdateConfig(func(config *PoolConfig) { config.MaxIdle = -1 })
+	assert.NotNil(t, err)
+	assert.Equal(t, uint64(2), pool.currentConfig().Settings.MaxRows)
+	assert.Equal(t, 1, pool.currentConfig().MaxIdle)
+
+	assert.Nil(t, pool.Close(context.Background()))
+	assert.ErrorIs(t, pool.UpdateConfig(func(config *PoolConfig) {}), ErrClosed)
+}
+
+func TestPoolUpdateConfigUnderLoad(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 4, Settings: &ConnectionSettings{}})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				pooled, err := pool.Acquire(context.Background())
+				if err != nil {
+					errs <- err
+					return
+				}
+				// The settings of a connection in use do not change.
+				maxRows := pooled.maxRows
+				res, err := pooled.Query("UNWIND range(1, 3) AS i RETURN i;")
+				if err == nil {
+					res.Close()
+				}
+				if pooled.maxRows != maxRows {
+					t.Errorf("the row limit changed from %d to %d while the connection was in use", maxRows, pooled.maxRows)
+				}
+				pooled.Release()
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	for i := range 200 {
+		err := pool.UpdateConfig(func(config *PoolConfig) {
+			config.MaxIdle = 1 + i%4
+			config.Settings.MaxRows = uint64(10 + i)
+		})
+		assert.Nil(t, err)
+	}
+	close(stop)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	pooled, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(209), pooled.maxRows)
+	pooled.Release()
+}
+
+func TestPoolStatementCache(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 2, StatementCacheSize: 2})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+	const first, second, third = "RETURN 1;", "RETURN 2;", "RETURN 3;"
+
+	// Each connection prepares the text once.
+	pooled, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	other, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	stmt, err := pooled.PrepareCached(first)
+	assert.Nil(t, err)
+	again, err := pooled.PrepareCached(first)
+	assert.Nil(t, err)
+	assert.Same(t, stmt, again)
+	otherStmt, err := other.PrepareCached(first)
+	assert.Nil(t, err)
+	assert.NotSame(t, stmt, otherStmt)
+	stats := pool.StatementCacheStats()
+	assert.Equal(t, 1, stats.Size)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+	assert.Equal(t, QueryCacheStats{Hits: 1, Misses: 2}, stats.ByQuery[queryHash(first)])
+
+	// Evicting a text closes its statements on every connection once they
+	// are released, but not before.
+	_, err = pooled.PrepareCached(second)
+	assert.Nil(t, err)
+	_, err = pooled.PrepareCached(third)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), pool.StatementCacheStats().Evictions)
+	assert.False(t, stmt.isClosed)
+	pooled.Release()
+	other.Release()
+	assert.True(t, stmt.isClosed)
+	assert.True(t, otherStmt.isClosed)
+	stats = pool.StatementCacheStats()
+	assert.Equal(t, 2, stats.Size)
+	assert.NotContains(t, stats.ByQuery, queryHash(first))
+
+	// A schema change drops the cache.
+	result, err := pool.Execute(context.Background(), third, nil)
+	assert.Nil(t, err)
+	result.Close()
+	result, err = pool.Query(context.Background(), "CREATE NODE TABLE Item(id INT64, PRIMARY KEY(id));")
+	assert.Nil(t, err)
+	result.Close()
+	result, err = pool.Execute(context.Background(), "MATCH (i:Item) WHERE i.id = $id RETURN i.id;", map[string]any{"id": int64(1)})
+	assert.Nil(t, err)
+	assert.False(t, result.HasNext())
+	result.Close()
+	stats = pool.StatementCacheStats()
+	assert.Equal(t, uint64(1), stats.Invalidations)
+	assert.Equal(t, 1, stats.Size)
+	assert.Equal(t, 0, pool.Stats().InUse)
+
+	assert.Nil(t, pool.UpdateConfig(func(config *PoolConfig) {}))
+	err = pool.UpdateConfig(func(config *PoolConfig) { config.StatementCacheSize = 3 })
+	assert.ErrorIs(t, err, ErrImmutableOption)
+	_, err = NewPool(db, PoolConfig{StatementCacheSize: -1})
+	assert.ErrorContains(t, err, "cannot be negative")
+}
+
+func TestPoolStatementCacheDisabled(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 1})
+	assert.Nil(t, err)
+	defer pool.Close(context.Background())
+	openStatements := db.Stats().OpenPreparedStatements
+
+	pooled, err := pool.Acquire(context.Background())
+	assert.Nil(t, err)
+	stmt, err := pooled.PrepareCached("RETURN 1;")
+	assert.Nil(t, err)
+	// The statement stays valid until the connection is released.
+	result, err := pooled.Execute(stmt, nil)
+	assert.Nil(t, err)
+	result.Close()
+	pooled.Release()
+	assert.True(t, stmt.isClosed)
+	assert.Equal(t, openStatements, db.Stats().OpenPreparedStatements)
+	assert.Equal(t, 0, pool.StatementCacheStats().Size)
+}
+
+func TestPoolStatementCacheConcurrent(t *testing.T) {
+	checkBackgroundTasks(t)
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	pool, err := NewPool(db, PoolConfig{MaxConns: 4, StatementCacheSize: 3})
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for worker := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 50 {
+				// Five texts for three entries keep evicting.
+				query := fmt.Sprintf("RETURN %d + $x;", (worker+i)%5)
+				result, err := pool.Execute(context.Background(), query, map[string]any{"x": int64(i)})
+				if err != nil {
+					errs <- err
+					return
+				}
+				result.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	stats := pool.StatementCacheStats()
+	assert.Equal(t, uint64(400), stats.Hits+stats.Misses)
+	assert.LessOrEqual(t, stats.Size, 3)
+	assert.Nil(t, pool.Close(context.Background()))
+	assert.Equal(t, int64(0), db.Stats().OpenPreparedStatements)
+}
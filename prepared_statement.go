@@ -4,21 +4,148 @@ package lbug
 // #include <stdlib.h>
 import "C"
 
+import "context"
+
 // PreparedStatement represents a prepared statement in Lbug, which can be
 // used to execute a query with parameters.
 // PreparedStatement is returned by the `Prepare` method of Connection.
 type PreparedStatement struct {
 	cPreparedStatement C.lbug_prepared_statement
 	connection         *Connection
-	isClosed           bool
+	query              string
+	changesSchema      bool
+	parameters         map[string]bool
+	// regexes are the regular expressions bound with BindRegex by parameter.
+	regexes       map[string]regexBinding
+	isClosed      bool
+	handleID      uint64
+	policyVersion uint64
 }
 
 // Close releases the underlying C resources for the PreparedStatement.
 // MUST be called when done to prevent resource leaks.
 func (stmt *PreparedStatement) Close() {
+	stmt.connection.closeMutex.Lock()
+	defer stmt.connection.closeMutex.Unlock()
+	stmt.close()
+}
+
+// closed returns true if the statement is closed.
+func (stmt *PreparedStatement) closed() bool {
+	stmt.connection.closeMutex.RLock()
+	defer stmt.connection.closeMutex.RUnlock()
+	return stmt.isClosed
+}
+
+// close destroys the C prepared statement. closeMutex of the connection must
+// be held for writing.
+func (stmt *PreparedStatement) close() {
 	if stmt.isClosed {
 		return
 	}
-	C.lbug_prepared_statement_destroy(&stmt.cPreparedStatement)
+	stmt.connection.countCgoCall(cgoClose)
+	if stmt.connection.checkConnection(HandlePreparedStatement) == nil {
+		C.lbug_prepared_statement_destroy(&stmt.cPreparedStatement)
+	}
+	stmt.connection.untrackChild(HandlePreparedStatement, stmt.handleID)
+	if stmt.handleID != 0 {
+		stmt.connection.stats().openPreparedStatements.Add(-1)
+	}
 	stmt.isClosed = true
 }
+
+// WriteSummary describes the execution of a statement run with Exec.
+type WriteSummary struct {
+	// CompilingTime is the time spent compiling the statement in
+	// milliseconds.
+	CompilingTime float64
+	// ExecutionTime is the time spent executing the statement in
+	// milliseconds.
+	ExecutionTime float64
+	// NumTuples is the number of tuples the statement returned.
+	NumTuples uint64
+}
+
+// Exec executes the prepared statement with the specified arguments and
+// discards its result. Unlike Connection.Execute, it does not allocate a
+// QueryResult: the C result is destroyed before Exec returns, which makes it
+// suitable for write statements executed at a high rate. The engine does not
+// report the number of nodes and relationships created or deleted, so the
+// summary only carries the timings and the number of returned tuples.
+func (stmt *PreparedStatement) Exec(args map[string]any) (WriteSummary, error) {
+	return stmt.execSensitive(args, nil)
+}
+
+// ExecWithContext is like Exec, but interrupts the execution when the
+// context is done, as described for Connection.QueryWithContext. The
+// parameters marked on the context with WithSensitive are redacted like those
+// marked with MarkSensitiveParams.
+func (stmt *PreparedStatement) ExecWithContext(ctx context.Context, args map[string]any) (WriteSummary, error) {
+	return runWithContext(ctx, stmt.connection, stmt.query, func() (WriteSummary, error) {
+		return stmt.execSensitive(args, sensitiveParamsFromContext(ctx))
+	})
+}
+
+// execSensitive executes the statement like Exec, redacting the sensitive
+// parameters in addition to those of the connection.
+func (stmt *PreparedStatement) execSensitive(args map[string]any, sensitive []string) (WriteSummary, error) {
+	out, err := stmt.connection.intercept(&call{op: traceExec, query: stmt.query, statement: stmt, args: args, sensitive: sensitive})
+	return out.summary, err
+}
+
+// exec executes the statement once the call has gone through the
+// interceptors.
+func (stmt *PreparedStatement) exec(args map[string]any) (WriteSummary, error) {
+	conn := stmt.connection
+	conn.closeMutex.RLock()
+	defer conn.closeMutex.RUnlock()
+	if conn.isClosed {
+		return WriteSummary{}, &Error{Op: OpExecute, Query: stmt.query, Err: &closedError{"failed to execute because the connection is closed"}}
+	}
+	if stmt.isClosed {
+		return WriteSummary{}, &Error{Op: OpExecute, Query: stmt.query, Err: &closedError{"failed to execute because the prepared statement is closed"}}
+	}
+	if err := conn.checkOpenResults(stmt.query); err != nil {
+		return WriteSummary{}, &Error{Op: OpExecute, Query: stmt.query, Err: err}
+	}
+	for key, value := range args {
+		if err := conn.bindParameter(stmt, key, value); err != nil {
+			return WriteSummary{}, err
+		}
+	}
+	if err := conn.bindDefaultParams(stmt, args); err != nil {
+		return WriteSummary{}, err
+	}
+	var cQueryResult C.lbug_query_result
+	conn.countCgoCall(cgoExecute)
+	if err := conn.checkDatabase(); err != nil {
+		return WriteSummary{}, &Error{Op: OpExecute, Query: stmt.query, Err: err}
+	}
+	status := C.lbug_connection_execute(&conn.cConnection, &stmt.cPreparedStatement, &cQueryResult)
+	if stmt.changesSchema {
+		conn.InvalidateSchemaCache()
+	}
+	conn.countCgoCall(cgoClose)
+	defer C.lbug_query_result_destroy(&cQueryResult)
+	if status != C.LbugSuccess || !C.lbug_query_result_is_success(&cQueryResult) {
+		cErrMsg := C.lbug_query_result_get_error_message(&cQueryResult)
+		defer C.lbug_destroy_string(cErrMsg)
+		return WriteSummary{}, conn.engineError(OpExecute, stmt.query, C.GoString(cErrMsg))
+	}
+	conn.recordWrites(stmt.query)
+	summary := writeSummary(&cQueryResult)
+	conn.summarizeInTransaction(summary)
+	return summary, nil
+}
+
+// writeSummary returns the summary of the successful C result.
+func writeSummary(cQueryResult *C.lbug_query_result) WriteSummary {
+	var cQuerySummary C.lbug_query_summary
+	C.lbug_query_result_get_query_summary(cQueryResult, &cQuerySummary)
+	defer C.lbug_query_summary_destroy(&cQuerySummary)
+	return WriteSummary{
+		CompilingTime: float64(C.lbug_query_summary_get_compiling_time(&cQuerySummary)),
+		ExecutionTime: float64(C.lbug_query_summary_get_execution_time(&cQuerySummary)),
+		NumTuples:     uint64(C.lbug_query_result_get_num_tuples(cQueryResult)),
+	}
+}
@@ -0,0 +1,109 @@
+package lbug
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreparedStatementExec(t *testing.T) {
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	result, err := conn.Query("CREATE NODE TABLE counter(id INT64, hits INT64, PRIMARY KEY(id));")
+	assert.Nil(t, err)
+	result.Close()
+
+	stmt, err := conn.Prepare("MERGE (c:counter {id: $id}) ON CREATE SET c.hits = 1 ON MATCH SET c.hits = c.hits + 1;")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	openResults := db.Stats().OpenQueryResults
+	for i := 0; i < 3; i++ {
+		summary, err := stmt.Exec(map[string]any{"id": int64(1)})
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(0), summary.NumTuples)
+		assert.GreaterOrEqual(t, summary.ExecutionTime, 0.0)
+	}
+	assert.Equal(t, openResults, db.Stats().OpenQueryResults)
+
+	result, err = conn.Query("MATCH (c:counter) RETURN c.hits;")
+	assert.Nil(t, err)
+	defer result.Close()
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	value, err := tuple.GetValue(0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), value)
+
+	_, err = stmt.Exec(map[string]any{"id": "not an integer"})
+	assert.NotNil(t, err)
+	stmt.Close()
+	_, err = stmt.Exec(nil)
+	assert.ErrorContains(t, err, "closed")
+}
+
+func BenchmarkPreparedStatementExec(b *testing.B) {
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := OpenConnection(db)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+	result, err := conn.Query("CREATE NODE TABLE counter(id INT64, hits INT64, PRIMARY KEY(id));")
+	if err != nil {
+		b.Fatal(err)
+	}
+	result.Close()
+	stmt, err := conn.Prepare("MERGE (c:counter {id: $id}) ON CREATE SET c.hits = 1 ON MATCH SET c.hits = c.hits + 1;")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer stmt.Close()
+	args := map[string]any{"id": int64(1)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := stmt.Exec(args); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPreparedStatementExecWithContext(t *testing.T) {
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	stmt, err := conn.Prepare(largeQuery)
+	assert.Nil(t, err)
+	defer stmt.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err = stmt.ExecWithContext(ctx, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), 10*time.Second)
+	// A done context is not used.
+	_, err = stmt.ExecWithContext(ctx, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	stmt, err = conn.Prepare("RETURN $x;")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	summary, err := stmt.ExecWithContext(context.Background(), map[string]any{"x": int64(1)})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), summary.NumTuples)
+}
@@ -0,0 +1,162 @@
+package lbug
+
+// #include "lbug.h"
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime/pprof"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The operations of the profiling labels of the calls into the C API.
+const (
+	profileQuery   = "query"
+	profileCopy    = "copy"
+	profileExecute = "execute"
+	profileNext    = "next"
+	profileDecode  = "decode"
+)
+
+// The keys of the profiling labels set with
+// ConnectionOptions.EnableProfilingLabels.
+const (
+	// ProfileLabelOperation is the label of the operation of a call into the
+	// C API: "query", "copy" for the queries starting with COPY, "execute",
+	// "next" for QueryResult.Next, and "decode" for the fetches of
+	// DecodePipeline and NextChunk.
+	ProfileLabelOperation = "lbug.operation"
+	// ProfileLabelQuery is the label of the hash of the query, as
+	// hexadecimal FNV-1a, so that the samples of a query can be grouped
+	// without recording its text.
+	ProfileLabelQuery = "lbug.query"
+)
+
+// cgoLatencyBounds are the upper bounds of the buckets of CgoLatency, from
+// 1µs to about 4s by factors of 4, then unbounded.
+var cgoLatencyBounds = func() []time.Duration {
+	bounds := make([]time.Duration, 0, 13)
+	for bound := time.Microsecond; bound < 5*time.Second; bound *= 4 {
+		bounds = append(bounds, bound)
+	}
+	return append(bounds, math.MaxInt64)
+}()
+
+// CgoLatencyBucket counts the sampled calls into the C API whose wall time is
+// at most UpperBound and above the bound of the previous bucket.
+type CgoLatencyBucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// CgoLatency is a histogram of the wall time of the calls into the C API
+// sampled with ConnectionOptions.SampleCgoCalls.
+type CgoLatency struct {
+	// Count and Total are the number and the total wall time of the sampled
+	// calls.
+	Count uint64
+	Total time.Duration
+	// Buckets are the counts by wall time, the last one unbounded.
+	Buckets []CgoLatencyBucket
+}
+
+// cgoProfiler labels and samples the calls into the C API of a connection. A
+// connection without profiling options has none, so that a disabled profiler
+// costs a nil check per call.
+type cgoProfiler struct {
+	labels      bool
+	sampleEvery uint64
+	calls       atomic.Uint64
+	count       atomic.Uint64
+	total       atomic.Int64
+	buckets     []atomic.Uint64
+}
+
+// newCgoProfiler returns the profiler of the options, or nil if they enable
+// no profiling.
+func newCgoProfiler(opts ConnectionOptions) *cgoProfiler {
+	if !opts.EnableProfilingLabels && opts.SampleCgoCalls == 0 {
+		return nil
+	}
+	return &cgoProfiler{
+		labels:      opts.EnableProfilingLabels,
+		sampleEvery: uint64(opts.SampleCgoCalls),
+		buckets:     make([]atomic.Uint64, len(cgoLatencyBounds)),
+	}
+}
+
+// run runs call, a call of the operation into the C API for the query with
+// the hash, with the labels of the operation and, if it is sampled, records
+// its wall time.
+func (profiler *cgoProfiler) run(operation string, hash uint64, call func()) {
+	if profiler.sampleEvery > 0 && profiler.calls.Add(1)%profiler.sampleEvery == 0 {
+		timed := call
+		call = func() {
+			start := time.Now()
+			timed()
+			profiler.record(time.Since(start))
+		}
+	}
+	if !profiler.labels {
+		call()
+		return
+	}
+	labels := pprof.Labels(ProfileLabelOperation, operation, ProfileLabelQuery, fmt.Sprintf("%016x", hash))
+	pprof.Do(context.Background(), labels, func(context.Context) { call() })
+}
+
+// record adds the wall time of a sampled call to the histogram.
+func (profiler *cgoProfiler) record(elapsed time.Duration) {
+	profiler.count.Add(1)
+	profiler.total.Add(int64(elapsed))
+	for i, bound := range cgoLatencyBounds {
+		if elapsed <= bound {
+			profiler.buckets[i].Add(1)
+			return
+		}
+	}
+}
+
+// latency returns a snapshot of the histogram.
+func (profiler *cgoProfiler) latency() CgoLatency {
+	if profiler == nil {
+		return CgoLatency{}
+	}
+	latency := CgoLatency{
+		Count:   profiler.count.Load(),
+		Total:   time.Duration(profiler.total.Load()),
+		Buckets: make([]CgoLatencyBucket, len(cgoLatencyBounds)),
+	}
+	for i, bound := range cgoLatencyBounds {
+		latency.Buckets[i] = CgoLatencyBucket{UpperBound: bound, Count: profiler.buckets[i].Load()}
+	}
+	return latency
+}
+
+// fetchNext fetches the next tuple of the result for DecodePipeline or
+// NextChunk, with the profiling of the connection.
+func (queryResult *QueryResult) fetchNext(cFlatTuple *C.lbug_flat_tuple) C.lbug_state {
+	profiler := queryResult.connection.profiler
+	if profiler == nil {
+		return C.lbug_query_result_get_next(&queryResult.cQueryResult, cFlatTuple)
+	}
+	var status C.lbug_state
+	profiler.run(profileDecode, queryResult.profileHash, func() {
+		status = C.lbug_query_result_get_next(&queryResult.cQueryResult, cFlatTuple)
+	})
+	return status
+}
+
+// queryProfileOperation returns the operation of the profiling labels of the
+// query.
+func queryProfileOperation(query string) string {
+	query = strings.TrimSpace(query)
+	if len(query) >= 4 && strings.EqualFold(query[:4], "COPY") {
+		return profileCopy
+	}
+	return profileQuery
+}
@@ -0,0 +1,111 @@
+package lbug
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCgoProfilerSampling(t *testing.T) {
+	assert.Nil(t, newCgoProfiler(ConnectionOptions{SingleThreaded: true}))
+	assert.Equal(t, CgoLatency{}, (*cgoProfiler)(nil).latency())
+
+	profiler := newCgoProfiler(ConnectionOptions{SampleCgoCalls: 2})
+	calls := 0
+	for i := 0; i < 10; i++ {
+		profiler.run(profileNext, 0, func() { calls++ })
+	}
+	assert.Equal(t, 10, calls)
+	assert.Equal(t, uint64(5), profiler.latency().Count)
+
+	profiler = newCgoProfiler(ConnectionOptions{SampleCgoCalls: 1})
+	profiler.record(500 * time.Nanosecond)
+	profiler.record(2 * time.Microsecond)
+	profiler.record(time.Minute)
+	latency := profiler.latency()
+	assert.Equal(t, uint64(3), latency.Count)
+	assert.Equal(t, time.Minute+2500*time.Nanosecond, latency.Total)
+	assert.Len(t, latency.Buckets, len(cgoLatencyBounds))
+	assert.Equal(t, CgoLatencyBucket{UpperBound: time.Microsecond, Count: 1}, latency.Buckets[0])
+	assert.Equal(t, CgoLatencyBucket{UpperBound: 4 * time.Microsecond, Count: 1}, latency.Buckets[1])
+	assert.Equal(t, uint64(1), latency.Buckets[len(latency.Buckets)-1].Count)
+}
+
+func TestQueryProfileOperation(t *testing.T) {
+	assert.Equal(t, profileCopy, queryProfileOperation("  copy person FROM 'p.csv';"))
+	assert.Equal(t, profileQuery, queryProfileOperation("RETURN 1;"))
+	assert.Equal(t, profileQuery, queryProfileOperation("COP"))
+}
+
+// profileContains captures a CPU profile while run runs and reports whether
+// its string table holds each of the strings.
+func profileContains(t *testing.T, run func(), strings ...string) {
+	t.Helper()
+	var profile bytes.Buffer
+	if err := pprof.StartCPUProfile(&profile); err != nil {
+		t.Skipf("CPU profiling is not available: %v", err)
+	}
+	run()
+	pprof.StopCPUProfile()
+	reader, err := gzip.NewReader(&profile)
+	if !assert.Nil(t, err) {
+		return
+	}
+	decoded, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	for _, s := range strings {
+		assert.True(t, bytes.Contains(decoded, []byte(s)), s)
+	}
+}
+
+func TestProfilingLabels(t *testing.T) {
+	profiler := newCgoProfiler(ConnectionOptions{EnableProfilingLabels: true})
+	hash := queryHash("RETURN 1;")
+	profileContains(t, func() {
+		profiler.run(profileQuery, hash, func() {
+			// A busy loop standing in for the time spent in the engine.
+			for deadline := time.Now().Add(500 * time.Millisecond); time.Now().Before(deadline); {
+			}
+		})
+	}, ProfileLabelOperation, profileQuery, ProfileLabelQuery, fmt.Sprintf("%016x", hash))
+}
+
+func TestConnectionProfilingLabels(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	defer db.Close()
+	conn, err := OpenConnectionWithOptions(db, ConnectionOptions{EnableProfilingLabels: true, SampleCgoCalls: 1})
+	assert.Nil(t, err)
+	defer conn.Close()
+	const query = "UNWIND range(1, 3000) AS i UNWIND range(1, 3000) AS j RETURN count(*);"
+	profileContains(t, func() {
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+			result, err := conn.Query(query)
+			if !assert.Nil(t, err) {
+				return
+			}
+			for result.HasNext() {
+				tuple, err := result.Next()
+				assert.Nil(t, err)
+				tuple.Close()
+			}
+			result.Close()
+		}
+	}, ProfileLabelOperation, profileQuery, fmt.Sprintf("%016x", queryHash(query)))
+
+	latency := conn.Stats().CgoLatency
+	assert.Greater(t, latency.Count, uint64(0))
+	var total uint64
+	for _, bucket := range latency.Buckets {
+		total += bucket.Count
+	}
+	assert.Equal(t, latency.Count, total)
+
+	_, err = OpenConnectionWithOptions(db, ConnectionOptions{SampleCgoCalls: -1})
+	assert.ErrorContains(t, err, "invalid SampleCgoCalls")
+}
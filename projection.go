@@ -0,0 +1,200 @@
+package lbug
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ProjectionSpec selects, renames and orders the columns of a query result,
+// see QueryResult.Project. Columns are named by their names in the result in
+// Include and in the keys of Rename, and by their projected names in Order.
+type ProjectionSpec struct {
+	// Include are the columns kept. If it is empty, all columns are kept.
+	Include []string
+	// Rename maps the names of columns to their projected names. Columns not
+	// renamed keep their names.
+	Rename map[string]string
+	// Order lists projected names of columns to put first, in this order.
+	// The other columns follow in their order in the result.
+	Order []string
+}
+
+// ProjectedResult is a view of a QueryResult with the columns selected,
+// renamed and ordered by a ProjectionSpec. It holds no data of its own: its
+// tuples read the values of the projected columns from the tuples of the
+// result, so only those columns are decoded. It is valid as long as the
+// result is open, and iterating it iterates the result.
+type ProjectedResult struct {
+	queryResult *QueryResult
+	// columns are the indexes of the projected columns in the result.
+	columns []uint64
+	names   []string
+	types   []DataType
+}
+
+// ProjectedTuple is a row of a ProjectedResult.
+type ProjectedTuple struct {
+	tuple      *FlatTuple
+	projection *ProjectedResult
+}
+
+// Project returns a view of the result with the columns selected, renamed and
+// ordered by the spec, e.g. to feed the rows to systems with strict naming
+// rules without changing the query. It returns an error wrapping
+// ErrNoSuchColumn, listing the columns of the result, if the spec names a
+// column the result does not have, and an error if two projected columns
+// would have the same name.
+func (queryResult *QueryResult) Project(spec ProjectionSpec) (*ProjectedResult, error) {
+	names := queryResult.GetColumnNames()
+	types := queryResult.GetColumnDataTypes()
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		index[name] = i
+	}
+	unknown := func(name string) error {
+		return fmt.Errorf("%w: the result has no column %s, its columns are %s", ErrNoSuchColumn, name, strings.Join(names, ", "))
+	}
+	included := make([]bool, len(names))
+	if len(spec.Include) == 0 {
+		for i := range included {
+			included[i] = true
+		}
+	}
+	for _, name := range spec.Include {
+		i, ok := index[name]
+		if !ok {
+			return nil, unknown(name)
+		}
+		included[i] = true
+	}
+	projectedNames := slices.Clone(names)
+	for from, to := range spec.Rename {
+		i, ok := index[from]
+		if !ok {
+			return nil, unknown(from)
+		}
+		projectedNames[i] = to
+	}
+	projection := &ProjectedResult{queryResult: queryResult}
+	projected := make(map[string]int)
+	for i, name := range projectedNames {
+		if !included[i] {
+			continue
+		}
+		if _, ok := projected[name]; ok {
+			return nil, fmt.Errorf("projection has two columns named %s", name)
+		}
+		projected[name] = i
+	}
+	add := func(i int) {
+		projection.columns = append(projection.columns, uint64(i))
+		projection.names = append(projection.names, projectedNames[i])
+		projection.types = append(projection.types, types[i])
+		included[i] = false
+	}
+	for _, name := range spec.Order {
+		i, ok := projected[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: the projection has no column %s", ErrNoSuchColumn, name)
+		}
+		if included[i] {
+			add(i)
+		}
+	}
+	for i := range names {
+		if included[i] {
+			add(i)
+		}
+	}
+	return projection, nil
+}
+
+// ColumnNames returns the projected names of the columns. The slice is owned
+// by the ProjectedResult and must not be modified.
+func (projection *ProjectedResult) ColumnNames() []string {
+	return projection.names
+}
+
+// ColumnDataTypes returns the logical types of the columns, in the order of
+// ColumnNames.
+func (projection *ProjectedResult) ColumnDataTypes() []DataType {
+	return slices.Clone(projection.types)
+}
+
+// HasNext returns true if the result has more tuples, see
+// QueryResult.HasNext.
+func (projection *ProjectedResult) HasNext() bool {
+	return projection.queryResult.HasNext()
+}
+
+// Next returns the next tuple of the result, see QueryResult.Next. The tuple
+// must be closed.
+func (projection *ProjectedResult) Next() (*ProjectedTuple, error) {
+	tuple, err := projection.queryResult.Next()
+	return &ProjectedTuple{tuple: tuple, projection: projection}, err
+}
+
+// Close closes the tuple of the result.
+func (tuple *ProjectedTuple) Close() {
+	tuple.tuple.Close()
+}
+
+// GetValue returns the value of the projected column at the index.
+func (tuple *ProjectedTuple) GetValue(index uint64) (any, error) {
+	if index >= uint64(len(tuple.projection.columns)) {
+		return nil, conversionError(index, &ColumnIndexError{Index: index, NumColumns: uint64(len(tuple.projection.columns))})
+	}
+	return tuple.tuple.GetValue(tuple.projection.columns[index])
+}
+
+// GetAsSlice returns the values of the projected columns, in the order of
+// the projection.
+func (tuple *ProjectedTuple) GetAsSlice() ([]any, error) {
+	values := make([]any, len(tuple.projection.columns))
+	for i := range values {
+		value, err := tuple.GetValue(uint64(i))
+		if err != nil {
+			return values, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// GetAsMap returns the values of the projected columns by their projected
+// names, which are used verbatim: the KeyCase of Connection.SetExportOptions
+// does not apply to them.
+func (tuple *ProjectedTuple) GetAsMap() (map[string]any, error) {
+	values, err := tuple.GetAsSlice()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]any, len(values))
+	for i, name := range tuple.projection.names {
+		m[name] = values[i]
+	}
+	return m, nil
+}
+
+// CollectProjection decodes the remaining rows of the projected result into
+// values of the struct type T as CollectWithOptions does, matching the fields
+// with the projected names of the columns.
+func CollectProjection[T any](projection *ProjectedResult, opts CollectOptions) ([]T, error) {
+	return collectRows[T](projection.names, projection.types, opts, projection.HasNext, func(columns []uint64) ([]any, error) {
+		tuple, err := projection.Next()
+		if err != nil {
+			return nil, err
+		}
+		defer tuple.Close()
+		values := make([]any, len(projection.names))
+		for _, col := range columns {
+			value, err := tuple.GetValue(col)
+			if err != nil {
+				return values, err
+			}
+			values[col] = value
+		}
+		return values, nil
+	})
+}
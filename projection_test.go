@@ -0,0 +1,65 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const projectionQuery = "MATCH (p:person) WHERE p.ID <= 2 RETURN p.ID, p.fName, p.age ORDER BY p.ID;"
+
+func TestProject(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	result, err := conn.Query(projectionQuery)
+	assert.Nil(t, err)
+	defer result.Close()
+	projection, err := result.Project(ProjectionSpec{
+		Include: []string{"p.ID", "p.fName"},
+		Rename:  map[string]string{"p.ID": "id", "p.fName": "first_name"},
+		Order:   []string{"first_name"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"first_name", "id"}, projection.ColumnNames())
+	types := projection.ColumnDataTypes()
+	assert.Equal(t, "STRING", types[0].Name)
+	assert.Equal(t, "INT64", types[1].Name)
+	assert.True(t, projection.HasNext())
+	tuple, err := projection.Next()
+	assert.Nil(t, err)
+	row, err := tuple.GetAsMap()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"first_name": "Alice", "id": int64(0)}, row)
+	_, err = tuple.GetValue(2)
+	assert.ErrorIs(t, err, ErrColumnIndexOutOfRange)
+	tuple.Close()
+
+	type person struct {
+		ID        int64  `lbug:"id"`
+		FirstName string `lbug:"first_name"`
+		Age       int64  `lbug:"p.age"`
+	}
+	people, err := CollectProjection[person](projection, CollectOptions{})
+	assert.Nil(t, err)
+	// The excluded column is not decoded into the field of its name.
+	assert.Equal(t, []person{{ID: 2, FirstName: "Bob"}}, people)
+}
+
+func TestProjectErrors(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	result, err := conn.Query(projectionQuery)
+	assert.Nil(t, err)
+	defer result.Close()
+	_, err = result.Project(ProjectionSpec{Include: []string{"p.name"}})
+	assert.ErrorIs(t, err, ErrNoSuchColumn)
+	assert.ErrorContains(t, err, "the result has no column p.name, its columns are p.ID, p.fName, p.age")
+	_, err = result.Project(ProjectionSpec{Rename: map[string]string{"age": "years"}})
+	assert.ErrorIs(t, err, ErrNoSuchColumn)
+	_, err = result.Project(ProjectionSpec{Rename: map[string]string{"p.age": "p.ID"}})
+	assert.ErrorContains(t, err, "projection has two columns named p.ID")
+	// Order names the projected columns.
+	_, err = result.Project(ProjectionSpec{Rename: map[string]string{"p.age": "age"}, Order: []string{"p.age"}})
+	assert.ErrorContains(t, err, "the projection has no column p.age")
+	projection, err := result.Project(ProjectionSpec{Rename: map[string]string{"p.age": "age"}, Order: []string{"age"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"age", "p.ID", "p.fName"}, projection.ColumnNames())
+}
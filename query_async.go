@@ -0,0 +1,140 @@
+package lbug
+
+import (
+	"context"
+	"fmt"
+)
+
+// defaultAsyncBatchSize is the number of rows of a batch of an AsyncQuery when
+// AsyncQueryOptions.BatchSize is zero.
+const defaultAsyncBatchSize = 1024
+
+// AsyncQueryOptions configures Connection.QueryAsyncWithOptions.
+type AsyncQueryOptions struct {
+	// BatchSize is the maximum number of rows of a batch. If it is zero,
+	// batches hold up to 1024 rows.
+	BatchSize int
+	// Buffer is the number of batches fetched ahead of the consumer. The
+	// rows are not fetched further while the buffer is full. If it is zero,
+	// 4 batches are buffered.
+	Buffer int
+}
+
+// RowBatch is a batch of rows of an AsyncQuery. Its values are copied into Go
+// memory, as for QueryResult.NextChunk, so a batch remains valid after the
+// query has ended.
+type RowBatch struct {
+	*DataChunk
+	// FirstRow is the index of the first row of the batch in the result.
+	FirstRow uint64
+}
+
+// AsyncQuery is a query run in the background by Connection.QueryAsync,
+// whose rows are delivered in batches on the channel returned by Batches.
+type AsyncQuery struct {
+	query   string
+	batches chan RowBatch
+	cancel  context.CancelFunc
+	done    chan struct{}
+	// err is set before done is closed.
+	err error
+}
+
+// QueryAsync runs the query in the background, see QueryAsyncWithOptions.
+func (conn *Connection) QueryAsync(query string) (*AsyncQuery, error) {
+	return conn.QueryAsyncWithOptions(context.Background(), query, AsyncQueryOptions{})
+}
+
+// QueryAsyncWithOptions runs the query on a goroutine, counted toward the limit
+// set with SetMaxBackgroundTasks, and returns without waiting for it. The rows
+// of the result are fetched in batches of opts.BatchSize rows, delivered in
+// order on the channel returned by Batches, which is closed once the query
+// has ended. The engine returns the result once the query has completed, so
+// the batches arrive while the rows are fetched, which for large results is
+// most of the time spent in the binding.
+//
+// The query is interrupted, or the fetching stops, when the context is done
+// or Cancel is called. The connection must not be used until the channel is
+// closed, and a single-threaded connection is taken over by the goroutine
+// until then.
+func (conn *Connection) QueryAsyncWithOptions(ctx context.Context, query string, opts AsyncQueryOptions) (*AsyncQuery, error) {
+	if opts.BatchSize < 0 || opts.Buffer < 0 {
+		return nil, fmt.Errorf("invalid async query options: the batch size %d and the buffer size %d must not be negative", opts.BatchSize, opts.Buffer)
+	}
+	if opts.BatchSize == 0 {
+		opts.BatchSize = defaultAsyncBatchSize
+	}
+	if opts.Buffer == 0 {
+		opts.Buffer = 4
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	async := &AsyncQuery{
+		query:   query,
+		batches: make(chan RowBatch, opts.Buffer),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	owner := &conn.closeMutex.owner
+	owner.release()
+	err := background.spawn("QueryAsync", conn, func() {
+		defer close(async.done)
+		defer close(async.batches)
+		defer owner.release()
+		defer cancel()
+		async.err = async.run(ctx, conn, opts.BatchSize)
+	})
+	if err != nil {
+		cancel()
+		return nil, &Error{Op: OpExecute, Query: query, Err: err}
+	}
+	return async, nil
+}
+
+// run runs the query and sends the batches of its result.
+func (async *AsyncQuery) run(ctx context.Context, conn *Connection, batchSize int) error {
+	result, err := conn.QueryWithContext(ctx, async.query)
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+	for first := uint64(0); ; {
+		if err := ctx.Err(); err != nil {
+			return &Error{Op: OpIterate, Query: async.query, Err: err}
+		}
+		chunk, err := result.NextChunk(batchSize)
+		if err != nil {
+			return err
+		}
+		if chunk.NumRows() == 0 {
+			return nil
+		}
+		select {
+		case async.batches <- RowBatch{DataChunk: chunk, FirstRow: first}:
+		case <-ctx.Done():
+			return &Error{Op: OpIterate, Query: async.query, Err: ctx.Err()}
+		}
+		first += uint64(chunk.NumRows())
+	}
+}
+
+// Batches returns the channel on which the batches of rows of the result are
+// delivered, which is closed once the query has ended. Err then returns its
+// error.
+func (async *AsyncQuery) Batches() <-chan RowBatch {
+	return async.batches
+}
+
+// Cancel interrupts the query, or stops fetching its rows if it has
+// completed. The batches already buffered are still delivered. It does
+// nothing if the query has ended.
+func (async *AsyncQuery) Cancel() {
+	async.cancel()
+}
+
+// Err waits for the query to end and returns its error, which matches
+// context.Canceled if it has been canceled. Unless the query is canceled, the
+// batches must be received for it to end.
+func (async *AsyncQuery) Err() error {
+	<-async.done
+	return async.err
+}
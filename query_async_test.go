@@ -0,0 +1,81 @@
+package lbug
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryAsync(t *testing.T) {
+	checkBackgroundTasks(t)
+	conn := openCopyTestConnection(t)
+
+	async, err := conn.QueryAsyncWithOptions(context.Background(), "UNWIND range(1, 10) AS i RETURN i;", AsyncQueryOptions{BatchSize: 4, Buffer: 1})
+	assert.Nil(t, err)
+	var values []int64
+	var firstRows []uint64
+	for batch := range async.Batches() {
+		firstRows = append(firstRows, batch.FirstRow)
+		column, err := batch.Int64Column(0)
+		assert.Nil(t, err)
+		values = append(values, column...)
+	}
+	assert.Nil(t, async.Err())
+	assert.Equal(t, []uint64{0, 4, 8}, firstRows)
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, values)
+
+	// The connection can be used again once the query has ended.
+	count, err := queryCount(conn, "RETURN 1;")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), count)
+
+	async, err = conn.QueryAsync("RETURN x;")
+	assert.Nil(t, err)
+	for range async.Batches() {
+		t.Fatal("a failed query delivered a batch")
+	}
+	assert.NotNil(t, async.Err())
+
+	_, err = conn.QueryAsyncWithOptions(context.Background(), "RETURN 1;", AsyncQueryOptions{BatchSize: -1})
+	assert.ErrorContains(t, err, "must not be negative")
+}
+
+func TestQueryAsyncCancel(t *testing.T) {
+	checkBackgroundTasks(t)
+	conn := openCopyTestConnection(t)
+
+	async, err := conn.QueryAsyncWithOptions(context.Background(), "UNWIND range(1, 100000) AS i RETURN i;", AsyncQueryOptions{BatchSize: 10, Buffer: 1})
+	assert.Nil(t, err)
+	batch := <-async.Batches()
+	assert.Equal(t, 10, batch.NumRows())
+	async.Cancel()
+	// The batch buffered when the query was canceled may still be delivered.
+	received := 0
+	for range async.Batches() {
+		received++
+	}
+	assert.LessOrEqual(t, received, 2)
+	assert.ErrorIs(t, async.Err(), context.Canceled)
+	async.Cancel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	async, err = conn.QueryAsyncWithOptions(ctx, "RETURN 1;", AsyncQueryOptions{})
+	assert.Nil(t, err)
+	for range async.Batches() {
+	}
+	assert.ErrorIs(t, async.Err(), context.Canceled)
+}
+
+func TestQueryAsyncMaxBackgroundTasks(t *testing.T) {
+	checkBackgroundTasks(t)
+	conn := openCopyTestConnection(t)
+	release := make(chan struct{})
+	SetMaxBackgroundTasks(1)
+	defer SetMaxBackgroundTasks(0)
+	assert.Nil(t, background.spawn("busy", nil, func() { <-release }))
+	_, err := conn.QueryAsync("RETURN 1;")
+	assert.ErrorIs(t, err, ErrTooManyBackgroundTasks)
+	close(release)
+}
@@ -0,0 +1,96 @@
+package lbug
+
+import (
+	"context"
+	"errors"
+)
+
+// GroupQuery is a query run by Connection.QueryGroup.
+type GroupQuery struct {
+	Query string
+	// Args are the parameters of the query. If it is not nil, the query is
+	// prepared and executed with them, otherwise it is run with Query.
+	Args map[string]any
+}
+
+// beforeGroupQueryForTesting, if set, is called by QueryGroup before it runs
+// the query at the index, so that tests can write to the database between the
+// queries of the group.
+var beforeGroupQueryForTesting func(index int)
+
+// QueryGroup runs the read queries in a single read-only transaction, so that
+// they all observe the same state of the database even if other connections
+// write to it in the meantime, e.g. for the panels of a dashboard. The engine
+// runs one query at a time per connection, so the queries run sequentially,
+// in the order of the slice, and the results are returned in the same order.
+// A result holds all its rows once its query has run, so the results can be
+// iterated after QueryGroup returns, and each must be closed by the caller.
+//
+// If a query fails, the results of the previous ones are closed, the
+// transaction is rolled back and QueryGroup returns a *GroupQueryError with
+// the index of the query. A query that writes fails in the read-only
+// transaction. QueryGroup must not be called in an explicit transaction; to
+// run several groups against the same view, use SnapshotConn.QueryGroup.
+func (conn *Connection) QueryGroup(ctx context.Context, queries []GroupQuery) ([]*QueryResult, error) {
+	conn.snapshotMutex.Lock()
+	inTransaction := conn.inTransaction
+	conn.snapshotMutex.Unlock()
+	if inTransaction {
+		return nil, errors.New("QueryGroup cannot run in an explicit transaction")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := runStatement(conn, "BEGIN TRANSACTION READ ONLY;"); err != nil {
+		return nil, err
+	}
+	results, err := conn.runGroupQueries(ctx, queries)
+	if err != nil {
+		runStatement(conn, "ROLLBACK;")
+		return nil, err
+	}
+	if err := runStatement(conn, "COMMIT;"); err != nil {
+		for _, result := range results {
+			result.Close()
+		}
+		return nil, err
+	}
+	return results, nil
+}
+
+// runGroupQueries runs the queries of a group in the current transaction. If
+// a query fails, the results of the previous ones are closed and a
+// *GroupQueryError is returned.
+func (conn *Connection) runGroupQueries(ctx context.Context, queries []GroupQuery) ([]*QueryResult, error) {
+	results := make([]*QueryResult, 0, len(queries))
+	for i, query := range queries {
+		if beforeGroupQueryForTesting != nil {
+			beforeGroupQueryForTesting(i)
+		}
+		result, err := conn.runGroupQuery(ctx, query)
+		if err != nil {
+			for _, result := range results {
+				result.Close()
+			}
+			return nil, &GroupQueryError{Index: i, Query: query.Query, Err: err}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// runGroupQuery runs a query of a group.
+func (conn *Connection) runGroupQuery(ctx context.Context, query GroupQuery) (*QueryResult, error) {
+	if query.Args == nil {
+		return conn.QueryWithContext(ctx, query.Query)
+	}
+	statement, err := conn.PrepareWithContext(ctx, query.Query)
+	if err != nil {
+		if statement != nil {
+			statement.Close()
+		}
+		return nil, err
+	}
+	defer statement.Close()
+	return conn.ExecuteWithContext(ctx, statement, query.Args)
+}
@@ -0,0 +1,96 @@
+package lbug
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countPersons returns the count returned by the result, which it closes, or
+// runs the count query on the connection if the result is nil.
+func countPersons(t *testing.T, conn *Connection, result *QueryResult) int64 {
+	t.Helper()
+	if result == nil {
+		var err error
+		result, err = conn.Query("MATCH (p:person) RETURN count(*);")
+		if !assert.Nil(t, err) {
+			return -1
+		}
+	}
+	defer result.Close()
+	tuple, err := result.Next()
+	if !assert.Nil(t, err) {
+		return -1
+	}
+	defer tuple.Close()
+	count, err := tuple.GetValue(0)
+	assert.Nil(t, err)
+	return count.(int64)
+}
+
+func TestQueryGroupSnapshot(t *testing.T) {
+	db, conn := SetupTestDatabase(t)
+	writer, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer writer.Close()
+	before := countPersons(t, conn, nil)
+
+	beforeGroupQueryForTesting = func(index int) {
+		if index > 0 {
+			mustRun(t, writer, fmt.Sprintf("CREATE (:person {ID: %d, fName: 'Writer'});", 1000+index))
+		}
+	}
+	defer func() { beforeGroupQueryForTesting = nil }()
+	results, err := conn.QueryGroup(context.Background(), []GroupQuery{
+		{Query: "MATCH (p:person) RETURN count(*);"},
+		{Query: "MATCH (p:person) WHERE p.ID >= $min RETURN count(*);", Args: map[string]any{"min": int64(0)}},
+		{Query: "MATCH (p:person) RETURN count(*);"},
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, results, 3) {
+		for _, result := range results {
+			assert.Equal(t, before, countPersons(t, conn, result))
+		}
+	}
+	// The writes between the queries are visible once the group is done.
+	assert.Equal(t, before+2, countPersons(t, conn, nil))
+}
+
+func TestQueryGroupFailure(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	results, err := conn.QueryGroup(context.Background(), []GroupQuery{
+		{Query: "MATCH (p:person) RETURN count(*);"},
+		{Query: "MATCH (p:nonexistent) RETURN p;"},
+		{Query: "MATCH (p:person) RETURN count(*);"},
+	})
+	assert.Nil(t, results)
+	var groupErr *GroupQueryError
+	if assert.ErrorAs(t, err, &groupErr) {
+		assert.Equal(t, 1, groupErr.Index)
+		assert.Equal(t, "MATCH (p:nonexistent) RETURN p;", groupErr.Query)
+	}
+	var lbugErr *Error
+	assert.ErrorAs(t, err, &lbugErr)
+
+	// Writes fail in the read-only transaction, and the connection is usable
+	// after the rollback.
+	_, err = conn.QueryGroup(context.Background(), []GroupQuery{{Query: "CREATE (:person {ID: 2000});"}})
+	assert.ErrorAs(t, err, &groupErr)
+	assert.Equal(t, 0, groupErr.Index)
+	mustRun(t, conn, "BEGIN TRANSACTION;")
+	_, err = conn.QueryGroup(context.Background(), []GroupQuery{{Query: "RETURN 1;"}})
+	assert.ErrorContains(t, err, "QueryGroup cannot run in an explicit transaction")
+	mustRun(t, conn, "ROLLBACK;")
+	results, err = conn.QueryGroup(context.Background(), []GroupQuery{{Query: "RETURN 1;"}})
+	assert.Nil(t, err)
+	for _, result := range results {
+		result.Close()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = conn.QueryGroup(ctx, []GroupQuery{{Query: "RETURN 1;"}})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,105 @@
+package lbug
+
+import (
+	"strings"
+	"unicode"
+)
+
+// resultOrdering tells whether the rows of a query result are returned in a
+// deterministic order.
+type resultOrdering int
+
+const (
+	// orderingUnknown means that the ordering could not be determined from
+	// the query, e.g. because it is a multi-statement query or uses UNION or
+	// CALL.
+	orderingUnknown resultOrdering = iota
+	// orderingOrdered means that the final RETURN clause has an ORDER BY.
+	orderingOrdered
+	// orderingUnordered means that the query has no ORDER BY at all, so the
+	// order of the rows may change between identical runs.
+	orderingUnordered
+)
+
+// detectOrdering determines from the text of a query whether its result is
+// ordered. The detection is deliberately conservative and reports
+// orderingUnknown for anything it does not understand rather than risk
+// reporting a wrong ordering.
+func detectOrdering(query string) resultOrdering {
+	tokens := queryKeywords(query)
+	for len(tokens) > 0 && tokens[len(tokens)-1] == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+	lastReturn := -1
+	hasOrderBy := false
+	for i, token := range tokens {
+		switch token {
+		case ";", "UNION", "CALL":
+			return orderingUnknown
+		case "RETURN":
+			lastReturn = i
+		case "ORDER":
+			if i+1 < len(tokens) && tokens[i+1] == "BY" {
+				hasOrderBy = true
+			}
+		}
+	}
+	if lastReturn < 0 {
+		return orderingUnknown
+	}
+	for i := lastReturn; i+1 < len(tokens); i++ {
+		if tokens[i] == "ORDER" && tokens[i+1] == "BY" {
+			return orderingOrdered
+		}
+	}
+	if hasOrderBy {
+		// An ORDER BY in a WITH clause may or may not carry over to the
+		// final result.
+		return orderingUnknown
+	}
+	return orderingUnordered
+}
+
+// queryKeywords splits a query into upper-cased words, semicolons and
+// parameters, which keep their case and their "$" prefix, skipping string
+// literals, escaped identifiers and comments.
+func queryKeywords(query string) []string {
+	var tokens []string
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			for i++; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && r != '`' {
+					i++
+				}
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/'); i++ {
+			}
+			i++
+		case r == ';':
+			tokens = append(tokens, ";")
+		case r == '$':
+			start := i + 1
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '_') {
+				i++
+			}
+			if i >= start {
+				tokens = append(tokens, "$"+string(runes[start:i+1]))
+			}
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '_') {
+				i++
+			}
+			tokens = append(tokens, strings.ToUpper(string(runes[start:i+1])))
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,70 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectOrdering(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected resultOrdering
+	}{
+		{"MATCH (a:person) RETURN a.name ORDER BY a.name;", orderingOrdered},
+		{"match (a:person) return a.name order by a.name desc limit 3", orderingOrdered},
+		{"MATCH (a:person) RETURN a.name;", orderingUnordered},
+		{"MATCH (a:person) RETURN a.name LIMIT 10;", orderingUnordered},
+		{"MATCH (a:person) WHERE a.name = 'ORDER BY' RETURN a.name;", orderingUnordered},
+		{"MATCH (a:person) RETURN a.name // ORDER BY a.name\n;", orderingUnordered},
+		{"MATCH (a:person) RETURN a.`order by`;", orderingUnordered},
+		{"MATCH (a:person) WITH a ORDER BY a.age LIMIT 5 RETURN a.name;", orderingUnknown},
+		{"MATCH (a:person) RETURN a.name UNION MATCH (b:organisation) RETURN b.name;", orderingUnknown},
+		{"CALL show_tables() RETURN *;", orderingUnknown},
+		{"RETURN 1; RETURN 2;", orderingUnknown},
+		{"CREATE NODE TABLE t(id INT64, PRIMARY KEY(id));", orderingUnknown},
+		{"", orderingUnknown},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, detectOrdering(test.query), test.query)
+	}
+}
+
+func TestQueryResultIsOrdered(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	result, err := conn.Query("MATCH (a:person) RETURN a.fName ORDER BY a.fName;")
+	assert.Nil(t, err)
+	defer result.Close()
+	ordered, known := result.IsOrdered()
+	assert.True(t, ordered)
+	assert.True(t, known)
+	assert.Nil(t, result.checkOrderedIteration())
+
+	stmt, err := conn.Prepare("MATCH (a:person) WHERE a.age > $age RETURN a.fName;")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	result, err = conn.Execute(stmt, map[string]any{"age": int64(20)})
+	assert.Nil(t, err)
+	defer result.Close()
+	ordered, known = result.IsOrdered()
+	assert.False(t, ordered)
+	assert.True(t, known)
+	assert.Nil(t, result.checkOrderedIteration())
+}
+
+func TestRequireOrderedIteration(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	conn.SetRequireOrderedIteration(true)
+	defer conn.SetRequireOrderedIteration(false)
+	result, err := conn.Query("MATCH (a:person) RETURN a.fName;")
+	assert.Nil(t, err)
+	defer result.Close()
+	assert.ErrorIs(t, result.checkOrderedIteration(), ErrUnorderedResult)
+
+	result, err = conn.Query("RETURN 1; RETURN 2;")
+	assert.Nil(t, err)
+	defer result.Close()
+	_, known := result.IsOrdered()
+	assert.False(t, known)
+	assert.Nil(t, result.checkOrderedIteration())
+}
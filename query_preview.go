@@ -0,0 +1,103 @@
+package lbug
+
+// #include "lbug.h"
+import "C"
+
+import "fmt"
+
+// Preview is the beginning of the result of a query, as returned by
+// Connection.QueryPreview.
+type Preview struct {
+	// Columns describes the columns of the result.
+	Columns []PreviewColumn
+	// Rows holds up to n rows of the result, decoded as by
+	// FlatTuple.GetAsSlice.
+	Rows [][]any
+	// Truncated reports whether the result has more rows than Rows. It is
+	// false if the result has exactly n rows.
+	Truncated bool
+	// TotalRows is the number of rows of the result.
+	TotalRows uint64
+	// TotalExact reports whether TotalRows is the exact number of rows of
+	// the result rather than an estimate from the cardinality of the plan.
+	// The engine materializes the results of queries, so the total is
+	// currently always exact.
+	TotalExact bool
+}
+
+// PreviewColumn describes a column of a Preview.
+type PreviewColumn struct {
+	// Name is the name of the column.
+	Name string
+	// TypeID is the logical type id of the column in the C API.
+	TypeID int
+}
+
+// QueryPreview executes the query with the parameters and returns up to n
+// rows of its result together with the columns and the total number of rows,
+// e.g. to show the first rows of a result in a user interface. If params is
+// empty, the query is executed directly, otherwise it is prepared and
+// executed with params. The result is closed before QueryPreview returns. The
+// row limit of the connection does not apply, since at most n rows are
+// decoded.
+func (conn *Connection) QueryPreview(query string, params map[string]any, n int) (*Preview, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid number of preview rows %d", n)
+	}
+	var result *QueryResult
+	var err error
+	if len(params) == 0 {
+		result, err = conn.Query(query)
+	} else {
+		var statement *PreparedStatement
+		statement, err = conn.Prepare(query)
+		defer statement.Close()
+		if err == nil {
+			result, err = conn.Execute(statement, params)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	// The total is read first, since the result may be closed automatically
+	// once the last row has been fetched.
+	preview := &Preview{TotalExact: true}
+	result.maxRows = 0
+	if err := result.previewMetadata(preview); err != nil {
+		return nil, err
+	}
+	for len(preview.Rows) < n && result.HasNext() {
+		tuple, err := result.Next()
+		if err != nil {
+			tuple.Close()
+			return nil, err
+		}
+		row, err := tuple.GetAsSlice()
+		tuple.Close()
+		if err != nil {
+			return nil, err
+		}
+		preview.Rows = append(preview.Rows, row)
+	}
+	preview.Truncated = preview.TotalRows > uint64(len(preview.Rows))
+	return preview, nil
+}
+
+// previewMetadata sets the columns and the total number of rows of the
+// preview.
+func (queryResult *QueryResult) previewMetadata(preview *Preview) error {
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	if queryResult.isClosed {
+		return &Error{Op: OpIterate, Err: &closedError{"failed to preview the result because it is closed"}}
+	}
+	names := queryResult.getColumnNames()
+	types := queryResult.getColumnDataTypes()
+	preview.Columns = make([]PreviewColumn, len(names))
+	for i, name := range names {
+		preview.Columns[i] = PreviewColumn{Name: name, TypeID: types[i].TypeID}
+	}
+	preview.TotalRows = uint64(C.lbug_query_result_get_num_tuples(&queryResult.cQueryResult))
+	return nil
+}
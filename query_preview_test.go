@@ -0,0 +1,51 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryPreview(t *testing.T) {
+	db, conn := SetupTestDatabase(t)
+	query := "MATCH (a:person) RETURN a.ID AS id, a.fName AS name ORDER BY a.ID;"
+	preview, err := conn.QueryPreview(query, nil, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []PreviewColumn{{Name: "id", TypeID: int64TypeID}, {Name: "name", TypeID: preview.Columns[1].TypeID}}, preview.Columns)
+	assert.NotEqual(t, int64TypeID, preview.Columns[1].TypeID)
+	assert.Equal(t, [][]any{{int64(0), "Alice"}, {int64(2), "Bob"}, {int64(3), "Carol"}}, preview.Rows)
+	assert.True(t, preview.Truncated)
+	assert.Equal(t, uint64(8), preview.TotalRows)
+	assert.True(t, preview.TotalExact)
+	assert.Equal(t, int64(0), db.Stats().OpenQueryResults)
+	assert.Equal(t, int64(0), db.Stats().OpenFlatTuples)
+
+	// A result with exactly n rows is not truncated.
+	preview, err = conn.QueryPreview(query, nil, 8)
+	assert.Nil(t, err)
+	assert.Len(t, preview.Rows, 8)
+	assert.False(t, preview.Truncated)
+
+	preview, err = conn.QueryPreview(query, nil, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, preview.Rows)
+	assert.True(t, preview.Truncated)
+	assert.Equal(t, uint64(8), preview.TotalRows)
+
+	_, err = conn.QueryPreview(query, nil, -1)
+	assert.ErrorContains(t, err, "invalid number of preview rows -1")
+}
+
+func TestQueryPreviewParameters(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	conn.SetMaxRows(1)
+	defer conn.SetMaxRows(0)
+	preview, err := conn.QueryPreview("MATCH (a:person) WHERE a.age > $age RETURN a.fName ORDER BY a.fName;", map[string]any{"age": int64(30)}, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]any{{"Alice"}, {"Carol"}, {"Hubert Blaine Wolfeschlegelsteinhausenbergerdorff"}}, preview.Rows)
+	assert.False(t, preview.Truncated)
+	assert.Equal(t, uint64(3), preview.TotalRows)
+
+	_, err = conn.QueryPreview("MATCH (a:person) RETURN a.unknown;", nil, 1)
+	assert.NotNil(t, err)
+}
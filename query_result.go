@@ -6,6 +6,8 @@ import "C"
 
 import (
 	"fmt"
+	"sync"
+	"time"
 	"unsafe"
 )
 
@@ -16,38 +18,142 @@ type QueryResult struct {
 	cQueryResult C.lbug_query_result
 	connection   *Connection
 	isClosed     bool
-	columnNames  []string
+	query        string
+	// profileHash is the hash of the query labelling its calls into the C
+	// API, set if profiling is enabled on the connection.
+	profileHash uint64
+	// injectedLimit is the LIMIT appended to the query by ExploratoryMode.
+	injectedLimit int
+	// regexFilters are the regular expressions of BindRegex matched on the
+	// client, and pending is the tuple fetched ahead by HasNext to match
+	// them, with the error fetching or matching it.
+	regexFilters   []regexBinding
+	pending        *FlatTuple
+	pendingErr     error
+	columnNames    []string
+	autoClose      bool
+	requireOrdered bool
+	ordering       resultOrdering
+	converter      valueConverter
+	exportOptions  ExportOptions
+	hasFetched     bool
+	// scanPlan is the last matching of the fields of a struct with the
+	// columns made by ScanStructWithOptions.
+	scanPlan     *scanPlan
+	handleID     uint64
+	maxRows      uint64
+	numFetched   uint64
+	snapshotID   uint64
+	invalidation invalidationMark
+	// succeeded is set once the query of the result has succeeded, so that
+	// its summary can be read. The engine times of the summary are kept in
+	// engineTimes when the result is closed.
+	succeeded   bool
+	engineTimes QueryTimings
+	// numTuples is the number of tuples of the result, kept when it is closed.
+	numTuples   uint64
+	fetchTimer  bindingTimer
+	decodeTimer bindingTimer
+	// release is called once the result is closed, by Close or auto-close,
+	// to give the connection of a result returned by Pool.Query back to the pool.
+	release     func()
+	releaseOnce sync.Once
 }
 
 // ToString returns the string representation of the QueryResult.
 // The string representation contains the column names and the tuples in the
 // result set.
 func (queryResult *QueryResult) ToString() string {
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	if queryResult.isClosed {
+		return ""
+	}
 	cString := C.lbug_query_result_to_string(&queryResult.cQueryResult)
 	str := C.GoString(cString)
 	C.free(unsafe.Pointer(cString))
 	return str
 }
 
-// Close releases the underlying C resources for the QueryResult.
+// Close releases the underlying C resources for the QueryResult, and closes
+// the tuples fetched from it that are still open, since their C resources
+// belong to the result.
 // MUST be called when done to prevent resource leaks.
 func (queryResult *QueryResult) Close() {
+	queryResult.discardPending()
+	queryResult.connection.closeMutex.Lock()
+	queryResult.close()
+	queryResult.connection.closeMutex.Unlock()
+	queryResult.releaseConnection()
+}
+
+// releaseConnection gives the connection of a result returned by Pool.Query
+// back to the pool, once. closeMutex of the connection must not be held, since
+// the pool may close the connection.
+func (queryResult *QueryResult) releaseConnection() {
+	if queryResult.release != nil {
+		queryResult.releaseOnce.Do(queryResult.release)
+	}
+}
+
+// close destroys the C query result, closing the tuples fetched from it
+// first. closeMutex of the connection must be held for writing, unless the
+// result has not been returned yet.
+func (queryResult *QueryResult) close() {
 	if queryResult.isClosed {
 		return
 	}
-	C.lbug_query_result_destroy(&queryResult.cQueryResult)
+	queryResult.closeTuples()
+	queryResult.connection.countCgoCall(cgoClose)
+	if queryResult.connection.checkConnection(HandleQueryResult) == nil {
+		queryResult.engineTimes = queryResult.engineTimings()
+		if queryResult.succeeded {
+			queryResult.numTuples = uint64(C.lbug_query_result_get_num_tuples(&queryResult.cQueryResult))
+		}
+		C.lbug_query_result_destroy(&queryResult.cQueryResult)
+	}
+	queryResult.connection.untrackChild(HandleQueryResult, queryResult.handleID)
+	if queryResult.handleID != 0 {
+		queryResult.connection.stats().openQueryResults.Add(-1)
+	}
 	queryResult.isClosed = true
 }
 
+// closeTuples closes the open tuples fetched from the result: their C tuples
+// are owned by the C result, and must not be read once it is destroyed.
+func (queryResult *QueryResult) closeTuples() {
+	for _, child := range queryResult.connection.openChildren() {
+		if tuple, ok := child.(*FlatTuple); ok && tuple.queryResult == queryResult {
+			tuple.close()
+		}
+	}
+}
+
 // ResetIterator resets the iterator of the QueryResult. After calling this method, the `Next`
 // method can be called to iterate over the result set from the beginning.
+// Calling ResetIterator on a closed QueryResult has no effect.
 func (queryResult *QueryResult) ResetIterator() {
+	queryResult.discardPending()
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	if queryResult.isClosed {
+		return
+	}
 	C.lbug_query_result_reset_iterator(&queryResult.cQueryResult)
+	queryResult.numFetched = 0
 }
 
 // GetColumnNames returns the column names of the QueryResult as a slice of strings.
 func (queryResult *QueryResult) GetColumnNames() []string {
-	if queryResult.columnNames != nil {
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	return queryResult.getColumnNames()
+}
+
+// getColumnNames returns the column names, caching them. closeMutex of the
+// connection must be held.
+func (queryResult *QueryResult) getColumnNames() []string {
+	if queryResult.columnNames != nil || queryResult.isClosed {
 		return queryResult.columnNames
 	}
 	numColumns := int64(C.lbug_query_result_get_num_columns(&queryResult.cQueryResult))
@@ -64,51 +170,212 @@ func (queryResult *QueryResult) GetColumnNames() []string {
 
 // GetNumberOfColumns returns the number of columns in the QueryResult.
 func (queryResult *QueryResult) GetNumberOfColumns() uint64 {
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	return queryResult.getNumberOfColumns()
+}
+
+// getNumberOfColumns returns the number of columns. closeMutex of the
+// connection must be held.
+func (queryResult *QueryResult) getNumberOfColumns() uint64 {
+	if queryResult.columnNames != nil || queryResult.isClosed {
+		return uint64(len(queryResult.columnNames))
+	}
 	return uint64(C.lbug_query_result_get_num_columns(&queryResult.cQueryResult))
 }
 
-// GetNumberOfRows returns the number of rows in the QueryResult.
+// GetNumberOfRows returns the number of rows in the QueryResult. Once the
+// result is closed, it returns the number of rows it had when it was closed.
 func (queryResult *QueryResult) GetNumberOfRows() uint64 {
-	if queryResult.columnNames != nil {
-		return uint64(len(queryResult.columnNames))
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	if queryResult.isClosed {
+		return queryResult.numTuples
 	}
 	return uint64(C.lbug_query_result_get_num_tuples(&queryResult.cQueryResult))
 }
 
 // HasNext returns true if there is at least one more tuple in the result set.
+// If auto-close is enabled on the connection, the QueryResult is closed when
+// HasNext returns false after at least one tuple has been fetched, unless the
+// results of further statements of the query are still to be read.
 func (queryResult *QueryResult) HasNext() bool {
-	return bool(C.lbug_query_result_has_next(&queryResult.cQueryResult))
+	if len(queryResult.regexFilters) > 0 {
+		return queryResult.fetchFiltered()
+	}
+	return queryResult.hasNextTuple()
+}
+
+func (queryResult *QueryResult) hasNextTuple() bool {
+	hasNext, autoClosed := queryResult.hasNextTupleOrClose()
+	if autoClosed {
+		queryResult.releaseConnection()
+	}
+	return hasNext
+}
+
+// hasNextTupleOrClose returns whether there is another tuple, and closes the
+// result if it is auto-closed, returning true as well in that case.
+func (queryResult *QueryResult) hasNextTupleOrClose() (bool, bool) {
+	queryResult.connection.closeMutex.RLock()
+	if queryResult.isClosed {
+		queryResult.connection.closeMutex.RUnlock()
+		return false, false
+	}
+	queryResult.connection.countCgoCall(cgoNext)
+	if queryResult.connection.checkConnection(HandleQueryResult) != nil {
+		queryResult.connection.closeMutex.RUnlock()
+		return false, false
+	}
+	hasNext := bool(C.lbug_query_result_has_next(&queryResult.cQueryResult))
+	// The result of a statement is only closed once it is the last one of the
+	// query, since closing it destroys the results of the statements after it.
+	autoClose := !hasNext && queryResult.autoClose && queryResult.hasFetched &&
+		!bool(C.lbug_query_result_has_next_query_result(&queryResult.cQueryResult))
+	queryResult.connection.closeMutex.RUnlock()
+	if !autoClose {
+		return hasNext, false
+	}
+	queryResult.connection.closeMutex.Lock()
+	defer queryResult.connection.closeMutex.Unlock()
+	if queryResult.isClosed {
+		// Closed by another goroutine in between.
+		return false, false
+	}
+	// Cache the column names so that GetColumnNames still returns them after
+	// the underlying C result is destroyed.
+	queryResult.getColumnNames()
+	queryResult.close()
+	return false, true
 }
 
 // Next returns the next tuple in the result set.
 func (queryResult *QueryResult) Next() (*FlatTuple, error) {
+	if len(queryResult.regexFilters) == 0 || !queryResult.fetchFiltered() {
+		return queryResult.nextTuple()
+	}
+	tuple, err := queryResult.pending, queryResult.pendingErr
+	queryResult.pending, queryResult.pendingErr = nil, nil
+	return tuple, err
+}
+
+func (queryResult *QueryResult) nextTuple() (*FlatTuple, error) {
 	tuple := &FlatTuple{}
 	tuple.queryResult = queryResult
-	status := C.lbug_query_result_get_next(&queryResult.cQueryResult, &tuple.cFlatTuple)
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	if queryResult.isClosed {
+		tuple.isClosed = true
+		return tuple, &Error{Op: OpIterate, Err: &closedError{"failed to get next tuple because the query result is closed"}}
+	}
+	if err := queryResult.connection.checkConnection(HandleQueryResult); err != nil {
+		tuple.isClosed = true
+		return tuple, &Error{Op: OpIterate, Err: err}
+	}
+	if err := queryResult.checkRowLimit(); err != nil {
+		tuple.isClosed = true
+		return tuple, err
+	}
+	if err := queryResult.connection.reserveHandle(HandleFlatTuple); err != nil {
+		tuple.isClosed = true
+		return tuple, &Error{Op: OpIterate, Err: err}
+	}
+	queryResult.connection.countCgoCall(cgoNext)
+	if err := queryResult.checkInvalidated(); err != nil {
+		handles.cancel(HandleFlatTuple)
+		tuple.isClosed = true
+		return tuple, &Error{Op: OpIterate, Err: err}
+	}
+	start := time.Now()
+	var status C.lbug_state
+	if profiler := queryResult.connection.profiler; profiler != nil {
+		profiler.run(profileNext, queryResult.profileHash, func() {
+			status = C.lbug_query_result_get_next(&queryResult.cQueryResult, &tuple.cFlatTuple)
+		})
+	} else {
+		status = C.lbug_query_result_get_next(&queryResult.cQueryResult, &tuple.cFlatTuple)
+	}
+	queryResult.fetchTimer.since(start)
 	if status != C.LbugSuccess {
-		return tuple, fmt.Errorf("failed to get next tuple with status %d", status)
+		handles.cancel(HandleFlatTuple)
+		queryResult.connection.lastCallFailed.Store(true)
+		return tuple, &Error{Op: OpIterate, Message: fmt.Sprintf("failed to get next tuple with status %d", status)}
 	}
+	tuple.handleID = queryResult.connection.trackChild(HandleFlatTuple, tuple)
+	tuple.numColumns = queryResult.getNumberOfColumns()
+	stats := queryResult.connection.stats()
+	stats.openFlatTuples.Add(1)
+	stats.tuplesFetched.Add(1)
+	queryResult.hasFetched = true
+	queryResult.numFetched++
 	return tuple, nil
 }
 
+// checkRowLimit returns an error wrapping a *RowLimitError if the row limit
+// of the result has been reached and there are more tuples. closeMutex of the
+// connection must be held.
+func (queryResult *QueryResult) checkRowLimit() error {
+	if queryResult.maxRows == 0 || queryResult.numFetched < queryResult.maxRows || !bool(C.lbug_query_result_has_next(&queryResult.cQueryResult)) {
+		return nil
+	}
+	if queryResult.numFetched == queryResult.maxRows {
+		queryResult.numFetched++
+		queryResult.connection.stats().rowLimitsExceeded.Add(1)
+	}
+	return &Error{Op: OpIterate, Err: &RowLimitError{
+		Limit: queryResult.maxRows,
+		Rows:  uint64(C.lbug_query_result_get_num_tuples(&queryResult.cQueryResult)),
+	}}
+}
+
 // HasNextQueryResult returns true not all the query results is consumed when
 // multiple query statements are executed.
 func (queryResult *QueryResult) HasNextQueryResult() bool {
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	if queryResult.isClosed {
+		return false
+	}
 	return bool(C.lbug_query_result_has_next_query_result(&queryResult.cQueryResult))
 }
 
 // NextQueryResult returns the next query result when multiple query statements are executed.
 func (queryResult *QueryResult) NextQueryResult() (*QueryResult, error) {
-	nextQueryResult := &QueryResult{}
+	nextQueryResult := queryResult.connection.newQueryResult(queryResult.query)
+	// The next results are auto-closed as the first one, on which auto-close
+	// may have been disabled.
+	nextQueryResult.autoClose = queryResult.autoClose
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	if queryResult.isClosed {
+		nextQueryResult.isClosed = true
+		return nextQueryResult, &Error{Op: OpIterate, Err: &closedError{"failed to get next query result because the query result is closed"}}
+	}
+	if err := queryResult.connection.reserveHandle(HandleQueryResult); err != nil {
+		nextQueryResult.isClosed = true
+		return nextQueryResult, &Error{Op: OpIterate, Err: err}
+	}
 	status := C.lbug_query_result_get_next_query_result(&queryResult.cQueryResult, &nextQueryResult.cQueryResult)
 	if status != C.LbugSuccess {
-		return nextQueryResult, fmt.Errorf("failed to get next query result with status %d", status)
+		handles.cancel(HandleQueryResult)
+		return nextQueryResult, &Error{Op: OpIterate, Message: fmt.Sprintf("failed to get next query result with status %d", status)}
+	}
+	nextQueryResult.handleID = queryResult.connection.trackChild(HandleQueryResult, nextQueryResult)
+	queryResult.connection.stats().openQueryResults.Add(1)
+	nextQueryResult.succeeded = bool(C.lbug_query_result_is_success(&nextQueryResult.cQueryResult))
+	if nextQueryResult.succeeded {
+		queryResult.connection.summarizeResultInTransaction(nextQueryResult)
 	}
 	return nextQueryResult, nil
 }
 
 // GetCompilingTime returns the compiling time of the query in milliseconds.
 func (queryResult *QueryResult) GetCompilingTime() float64 {
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	if queryResult.isClosed {
+		return 0
+	}
 	var cQuerySummary C.lbug_query_summary
 	C.lbug_query_result_get_query_summary(&queryResult.cQueryResult, &cQuerySummary)
 	defer C.lbug_query_summary_destroy(&cQuerySummary)
@@ -117,8 +384,47 @@ func (queryResult *QueryResult) GetCompilingTime() float64 {
 
 // GetExecutionTime returns the execution time of the query in milliseconds.
 func (queryResult *QueryResult) GetExecutionTime() float64 {
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	if queryResult.isClosed {
+		return 0
+	}
 	var cQuerySummary C.lbug_query_summary
 	C.lbug_query_result_get_query_summary(&queryResult.cQueryResult, &cQuerySummary)
 	defer C.lbug_query_summary_destroy(&cQuerySummary)
 	return float64(C.lbug_query_summary_get_execution_time(&cQuerySummary))
 }
+
+// IsOrdered reports whether the rows of the result are returned in a
+// deterministic order, which is only the case when the final RETURN clause of
+// the query has an ORDER BY. The ordering is detected from the text of the
+// query; known is false if it could not be determined, e.g. for
+// multi-statement queries, queries using UNION or CALL, or queries with an
+// ORDER BY only in a WITH clause.
+func (queryResult *QueryResult) IsOrdered() (ordered bool, known bool) {
+	switch queryResult.ordering {
+	case orderingOrdered:
+		return true, true
+	case orderingUnordered:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// checkOrderedIteration returns ErrUnorderedResult if the connection requires
+// ordered iteration and the result is known to be unordered. It must be
+// called by the features that rely on the order of the rows being stable.
+func (queryResult *QueryResult) checkOrderedIteration() error {
+	if queryResult.requireOrdered && queryResult.ordering == orderingUnordered {
+		return ErrUnorderedResult
+	}
+	return nil
+}
+
+// ConversionWarnings returns the values of unsupported logical types that were
+// converted to nil so far because the UnknownTypePolicy of the connection is
+// UnknownTypeSkip.
+func (queryResult *QueryResult) ConversionWarnings() []ConversionWarning {
+	return queryResult.converter.warnings
+}
@@ -1,6 +1,7 @@
 package lbug
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -33,6 +34,7 @@ func TestQueryResultResetIterator(t *testing.T) {
 	assert.True(t, res.HasNext())
 	tuple, err := res.Next()
 	assert.Nil(t, err)
+	defer tuple.Close()
 	value, err := tuple.GetValue(0)
 	assert.Nil(t, err)
 	assert.Equal(t, int64(0), value)
@@ -40,6 +42,7 @@ func TestQueryResultResetIterator(t *testing.T) {
 	assert.True(t, res.HasNext())
 	tuple, err = res.Next()
 	assert.Nil(t, err)
+	defer tuple.Close()
 	value, err = tuple.GetValue(0)
 	assert.Nil(t, err)
 	assert.Equal(t, int64(0), value)
@@ -74,7 +77,11 @@ func TestQueryResultGetNumberOfRows(t *testing.T) {
 	assert.Nil(t, err)
 	numRows := res.GetNumberOfRows()
 	assert.Equal(t, uint64(8), numRows)
+	// Caching the column names does not change the number of rows
+	assert.Equal(t, []string{"a"}, res.GetColumnNames())
+	assert.Equal(t, uint64(8), res.GetNumberOfRows())
 	res.Close()
+	assert.Equal(t, uint64(8), res.GetNumberOfRows())
 }
 
 func TestQueryResultHasNext(t *testing.T) {
@@ -82,8 +89,9 @@ func TestQueryResultHasNext(t *testing.T) {
 	res, err := conn.Query("MATCH (a:person) RETURN a LIMIT 1;")
 	assert.Nil(t, err)
 	assert.True(t, res.HasNext())
-	_, err = res.Next()
+	tuple, err := res.Next()
 	assert.Nil(t, err)
+	tuple.Close()
 	assert.False(t, res.HasNext())
 	res.Close()
 }
@@ -95,6 +103,7 @@ func TestQueryResultNext(t *testing.T) {
 	assert.True(t, res.HasNext())
 	tuple, err := res.Next()
 	assert.Nil(t, err)
+	defer tuple.Close()
 	values, err := tuple.GetAsSlice()
 	assert.Nil(t, err)
 	assert.Equal(t, 4, len(values))
@@ -113,6 +122,7 @@ func TestMultipleQueryResults(t *testing.T) {
 	assert.True(t, res.HasNext())
 	tuple, err := res.Next()
 	assert.Nil(t, err)
+	defer tuple.Close()
 	value, err := tuple.GetValue(0)
 	assert.Nil(t, err)
 	assert.Equal(t, int64(1), value)
@@ -124,6 +134,7 @@ func TestMultipleQueryResults(t *testing.T) {
 	assert.True(t, res.HasNext())
 	tuple, err = res.Next()
 	assert.Nil(t, err)
+	defer tuple.Close()
 	value, err = tuple.GetValue(0)
 	assert.Nil(t, err)
 	assert.Equal(t, int64(2), value)
@@ -135,6 +146,7 @@ func TestMultipleQueryResults(t *testing.T) {
 	assert.True(t, res.HasNext())
 	tuple, err = res.Next()
 	assert.Nil(t, err)
+	defer tuple.Close()
 	value, err = tuple.GetValue(0)
 	assert.Nil(t, err)
 	assert.Equal(t, int64(3), value)
@@ -157,3 +169,139 @@ func TestQueryResultGetExecutionTime(t *testing.T) {
 	assert.Greater(t, res.GetExecutionTime(), float64(0))
 	res.Close()
 }
+
+func TestQueryResultAutoClose(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	conn, _ := OpenConnection(db)
+	defer conn.Close()
+	conn.SetAutoCloseResults(true)
+	res, err := conn.Query("MATCH (a:person) WHERE a.ID = 0 RETURN a.fName, a.age;")
+	assert.Nil(t, err)
+	var tuple *FlatTuple
+	for res.HasNext() {
+		tuple, err = res.Next()
+		assert.Nil(t, err)
+	}
+	assert.True(t, res.isClosed)
+	// The tuples fetched from the result are closed with it
+	assert.True(t, tuple.isClosed)
+	_, err = tuple.GetAsSlice()
+	assert.ErrorIs(t, err, ErrClosed)
+	_, err = tuple.GetValue(0)
+	assert.ErrorIs(t, err, ErrClosed)
+	tuple.Close()
+	// Explicit close after auto-close should not panic
+	res.Close()
+	assert.True(t, res.isClosed)
+	assert.False(t, res.HasNext())
+	res.ResetIterator()
+	_, err = res.Next()
+	assert.NotNil(t, err)
+}
+
+func TestQueryResultAutoCloseRequiresFetch(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	conn, _ := OpenConnection(db)
+	defer conn.Close()
+	conn.SetAutoCloseResults(true)
+	res, err := conn.Query("MATCH (a:person) WHERE a.ID = -1 RETURN a.fName;")
+	assert.Nil(t, err)
+	assert.False(t, res.HasNext())
+	assert.False(t, res.isClosed)
+	res.Close()
+}
+
+func TestQueryResultAutoCloseMultipleStatements(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	conn, _ := OpenConnection(db)
+	defer conn.Close()
+	conn.SetAutoCloseResults(true)
+	res, err := conn.Query("RETURN 1; RETURN 2;")
+	assert.Nil(t, err)
+	defer res.Close()
+	for res.HasNext() {
+		tuple, err := res.Next()
+		assert.Nil(t, err)
+		tuple.Close()
+	}
+	// The first result is kept open while the second one is not consumed
+	assert.False(t, res.isClosed)
+	assert.True(t, res.HasNextQueryResult())
+	next, err := res.NextQueryResult()
+	assert.Nil(t, err)
+	defer next.Close()
+	assert.True(t, next.HasNext())
+	tuple, err := next.Next()
+	assert.Nil(t, err)
+	value, err := tuple.GetValue(0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), value)
+	tuple.Close()
+	assert.False(t, next.HasNext())
+	assert.True(t, next.isClosed)
+}
+
+func TestQueryResultAutoCloseDisabled(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	res, err := conn.Query("MATCH (a:person) WHERE a.ID = 0 RETURN a.ID;")
+	assert.Nil(t, err)
+	for res.HasNext() {
+		tuple, err := res.Next()
+		assert.Nil(t, err)
+		tuple.Close()
+	}
+	assert.False(t, res.isClosed)
+	res.ResetIterator()
+	assert.True(t, res.HasNext())
+	res.Close()
+}
+
+func TestQueryResultMaxRows(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	conn, _ := OpenConnection(db)
+	defer conn.Close()
+	conn.SetMaxRows(2)
+	exceeded := db.Stats().RowLimitsExceeded
+	res, err := conn.Query("UNWIND range(1, 5) AS i RETURN i;")
+	assert.Nil(t, err)
+	defer res.Close()
+	for i := 0; i < 2; i++ {
+		assert.True(t, res.HasNext())
+		tuple, err := res.Next()
+		assert.Nil(t, err)
+		tuple.Close()
+	}
+	assert.True(t, res.HasNext())
+	_, err = res.Next()
+	assert.ErrorIs(t, err, ErrRowLimitExceeded)
+	var limitErr *RowLimitError
+	assert.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, RowLimitError{Limit: 2, Rows: 5}, *limitErr)
+	_, err = res.Next()
+	assert.ErrorIs(t, err, ErrRowLimitExceeded)
+	assert.Equal(t, exceeded+1, db.Stats().RowLimitsExceeded)
+
+	// The limit applies again after the iterator is reset.
+	res.ResetIterator()
+	tuple, err := res.Next()
+	assert.Nil(t, err)
+	tuple.Close()
+}
+
+func TestQueryResultMaxRowsSmallerLimit(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	conn, _ := OpenConnection(db)
+	defer conn.Close()
+	conn.SetMaxRows(2)
+	res, err := conn.Query("UNWIND range(1, 5) AS i RETURN i LIMIT 2;")
+	assert.Nil(t, err)
+	defer res.Close()
+	count := 0
+	for res.HasNext() {
+		tuple, err := res.Next()
+		assert.Nil(t, err)
+		tuple.Close()
+		count++
+	}
+	assert.Equal(t, 2, count)
+}
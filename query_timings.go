@@ -0,0 +1,76 @@
+package lbug
+
+// #include "lbug.h"
+import "C"
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// QueryTimings breaks down the time spent on a query between the engine and
+// the bindings, see QueryResult.Timings.
+type QueryTimings struct {
+	// Compile and Execute are the compiling and execution times of the query
+	// reported by the engine.
+	Compile time.Duration
+	Execute time.Duration
+	// Fetch is the time spent by Next fetching tuples from the engine,
+	// including the cgo calls, summed over the tuples fetched so far.
+	Fetch time.Duration
+	// Decode is the time spent converting values to Go by GetValue,
+	// GetAsSlice and GetAsMap, summed over the tuples of the result decoded
+	// so far. The decoding of the typed getters, e.g. GetNode, is not
+	// measured.
+	Decode time.Duration
+}
+
+// bindingTimer sums the time spent in an operation of the bindings, with two
+// reads of the monotonic clock per operation.
+type bindingTimer struct {
+	nanoseconds atomic.Int64
+}
+
+// since adds the time elapsed since start.
+func (timer *bindingTimer) since(start time.Time) {
+	timer.nanoseconds.Add(int64(time.Since(start)))
+}
+
+func (timer *bindingTimer) total() time.Duration {
+	return time.Duration(timer.nanoseconds.Load())
+}
+
+// Timings returns the breakdown of the time spent on the query. The fetch and
+// decoding times grow as the result is iterated, and are final once the
+// result is closed; the engine times are kept when the result is closed, so
+// that they can still be read after Close, e.g. by the code observing the
+// end of a query.
+func (queryResult *QueryResult) Timings() QueryTimings {
+	queryResult.connection.closeMutex.RLock()
+	defer queryResult.connection.closeMutex.RUnlock()
+	timings := queryResult.engineTimings()
+	timings.Fetch = queryResult.fetchTimer.total()
+	timings.Decode = queryResult.decodeTimer.total()
+	return timings
+}
+
+// engineTimings returns the compiling and execution times of the query, read
+// from the summary of the query while the result is open. closeMutex of the
+// connection must be held.
+func (queryResult *QueryResult) engineTimings() QueryTimings {
+	if queryResult.isClosed || !queryResult.succeeded {
+		return queryResult.engineTimes
+	}
+	var cQuerySummary C.lbug_query_summary
+	C.lbug_query_result_get_query_summary(&queryResult.cQueryResult, &cQuerySummary)
+	defer C.lbug_query_summary_destroy(&cQuerySummary)
+	return QueryTimings{
+		Compile: engineDuration(float64(C.lbug_query_summary_get_compiling_time(&cQuerySummary))),
+		Execute: engineDuration(float64(C.lbug_query_summary_get_execution_time(&cQuerySummary))),
+	}
+}
+
+// engineDuration converts a time in milliseconds reported by the engine.
+func engineDuration(milliseconds float64) time.Duration {
+	return time.Duration(milliseconds * float64(time.Millisecond))
+}
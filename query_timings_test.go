@@ -0,0 +1,87 @@
+package lbug
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindingTimer(t *testing.T) {
+	var timer bindingTimer
+	timer.since(time.Now().Add(-time.Second))
+	timer.since(time.Now().Add(-time.Second))
+	assert.True(t, timer.total() >= 2*time.Second)
+	assert.Equal(t, 1500*time.Microsecond, engineDuration(1.5))
+}
+
+func TestQueryResultTimings(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	result, err := conn.Query("UNWIND range(1, 1000) AS i RETURN i, 'value' + cast(i, 'STRING') AS s;")
+	assert.Nil(t, err)
+	timings := result.Timings()
+	assert.True(t, timings.Compile > 0)
+	assert.True(t, timings.Execute > 0)
+	assert.Equal(t, time.Duration(0), timings.Fetch)
+	assert.Equal(t, time.Duration(0), timings.Decode)
+	for result.HasNext() {
+		tuple, err := result.Next()
+		assert.Nil(t, err)
+		_, err = tuple.GetAsSlice()
+		assert.Nil(t, err)
+		tuple.Close()
+	}
+	iterated := result.Timings()
+	assert.True(t, iterated.Fetch > 0)
+	assert.True(t, iterated.Decode > 0)
+	assert.Equal(t, timings.Compile, iterated.Compile)
+
+	// The timings are kept once the result is closed.
+	result.Close()
+	assert.Equal(t, iterated, result.Timings())
+}
+
+// BenchmarkBindingTimer measures the overhead of timing a row: two reads of
+// the monotonic clock and an atomic addition.
+func BenchmarkBindingTimer(b *testing.B) {
+	var timer bindingTimer
+	for i := 0; i < b.N; i++ {
+		timer.since(time.Now())
+	}
+}
+
+// BenchmarkTimedIteration iterates a result with the fetch and decoding
+// times measured, reporting the share of the time of the iteration spent
+// reading the clock, as estimated from BenchmarkBindingTimer.
+func BenchmarkTimedIteration(b *testing.B) {
+	_, conn := SetupTestDatabase(b)
+	var clock bindingTimer
+	start := time.Now()
+	for i := 0; i < 10000; i++ {
+		clock.since(time.Now())
+	}
+	perTiming := time.Since(start) / 10000
+	b.ResetTimer()
+	rows := 0
+	for i := 0; i < b.N; i++ {
+		result, err := conn.Query("UNWIND range(1, 10000) AS i RETURN i, cast(i, 'STRING') AS s;")
+		if err != nil {
+			b.Fatal(err)
+		}
+		for result.HasNext() {
+			tuple, err := result.Next()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := tuple.GetAsSlice(); err != nil {
+				b.Fatal(err)
+			}
+			tuple.Close()
+			rows++
+		}
+		result.Close()
+	}
+	b.StopTimer()
+	// Each row is timed twice, by Next and by GetAsSlice.
+	b.ReportMetric(100*float64(2*perTiming)*float64(rows)/float64(b.Elapsed()), "%clock")
+}
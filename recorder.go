@@ -0,0 +1,552 @@
+package lbug
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The operations written by a recording.
+const (
+	recordedQuery   = "query"
+	recordedPrepare = "prepare"
+	recordedExecute = "execute"
+	recordedExec    = "exec"
+)
+
+// RecordedOperation is a line of a recording written by a connection with
+// recording enabled. It describes the operation and its outcome, but never the
+// data of its result.
+type RecordedOperation struct {
+	// Op is "query" for Connection.Query, "prepare" for Connection.Prepare,
+	// "execute" for Connection.Execute and "exec" for PreparedStatement.Exec.
+	Op string `json:"op"`
+	// Query is the query string, or the query of the prepared statement.
+	Query string `json:"query"`
+	// Params are the arguments of an execution by name.
+	Params map[string]RecordedValue `json:"params,omitempty"`
+	// Time is the time the operation started.
+	Time time.Time `json:"time"`
+	// Duration is the time the operation took.
+	Duration time.Duration `json:"duration_ns"`
+	// Error is the message of the error returned by the operation, if any.
+	Error string `json:"error,omitempty"`
+	// Rows and Columns are the numbers of tuples and columns of the result.
+	// Exec records no columns.
+	Rows    uint64 `json:"rows,omitempty"`
+	Columns uint64 `json:"columns,omitempty"`
+	// InjectedLimit is the LIMIT appended to the query by the ExploratoryMode
+	// of the connection, if any. Query is the query before it was appended.
+	InjectedLimit int `json:"injected_limit,omitempty"`
+}
+
+// RecordedValue is a parameter value of a recording, tagged with its type so
+// that it is bound with the same type when replayed. Value is the JSON
+// encoding of the value: a number for integers and intervals in nanoseconds,
+// a string for floats, strings and RFC 3339 timestamps, an array of
+// RecordedValues for lists, an object of RecordedValues for structs and an
+// array of key and value pairs for maps. Values of types that cannot be
+// recorded, such as Blob, have the type UNSUPPORTED and their Go type as
+// value. The values of sensitive parameters, see MarkSensitiveParams, have the
+// type REDACTED and their redaction token as value.
+type RecordedValue struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// recordedMapItem is an item of a recorded MAP value.
+type recordedMapItem struct {
+	Key   RecordedValue `json:"key"`
+	Value RecordedValue `json:"value"`
+}
+
+// recorder writes the operations of a connection to a recording.
+type recorder struct {
+	mu     sync.Mutex
+	writer io.Writer
+	err    error
+}
+
+// EnableRecording starts writing a line of JSON to the writer for every call
+// to Query, Prepare and Execute of the connection and to Exec of its prepared
+// statements, in the order the calls complete. Each line is a
+// RecordedOperation written with a single call to Write. The recording can be
+// re-executed with Replay to reproduce a problem. Recording replaces a
+// previous recording of the connection. Connections may record to separate
+// writers concurrently; connections sharing a writer must be given one whose
+// Write calls are safe for concurrent use and do not interleave, such as an
+// *os.File opened with os.O_APPEND.
+func (conn *Connection) EnableRecording(w io.Writer) {
+	conn.recorder.Store(&recorder{writer: w})
+}
+
+// DisableRecording stops the recording of the connection and returns the
+// first error returned by the writer, if any. Recording stops at the first
+// error.
+func (conn *Connection) DisableRecording() error {
+	recorder := conn.recorder.Swap(nil)
+	if recorder == nil {
+		return nil
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return recorder.err
+}
+
+// record writes the operation started at start with the given outcome.
+func (recorder *recorder) record(op string, query string, args map[string]any, start time.Time, rows uint64, columns uint64, err error) {
+	recorder.write(newRecordedOperation(op, query, args, start, rows, columns, err))
+}
+
+// newRecordedOperation returns the recorded form of the operation started at
+// start with the given outcome.
+func newRecordedOperation(op string, query string, args map[string]any, start time.Time, rows uint64, columns uint64, err error) RecordedOperation {
+	operation := RecordedOperation{
+		Op:       op,
+		Query:    query,
+		Time:     start,
+		Duration: time.Since(start),
+		Rows:     rows,
+		Columns:  columns,
+	}
+	if err != nil {
+		operation.Error = err.Error()
+	}
+	if len(args) > 0 {
+		operation.Params = make(map[string]RecordedValue, len(args))
+		for key, value := range args {
+			operation.Params[key] = recordValue(value)
+		}
+	}
+	return operation
+}
+
+// write writes the operation to the recording.
+func (recorder *recorder) write(operation RecordedOperation) {
+	line, marshalErr := json.Marshal(operation)
+	line = append(line, '\n')
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if recorder.err != nil {
+		return
+	}
+	if marshalErr != nil {
+		recorder.err = fmt.Errorf("failed to record operation: %w", marshalErr)
+		return
+	}
+	if _, err := recorder.writer.Write(line); err != nil {
+		recorder.err = fmt.Errorf("failed to record operation: %w", err)
+	}
+}
+
+// recordResult records an operation returning a QueryResult.
+func (recorder *recorder) recordResult(op string, query string, args map[string]any, start time.Time, queryResult *QueryResult, err error) {
+	var rows, columns uint64
+	if err == nil {
+		rows = queryResult.GetNumberOfRows()
+		columns = queryResult.GetNumberOfColumns()
+	}
+	operation := newRecordedOperation(op, query, args, start, rows, columns, err)
+	if err == nil {
+		operation.InjectedLimit = queryResult.injectedLimit
+	}
+	recorder.write(operation)
+}
+
+// recordValue returns the recorded form of a parameter value.
+func recordValue(value any) RecordedValue {
+	encode := func(typeName string, v any) RecordedValue {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return unsupportedValue(value)
+		}
+		return RecordedValue{Type: typeName, Value: encoded}
+	}
+	if isNilContainer(value) {
+		return RecordedValue{Type: "NULL"}
+	}
+	switch v := value.(type) {
+	case redactedValue:
+		return encode("REDACTED", string(v))
+	case nil:
+		return RecordedValue{Type: "NULL"}
+	case bool:
+		return encode("BOOL", v)
+	case int:
+		return encode("INT64", v)
+	case int64:
+		return encode("INT64", v)
+	case int32:
+		return encode("INT32", v)
+	case int16:
+		return encode("INT16", v)
+	case int8:
+		return encode("INT8", v)
+	case uint:
+		return encode("UINT64", v)
+	case uint64:
+		return encode("UINT64", v)
+	case uint32:
+		return encode("UINT32", v)
+	case uint16:
+		return encode("UINT16", v)
+	case uint8:
+		return encode("UINT8", v)
+	case float64:
+		// Floats are recorded as strings, which can hold NaN and infinities.
+		return encode("DOUBLE", strconv.FormatFloat(v, 'g', -1, 64))
+	case float32:
+		return encode("FLOAT", strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case string:
+		return encode("STRING", v)
+	case time.Time:
+		return encode("TIMESTAMP", v.UTC().Format(time.RFC3339Nano))
+	case time.Duration:
+		return encode("INTERVAL", int64(v))
+	case map[string]any:
+		fields := make(map[string]RecordedValue, len(v))
+		for key, item := range v {
+			fields[key] = recordValue(item)
+		}
+		return encode("STRUCT", fields)
+	case json.RawMessage:
+		return encode("STRING", string(v))
+	case []MapItem:
+		items := make([]recordedMapItem, len(v))
+		for i, item := range v {
+			items[i] = recordedMapItem{Key: recordValue(item.Key), Value: recordValue(item.Value)}
+		}
+		return encode("MAP", items)
+	}
+	if marshaler, ok := value.(json.Marshaler); ok {
+		document, err := marshaler.MarshalJSON()
+		if err != nil {
+			return unsupportedValue(value)
+		}
+		return encode("STRING", string(document))
+	}
+	if reflect.TypeOf(value).Kind() == reflect.Slice {
+		sliceValue := reflect.ValueOf(value)
+		items := make([]RecordedValue, sliceValue.Len())
+		for i := range items {
+			items[i] = recordValue(sliceValue.Index(i).Interface())
+		}
+		return encode("LIST", items)
+	}
+	return unsupportedValue(value)
+}
+
+// unsupportedValue returns the recorded form of a value that cannot be
+// recorded.
+func unsupportedValue(value any) RecordedValue {
+	encoded, _ := json.Marshal(fmt.Sprintf("%T", value))
+	return RecordedValue{Type: "UNSUPPORTED", Value: encoded}
+}
+
+// replayValue returns the Go value of a recorded parameter value.
+func replayValue(recorded RecordedValue) (any, error) {
+	decode := func(v any) error {
+		if err := json.Unmarshal(recorded.Value, v); err != nil {
+			return fmt.Errorf("invalid recorded %s value %s: %w", recorded.Type, recorded.Value, err)
+		}
+		return nil
+	}
+	decodeFloat := func(bitSize int) (float64, error) {
+		var s string
+		if err := decode(&s); err != nil {
+			return 0, err
+		}
+		f, err := strconv.ParseFloat(s, bitSize)
+		if err != nil {
+			return 0, fmt.Errorf("invalid recorded %s value %s: %w", recorded.Type, recorded.Value, err)
+		}
+		return f, nil
+	}
+	var err error
+	switch recorded.Type {
+	case "NULL":
+		return nil, nil
+	case "BOOL":
+		var v bool
+		err = decode(&v)
+		return v, err
+	case "INT64":
+		var v int64
+		err = decode(&v)
+		return v, err
+	case "INT32":
+		var v int32
+		err = decode(&v)
+		return v, err
+	case "INT16":
+		var v int16
+		err = decode(&v)
+		return v, err
+	case "INT8":
+		var v int8
+		err = decode(&v)
+		return v, err
+	case "UINT64":
+		var v uint64
+		err = decode(&v)
+		return v, err
+	case "UINT32":
+		var v uint32
+		err = decode(&v)
+		return v, err
+	case "UINT16":
+		var v uint16
+		err = decode(&v)
+		return v, err
+	case "UINT8":
+		var v uint8
+		err = decode(&v)
+		return v, err
+	case "DOUBLE":
+		return decodeFloat(64)
+	case "FLOAT":
+		f, err := decodeFloat(32)
+		return float32(f), err
+	case "STRING":
+		var v string
+		err = decode(&v)
+		return v, err
+	case "TIMESTAMP":
+		var s string
+		if err := decode(&s); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recorded %s value %s: %w", recorded.Type, recorded.Value, err)
+		}
+		return t, nil
+	case "INTERVAL":
+		var v int64
+		err = decode(&v)
+		return time.Duration(v), err
+	case "LIST":
+		var items []RecordedValue
+		if err := decode(&items); err != nil {
+			return nil, err
+		}
+		list := make([]any, len(items))
+		for i, item := range items {
+			if list[i], err = replayValue(item); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case "STRUCT":
+		var fields map[string]RecordedValue
+		if err := decode(&fields); err != nil {
+			return nil, err
+		}
+		value := make(map[string]any, len(fields))
+		for key, field := range fields {
+			if value[key], err = replayValue(field); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	case "MAP":
+		var items []recordedMapItem
+		if err := decode(&items); err != nil {
+			return nil, err
+		}
+		value := make([]MapItem, len(items))
+		for i, item := range items {
+			if value[i].Key, err = replayValue(item.Key); err != nil {
+				return nil, err
+			}
+			if value[i].Value, err = replayValue(item.Value); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	case "UNSUPPORTED":
+		return nil, fmt.Errorf("parameter of type %s was not recorded", recorded.Value)
+	case "REDACTED":
+		return nil, fmt.Errorf("sensitive parameter was recorded as %s and cannot be replayed", recorded.Value)
+	}
+	return nil, fmt.Errorf("unknown recorded type %q", recorded.Type)
+}
+
+// ReplayOptions configures Replay.
+type ReplayOptions struct {
+	// Speed scales the delays between the operations: 1 replays them with the
+	// recorded timing, 2 twice as fast. If it is zero, the operations are
+	// replayed without delays.
+	Speed float64
+	// ReadResults fetches every tuple of each result and converts its values
+	// before closing the result, as the recorded application presumably did.
+	ReadResults bool
+}
+
+// ReplayMismatchError describes an operation whose outcome when replayed
+// differs from the recorded one.
+type ReplayMismatchError struct {
+	// Line is the line of the operation in the recording, starting at 1.
+	Line     int
+	Op       string
+	Query    string
+	Recorded string
+	Replayed string
+}
+
+func (err *ReplayMismatchError) Error() string {
+	return fmt.Sprintf("line %d: %s %q: recorded %s, replayed %s", err.Line, err.Op, err.Query, err.Recorded, err.Replayed)
+}
+
+// Replay re-executes the operations of a recording written by a connection
+// with recording enabled, in order, on the connection. The prepared
+// statements are prepared again by query and closed when Replay returns;
+// the lifetimes of the recorded handles are not recorded. Replay returns an
+// error if the recording cannot be read. Otherwise, it returns a
+// *ReplayMismatchError per operation whose error, number of rows or number of
+// columns differs from the recorded one, joined into one, or nil.
+func Replay(r io.Reader, conn *Connection, opts ReplayOptions) error {
+	statements := make(map[string]*PreparedStatement)
+	defer func() {
+		for _, statement := range statements {
+			statement.Close()
+		}
+	}()
+	prepare := func(query string) (*PreparedStatement, error) {
+		if statement, ok := statements[query]; ok {
+			return statement, nil
+		}
+		statement, err := conn.Prepare(query)
+		if err != nil {
+			statement.Close()
+			return nil, err
+		}
+		statements[query] = statement
+		return statement, nil
+	}
+
+	var mismatches []error
+	var first time.Time
+	var started time.Time
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<30)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var operation RecordedOperation
+		if err := json.Unmarshal(scanner.Bytes(), &operation); err != nil {
+			return fmt.Errorf("line %d: invalid recorded operation: %w", line, err)
+		}
+		if started.IsZero() {
+			first, started = operation.Time, time.Now()
+		} else if opts.Speed > 0 {
+			offset := time.Duration(float64(operation.Time.Sub(first)) / opts.Speed)
+			time.Sleep(time.Until(started.Add(offset)))
+		}
+
+		var rows, columns uint64
+		var err error
+		switch operation.Op {
+		case recordedQuery:
+			var queryResult *QueryResult
+			if queryResult, err = conn.Query(operation.Query); err == nil {
+				rows, columns, err = finishReplayedResult(queryResult, opts.ReadResults)
+			}
+		case recordedExecute, recordedExec:
+			args, argsErr := replayArgs(operation.Params)
+			if argsErr != nil {
+				return fmt.Errorf("line %d: %w", line, argsErr)
+			}
+			var statement *PreparedStatement
+			if statement, err = prepare(operation.Query); err != nil {
+				break
+			}
+			if operation.Op == recordedExec {
+				var summary WriteSummary
+				summary, err = statement.Exec(args)
+				rows = summary.NumTuples
+				break
+			}
+			var queryResult *QueryResult
+			if queryResult, err = conn.Execute(statement, args); err == nil {
+				rows, columns, err = finishReplayedResult(queryResult, opts.ReadResults)
+			}
+		case recordedPrepare:
+			_, err = prepare(operation.Query)
+		default:
+			return fmt.Errorf("line %d: unknown recorded operation %q", line, operation.Op)
+		}
+
+		var recorded error
+		if operation.Error != "" {
+			recorded = errors.New(operation.Error)
+		}
+		if operation.Error != "" || err != nil || operation.Rows != rows || operation.Columns != columns {
+			recordedOutcome := describeReplayOutcome(operation.Rows, operation.Columns, recorded)
+			replayedOutcome := describeReplayOutcome(rows, columns, err)
+			if recordedOutcome != replayedOutcome {
+				mismatches = append(mismatches, &ReplayMismatchError{
+					Line:     line,
+					Op:       operation.Op,
+					Query:    operation.Query,
+					Recorded: recordedOutcome,
+					Replayed: replayedOutcome,
+				})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read recording: %w", err)
+	}
+	return errors.Join(mismatches...)
+}
+
+// replayArgs returns the arguments of a recorded execution.
+func replayArgs(params map[string]RecordedValue) (map[string]any, error) {
+	args := make(map[string]any, len(params))
+	for key, param := range params {
+		value, err := replayValue(param)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", key, err)
+		}
+		args[key] = value
+	}
+	return args, nil
+}
+
+// finishReplayedResult returns the numbers of rows and columns of a replayed
+// result and closes it, after fetching every tuple and converting its values
+// if read is set.
+func finishReplayedResult(queryResult *QueryResult, read bool) (rows uint64, columns uint64, err error) {
+	defer queryResult.Close()
+	rows, columns = queryResult.GetNumberOfRows(), queryResult.GetNumberOfColumns()
+	for read && queryResult.HasNext() {
+		tuple, err := queryResult.Next()
+		if err != nil {
+			tuple.Close()
+			return rows, columns, err
+		}
+		_, err = tuple.GetAsSlice()
+		tuple.Close()
+		if err != nil {
+			return rows, columns, err
+		}
+	}
+	return rows, columns, nil
+}
+
+// describeReplayOutcome describes the outcome of an operation for a
+// ReplayMismatchError.
+func describeReplayOutcome(rows uint64, columns uint64, err error) string {
+	if err != nil {
+		return fmt.Sprintf("error %q", err.Error())
+	}
+	return fmt.Sprintf("%d rows and %d columns", rows, columns)
+}
@@ -0,0 +1,188 @@
+package lbug
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordValueRoundTrip(t *testing.T) {
+	values := []any{
+		nil,
+		true,
+		int64(math.MinInt64),
+		int32(-7),
+		int16(300),
+		int8(-1),
+		uint64(math.MaxUint64),
+		uint32(7),
+		uint16(65535),
+		uint8(255),
+		1.5,
+		float32(0.1),
+		"Hello World",
+		time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC),
+		90 * time.Minute,
+		[]any{int64(1), int64(2)},
+		map[string]any{"name": "Alice", "age": int64(30)},
+		[]MapItem{{Key: "a", Value: int64(1)}},
+	}
+	for _, value := range values {
+		encoded, err := json.Marshal(recordValue(value))
+		assert.Nil(t, err)
+		var recorded RecordedValue
+		assert.Nil(t, json.Unmarshal(encoded, &recorded))
+		got, err := replayValue(recorded)
+		assert.Nil(t, err)
+		assert.Equal(t, value, got, "%s", encoded)
+	}
+}
+
+func TestRecordValueCanonicalizesTypes(t *testing.T) {
+	got, err := replayValue(recordValue(7))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7), got)
+	got, err = replayValue(recordValue([]string{"a", "b"}))
+	assert.Nil(t, err)
+	assert.Equal(t, []any{"a", "b"}, got)
+	got, err = replayValue(recordValue(math.NaN()))
+	assert.Nil(t, err)
+	assert.True(t, math.IsNaN(got.(float64)))
+}
+
+func TestRecordValueUnsupported(t *testing.T) {
+	recorded := recordValue(Blob{Reader: strings.NewReader("abc"), Length: 3})
+	assert.Equal(t, "UNSUPPORTED", recorded.Type)
+	_, err := replayValue(recorded)
+	assert.ErrorContains(t, err, "lbug.Blob")
+}
+
+func TestRecordingAndReplay(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	var recording bytes.Buffer
+	conn.EnableRecording(&recording)
+	result, err := conn.Query("CREATE NODE TABLE person(name STRING, age INT64, PRIMARY KEY(name))")
+	assert.Nil(t, err)
+	result.Close()
+	stmt, err := conn.Prepare("CREATE (:person {name: $name, age: $age})")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	_, err = stmt.Exec(map[string]any{"name": "Alice", "age": 30})
+	assert.Nil(t, err)
+	result, err = conn.Execute(stmt, map[string]any{"name": "Bob", "age": int64(40)})
+	assert.Nil(t, err)
+	result.Close()
+	_, err = conn.Query("MATCH (p:missing) RETURN p")
+	assert.NotNil(t, err)
+	result, err = conn.Query("MATCH (p:person) RETURN p.name, p.age")
+	assert.Nil(t, err)
+	result.Close()
+	assert.Nil(t, conn.DisableRecording())
+
+	lines := strings.Split(strings.TrimSpace(recording.String()), "\n")
+	assert.Equal(t, 6, len(lines))
+	var operations []RecordedOperation
+	for _, line := range lines {
+		var operation RecordedOperation
+		assert.Nil(t, json.Unmarshal([]byte(line), &operation))
+		operations = append(operations, operation)
+	}
+	assert.Equal(t, []string{"query", "prepare", "exec", "execute", "query", "query"},
+		[]string{operations[0].Op, operations[1].Op, operations[2].Op, operations[3].Op, operations[4].Op, operations[5].Op})
+	assert.Equal(t, RecordedValue{Type: "INT64", Value: json.RawMessage("30")}, operations[2].Params["age"])
+	assert.NotEqual(t, "", operations[4].Error)
+	assert.Equal(t, uint64(2), operations[5].Rows)
+	assert.Equal(t, uint64(2), operations[5].Columns)
+	assert.NotContains(t, lines[5], "Alice")
+
+	_, replayConn := openTempTableTestConnection(t)
+	err = Replay(strings.NewReader(recording.String()), replayConn, ReplayOptions{ReadResults: true})
+	assert.Nil(t, err)
+	count, err := replayConn.Query("MATCH (p:person) RETURN count(*)")
+	assert.Nil(t, err)
+	defer count.Close()
+	tuple, err := count.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	value, err := tuple.GetValue(0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), value)
+}
+
+func TestReplayReportsMismatches(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	var recording bytes.Buffer
+	conn.EnableRecording(&recording)
+	result, err := conn.Query("CREATE NODE TABLE person(name STRING, PRIMARY KEY(name))")
+	assert.Nil(t, err)
+	result.Close()
+	assert.Nil(t, conn.DisableRecording())
+
+	// Replaying on the same connection fails because the table exists.
+	err = Replay(&recording, conn, ReplayOptions{})
+	var mismatch *ReplayMismatchError
+	assert.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, 1, mismatch.Line)
+	assert.Contains(t, mismatch.Replayed, "error")
+}
+
+func TestReplayTiming(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var recording bytes.Buffer
+	for _, offset := range []time.Duration{0, 200 * time.Millisecond} {
+		line, err := json.Marshal(RecordedOperation{Op: "query", Query: "RETURN 1", Time: start.Add(offset), Rows: 1, Columns: 1})
+		assert.Nil(t, err)
+		recording.Write(append(line, '\n'))
+	}
+
+	began := time.Now()
+	assert.Nil(t, Replay(bytes.NewReader(recording.Bytes()), conn, ReplayOptions{Speed: 1}))
+	assert.GreaterOrEqual(t, time.Since(began), 200*time.Millisecond)
+
+	began = time.Now()
+	assert.Nil(t, Replay(bytes.NewReader(recording.Bytes()), conn, ReplayOptions{}))
+	assert.Less(t, time.Since(began), 200*time.Millisecond)
+}
+
+func TestReplayInvalidRecording(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	err := Replay(strings.NewReader("{\"op\":\"query\",\"query\":\"RETURN 1\",\"rows\":1,\"columns\":1}\nnot json\n"), conn, ReplayOptions{})
+	assert.ErrorContains(t, err, "line 2")
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestRecordingWriteError(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	conn.EnableRecording(failingWriter{})
+	result, err := conn.Query("RETURN 1")
+	assert.Nil(t, err)
+	result.Close()
+	assert.ErrorContains(t, conn.DisableRecording(), "disk full")
+	assert.Nil(t, conn.DisableRecording())
+}
+
+func TestRecordValueJSON(t *testing.T) {
+	got, err := replayValue(recordValue(json.RawMessage(`{"a":1}`)))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"a":1}`, got)
+	got, err = replayValue(recordValue(jsonPoint{X: 1, Y: 2}))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"x":1,"y":2}`, got)
+}
+
+func TestRecordValueNilContainer(t *testing.T) {
+	assert.Equal(t, RecordedValue{Type: "NULL"}, recordValue([]string(nil)))
+	assert.Equal(t, RecordedValue{Type: "LIST", Value: json.RawMessage("[]")}, recordValue([]string{}))
+}
@@ -0,0 +1,342 @@
+package lbug
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// redactedToken prefixes the keyed hash that replaces the values of the
+// sensitive parameters.
+const redactedToken = "[REDACTED:"
+
+// redactionKey is the key of the hashes of the redacted values.
+var redactionKey atomic.Pointer[[]byte]
+
+func init() {
+	key := make([]byte, 32)
+	rand.Read(key)
+	redactionKey.Store(&key)
+}
+
+// SetRedactionKey sets the key of the hashes that replace the values of the
+// sensitive parameters. By default, the key is random and the hashes only tell
+// whether two redacted values of the process are equal; processes sharing a key
+// give the same hash to the same value, so that the values can be correlated
+// across the recordings and logs of the processes without being revealed. The
+// key is copied.
+func SetRedactionKey(key []byte) {
+	key = slices.Clone(key)
+	redactionKey.Store(&key)
+}
+
+// MarkSensitiveParams marks the parameters of the names, e.g. "ssn" for the
+// $ssn of a query, as holding personal data. The values of sensitive
+// parameters bound by Execute and Exec on the connection never appear in the
+// outputs of the connection: they are recorded by EnableRecording as REDACTED
+// values, which Replay cannot bind, and replaced in the messages of the
+// returned errors by a fixed token followed by a keyed hash of the value, see
+// SetRedactionKey. The causes of the errors remain reachable with errors.Is
+// and errors.As, but with their messages unredacted. Parameter names are case
+// sensitive, and marks cannot be removed.
+func (conn *Connection) MarkSensitiveParams(names ...string) {
+	if conn.sensitiveParams == nil {
+		conn.sensitiveParams = make(map[string]bool, len(names))
+	}
+	for _, name := range names {
+		conn.sensitiveParams[name] = true
+	}
+}
+
+type sensitiveParamsKey struct{}
+
+// WithSensitive returns a copy of the context marking the parameters of the
+// names as sensitive for the calls it is passed to, such as
+// ExecuteWithContext, in addition to the parameters marked on the connection
+// with MarkSensitiveParams.
+func WithSensitive(ctx context.Context, names ...string) context.Context {
+	previous, _ := ctx.Value(sensitiveParamsKey{}).([]string)
+	return context.WithValue(ctx, sensitiveParamsKey{}, append(slices.Clip(previous), names...))
+}
+
+// sensitiveParamsFromContext returns the names marked sensitive with
+// WithSensitive.
+func sensitiveParamsFromContext(ctx context.Context) []string {
+	names, _ := ctx.Value(sensitiveParamsKey{}).([]string)
+	return names
+}
+
+// redactedValue is the recorded value of a sensitive parameter, its token.
+type redactedValue string
+
+// redactionToken returns the token replacing the value: the fixed token and
+// the keyed hash of the canonical recorded form of the value.
+func redactionToken(value any) string {
+	canonical, _ := json.Marshal(recordValue(value))
+	mac := hmac.New(sha256.New, *redactionKey.Load())
+	mac.Write(canonical)
+	return redactedToken + hex.EncodeToString(mac.Sum(nil)[:8]) + "]"
+}
+
+// redaction redacts the values of the sensitive parameters of a call. A nil
+// redaction redacts nothing.
+type redaction struct {
+	params   map[string]any
+	tokens   map[string]string
+	replacer *strings.Replacer
+	// scalars are the replacements of the forms of scalars, longest first,
+	// made after those of replacer.
+	scalars []redactionReplacement
+}
+
+// redactionReplacement replaces a form of a sensitive value by its token.
+type redactionReplacement struct {
+	literal redactedLiteral
+	token   string
+}
+
+// redactedLiteral is a form in which a sensitive value may be written in an
+// error message. The forms of scalars, such as numbers and booleans, are only
+// replaced where they are a whole token of the message, so that the value 1
+// does not garble every other number holding the digit.
+type redactedLiteral struct {
+	text   string
+	scalar bool
+}
+
+// redaction returns the redaction of the sensitive parameters among the
+// parameters bound by a call, or nil if none is sensitive.
+func (conn *Connection) redaction(params map[string]any, extra []string) *redaction {
+	if len(conn.sensitiveParams) == 0 && len(extra) == 0 {
+		return nil
+	}
+	var r *redaction
+	for key, value := range params {
+		if !conn.sensitiveParams[key] && !slices.Contains(extra, key) {
+			continue
+		}
+		if r == nil {
+			r = &redaction{params: map[string]any{}, tokens: map[string]string{}}
+		}
+		r.params[key] = value
+		r.tokens[key] = redactionToken(value)
+	}
+	if r == nil {
+		return nil
+	}
+	var replacements []redactionReplacement
+	for key, value := range r.params {
+		for _, literal := range redactedLiterals(value, nil) {
+			replacements = append(replacements, redactionReplacement{literal, r.tokens[key]})
+		}
+	}
+	// The longest literals are replaced first, so that a literal containing
+	// another is not partially replaced.
+	slices.SortFunc(replacements, func(a, b redactionReplacement) int {
+		return len(b.literal.text) - len(a.literal.text)
+	})
+	var oldnew []string
+	for _, replacement := range replacements {
+		if replacement.literal.scalar {
+			r.scalars = append(r.scalars, replacement)
+		} else {
+			oldnew = append(oldnew, replacement.literal.text, replacement.token)
+		}
+	}
+	r.replacer = strings.NewReplacer(oldnew...)
+	return r
+}
+
+// redactedLiterals appends the forms in which the value, or its elements, may
+// be written in an error message.
+func redactedLiterals(value any, literals []redactedLiteral) []redactedLiteral {
+	addLiteral := func(literal redactedLiteral) {
+		if literal.text != "" && !slices.Contains(literals, literal) {
+			literals = append(literals, literal)
+		}
+	}
+	add := func(text string) {
+		addLiteral(redactedLiteral{text: text})
+	}
+	addScalar := func(text string) {
+		addLiteral(redactedLiteral{text: text, scalar: true})
+	}
+	switch v := value.(type) {
+	case nil:
+		return literals
+	case string:
+		add(v)
+		add(strconv.Quote(v))
+		return literals
+	case []byte:
+		add(string(v))
+		add(fmt.Sprintf("%x", v))
+		return literals
+	case time.Time:
+		add(v.String())
+		add(v.Format(time.RFC3339Nano))
+		add(v.UTC().Format(time.RFC3339Nano))
+		add(v.Format("2006-01-02"))
+		add(v.Format("2006-01-02 15:04:05.999999999"))
+		for _, n := range []int64{v.Unix(), v.UnixMilli(), v.UnixMicro(), v.UnixNano(), v.Unix() / secondsPerDay} {
+			addScalar(strconv.FormatInt(n, 10))
+		}
+		return literals
+	case *big.Int:
+		if v != nil {
+			addScalar(v.String())
+		}
+		return literals
+	case []any:
+		for _, item := range v {
+			literals = redactedLiterals(item, literals)
+		}
+	case map[string]any:
+		for _, item := range v {
+			literals = redactedLiterals(item, literals)
+		}
+	case []MapItem:
+		for _, item := range v {
+			literals = redactedLiterals(item.Key, literals)
+			literals = redactedLiterals(item.Value, literals)
+		}
+	default:
+		addScalar(fmt.Sprint(value))
+		return literals
+	}
+	add(fmt.Sprint(value))
+	return literals
+}
+
+// scrub replaces the values of the sensitive parameters in the message.
+func (r *redaction) scrub(message string) string {
+	if r == nil {
+		return message
+	}
+	message = r.replacer.Replace(message)
+	for _, replacement := range r.scalars {
+		message = replaceWholeTokens(message, replacement.literal.text, replacement.token)
+	}
+	return message
+}
+
+// replaceWholeTokens replaces the occurrences of the literal in the message
+// that are not part of a longer word or number, such as the 1 of "12" or
+// "0.1".
+func replaceWholeTokens(message string, literal string, token string) string {
+	var builder strings.Builder
+	start := 0
+	for offset := 0; ; {
+		i := strings.Index(message[offset:], literal)
+		if i < 0 {
+			break
+		}
+		i += offset
+		end := i + len(literal)
+		if !isWholeToken(message, i, end) {
+			offset = i + 1
+			continue
+		}
+		builder.WriteString(message[start:i])
+		builder.WriteString(token)
+		start, offset = end, end
+	}
+	if start == 0 {
+		return message
+	}
+	builder.WriteString(message[start:])
+	return builder.String()
+}
+
+// isWholeToken returns true if message[start:end] is neither preceded nor
+// followed by a letter, digit or underscore, nor by the separator of the
+// decimals of a number.
+func isWholeToken(message string, start int, end int) bool {
+	if start > 0 && (isWordByte(message[start-1]) || message[start-1] == '.') {
+		return false
+	}
+	if end < len(message) && isWordByte(message[end]) {
+		return false
+	}
+	return end+1 >= len(message) || message[end] != '.' || message[end+1] < '0' || message[end+1] > '9'
+}
+
+// isWordByte returns true for the ASCII letters, digits and underscore.
+func isWordByte(b byte) bool {
+	return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+// args returns a copy of the parameters with the values of the sensitive
+// parameters replaced by their tokens, for the recording.
+func (r *redaction) args(params map[string]any) map[string]any {
+	if r == nil {
+		return params
+	}
+	redacted := make(map[string]any, len(params))
+	for key, value := range params {
+		if token, ok := r.tokens[key]; ok {
+			redacted[key] = redactedValue(token)
+		} else {
+			redacted[key] = value
+		}
+	}
+	return redacted
+}
+
+// error returns the error with the values of the sensitive parameters
+// replaced in its message.
+func (r *redaction) error(err error) error {
+	if r == nil || err == nil {
+		return err
+	}
+	lbugErr, ok := err.(*Error)
+	if !ok {
+		return r.wrap(err)
+	}
+	return &Error{
+		Op:        lbugErr.Op,
+		Query:     lbugErr.Query,
+		Parameter: lbugErr.Parameter,
+		Column:    lbugErr.Column,
+		Message:   r.scrub(lbugErr.Message),
+		Err:       r.wrap(lbugErr.Err),
+	}
+}
+
+// wrap wraps the error in a redactedError if its message holds the value of a
+// sensitive parameter.
+func (r *redaction) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	message := err.Error()
+	if scrubbed := r.scrub(message); scrubbed != message {
+		return &redactedError{message: scrubbed, err: err}
+	}
+	return err
+}
+
+// redactedError is an error whose message has been redacted. It unwraps to
+// the original error.
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (err *redactedError) Error() string {
+	return err.message
+}
+
+func (err *redactedError) Unwrap() error {
+	return err.err
+}
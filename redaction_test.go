@@ -0,0 +1,153 @@
+package lbug
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSSN = "078-05-1120"
+
+func TestRedactionToken(t *testing.T) {
+	defer SetRedactionKey([]byte("restored"))
+	SetRedactionKey([]byte("key one"))
+	token := redactionToken(testSSN)
+	assert.True(t, strings.HasPrefix(token, redactedToken), token)
+	assert.Equal(t, token, redactionToken(testSSN))
+	assert.NotEqual(t, token, redactionToken("078-05-1121"))
+	assert.False(t, strings.Contains(token, testSSN))
+	SetRedactionKey([]byte("key two"))
+	assert.NotEqual(t, token, redactionToken(testSSN))
+}
+
+func TestWithSensitive(t *testing.T) {
+	ctx := WithSensitive(context.Background(), "ssn")
+	other := WithSensitive(ctx, "email")
+	assert.Equal(t, []string{"ssn"}, sensitiveParamsFromContext(ctx))
+	assert.Equal(t, []string{"ssn", "email"}, sensitiveParamsFromContext(other))
+	assert.Nil(t, sensitiveParamsFromContext(context.Background()))
+}
+
+func TestRedactionError(t *testing.T) {
+	conn := &Connection{}
+	assert.Nil(t, conn.redaction(map[string]any{"ssn": testSSN}, nil))
+	conn.MarkSensitiveParams("ssn", "born")
+	born := time.Date(1970, 1, 2, 0, 0, 0, 0, time.UTC)
+	r := conn.redaction(map[string]any{"ssn": testSSN, "born": born, "name": "Alice"}, nil)
+	cause := errors.New("invalid SSN " + testSSN + " born at 86400000000 microseconds")
+	err := r.error(&Error{Op: OpBind, Parameter: "ssn", Message: fmt.Sprintf("rejected %q", testSSN), Err: cause})
+	message := err.Error()
+	assert.False(t, strings.Contains(message, testSSN), message)
+	assert.False(t, strings.Contains(message, "86400000000"), message)
+	assert.Contains(t, message, redactionToken(testSSN))
+	assert.ErrorIs(t, err, cause)
+	var lbugErr *Error
+	if assert.ErrorAs(t, err, &lbugErr) {
+		assert.Equal(t, "ssn", lbugErr.Parameter)
+	}
+	// Errors without sensitive values are returned as they are.
+	plain := errors.New("Binder exception: table nonexistent does not exist")
+	assert.Equal(t, plain, r.error(plain))
+
+	args := r.args(map[string]any{"ssn": testSSN, "name": "Alice"})
+	assert.Equal(t, redactedValue(redactionToken(testSSN)), args["ssn"])
+	assert.Equal(t, "Alice", args["name"])
+
+	// Parameters marked on the call are redacted too.
+	r = conn.redaction(map[string]any{"email": "alice@example.com"}, []string{"email"})
+	assert.Equal(t, "no user "+redactionToken("alice@example.com"), r.scrub("no user alice@example.com"))
+}
+
+func TestRedactionSmallScalars(t *testing.T) {
+	conn := &Connection{}
+	conn.MarkSensitiveParams("level", "admin")
+	r := conn.redaction(map[string]any{"level": int64(1), "admin": true}, nil)
+	level, admin := redactionToken(int64(1)), redactionToken(true)
+	// Only whole tokens are replaced, not the digits of other numbers.
+	assert.Equal(t,
+		"level "+level+" of 12 at line 10, column 0.1, "+level+".",
+		r.scrub("level 1 of 12 at line 10, column 0.1, 1."))
+	assert.Equal(t, "admin="+admin+", trueness "+level, r.scrub("admin=true, trueness 1"))
+	assert.Equal(t, "Binder exception: table t1 does not exist", r.scrub("Binder exception: table t1 does not exist"))
+}
+
+func TestRecordValueRedacted(t *testing.T) {
+	token := redactionToken(testSSN)
+	recorded := recordValue(redactedValue(token))
+	assert.Equal(t, "REDACTED", recorded.Type)
+	_, err := replayValue(recorded)
+	assert.ErrorContains(t, err, "cannot be replayed")
+}
+
+// invalidSSN fails to convert with an error holding its value.
+type invalidSSN string
+
+func (ssn invalidSSN) LbugValue() (any, error) {
+	return nil, fmt.Errorf("%s is not a valid SSN", string(ssn))
+}
+
+func TestSensitiveParamsNeverOutput(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	var recording bytes.Buffer
+	conn.EnableRecording(&recording)
+	conn.MarkSensitiveParams("ssn")
+	var errs []error
+
+	// A successful execution, an engine error and bind errors.
+	statement, err := conn.Prepare("RETURN $ssn AS ssn;")
+	assert.Nil(t, err)
+	defer statement.Close()
+	result, err := conn.Execute(statement, map[string]any{"ssn": testSSN})
+	if assert.Nil(t, err) {
+		result.Close()
+	}
+	cast, err := conn.Prepare("RETURN CAST($ssn AS INT64);")
+	assert.Nil(t, err)
+	defer cast.Close()
+	_, err = conn.Execute(cast, map[string]any{"ssn": testSSN})
+	errs = append(errs, err)
+	_, err = conn.Execute(statement, map[string]any{"ssn": invalidSSN(testSSN)})
+	errs = append(errs, err)
+	_, err = statement.Exec(map[string]any{"ssn": invalidSSN(testSSN)})
+	errs = append(errs, err)
+	born := time.Date(300000, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err = conn.Execute(statement, map[string]any{"ssn": born})
+	errs = append(errs, err)
+
+	// A parameter marked on the context only.
+	email := "alice.smith@example.com"
+	emails, err := conn.Prepare("RETURN CAST($email AS INT64);")
+	assert.Nil(t, err)
+	defer emails.Close()
+	_, err = conn.ExecuteWithContext(WithSensitive(context.Background(), "email"), emails, map[string]any{"email": email})
+	errs = append(errs, err)
+
+	assert.Nil(t, conn.DisableRecording())
+	for _, err := range errs {
+		if assert.NotNil(t, err) {
+			assert.False(t, strings.Contains(err.Error(), testSSN), err.Error())
+			assert.False(t, strings.Contains(err.Error(), email), err.Error())
+			assert.False(t, strings.Contains(err.Error(), "300000-01-01"), err.Error())
+		}
+	}
+	assert.Contains(t, errs[0].Error(), redactionToken(testSSN))
+	assert.ErrorIs(t, errs[3], ErrTimeOutOfRange)
+	output := recording.String()
+	assert.False(t, strings.Contains(output, testSSN), output)
+	assert.False(t, strings.Contains(output, email), output)
+	assert.False(t, strings.Contains(output, "300000-01-01"), output)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		var operation RecordedOperation
+		assert.Nil(t, json.Unmarshal([]byte(line), &operation))
+		for _, param := range operation.Params {
+			assert.Equal(t, "REDACTED", param.Type)
+		}
+	}
+}
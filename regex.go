@@ -0,0 +1,209 @@
+package lbug
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// matchAnyPattern is the pattern bound in place of a regular expression
+// filtered on the client, which matches every string.
+const matchAnyPattern = "(?s).*"
+
+// RegexOptions configures PreparedStatement.BindRegex.
+type RegexOptions struct {
+	// FilterColumn, if set, is the column of the result holding the strings
+	// the regular expression is matched against, so that a regular
+	// expression that cannot be translated is matched on the client instead
+	// of failing BindRegex: the parameter is bound to a pattern matching any
+	// string, and HasNext and Next skip the rows whose value of the column is
+	// NULL or does not match. The other ways of reading the result, e.g.
+	// GetNumberOfRows or NextChunk, see the rows that are skipped.
+	FilterColumn string
+}
+
+// regexBinding is a regular expression bound to a parameter of a prepared
+// statement by BindRegex.
+type regexBinding struct {
+	// pattern is the pattern bound to the parameter.
+	pattern string
+	// filter and column are the regular expression matched on the client
+	// and the column it is matched against, if it could not be translated.
+	filter *regexp.Regexp
+	column string
+}
+
+// TranslateRegex returns the pattern for the regular expressions of the
+// engine matching the same strings as re.MatchString, both with the =~
+// operator, which matches whole strings, and with regexp_matches. If re uses
+// constructs that the engine does not support or interprets differently, such
+// as the ungreedy flag (?U) or named groups written (?<name>...), it returns
+// an *UnsupportedRegexError listing them.
+func TranslateRegex(re *regexp.Regexp) (string, error) {
+	pattern := re.String()
+	if constructs := unsupportedRegexConstructs(pattern); len(constructs) > 0 {
+		return "", &UnsupportedRegexError{Pattern: pattern, Constructs: constructs}
+	}
+	return "(?s:.*)(?:" + pattern + ")(?s:.*)", nil
+}
+
+// BindRegex binds the translation of re by TranslateRegex to the parameter
+// name of the statement for its next executions, unless an argument of the
+// same name is passed to Execute. If re cannot be translated, BindRegex fails
+// with an *UnsupportedRegexError unless opts.FilterColumn is set, see
+// RegexOptions. A nil re removes the binding.
+func (stmt *PreparedStatement) BindRegex(name string, re *regexp.Regexp, opts RegexOptions) error {
+	if !stmt.parameters[name] {
+		return fmt.Errorf("prepared statement has no parameter $%s", name)
+	}
+	if re == nil {
+		delete(stmt.regexes, name)
+		return nil
+	}
+	binding := regexBinding{}
+	pattern, err := TranslateRegex(re)
+	switch {
+	case err == nil:
+		binding.pattern = pattern
+	case opts.FilterColumn != "":
+		binding = regexBinding{pattern: matchAnyPattern, filter: re, column: opts.FilterColumn}
+	default:
+		return err
+	}
+	if stmt.regexes == nil {
+		stmt.regexes = make(map[string]regexBinding)
+	}
+	stmt.regexes[name] = binding
+	return nil
+}
+
+// bindRegexes binds the regular expressions of the statement not among the
+// arguments, and adds those matched on the client to the filters of the
+// result.
+func (conn *Connection) bindRegexes(stmt *PreparedStatement, args map[string]any, queryResult *QueryResult) error {
+	for name, binding := range stmt.regexes {
+		if _, ok := args[name]; ok {
+			continue
+		}
+		if err := conn.bindParameter(stmt, name, binding.pattern); err != nil {
+			return err
+		}
+		if binding.filter != nil {
+			queryResult.regexFilters = append(queryResult.regexFilters, binding)
+		}
+	}
+	return nil
+}
+
+// fetchFiltered fetches the next tuple whose values match the regular
+// expressions filtered on the client into pending, unless there is one
+// already, and returns false if there is none. A tuple that fails to be
+// fetched or matched is kept with its error.
+func (queryResult *QueryResult) fetchFiltered() bool {
+	for queryResult.pending == nil {
+		if !queryResult.hasNextTuple() {
+			return false
+		}
+		tuple, err := queryResult.nextTuple()
+		if err == nil {
+			var matches bool
+			matches, err = queryResult.matchesFilters(tuple)
+			if err == nil && !matches {
+				tuple.Close()
+				continue
+			}
+		}
+		queryResult.pending, queryResult.pendingErr = tuple, err
+	}
+	return true
+}
+
+// matchesFilters returns true if the tuple matches the regular expressions
+// filtered on the client.
+func (queryResult *QueryResult) matchesFilters(tuple *FlatTuple) (bool, error) {
+	names := queryResult.GetColumnNames()
+	for _, filter := range queryResult.regexFilters {
+		index := -1
+		for i, name := range names {
+			if name == filter.column {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return false, fmt.Errorf("result has no column %s to match %s against", filter.column, filter.filter)
+		}
+		value, err := tuple.GetValue(uint64(index))
+		if err != nil {
+			return false, err
+		}
+		if value == nil {
+			return false, nil
+		}
+		text, ok := value.(string)
+		if !ok {
+			return false, fmt.Errorf("column %s of type %T cannot be matched against %s", filter.column, value, filter.filter)
+		}
+		if !filter.filter.MatchString(text) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// discardPending closes the tuple fetched ahead by fetchFiltered, if any.
+func (queryResult *QueryResult) discardPending() {
+	if queryResult.pending != nil {
+		queryResult.pending.Close()
+		queryResult.pending, queryResult.pendingErr = nil, nil
+	}
+}
+
+// unsupportedRegexConstructs returns the constructs of a valid Go regular
+// expression that the engine does not support or interprets differently.
+func unsupportedRegexConstructs(pattern string) []string {
+	var constructs []string
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		rest := pattern[i:]
+		switch {
+		case strings.HasPrefix(rest, `\Q`):
+			// The text up to \E is literal.
+			end := strings.Index(rest, `\E`)
+			if end < 0 {
+				return constructs
+			}
+			i += end + 1
+		case rest[0] == '\\':
+			i++
+		case inClass:
+			if strings.HasPrefix(rest, "[:") {
+				if end := strings.Index(rest, ":]"); end >= 0 {
+					i += end + 1
+				}
+			} else if rest[0] == ']' {
+				inClass = false
+			}
+		case rest[0] == '[':
+			inClass = true
+			// A ] first in the class, after an optional ^, is literal.
+			if strings.HasPrefix(rest, "[^") {
+				i++
+			}
+			if i+1 < len(pattern) && pattern[i+1] == ']' {
+				i++
+			}
+		case strings.HasPrefix(rest, "(?<"):
+			end := strings.IndexByte(rest, '>')
+			constructs = append(constructs, rest[:end+1])
+			i += end
+		case strings.HasPrefix(rest, "(?") && !strings.HasPrefix(rest, "(?P<"):
+			end := strings.IndexAny(rest, ":)")
+			if strings.ContainsRune(rest[2:end], 'U') {
+				constructs = append(constructs, rest[:end+1])
+			}
+			i += end
+		}
+	}
+	return constructs
+}
@@ -0,0 +1,145 @@
+package lbug
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// regexCorpus are the patterns matched by the engine and on the client by
+// TestBindRegex.
+var regexCorpus = []string{
+	`abc`,
+	`^abc`,
+	`abc$`,
+	`^$`,
+	`(?i)ABC`,
+	`a.c`,
+	`(?s)a.c`,
+	`(?m)^c`,
+	`[[:digit:]]+`,
+	`\d{3}-\d{4}`,
+	`[^a-z]`,
+	`[]a]`,
+	`\bword\b`,
+	`(?P<first>ab)+c`,
+	`a|^$`,
+	`\Q(?U)\E`,
+	`\p{Greek}`,
+	`colou?r`,
+}
+
+// regexStrings are the values of the nodes of TestBindRegex.
+var regexStrings = []string{"abc", "xabcx", "ABC", "a\nc", "b\nc", "555-1234", "word up", "swordfish", "ababc", "", "color", "colour", "λόγος", "]", "(?U)"}
+
+func TestUnsupportedRegexConstructs(t *testing.T) {
+	for pattern, constructs := range map[string][]string{
+		`abc`:                nil,
+		`(?i)abc`:            nil,
+		`(?P<name>a)`:        nil,
+		`(?U)a+`:             {"(?U)"},
+		`(?iU:a+)b(?U)`:      {"(?iU:", "(?U)"},
+		`(?<name>a)`:         {"(?<name>"},
+		`\(?U\)`:             nil,
+		`[(?U)]`:             nil,
+		`[]()?U]`:            nil,
+		`[[:alpha:](?U)]`:    nil,
+		`\Q(?U)\E(?U)`:       {"(?U)"},
+		`\Q(?U)`:             nil,
+		`(?:a)(?s:b)(?-U:c)`: {"(?-U:"},
+	} {
+		regexp.MustCompile(pattern)
+		assert.Equal(t, constructs, unsupportedRegexConstructs(pattern), pattern)
+	}
+
+	_, err := TranslateRegex(regexp.MustCompile(`(?U)a+`))
+	var regexErr *UnsupportedRegexError
+	if assert.True(t, errors.As(err, &regexErr)) {
+		assert.Equal(t, []string{"(?U)"}, regexErr.Constructs)
+		assert.ErrorContains(t, err, `"(?U)a+" uses constructs not supported by the engine: (?U)`)
+	}
+	pattern, err := TranslateRegex(regexp.MustCompile(`^ab`))
+	assert.Nil(t, err)
+	assert.Equal(t, `(?s:.*)(?:^ab)(?s:.*)`, pattern)
+}
+
+func TestBindRegex(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE str(id INT64, v STRING, PRIMARY KEY(id));")
+	statement, err := conn.Prepare("CREATE (:str {id: $id, v: $v});")
+	assert.Nil(t, err)
+	for i, value := range regexStrings {
+		_, err := statement.Exec(map[string]any{"id": int64(i), "v": value})
+		assert.Nil(t, err)
+	}
+	statement.Close()
+	mustRun(t, conn, "CREATE (:str {id: 1000});")
+
+	matched := func(statement *PreparedStatement) []string {
+		result, err := conn.Execute(statement, nil)
+		if !assert.Nil(t, err) {
+			return nil
+		}
+		defer result.Close()
+		values := []string{}
+		for result.HasNext() {
+			tuple, err := result.Next()
+			if !assert.Nil(t, err) {
+				return nil
+			}
+			value, err := tuple.GetValue(0)
+			tuple.Close()
+			assert.Nil(t, err)
+			values = append(values, value.(string))
+		}
+		return values
+	}
+	for _, query := range []string{
+		"MATCH (s:str) WHERE s.v =~ $re RETURN s.v ORDER BY s.id;",
+		"MATCH (s:str) WHERE regexp_matches(s.v, $re) RETURN s.v ORDER BY s.id;",
+	} {
+		engine, err := conn.Prepare(query)
+		assert.Nil(t, err)
+		defer engine.Close()
+		client, err := conn.Prepare(query)
+		assert.Nil(t, err)
+		defer client.Close()
+		for _, pattern := range regexCorpus {
+			re := regexp.MustCompile(pattern)
+			expected := []string{}
+			for _, value := range regexStrings {
+				if re.MatchString(value) {
+					expected = append(expected, value)
+				}
+			}
+			assert.Nil(t, engine.BindRegex("re", re, RegexOptions{}))
+			client.regexes = map[string]regexBinding{"re": {pattern: matchAnyPattern, filter: re, column: "s.v"}}
+			assert.Equal(t, expected, matched(engine), pattern)
+			assert.Equal(t, expected, matched(client), pattern)
+		}
+	}
+
+	// An untranslatable pattern fails without a filter column, and is matched
+	// on the client with one.
+	statement, err = conn.Prepare("MATCH (s:str) WHERE s.v =~ $re RETURN s.v ORDER BY s.id;")
+	assert.Nil(t, err)
+	defer statement.Close()
+	ungreedy := regexp.MustCompile(`(?U)^a.+c`)
+	var regexErr *UnsupportedRegexError
+	assert.True(t, errors.As(statement.BindRegex("re", ungreedy, RegexOptions{}), &regexErr))
+	assert.Nil(t, statement.BindRegex("re", ungreedy, RegexOptions{FilterColumn: "s.v"}))
+	assert.Equal(t, []string{"abc", "ababc"}, matched(statement))
+	// ResetIterator discards the tuple fetched ahead.
+	result, err := conn.Execute(statement, nil)
+	assert.Nil(t, err)
+	assert.True(t, result.HasNext())
+	result.ResetIterator()
+	assert.True(t, result.HasNext())
+	result.Close()
+
+	assert.ErrorContains(t, statement.BindRegex("other", ungreedy, RegexOptions{}), "no parameter $other")
+	assert.Nil(t, statement.BindRegex("re", nil, RegexOptions{}))
+	assert.Empty(t, statement.regexes)
+}
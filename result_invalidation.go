@@ -0,0 +1,67 @@
+package lbug
+
+// invalidationMark holds the numbers of checkpoints and schema changes of the
+// database when a result was returned: in total, for the fast check of Next,
+// and made through other connections than the connection of the result.
+type invalidationMark struct {
+	checkpoints, schemaChanges           uint64
+	otherCheckpoints, otherSchemaChanges uint64
+}
+
+// recordSchemaChange counts a committed schema change made through the
+// connection. snapshotMutex must be held.
+func (conn *Connection) recordSchemaChange() {
+	conn.ownSchemaChanges++
+	conn.database.snapshot.schemaChanges.Add(1)
+}
+
+// invalidationMark returns the current numbers of checkpoints and schema
+// changes of the database.
+func (conn *Connection) invalidationMark() invalidationMark {
+	counter := conn.database.snapshot
+	if counter == nil {
+		return invalidationMark{}
+	}
+	conn.snapshotMutex.Lock()
+	defer conn.snapshotMutex.Unlock()
+	mark := invalidationMark{
+		checkpoints:   counter.checkpoints.Load(),
+		schemaChanges: counter.schemaChanges.Load(),
+	}
+	mark.otherCheckpoints = mark.checkpoints - conn.ownCheckpoints
+	mark.otherSchemaChanges = mark.schemaChanges - conn.ownSchemaChanges
+	return mark
+}
+
+// checkInvalidated returns a *ResultInvalidatedError if the result can no
+// longer be iterated safely: its connection has been closed, or another
+// connection to the database has run a CHECKPOINT or changed the schema since
+// the result was returned. Checkpoints and schema changes made through the
+// connection of the result do not invalidate it. closeMutex of the connection
+// must be held.
+func (queryResult *QueryResult) checkInvalidated() error {
+	conn := queryResult.connection
+	err := &ResultInvalidatedError{Query: queryResult.query, Delivered: queryResult.numFetched}
+	if conn.isClosed {
+		err.Cause = InvalidatedByClose
+		return err
+	}
+	counter := conn.database.snapshot
+	if counter == nil ||
+		counter.checkpoints.Load() == queryResult.invalidation.checkpoints &&
+			counter.schemaChanges.Load() == queryResult.invalidation.schemaChanges {
+		return nil
+	}
+	mark := conn.invalidationMark()
+	switch {
+	case mark.otherSchemaChanges != queryResult.invalidation.otherSchemaChanges:
+		err.Cause = InvalidatedBySchemaChange
+	case mark.otherCheckpoints != queryResult.invalidation.otherCheckpoints:
+		err.Cause = InvalidatedByCheckpoint
+	default:
+		// Only the connection of the result has changed the database.
+		queryResult.invalidation = mark
+		return nil
+	}
+	return err
+}
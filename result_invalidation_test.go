@@ -0,0 +1,109 @@
+package lbug
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultInvalidatedError(t *testing.T) {
+	err := error(&Error{Op: OpIterate, Err: &ResultInvalidatedError{Query: "RETURN 1;", Cause: InvalidatedByCheckpoint, Delivered: 3}})
+	assert.ErrorIs(t, err, ErrResultInvalidated)
+	assert.False(t, errors.Is(err, ErrClosed))
+	assert.ErrorContains(t, err, "checkpoint")
+	err = &ResultInvalidatedError{Cause: InvalidatedByClose}
+	assert.ErrorIs(t, err, ErrResultInvalidated)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.Equal(t, "schema change", InvalidatedBySchemaChange.String())
+	assert.Equal(t, "InvalidationCause(0)", InvalidationCause(0).String())
+}
+
+// assertInvalidated fetches the next tuple of the result and checks that it
+// fails with a *ResultInvalidatedError of the cause.
+func assertInvalidated(t *testing.T, result *QueryResult, cause InvalidationCause, delivered uint64) {
+	t.Helper()
+	_, err := result.Next()
+	assert.ErrorIs(t, err, ErrResultInvalidated)
+	var invalidated *ResultInvalidatedError
+	if assert.ErrorAs(t, err, &invalidated) {
+		assert.Equal(t, cause, invalidated.Cause)
+		assert.Equal(t, delivered, invalidated.Delivered)
+	}
+}
+
+// TestResultInvalidatedByOtherConnection iterates a streamed and a frozen
+// result while another connection changes the schema and checkpoints.
+func TestResultInvalidatedByOtherConnection(t *testing.T) {
+	db, conn := SetupTestDatabase(t)
+	other, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer other.Close()
+	const read = "MATCH (p:person) RETURN p.ID ORDER BY p.ID;"
+
+	streamed, err := conn.Query(read)
+	assert.Nil(t, err)
+	defer streamed.Close()
+	materialized, err := conn.Query(read)
+	assert.Nil(t, err)
+	frozen, err := materialized.Freeze()
+	assert.Nil(t, err)
+	tuple, err := streamed.Next()
+	assert.Nil(t, err)
+	tuple.Close()
+
+	done := make(chan error)
+	go func() {
+		result, err := other.Query("ALTER TABLE person ADD extra INT64 DEFAULT 1;")
+		if err == nil {
+			result.Close()
+		}
+		done <- err
+	}()
+	assert.Nil(t, <-done)
+	assertInvalidated(t, streamed, InvalidatedBySchemaChange, 1)
+	// The frozen result is unaffected, and the connection remains usable.
+	assert.Equal(t, int64(frozen.Len()), countPersons(t, conn, nil))
+	assert.Equal(t, int64(0), frozen.Row(0)[0])
+	assert.False(t, conn.lastCallFailed.Load())
+
+	streamed, err = conn.Query(read)
+	assert.Nil(t, err)
+	defer streamed.Close()
+	mustRun(t, other, "CHECKPOINT;")
+	assertInvalidated(t, streamed, InvalidatedByCheckpoint, 0)
+
+	// Schema changes of the connection of the result do not invalidate it,
+	// neither do the schema changes of a rolled back transaction.
+	streamed, err = conn.Query(read)
+	assert.Nil(t, err)
+	defer streamed.Close()
+	mustRun(t, conn, "ALTER TABLE person DROP extra;")
+	mustRun(t, other, "BEGIN TRANSACTION;")
+	mustRun(t, other, "ALTER TABLE person ADD extra INT64;")
+	mustRun(t, other, "ROLLBACK;")
+	rows := 0
+	for streamed.HasNext() {
+		tuple, err := streamed.Next()
+		assert.Nil(t, err)
+		tuple.Close()
+		rows++
+	}
+	assert.Equal(t, frozen.Len(), rows)
+}
+
+func TestResultInvalidatedByClose(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	result, err := conn.Query("UNWIND range(1, 3) AS i RETURN i;")
+	assert.Nil(t, err)
+	defer result.Close()
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+	tuple.Close()
+	conn.Close()
+	assertInvalidated(t, result, InvalidatedByClose, 1)
+	_, err = result.Next()
+	assert.ErrorIs(t, err, ErrClosed)
+}
@@ -0,0 +1,182 @@
+package lbug
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// rowSource is a data source registered with Connection.RegisterRowSource.
+type rowSource struct {
+	columns []ColumnSpec
+	next    func() ([]any, error)
+}
+
+// RegisterRowSource registers a data source of the connection, which queries
+// run with Query read with "LOAD FROM go_source('<name>')", e.g.
+//
+//	LOAD FROM go_source('events') WITH * MATCH (p:person {name: user}) RETURN p.name, kind
+//
+// The engine cannot call back into Go while it executes a query, so Query
+// reads the source before executing the query: it calls next until it returns
+// io.EOF, spools the rows to a temporary CSV file and rewrites the clause to
+// "LOAD WITH HEADERS (<columns>) FROM '<file>' (HEADER=true)". The file is
+// removed before Query returns.
+//
+// next is called on the goroutine calling Query and never concurrently. Each
+// row must have one value per column, in the order of columns, and the values
+// must be nil, booleans, integers, floats, strings without line breaks or
+// time.Time values. nil values and empty strings are read as NULL. A source is
+// read by a single query: it is unregistered when the query reads it, and must
+// be registered again to be read again. If next returns an error other than
+// io.EOF, Query returns an *Error wrapping it without executing the query.
+//
+// go_source is only rewritten by Query, not by Prepare or Execute.
+func (conn *Connection) RegisterRowSource(name string, columns []ColumnSpec, next func() ([]any, error)) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("row source %s must have at least one column", name)
+	}
+	conn.rowSourcesMutex.Lock()
+	defer conn.rowSourcesMutex.Unlock()
+	if _, ok := conn.rowSources[name]; ok {
+		return fmt.Errorf("row source %s is already registered", name)
+	}
+	if conn.rowSources == nil {
+		conn.rowSources = make(map[string]*rowSource)
+	}
+	conn.rowSources[name] = &rowSource{columns: columns, next: next}
+	return nil
+}
+
+// UnregisterRowSource unregisters a data source registered with
+// RegisterRowSource that has not been read yet. It does nothing if there is no
+// such source.
+func (conn *Connection) UnregisterRowSource(name string) {
+	conn.rowSourcesMutex.Lock()
+	defer conn.rowSourcesMutex.Unlock()
+	delete(conn.rowSources, name)
+}
+
+// rowSourcePattern matches a LOAD FROM clause reading a row source, with the
+// name of the source in the first group.
+var rowSourcePattern = regexp.MustCompile(`(?i:LOAD\s+FROM)\s+go_source\(\s*'([^']*)'\s*\)`)
+
+// spoolRowSources spools the row sources read by the query to temporary files
+// and returns the rewritten query and the files, which the caller must remove,
+// also on error.
+func (conn *Connection) spoolRowSources(query string) (string, []string, error) {
+	if !strings.Contains(query, "go_source") {
+		return query, nil, nil
+	}
+	var files []string
+	spooled := make(map[string]string)
+	var rewriteErr error
+	rewritten := rowSourcePattern.ReplaceAllStringFunc(query, func(clause string) string {
+		if rewriteErr != nil {
+			return clause
+		}
+		name := rowSourcePattern.FindStringSubmatch(clause)[1]
+		if replacement, ok := spooled[name]; ok {
+			return replacement
+		}
+		conn.rowSourcesMutex.Lock()
+		source, ok := conn.rowSources[name]
+		delete(conn.rowSources, name)
+		conn.rowSourcesMutex.Unlock()
+		if !ok {
+			rewriteErr = &Error{Op: OpExecute, Query: query, Message: fmt.Sprintf("no row source named %s is registered", name)}
+			return clause
+		}
+		file, err := source.spool()
+		if file != "" {
+			files = append(files, file)
+		}
+		if err != nil {
+			rewriteErr = &Error{Op: OpExecute, Query: query, Message: "failed to read row source " + name, Err: err}
+			return clause
+		}
+		var replacement strings.Builder
+		replacement.WriteString("LOAD WITH HEADERS (")
+		for i, column := range source.columns {
+			if i > 0 {
+				replacement.WriteString(", ")
+			}
+			fmt.Fprintf(&replacement, "%s %s", QuoteIdentifier(column.Name), column.Type)
+		}
+		fmt.Fprintf(&replacement, ") FROM %s (HEADER=true)", quoteStringLiteral(file))
+		spooled[name] = replacement.String()
+		return spooled[name]
+	})
+	return rewritten, files, rewriteErr
+}
+
+// spool reads all rows of the source into a temporary CSV file and returns
+// its path, which is set if the file was created even if an error occurred.
+func (source *rowSource) spool() (string, error) {
+	file, err := os.CreateTemp("", "lbug-source-*.csv")
+	if err != nil {
+		return "", err
+	}
+	err = source.write(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return file.Name(), err
+}
+
+// write writes the header and the rows of the source to the file.
+func (source *rowSource) write(file *os.File) error {
+	writer := csv.NewWriter(file)
+	header := make([]string, len(source.columns))
+	for i, column := range source.columns {
+		header[i] = column.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(source.columns))
+	for rowIndex := 0; ; rowIndex++ {
+		row, err := source.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(row) != len(source.columns) {
+			return fmt.Errorf("row %d has %d values, expected %d", rowIndex, len(row), len(source.columns))
+		}
+		for i, value := range row {
+			field, err := rowSourceField(value)
+			if err != nil {
+				return fmt.Errorf("row %d, column %s: %w", rowIndex, source.columns[i].Name, err)
+			}
+			record[i] = field
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// rowSourceField formats a value of a row source as a CSV field.
+func rowSourceField(value any) (string, error) {
+	if timestamp, ok := value.(time.Time); ok {
+		return timestamp.UTC().Format("2006-01-02 15:04:05.999999"), nil
+	}
+	if value == nil {
+		return "", nil
+	}
+	dataType, err := spilledType(value)
+	if err != nil {
+		return "", err
+	}
+	return spilledField(value, dataType)
+}
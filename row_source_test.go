@@ -0,0 +1,94 @@
+package lbug
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sliceSource returns a next function of a row source reading the rows.
+func sliceSource(rows [][]any) func() ([]any, error) {
+	return func() ([]any, error) {
+		if len(rows) == 0 {
+			return nil, io.EOF
+		}
+		row := rows[0]
+		rows = rows[1:]
+		return row, nil
+	}
+}
+
+func TestSpoolRowSources(t *testing.T) {
+	conn := &Connection{}
+	columns := []ColumnSpec{{Name: "user", Type: "STRING"}, {Name: "at", Type: "TIMESTAMP"}, {Name: "n", Type: "INT64"}}
+	at := time.Date(2024, 2, 29, 12, 34, 56, 789000000, time.UTC)
+	assert.Nil(t, conn.RegisterRowSource("events", columns, sliceSource([][]any{{"Alice", at, 1}, {"Bob", nil, int64(2)}})))
+	assert.ErrorContains(t, conn.RegisterRowSource("events", columns, sliceSource(nil)), "already registered")
+
+	query, files, err := conn.spoolRowSources("LOAD FROM go_source('events') RETURN *")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+	defer os.Remove(files[0])
+	assert.Equal(t, "LOAD WITH HEADERS (`user` STRING, `at` TIMESTAMP, `n` INT64) FROM '"+files[0]+"' (HEADER=true) RETURN *", query)
+	contents, err := os.ReadFile(files[0])
+	assert.Nil(t, err)
+	assert.Equal(t, "user,at,n\nAlice,2024-02-29 12:34:56.789,1\nBob,,2\n", string(contents))
+
+	// The source is read by a single query.
+	_, files, err = conn.spoolRowSources("LOAD FROM go_source('events') RETURN *")
+	assert.Empty(t, files)
+	var lbugErr *Error
+	assert.True(t, errors.As(err, &lbugErr))
+	assert.ErrorContains(t, err, "no row source named events")
+
+	query, files, err = conn.spoolRowSources("RETURN 1")
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+	assert.Equal(t, "RETURN 1", query)
+}
+
+func TestSpoolRowSourcesErrors(t *testing.T) {
+	conn := &Connection{}
+	boom := errors.New("boom")
+	rows := sliceSource([][]any{{int64(1)}})
+	assert.Nil(t, conn.RegisterRowSource("failing", []ColumnSpec{{Name: "id", Type: "INT64"}}, func() ([]any, error) {
+		row, err := rows()
+		if errors.Is(err, io.EOF) {
+			return nil, boom
+		}
+		return row, err
+	}))
+	_, files, err := conn.spoolRowSources("LOAD FROM go_source('failing') RETURN *")
+	for _, file := range files {
+		os.Remove(file)
+	}
+	assert.ErrorIs(t, err, boom)
+	assert.ErrorContains(t, err, "failed to read row source failing")
+
+	assert.Nil(t, conn.RegisterRowSource("short", []ColumnSpec{{Name: "a", Type: "INT64"}, {Name: "b", Type: "INT64"}},
+		sliceSource([][]any{{int64(1)}})))
+	_, files, err = conn.spoolRowSources("LOAD FROM go_source('short') RETURN *")
+	for _, file := range files {
+		os.Remove(file)
+	}
+	assert.ErrorContains(t, err, "row 0 has 1 values, expected 2")
+
+	assert.ErrorContains(t, conn.RegisterRowSource("empty", nil, sliceSource(nil)), "at least one column")
+}
+
+func TestRowSourceJoin(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	events := [][]any{{"Alice", "login"}, {"Bob", "logout"}, {"Nobody", "login"}}
+	assert.Nil(t, conn.RegisterRowSource("events", []ColumnSpec{{Name: "user", Type: "STRING"}, {Name: "kind", Type: "STRING"}},
+		sliceSource(events)))
+	rows, err := queryRows(conn, "LOAD FROM go_source('events') WITH * MATCH (p:person {fName: user}) RETURN p.fName, kind ORDER BY p.fName")
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{
+		{"p.fName": "Alice", "kind": "login"},
+		{"p.fName": "Bob", "kind": "logout"},
+	}, rows)
+}
@@ -0,0 +1,134 @@
+package lbug
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// SaveOptions configures Database.SaveTo.
+type SaveOptions struct {
+	// Overwrite removes the database at the target path if it exists. If it
+	// is false, SaveTo fails when the target path exists.
+	Overwrite bool
+	// BatchSize is the number of rows inserted per statement, as in
+	// CopyTablesOptions.
+	BatchSize int
+}
+
+// SaveTo copies all node and relationship tables of the database to a new
+// on-disk database at the given path and returns the new database, e.g. to
+// persist a graph built in an in-memory database. The tables are copied as by
+// CopyTables, so tables with SERIAL columns are not supported. After the copy,
+// the number of rows of every table of the new database is checked against
+// the source. If SaveTo fails, the new database is closed and removed.
+//
+// If the path exists, SaveTo returns an error matching os.ErrExist unless
+// SaveOptions.Overwrite is set, in which case the existing database is
+// removed first. A database that is open in the process is never removed.
+func (db *Database) SaveTo(path string, systemConfig SystemConfig, opts SaveOptions) (*Database, error) {
+	if systemConfig.ReadOnly {
+		return nil, fmt.Errorf("failed to save database to %s: the system configuration is read-only", path)
+	}
+	if isInMemoryPath(path) {
+		return nil, fmt.Errorf("failed to save database to %s: the target must be an on-disk database", path)
+	}
+	canonicalPath, err := canonicalDatabasePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save database to %s: %w", path, err)
+	}
+	openDatabases.Lock()
+	_, isOpen := openDatabases.byPath[canonicalPath]
+	openDatabases.Unlock()
+	if isOpen {
+		return nil, fmt.Errorf("failed to save database to %s: %w", path, ErrAlreadyOpen)
+	}
+	if _, err := os.Stat(path); err == nil {
+		if !opts.Overwrite {
+			return nil, fmt.Errorf("failed to save database to %s: %w", path, os.ErrExist)
+		}
+		if err := removeDatabaseFiles(path); err != nil {
+			return nil, fmt.Errorf("failed to remove the database at %s: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to save database to %s: %w", path, err)
+	}
+
+	target, err := OpenDatabase(path, systemConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.copyTo(target, opts); err != nil {
+		target.Close()
+		removeDatabaseFiles(path)
+		return nil, fmt.Errorf("failed to save database to %s: %w", path, err)
+	}
+	return target, nil
+}
+
+// copyTo copies all tables of the database to the target database and checks
+// the numbers of rows of the copies.
+func (db *Database) copyTo(target *Database, opts SaveOptions) error {
+	src, err := OpenConnection(db)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := OpenConnection(target)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	if _, err := CopyTables(src, dst, nil, CopyTablesOptions{BatchSize: opts.BatchSize}); err != nil {
+		return err
+	}
+	tables, err := src.catalogTables()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		query := "MATCH (n:" + QuoteIdentifier(name) + ") RETURN count(n);"
+		if tables[name] {
+			query = "MATCH ()-[r:" + QuoteIdentifier(name) + "]->() RETURN count(r);"
+		}
+		srcCount, err := queryCount(src, query)
+		if err != nil {
+			return err
+		}
+		dstCount, err := queryCount(dst, query)
+		if err != nil {
+			return err
+		}
+		if srcCount != dstCount {
+			return fmt.Errorf("table %s has %d rows in the copy, expected %d", name, dstCount, srcCount)
+		}
+	}
+	return nil
+}
+
+// queryCount runs a count query and returns the count.
+func queryCount(conn *Connection, query string) (uint64, error) {
+	result, err := conn.Query(query)
+	if err != nil {
+		return 0, err
+	}
+	defer result.Close()
+	return readCount(result)
+}
+
+// removeDatabaseFiles removes an on-disk database and its write-ahead log.
+func removeDatabaseFiles(path string) error {
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	if err := os.Remove(path + ".wal"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package lbug
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// saveToTypesSchema is a node table with a column of every logical type
+// supported by CopyTables.
+const saveToTypesSchema = `CREATE NODE TABLE everything(
+	id INT64, b BOOL, i8 INT8, i16 INT16, i32 INT32, u8 UINT8, u16 UINT16, u32 UINT32, u64 UINT64,
+	i128 INT128, f FLOAT, d DOUBLE, dec DECIMAL(18, 2), s STRING, dt DATE, ts TIMESTAMP, iv INTERVAL,
+	id2 UUID, bl BLOB, list INT64[], arr DOUBLE[3], st STRUCT(a INT64, b STRING), m MAP(STRING, INT64),
+	PRIMARY KEY(id));`
+
+func TestSaveTo(t *testing.T) {
+	db, err := OpenInMemoryDatabase(SystemConfigEmbeddedTest())
+	assert.Nil(t, err)
+	defer db.Close()
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	mustRun(t, conn, saveToTypesSchema)
+	mustRun(t, conn, `CREATE (:everything {id: 1, b: true, i8: -8, i16: -16, i32: -32, u8: 8, u16: 16, u32: 32, u64: 64,
+		i128: CAST('170141183460469231731687303715884105727' AS INT128), f: 1.5, d: 2.25, dec: 12.34, s: 'text',
+		dt: date('2024-02-29'), ts: timestamp('2024-02-29 12:34:56.789'), iv: interval('1 day 2 hours'),
+		id2: uuid('a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11'), bl: BLOB('\\xDE\\xAD'), list: [1, 2, 3], arr: [1.0, 2.0, 3.0],
+		st: {a: 1, b: 'x'}, m: map(['k'], [1])});`)
+	mustRun(t, conn, "CREATE (:everything {id: 2, list: []});")
+	mustRun(t, conn, "CREATE REL TABLE linked(FROM everything TO everything, weight DOUBLE);")
+	mustRun(t, conn, "MATCH (a:everything {id: 1}), (b:everything {id: 2}) CREATE (a)-[:linked {weight: 0.5}]->(b);")
+
+	path := filepath.Join(t.TempDir(), "saved")
+	saved, err := db.SaveTo(path, SystemConfigEmbeddedTest(), SaveOptions{})
+	assert.Nil(t, err)
+	savedConn, err := OpenConnection(saved)
+	assert.Nil(t, err)
+
+	const query = "MATCH (n:everything) RETURN n.* ORDER BY n.id;"
+	expected, err := queryRows(conn, query)
+	assert.Nil(t, err)
+	actual, err := queryRows(savedConn, query)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+	rels, err := queryRows(savedConn, "MATCH (a)-[r:linked]->(b) RETURN a.id, b.id, r.weight;")
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{{"a.id": int64(1), "b.id": int64(2), "r.weight": 0.5}}, rels)
+	savedConn.Close()
+	saved.Close()
+
+	// The saved database persists.
+	reopened, err := OpenDatabase(path, SystemConfigEmbeddedTest())
+	assert.Nil(t, err)
+	defer reopened.Close()
+	reopenedConn, err := OpenConnection(reopened)
+	assert.Nil(t, err)
+	defer reopenedConn.Close()
+	actual, err = queryRows(reopenedConn, query)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestSaveToExistingTarget(t *testing.T) {
+	db, err := OpenInMemoryDatabase(SystemConfigEmbeddedTest())
+	assert.Nil(t, err)
+	defer db.Close()
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	mustRun(t, conn, "CREATE NODE TABLE person(name STRING, PRIMARY KEY(name));")
+	mustRun(t, conn, "CREATE (:person {name: 'Alice'});")
+
+	path := filepath.Join(t.TempDir(), "saved")
+	assert.Nil(t, os.WriteFile(path, []byte("not a database"), 0o644))
+	_, err = db.SaveTo(path, SystemConfigEmbeddedTest(), SaveOptions{})
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	saved, err := db.SaveTo(path, SystemConfigEmbeddedTest(), SaveOptions{Overwrite: true})
+	assert.Nil(t, err)
+	// A database that is open is not overwritten.
+	_, err = db.SaveTo(path, SystemConfigEmbeddedTest(), SaveOptions{Overwrite: true})
+	assert.ErrorIs(t, err, ErrAlreadyOpen)
+	saved.Close()
+}
+
+func TestSaveToRemovesTargetOnFailure(t *testing.T) {
+	db, err := OpenInMemoryDatabase(SystemConfigEmbeddedTest())
+	assert.Nil(t, err)
+	defer db.Close()
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	mustRun(t, conn, "CREATE NODE TABLE counter(id SERIAL, PRIMARY KEY(id));")
+
+	path := filepath.Join(t.TempDir(), "saved")
+	_, err = db.SaveTo(path, SystemConfigEmbeddedTest(), SaveOptions{})
+	assert.ErrorContains(t, err, "SERIAL")
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,139 @@
+package lbug
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// Scan reads the next row of the QueryResult into the values pointed to by
+// dest, one per column, converting the values of the columns as
+// CollectWithOptions does for the fields of a struct, e.g. a NODE, REL or
+// STRUCT value into a pointer to a struct. A nil entry of dest skips its
+// column. Scan returns an error if there is no next row; it is typically
+// called in a loop on HasNext:
+//
+//	for result.HasNext() {
+//		var name string
+//		var age int64
+//		if err := result.Scan(&name, &age); err != nil {
+//			return err
+//		}
+//	}
+func (queryResult *QueryResult) Scan(dest ...any) error {
+	if columns := queryResult.GetNumberOfColumns(); uint64(len(dest)) != columns {
+		return fmt.Errorf("cannot scan %d columns into %d values", columns, len(dest))
+	}
+	targets := make([]reflect.Value, len(dest))
+	columns := make([]uint64, 0, len(dest))
+	for i, d := range dest {
+		if d == nil {
+			continue
+		}
+		target := reflect.ValueOf(d)
+		if target.Kind() != reflect.Pointer || target.IsNil() {
+			return fmt.Errorf("cannot scan column %d into %T: not a non-nil pointer", i, d)
+		}
+		targets[i] = target.Elem()
+		columns = append(columns, uint64(i))
+	}
+	values, err := queryResult.scanNext(columns)
+	if err != nil {
+		return err
+	}
+	for col, target := range targets {
+		if !target.IsValid() {
+			continue
+		}
+		if err := assignValue(target, values[col], CollectOptions{}); err != nil {
+			return conversionError(uint64(col), err)
+		}
+	}
+	return nil
+}
+
+// ScanStruct reads the next row of the QueryResult into the struct pointed to
+// by dest, see ScanStructWithOptions.
+func (queryResult *QueryResult) ScanStruct(dest any) error {
+	return queryResult.ScanStructWithOptions(dest, CollectOptions{})
+}
+
+// ScanStructWithOptions reads the next row of the QueryResult into the struct
+// pointed to by dest, decoding every column into the field of the same name,
+// or whose tag is the name of the column, as CollectWithOptions does. The
+// fields without a column keep their values, and the other columns are not
+// converted. The fields of the struct type are matched with the columns once
+// per result and options. ScanStructWithOptions returns a *CollectTypeError
+// if fields cannot hold the values of their columns, and an error if there is
+// no next row.
+func (queryResult *QueryResult) ScanStructWithOptions(dest any, opts CollectOptions) error {
+	target := reflect.ValueOf(dest)
+	if target.Kind() != reflect.Pointer || target.IsNil() || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot scan a row into %T: not a non-nil pointer to a struct", dest)
+	}
+	target = target.Elem()
+	plan, err := queryResult.scanPlanOf(target.Type(), opts)
+	if err != nil {
+		return err
+	}
+	values, err := queryResult.scanNext(plan.columns)
+	if err != nil {
+		return err
+	}
+	for col, field := range plan.fields {
+		if field == nil {
+			continue
+		}
+		if err := assignValue(target.FieldByIndex(field), values[col], opts); err != nil {
+			return conversionError(uint64(col), err)
+		}
+	}
+	return nil
+}
+
+// scanPlan is the matching of the fields of a struct type with the columns of
+// a result by ScanStructWithOptions, kept for the next rows.
+type scanPlan struct {
+	structType reflect.Type
+	weak       bool
+	tags       []string
+	fields     [][]int
+	columns    []uint64
+}
+
+// scanPlanOf returns the matching of the fields of the struct type with the
+// columns of the result, reusing the one of the previous call if it was made
+// with the same type and options.
+func (queryResult *QueryResult) scanPlanOf(structType reflect.Type, opts CollectOptions) (*scanPlan, error) {
+	plan := queryResult.scanPlan
+	if plan != nil && plan.structType == structType && plan.weak == opts.WeakConversion && slices.Equal(plan.tags, opts.tags()) {
+		return plan, nil
+	}
+	fields, err := collectFields(structType, queryResult.GetColumnNames(), queryResult.GetColumnDataTypes(), opts)
+	if err != nil {
+		return nil, err
+	}
+	plan = &scanPlan{
+		structType: structType,
+		weak:       opts.WeakConversion,
+		tags:       slices.Clone(opts.tags()),
+		fields:     fields,
+		columns:    projectedColumns(fields),
+	}
+	queryResult.scanPlan = plan
+	return plan, nil
+}
+
+// scanNext returns the values of the columns at the indexes of the next row,
+// see FlatTuple.getColumns, or an error if there is none.
+func (queryResult *QueryResult) scanNext(columns []uint64) ([]any, error) {
+	if !queryResult.HasNext() {
+		return nil, &Error{Op: OpIterate, Message: "no more rows to scan"}
+	}
+	tuple, err := queryResult.Next()
+	if err != nil {
+		return nil, err
+	}
+	defer tuple.Close()
+	return tuple.getColumns(columns)
+}
@@ -0,0 +1,161 @@
+package lbug
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type scannedPerson struct {
+	ID    InternalID `lbug:"_id"`
+	Label string     `lbug:"_label"`
+	Name  string     `lbug:"name"`
+	Age   *int64     `lbug:"age"`
+}
+
+type scannedKnows struct {
+	Source      InternalID `lbug:"_src"`
+	Destination InternalID `lbug:"_dst"`
+	Since       int64      `lbug:"since"`
+}
+
+func TestAssignNodeAndRelationship(t *testing.T) {
+	var person scannedPerson
+	node := Node{ID: InternalID{TableID: 1, Offset: 2}, Label: "person", Properties: map[string]any{"name": "Alice", "age": int64(30)}}
+	assert.Nil(t, assignValue(reflect.ValueOf(&person).Elem(), node, CollectOptions{}))
+	assert.Equal(t, node.ID, person.ID)
+	assert.Equal(t, "person", person.Label)
+	assert.Equal(t, "Alice", person.Name)
+	if assert.NotNil(t, person.Age) {
+		assert.Equal(t, int64(30), *person.Age)
+	}
+	var pointer *scannedPerson
+	assert.Nil(t, assignValue(reflect.ValueOf(&pointer).Elem(), node, CollectOptions{}))
+	assert.Equal(t, &person, pointer)
+
+	var knows scannedKnows
+	rel := Relationship{SourceID: InternalID{Offset: 1}, DestinationID: InternalID{Offset: 2}, Label: "knows", Properties: map[string]any{"since": int64(2020)}}
+	assert.Nil(t, assignValue(reflect.ValueOf(&knows).Elem(), rel, CollectOptions{}))
+	assert.Equal(t, scannedKnows{Source: rel.SourceID, Destination: rel.DestinationID, Since: 2020}, knows)
+
+	assert.True(t, canHold(reflect.TypeOf(person), DataType{Name: "NODE"}, false))
+	assert.True(t, canHold(reflect.TypeOf(knows), DataType{Name: "REL"}, false))
+	assert.False(t, canHold(reflect.TypeOf(""), DataType{Name: "REL"}, false))
+}
+
+func TestScan(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE person(name STRING, age INT64, PRIMARY KEY(name));")
+	mustRun(t, conn, "CREATE REL TABLE knows(FROM person TO person, since INT64);")
+	mustRun(t, conn, "CREATE (:person {name: 'Alice', age: 30})-[:knows {since: 2020}]->(:person {name: 'Bob'});")
+
+	result, err := conn.Query("MATCH (a:person)-[k:knows]->(b:person) RETURN a.name, b.age, a, k, {n: b.name} AS s;")
+	assert.Nil(t, err)
+	defer result.Close()
+	var name string
+	var age *int64
+	var person scannedPerson
+	var knows scannedKnows
+	var s struct{ N string }
+	assert.Nil(t, result.Scan(&name, &age, &person, &knows, &s))
+	assert.Equal(t, "Alice", name)
+	assert.Nil(t, age)
+	assert.Equal(t, "Alice", person.Name)
+	assert.Equal(t, "person", person.Label)
+	assert.Equal(t, int64(2020), knows.Since)
+	assert.Equal(t, person.ID, knows.Source)
+	assert.Equal(t, "Bob", s.N)
+	assert.ErrorContains(t, result.Scan(&name, &age, nil, nil, nil), "no more rows to scan")
+
+	result, err = conn.Query("MATCH (a:person) RETURN a.name, a.age ORDER BY a.name;")
+	assert.Nil(t, err)
+	defer result.Close()
+	assert.ErrorContains(t, result.Scan(&name), "cannot scan 2 columns into 1 values")
+	assert.ErrorContains(t, result.Scan(name, nil), "not a non-nil pointer")
+	var wrong bool
+	var lbugErr *Error
+	err = result.Scan(nil, &wrong)
+	assert.ErrorAs(t, err, &lbugErr)
+	assert.Equal(t, uint64(1), lbugErr.Column)
+	assert.ErrorContains(t, err, "cannot assign int64 to bool")
+}
+
+func TestScanStruct(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE person(name STRING, age INT64, PRIMARY KEY(name));")
+	mustRun(t, conn, "CREATE (:person {name: 'Alice', age: 30}), (:person {name: 'Bob'});")
+
+	type row struct {
+		Name   string        `lbug:"p.name"`
+		Age    *int64        `lbug:"p.age"`
+		Person scannedPerson `lbug:"p"`
+		Other  string
+	}
+	result, err := conn.Query("MATCH (p:person) RETURN p.name, p.age, p ORDER BY p.name;")
+	assert.Nil(t, err)
+	defer result.Close()
+	var rows []row
+	for result.HasNext() {
+		r := row{Other: "kept"}
+		if !assert.Nil(t, result.ScanStruct(&r)) {
+			break
+		}
+		rows = append(rows, r)
+	}
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, "Alice", rows[0].Name)
+		assert.Equal(t, int64(30), *rows[0].Age)
+		assert.Equal(t, "Alice", rows[0].Person.Name)
+		assert.Equal(t, "kept", rows[0].Other)
+		assert.Equal(t, "Bob", rows[1].Person.Name)
+		assert.Nil(t, rows[1].Age)
+	}
+	assert.ErrorContains(t, result.ScanStruct(&row{}), "no more rows to scan")
+	assert.ErrorContains(t, result.ScanStruct(row{}), "not a non-nil pointer to a struct")
+
+	result, err = conn.Query("MATCH (p:person) RETURN p.name;")
+	assert.Nil(t, err)
+	defer result.Close()
+	var mismatch struct {
+		Name int64 `lbug:"p.name"`
+	}
+	var typeErr *CollectTypeError
+	assert.ErrorAs(t, result.ScanStruct(&mismatch), &typeErr)
+	// The row is not consumed by a type error.
+	assert.True(t, result.HasNext())
+}
+
+func TestScanStructJSONTags(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE person(name STRING, level STRING, PRIMARY KEY(name));")
+	mustRun(t, conn, "CREATE (:person {name: 'Alice', level: 'high'});")
+
+	type person struct {
+		Name  string `json:"name"`
+		Level level  `json:"level"`
+	}
+	type row struct {
+		Person person `json:"p"`
+		Level  *level `json:"p.level"`
+		Name   string `json:"-"`
+	}
+	result, err := conn.Query("MATCH (p:person) RETURN p, p.level, p.name AS Name;")
+	assert.Nil(t, err)
+	defer result.Close()
+	r := row{Name: "kept"}
+	assert.Nil(t, result.ScanStruct(&r))
+	assert.Equal(t, person{Name: "Alice", Level: 2}, r.Person)
+	if assert.NotNil(t, r.Level) {
+		assert.Equal(t, level(2), *r.Level)
+	}
+	assert.Equal(t, "kept", r.Name)
+
+	// Without the json tag, only the field of the same name is decoded.
+	result, err = conn.Query("MATCH (p:person) RETURN p, p.name AS Name;")
+	assert.Nil(t, err)
+	defer result.Close()
+	r = row{}
+	assert.Nil(t, result.ScanStructWithOptions(&r, CollectOptions{Tags: []string{"lbug"}}))
+	assert.Equal(t, row{Name: "Alice"}, r)
+}
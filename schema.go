@@ -0,0 +1,143 @@
+package lbug
+
+// #include "lbug.h"
+import "C"
+
+import "strings"
+
+// Schema describes the node and relationship tables of the catalog, as
+// returned by Connection.GetSchema.
+type Schema struct {
+	// NodeTables are the node tables, sorted by name.
+	NodeTables []TableSchema
+	// RelTables are the relationship tables, sorted by name.
+	RelTables []TableSchema
+}
+
+// TableSchema describes a node or relationship table.
+type TableSchema struct {
+	Name string
+	// Columns are the columns of the table, in the order of its definition.
+	// The columns of relationship tables do not include the implicit
+	// source and destination columns.
+	Columns []ColumnSchema
+	// PrimaryKey is the name of the primary key column of a node table, and
+	// empty for relationship tables.
+	PrimaryKey string
+	// Connections are the FROM/TO pairs of node tables of a relationship
+	// table.
+	Connections []RelConnection
+	// Indexes are the indexes of the table, see Connection.ListIndexes.
+	Indexes []Index
+}
+
+// ColumnSchema describes a column of a table.
+type ColumnSchema struct {
+	Name string
+	// Type is the Cypher type of the column as in its definition, e.g.
+	// "STRING[]" or "DECIMAL(18, 3)".
+	Type string
+	// DataType is the logical type of the column, e.g. "LIST" for STRING[],
+	// as for the columns of a QueryResult. Its TypeID is -1 if the type is
+	// unknown to the bindings.
+	DataType DataType
+	// PrimaryKey is true for the primary key of a node table.
+	PrimaryKey bool
+	// Default is the DEFAULT expression of the column, or empty if the column
+	// defaults to NULL.
+	Default string
+}
+
+// RelConnection is a FROM/TO pair of node tables connected by a relationship
+// table.
+type RelConnection struct {
+	From string
+	To   string
+}
+
+// Table returns the node or relationship table with the name, compared case
+// insensitively as by the engine, or false if there is none.
+func (schema *Schema) Table(name string) (TableSchema, bool) {
+	for _, tables := range [][]TableSchema{schema.NodeTables, schema.RelTables} {
+		for _, table := range tables {
+			if strings.EqualFold(table.Name, name) {
+				return table, true
+			}
+		}
+	}
+	return TableSchema{}, false
+}
+
+// GetSchema reads the node and relationship tables of the catalog, with their
+// columns, connections and indexes. Other kinds of tables are omitted. The
+// schema is read with show_tables, table_info, show_connection and
+// show_indexes, in as many queries as there are tables, and is not cached.
+func (conn *Connection) GetSchema() (*Schema, error) {
+	tables, err := listTables(conn)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := conn.ListIndexes()
+	if err != nil {
+		return nil, err
+	}
+	tableIndexes := make(map[string][]Index)
+	for _, index := range indexes {
+		tableIndexes[index.Table] = append(tableIndexes[index.Table], index)
+	}
+	schema := &Schema{}
+	for _, name := range sortedTableNames(tables) {
+		described, err := describeTable(conn, name, tables[name])
+		if err != nil {
+			return nil, err
+		}
+		table := TableSchema{Name: name, Indexes: tableIndexes[name]}
+		for _, column := range described.columns {
+			table.Columns = append(table.Columns, ColumnSchema{
+				Name:       column.name,
+				Type:       column.dataType,
+				DataType:   dataTypeOfName(column.dataType),
+				PrimaryKey: column.primaryKey,
+				Default:    column.defaultExpression,
+			})
+			if column.primaryKey {
+				table.PrimaryKey = column.name
+			}
+		}
+		for _, connection := range described.connections {
+			table.Connections = append(table.Connections, RelConnection{From: connection.from, To: connection.to})
+		}
+		if described.isRel {
+			schema.RelTables = append(schema.RelTables, table)
+		} else {
+			schema.NodeTables = append(schema.NodeTables, table)
+		}
+	}
+	return schema, nil
+}
+
+// dataTypeOfName returns the DataType of a Cypher type as written in DDL,
+// looked up in dataTypeNames so that the types added to the C API are known
+// as soon as they are named there.
+func dataTypeOfName(name string) DataType {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	// The types of lists end with [] and those of arrays with their size in
+	// brackets, whatever their element type, e.g. "STRUCT(a INT64)[]".
+	if strings.HasSuffix(name, "]") {
+		if open := strings.LastIndexByte(name, '['); open >= 0 {
+			if open+2 == len(name) {
+				return newDataType(C.LBUG_LIST)
+			}
+			return newDataType(C.LBUG_ARRAY)
+		}
+	}
+	if open := strings.IndexByte(name, '('); open >= 0 {
+		name = strings.TrimSpace(name[:open])
+	}
+	for id, typeName := range dataTypeNames {
+		if typeName == name {
+			return newDataType(id)
+		}
+	}
+	return DataType{TypeID: -1, Name: name}
+}
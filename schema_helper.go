@@ -0,0 +1,224 @@
+package lbug
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// tableColumn describes a column of a node or relationship table.
+type tableColumn struct {
+	name       string
+	dataType   string
+	primaryKey bool
+	// defaultExpression is the DEFAULT expression of the column, or empty if
+	// the column defaults to NULL.
+	defaultExpression string
+}
+
+// relConnection describes a FROM/TO pair of node tables connected by a
+// relationship table.
+type relConnection struct {
+	from           string
+	to             string
+	fromPrimaryKey string
+	toPrimaryKey   string
+}
+
+// tableSchema describes a node or relationship table in the catalog.
+type tableSchema struct {
+	name        string
+	isRel       bool
+	columns     []tableColumn
+	connections []relConnection
+}
+
+// primaryKey returns the primary key column of a node table.
+func (schema *tableSchema) primaryKey() (tableColumn, bool) {
+	for _, column := range schema.columns {
+		if column.primaryKey {
+			return column, true
+		}
+	}
+	return tableColumn{}, false
+}
+
+// column returns the column with the given name.
+func (schema *tableSchema) column(name string) (tableColumn, bool) {
+	for _, column := range schema.columns {
+		if column.name == name {
+			return column, true
+		}
+	}
+	return tableColumn{}, false
+}
+
+// QuoteIdentifier quotes an identifier with backticks so that it can be used
+// in a Cypher query regardless of reserved words and special characters.
+// Backticks in the identifier are escaped by doubling them.
+func QuoteIdentifier(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+}
+
+// quoteStringLiteral quotes a string as a single-quoted Cypher string literal.
+func quoteStringLiteral(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// optionLiteral renders the value of an option of a statement or procedure
+// as a Cypher literal. It returns an error for values of other types than
+// bool, integers, floats and strings, and for NaN and infinite floats, which
+// have no literal.
+func optionLiteral(value any) (string, error) {
+	switch v := value.(type) {
+	case bool, int, int64, int32, uint, uint64, uint32:
+		return fmt.Sprint(v), nil
+	case float64:
+		return floatLiteral(v, 64)
+	case float32:
+		return floatLiteral(float64(v), 32)
+	case string:
+		return quoteStringLiteral(v), nil
+	}
+	return "", fmt.Errorf("unsupported value of type %T", value)
+}
+
+// floatLiteral renders a finite float of the bit size as the shortest Cypher
+// literal that reads back as the same value.
+func floatLiteral(value float64, bitSize int) (string, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", fmt.Errorf("unsupported non-finite value %v", value)
+	}
+	// Cypher exponents take no plus sign.
+	return strings.Replace(strconv.FormatFloat(value, 'g', -1, bitSize), "e+", "e", 1), nil
+}
+
+// isOptionName returns true if the name of an option of a statement or
+// procedure matches [A-Za-z_][A-Za-z0-9_]*, so that it can be written in the
+// statement verbatim.
+func isOptionName(name string) bool {
+	for i, c := range name {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// queryRows runs the query and returns all rows as maps keyed by column name,
+// verbatim whatever the export options of the connection.
+func queryRows(conn *Connection, query string) ([]map[string]any, error) {
+	result, err := conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	result.exportOptions = ExportOptions{}
+	var rows []map[string]any
+	for result.HasNext() {
+		tuple, err := result.Next()
+		if err != nil {
+			tuple.Close()
+			return nil, err
+		}
+		row, err := tuple.GetAsMap()
+		tuple.Close()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// listTables returns the names of the node and relationship tables in the
+// catalog, with a flag telling whether each table is a relationship table.
+// Other kinds of tables are omitted.
+func listTables(conn *Connection) (map[string]bool, error) {
+	rows, err := queryRows(conn, "CALL show_tables() RETURN *;")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	tables := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		name, _ := row["name"].(string)
+		tableType, _ := row["type"].(string)
+		switch strings.ToUpper(tableType) {
+		case "NODE":
+			tables[name] = false
+		case "REL":
+			tables[name] = true
+		}
+	}
+	return tables, nil
+}
+
+// describeTable returns the schema of the node or relationship table with the
+// given name.
+func describeTable(conn *Connection, name string, isRel bool) (*tableSchema, error) {
+	schema := &tableSchema{name: name, isRel: isRel}
+	rows, err := queryRows(conn, fmt.Sprintf("CALL table_info(%s) RETURN *;", quoteStringLiteral(name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table %s: %w", name, err)
+	}
+	for _, row := range rows {
+		column := tableColumn{}
+		column.name, _ = row["name"].(string)
+		column.dataType, _ = row["type"].(string)
+		column.primaryKey, _ = row["primary key"].(bool)
+		column.defaultExpression, _ = row["default expression"].(string)
+		// The values of SERIAL columns come from their implicit sequence.
+		if strings.EqualFold(column.defaultExpression, "NULL") || column.dataType == "SERIAL" {
+			column.defaultExpression = ""
+		}
+		schema.columns = append(schema.columns, column)
+	}
+	if !isRel {
+		return schema, nil
+	}
+	rows, err = queryRows(conn, fmt.Sprintf("CALL show_connection(%s) RETURN *;", quoteStringLiteral(name)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe connections of table %s: %w", name, err)
+	}
+	for _, row := range rows {
+		connection := relConnection{}
+		connection.from, _ = row["source table name"].(string)
+		connection.to, _ = row["destination table name"].(string)
+		connection.fromPrimaryKey, _ = row["source table primary key"].(string)
+		connection.toPrimaryKey, _ = row["destination table primary key"].(string)
+		schema.connections = append(schema.connections, connection)
+	}
+	return schema, nil
+}
+
+// createTableStatement returns the DDL statement that creates the table.
+func (schema *tableSchema) createTableStatement() string {
+	var builder strings.Builder
+	if schema.isRel {
+		builder.WriteString("CREATE REL TABLE ")
+	} else {
+		builder.WriteString("CREATE NODE TABLE ")
+	}
+	builder.WriteString(QuoteIdentifier(schema.name))
+	builder.WriteString("(")
+	var parts []string
+	for _, connection := range schema.connections {
+		parts = append(parts, fmt.Sprintf("FROM %s TO %s", QuoteIdentifier(connection.from), QuoteIdentifier(connection.to)))
+	}
+	for _, column := range schema.columns {
+		part := QuoteIdentifier(column.name) + " " + column.dataType
+		if column.defaultExpression != "" {
+			part += " DEFAULT " + column.defaultExpression
+		}
+		parts = append(parts, part)
+	}
+	if primaryKey, ok := schema.primaryKey(); ok {
+		parts = append(parts, fmt.Sprintf("PRIMARY KEY(%s)", QuoteIdentifier(primaryKey.name)))
+	}
+	builder.WriteString(strings.Join(parts, ", "))
+	builder.WriteString(");")
+	return builder.String()
+}
@@ -0,0 +1,58 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataTypeOfName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"INT64":                  "INT64",
+		"string":                 "STRING",
+		"STRING[]":               "LIST",
+		"INT64[3]":               "ARRAY",
+		"DECIMAL(18, 3)":         "DECIMAL",
+		"STRUCT(a INT64[])":      "STRUCT",
+		"STRUCT(a INT64)[]":      "LIST",
+		"MAP(STRING, INT64)":     "MAP",
+		"UNION(a INT64, b DATE)": "UNION",
+		"SERIAL":                 "SERIAL",
+	} {
+		dataType := dataTypeOfName(name)
+		assert.Equal(t, expected, dataType.Name, name)
+		assert.True(t, dataType.isKnown(), name)
+	}
+	assert.Equal(t, DataType{TypeID: -1, Name: "GEOMETRY"}, dataTypeOfName("GEOMETRY"))
+}
+
+func TestGetSchema(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE person(name STRING, tags STRING[], age INT64 DEFAULT 0, PRIMARY KEY(name));")
+	mustRun(t, conn, "CREATE NODE TABLE city(id SERIAL, PRIMARY KEY(id));")
+	mustRun(t, conn, "CREATE REL TABLE visits(FROM person TO city, FROM person TO person, since DATE);")
+
+	schema, err := conn.GetSchema()
+	assert.Nil(t, err)
+	if assert.Len(t, schema.NodeTables, 2) && assert.Len(t, schema.RelTables, 1) {
+		assert.Equal(t, "city", schema.NodeTables[0].Name)
+		person := schema.NodeTables[1]
+		assert.Equal(t, "name", person.PrimaryKey)
+		assert.Equal(t, []ColumnSchema{
+			{Name: "name", Type: "STRING", DataType: dataTypeOfName("STRING"), PrimaryKey: true},
+			{Name: "tags", Type: "STRING[]", DataType: dataTypeOfName("STRING[]")},
+			{Name: "age", Type: "INT64", DataType: dataTypeOfName("INT64"), Default: "0"},
+		}, person.Columns)
+		visits := schema.RelTables[0]
+		assert.Empty(t, visits.PrimaryKey)
+		assert.ElementsMatch(t, []RelConnection{{From: "person", To: "city"}, {From: "person", To: "person"}}, visits.Connections)
+		if assert.Len(t, visits.Columns, 1) {
+			assert.Equal(t, "DATE", visits.Columns[0].DataType.Name)
+		}
+	}
+	table, ok := schema.Table("Person")
+	assert.True(t, ok)
+	assert.Equal(t, "person", table.Name)
+	_, ok = schema.Table("missing")
+	assert.False(t, ok)
+}
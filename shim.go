@@ -0,0 +1,120 @@
+package lbug
+
+// #include "lbug_shim.h"
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// shimVersion is the version of the layout of the packed buffers of the C
+// shim the Go side is written against, see lbug_shim.h.
+const shimVersion = 1
+
+// checkShimBuffer returns an error if the packed buffer was not written by the
+// version of the shim of the Go side, or is not of the kind.
+func checkShimBuffer(header *C.lbug_go_shim_header, kind C.lbug_go_shim_kind) error {
+	if header.version != shimVersion {
+		return fmt.Errorf("C shim buffer has layout version %d, expected %d", header.version, shimVersion)
+	}
+	if header.kind != C.uint32_t(kind) {
+		return fmt.Errorf("C shim buffer has kind %d, expected %d", header.kind, kind)
+	}
+	return nil
+}
+
+// rowValues are the values of a tuple fetched with one call into the shim.
+// The values are borrowed from the tuple, which must outlive them, and must be
+// released with free.
+type rowValues struct {
+	buffer *C.lbug_go_row_values
+	values []C.lbug_value
+	nulls  []C.uint8_t
+}
+
+// fetchRowValues fetches the values of the columns of the tuple.
+func fetchRowValues(tuple *C.lbug_flat_tuple, numColumns uint64) (*rowValues, error) {
+	var buffer *C.lbug_go_row_values
+	if C.lbug_go_fetch_row_values(tuple, C.uint64_t(numColumns), &buffer) != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get the values of the tuple")
+	}
+	if err := checkShimBuffer(&buffer.header, C.LBUG_GO_SHIM_ROW_VALUES); err != nil {
+		C.lbug_go_shim_free(unsafe.Pointer(buffer))
+		return nil, err
+	}
+	return &rowValues{
+		buffer: buffer,
+		values: unsafe.Slice(buffer.values, int(buffer.num_values)),
+		nulls:  unsafe.Slice(buffer.nulls, int(buffer.num_values)),
+	}, nil
+}
+
+// free destroys the values.
+func (row *rowValues) free() {
+	C.lbug_go_shim_free(unsafe.Pointer(row.buffer))
+}
+
+// fetchNullMask returns whether the values of the columns of the tuple are
+// NULL.
+func fetchNullMask(tuple *C.lbug_flat_tuple, numColumns uint64) ([]bool, error) {
+	var buffer *C.lbug_go_null_mask
+	if C.lbug_go_fetch_null_mask(tuple, C.uint64_t(numColumns), &buffer) != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get the null mask of the tuple")
+	}
+	defer C.lbug_go_shim_free(unsafe.Pointer(buffer))
+	if err := checkShimBuffer(&buffer.header, C.LBUG_GO_SHIM_NULL_MASK); err != nil {
+		return nil, err
+	}
+	words := unsafe.Slice(buffer.words, int((buffer.num_columns+63)/64))
+	return unpackNullMask(words, uint64(buffer.num_columns)), nil
+}
+
+// unpackNullMask returns the bits of a null mask of the shim as booleans.
+func unpackNullMask[W ~uint64](words []W, numColumns uint64) []bool {
+	nulls := make([]bool, numColumns)
+	for i := range nulls {
+		nulls[i] = words[i/64]&(1<<(i%64)) != 0
+	}
+	return nulls
+}
+
+// pathElements are the nodes and relationships of a RECURSIVE_REL value
+// fetched with one call into the shim. They are borrowed from the value, which
+// must outlive them, and must be released with free. If the path has more
+// elements than the limit, only numNodes and numRels are set.
+type pathElements struct {
+	buffer   *C.lbug_go_path_elements
+	numNodes uint64
+	numRels  uint64
+	nodes    []C.lbug_value
+	rels     []C.lbug_value
+}
+
+// fetchPathElements fetches the nodes and relationships of the path, unless
+// they are more than maxElements. Zero means no limit.
+func fetchPathElements(path *C.lbug_value, maxElements uint64) (*pathElements, error) {
+	var buffer *C.lbug_go_path_elements
+	if C.lbug_go_fetch_path_elements(path, C.uint64_t(maxElements), &buffer) != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get the elements of the path")
+	}
+	if err := checkShimBuffer(&buffer.header, C.LBUG_GO_SHIM_PATH_ELEMENTS); err != nil {
+		C.lbug_go_shim_free(unsafe.Pointer(buffer))
+		return nil, err
+	}
+	elements := &pathElements{
+		buffer:   buffer,
+		numNodes: uint64(buffer.num_nodes),
+		numRels:  uint64(buffer.num_rels),
+	}
+	if buffer.nodes != nil {
+		elements.nodes = unsafe.Slice(buffer.nodes, int(buffer.num_nodes))
+		elements.rels = unsafe.Slice(buffer.rels, int(buffer.num_rels))
+	}
+	return elements, nil
+}
+
+// free destroys the elements.
+func (elements *pathElements) free() {
+	C.lbug_go_shim_free(unsafe.Pointer(elements.buffer))
+}
@@ -0,0 +1,108 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnpackNullMask(t *testing.T) {
+	words := []uint64{1<<0 | 1<<63, 1 << 1}
+	nulls := unpackNullMask(words, 66)
+	assert.Len(t, nulls, 66)
+	for i, null := range nulls {
+		assert.Equal(t, i == 0 || i == 63 || i == 65, null, i)
+	}
+	assert.Len(t, unpackNullMask([]uint64{}, 0), 0)
+}
+
+// shimTypeQueries return a value of every logical type decoded by the
+// bindings, and a NULL, in one row.
+var shimTypeQueries = []string{
+	`MATCH (a:person) WHERE a.ID = 0 RETURN a.isStudent, a.age, a.eyeSight, a.fName, a.birthdate, a.registerTime,
+		a.lastJobDuration, a.workedHours, a.usedNames, a.courseScoresPerTerm, a, id(a), NULL AS none;`,
+	`RETURN CAST(170, "INT32"), CAST(888, "INT16"), CAST(8, "INT8"), CAST(18446744073709551610, "INT128"),
+		CAST(1, "UINT64"), CAST(2, "UINT32"), CAST(3, "UINT16"), CAST(4, "UINT8"), CAST(1.75, "FLOAT"),
+		BLOB('\\xAA\\xBB'), CAST('00000000-0000-0000-0000-000000000001', 'UUID'), CAST(1.5, 'DECIMAL(18, 1)'),
+		INTERVAL("1 month 2 days"), CAST([3, 4, 12, 11], 'INT64[4]'), {name: 'Alice', age: 30},
+		map(['a', 'b'], [1, 2]), union_value(k := 1), CAST(NULL, 'MAP(STRING, INT64)');`,
+	`RETURN CAST('2024-08-29 10:03:05.003', 'TIMESTAMP_MS'), CAST('2024-08-29 10:03:05', 'TIMESTAMP_SEC'),
+		CAST('2024-08-29 10:03:05.000000001', 'TIMESTAMP_NS'), CAST('2024-08-29 10:03:05+01', 'TIMESTAMP_TZ');`,
+	`MATCH (p:person)-[r:workAt]->(o:organisation) WHERE p.ID = 5 RETURN p, r, o;`,
+	`MATCH (a:person)-[e:knows*1..2]->(b:person) WHERE a.ID = 0 RETURN e, nodes(e), rels(e) LIMIT 3;`,
+}
+
+// TestShimRowValues compares the values of GetAsSlice, fetched with one call
+// into the C shim, with the values gotten one call per column by GetValue.
+func TestShimRowValues(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	for _, query := range shimTypeQueries {
+		res, err := conn.Query(query)
+		if !assert.Nil(t, err, query) {
+			continue
+		}
+		for res.HasNext() {
+			tuple, err := res.Next()
+			assert.Nil(t, err)
+			values, err := tuple.GetAsSlice()
+			assert.Nil(t, err, query)
+			nulls, err := tuple.Nulls()
+			assert.Nil(t, err)
+			assert.Len(t, values, int(res.GetNumberOfColumns()))
+			assert.Len(t, nulls, len(values))
+			for i := range values {
+				value, err := tuple.GetValue(uint64(i))
+				assert.Nil(t, err)
+				assert.Equal(t, value, values[i], "%s: column %d", query, i)
+				assert.Equal(t, value == nil, nulls[i], "%s: column %d", query, i)
+			}
+			tuple.Close()
+		}
+		res.Close()
+	}
+}
+
+// TestShimPathElements compares the elements of the paths fetched with one
+// call into the C shim with the lists of their nodes and relationships.
+func TestShimPathElements(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	res, err := conn.Query("MATCH (a:person)-[e:knows*1..3]->(b:person) WHERE a.ID = 0 RETURN e, nodes(e), rels(e);")
+	assert.Nil(t, err)
+	defer res.Close()
+	rows := 0
+	for res.HasNext() {
+		tuple, err := res.Next()
+		assert.Nil(t, err)
+		path, err := tuple.GetRecursiveRelationship(0)
+		assert.Nil(t, err)
+		nodes, err := tuple.GetValue(1)
+		assert.Nil(t, err)
+		rels, err := tuple.GetValue(2)
+		assert.Nil(t, err)
+		if assert.Len(t, nodes, len(path.Nodes)) && assert.Len(t, rels, len(path.Relationships)) {
+			for i, node := range nodes.([]any) {
+				assert.Equal(t, node, path.Nodes[i])
+			}
+			for i, rel := range rels.([]any) {
+				assert.Equal(t, rel, path.Relationships[i])
+			}
+		}
+		tuple.Close()
+		rows++
+	}
+	assert.Greater(t, rows, 0)
+
+	// A path over the limit is not fetched.
+	conn.SetMaxPathElements(2)
+	res, err = conn.Query("MATCH (a:person)-[e:knows*2..2]->(b:person) WHERE a.ID = 0 RETURN e LIMIT 1;")
+	assert.Nil(t, err)
+	defer res.Close()
+	tuple, err := res.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	_, err = tuple.GetRecursiveRelationship(0)
+	var lengthErr *PathLengthError
+	if assert.ErrorAs(t, err, &lengthErr) {
+		assert.Equal(t, uint64(2), lengthErr.Limit)
+	}
+}
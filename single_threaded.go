@@ -0,0 +1,94 @@
+package lbug
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ConnectionOptions configures a connection opened with
+// OpenConnectionWithOptions.
+type ConnectionOptions struct {
+	// SingleThreaded removes the locking of the connection and of the
+	// statements, results and tuples created from it, for applications using
+	// each connection from a single goroutine. The lock otherwise taken by
+	// every call, e.g. by each QueryResult.Next and FlatTuple.GetValue, is
+	// replaced by a no-op: the connection, its handles and CloseAll must not
+	// be used concurrently, and PrepareWithContext waits for the preparation
+	// to complete instead of abandoning it in the background when the context
+	// is done. Interrupt may still be called from any goroutine. The counters
+	// of Stats are shared with the other connections of the database and are
+	// still updated atomically.
+	//
+	// Built with the lbug_debug tag, the guards record the goroutine that
+	// first uses the connection and panic when another goroutine uses it, so
+	// that misuse is caught in development. The goroutines of DecodePipeline
+	// and CloseAll take the connection over while they use it.
+	SingleThreaded bool
+	// EnableProfilingLabels runs the calls to Query and Execute, and the
+	// calls into the C API that fetch their results, with the pprof labels
+	// ProfileLabelOperation and ProfileLabelQuery, so that the time spent
+	// in the engine shows up by operation and query in CPU profiles. The
+	// labels are set with pprof.Do on a context without labels, so the
+	// goroutine labels set by the caller are cleared while the call runs.
+	EnableProfilingLabels bool
+	// SampleCgoCalls records the wall time of every SampleCgoCalls-th of
+	// these calls into the histogram reported by Stats as CgoLatency, e.g.
+	// 100 to sample 1% of the calls. Zero, the default, disables sampling.
+	SampleCgoCalls int
+	// Exploratory appends a default LIMIT to the read queries run with Query
+	// that have none, see ExploratoryMode.
+	Exploratory ExploratoryMode
+}
+
+// OpenConnectionWithOptions opens a connection to the database configured
+// with the options.
+func OpenConnectionWithOptions(database *Database, opts ConnectionOptions) (*Connection, error) {
+	if opts.SampleCgoCalls < 0 {
+		return nil, fmt.Errorf("invalid SampleCgoCalls %d: must not be negative", opts.SampleCgoCalls)
+	}
+	if opts.Exploratory.DefaultLimit < 0 {
+		return nil, fmt.Errorf("invalid DefaultLimit %d: must not be negative", opts.Exploratory.DefaultLimit)
+	}
+	conn, err := OpenConnection(database)
+	conn.closeMutex.singleThreaded = opts.SingleThreaded
+	conn.profiler = newCgoProfiler(opts)
+	conn.exploratory = opts.Exploratory
+	return conn, err
+}
+
+// closeGuard is the lock of the handles of a connection: a sync.RWMutex, or
+// no lock at all for a single-threaded connection, checked by owner in debug
+// builds.
+type closeGuard struct {
+	mutex          sync.RWMutex
+	singleThreaded bool
+	owner          goroutineOwner
+}
+
+func (guard *closeGuard) Lock() {
+	if guard.singleThreaded {
+		guard.owner.check()
+		return
+	}
+	guard.mutex.Lock()
+}
+
+func (guard *closeGuard) Unlock() {
+	if !guard.singleThreaded {
+		guard.mutex.Unlock()
+	}
+}
+
+func (guard *closeGuard) RLock() {
+	if guard.singleThreaded {
+		guard.owner.check()
+		return
+	}
+	guard.mutex.RLock()
+}
+
+func (guard *closeGuard) RUnlock() {
+	if !guard.singleThreaded {
+		guard.mutex.RUnlock()
+	}
+}
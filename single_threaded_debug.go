@@ -0,0 +1,46 @@
+//go:build lbug_debug
+
+package lbug
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+// goroutineOwner records the goroutine that first uses a single-threaded
+// connection, and panics when another goroutine uses it.
+type goroutineOwner struct {
+	id atomic.Uint64
+}
+
+func (owner *goroutineOwner) check() {
+	id := currentGoroutineID()
+	if owner.id.CompareAndSwap(0, id) {
+		return
+	}
+	if ownerID := owner.id.Load(); ownerID != id {
+		panic(fmt.Sprintf("lbug: single-threaded Connection owned by goroutine %d used by goroutine %d", ownerID, id))
+	}
+}
+
+// release lets the next goroutine using the connection take it over.
+func (owner *goroutineOwner) release() {
+	owner.id.Store(0)
+}
+
+// currentGoroutineID returns the ID of the calling goroutine, parsed from the
+// header of its stack trace, "goroutine 18 [running]:".
+func currentGoroutineID() uint64 {
+	var buf [64]byte
+	header := buf[:runtime.Stack(buf[:], false)]
+	header = bytes.TrimPrefix(header, []byte("goroutine "))
+	header = header[:bytes.IndexByte(header, ' ')]
+	id, err := strconv.ParseUint(string(header), 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("lbug: failed to parse the goroutine ID: %v", err))
+	}
+	return id
+}
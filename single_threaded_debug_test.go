@@ -0,0 +1,32 @@
+//go:build lbug_debug
+
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoroutineOwner(t *testing.T) {
+	guard := &closeGuard{singleThreaded: true}
+	guard.RLock()
+	guard.RUnlock()
+	otherGoroutine := func() (recovered any) {
+		done := make(chan any)
+		go func() {
+			defer func() { done <- recover() }()
+			guard.RLock()
+			guard.RUnlock()
+		}()
+		return <-done
+	}
+	assert.Contains(t, otherGoroutine(), "single-threaded Connection owned by goroutine")
+	// The owner is still the first goroutine.
+	assert.NotPanics(t, guard.Lock)
+
+	// Once released, another goroutine may take the connection over.
+	guard.owner.release()
+	assert.Nil(t, otherGoroutine())
+	assert.Panics(t, guard.Lock)
+}
@@ -0,0 +1,11 @@
+//go:build !lbug_debug
+
+package lbug
+
+// goroutineOwner checks that a single-threaded connection is used by one
+// goroutine. The check is only made in builds with the lbug_debug tag.
+type goroutineOwner struct{}
+
+func (owner *goroutineOwner) check() {}
+
+func (owner *goroutineOwner) release() {}
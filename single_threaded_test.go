@@ -0,0 +1,88 @@
+package lbug
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseGuardSingleThreaded(t *testing.T) {
+	guard := &closeGuard{singleThreaded: true}
+	// The guard does not lock, so nested locks do not deadlock.
+	guard.RLock()
+	guard.Lock()
+	guard.Unlock()
+	guard.RUnlock()
+	guard.mutex.Lock()
+	guard.RLock()
+	guard.RUnlock()
+	guard.mutex.Unlock()
+}
+
+func TestSingleThreadedConnection(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	conn, err := OpenConnectionWithOptions(db, ConnectionOptions{SingleThreaded: true})
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.True(t, conn.closeMutex.singleThreaded)
+	statement, err := conn.PrepareWithContext(context.Background(), "MATCH (p:person) WHERE p.ID >= $min RETURN p.fName ORDER BY p.ID;")
+	assert.Nil(t, err)
+	defer statement.Close()
+	result, err := conn.Execute(statement, map[string]any{"min": int64(0)})
+	assert.Nil(t, err)
+	defer result.Close()
+	var names []any
+	for result.HasNext() {
+		tuple, err := result.Next()
+		assert.Nil(t, err)
+		name, err := tuple.GetValue(0)
+		assert.Nil(t, err)
+		names = append(names, name)
+		tuple.Close()
+	}
+	assert.Equal(t, "Alice", names[0])
+	assert.Equal(t, int64(len(names)), countPersons(t, conn, nil))
+}
+
+// benchmarkIterate benchmarks the iteration over the rows of a result, per
+// row: HasNext, Next, GetValue and Close of the tuple, each of which takes the
+// lock of the connection unless it is single-threaded.
+func benchmarkIterate(b *testing.B, opts ConnectionOptions) {
+	db, _ := SetupTestDatabase(b)
+	conn, err := OpenConnectionWithOptions(db, opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+	const query = "UNWIND range(1, 100000) AS i RETURN i;"
+	rows := 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	for rows < b.N {
+		b.StopTimer()
+		result, err := conn.Query(query)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		for rows < b.N && result.HasNext() {
+			tuple, _ := result.Next()
+			tuple.GetValue(0)
+			tuple.Close()
+			rows++
+		}
+		b.StopTimer()
+		result.Close()
+	}
+}
+
+// To quantify the savings of single-threaded connections per row, compare
+// the benchmarks with, e.g.:
+//
+//	go test -run '^$' -bench '^BenchmarkIterate' -count 10 | tee iterate.txt
+//	benchstat -col /mode iterate.txt
+func BenchmarkIterate(b *testing.B) {
+	b.Run("mode=locked", func(b *testing.B) { benchmarkIterate(b, ConnectionOptions{}) })
+	b.Run("mode=singlethreaded", func(b *testing.B) { benchmarkIterate(b, ConnectionOptions{SingleThreaded: true}) })
+}
@@ -0,0 +1,142 @@
+package lbug
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// snapshotCounter approximates the version of the state of a database. The
+// C API exposes no transaction or commit timestamp, so the counter is
+// incremented by the bindings whenever a statement that may write completes
+// successfully outside an explicit transaction, or a transaction that ran
+// such statements is committed.
+type snapshotCounter struct {
+	version atomic.Uint64
+	// checkpoints and schemaChanges count the CHECKPOINT statements and the
+	// committed schema changes, see ErrResultInvalidated.
+	checkpoints   atomic.Uint64
+	schemaChanges atomic.Uint64
+}
+
+// current returns the version, or zero for a nil counter.
+func (counter *snapshotCounter) current() uint64 {
+	if counter == nil {
+		return 0
+	}
+	return counter.version.Load()
+}
+
+// SnapshotID returns an identifier of the state of the database visible to
+// the connection, for example to tag cached responses: the identifier changes
+// when data visible to new queries may have changed, and can be compared with
+// QueryResult.SnapshotID to find out whether a result is stale.
+//
+// The engine does not expose a commit timestamp, so the identifier is a
+// counter of the writes committed through the connections of the process to
+// the database, shared by all the Database handles of an engine instance. It
+// is an approximation: statements are classified as writes by their keywords,
+// which may count statements that change nothing, e.g. a MERGE matching an
+// existing node, and writes made by other processes are not counted. The
+// counter starts at zero when the database is opened.
+func (conn *Connection) SnapshotID() (uint64, error) {
+	conn.closeMutex.RLock()
+	defer conn.closeMutex.RUnlock()
+	if conn.isClosed {
+		return 0, &Error{Op: OpExecute, Err: &closedError{"failed to get the snapshot because the connection is closed"}}
+	}
+	return conn.database.snapshot.current(), nil
+}
+
+// SnapshotID returns the identifier, as returned by Connection.SnapshotID,
+// of the state of the database the query was executed under, including the
+// writes of the query itself.
+func (queryResult *QueryResult) SnapshotID() uint64 {
+	return queryResult.snapshotID
+}
+
+// recordWrites advances the snapshot of the database after the query has
+// completed successfully on the connection, and returns the snapshot the
+// query was executed under.
+func (conn *Connection) recordWrites(query string) uint64 {
+	counter := conn.database.snapshot
+	if counter == nil {
+		return 0
+	}
+	conn.snapshotMutex.Lock()
+	defer conn.snapshotMutex.Unlock()
+	for _, statement := range splitStatements(queryKeywords(query)) {
+		switch {
+		case statement[0] == "BEGIN":
+			conn.inTransaction = true
+		case statement[0] == "COMMIT":
+			if conn.pendingWrites {
+				counter.version.Add(1)
+			}
+			if conn.pendingSchemaChanges {
+				conn.recordSchemaChange()
+			}
+			conn.inTransaction, conn.pendingWrites, conn.pendingSchemaChanges = false, false, false
+			conn.transactionResults = nil
+		case statement[0] == "ROLLBACK":
+			conn.inTransaction, conn.pendingWrites, conn.pendingSchemaChanges = false, false, false
+			conn.transactionResults = nil
+		case statement[0] == "CHECKPOINT":
+			conn.ownCheckpoints++
+			counter.checkpoints.Add(1)
+		case mayWrite(statement):
+			schemaChange := keywordsChangeSchema(statement)
+			if conn.inTransaction {
+				conn.pendingWrites = true
+				conn.pendingSchemaChanges = conn.pendingSchemaChanges || schemaChange
+			} else {
+				counter.version.Add(1)
+				if schemaChange {
+					conn.recordSchemaChange()
+				}
+			}
+		}
+	}
+	return counter.current()
+}
+
+// splitStatements splits the keywords of a query into the keywords of its
+// statements, skipping empty ones.
+func splitStatements(tokens []string) [][]string {
+	var statements [][]string
+	start := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && tokens[i] != ";" {
+			continue
+		}
+		if i > start {
+			statements = append(statements, tokens[start:i])
+		}
+		start = i + 1
+	}
+	return statements
+}
+
+// mayWrite returns true if the keywords of a statement include a clause that
+// may change data or the catalog. False positives only advance the snapshot.
+func mayWrite(statement []string) bool {
+	copying := false
+	for _, token := range statement {
+		switch token {
+		case "CREATE", "MERGE", "SET", "DELETE", "REMOVE", "DROP", "ALTER", "IMPORT":
+			return true
+		case "COPY":
+			copying = true
+		case "FROM":
+			if copying {
+				return true
+			}
+		default:
+			// Procedures creating and dropping indexes, e.g.
+			// CREATE_FTS_INDEX.
+			if strings.HasPrefix(token, "CREATE_") || strings.HasPrefix(token, "DROP_") {
+				return true
+			}
+		}
+	}
+	return false
+}
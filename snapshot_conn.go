@@ -0,0 +1,204 @@
+package lbug
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// defaultSnapshotIdleTimeout is the idle timeout of the snapshots of a
+// connection when SetSnapshotIdleTimeout has not been called.
+const defaultSnapshotIdleTimeout = 10 * time.Minute
+
+// SetSnapshotIdleTimeout sets the time after which a SnapshotConn of the
+// connection that has not been used is released, so that a forgotten
+// snapshot does not pin an old version of the database. A timeout of zero
+// restores the default of 10 minutes, and a negative timeout disables the
+// release. It applies to the snapshots begun afterwards.
+func (conn *Connection) SetSnapshotIdleTimeout(timeout time.Duration) {
+	conn.snapshotIdleTimeout = timeout
+}
+
+// SnapshotConn runs read queries on a connection against a consistent view of
+// the database, pinned by BeginSnapshot until Release: the writes of other
+// connections committed in the meantime are not visible to its queries, e.g.
+// for an export taking hours to complete. Its methods are safe for concurrent
+// use, and run one at a time.
+//
+// The view is held by a read-only transaction of the connection, so writes
+// fail on the snapshot, and the connection must not be used directly until
+// the snapshot is released. A snapshot that is not used for the idle timeout
+// of the connection is released, see Connection.SetSnapshotIdleTimeout, and
+// its methods then return ErrSnapshotExpired. The results returned by a
+// snapshot hold all their rows, and remain valid after it is released.
+type SnapshotConn struct {
+	conn        *Connection
+	idleTimeout time.Duration
+	mutex       sync.Mutex
+	// err is returned by the uses of the snapshot once it is released.
+	err error
+	// generation is incremented by every use, so that the idle timer armed
+	// before the use does not release the snapshot.
+	generation uint64
+	timer      *time.Timer
+}
+
+// BeginSnapshot begins a snapshot of the database on the connection. It fails
+// if the connection is in an explicit transaction.
+func (conn *Connection) BeginSnapshot(ctx context.Context) (*SnapshotConn, error) {
+	conn.snapshotMutex.Lock()
+	inTransaction := conn.inTransaction
+	conn.snapshotMutex.Unlock()
+	if inTransaction {
+		return nil, errors.New("BeginSnapshot cannot run in an explicit transaction")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := runStatement(conn, "BEGIN TRANSACTION READ ONLY;"); err != nil {
+		return nil, err
+	}
+	snapshot := &SnapshotConn{conn: conn, idleTimeout: conn.snapshotIdleTimeout}
+	if snapshot.idleTimeout == 0 {
+		snapshot.idleTimeout = defaultSnapshotIdleTimeout
+	}
+	snapshot.mutex.Lock()
+	snapshot.arm()
+	snapshot.mutex.Unlock()
+	return snapshot, nil
+}
+
+// arm starts the idle timer of the snapshot. mutex must be held.
+func (snapshot *SnapshotConn) arm() {
+	if snapshot.idleTimeout < 0 {
+		return
+	}
+	generation := snapshot.generation
+	snapshot.timer = time.AfterFunc(snapshot.idleTimeout, func() {
+		snapshot.mutex.Lock()
+		defer snapshot.mutex.Unlock()
+		if snapshot.generation == generation && snapshot.err == nil {
+			snapshot.release(ErrSnapshotExpired)
+		}
+	})
+}
+
+// use runs the function on the connection of the snapshot, unless it is
+// released, and restarts the idle timer.
+func (snapshot *SnapshotConn) use(f func(conn *Connection) error) error {
+	snapshot.mutex.Lock()
+	defer snapshot.mutex.Unlock()
+	if snapshot.err != nil {
+		return snapshot.err
+	}
+	if snapshot.timer != nil {
+		snapshot.timer.Stop()
+	}
+	snapshot.generation++
+	defer snapshot.arm()
+	return f(snapshot.conn)
+}
+
+// release ends the transaction of the snapshot, after which its uses return
+// err. mutex must be held.
+func (snapshot *SnapshotConn) release(err error) error {
+	snapshot.err = err
+	if snapshot.timer != nil {
+		snapshot.timer.Stop()
+	}
+	return runStatement(snapshot.conn, "ROLLBACK;")
+}
+
+// Release releases the snapshot, after which its methods fail with an error
+// matching ErrClosed, and the connection can be used again. Releasing a
+// released or expired snapshot does nothing.
+func (snapshot *SnapshotConn) Release() error {
+	snapshot.mutex.Lock()
+	defer snapshot.mutex.Unlock()
+	if snapshot.err != nil {
+		return nil
+	}
+	return snapshot.release(&closedError{"the snapshot is released"})
+}
+
+// Connection returns the connection of the snapshot.
+func (snapshot *SnapshotConn) Connection() *Connection {
+	return snapshot.conn
+}
+
+// Query runs the query against the snapshot, see Connection.Query.
+func (snapshot *SnapshotConn) Query(query string) (*QueryResult, error) {
+	return snapshot.QueryWithContext(context.Background(), query)
+}
+
+// QueryWithContext runs the query against the snapshot, see
+// Connection.QueryWithContext.
+func (snapshot *SnapshotConn) QueryWithContext(ctx context.Context, query string) (*QueryResult, error) {
+	var result *QueryResult
+	err := snapshot.use(func(conn *Connection) error {
+		var err error
+		result, err = conn.QueryWithContext(ctx, query)
+		return err
+	})
+	return result, err
+}
+
+// Prepare prepares the query on the connection of the snapshot, to be run
+// with Execute.
+func (snapshot *SnapshotConn) Prepare(query string) (*PreparedStatement, error) {
+	var statement *PreparedStatement
+	err := snapshot.use(func(conn *Connection) error {
+		var err error
+		statement, err = conn.Prepare(query)
+		return err
+	})
+	return statement, err
+}
+
+// Execute runs the prepared statement against the snapshot, see
+// Connection.Execute.
+func (snapshot *SnapshotConn) Execute(statement *PreparedStatement, args map[string]any) (*QueryResult, error) {
+	return snapshot.ExecuteWithContext(context.Background(), statement, args)
+}
+
+// ExecuteWithContext runs the prepared statement against the snapshot, see
+// Connection.ExecuteWithContext.
+func (snapshot *SnapshotConn) ExecuteWithContext(ctx context.Context, statement *PreparedStatement, args map[string]any) (*QueryResult, error) {
+	var result *QueryResult
+	err := snapshot.use(func(conn *Connection) error {
+		var err error
+		result, err = conn.ExecuteWithContext(ctx, statement, args)
+		return err
+	})
+	return result, err
+}
+
+// QueryGroup runs the read queries against the snapshot, in the order of the
+// slice, see Connection.QueryGroup. If a query fails, the results of the
+// previous ones are closed and a *GroupQueryError is returned, but the
+// snapshot is not released.
+func (snapshot *SnapshotConn) QueryGroup(ctx context.Context, queries []GroupQuery) ([]*QueryResult, error) {
+	var results []*QueryResult
+	err := snapshot.use(func(conn *Connection) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		results, err = conn.runGroupQueries(ctx, queries)
+		return err
+	})
+	return results, err
+}
+
+// ExportResumable runs the export against the snapshot, see ExportResumable.
+// The idle timeout does not release the snapshot while the export runs.
+func (snapshot *SnapshotConn) ExportResumable(spec ExportSpec, store CheckpointStore) (uint64, error) {
+	var rows uint64
+	err := snapshot.use(func(conn *Connection) error {
+		var err error
+		rows, err = ExportResumable(conn, spec, store)
+		return err
+	})
+	return rows, err
+}
@@ -0,0 +1,115 @@
+package lbug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotConnIsolation(t *testing.T) {
+	db, conn := SetupTestDatabase(t)
+	writer, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer writer.Close()
+	before := countPersons(t, conn, nil)
+
+	snapshot, err := conn.BeginSnapshot(context.Background())
+	assert.Nil(t, err)
+	defer snapshot.Release()
+	_, err = conn.BeginSnapshot(context.Background())
+	assert.NotNil(t, err)
+	mustRun(t, writer, "CREATE (:person {ID: 3000, fName: 'Writer'});")
+
+	// The write is invisible to the snapshot, but visible to a fresh
+	// connection.
+	result, err := snapshot.Query("MATCH (p:person) RETURN count(*);")
+	assert.Nil(t, err)
+	assert.Equal(t, before, countPersons(t, conn, result))
+	fresh, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer fresh.Close()
+	assert.Equal(t, before+1, countPersons(t, fresh, nil))
+
+	statement, err := snapshot.Prepare("MATCH (p:person) WHERE p.ID >= $min RETURN count(*);")
+	assert.Nil(t, err)
+	defer statement.Close()
+	result, err = snapshot.Execute(statement, map[string]any{"min": int64(0)})
+	assert.Nil(t, err)
+	assert.Equal(t, before, countPersons(t, conn, result))
+	results, err := snapshot.QueryGroup(context.Background(), []GroupQuery{
+		{Query: "MATCH (p:person) RETURN count(*);"},
+		{Query: "MATCH (p:person) WHERE p.ID = $id RETURN count(*);", Args: map[string]any{"id": int64(3000)}},
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, before, countPersons(t, conn, results[0]))
+		assert.Equal(t, int64(0), countPersons(t, conn, results[1]))
+	}
+
+	// Writes fail on the snapshot.
+	_, err = snapshot.Query("CREATE (:person {ID: 3001});")
+	assert.NotNil(t, err)
+
+	// Once released, the connection sees the write.
+	assert.Nil(t, snapshot.Release())
+	assert.Nil(t, snapshot.Release())
+	_, err = snapshot.Query("RETURN 1;")
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.Equal(t, before+1, countPersons(t, conn, nil))
+}
+
+func TestSnapshotConnExpires(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	conn.SetSnapshotIdleTimeout(50 * time.Millisecond)
+	snapshot, err := conn.BeginSnapshot(context.Background())
+	assert.Nil(t, err)
+	// Using the snapshot restarts the idle timeout.
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		result, err := snapshot.Query("RETURN 1;")
+		if assert.Nil(t, err) {
+			result.Close()
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+	_, err = snapshot.Query("RETURN 1;")
+	assert.True(t, errors.Is(err, ErrSnapshotExpired))
+	assert.Nil(t, snapshot.Release())
+	// The transaction of the snapshot has ended.
+	mustRun(t, conn, "CREATE (:person {ID: 3002});")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = conn.BeginSnapshot(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSnapshotConnExportResumable(t *testing.T) {
+	conn := setupExportTestConnection(t, 30)
+	writer, err := OpenConnection(conn.database)
+	assert.Nil(t, err)
+	defer writer.Close()
+	snapshot, err := conn.BeginSnapshot(context.Background())
+	assert.Nil(t, err)
+	defer snapshot.Release()
+	written := 0
+	total, err := snapshot.ExportResumable(ExportSpec{
+		Name:      "items",
+		Table:     "item",
+		Key:       "id",
+		BatchSize: 10,
+		Write: func(columns []string, rows [][]any) error {
+			// Rows written after the export started are not exported.
+			mustRun(t, writer, fmt.Sprintf("CREATE (:item {id: %d, name: 'late'});", 1000+written))
+			written += len(rows)
+			return nil
+		},
+	}, &FileCheckpointStore{Dir: t.TempDir()})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(30), total)
+	assert.Equal(t, 30, written)
+}
@@ -0,0 +1,86 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMayWrite(t *testing.T) {
+	for query, writes := range map[string]bool{
+		"MATCH (n:person) RETURN n.created;":                      false,
+		"MATCH (n:person) WHERE n.name = 'CREATE' RETURN n;":      false,
+		"CREATE (:person {id: 1});":                               true,
+		"MATCH (n:person) SET n.age = 1;":                         true,
+		"MATCH (n:person) DETACH DELETE n;":                       true,
+		"MERGE (:person {id: 1});":                                true,
+		"COPY person FROM 'people.csv';":                          true,
+		"COPY (MATCH (n) RETURN n) TO 'people.csv';":              false,
+		"CALL CREATE_FTS_INDEX('person', 'idx', ['name']);":       true,
+		"CALL show_tables() RETURN *;":                            false,
+		"RETURN $set;":                                            false,
+		"/* CREATE */ MATCH (n) // DELETE\nRETURN count(*) AS c;": false,
+	} {
+		statements := splitStatements(queryKeywords(query))
+		assert.Equal(t, 1, len(statements), query)
+		assert.Equal(t, writes, mayWrite(statements[0]), query)
+	}
+	assert.Equal(t, [][]string{{"BEGIN", "TRANSACTION"}, {"COMMIT"}}, splitStatements(queryKeywords(";BEGIN TRANSACTION;; COMMIT;")))
+}
+
+func TestSnapshotID(t *testing.T) {
+	db, conn := openTempTableTestConnection(t)
+	snapshot := func() uint64 {
+		id, err := conn.SnapshotID()
+		assert.Nil(t, err)
+		return id
+	}
+	assert.Equal(t, uint64(0), snapshot())
+	mustRun(t, conn, "CREATE NODE TABLE item(id INT64, PRIMARY KEY(id));")
+	assert.Equal(t, uint64(1), snapshot())
+
+	result, err := conn.Query("MATCH (i:item) RETURN count(*);")
+	assert.Nil(t, err)
+	result.Close()
+	assert.Equal(t, uint64(1), result.SnapshotID())
+	assert.Equal(t, uint64(1), snapshot())
+
+	statement, err := conn.Prepare("CREATE (:item {id: $id});")
+	assert.Nil(t, err)
+	defer statement.Close()
+	_, err = statement.Exec(map[string]any{"id": int64(1)})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), snapshot())
+
+	// The writes of a transaction advance the snapshot once, when it is
+	// committed, and not at all if it is rolled back.
+	mustRun(t, conn, "BEGIN TRANSACTION;")
+	mustRun(t, conn, "CREATE (:item {id: 2});")
+	mustRun(t, conn, "CREATE (:item {id: 3});")
+	assert.Equal(t, uint64(2), snapshot())
+	mustRun(t, conn, "COMMIT;")
+	assert.Equal(t, uint64(3), snapshot())
+	mustRun(t, conn, "BEGIN TRANSACTION;")
+	mustRun(t, conn, "CREATE (:item {id: 4});")
+	mustRun(t, conn, "ROLLBACK;")
+	assert.Equal(t, uint64(3), snapshot())
+
+	// The snapshot is shared by the connections of the database, and a
+	// result records the snapshot including its own writes.
+	other, err := OpenConnection(db)
+	assert.Nil(t, err)
+	result, err = other.Query("CREATE (:item {id: 5}) RETURN 1;")
+	assert.Nil(t, err)
+	result.Close()
+	assert.Equal(t, uint64(4), result.SnapshotID())
+	assert.Equal(t, uint64(4), snapshot())
+
+	// A failed write does not advance the snapshot.
+	_, err = conn.Query("CREATE (:item {id: 5});")
+	assert.NotNil(t, err)
+	assert.Equal(t, uint64(4), snapshot())
+
+	other.Close()
+	_, err = other.SnapshotID()
+	assert.ErrorIs(t, err, ErrClosed)
+}
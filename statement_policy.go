@@ -0,0 +1,206 @@
+package lbug
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// StatementType is the kind of a statement, as classified from its keywords
+// for a Policy.
+type StatementType string
+
+const (
+	// StatementRead is a query that only reads data, e.g. MATCH ... RETURN.
+	StatementRead StatementType = "read"
+	// StatementWrite is a query that may change data, e.g. with CREATE,
+	// MERGE, SET or DELETE clauses, or a call to a procedure creating or
+	// dropping an index.
+	StatementWrite StatementType = "write"
+	// StatementDDL changes the catalog: CREATE, DROP and ALTER of tables,
+	// sequences, types, macros and graphs, and COMMENT ON.
+	StatementDDL StatementType = "ddl"
+	// StatementCopy reads or writes files: COPY, LOAD FROM, and EXPORT and
+	// IMPORT DATABASE.
+	StatementCopy StatementType = "copy"
+	// StatementCall is a standalone CALL of a procedure or of a setting, e.g.
+	// CALL show_tables() RETURN * or CALL threads=4.
+	StatementCall StatementType = "call"
+	// StatementAttach attaches, detaches or switches databases: ATTACH,
+	// DETACH and USE.
+	StatementAttach StatementType = "attach"
+	// StatementExtension installs or loads an extension.
+	StatementExtension StatementType = "extension"
+	// StatementTransaction controls transactions: BEGIN TRANSACTION,
+	// COMMIT, ROLLBACK and CHECKPOINT.
+	StatementTransaction StatementType = "transaction"
+)
+
+// Statement is a statement of a query checked by a Policy.
+type Statement struct {
+	// Type is the kind of the statement. EXPLAIN and PROFILE statements have
+	// the type of the statement they explain.
+	Type StatementType
+	// Procedures are the upper-cased names of the procedures the statement
+	// calls, both in standalone CALL statements and in CALL clauses.
+	Procedures []string
+	// Index is the index of the statement in the query, from 0.
+	Index int
+	// Query is the text of the whole query, which may have other statements.
+	Query string
+}
+
+// Policy decides which statements may run on a connection, see
+// Connection.SetStatementPolicy.
+type Policy interface {
+	// Allow returns true if the statement may run, or false and the reason
+	// it is denied.
+	Allow(statement Statement) (allowed bool, reason string)
+}
+
+// PolicyFunc adapts a function to the Policy interface.
+type PolicyFunc func(statement Statement) (bool, string)
+
+// Allow returns the result of the function.
+func (policy PolicyFunc) Allow(statement Statement) (bool, string) {
+	return policy(statement)
+}
+
+// AllowListPolicy allows the statements of the listed types that call only
+// the listed procedures.
+type AllowListPolicy struct {
+	// Types are the types of the allowed statements.
+	Types []StatementType
+	// Procedures are the names of the procedures that may be called, in any
+	// case. A statement calling any other procedure is denied, whatever its
+	// type.
+	Procedures []string
+}
+
+// Allow implements Policy.
+func (policy AllowListPolicy) Allow(statement Statement) (bool, string) {
+	if !slices.Contains(policy.Types, statement.Type) {
+		return false, fmt.Sprintf("%s statements are not allowed", statement.Type)
+	}
+	for _, procedure := range statement.Procedures {
+		if !slices.ContainsFunc(policy.Procedures, func(allowed string) bool { return strings.EqualFold(allowed, procedure) }) {
+			return false, fmt.Sprintf("procedure %s is not allowed", procedure)
+		}
+	}
+	return true, ""
+}
+
+// ReadOnlyPolicy allows only the statements that read data, and the calls of
+// the procedures that show the catalog and the settings.
+var ReadOnlyPolicy Policy = AllowListPolicy{
+	Types: []StatementType{StatementRead, StatementCall},
+	Procedures: []string{
+		"SHOW_TABLES", "TABLE_INFO", "SHOW_CONNECTION", "SHOW_FUNCTIONS",
+		"SHOW_SEQUENCES", "SHOW_INDEXES", "SHOW_MACROS", "SHOW_WARNINGS",
+		"SHOW_ATTACHED_DATABASES", "SHOW_LOADED_EXTENSIONS",
+		"SHOW_OFFICIAL_EXTENSIONS", "SHOW_PROJECTED_GRAPHS",
+		"PROJECTED_GRAPH_INFO", "CURRENT_SETTING", "DB_VERSION",
+	},
+}
+
+// AdminPolicy allows all statements. It is the policy of the connections of
+// trusted administrators, for which a nil policy would read as an oversight.
+var AdminPolicy Policy = PolicyFunc(func(Statement) (bool, string) { return true, "" })
+
+// SetStatementPolicy sets the policy deciding which statements may run on the
+// connection, e.g. ReadOnlyPolicy for the queries submitted by the users of a
+// multi-tenant application. Every statement of a query run by Query, or
+// prepared by Prepare, is classified from its keywords and checked before the
+// query reaches the engine; if the policy denies any statement, the whole
+// query fails with a *StatementDeniedError. Prepared statements are checked
+// again by Execute and Exec after the policy is changed. The queries built by
+// the helpers of the package are checked like any other. A nil policy, the
+// default, allows all statements.
+//
+// The classification does not parse Cypher. It errs on the side of the more
+// privileged type, e.g. a CREATE clause whose variable is named node and
+// whose label is named table is classified as DDL, so a policy may deny
+// statements it should allow, but a statement does not pass for a less
+// privileged type.
+func (conn *Connection) SetStatementPolicy(policy Policy) {
+	conn.policy = policy
+	conn.policyVersion++
+}
+
+// checkStatementPolicy returns a *StatementDeniedError for the first
+// statement of the query denied by the policy of the connection.
+func (conn *Connection) checkStatementPolicy(query string) error {
+	if conn.policy == nil {
+		return nil
+	}
+	for i, tokens := range splitStatements(queryKeywords(query)) {
+		statementType, procedures := classifyStatement(tokens)
+		statement := Statement{Type: statementType, Procedures: procedures, Index: i, Query: query}
+		if allowed, reason := conn.policy.Allow(statement); !allowed {
+			return &StatementDeniedError{Statement: statement, Reason: reason}
+		}
+	}
+	return nil
+}
+
+// ddlObjects are the keywords following CREATE and DROP in DDL statements.
+var ddlObjects = []string{"NODE", "REL", "TABLE", "SEQUENCE", "TYPE", "MACRO", "GRAPH"}
+
+// classifyStatement returns the type of the statement with the keywords and
+// the procedures it calls.
+func classifyStatement(tokens []string) (StatementType, []string) {
+	for len(tokens) > 0 && (tokens[0] == "EXPLAIN" || tokens[0] == "PROFILE") {
+		tokens = tokens[1:]
+	}
+	var procedures []string
+	for i, token := range tokens {
+		if token == "CALL" && i+1 < len(tokens) {
+			procedures = append(procedures, tokens[i+1])
+		}
+	}
+	if len(tokens) == 0 {
+		return StatementRead, procedures
+	}
+	second := ""
+	if len(tokens) > 1 {
+		second = tokens[1]
+	}
+	switch tokens[0] {
+	case "BEGIN", "COMMIT", "ROLLBACK", "CHECKPOINT":
+		return StatementTransaction, procedures
+	case "ATTACH", "DETACH", "USE":
+		return StatementAttach, procedures
+	case "INSTALL", "UNINSTALL":
+		return StatementExtension, procedures
+	case "LOAD":
+		if second == "EXTENSION" {
+			return StatementExtension, procedures
+		}
+	case "ALTER", "COMMENT":
+		return StatementDDL, procedures
+	case "CREATE", "DROP":
+		if slices.Contains(ddlObjects, second) {
+			return StatementDDL, procedures
+		}
+	case "COPY":
+		return StatementCopy, procedures
+	case "EXPORT", "IMPORT":
+		if second == "DATABASE" {
+			return StatementCopy, procedures
+		}
+	}
+	for i, token := range tokens {
+		// LOAD FROM go_source reads a row source of the connection rather
+		// than a file, see RegisterRowSource.
+		if token == "LOAD" && !(i+2 < len(tokens) && tokens[i+1] == "FROM" && tokens[i+2] == "GO_SOURCE") {
+			return StatementCopy, procedures
+		}
+	}
+	if mayWrite(tokens) {
+		return StatementWrite, procedures
+	}
+	if tokens[0] == "CALL" {
+		return StatementCall, procedures
+	}
+	return StatementRead, procedures
+}
@@ -0,0 +1,133 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyStatement(t *testing.T) {
+	for query, expected := range map[string]StatementType{
+		"MATCH (p:person) RETURN p.name":                                  StatementRead,
+		"RETURN 'CREATE NODE TABLE t(id INT64)'":                          StatementRead,
+		"MATCH (p) /* DROP TABLE person */ RETURN p":                      StatementRead,
+		"CREATE (:person {name: 'Alice'})":                                StatementWrite,
+		"MATCH (p:person) SET p.age = 3":                                  StatementWrite,
+		"MATCH (p:person) WITH p DETACH DELETE p":                         StatementWrite,
+		"CALL create_fts_index('person', 'idx', ['name'])":                StatementWrite,
+		"create node table t(id INT64, PRIMARY KEY(id))":                  StatementDDL,
+		"CREATE REL TABLE knows(FROM person TO person)":                   StatementDDL,
+		"DROP TABLE person":                                               StatementDDL,
+		"ALTER TABLE person ADD age INT64":                                StatementDDL,
+		"CREATE SEQUENCE ids":                                             StatementDDL,
+		"COMMENT ON TABLE person IS 'people'":                             StatementDDL,
+		"COPY person FROM 'people.csv'":                                   StatementCopy,
+		"COPY (MATCH (p) RETURN p.name) TO 'names.csv'":                   StatementCopy,
+		"LOAD FROM 'people.csv' RETURN *":                                 StatementCopy,
+		"MATCH (p) WITH p LOAD WITH HEADERS (a STRING) FROM 'x' RETURN a": StatementCopy,
+		"LOAD FROM go_source('events') RETURN *":                          StatementRead,
+		"EXPORT DATABASE '/tmp/backup'":                                   StatementCopy,
+		"IMPORT DATABASE '/tmp/backup'":                                   StatementCopy,
+		"CALL show_tables() RETURN *":                                     StatementCall,
+		"CALL threads=4":                                                  StatementCall,
+		"ATTACH 'other.db' AS other (dbtype lbug)":                        StatementAttach,
+		"DETACH other":                                                    StatementAttach,
+		"USE other":                                                       StatementAttach,
+		"INSTALL json":                                                    StatementExtension,
+		"LOAD EXTENSION json":                                             StatementExtension,
+		"BEGIN TRANSACTION":                                               StatementTransaction,
+		"CHECKPOINT":                                                      StatementTransaction,
+		"EXPLAIN MATCH (p) RETURN p":                                      StatementRead,
+		"PROFILE CREATE NODE TABLE t(id INT64, PRIMARY KEY(id))":          StatementDDL,
+	} {
+		statements := splitStatements(queryKeywords(query))
+		assert.Equal(t, 1, len(statements), query)
+		statementType, _ := classifyStatement(statements[0])
+		assert.Equal(t, expected, statementType, query)
+	}
+	_, procedures := classifyStatement(queryKeywords("MATCH (p) CALL table_info('person') RETURN * UNION CALL show_tables() RETURN *"))
+	assert.Equal(t, []string{"TABLE_INFO", "SHOW_TABLES"}, procedures)
+}
+
+func TestCheckStatementPolicy(t *testing.T) {
+	conn := &Connection{}
+	assert.Nil(t, conn.checkStatementPolicy("DROP TABLE person;"))
+	conn.SetStatementPolicy(ReadOnlyPolicy)
+	for _, query := range []string{
+		"MATCH (p) RETURN p;",
+		"CALL show_tables() RETURN *;",
+		"MATCH (p:person) RETURN p; CALL TABLE_INFO('person') RETURN *;",
+	} {
+		assert.Nil(t, conn.checkStatementPolicy(query), query)
+	}
+	for query, message := range map[string]string{
+		// A denied statement anywhere in a script denies the whole script.
+		"MATCH (p) RETURN p; DROP TABLE person;":  "ddl statement 2 of the query is denied by the statement policy: ddl statements are not allowed",
+		"MATCH (p) RETURN p;COPY person FROM 'x'": "copy statement 2 of the query is denied by the statement policy: copy statements are not allowed",
+		"CALL threads=1;":                         "call statement 1 of the query is denied by the statement policy: procedure THREADS is not allowed",
+		// A read-only procedure does not make a write pass.
+		"CALL show_tables() WITH * CREATE (:person {name: 'x'});":  "write statement 1 of the query is denied by the statement policy: write statements are not allowed",
+		"ATTACH 'other.db' AS other (dbtype lbug);":                "attach statement 1 of the query is denied by the statement policy: attach statements are not allowed",
+		"MATCH (p) CALL drop_fts_index('person', 'idx') RETURN p;": "write statement 1 of the query is denied by the statement policy: write statements are not allowed",
+	} {
+		err := conn.checkStatementPolicy(query)
+		var denied *StatementDeniedError
+		if assert.ErrorAs(t, err, &denied, query) {
+			assert.Equal(t, query, denied.Statement.Query)
+			assert.Equal(t, message, err.Error())
+		}
+		assert.ErrorIs(t, err, ErrStatementDenied)
+	}
+
+	conn.SetStatementPolicy(AllowListPolicy{Types: []StatementType{StatementRead, StatementWrite}})
+	assert.Nil(t, conn.checkStatementPolicy("MATCH (p:person) SET p.age = 1;"))
+	assert.NotNil(t, conn.checkStatementPolicy("MATCH (p) CALL show_tables() RETURN p;"))
+	conn.SetStatementPolicy(AdminPolicy)
+	assert.Nil(t, conn.checkStatementPolicy("ATTACH 'x' AS x (dbtype lbug); DROP TABLE person;"))
+}
+
+func TestStatementPolicy(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	count, err := conn.Prepare("MATCH (p:person) RETURN count(*);")
+	assert.Nil(t, err)
+	defer count.Close()
+	create, err := conn.Prepare("CREATE (:person {ID: 100, fName: 'Zed'});")
+	assert.Nil(t, err)
+	defer create.Close()
+
+	conn.SetStatementPolicy(ReadOnlyPolicy)
+	_, err = conn.Query("MATCH (p:person) RETURN p.fName; MATCH (p:person) DETACH DELETE p;")
+	var connErr *Error
+	assert.ErrorAs(t, err, &connErr)
+	assert.Equal(t, OpExecute, connErr.Op)
+	assert.ErrorIs(t, err, ErrStatementDenied)
+	_, err = conn.Prepare("DROP TABLE person;")
+	assert.ErrorAs(t, err, &connErr)
+	assert.Equal(t, OpPrepare, connErr.Op)
+	assert.ErrorIs(t, err, ErrStatementDenied)
+
+	// The statements prepared before the policy was set are checked again.
+	_, err = conn.Execute(create, nil)
+	assert.ErrorIs(t, err, ErrStatementDenied)
+	_, err = create.Exec(nil)
+	assert.ErrorIs(t, err, ErrStatementDenied)
+	result, err := conn.Execute(count, nil)
+	assert.Nil(t, err)
+	result.Close()
+	result, err = conn.Query("CALL show_tables() RETURN *;")
+	assert.Nil(t, err)
+	result.Close()
+
+	// Nothing reached the engine.
+	rows, err := queryRows(conn, "MATCH (p:person) RETURN p.ID AS id;")
+	assert.Nil(t, err)
+	assert.NotEqual(t, 0, len(rows))
+	for _, row := range rows {
+		assert.NotEqual(t, int64(100), row["id"])
+	}
+
+	conn.SetStatementPolicy(nil)
+	result, err = conn.Execute(create, nil)
+	assert.Nil(t, err)
+	result.Close()
+}
@@ -0,0 +1,184 @@
+package lbug
+
+import "sync/atomic"
+
+// DatabaseStats is a snapshot of the usage counters of a Database, suitable for
+// periodic logging and dashboards. The counters cover all connections opened
+// on the database. Open* fields are gauges of the handles that are currently
+// open; the other fields are cumulative since the database was opened.
+//
+// The engine does not expose plan cache counters through its C API, and the
+// bindings do not cache statements or results, so no cache metrics are
+// reported.
+type DatabaseStats struct {
+	OpenConnections        int64
+	OpenPreparedStatements int64
+	OpenQueryResults       int64
+	OpenFlatTuples         int64
+	// QueriesExecuted counts the calls to Query, Execute and Exec, including
+	// the failed ones.
+	QueriesExecuted uint64
+	// QueryErrors counts the calls to Query, Execute and Exec that failed.
+	QueryErrors uint64
+	// StatementsPrepared counts the calls to Prepare, including the failed
+	// ones.
+	StatementsPrepared uint64
+	// PrepareErrors counts the calls to Prepare that failed.
+	PrepareErrors uint64
+	// TuplesFetched counts the tuples returned by QueryResult.Next.
+	TuplesFetched uint64
+	// RowLimitsExceeded counts the results truncated because they have more
+	// rows than the limit set with Connection.SetMaxRows.
+	RowLimitsExceeded uint64
+	// HandleLimitsExceeded counts the handles not created because they would
+	// exceed a limit set with SetMaxOpenHandles.
+	HandleLimitsExceeded uint64
+}
+
+// databaseStats holds the counters behind DatabaseStats.
+type databaseStats struct {
+	openConnections        atomic.Int64
+	openPreparedStatements atomic.Int64
+	openQueryResults       atomic.Int64
+	openFlatTuples         atomic.Int64
+	queriesExecuted        atomic.Uint64
+	queryErrors            atomic.Uint64
+	statementsPrepared     atomic.Uint64
+	prepareErrors          atomic.Uint64
+	tuplesFetched          atomic.Uint64
+	rowLimitsExceeded      atomic.Uint64
+	handleLimitsExceeded   atomic.Uint64
+}
+
+// detachedStats collects the counters of objects that do not belong to a
+// database, so that callers never have to check for nil.
+var detachedStats databaseStats
+
+// Stats returns a snapshot of the usage counters of the database.
+func (db *Database) Stats() DatabaseStats {
+	stats := &db.stats
+	return DatabaseStats{
+		OpenConnections:        stats.openConnections.Load(),
+		OpenPreparedStatements: stats.openPreparedStatements.Load(),
+		OpenQueryResults:       stats.openQueryResults.Load(),
+		OpenFlatTuples:         stats.openFlatTuples.Load(),
+		QueriesExecuted:        stats.queriesExecuted.Load(),
+		QueryErrors:            stats.queryErrors.Load(),
+		StatementsPrepared:     stats.statementsPrepared.Load(),
+		PrepareErrors:          stats.prepareErrors.Load(),
+		TuplesFetched:          stats.tuplesFetched.Load(),
+		RowLimitsExceeded:      stats.rowLimitsExceeded.Load(),
+		HandleLimitsExceeded:   stats.handleLimitsExceeded.Load(),
+	}
+}
+
+// stats returns the counters of the database the connection belongs to.
+func (conn *Connection) stats() *databaseStats {
+	if conn == nil || conn.database == nil {
+		return &detachedStats
+	}
+	return &conn.database.stats
+}
+
+// CgoCalls counts the calls into the C API made for the operations of a
+// connection, by operation. Each counter counts the calls of the C functions
+// that perform the operation, and not the auxiliary calls, e.g. to inspect the
+// type of a value, so the counters show how often a workload crosses into the
+// engine rather than the exact number of transitions.
+type CgoCalls struct {
+	// Query counts the queries run by Query.
+	Query uint64
+	// Prepare counts the statements prepared by Prepare.
+	Prepare uint64
+	// Execute counts the executions of prepared statements by Execute and
+	// Exec.
+	Execute uint64
+	// Bind counts the parameters bound.
+	Bind uint64
+	// Next counts the calls checking for and fetching the next tuple of a
+	// result.
+	Next uint64
+	// GetValue counts the values read from tuples.
+	GetValue uint64
+	// Close counts the handles of the connection destroyed.
+	Close uint64
+}
+
+// ConnectionStats is a snapshot of the counters of a Connection.
+type ConnectionStats struct {
+	// CgoCalls are the calls into the C API counted while counting is enabled
+	// with SetCgoCallCounting.
+	CgoCalls CgoCalls
+	// CgoLatency is the histogram of the wall time of the calls sampled with
+	// ConnectionOptions.SampleCgoCalls.
+	CgoLatency CgoLatency
+}
+
+// The operations counted by cgoCallCounters.
+const (
+	cgoQuery = iota
+	cgoPrepare
+	cgoExecute
+	cgoBind
+	cgoNext
+	cgoGetValue
+	cgoClose
+	numCgoOperations
+)
+
+// cgoCallCounters holds the counters behind CgoCalls. Counting costs an
+// atomic load per call while it is disabled.
+type cgoCallCounters struct {
+	enabled atomic.Bool
+	counts  [numCgoOperations]atomic.Uint64
+}
+
+// add counts a call of the operation if counting is enabled.
+func (counters *cgoCallCounters) add(operation int) {
+	if counters.enabled.Load() {
+		counters.counts[operation].Add(1)
+	}
+}
+
+// countCgoCall counts a call of the operation into the C API on the
+// connection.
+func (conn *Connection) countCgoCall(operation int) {
+	if hook := lifetimeHooks.beforeCall.Load(); hook != nil {
+		(*hook)()
+	}
+	if conn != nil {
+		conn.cgoCalls.add(operation)
+	}
+}
+
+// SetCgoCallCounting enables or disables the counting of the calls into the C
+// API made on the connection, reported by Stats. Counting is disabled by
+// default.
+func (conn *Connection) SetCgoCallCounting(enabled bool) {
+	conn.cgoCalls.enabled.Store(enabled)
+}
+
+// ResetCgoCalls sets the counters of the calls into the C API of the
+// connection to zero.
+func (conn *Connection) ResetCgoCalls() {
+	for i := range conn.cgoCalls.counts {
+		conn.cgoCalls.counts[i].Store(0)
+	}
+}
+
+// Stats returns a snapshot of the counters of the connection.
+func (conn *Connection) Stats() ConnectionStats {
+	counts := &conn.cgoCalls.counts
+	return ConnectionStats{
+		CgoCalls: CgoCalls{
+			Query:    counts[cgoQuery].Load(),
+			Prepare:  counts[cgoPrepare].Load(),
+			Execute:  counts[cgoExecute].Load(),
+			Bind:     counts[cgoBind].Load(),
+			Next:     counts[cgoNext].Load(),
+			GetValue: counts[cgoGetValue].Load(),
+			Close:    counts[cgoClose].Load(),
+		},
+		CgoLatency: conn.profiler.latency(),
+	}
+}
@@ -0,0 +1,137 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabaseStats(t *testing.T) {
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	assert.Equal(t, DatabaseStats{}, db.Stats())
+
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), db.Stats().OpenConnections)
+
+	result, err := conn.Query("UNWIND [1, 2, 3] AS x RETURN x;")
+	assert.Nil(t, err)
+	for result.HasNext() {
+		tuple, err := result.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), db.Stats().OpenFlatTuples)
+		tuple.Close()
+	}
+	assert.Equal(t, int64(1), db.Stats().OpenQueryResults)
+	result.Close()
+
+	_, err = conn.Query("RETURN nonexistent;")
+	assert.NotNil(t, err)
+
+	stmt, err := conn.Prepare("RETURN $x;")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), db.Stats().OpenPreparedStatements)
+	result, err = conn.Execute(stmt, map[string]any{"x": int64(1)})
+	assert.Nil(t, err)
+	result.Close()
+	stmt.Close()
+
+	stmt, err = conn.Prepare("RETURN")
+	assert.NotNil(t, err)
+	stmt.Close()
+
+	assert.Equal(t, DatabaseStats{
+		OpenConnections:    1,
+		QueriesExecuted:    3,
+		QueryErrors:        1,
+		StatementsPrepared: 2,
+		PrepareErrors:      1,
+		TuplesFetched:      3,
+	}, db.Stats())
+
+	conn.Close()
+	assert.Equal(t, int64(0), db.Stats().OpenConnections)
+}
+
+func TestDatabaseStatsCountDeniedCalls(t *testing.T) {
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	stmt, err := conn.Prepare("RETURN 1;")
+	assert.Nil(t, err)
+	defer stmt.Close()
+
+	// The calls failing before they reach the engine are counted as well.
+	conn.SetStatementPolicy(PolicyFunc(func(Statement) (bool, string) { return false, "denied" }))
+	_, err = conn.Query("RETURN 1;")
+	assert.ErrorIs(t, err, ErrStatementDenied)
+	denied, err := conn.Prepare("RETURN 1;")
+	assert.ErrorIs(t, err, ErrStatementDenied)
+	denied.Close()
+	_, err = conn.Execute(stmt, nil)
+	assert.ErrorIs(t, err, ErrStatementDenied)
+	_, err = stmt.Exec(nil)
+	assert.ErrorIs(t, err, ErrStatementDenied)
+
+	stats := db.Stats()
+	assert.Equal(t, uint64(3), stats.QueriesExecuted)
+	assert.Equal(t, uint64(3), stats.QueryErrors)
+	assert.Equal(t, uint64(2), stats.StatementsPrepared)
+	assert.Equal(t, uint64(1), stats.PrepareErrors)
+}
+
+func TestCgoCallCounters(t *testing.T) {
+	conn := &Connection{}
+	conn.countCgoCall(cgoQuery)
+	assert.Equal(t, ConnectionStats{}, conn.Stats())
+	conn.SetCgoCallCounting(true)
+	conn.countCgoCall(cgoQuery)
+	conn.countCgoCall(cgoNext)
+	conn.countCgoCall(cgoNext)
+	assert.Equal(t, CgoCalls{Query: 1, Next: 2}, conn.Stats().CgoCalls)
+	conn.ResetCgoCalls()
+	assert.Equal(t, ConnectionStats{}, conn.Stats())
+	var detached *Connection
+	detached.countCgoCall(cgoClose)
+}
+
+func TestConnectionStatsCgoCalls(t *testing.T) {
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	conn.SetCgoCallCounting(true)
+
+	result, err := conn.Query("UNWIND [1, 2] AS x RETURN x, x + 1;")
+	assert.Nil(t, err)
+	for result.HasNext() {
+		tuple, err := result.Next()
+		assert.Nil(t, err)
+		_, err = tuple.GetAsSlice()
+		assert.Nil(t, err)
+		tuple.Close()
+	}
+	result.Close()
+	stmt, err := conn.Prepare("RETURN $x, $y;")
+	assert.Nil(t, err)
+	_, err = stmt.Exec(map[string]any{"x": 1, "y": 2})
+	assert.Nil(t, err)
+	stmt.Close()
+
+	assert.Equal(t, CgoCalls{
+		Query:    1,
+		Prepare:  1,
+		Execute:  1,
+		Bind:     2,
+		Next:     5,
+		GetValue: 4,
+		Close:    5,
+	}, conn.Stats().CgoCalls)
+}
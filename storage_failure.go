@@ -0,0 +1,116 @@
+package lbug
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrStorageCorrupted is returned when the engine reports that files of the
+// database are corrupted, e.g. a checksum mismatch or an invalid file header.
+var ErrStorageCorrupted = errors.New("database storage is corrupted")
+
+// ErrStorageUnavailable is returned when the engine fails to read or write the
+// files of the database, e.g. because the database directory was removed, the
+// disk is full or the permissions changed.
+var ErrStorageUnavailable = errors.New("database storage is unavailable")
+
+// storageCorruptedMessages and storageUnavailableMessages are the fragments,
+// in lower case, of the engine error messages classified as
+// ErrStorageCorrupted and ErrStorageUnavailable. Corruption is checked first,
+// as the engine reports some corruptions as IO exceptions.
+var (
+	storageCorruptedMessages = []string{
+		"corrupt",
+		"checksum",
+		"invalid database file",
+		"not a valid lbug database",
+	}
+	storageUnavailableMessages = []string{
+		"io exception",
+		"no such file or directory",
+		"cannot open file",
+		"cannot read from file",
+		"cannot write to file",
+		"permission denied",
+		"no space left on device",
+		"read-only file system",
+	}
+)
+
+// storageError returns ErrStorageCorrupted or ErrStorageUnavailable if the
+// engine error message reports a storage failure, or nil otherwise.
+func storageError(message string) error {
+	message = strings.ToLower(message)
+	for _, fragment := range storageCorruptedMessages {
+		if strings.Contains(message, fragment) {
+			return ErrStorageCorrupted
+		}
+	}
+	for _, fragment := range storageUnavailableMessages {
+		if strings.Contains(message, fragment) {
+			return ErrStorageUnavailable
+		}
+	}
+	return nil
+}
+
+// databaseHealth records the first storage failure of an engine instance,
+// which is shared by the Database handles of an on-disk database.
+type databaseHealth struct {
+	mutex   sync.Mutex
+	err     error
+	onFatal func(error)
+}
+
+// fail records err as the failure of the database if it has not failed yet,
+// and calls the OnFatal callback for the first failure only.
+func (health *databaseHealth) fail(err error) {
+	if health == nil {
+		return
+	}
+	health.mutex.Lock()
+	if health.err != nil {
+		health.mutex.Unlock()
+		return
+	}
+	health.err = err
+	onFatal := health.onFatal
+	health.mutex.Unlock()
+	if onFatal != nil {
+		onFatal(err)
+	}
+}
+
+// failure returns the failure of the database, or nil if it has not failed.
+func (health *databaseHealth) failure() error {
+	if health == nil {
+		return nil
+	}
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+	return health.err
+}
+
+// Failure returns the storage failure that marked the database as failed, or
+// nil if it has not failed. The failure is an *Error matching
+// ErrStorageCorrupted or ErrStorageUnavailable. Once a database has failed,
+// OpenConnection refuses to open connections on it with an error wrapping the
+// failure; the process should close the database and reopen it, or restart,
+// once the storage is repaired.
+func (db *Database) Failure() error {
+	return db.health.failure()
+}
+
+// engineError returns the error of an operation that the engine failed with
+// the message. If the message reports a storage failure, the error matches
+// ErrStorageCorrupted or ErrStorageUnavailable and the database of the
+// connection is marked as failed.
+func (conn *Connection) engineError(op string, query string, message string) *Error {
+	conn.lastCallFailed.Store(true)
+	err := &Error{Op: op, Query: query, Message: message, Err: storageError(message)}
+	if err.Err != nil && conn.database != nil {
+		conn.database.health.fail(err)
+	}
+	return err
+}
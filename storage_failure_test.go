@@ -0,0 +1,98 @@
+package lbug
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageError(t *testing.T) {
+	assert.Equal(t, ErrStorageUnavailable, storageError("IO exception: Cannot open file. path: /tmp/db/data.kz - Error 2: No such file or directory"))
+	assert.Equal(t, ErrStorageUnavailable, storageError("IO exception: Cannot write to file. Error 28: No space left on device"))
+	assert.Equal(t, ErrStorageCorrupted, storageError("Runtime exception: Corrupted wal file. Checksum verification failed"))
+	assert.Equal(t, ErrStorageCorrupted, storageError("IO exception: invalid database file"))
+	assert.Nil(t, storageError("Binder exception: Table person does not exist."))
+	assert.Nil(t, storageError("Parser exception: Invalid input <RETURN 1 +>"))
+}
+
+func TestDatabaseHealthFailsOnce(t *testing.T) {
+	var fatal []error
+	health := &databaseHealth{onFatal: func(err error) { fatal = append(fatal, err) }}
+	assert.Nil(t, health.failure())
+	first := &Error{Op: OpExecute, Message: "IO exception", Err: ErrStorageUnavailable}
+	health.fail(first)
+	health.fail(&Error{Op: OpExecute, Message: "corrupted", Err: ErrStorageCorrupted})
+	assert.Equal(t, first, health.failure())
+	assert.Equal(t, []error{first}, fatal)
+
+	var detached *databaseHealth
+	detached.fail(first)
+	assert.Nil(t, detached.failure())
+}
+
+func TestEngineErrorMarksDatabaseFailed(t *testing.T) {
+	var fatal error
+	db := &Database{health: &databaseHealth{onFatal: func(err error) { fatal = err }}}
+	conn := &Connection{database: db}
+
+	err := conn.engineError(OpExecute, "MATCH (n) RETURN n;", "Binder exception: Table n does not exist.")
+	assert.Nil(t, err.Err)
+	assert.Nil(t, db.Failure())
+
+	err = conn.engineError(OpExecute, "MATCH (n) RETURN n;", "IO exception: Cannot read from file: No such file or directory")
+	assert.ErrorIs(t, err, ErrStorageUnavailable)
+	assert.Equal(t, "IO exception: Cannot read from file: No such file or directory", err.Message)
+	assert.Equal(t, err, db.Failure())
+	assert.Equal(t, err, fatal)
+
+	_, err2 := OpenConnection(db)
+	assert.ErrorIs(t, err2, ErrStorageUnavailable)
+	var lbugErr *Error
+	assert.True(t, errors.As(err2, &lbugErr))
+	assert.Equal(t, OpOpen, lbugErr.Op)
+}
+
+func TestRemovedDatabaseDirectory(t *testing.T) {
+	var fatal error
+	config := DefaultSystemConfig()
+	config.OnFatal = func(err error) { fatal = err }
+	path := getDatabasePath(t)
+	db, err := OpenDatabase(path, config)
+	assert.Nil(t, err)
+	defer db.Close()
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	mustRun(t, conn, "CREATE NODE TABLE person(id INT64, PRIMARY KEY(id));")
+	mustRun(t, conn, "CREATE (:person {id: 1});")
+
+	removed := path
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		removed = filepath.Dir(path)
+	}
+	if err := os.RemoveAll(removed); err != nil {
+		t.Skipf("cannot remove the database files: %v", err)
+	}
+	var failure error
+	for _, query := range []string{"CHECKPOINT;", "CREATE (:person {id: 2});", "CHECKPOINT;"} {
+		result, err := conn.Query(query)
+		if err != nil {
+			failure = err
+			break
+		}
+		result.Close()
+	}
+	if failure == nil {
+		t.Skip("the engine did not fail after the database files were removed on this platform")
+	}
+	if !errors.Is(failure, ErrStorageUnavailable) && !errors.Is(failure, ErrStorageCorrupted) {
+		t.Skipf("the engine reported an unclassified error: %v", failure)
+	}
+	assert.Equal(t, failure, db.Failure())
+	assert.Equal(t, failure, fatal)
+	_, err = OpenConnection(db)
+	assert.ErrorIs(t, err, failure)
+}
@@ -0,0 +1,181 @@
+package lbug
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+)
+
+// RawString is a string bound as a STRING parameter without the validation of
+// SetValidateStrings, for callers that intentionally store arbitrary bytes in
+// STRING columns. The C API takes NUL-terminated strings, so the bytes after a
+// NUL byte are still not stored.
+type RawString string
+
+// SetValidateStrings enables or disables the validation of the strings of the
+// queries prepared or run on the connection and of the parameters bound by
+// Execute and Exec, including the strings nested in lists, maps and structs
+// and the names of the fields of structs. The C API takes NUL-terminated
+// strings, so a NUL byte would silently truncate the string, and the engine
+// does not check that strings are valid UTF-8, so the validation rejects both
+// with an *InvalidStringError before the string is passed to the C API. The
+// identifiers passed to the helpers of the package, e.g. table and property
+// names, are validated with the queries they build. Values of RawString and of
+// types implementing LbugValuer, json.Marshaler or encoding.TextMarshaler are
+// not validated.
+//
+// The validation is enabled by default.
+func (conn *Connection) SetValidateStrings(enabled bool) {
+	conn.rawStrings = !enabled
+}
+
+// invalidStringOffset returns the offset of the first NUL byte or byte of an
+// invalid UTF-8 sequence of the string, or -1 if the string is valid.
+func invalidStringOffset(s string) (offset int, nul bool) {
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == 0:
+			return i, true
+		case c < utf8.RuneSelf:
+			i++
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				return i, false
+			}
+			i += size
+		}
+	}
+	return -1, false
+}
+
+// checkString returns an *InvalidStringError at the path if the string is
+// invalid.
+func checkString(s string, path string) *InvalidStringError {
+	if offset, nul := invalidStringOffset(s); offset >= 0 {
+		return &InvalidStringError{Path: path, Offset: offset, NUL: nul}
+	}
+	return nil
+}
+
+// findInvalidString returns an *InvalidStringError for the first invalid string
+// of a parameter value, as validated by SetValidateStrings, or nil.
+func findInvalidString(value any) *InvalidStringError {
+	switch v := value.(type) {
+	case nil, RawString, []byte, json.RawMessage, Blob, LbugValuer, json.Marshaler, encoding.TextMarshaler:
+		return nil
+	case string:
+		return checkString(v, "")
+	case map[string]any:
+		for key, field := range v {
+			if err := checkString(key, ""); err != nil {
+				return err.within(fmt.Sprintf("{key %q}", key))
+			}
+			if err := findInvalidString(field); err != nil {
+				return err.within("." + key)
+			}
+		}
+		return nil
+	case []MapItem:
+		for i, item := range v {
+			if err := findInvalidString(item.Key); err != nil {
+				return err.within(fmt.Sprintf("[%d].Key", i))
+			}
+			if err := findInvalidString(item.Value); err != nil {
+				return err.within(fmt.Sprintf("[%d].Value", i))
+			}
+		}
+		return nil
+	case []any:
+		for i, element := range v {
+			if err := findInvalidString(element); err != nil {
+				return err.within(fmt.Sprintf("[%d]", i))
+			}
+		}
+		return nil
+	}
+	reflectValue := reflect.ValueOf(value)
+	switch reflectValue.Kind() {
+	case reflect.String:
+		return checkString(reflectValue.String(), "")
+	case reflect.Pointer:
+		if reflectValue.IsNil() {
+			return nil
+		}
+		return findInvalidString(reflectValue.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if reflectValue.Type().Elem().Kind() == reflect.Uint8 {
+			return nil
+		}
+		for i := 0; i < reflectValue.Len(); i++ {
+			if err := findInvalidString(reflectValue.Index(i).Interface()); err != nil {
+				return err.within(fmt.Sprintf("[%d]", i))
+			}
+		}
+	case reflect.Map:
+		for iter := reflectValue.MapRange(); iter.Next(); {
+			if err := findInvalidString(iter.Key().Interface()); err != nil {
+				return err.within(fmt.Sprintf("{key %v}", iter.Key()))
+			}
+			if err := findInvalidString(iter.Value().Interface()); err != nil {
+				return err.within(fmt.Sprintf("[%v]", iter.Key()))
+			}
+		}
+	case reflect.Struct:
+		valueType := reflectValue.Type()
+		for i := 0; i < valueType.NumField(); i++ {
+			name, ok := structFieldName(valueType.Field(i))
+			if !ok {
+				continue
+			}
+			if err := checkString(name, ""); err != nil {
+				return err.within(fmt.Sprintf("{field %q}", name))
+			}
+			if err := findInvalidString(reflectValue.Field(i).Interface()); err != nil {
+				return err.within("." + name)
+			}
+		}
+	}
+	return nil
+}
+
+// within prefixes the path of the error with the location of the value that
+// contains the invalid string.
+func (err *InvalidStringError) within(location string) *InvalidStringError {
+	err.Path = location + err.Path
+	return err
+}
+
+// checkParameterStrings returns an *InvalidStringError naming the parameter if
+// its name or value has an invalid string and the connection validates
+// strings.
+func (conn *Connection) checkParameterStrings(name string, value any) error {
+	if conn.rawStrings {
+		return nil
+	}
+	err := checkString(name, "")
+	if err != nil {
+		err.Path = "{name}"
+	} else {
+		err = findInvalidString(value)
+	}
+	if err != nil {
+		err.Parameter = name
+		return err
+	}
+	return nil
+}
+
+// checkQueryString returns an *InvalidStringError if the query is invalid and
+// the connection validates strings.
+func (conn *Connection) checkQueryString(query string) error {
+	if conn.rawStrings {
+		return nil
+	}
+	if err := checkString(query, ""); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidStringOffset(t *testing.T) {
+	for s, expected := range map[string]struct {
+		offset int
+		nul    bool
+	}{
+		"":                 {-1, false},
+		"plain":            {-1, false},
+		"日本語 ✓":            {-1, false},
+		"a\x00b":           {1, true},
+		"ab\xffc":          {2, false},
+		"日本\xe8\xaa":       {6, false},
+		"\xe6\x97\xa5\x00": {3, true},
+	} {
+		offset, nul := invalidStringOffset(s)
+		assert.Equal(t, expected.offset, offset, "%q", s)
+		assert.Equal(t, expected.nul, nul, "%q", s)
+	}
+}
+
+func TestFindInvalidString(t *testing.T) {
+	type address struct {
+		City string `lbug:"city"`
+		Tags []string
+	}
+	for _, test := range []struct {
+		value any
+		path  string
+	}{
+		{"ok\x00", ""},
+		{[]any{"a", int64(1), "b\xff"}, "[2]"},
+		{map[string]any{"name": map[string]any{"first": "\x00"}}, ".name.first"},
+		{map[string]any{"na\xffme": "Alice"}, "{key \"na\\xffme\"}"},
+		{[]MapItem{{Key: "a", Value: "b"}, {Key: "\x00", Value: "c"}}, "[1].Key"},
+		{[]address{{City: "Waterloo"}, {City: "Kitchener", Tags: []string{"x", "\xff"}}}, "[1].Tags[1]"},
+		{&address{City: "\x00"}, ".city"},
+		{map[int64]string{7: "\xff"}, "[7]"},
+	} {
+		err := findInvalidString(test.value)
+		if assert.NotNil(t, err, "%#v", test.value) {
+			assert.Equal(t, test.path, err.Path, "%#v", test.value)
+		}
+	}
+	for _, value := range []any{
+		nil, "valid", RawString("\xff\x00"), []byte{0, 0xff}, []RawString{"\x00"}, int64(0),
+		map[string]any{"a": []any{"b"}}, (*address)(nil), Blob{},
+	} {
+		assert.Nil(t, findInvalidString(value), "%#v", value)
+	}
+}
+
+func TestCheckParameterStrings(t *testing.T) {
+	conn := &Connection{}
+	err := conn.checkParameterStrings("names", []string{"Alice", "B\x00b"})
+	var stringErr *InvalidStringError
+	assert.ErrorAs(t, err, &stringErr)
+	assert.ErrorIs(t, err, ErrInvalidString)
+	assert.Equal(t, InvalidStringError{Parameter: "names", Path: "[1]", Offset: 1, NUL: true}, *stringErr)
+	assert.Equal(t, "parameter $names at [1] has a NUL byte at byte 1: wrap strings of arbitrary bytes in RawString", err.Error())
+	err = conn.checkParameterStrings("n\xffame", "Alice")
+	assert.ErrorAs(t, err, &stringErr)
+	assert.Equal(t, "{name}", stringErr.Path)
+	assert.Equal(t, "query has invalid UTF-8 at byte 9", conn.checkQueryString("RETURN 'a\xff';").Error())
+
+	conn.SetValidateStrings(false)
+	assert.Nil(t, conn.checkParameterStrings("names", []string{"B\x00b"}))
+	assert.Nil(t, conn.checkQueryString("RETURN 'a\xff';"))
+}
+
+func TestValidateStrings(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE item(name STRING, PRIMARY KEY(name));")
+	statement, err := conn.Prepare("CREATE (:item {name: $name});")
+	assert.Nil(t, err)
+	defer statement.Close()
+	_, err = conn.Execute(statement, map[string]any{"name": "caf\xe9"})
+	var connErr *Error
+	assert.ErrorAs(t, err, &connErr)
+	assert.Equal(t, OpBind, connErr.Op)
+	assert.Equal(t, "name", connErr.Parameter)
+	assert.ErrorIs(t, err, ErrInvalidString)
+	_, err = conn.Query("RETURN 'a\x00b';")
+	assert.ErrorIs(t, err, ErrInvalidString)
+	_, err = conn.Prepare("RETURN $a\xff;")
+	assert.ErrorIs(t, err, ErrInvalidString)
+
+	// Identifiers of the helpers are validated with the queries they build.
+	_, err = conn.CreateNode("it\x00em", map[string]any{"name": "a"}, CreateOptions{})
+	assert.ErrorIs(t, err, ErrInvalidString)
+
+	// RawString values are bound as they are.
+	result, err := conn.Execute(statement, map[string]any{"name": RawString("caf\xe9")})
+	assert.Nil(t, err)
+	result.Close()
+	rows, err := queryRows(conn, "MATCH (i:item) RETURN i.name AS name;")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(rows))
+	assert.Equal(t, 4, len(rows[0]["name"].(string)))
+
+	conn.SetValidateStrings(false)
+	result, err = conn.Execute(statement, map[string]any{"name": "na\xefve"})
+	assert.Nil(t, err)
+	result.Close()
+}
@@ -0,0 +1,209 @@
+package lbug
+
+// #include "lbug.h"
+import "C"
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// TypeCapability is a set of the operations of the bindings supported for the
+// values of a logical type.
+type TypeCapability uint
+
+const (
+	// CapabilityDecode means that values of the type are converted to Go by
+	// FlatTuple.GetValue, to TypeSupport.GoType.
+	CapabilityDecode TypeCapability = 1 << iota
+	// CapabilityBind means that Go values, those of TypeSupport.BindGoTypes,
+	// are bound as parameters of the type.
+	CapabilityBind
+	// CapabilityExportCSV means that the decoded values are written by
+	// CSVExportWriter.
+	CapabilityExportCSV
+	// CapabilityExportJSON means that the decoded values are encoded by
+	// FlatTuple.ToJSON.
+	CapabilityExportJSON
+	// CapabilityExportArrow means that the values are exported in the Arrow
+	// format.
+	CapabilityExportArrow
+	// CapabilityCollect means that the values are decoded into struct fields
+	// of their Go type by Collect.
+	CapabilityCollect
+)
+
+// capabilityNames are the names of the capabilities, in the order of their
+// bits.
+var capabilityNames = []string{"decode", "bind", "csv", "json", "arrow", "collect"}
+
+// Has returns true if all the capabilities of other are in the set.
+func (capability TypeCapability) Has(other TypeCapability) bool {
+	return capability&other == other
+}
+
+// String returns the names of the capabilities of the set separated by "|",
+// e.g. "decode|json", or "none" for the empty set.
+func (capability TypeCapability) String() string {
+	var names []string
+	for i, name := range capabilityNames {
+		if capability.Has(1 << i) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// TypeSupport is the support of a logical type by the bindings, an entry of
+// SupportMatrix.
+type TypeSupport struct {
+	Type         DataType
+	Capabilities TypeCapability
+	// GoType is the type of the values returned by FlatTuple.GetValue, or
+	// empty if the values are not decoded.
+	GoType string
+	// BindGoTypes are the Go types bound as parameters of the type, sorted.
+	// Named types, pointers and the other types of the same kinds are bound
+	// as well.
+	BindGoTypes []string
+}
+
+// SupportMatrix returns the support of every logical type known to the
+// bindings, sorted by type id. The matrix is derived from the conversions the
+// bindings register, the decoders of FlatTuple.GetValue, the Go types bound
+// by goValueToLbugValue, and the encoders of the exports and of Collect, so
+// that it describes the behavior of the installed version of the bindings.
+// The engine may support fewer of these types, see Capabilities, and
+// implicitly casts bound values to the types of the parameters, e.g. a STRING
+// to a UUID.
+func SupportMatrix() []TypeSupport {
+	bound := boundGoTypesByID()
+	arrow := engineProbes.arrowExport()
+	matrix := make([]TypeSupport, 0, len(dataTypeNames))
+	for typeID := range dataTypeNames {
+		support := TypeSupport{Type: newDataType(typeID)}
+		if int(typeID) < len(valueConversions) && valueConversions[typeID] != nil {
+			support.Capabilities |= CapabilityDecode
+			if arrow {
+				support.Capabilities |= CapabilityExportArrow
+			}
+		}
+		if goType, ok := columnGoTypes[support.Type.Name]; ok && support.Capabilities.Has(CapabilityDecode) {
+			support.GoType = goType.String()
+			sample := sampleValue(goType)
+			if _, err := rowSourceField(sample); err == nil {
+				support.Capabilities |= CapabilityExportCSV
+			}
+			if _, err := json.Marshal(sample); err == nil {
+				support.Capabilities |= CapabilityExportJSON
+			}
+			if canHold(goType, support.Type, false) {
+				support.Capabilities |= CapabilityCollect
+			}
+		}
+		if goTypes := bound[typeID]; len(goTypes) > 0 {
+			support.Capabilities |= CapabilityBind
+			support.BindGoTypes = goTypes
+		}
+		matrix = append(matrix, support)
+	}
+	sort.Slice(matrix, func(i, j int) bool {
+		return matrix[i].Type.TypeID < matrix[j].Type.TypeID
+	})
+	return matrix
+}
+
+// sampleValue returns a value of the type for the encoders to be tried on:
+// the zero value, or a pointer to the zero value for pointer types.
+func sampleValue(goType reflect.Type) any {
+	if goType.Kind() == reflect.Pointer {
+		return reflect.New(goType.Elem()).Interface()
+	}
+	return reflect.Zero(goType).Interface()
+}
+
+// boundGoTypesByID returns the names of the Go types bound as each logical
+// type, among the predeclared types of logicalTypeIDs, the types of
+// boundGoTypes, the nested types of the switch of goValueToLbugValue and the
+// types values are decoded to.
+func boundGoTypesByID() map[C.lbug_data_type_id][]string {
+	candidates := []reflect.Type{
+		reflect.TypeOf(map[string]any(nil)),
+		reflect.TypeOf([]MapItem(nil)),
+		reflect.TypeOf([]any(nil)),
+		reflect.TypeOf(false),
+	}
+	for _, basic := range basicTypes {
+		candidates = append(candidates, basic)
+	}
+	for goType := range boundGoTypes {
+		candidates = append(candidates, goType)
+	}
+	for _, goType := range columnGoTypes {
+		candidates = append(candidates, goType)
+	}
+	bound := make(map[C.lbug_data_type_id][]string)
+	for _, goType := range candidates {
+		typeID, ok := boundTypeID(goType)
+		if ok && !slices.Contains(bound[typeID], goType.String()) {
+			bound[typeID] = append(bound[typeID], goType.String())
+		}
+	}
+	if names, ok := bound[C.LBUG_TIMESTAMP]; ok {
+		// time.Time values with nanoseconds are bound as TIMESTAMP_NS.
+		bound[C.LBUG_TIMESTAMP_NS] = append(bound[C.LBUG_TIMESTAMP_NS], names...)
+	}
+	for _, names := range bound {
+		sort.Strings(names)
+	}
+	return bound
+}
+
+// boundTypeID returns the logical type values of the Go type are bound as by
+// goValueToLbugValue, following its order of conversions, or false if it
+// depends on the values, e.g. for LbugValuer implementations.
+func boundTypeID(goType reflect.Type) (C.lbug_data_type_id, bool) {
+	switch {
+	case goType.Implements(reflect.TypeOf((*LbugValuer)(nil)).Elem()):
+		return 0, false
+	case goType == reflect.TypeOf(map[string]any(nil)):
+		return C.LBUG_STRUCT, true
+	case goType == reflect.TypeOf([]MapItem(nil)):
+		return C.LBUG_MAP, true
+	case goType == reflect.TypeOf([]any(nil)):
+		return C.LBUG_LIST, true
+	}
+	if typeID, ok := boundGoTypes[goType]; ok {
+		return typeID, true
+	}
+	// The predeclared types are in the switch, before the marshalers.
+	if typeID, ok := logicalTypeIDs[goType.Kind()]; ok && goType.PkgPath() == "" && goType.Name() != "" {
+		return typeID, true
+	}
+	if goType.Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()) ||
+		goType.Implements(reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()) {
+		return C.LBUG_STRING, true
+	}
+	switch goType.Kind() {
+	case reflect.Pointer:
+		return boundTypeID(goType.Elem())
+	case reflect.Slice, reflect.Array:
+		return C.LBUG_LIST, true
+	case reflect.Map:
+		if goType.Key().Kind() == reflect.String {
+			return C.LBUG_STRUCT, true
+		}
+		return C.LBUG_MAP, true
+	case reflect.Struct:
+		return C.LBUG_STRUCT, true
+	}
+	typeID, ok := logicalTypeIDs[goType.Kind()]
+	return typeID, ok
+}
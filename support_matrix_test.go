@@ -0,0 +1,91 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// supportByName returns the entries of the matrix by type name.
+func supportByName(matrix []TypeSupport) map[string]TypeSupport {
+	byName := make(map[string]TypeSupport, len(matrix))
+	for _, support := range matrix {
+		byName[support.Type.Name] = support
+	}
+	return byName
+}
+
+func TestSupportMatrixCoversTypes(t *testing.T) {
+	matrix := SupportMatrix()
+	assert.Len(t, matrix, len(dataTypeNames))
+	byName := supportByName(matrix)
+	for _, name := range dataTypeNames {
+		_, ok := byName[name]
+		assert.True(t, ok, "no entry for %s", name)
+	}
+	for i := 1; i < len(matrix); i++ {
+		assert.Less(t, matrix[i-1].Type.TypeID, matrix[i].Type.TypeID)
+	}
+	// Every registered decoder is for a known type, with a Go type.
+	byID := make(map[int]TypeSupport, len(matrix))
+	for _, support := range matrix {
+		byID[support.Type.TypeID] = support
+	}
+	for typeID, conversion := range valueConversions {
+		if conversion == nil {
+			continue
+		}
+		support, ok := byID[typeID]
+		if assert.True(t, ok, "decoder registered for the unknown type id %d", typeID) {
+			assert.True(t, support.Capabilities.Has(CapabilityDecode), support.Type.Name)
+			assert.NotEqual(t, "", support.GoType, support.Type.Name)
+		}
+	}
+	// Every type is decoded, except the internal types.
+	for _, support := range matrix {
+		if support.Type.Name != "ANY" && support.Type.Name != "POINTER" {
+			assert.True(t, support.Capabilities.Has(CapabilityDecode), support.Type.Name)
+		}
+	}
+}
+
+func TestSupportMatrixEntries(t *testing.T) {
+	byName := supportByName(SupportMatrix())
+	int64Support := byName["INT64"]
+	assert.Equal(t, "int64", int64Support.GoType)
+	assert.Equal(t, "decode|bind|csv|json|collect", int64Support.Capabilities.String())
+	assert.Equal(t, []string{"int", "int64"}, int64Support.BindGoTypes)
+	// []byte values are bound as lists of UINT8, and Blob as an escaped string.
+	assert.False(t, byName["BLOB"].Capabilities.Has(CapabilityBind))
+	assert.Contains(t, byName["LIST"].BindGoTypes, "[]uint8")
+	assert.Contains(t, byName["STRING"].BindGoTypes, "lbug.Blob")
+	assert.Equal(t, []string{"time.Time"}, byName["TIMESTAMP_NS"].BindGoTypes)
+	assert.Contains(t, byName["INTERVAL"].BindGoTypes, "time.Duration")
+	assert.Contains(t, byName["STRUCT"].BindGoTypes, "map[string]interface {}")
+	assert.Contains(t, byName["STRING"].BindGoTypes, rawJSONType.String())
+	// UUID and INT128 values are bound as strings, which the engine casts.
+	assert.False(t, byName["UUID"].Capabilities.Has(CapabilityBind))
+	assert.Contains(t, byName["STRING"].BindGoTypes, "uuid.UUID")
+	assert.Contains(t, byName["STRING"].BindGoTypes, "*big.Int")
+	assert.False(t, byName["SERIAL"].Capabilities.Has(CapabilityBind))
+	assert.True(t, byName["SERIAL"].Capabilities.Has(CapabilityDecode))
+	assert.False(t, byName["NODE"].Capabilities.Has(CapabilityExportCSV))
+	assert.True(t, byName["NODE"].Capabilities.Has(CapabilityExportJSON|CapabilityCollect))
+	assert.Equal(t, "none", byName["POINTER"].Capabilities.String())
+	for _, support := range byName {
+		assert.False(t, support.Capabilities.Has(CapabilityExportArrow), support.Type.Name)
+	}
+}
+
+func TestSupportMatrixCoversEngineTypes(t *testing.T) {
+	db, _ := SetupTestDatabase(t)
+	caps, err := db.Capabilities()
+	assert.Nil(t, err)
+	byName := supportByName(SupportMatrix())
+	for _, dataType := range caps.SupportedLogicalTypes {
+		support, ok := byName[dataType.Name]
+		if assert.True(t, ok, "no entry for the engine type %s", dataType.Name) {
+			assert.True(t, support.Capabilities.Has(CapabilityDecode), dataType.Name)
+		}
+	}
+}
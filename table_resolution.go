@@ -0,0 +1,179 @@
+package lbug
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrNoSuchTable is returned when a table name passed to a helper does not
+// resolve to a table of the catalog.
+var ErrNoSuchTable = errors.New("no such table")
+
+// NoSuchTableError is returned when a table name does not resolve to a table
+// of the catalog. It matches ErrNoSuchTable with errors.Is.
+type NoSuchTableError struct {
+	Name string
+	// Suggestions are the names of the tables of the catalog that are close
+	// to Name, closest first.
+	Suggestions []string
+}
+
+func (err *NoSuchTableError) Error() string {
+	if len(err.Suggestions) == 0 {
+		return fmt.Sprintf("table %s does not exist", err.Name)
+	}
+	return fmt.Sprintf("table %s does not exist (did you mean %s?)", err.Name, strings.Join(err.Suggestions, " or "))
+}
+
+func (err *NoSuchTableError) Unwrap() error {
+	return ErrNoSuchTable
+}
+
+// maxTableSuggestions is the maximum number of suggestions of a
+// NoSuchTableError.
+const maxTableSuggestions = 3
+
+// ResolveTableName returns the name of the table of the catalog that name
+// refers to. Table names are matched case-insensitively like the engine does,
+// preferring an exact match, and may be quoted with backticks. If no table
+// matches, an error wrapping ErrNoSuchTable suggests close names.
+//
+// The catalog is read once and cached on the connection. The cache is
+// invalidated when the connection runs a statement that may change the
+// schema; InvalidateSchemaCache must be called after the schema is changed
+// through another connection.
+func (conn *Connection) ResolveTableName(name string) (string, error) {
+	tables, err := conn.catalogTables()
+	if err != nil {
+		return "", err
+	}
+	return resolveTableName(tables, name)
+}
+
+// InvalidateSchemaCache drops the catalog cached by ResolveTableName.
+func (conn *Connection) InvalidateSchemaCache() {
+	conn.schemaMutex.Lock()
+	conn.tables = nil
+	conn.schemaMutex.Unlock()
+}
+
+// catalogTables returns the node and relationship tables of the catalog,
+// reading them only if they are not cached yet.
+func (conn *Connection) catalogTables() (map[string]bool, error) {
+	conn.schemaMutex.Lock()
+	tables := conn.tables
+	conn.schemaMutex.Unlock()
+	if tables != nil {
+		return tables, nil
+	}
+	tables, err := listTables(conn)
+	if err != nil {
+		return nil, err
+	}
+	conn.schemaMutex.Lock()
+	conn.tables = tables
+	conn.schemaMutex.Unlock()
+	return tables, nil
+}
+
+// resolveTableName resolves name against the given tables.
+func resolveTableName(tables map[string]bool, name string) (string, error) {
+	if len(name) >= 2 && name[0] == '`' && name[len(name)-1] == '`' {
+		name = strings.ReplaceAll(name[1:len(name)-1], "``", "`")
+	}
+	if _, ok := tables[name]; ok {
+		return name, nil
+	}
+	var matches []string
+	for table := range tables {
+		if strings.EqualFold(table, name) {
+			matches = append(matches, table)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+	if len(matches) > 1 {
+		sort.Strings(matches)
+		return "", &NoSuchTableError{Name: name, Suggestions: matches}
+	}
+	return "", &NoSuchTableError{Name: name, Suggestions: suggestTableNames(tables, name)}
+}
+
+// suggestTableNames returns the table names within a small edit distance of
+// name, closest first.
+func suggestTableNames(tables map[string]bool, name string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+	lowerName := strings.ToLower(name)
+	maxDistance := max(2, len(lowerName)/3)
+	var candidates []candidate
+	for table := range tables {
+		distance := editDistance(lowerName, strings.ToLower(table))
+		if distance <= maxDistance {
+			candidates = append(candidates, candidate{table, distance})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < maxTableSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].name)
+	}
+	return suggestions
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a string, b string) int {
+	first, second := []rune(a), []rune(b)
+	previous := make([]int, len(second)+1)
+	current := make([]int, len(second)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(first); i++ {
+		current[0] = i
+		for j := 1; j <= len(second); j++ {
+			cost := 1
+			if first[i-1] == second[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(second)]
+}
+
+// changesSchema returns true if the query may change the catalog, in which
+// case the tables cached by ResolveTableName are invalidated. False positives
+// only cost a refresh of the cache.
+func changesSchema(query string) bool {
+	return keywordsChangeSchema(queryKeywords(query))
+}
+
+// keywordsChangeSchema returns true if the keywords of a query, or of one of
+// its statements, change the catalog, see changesSchema.
+func keywordsChangeSchema(tokens []string) bool {
+	for i, token := range tokens {
+		switch token {
+		case "IMPORT", "ATTACH", "DETACH":
+			return true
+		case "CREATE", "DROP", "ALTER":
+			for j := i + 1; j < len(tokens) && j <= i+3; j++ {
+				if tokens[j] == "TABLE" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
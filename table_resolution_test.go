@@ -0,0 +1,103 @@
+package lbug
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveTableNameInCatalog(t *testing.T) {
+	tables := map[string]bool{"Person": false, "Order": false, "knows": true, "person_v2": false}
+	for name, expected := range map[string]string{
+		"Person":    "Person",
+		"person":    "Person",
+		"PERSON":    "Person",
+		"order":     "Order",
+		"`Order`":   "Order",
+		"`order`":   "Order",
+		"KNOWS":     "knows",
+		"PERSON_v2": "person_v2",
+	} {
+		resolved, err := resolveTableName(tables, name)
+		assert.Nil(t, err, name)
+		assert.Equal(t, expected, resolved, name)
+	}
+
+	_, err := resolveTableName(tables, "persn")
+	assert.ErrorIs(t, err, ErrNoSuchTable)
+	var tableErr *NoSuchTableError
+	assert.True(t, errors.As(err, &tableErr))
+	assert.Equal(t, []string{"Person"}, tableErr.Suggestions)
+	assert.Equal(t, "table persn does not exist (did you mean Person?)", err.Error())
+
+	_, err = resolveTableName(tables, "unrelated")
+	assert.Equal(t, "table unrelated does not exist", err.Error())
+}
+
+func TestResolveTableNameAmbiguous(t *testing.T) {
+	tables := map[string]bool{"item": false, "Item": false}
+	resolved, err := resolveTableName(tables, "Item")
+	assert.Nil(t, err)
+	assert.Equal(t, "Item", resolved)
+	_, err = resolveTableName(tables, "ITEM")
+	assert.ErrorIs(t, err, ErrNoSuchTable)
+	assert.Equal(t, "table ITEM does not exist (did you mean Item or item?)", err.Error())
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("person", "person"))
+	assert.Equal(t, 1, editDistance("persn", "person"))
+	assert.Equal(t, 3, editDistance("kitten", "sitting"))
+	assert.Equal(t, 4, editDistance("", "abcd"))
+}
+
+func TestChangesSchema(t *testing.T) {
+	assert.True(t, changesSchema("CREATE NODE TABLE t(id INT64, PRIMARY KEY(id));"))
+	assert.True(t, changesSchema("create rel table group r(FROM a TO b);"))
+	assert.True(t, changesSchema("DROP TABLE t;"))
+	assert.True(t, changesSchema("ALTER TABLE t ADD x INT64;"))
+	assert.True(t, changesSchema("IMPORT DATABASE '/tmp/export';"))
+	assert.False(t, changesSchema("CREATE (:t {id: 1});"))
+	assert.False(t, changesSchema("MATCH (n:t) WHERE n.name = 'DROP TABLE t' RETURN n;"))
+}
+
+func TestConnectionResolveTableName(t *testing.T) {
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	result, err := conn.Query("CREATE NODE TABLE `Order`(id INT64, PRIMARY KEY(id));")
+	assert.Nil(t, err)
+	result.Close()
+
+	resolved, err := conn.ResolveTableName("order")
+	assert.Nil(t, err)
+	assert.Equal(t, "Order", resolved)
+	_, err = conn.ResolveTableName("Customer")
+	assert.ErrorIs(t, err, ErrNoSuchTable)
+
+	// Creating a table through the connection invalidates the cache.
+	result, err = conn.Query("CREATE NODE TABLE Customer(id INT64, PRIMARY KEY(id));")
+	assert.Nil(t, err)
+	result.Close()
+	resolved, err = conn.ResolveTableName("customer")
+	assert.Nil(t, err)
+	assert.Equal(t, "Customer", resolved)
+
+	// Changes made through other connections require an explicit invalidation.
+	other, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer other.Close()
+	result, err = other.Query("CREATE NODE TABLE Invoice(id INT64, PRIMARY KEY(id));")
+	assert.Nil(t, err)
+	result.Close()
+	_, err = conn.ResolveTableName("invoice")
+	assert.ErrorIs(t, err, ErrNoSuchTable)
+	conn.InvalidateSchemaCache()
+	resolved, err = conn.ResolveTableName("invoice")
+	assert.Nil(t, err)
+	assert.Equal(t, "Invoice", resolved)
+}
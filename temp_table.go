@@ -0,0 +1,141 @@
+package lbug
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// defaultTempTablePrefix is the prefix of the names of temporary tables when
+// NodeTableSpec.Prefix is not set.
+const defaultTempTablePrefix = "tmp"
+
+// maxTempTableAttempts is the number of names TempTable tries before giving up
+// when the generated names collide with existing tables.
+const maxTempTableAttempts = 5
+
+// ColumnSpec describes a column of a table to create.
+type ColumnSpec struct {
+	Name string
+	// Type is the data type of the column in Cypher, e.g. "INT64" or
+	// "STRING[]".
+	Type string
+}
+
+// NodeTableSpec describes a node table created by Connection.TempTable.
+type NodeTableSpec struct {
+	// Prefix is the prefix of the generated table name. If it is empty, "tmp"
+	// is used.
+	Prefix string
+	// Columns are the columns of the table.
+	Columns []ColumnSpec
+	// PrimaryKey is the name of the primary key column.
+	PrimaryKey string
+}
+
+// TempTable creates a node table with a unique name made of the prefix of the
+// spec and a random suffix, and returns its name and a function that drops
+// it. The table is registered with the connection, and the tables that have
+// not been dropped yet are dropped when the connection is closed.
+//
+// The cleanup function may be called more than once and does nothing after
+// the table has been dropped or the connection has been closed. If dropping
+// the table fails, e.g. because a transaction of the connection is still
+// open, the table stays registered and Close tries to drop it again, ignoring
+// errors. If the table is created inside a transaction that is rolled back,
+// the table no longer exists and cleanup only unregisters it.
+func (conn *Connection) TempTable(spec NodeTableSpec) (string, func(), error) {
+	if len(spec.Columns) == 0 {
+		return "", nil, fmt.Errorf("temporary table must have at least one column")
+	}
+	prefix := spec.Prefix
+	if prefix == "" {
+		prefix = defaultTempTablePrefix
+	}
+	schema := &tableSchema{}
+	for _, column := range spec.Columns {
+		schema.columns = append(schema.columns, tableColumn{
+			name:       column.Name,
+			dataType:   column.Type,
+			primaryKey: column.Name == spec.PrimaryKey,
+		})
+	}
+	if _, ok := schema.primaryKey(); !ok {
+		return "", nil, fmt.Errorf("primary key %s is not a column of the temporary table", spec.PrimaryKey)
+	}
+	var err error
+	for attempt := 0; attempt < maxTempTableAttempts; attempt++ {
+		schema.name, err = newTempTableName(prefix)
+		if err != nil {
+			return "", nil, err
+		}
+		err = runStatement(conn, schema.createTableStatement())
+		if err == nil {
+			break
+		}
+		if !strings.Contains(err.Error(), "already exists") {
+			return "", nil, fmt.Errorf("failed to create temporary table: %w", err)
+		}
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary table: %w", err)
+	}
+	name := schema.name
+	conn.tempTablesMutex.Lock()
+	if conn.tempTables == nil {
+		conn.tempTables = make(map[string]bool)
+	}
+	conn.tempTables[name] = true
+	conn.tempTablesMutex.Unlock()
+	return name, func() { conn.dropTempTable(name) }, nil
+}
+
+// newTempTableName generates the names of temporary tables. Tests replace it
+// to provoke collisions.
+var newTempTableName = tempTableName
+
+// tempTableName returns the prefix followed by a random suffix.
+func tempTableName(prefix string) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate temporary table name: %w", err)
+	}
+	return prefix + "_" + hex.EncodeToString(suffix), nil
+}
+
+// dropTempTable drops a temporary table of the connection and unregisters
+// it. A table that no longer exists is unregistered as well.
+func (conn *Connection) dropTempTable(name string) error {
+	conn.tempTablesMutex.Lock()
+	defer conn.tempTablesMutex.Unlock()
+	if !conn.tempTables[name] {
+		return nil
+	}
+	err := runStatement(conn, fmt.Sprintf("DROP TABLE %s;", QuoteIdentifier(name)))
+	if errors.Is(err, ErrClosed) {
+		return nil
+	}
+	if err != nil && !strings.Contains(err.Error(), "does not exist") {
+		return fmt.Errorf("failed to drop temporary table %s: %w", name, err)
+	}
+	delete(conn.tempTables, name)
+	return nil
+}
+
+// dropTempTables drops all temporary tables of the connection that have not
+// been dropped yet.
+func (conn *Connection) dropTempTables() error {
+	conn.tempTablesMutex.Lock()
+	names := make([]string, 0, len(conn.tempTables))
+	for name := range conn.tempTables {
+		names = append(names, name)
+	}
+	conn.tempTablesMutex.Unlock()
+	var errs []error
+	for _, name := range names {
+		errs = append(errs, conn.dropTempTable(name))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,115 @@
+package lbug
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var tempTableSpec = NodeTableSpec{
+	Prefix:     "scratch",
+	Columns:    []ColumnSpec{{Name: "id", Type: "INT64"}, {Name: "value", Type: "STRING"}},
+	PrimaryKey: "id",
+}
+
+func openTempTableTestConnection(t *testing.T) (*Database, *Connection) {
+	t.Helper()
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	t.Cleanup(db.Close)
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	t.Cleanup(conn.Close)
+	return db, conn
+}
+
+func tableExists(t *testing.T, conn *Connection, name string) bool {
+	t.Helper()
+	tables, err := listTables(conn)
+	assert.Nil(t, err)
+	_, ok := tables[name]
+	return ok
+}
+
+func TestTempTable(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	name, cleanup, err := conn.TempTable(tempTableSpec)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(name, "scratch_"))
+	assert.True(t, tableExists(t, conn, name))
+	result, err := conn.Query("CREATE (:" + QuoteIdentifier(name) + " {id: 1, value: 'a'});")
+	assert.Nil(t, err)
+	result.Close()
+
+	other, _, err := conn.TempTable(tempTableSpec)
+	assert.Nil(t, err)
+	assert.NotEqual(t, name, other)
+
+	cleanup()
+	assert.False(t, tableExists(t, conn, name))
+	// A second cleanup does nothing.
+	cleanup()
+	assert.True(t, tableExists(t, conn, other))
+}
+
+func TestTempTableDroppedOnClose(t *testing.T) {
+	db, conn := openTempTableTestConnection(t)
+	name, cleanup, err := conn.TempTable(tempTableSpec)
+	assert.Nil(t, err)
+	conn.Close()
+	// Cleanup after the connection is closed does nothing.
+	cleanup()
+
+	conn, err = OpenConnection(db)
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.False(t, tableExists(t, conn, name))
+}
+
+func TestTempTableNameCollision(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	result, err := conn.Query("CREATE NODE TABLE taken(id INT64, PRIMARY KEY(id));")
+	assert.Nil(t, err)
+	result.Close()
+	names := []string{"taken", "taken", "free"}
+	newTempTableName = func(prefix string) (string, error) {
+		name := names[0]
+		names = names[1:]
+		return name, nil
+	}
+	defer func() { newTempTableName = tempTableName }()
+	name, cleanup, err := conn.TempTable(tempTableSpec)
+	assert.Nil(t, err)
+	assert.Equal(t, "free", name)
+	cleanup()
+
+	names = []string{"taken", "taken", "taken", "taken", "taken"}
+	_, _, err = conn.TempTable(tempTableSpec)
+	assert.ErrorContains(t, err, "already exists")
+}
+
+func TestTempTableRolledBack(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	result, err := conn.Query("BEGIN TRANSACTION;")
+	assert.Nil(t, err)
+	result.Close()
+	name, cleanup, err := conn.TempTable(tempTableSpec)
+	assert.Nil(t, err)
+	result, err = conn.Query("ROLLBACK;")
+	assert.Nil(t, err)
+	result.Close()
+	assert.False(t, tableExists(t, conn, name))
+	cleanup()
+	conn.tempTablesMutex.Lock()
+	assert.Len(t, conn.tempTables, 0)
+	conn.tempTablesMutex.Unlock()
+}
+
+func TestTempTableInvalidSpec(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	_, _, err := conn.TempTable(NodeTableSpec{})
+	assert.NotNil(t, err)
+	_, _, err = conn.TempTable(NodeTableSpec{Columns: []ColumnSpec{{Name: "id", Type: "INT64"}}, PrimaryKey: "missing"})
+	assert.ErrorContains(t, err, "primary key missing")
+}
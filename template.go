@@ -0,0 +1,145 @@
+package lbug
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Template is a query whose parameters are checked against a declared list of
+// placeholders, so that a misspelled parameter, e.g. $userid for $user_id,
+// fails when the template is created, typically at package initialization,
+// instead of when the query runs. Templates are immutable and may be used
+// concurrently.
+type Template struct {
+	query  string
+	params []string
+}
+
+// NewTemplate returns a template of the query with the declared placeholders,
+// given without their "$" prefix. It returns an error if the placeholders are
+// not exactly the parameters used by the query. The parameters are found by
+// scanning the query, skipping string literals, escaped identifiers and
+// comments.
+func NewTemplate(query string, params ...string) (*Template, error) {
+	declared := make(map[string]bool, len(params))
+	for _, name := range params {
+		name = strings.TrimPrefix(name, "$")
+		if declared[name] {
+			return nil, fmt.Errorf("template declares placeholder $%s more than once", name)
+		}
+		declared[name] = true
+	}
+	used := queryParameters(query)
+	var undeclared, unused []string
+	for name := range used {
+		if !declared[name] {
+			undeclared = append(undeclared, "$"+name)
+		}
+	}
+	for name := range declared {
+		if !used[name] {
+			unused = append(unused, "$"+name)
+		}
+	}
+	if err := placeholderMismatch("the query uses undeclared placeholders", undeclared, "the template declares unused placeholders", unused); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(declared))
+	for name := range declared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &Template{query: query, params: names}, nil
+}
+
+// MustTemplate is like NewTemplate but panics if the placeholders do not
+// match the query. It simplifies the initialization of global variables
+// holding templates.
+func MustTemplate(query string, params ...string) *Template {
+	tmpl, err := NewTemplate(query, params...)
+	if err != nil {
+		panic(err)
+	}
+	return tmpl
+}
+
+// String returns the query of the template.
+func (tmpl *Template) String() string {
+	return tmpl.query
+}
+
+// Params returns the placeholders of the template, sorted, without their "$"
+// prefix.
+func (tmpl *Template) Params() []string {
+	return append([]string(nil), tmpl.params...)
+}
+
+// Query executes the template on the connection with the arguments, which
+// must have exactly one value per placeholder. Missing and unknown arguments
+// are reported before the query is prepared. A template without placeholders
+// is executed like Connection.Query, otherwise it is prepared and executed
+// with the arguments.
+func (tmpl *Template) Query(conn *Connection, args map[string]any) (*QueryResult, error) {
+	if err := tmpl.checkArgs(args); err != nil {
+		return nil, err
+	}
+	if len(tmpl.params) == 0 {
+		return conn.Query(tmpl.query)
+	}
+	statement, err := conn.Prepare(tmpl.query)
+	// The result is complete when Execute returns, so the statement is no
+	// longer needed.
+	defer statement.Close()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Execute(statement, args)
+}
+
+// checkArgs returns an error if the arguments do not match the placeholders.
+func (tmpl *Template) checkArgs(args map[string]any) error {
+	var missing, unknown []string
+	for _, name := range tmpl.params {
+		if _, ok := args[name]; !ok {
+			missing = append(missing, "$"+name)
+		}
+	}
+	for name := range args {
+		if _, found := sort.Find(len(tmpl.params), func(i int) int { return strings.Compare(name, tmpl.params[i]) }); !found {
+			unknown = append(unknown, "$"+name)
+		}
+	}
+	return placeholderMismatch("missing arguments", missing, "unknown arguments", unknown)
+}
+
+// placeholderMismatch returns an error listing the sorted names of both
+// kinds of mismatch, or nil if there is none.
+func placeholderMismatch(firstKind string, first []string, secondKind string, second []string) error {
+	var parts []string
+	for _, mismatch := range []struct {
+		kind  string
+		names []string
+	}{{firstKind, first}, {secondKind, second}} {
+		if len(mismatch.names) > 0 {
+			sort.Strings(mismatch.names)
+			parts = append(parts, mismatch.kind+" "+strings.Join(mismatch.names, ", "))
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return fmt.Errorf("placeholders do not match: %s", strings.Join(parts, "; "))
+}
+
+// queryParameters returns the names of the parameters used by the query,
+// without their "$" prefix.
+func queryParameters(query string) map[string]bool {
+	params := make(map[string]bool)
+	for _, token := range queryKeywords(query) {
+		if name, ok := strings.CutPrefix(token, "$"); ok {
+			params[name] = true
+		}
+	}
+	return params
+}
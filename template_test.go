@@ -0,0 +1,64 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryParameters(t *testing.T) {
+	query := "MATCH (n:person) WHERE n.id = $id AND n.name <> '$quoted' AND n.`$escaped` = $Name_2 // $comment\n" +
+		"/* $block */ RETURN n, $id, \"$double \\\" $still\";"
+	assert.Equal(t, map[string]bool{"id": true, "Name_2": true}, queryParameters(query))
+	assert.Empty(t, queryParameters("RETURN '$' + 1;"))
+	// Parameters are not mistaken for keywords.
+	assert.Equal(t, orderingUnordered, detectOrdering("MATCH (n) WHERE n.x = $order RETURN n;"))
+}
+
+func TestNewTemplate(t *testing.T) {
+	tmpl, err := NewTemplate("MATCH (n:person) WHERE n.id = $id AND n.age > $min_age RETURN n;", "min_age", "$id")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "min_age"}, tmpl.Params())
+	assert.Equal(t, "MATCH (n:person) WHERE n.id = $id AND n.age > $min_age RETURN n;", tmpl.String())
+
+	_, err = NewTemplate("MATCH (n:person) WHERE n.id = $user_id RETURN n;", "userid")
+	assert.ErrorContains(t, err, "placeholders do not match: the query uses undeclared placeholders $user_id; the template declares unused placeholders $userid")
+	_, err = NewTemplate("RETURN $a, $b;", "a")
+	assert.ErrorContains(t, err, "placeholders do not match: the query uses undeclared placeholders $b")
+	_, err = NewTemplate("RETURN $a;", "a", "a")
+	assert.ErrorContains(t, err, "template declares placeholder $a more than once")
+	// A placeholder in a string literal is not a parameter.
+	_, err = NewTemplate("RETURN '$a';", "a")
+	assert.ErrorContains(t, err, "the template declares unused placeholders $a")
+
+	assert.NotNil(t, MustTemplate("RETURN 1;"))
+	assert.Panics(t, func() { MustTemplate("RETURN $a;") })
+}
+
+func TestTemplateCheckArgs(t *testing.T) {
+	tmpl := MustTemplate("MATCH (n:person) WHERE n.id = $id AND n.age > $age RETURN n;", "id", "age")
+	assert.Nil(t, tmpl.checkArgs(map[string]any{"id": 1, "age": nil}))
+	// The arguments are checked before the connection is used.
+	_, err := tmpl.Query(nil, map[string]any{"id": 1, "agee": 2, "extra": 3})
+	assert.ErrorContains(t, err, "placeholders do not match: missing arguments $age; unknown arguments $agee, $extra")
+	_, err = tmpl.Query(nil, nil)
+	assert.ErrorContains(t, err, "missing arguments $age, $id")
+}
+
+func TestTemplateQuery(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	tmpl := MustTemplate("MATCH (a:person) WHERE a.age > $age RETURN a.fName ORDER BY a.fName LIMIT $limit;", "age", "limit")
+	result, err := tmpl.Query(conn, map[string]any{"age": int64(40), "limit": int64(1)})
+	assert.Nil(t, err)
+	defer result.Close()
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+	defer tuple.Close()
+	values, err := tuple.GetAsSlice()
+	assert.Nil(t, err)
+	assert.Equal(t, []any{"Carol"}, values)
+
+	result, err = MustTemplate("RETURN 1;").Query(conn, nil)
+	assert.Nil(t, err)
+	result.Close()
+}
@@ -5,45 +5,45 @@ package lbug
 import "C"
 
 import (
+	"fmt"
 	"math"
 	"time"
 )
 
-// unixEpoch returns the Unix epoch time.
-func unixEpoch() time.Time {
-	return time.Unix(0, 0)
+// timeToLbugDate converts a time.Time at midnight UTC to a lbug_date_t. It
+// returns an error matching ErrTimeOutOfRange if the number of days since the
+// epoch does not fit in a DATE.
+func timeToLbugDate(inputTime time.Time) (C.lbug_date_t, error) {
+	days := inputTime.Unix() / secondsPerDay
+	if inputTime.Unix()%secondsPerDay < 0 {
+		days--
+	}
+	if days < math.MinInt32 || days > math.MaxInt32 {
+		return C.lbug_date_t{}, fmt.Errorf("%w: date %s is outside the range of DATE", ErrTimeOutOfRange, inputTime.Format(time.DateOnly))
+	}
+	return C.lbug_date_t{days: C.int32_t(days)}, nil
 }
 
-// timeToLbugDate converts a time.Time to a lbug_date_t.
-func timeToLbugDate(inputTime time.Time) C.lbug_date_t {
-	diff := inputTime.Sub(unixEpoch())
-	diffDays := math.Floor(diff.Hours() / 24)
-	cLbugDate := C.lbug_date_t{}
-	cLbugDate.days = C.int32_t(diffDays)
-	return cLbugDate
+// timeToLbugTimestamp converts a time.Time to a lbug_timestamp_t. It returns
+// an error matching ErrTimeOutOfRange if the number of microseconds since the
+// epoch does not fit in an int64.
+func timeToLbugTimestamp(inputTime time.Time) (C.lbug_timestamp_t, error) {
+	if inputTime.Before(minTimestamp) || inputTime.After(maxTimestamp) {
+		return C.lbug_timestamp_t{}, fmt.Errorf("%w: time %s is outside the range of TIMESTAMP, %s to %s",
+			ErrTimeOutOfRange, inputTime.UTC().Format(time.RFC3339Nano), minTimestamp.Format(time.RFC3339Nano), maxTimestamp.Format(time.RFC3339Nano))
+	}
+	return C.lbug_timestamp_t{value: C.int64_t(inputTime.UnixMicro())}, nil
 }
 
-// lbugDateToTime converts a lbug_date_t to a time.Time in UTC.
-func lbugDateToTime(cLbugDate C.lbug_date_t) time.Time {
-	diff := time.Duration(cLbugDate.days) * 24 * time.Hour
-	return unixEpoch().UTC().Add(diff)
-}
-
-// timeToLbugTimestamp converts a time.Time to a lbug_timestamp_t.
-func timeToLbugTimestamp(inputTime time.Time) C.lbug_timestamp_t {
-	nanoseconds := inputTime.UnixNano()
-	microseconds := nanoseconds / 1000
-	cLbugTime := C.lbug_timestamp_t{}
-	cLbugTime.value = C.int64_t(microseconds)
-	return cLbugTime
-}
-
-// timeToLbugTimestampNs converts a time.Time to a lbug_timestamp_ns_t.
-func timeToLbugTimestampNs(inputTime time.Time) C.lbug_timestamp_ns_t {
-	nanoseconds := inputTime.UnixNano()
-	cLbugTime := C.lbug_timestamp_ns_t{}
-	cLbugTime.value = C.int64_t(nanoseconds)
-	return cLbugTime
+// timeToLbugTimestampNs converts a time.Time to a lbug_timestamp_ns_t. It
+// returns an error matching ErrTimeOutOfRange if the number of nanoseconds
+// since the epoch does not fit in an int64.
+func timeToLbugTimestampNs(inputTime time.Time) (C.lbug_timestamp_ns_t, error) {
+	if inputTime.Before(minTimestampNs) || inputTime.After(maxTimestampNs) {
+		return C.lbug_timestamp_ns_t{}, fmt.Errorf("%w: time %s has nanoseconds and is outside the range of TIMESTAMP_NS, %s to %s; truncate it to microseconds to bind it as a TIMESTAMP",
+			ErrTimeOutOfRange, inputTime.UTC().Format(time.RFC3339Nano), minTimestampNs.Format(time.RFC3339Nano), maxTimestampNs.Format(time.RFC3339Nano))
+	}
+	return C.lbug_timestamp_ns_t{value: C.int64_t(inputTime.UnixNano())}, nil
 }
 
 // timeHasNanoseconds returns true if the time.Time has non-zero nanoseconds.
@@ -60,6 +60,191 @@ func durationToLbugInterval(inputDuration time.Duration) C.lbug_interval_t {
 	return cLbugInterval
 }
 
+// Date is a time.Time bound as a DATE parameter, which is the calendar day of
+// the time in its location. DATE values are read back as a time.Time at
+// midnight UTC.
+type Date time.Time
+
+// day returns midnight UTC of the calendar day of the date.
+func (date Date) day() time.Time {
+	year, month, day := time.Time(date).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// TimestampTZ is a time.Time bound as a TIMESTAMP_TZ parameter. A time.Time
+// is bound as a TIMESTAMP, which has no time zone; both hold the instant of
+// the time, and the location of the time is not stored.
+type TimestampTZ time.Time
+
+const secondsPerDay = 24 * 60 * 60
+
+var (
+	// minTimestamp and maxTimestamp are the times whose numbers of
+	// microseconds since the epoch are the limits of an int64, the range of
+	// TIMESTAMP and TIMESTAMP_TZ values.
+	minTimestamp = time.UnixMicro(math.MinInt64).UTC()
+	maxTimestamp = time.UnixMicro(math.MaxInt64).UTC()
+	// minTimestampNs and maxTimestampNs are the limits of TIMESTAMP_NS.
+	minTimestampNs = time.Unix(0, math.MinInt64).UTC()
+	maxTimestampNs = time.Unix(0, math.MaxInt64).UTC()
+	// minTime and maxTime are the limits of the times decoded as time.Time,
+	// the years 0 to 9999 that time.Time formats as RFC 3339 and encodes as
+	// JSON.
+	minTime = time.Date(0, time.January, 1, 0, 0, 0, 0, time.UTC)
+	maxTime = time.Date(9999, time.December, 31, 23, 59, 59, 999999999, time.UTC)
+)
+
+// TimeRangePolicy controls how DATE and TIMESTAMP values outside the years 0
+// to 9999 are converted to Go values. Such values are exact in the engine and
+// in time.Time, but time.Time does not format them as RFC 3339 nor encode
+// them as JSON, and a value close to the limits of the engine is usually a
+// sentinel or a corrupted value rather than a date. Both the engine and
+// time.Time use the proleptic Gregorian calendar, so dates before its
+// adoption in 1582 are converted exactly and are not out of range.
+type TimeRangePolicy int
+
+const (
+	// TimeRangeStrict fails the conversion of the value with a
+	// *TimeRangeError matching ErrTimeOutOfRange. This is the default.
+	TimeRangeStrict TimeRangePolicy = iota
+	// TimeRangeLenient converts the value to a RawTime without an error.
+	TimeRangeLenient
+)
+
+// RawTime is the Go value of a DATE or TIMESTAMP value outside the years 0 to
+// 9999 when the TimeRangePolicy is TimeRangeLenient, and the value attached to
+// the TimeRangeError of its conversion otherwise.
+type RawTime struct {
+	// Type is the logical type of the value: DATE, TIMESTAMP, TIMESTAMP_TZ,
+	// TIMESTAMP_NS, TIMESTAMP_MS or TIMESTAMP_SEC.
+	Type string
+	// Value is the number of days since the epoch of a DATE, and the number of
+	// units of the type since the epoch otherwise, e.g. microseconds for a
+	// TIMESTAMP.
+	Value int64
+}
+
+// Unit returns the duration of the unit of Value.
+func (raw RawTime) Unit() time.Duration {
+	switch raw.Type {
+	case "DATE":
+		return 24 * time.Hour
+	case "TIMESTAMP_NS":
+		return time.Nanosecond
+	case "TIMESTAMP_MS":
+		return time.Millisecond
+	case "TIMESTAMP_SEC":
+		return time.Second
+	default:
+		return time.Microsecond
+	}
+}
+
+// unitName returns the plural name of the unit of Value.
+func (raw RawTime) unitName() string {
+	switch raw.Unit() {
+	case 24 * time.Hour:
+		return "days"
+	case time.Nanosecond:
+		return "nanoseconds"
+	case time.Millisecond:
+		return "milliseconds"
+	case time.Second:
+		return "seconds"
+	default:
+		return "microseconds"
+	}
+}
+
+// Time returns the instant of the value in UTC. Unlike the time.Time decoded
+// from values in the years 0 to 9999, it may be outside the range that
+// time.Time formats as RFC 3339.
+func (raw RawTime) Time() time.Time {
+	switch raw.Type {
+	case "DATE":
+		return time.Unix(raw.Value*secondsPerDay, 0).UTC()
+	case "TIMESTAMP_NS":
+		return time.Unix(0, raw.Value).UTC()
+	case "TIMESTAMP_MS":
+		return time.UnixMilli(raw.Value).UTC()
+	case "TIMESTAMP_SEC":
+		return time.Unix(raw.Value, 0).UTC()
+	default:
+		return time.UnixMicro(raw.Value).UTC()
+	}
+}
+
+// timeValue converts the raw value to a time.Time, in UTC for a DATE and in
+// the local time zone for a TIMESTAMP, according to the time range policy of
+// the converter.
+func (converter *valueConverter) timeValue(raw RawTime) (any, error) {
+	value := raw.Time()
+	if value.Before(minTime) || value.After(maxTime) {
+		if converter != nil && converter.timeRange == TimeRangeLenient {
+			return raw, nil
+		}
+		return nil, &TimeRangeError{Raw: raw}
+	}
+	if raw.Type == "DATE" {
+		return value, nil
+	}
+	return value.Local(), nil
+}
+
+// Interval represents an INTERVAL value in Lbug. An interval has separate
+// months, days and microseconds components.
+type Interval struct {
+	Months int32
+	Days   int32
+	Micros int64
+}
+
+// String returns the string representation of the Interval.
+func (interval Interval) String() string {
+	return fmt.Sprintf("%d months %d days %d micros", interval.Months, interval.Days, interval.Micros)
+}
+
+const (
+	microsPerDay      = int64(24 * time.Hour / time.Microsecond)
+	maxDurationMicros = math.MaxInt64 / int64(time.Microsecond)
+	minDurationMicros = math.MinInt64 / int64(time.Microsecond)
+)
+
+// ToDuration converts the Interval to a time.Duration. It returns an
+// IntervalConversionError matching ErrLossyIntervalConversion if the interval
+// has a months component, and an IntervalConversionError with Overflow set if
+// the interval does not fit in a time.Duration (about 292 years).
+func (interval Interval) ToDuration() (time.Duration, error) {
+	if interval.Months != 0 {
+		return 0, &IntervalConversionError{Interval: interval}
+	}
+	if int64(interval.Days) > maxDurationMicros/microsPerDay ||
+		int64(interval.Days) < minDurationMicros/microsPerDay {
+		return 0, &IntervalConversionError{Interval: interval, Overflow: true}
+	}
+	// The days component is now small enough to be represented in
+	// microseconds, but adding the microseconds component may still overflow.
+	daysMicros := int64(interval.Days) * microsPerDay
+	if (interval.Micros > 0 && daysMicros > math.MaxInt64-interval.Micros) ||
+		(interval.Micros < 0 && daysMicros < math.MinInt64-interval.Micros) {
+		return 0, &IntervalConversionError{Interval: interval, Overflow: true}
+	}
+	totalMicros := daysMicros + interval.Micros
+	if totalMicros > maxDurationMicros || totalMicros < minDurationMicros {
+		return 0, &IntervalConversionError{Interval: interval, Overflow: true}
+	}
+	return time.Duration(totalMicros) * time.Microsecond, nil
+}
+
+// lbugIntervalToInterval converts a lbug_interval_t to an Interval.
+func lbugIntervalToInterval(cLbugInterval C.lbug_interval_t) Interval {
+	return Interval{
+		Months: int32(cLbugInterval.months),
+		Days:   int32(cLbugInterval.days),
+		Micros: int64(cLbugInterval.micros),
+	}
+}
+
 // lbugIntervalToDuration converts a lbug_interval_t to a time.Duration.
 func lbugIntervalToDuration(cLbugInterval C.lbug_interval_t) time.Duration {
 	days := cLbugInterval.days
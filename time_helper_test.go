@@ -0,0 +1,133 @@
+package lbug
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeValueRange(t *testing.T) {
+	strict := &valueConverter{}
+	lenient := &valueConverter{timeRange: TimeRangeLenient}
+	minDays := minTime.Unix() / secondsPerDay
+	maxDays := time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC).Unix() / secondsPerDay
+	cases := []struct {
+		name string
+		raw  RawTime
+		want time.Time
+	}{
+		{"min date", RawTime{Type: "DATE", Value: minDays}, minTime},
+		{"max date", RawTime{Type: "DATE", Value: maxDays}, time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)},
+		{"min timestamp", RawTime{Type: "TIMESTAMP", Value: minTime.UnixMicro()}, minTime},
+		{"max timestamp", RawTime{Type: "TIMESTAMP", Value: maxTime.UnixMicro()}, maxTime.Truncate(time.Microsecond)},
+		{"min timestamp_sec", RawTime{Type: "TIMESTAMP_SEC", Value: minTime.Unix()}, minTime},
+		{"max timestamp_ms", RawTime{Type: "TIMESTAMP_MS", Value: maxTime.UnixMilli()}, maxTime.Truncate(time.Millisecond)},
+		{"before the Gregorian calendar", RawTime{Type: "DATE", Value: time.Date(1500, time.March, 1, 0, 0, 0, 0, time.UTC).Unix() / secondsPerDay}, time.Date(1500, time.March, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			value, err := strict.timeValue(c.raw)
+			assert.Nil(t, err)
+			got, ok := value.(time.Time)
+			assert.True(t, ok)
+			assert.True(t, c.want.Equal(got), got)
+		})
+	}
+
+	outOfRange := []RawTime{
+		{Type: "DATE", Value: minDays - 1},
+		{Type: "DATE", Value: maxDays + 1},
+		{Type: "DATE", Value: math.MaxInt32},
+		{Type: "TIMESTAMP", Value: minTime.UnixMicro() - 1},
+		{Type: "TIMESTAMP", Value: maxTime.UnixMicro() + 1},
+		{Type: "TIMESTAMP_TZ", Value: math.MinInt64},
+		{Type: "TIMESTAMP", Value: math.MaxInt64},
+		{Type: "TIMESTAMP_SEC", Value: maxTime.Unix() + 1},
+	}
+	for _, raw := range outOfRange {
+		_, err := strict.timeValue(raw)
+		assert.ErrorIs(t, err, ErrTimeOutOfRange)
+		var rangeErr *TimeRangeError
+		if assert.ErrorAs(t, err, &rangeErr) {
+			assert.Equal(t, raw, rangeErr.Raw)
+		}
+		// A nil converter is strict.
+		_, err = (*valueConverter)(nil).timeValue(raw)
+		assert.ErrorIs(t, err, ErrTimeOutOfRange)
+		value, err := lenient.timeValue(raw)
+		assert.Nil(t, err)
+		assert.Equal(t, raw, value)
+	}
+	_, err := strict.timeValue(RawTime{Type: "DATE", Value: maxDays + 1})
+	assert.ErrorContains(t, err, "DATE value of 2932897 days since the epoch is outside the years 0 to 9999 converted to time.Time")
+}
+
+func TestRawTime(t *testing.T) {
+	raw := RawTime{Type: "TIMESTAMP", Value: math.MaxInt64}
+	assert.Equal(t, time.Microsecond, raw.Unit())
+	assert.True(t, maxTimestamp.Equal(raw.Time()))
+	assert.Equal(t, 294247, raw.Time().Year())
+	raw = RawTime{Type: "DATE", Value: -1}
+	assert.Equal(t, 24*time.Hour, raw.Unit())
+	assert.Equal(t, time.Date(1969, time.December, 31, 0, 0, 0, 0, time.UTC), raw.Time())
+	raw = RawTime{Type: "TIMESTAMP_NS", Value: math.MinInt64}
+	assert.True(t, minTimestampNs.Equal(raw.Time()))
+}
+
+func TestTimeToLbugTimestampRange(t *testing.T) {
+	timestamp, err := timeToLbugTimestamp(maxTimestamp)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(math.MaxInt64), int64(timestamp.value))
+	timestamp, err = timeToLbugTimestamp(minTimestamp)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(math.MinInt64), int64(timestamp.value))
+	_, err = timeToLbugTimestamp(maxTimestamp.Add(time.Microsecond))
+	assert.ErrorIs(t, err, ErrTimeOutOfRange)
+	_, err = timeToLbugTimestamp(minTimestamp.Add(-time.Microsecond))
+	assert.ErrorIs(t, err, ErrTimeOutOfRange)
+	assert.ErrorContains(t, err, "is outside the range of TIMESTAMP")
+	// Times beyond the range of time.UnixNano are bound exactly.
+	old := time.Date(1500, time.March, 1, 12, 0, 0, 0, time.UTC)
+	timestamp, err = timeToLbugTimestamp(old)
+	assert.Nil(t, err)
+	assert.Equal(t, old.UnixMicro(), int64(timestamp.value))
+
+	timestampNs, err := timeToLbugTimestampNs(maxTimestampNs)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(math.MaxInt64), int64(timestampNs.value))
+	timestampNs, err = timeToLbugTimestampNs(minTimestampNs)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(math.MinInt64), int64(timestampNs.value))
+	_, err = timeToLbugTimestampNs(maxTimestampNs.Add(time.Nanosecond))
+	assert.ErrorIs(t, err, ErrTimeOutOfRange)
+	_, err = timeToLbugTimestampNs(minTimestampNs.Add(-time.Nanosecond))
+	assert.ErrorIs(t, err, ErrTimeOutOfRange)
+	assert.ErrorContains(t, err, "outside the range of TIMESTAMP_NS")
+}
+
+func TestTimeToLbugDateRange(t *testing.T) {
+	maxDate := time.Unix(math.MaxInt32*secondsPerDay, 0).UTC()
+	minDate := time.Unix(math.MinInt32*secondsPerDay, 0).UTC()
+	date, err := timeToLbugDate(maxDate)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(math.MaxInt32), int32(date.days))
+	date, err = timeToLbugDate(minDate)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(math.MinInt32), int32(date.days))
+	_, err = timeToLbugDate(maxDate.AddDate(0, 0, 1))
+	assert.ErrorIs(t, err, ErrTimeOutOfRange)
+	_, err = timeToLbugDate(minDate.AddDate(0, 0, -1))
+	assert.ErrorIs(t, err, ErrTimeOutOfRange)
+	// Dates before the epoch and the range of time.Duration round-trip.
+	for _, day := range []time.Time{
+		time.Date(1969, time.December, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(1500, time.March, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC),
+	} {
+		date, err := timeToLbugDate(day)
+		assert.Nil(t, err)
+		assert.Equal(t, day, RawTime{Type: "DATE", Value: int64(date.days)}.Time())
+	}
+}
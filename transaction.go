@@ -0,0 +1,304 @@
+package lbug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// TransactionOptions configures Connection.BeginTransactionWithOptions.
+type TransactionOptions struct {
+	// ReadOnly begins a read-only transaction, in which the statements that
+	// write fail. Unlike write transactions, read-only transactions do not
+	// wait for each other.
+	ReadOnly bool
+}
+
+// Transaction runs statements on a connection in an explicit transaction,
+// begun by BeginTransaction, whose writes are committed together by Commit or
+// discarded by Rollback. Its methods are safe for concurrent use, and run one
+// at a time. The connection must not be used directly until the transaction
+// ends, and the statements run in the transaction must not begin, commit or
+// roll back transactions themselves.
+type Transaction struct {
+	conn     *Connection
+	readOnly bool
+	mutex    sync.Mutex
+	// err is returned by the uses of the transaction once it has ended.
+	err error
+	// release gives the writer of the database back, for write
+	// transactions.
+	release func()
+
+	// summaryMutex guards summary, which the statements run on the
+	// connection of the transaction add to until it ends, see Summary.
+	summaryMutex sync.Mutex
+	summary      TransactionSummary
+	ended        bool
+}
+
+// TransactionSummary is the accumulated WriteSummary of the statements run
+// in a Transaction, see Transaction.Summary.
+type TransactionSummary struct {
+	WriteSummary
+	// Statements is the number of statements summed.
+	Statements uint64
+	// RolledBack is true once the transaction is rolled back, in which case
+	// the summary is otherwise zero.
+	RolledBack bool
+}
+
+// BeginTransaction begins a write transaction on the connection, see
+// BeginTransactionWithOptions.
+func (conn *Connection) BeginTransaction(ctx context.Context) (*Transaction, error) {
+	return conn.BeginTransactionWithOptions(ctx, TransactionOptions{})
+}
+
+// BeginTransactionWithOptions begins a transaction on the connection. The
+// write transactions of the connections of a database are run one at a time:
+// BeginTransactionWithOptions waits for the write transaction in progress to
+// end, or returns the error of the context if it is done first. It fails if
+// the connection is in an explicit transaction already.
+//
+// The transaction must be ended with Commit, Rollback or Close. Closing the
+// connection rolls it back.
+func (conn *Connection) BeginTransactionWithOptions(ctx context.Context, opts TransactionOptions) (*Transaction, error) {
+	conn.snapshotMutex.Lock()
+	inTransaction := conn.inTransaction || conn.transaction != nil
+	conn.snapshotMutex.Unlock()
+	if inTransaction {
+		return nil, errors.New("BeginTransaction cannot run in an explicit transaction")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	tx := &Transaction{conn: conn, readOnly: opts.ReadOnly, release: func() {}}
+	begin := "BEGIN TRANSACTION;"
+	if opts.ReadOnly {
+		begin = "BEGIN TRANSACTION READ ONLY;"
+	} else {
+		writer := conn.database.writer
+		select {
+		case writer <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		var once sync.Once
+		tx.release = func() { once.Do(func() { <-writer }) }
+	}
+	if err := runStatement(conn, begin); err != nil {
+		tx.release()
+		return nil, err
+	}
+	conn.snapshotMutex.Lock()
+	conn.transaction = tx
+	conn.snapshotMutex.Unlock()
+	return tx, nil
+}
+
+// Connection returns the connection of the transaction.
+func (tx *Transaction) Connection() *Connection {
+	return tx.conn
+}
+
+// ReadOnly returns true for a read-only transaction.
+func (tx *Transaction) ReadOnly() bool {
+	return tx.readOnly
+}
+
+// Summary returns the summary of the statements run successfully in the
+// transaction so far: their timings and numbers of tuples summed, like the
+// WriteSummary of Exec for a single statement. It includes the statements
+// run on the connection of the transaction outside of its methods, e.g. with
+// Exec on a statement returned by Prepare or by a batch loader, and counts
+// the statements after the first of a query as their results are read with
+// NextQueryResult. The engine does not report the numbers of nodes and
+// relationships written. Once the transaction is rolled back, including when
+// Commit fails or the connection is closed, the summary is zero but for
+// RolledBack.
+func (tx *Transaction) Summary() TransactionSummary {
+	tx.summaryMutex.Lock()
+	defer tx.summaryMutex.Unlock()
+	return tx.summary
+}
+
+// summarize adds the summary of a statement run in the transaction, unless
+// the transaction has ended.
+func (tx *Transaction) summarize(summary WriteSummary) {
+	tx.summaryMutex.Lock()
+	defer tx.summaryMutex.Unlock()
+	if tx.ended {
+		return
+	}
+	tx.summary.CompilingTime += summary.CompilingTime
+	tx.summary.ExecutionTime += summary.ExecutionTime
+	tx.summary.NumTuples += summary.NumTuples
+	tx.summary.Statements++
+}
+
+// end stops the summary of the transaction, clearing it if the transaction
+// is rolled back, which happens after Commit stops it if the commit fails.
+func (tx *Transaction) end(rolledBack bool) {
+	tx.summaryMutex.Lock()
+	defer tx.summaryMutex.Unlock()
+	tx.ended = true
+	if rolledBack {
+		tx.summary = TransactionSummary{RolledBack: true}
+	}
+}
+
+// use runs the function on the connection of the transaction, unless it has
+// ended.
+func (tx *Transaction) use(f func(conn *Connection) error) error {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	if tx.err != nil {
+		return tx.err
+	}
+	return f(tx.conn)
+}
+
+// Query runs the query in the transaction, see Connection.Query.
+func (tx *Transaction) Query(query string) (*QueryResult, error) {
+	return tx.QueryWithContext(context.Background(), query)
+}
+
+// QueryWithContext runs the query in the transaction, see
+// Connection.QueryWithContext.
+func (tx *Transaction) QueryWithContext(ctx context.Context, query string) (*QueryResult, error) {
+	var result *QueryResult
+	err := tx.use(func(conn *Connection) error {
+		if err := checkTransactionControl(query); err != nil {
+			return err
+		}
+		var err error
+		result, err = conn.QueryWithContext(ctx, query)
+		return err
+	})
+	return result, err
+}
+
+// Prepare prepares the query on the connection of the transaction, to be run
+// with Execute.
+func (tx *Transaction) Prepare(query string) (*PreparedStatement, error) {
+	var statement *PreparedStatement
+	err := tx.use(func(conn *Connection) error {
+		if err := checkTransactionControl(query); err != nil {
+			return err
+		}
+		var err error
+		statement, err = conn.Prepare(query)
+		return err
+	})
+	return statement, err
+}
+
+// Execute runs the prepared statement in the transaction, see
+// Connection.Execute.
+func (tx *Transaction) Execute(statement *PreparedStatement, args map[string]any) (*QueryResult, error) {
+	return tx.ExecuteWithContext(context.Background(), statement, args)
+}
+
+// ExecuteWithContext runs the prepared statement in the transaction, see
+// Connection.ExecuteWithContext.
+func (tx *Transaction) ExecuteWithContext(ctx context.Context, statement *PreparedStatement, args map[string]any) (*QueryResult, error) {
+	var result *QueryResult
+	err := tx.use(func(conn *Connection) error {
+		if err := checkTransactionControl(statement.query); err != nil {
+			return err
+		}
+		var err error
+		result, err = conn.ExecuteWithContext(ctx, statement, args)
+		return err
+	})
+	return result, err
+}
+
+// Commit commits the transaction, after which its methods fail with an error
+// matching ErrClosed. If the commit fails, the transaction is rolled back.
+func (tx *Transaction) Commit() error {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	if tx.err != nil {
+		return tx.err
+	}
+	defer tx.detach()
+	tx.err = &closedError{"the transaction is committed"}
+	tx.end(false)
+	if err := runStatement(tx.conn, "COMMIT;"); err != nil {
+		tx.err = &closedError{"the transaction is rolled back"}
+		tx.end(true)
+		runStatement(tx.conn, "ROLLBACK;")
+		return err
+	}
+	return nil
+}
+
+// Rollback rolls the transaction back, after which its methods fail with an
+// error matching ErrClosed.
+func (tx *Transaction) Rollback() error {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	if tx.err != nil {
+		return tx.err
+	}
+	return tx.rollback()
+}
+
+// Close rolls the transaction back unless it has ended already, in which
+// case it does nothing. It is typically deferred after BeginTransaction, so
+// that the transaction is rolled back on the paths that do not commit it.
+func (tx *Transaction) Close() error {
+	tx.mutex.Lock()
+	defer tx.mutex.Unlock()
+	if tx.err != nil {
+		return nil
+	}
+	return tx.rollback()
+}
+
+// rollback rolls the transaction back and gives the writer of the database
+// back. mutex must be held.
+func (tx *Transaction) rollback() error {
+	defer tx.detach()
+	tx.err = &closedError{"the transaction is rolled back"}
+	tx.end(true)
+	return runStatement(tx.conn, "ROLLBACK;")
+}
+
+// detach removes the transaction from its connection and gives the writer of
+// the database back.
+func (tx *Transaction) detach() {
+	tx.conn.snapshotMutex.Lock()
+	if tx.conn.transaction == tx {
+		tx.conn.transaction = nil
+	}
+	tx.conn.snapshotMutex.Unlock()
+	tx.release()
+}
+
+// endTransaction gives the writer held by the transaction of the connection
+// back when the connection is closed, which rolls the transaction back.
+func (conn *Connection) endTransaction() {
+	conn.snapshotMutex.Lock()
+	tx := conn.transaction
+	conn.transaction = nil
+	conn.snapshotMutex.Unlock()
+	if tx != nil {
+		tx.end(true)
+		tx.release()
+	}
+}
+
+// checkTransactionControl returns an error if the query begins, commits or
+// rolls back a transaction, which only the methods of Transaction do.
+func checkTransactionControl(query string) error {
+	for _, statement := range splitStatements(queryKeywords(query)) {
+		switch statement[0] {
+		case "BEGIN", "COMMIT", "ROLLBACK":
+			return fmt.Errorf("cannot run %s in a Transaction: use its Commit and Rollback methods", statement[0])
+		}
+	}
+	return nil
+}
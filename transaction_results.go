@@ -0,0 +1,68 @@
+package lbug
+
+// #include "lbug.h"
+import "C"
+
+import "slices"
+
+// trackTransactionResult remembers a result returned in an explicit
+// transaction, so that the statements that may write in the transaction can
+// be refused while it has tuples left.
+func (conn *Connection) trackTransactionResult(queryResult *QueryResult) {
+	conn.snapshotMutex.Lock()
+	defer conn.snapshotMutex.Unlock()
+	if !conn.inTransaction {
+		return
+	}
+	conn.transactionResults = slices.DeleteFunc(conn.transactionResults, func(result *QueryResult) bool {
+		return result.isClosed
+	})
+	conn.transactionResults = append(conn.transactionResults, queryResult)
+}
+
+// summarizeResultInTransaction adds the summary of a successful result to the
+// summary of the Transaction the connection is in, if any.
+func (conn *Connection) summarizeResultInTransaction(queryResult *QueryResult) {
+	conn.snapshotMutex.Lock()
+	tx := conn.transaction
+	conn.snapshotMutex.Unlock()
+	if tx != nil {
+		tx.summarize(writeSummary(&queryResult.cQueryResult))
+	}
+}
+
+// summarizeInTransaction adds the summary of a statement run with Exec to the
+// summary of the Transaction the connection is in, if any.
+func (conn *Connection) summarizeInTransaction(summary WriteSummary) {
+	conn.snapshotMutex.Lock()
+	tx := conn.transaction
+	conn.snapshotMutex.Unlock()
+	if tx != nil {
+		tx.summarize(summary)
+	}
+}
+
+// checkOpenResults returns an *OpenResultError if the query may write and a
+// result of the explicit transaction of the connection has tuples left.
+// closeMutex of the connection must be held for reading.
+func (conn *Connection) checkOpenResults(query string) error {
+	conn.snapshotMutex.Lock()
+	defer conn.snapshotMutex.Unlock()
+	if !conn.inTransaction || len(conn.transactionResults) == 0 {
+		return nil
+	}
+	if !slices.ContainsFunc(splitStatements(queryKeywords(query)), mayWrite) {
+		return nil
+	}
+	for _, result := range conn.transactionResults {
+		if result.isClosed || !bool(C.lbug_query_result_has_next(&result.cQueryResult)) {
+			continue
+		}
+		return &OpenResultError{
+			Query:   result.query,
+			Fetched: result.numFetched,
+			Rows:    uint64(C.lbug_query_result_get_num_tuples(&result.cQueryResult)),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReadModifyWriteInTransaction updates each node returned by a query
+// while iterating it, the usual read-modify-write loop.
+func TestReadModifyWriteInTransaction(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	update, err := conn.Prepare("MATCH (p:person) WHERE p.ID = $id SET p.age = p.age + 1;")
+	assert.Nil(t, err)
+	defer update.Close()
+	const read = "MATCH (p:person) RETURN p.ID ORDER BY p.ID;"
+
+	mustRun(t, conn, "BEGIN TRANSACTION;")
+	result, err := conn.Query(read)
+	assert.Nil(t, err)
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+	id, err := tuple.GetValue(0)
+	assert.Nil(t, err)
+	tuple.Close()
+	// The write is refused while the result has tuples left, with Execute,
+	// Exec and Query alike.
+	_, err = conn.Execute(update, map[string]any{"id": id})
+	assert.ErrorIs(t, err, ErrOpenResultInTransaction)
+	var openErr *OpenResultError
+	if assert.ErrorAs(t, err, &openErr) {
+		assert.Equal(t, read, openErr.Query)
+		assert.Equal(t, uint64(1), openErr.Fetched)
+		assert.Equal(t, result.GetNumberOfRows(), openErr.Rows)
+	}
+	_, err = update.Exec(map[string]any{"id": id})
+	assert.ErrorIs(t, err, ErrOpenResultInTransaction)
+	_, err = conn.Query("MATCH (p:person) SET p.age = 0;")
+	assert.ErrorIs(t, err, ErrOpenResultInTransaction)
+	// Reads are not restricted.
+	assert.Equal(t, int64(result.GetNumberOfRows()), countPersons(t, conn, nil))
+
+	// Freezing the result reads it fully, and the loop can update each node.
+	frozen, err := result.Freeze()
+	assert.Nil(t, err)
+	for i := 0; i < frozen.Len(); i++ {
+		_, err := update.Exec(map[string]any{"id": frozen.Row(i)[0]})
+		assert.Nil(t, err)
+	}
+	mustRun(t, conn, "COMMIT;")
+
+	// A result that has been fully iterated or closed does not block writes.
+	mustRun(t, conn, "BEGIN TRANSACTION;")
+	result, err = conn.Query(read)
+	assert.Nil(t, err)
+	var ids []any
+	for result.HasNext() {
+		tuple, err := result.Next()
+		assert.Nil(t, err)
+		id, err := tuple.GetValue(0)
+		assert.Nil(t, err)
+		ids = append(ids, id)
+		tuple.Close()
+	}
+	for _, id := range ids {
+		_, err := update.Exec(map[string]any{"id": id})
+		assert.Nil(t, err)
+	}
+	other, err := conn.Query(read)
+	assert.Nil(t, err)
+	other.Close()
+	_, err = update.Exec(map[string]any{"id": ids[0]})
+	assert.Nil(t, err)
+	mustRun(t, conn, "COMMIT;")
+	result.Close()
+
+	// Results of a transaction that has ended no longer block writes.
+	mustRun(t, conn, "BEGIN TRANSACTION;")
+	result, err = conn.Query(read)
+	assert.Nil(t, err)
+	defer result.Close()
+	mustRun(t, conn, "ROLLBACK;")
+	_, err = update.Exec(map[string]any{"id": ids[0]})
+	assert.Nil(t, err)
+	assert.True(t, result.HasNext())
+}
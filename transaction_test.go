@@ -0,0 +1,160 @@
+package lbug
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransaction(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE item(id INT64, PRIMARY KEY(id));")
+
+	tx, err := conn.BeginTransaction(context.Background())
+	assert.Nil(t, err)
+	defer tx.Close()
+	assert.False(t, tx.ReadOnly())
+	assert.Equal(t, conn, tx.Connection())
+	result, err := tx.Query("CREATE (:item {id: 1});")
+	assert.Nil(t, err)
+	result.Close()
+	statement, err := tx.Prepare("CREATE (:item {id: $id});")
+	assert.Nil(t, err)
+	defer statement.Close()
+	result, err = tx.Execute(statement, map[string]any{"id": int64(2)})
+	assert.Nil(t, err)
+	result.Close()
+	_, err = tx.Query("COMMIT;")
+	assert.ErrorContains(t, err, "cannot run COMMIT in a Transaction")
+	_, err = conn.BeginTransaction(context.Background())
+	assert.ErrorContains(t, err, "cannot run in an explicit transaction")
+	assert.Nil(t, tx.Commit())
+	count, err := queryCount(conn, "MATCH (i:item) RETURN count(i);")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), count)
+
+	// The transaction has ended.
+	_, err = tx.Query("RETURN 1;")
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.ErrorIs(t, tx.Commit(), ErrClosed)
+	assert.Nil(t, tx.Close())
+
+	// Close rolls back.
+	tx, err = conn.BeginTransaction(context.Background())
+	assert.Nil(t, err)
+	result, err = tx.Query("CREATE (:item {id: 3});")
+	assert.Nil(t, err)
+	result.Close()
+	assert.Nil(t, tx.Close())
+	assert.ErrorIs(t, tx.Rollback(), ErrClosed)
+	count, err = queryCount(conn, "MATCH (i:item) RETURN count(i);")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), count)
+
+	// Writes fail in a read-only transaction.
+	tx, err = conn.BeginTransactionWithOptions(context.Background(), TransactionOptions{ReadOnly: true})
+	assert.Nil(t, err)
+	assert.True(t, tx.ReadOnly())
+	_, err = tx.Query("CREATE (:item {id: 4});")
+	assert.NotNil(t, err)
+	assert.Nil(t, tx.Rollback())
+}
+
+func TestTransactionSingleWriter(t *testing.T) {
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	defer db.Close()
+	first, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer first.Close()
+	second, err := OpenConnection(db)
+	assert.Nil(t, err)
+	defer second.Close()
+
+	tx, err := first.BeginTransaction(context.Background())
+	assert.Nil(t, err)
+	// The second writer waits for the first one.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = second.BeginTransaction(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// Readers do not.
+	reader, err := second.BeginTransactionWithOptions(context.Background(), TransactionOptions{ReadOnly: true})
+	assert.Nil(t, err)
+	assert.Nil(t, reader.Commit())
+
+	began := make(chan *Transaction)
+	go func() {
+		tx, err := second.BeginTransaction(context.Background())
+		assert.Nil(t, err)
+		began <- tx
+	}()
+	select {
+	case <-began:
+		t.Fatal("the second writer began before the first ended")
+	case <-time.After(20 * time.Millisecond):
+	}
+	assert.Nil(t, tx.Commit())
+	waiting := <-began
+	// Closing the connection rolls the transaction back and lets the next
+	// writer in.
+	second.Close()
+	tx, err = first.BeginTransaction(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Rollback())
+	assert.ErrorIs(t, waiting.Commit(), ErrClosed)
+}
+
+func TestTransactionSummary(t *testing.T) {
+	conn := openCopyTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE item(id INT64, PRIMARY KEY(id));")
+
+	tx, err := conn.BeginTransaction(context.Background())
+	assert.Nil(t, err)
+	defer tx.Close()
+	result, err := tx.Query("UNWIND range(1, 3) AS i CREATE (:item {id: i}) RETURN i;")
+	assert.Nil(t, err)
+	result.Close()
+	statement, err := tx.Prepare("CREATE (:item {id: $id}) RETURN $id;")
+	assert.Nil(t, err)
+	defer statement.Close()
+	result, err = tx.Execute(statement, map[string]any{"id": int64(10)})
+	assert.Nil(t, err)
+	result.Close()
+	// The statements run on the connection outside of the methods of the
+	// transaction are summed too.
+	summary, err := statement.Exec(map[string]any{"id": int64(11)})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), summary.NumTuples)
+	result, err = tx.Query("MATCH (i:item) RETURN i.id; RETURN 1;")
+	assert.Nil(t, err)
+	next, err := result.NextQueryResult()
+	assert.Nil(t, err)
+	next.Close()
+	result.Close()
+	// Failed statements are not summed.
+	_, err = tx.Query("RETURN nothing;")
+	assert.NotNil(t, err)
+
+	assert.Nil(t, tx.Commit())
+	total := tx.Summary()
+	assert.False(t, total.RolledBack)
+	assert.Equal(t, uint64(5), total.Statements)
+	assert.Equal(t, uint64(3+1+1+5+1), total.NumTuples)
+	assert.GreaterOrEqual(t, total.CompilingTime, 0.0)
+	// The statements run after the commit are not summed.
+	mustRun(t, conn, "RETURN 1;")
+	assert.Equal(t, total, tx.Summary())
+
+	// A rolled back transaction reports zeros.
+	tx, err = conn.BeginTransaction(context.Background())
+	assert.Nil(t, err)
+	result, err = tx.Query("CREATE (:item {id: 20}) RETURN 1;")
+	assert.Nil(t, err)
+	result.Close()
+	assert.Equal(t, uint64(1), tx.Summary().Statements)
+	assert.Nil(t, tx.Rollback())
+	assert.Equal(t, TransactionSummary{RolledBack: true}, tx.Summary())
+}
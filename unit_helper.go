@@ -0,0 +1,98 @@
+package lbug
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// byteSizeUnits maps the accepted byte size units (in lower case) to their
+// multipliers. Decimal units (KB, MB, ...) are powers of 1000 and binary units
+// (KiB, MiB, ...) are powers of 1024.
+var byteSizeUnits = map[string]uint64{
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// acceptedByteSizeUnits lists the accepted byte size units for error messages.
+const acceptedByteSizeUnits = "B, KB, MB, GB, TB, KiB, MiB, GiB, TiB"
+
+// maxByteSize is the largest byte size accepted by ParseByteSize, 1024TiB,
+// far beyond any buffer pool or database size, so that a misplaced unit or
+// extra digits are reported instead of being passed on to the engine.
+const maxByteSize = 1 << 50
+
+// acceptedDurationUnits lists the accepted duration units for error messages.
+const acceptedDurationUnits = "ms, s, m, h"
+
+var byteSizePattern = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([A-Za-z]*)$`)
+
+// durationPattern matches the durations whose every number carries one of
+// the accepted duration units.
+var durationPattern = regexp.MustCompile(`^(?:[0-9]+(?:\.[0-9]+)?(?:ms|s|m|h))+$`)
+
+// ParseByteSize parses a human-readable byte size such as "4GiB", "512MB" or
+// "1.5GB" and returns the number of bytes. Units are case-insensitive. A bare
+// number without a unit is interpreted as a number of bytes. Negative values,
+// values that do not resolve to a whole number of bytes, and values above
+// 1024TiB are rejected.
+func ParseByteSize(size string) (uint64, error) {
+	trimmed := strings.TrimSpace(size)
+	matches := byteSizePattern.FindStringSubmatch(trimmed)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid byte size %q: expected a non-negative number followed by one of %s", size, acceptedByteSizeUnits)
+	}
+	multiplier := uint64(1)
+	if matches[2] != "" {
+		var ok bool
+		multiplier, ok = byteSizeUnits[strings.ToLower(matches[2])]
+		if !ok {
+			return 0, fmt.Errorf("invalid byte size %q: unknown unit %q, accepted units are %s", size, matches[2], acceptedByteSizeUnits)
+		}
+	}
+	value, ok := new(big.Rat).SetString(matches[1])
+	if !ok {
+		return 0, fmt.Errorf("invalid byte size %q: malformed number %q", size, matches[1])
+	}
+	value.Mul(value, new(big.Rat).SetUint64(multiplier))
+	if !value.IsInt() {
+		return 0, fmt.Errorf("invalid byte size %q: does not resolve to a whole number of bytes", size)
+	}
+	bytes := value.Num()
+	if !bytes.IsUint64() || bytes.Uint64() > maxByteSize {
+		return 0, fmt.Errorf("invalid byte size %q: exceeds the maximum of 1024TiB", size)
+	}
+	return bytes.Uint64(), nil
+}
+
+// ParseTimeout parses a duration string such as "30s", "1m30s" or "250ms" and
+// returns the timeout in milliseconds. Every number must be followed by one of
+// the units ms, s, m and h, so that "0" and the units finer than a
+// millisecond accepted by time.ParseDuration are rejected, as are negative
+// durations and durations that are not a whole number of milliseconds.
+func ParseTimeout(timeout string) (uint64, error) {
+	trimmed := strings.TrimSpace(timeout)
+	if strings.HasPrefix(trimmed, "-") {
+		return 0, fmt.Errorf("invalid timeout %q: must not be negative", timeout)
+	}
+	if !durationPattern.MatchString(trimmed) {
+		return 0, fmt.Errorf("invalid timeout %q: expected a duration with one of the units %s", timeout, acceptedDurationUnits)
+	}
+	duration, err := time.ParseDuration(trimmed)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q: %w", timeout, err)
+	}
+	if duration%time.Millisecond != 0 {
+		return 0, fmt.Errorf("invalid timeout %q: must be a whole number of milliseconds", timeout)
+	}
+	return uint64(duration / time.Millisecond), nil
+}
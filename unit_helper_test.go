@@ -0,0 +1,101 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]uint64{
+		"1024":    1024,
+		"0":       0,
+		"1B":      1,
+		"1KB":     1000,
+		"1KiB":    1024,
+		"1kib":    1024,
+		"512MB":   512 * 1000 * 1000,
+		"512MiB":  512 * 1024 * 1024,
+		"4GiB":    4 * 1024 * 1024 * 1024,
+		"1.5GB":   1500 * 1000 * 1000,
+		"1.5KiB":  1536,
+		"2TB":     2 * 1000 * 1000 * 1000 * 1000,
+		" 8 GiB ": 8 * 1024 * 1024 * 1024,
+	}
+	for input, expected := range cases {
+		actual, err := ParseByteSize(input)
+		assert.Nil(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+}
+
+func TestParseByteSizeErrors(t *testing.T) {
+	inputs := []string{
+		"",
+		"-1GB",
+		"GB",
+		"4G",
+		"4 gigabytes",
+		"1.5",
+		"0.0001KB",
+		"1e3KB",
+		"99999999TiB",
+		"18446744073709551616",
+		"1025TiB",
+		"1125899906842625",
+	}
+	for _, input := range inputs {
+		_, err := ParseByteSize(input)
+		assert.NotNil(t, err, input)
+	}
+	_, err := ParseByteSize("4G")
+	assert.Contains(t, err.Error(), acceptedByteSizeUnits)
+
+	// The upper bound is inclusive.
+	size, err := ParseByteSize("1024TiB")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1<<50), size)
+	_, err = ParseByteSize("1025TiB")
+	assert.ErrorContains(t, err, "exceeds the maximum of 1024TiB")
+}
+
+func TestParseTimeout(t *testing.T) {
+	cases := map[string]uint64{
+		"0s":     0,
+		"250ms":  250,
+		"30s":    30000,
+		"1m30s":  90000,
+		"1.5s":   1500,
+		"2h":     7200000,
+		" 10s  ": 10000,
+	}
+	for input, expected := range cases {
+		actual, err := ParseTimeout(input)
+		assert.Nil(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+}
+
+func TestParseTimeoutErrors(t *testing.T) {
+	inputs := []string{"", "0", "30", "1m30", "-1s", "1.5ms", "10us", "10µs", "1000000ns", "1.s", "ten seconds"}
+	for _, input := range inputs {
+		_, err := ParseTimeout(input)
+		assert.NotNil(t, err, input)
+	}
+	_, err := ParseTimeout("30")
+	assert.Contains(t, err.Error(), acceptedDurationUnits)
+	_, err = ParseTimeout("10us")
+	assert.Contains(t, err.Error(), acceptedDurationUnits)
+	_, err = ParseTimeout("-1s")
+	assert.ErrorContains(t, err, "must not be negative")
+}
+
+func TestSystemConfigSetSizeStrings(t *testing.T) {
+	config := SystemConfig{}
+	assert.Nil(t, config.SetBufferPoolSizeString("256MiB"))
+	assert.Equal(t, uint64(256*1024*1024), config.BufferPoolSize)
+	assert.Nil(t, config.SetMaxDbSizeString("1TiB"))
+	assert.Equal(t, uint64(1<<40), config.MaxDbSize)
+	assert.NotNil(t, config.SetBufferPoolSizeString("lots"))
+	assert.Equal(t, uint64(256*1024*1024), config.BufferPoolSize)
+}
@@ -0,0 +1,82 @@
+package lbug
+
+// #include "lbug.h"
+import "C"
+
+import "fmt"
+
+// UnknownTypePolicy controls how values of logical types that the bindings do
+// not support are converted to Go values. Such types appear when the engine is
+// newer than the bindings.
+type UnknownTypePolicy int
+
+const (
+	// UnknownTypeError converts the value to its string representation and
+	// returns an error. This is the default.
+	UnknownTypeError UnknownTypePolicy = iota
+	// UnknownTypeSkip converts the value to nil without an error and records
+	// a ConversionWarning on the QueryResult.
+	UnknownTypeSkip
+	// UnknownTypeRaw converts the value to a RawValue without an error.
+	UnknownTypeRaw
+)
+
+// RawValue is the Go value of a value of an unsupported logical type when the
+// UnknownTypePolicy is UnknownTypeRaw.
+type RawValue struct {
+	// TypeID is the logical type id of the value in the C API.
+	TypeID int
+	// Text is the string representation of the value produced by the engine.
+	Text string
+}
+
+// ConversionWarning records a value of an unsupported logical type that was
+// converted to nil because the UnknownTypePolicy is UnknownTypeSkip.
+type ConversionWarning struct {
+	// TypeID is the logical type id of the value in the C API.
+	TypeID int
+	// Message describes the conversion.
+	Message string
+}
+
+// valueConverter carries the unknown type and time range policies of a
+// QueryResult into the conversion of its values. A nil converter uses
+// UnknownTypeError and TimeRangeStrict.
+type valueConverter struct {
+	policy    UnknownTypePolicy
+	timeRange TimeRangePolicy
+	warnings  []ConversionWarning
+	// maxPathElements is the limit of the elements of RECURSIVE_REL values,
+	// or zero for no limit.
+	maxPathElements uint64
+}
+
+// unsupportedTypeIDsForTesting lists type ids that are handled as unsupported
+// types, so that tests can exercise the unknown type policies.
+var unsupportedTypeIDsForTesting map[int]bool
+
+// int64TypeID is the type id of INT64, which tests mark as unsupported.
+var int64TypeID = int(C.LBUG_INT64)
+
+// unsupportedValue converts a value of an unsupported logical type according
+// to the policy of the converter.
+func (converter *valueConverter) unsupportedValue(lbugValue C.lbug_value, typeID int) (any, error) {
+	policy := UnknownTypeError
+	if converter != nil {
+		policy = converter.policy
+	}
+	if policy == UnknownTypeSkip {
+		converter.warnings = append(converter.warnings, ConversionWarning{
+			TypeID:  typeID,
+			Message: fmt.Sprintf("unsupported data type with type id: %d. the value is replaced by nil", typeID),
+		})
+		return nil, nil
+	}
+	valueString := C.lbug_value_to_string(&lbugValue)
+	defer C.lbug_destroy_string(valueString)
+	text := C.GoString(valueString)
+	if policy == UnknownTypeRaw {
+		return RawValue{TypeID: typeID, Text: text}, nil
+	}
+	return text, fmt.Errorf("unsupported data type with type id: %d. the value is force-casted to string", typeID)
+}
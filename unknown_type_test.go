@@ -0,0 +1,67 @@
+package lbug
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// queryWithUnsupportedInt64 runs a query returning INT64 values, plain and
+// nested, while INT64 is handled as an unsupported type.
+func queryWithUnsupportedInt64(t *testing.T, policy UnknownTypePolicy) (*QueryResult, *FlatTuple) {
+	t.Helper()
+	db, err := OpenInMemoryDatabase(DefaultSystemConfig())
+	assert.Nil(t, err)
+	t.Cleanup(db.Close)
+	conn, err := OpenConnection(db)
+	assert.Nil(t, err)
+	t.Cleanup(conn.Close)
+	conn.SetUnknownTypePolicy(policy)
+	unsupportedTypeIDsForTesting = map[int]bool{int64TypeID: true}
+	t.Cleanup(func() { unsupportedTypeIDsForTesting = nil })
+	result, err := conn.Query("RETURN 1 AS a, [2, 3] AS l, {x: 4} AS s, 'str' AS t;")
+	assert.Nil(t, err)
+	t.Cleanup(result.Close)
+	tuple, err := result.Next()
+	assert.Nil(t, err)
+	t.Cleanup(tuple.Close)
+	return result, tuple
+}
+
+func TestUnknownTypeError(t *testing.T) {
+	result, tuple := queryWithUnsupportedInt64(t, UnknownTypeError)
+	value, err := tuple.GetValue(0)
+	assert.ErrorContains(t, err, "unsupported data type")
+	assert.Equal(t, "1", value)
+	_, err = tuple.GetAsMap()
+	assert.NotNil(t, err)
+	value, err = tuple.GetValue(3)
+	assert.Nil(t, err)
+	assert.Equal(t, "str", value)
+	assert.Empty(t, result.ConversionWarnings())
+}
+
+func TestUnknownTypeSkip(t *testing.T) {
+	result, tuple := queryWithUnsupportedInt64(t, UnknownTypeSkip)
+	values, err := tuple.GetAsMap()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{
+		"a": nil,
+		"l": []any{nil, nil},
+		"s": map[string]any{"x": nil},
+		"t": "str",
+	}, values)
+	warnings := result.ConversionWarnings()
+	assert.Len(t, warnings, 4)
+	assert.Equal(t, int64TypeID, warnings[0].TypeID)
+}
+
+func TestUnknownTypeRaw(t *testing.T) {
+	result, tuple := queryWithUnsupportedInt64(t, UnknownTypeRaw)
+	values, err := tuple.GetAsSlice()
+	assert.Nil(t, err)
+	assert.Equal(t, RawValue{TypeID: int64TypeID, Text: "1"}, values[0])
+	assert.Equal(t, []any{RawValue{TypeID: int64TypeID, Text: "2"}, RawValue{TypeID: int64TypeID, Text: "3"}}, values[1])
+	assert.Equal(t, map[string]any{"x": RawValue{TypeID: int64TypeID, Text: "4"}}, values[2])
+	assert.Empty(t, result.ConversionWarnings())
+}
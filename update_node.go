@@ -0,0 +1,224 @@
+package lbug
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is returned by Connection.UpdateNode when no node has the given
+// primary key, and by BatchLoader.Load when no row has the given key.
+var ErrNotFound = errors.New("node not found")
+
+// UpdateOptions configures Connection.UpdateNode.
+type UpdateOptions struct {
+	// Include lists properties of a struct that are set even if their field
+	// has the zero value or is a nil pointer.
+	Include []string
+	// Exclude lists properties that are never set.
+	Exclude []string
+	// AutoTimestamps sets its UpdatedAt property to the time of the clock of
+	// the connection.
+	AutoTimestamps AutoTimestamps
+}
+
+// CreateOptions configures Connection.CreateNode.
+type CreateOptions struct {
+	// Exclude lists properties that are never set.
+	Exclude []string
+	// AutoTimestamps sets its CreatedAt and UpdatedAt properties to the time
+	// of the clock of the connection.
+	AutoTimestamps AutoTimestamps
+}
+
+// UpdateNode sets properties of the node of the table with the given primary
+// key to the values of changes, and returns an error matching ErrNotFound if
+// there is no such node.
+//
+// changes is a map[string]any or a struct, or a pointer to one, whose
+// exported fields are properties named after the field or after its "lbug"
+// tag; fields tagged "-" are ignored and embedded structs are not flattened.
+// Every entry of a map is set, with nil values setting the property to NULL.
+// A struct field is only set if it has a non-zero value, so a property can
+// be set to a zero value with a non-nil pointer field, e.g. a *int pointing
+// to 0. A field listed in opts.Include is set in any case, with a nil
+// pointer setting the property to NULL. The primary key cannot be changed.
+func (conn *Connection) UpdateNode(table string, primaryKey any, changes any, opts UpdateOptions) (WriteSummary, error) {
+	properties, err := updatedProperties(changes, opts)
+	if err != nil {
+		return WriteSummary{}, err
+	}
+	opts.AutoTimestamps.stamp(properties, conn.now(), false)
+	schema, err := describeTable(conn, table, false)
+	if err != nil {
+		return WriteSummary{}, err
+	}
+	key, ok := schema.primaryKey()
+	if !ok {
+		return WriteSummary{}, fmt.Errorf("node table %s has no primary key", table)
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		if name == key.name {
+			return WriteSummary{}, fmt.Errorf("the primary key %s of table %s cannot be updated", name, table)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	args := map[string]any{"pk": primaryKey}
+	assignments := make([]string, len(names))
+	for i, name := range names {
+		param := fmt.Sprintf("p%d", i)
+		assignments[i] = fmt.Sprintf("n.%s = $%s", QuoteIdentifier(name), param)
+		args[param] = properties[name]
+	}
+	query := fmt.Sprintf("MATCH (n:%s) WHERE n.%s = $pk SET %s RETURN 1;",
+		QuoteIdentifier(table), QuoteIdentifier(key.name), strings.Join(assignments, ", "))
+	statement, err := conn.Prepare(query)
+	defer statement.Close()
+	if err != nil {
+		return WriteSummary{}, err
+	}
+	summary, err := statement.Exec(args)
+	if err != nil {
+		return summary, err
+	}
+	if summary.NumTuples == 0 {
+		return summary, fmt.Errorf("%w: table %s has no node with %s %v", ErrNotFound, table, key.name, primaryKey)
+	}
+	return summary, nil
+}
+
+// CreateNode creates a node of the table with the properties, a
+// map[string]any or a struct, or a pointer to one, whose fields are named as
+// for UpdateNode. Unlike UpdateNode, every field is set, including zero
+// values, with nil pointers setting the property to NULL.
+func (conn *Connection) CreateNode(table string, properties any, opts CreateOptions) (WriteSummary, error) {
+	created, err := createdProperties(properties, opts.Exclude)
+	if err != nil {
+		return WriteSummary{}, err
+	}
+	opts.AutoTimestamps.stamp(created, conn.now(), true)
+	if len(created) == 0 {
+		return WriteSummary{}, errors.New("no properties to create")
+	}
+	names := make([]string, 0, len(created))
+	for name := range created {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args := make(map[string]any, len(names))
+	assignments := make([]string, len(names))
+	for i, name := range names {
+		param := fmt.Sprintf("p%d", i)
+		assignments[i] = fmt.Sprintf("%s: $%s", QuoteIdentifier(name), param)
+		args[param] = created[name]
+	}
+	query := fmt.Sprintf("CREATE (n:%s {%s});", QuoteIdentifier(table), strings.Join(assignments, ", "))
+	statement, err := conn.Prepare(query)
+	defer statement.Close()
+	if err != nil {
+		return WriteSummary{}, err
+	}
+	return statement.Exec(args)
+}
+
+// createdProperties returns the properties set by CreateNode.
+func createdProperties(properties any, exclude []string) (map[string]any, error) {
+	excluded := make(map[string]bool, len(exclude))
+	for _, name := range exclude {
+		excluded[name] = true
+	}
+	created := make(map[string]any)
+	if fields, ok := properties.(map[string]any); ok {
+		for name, value := range fields {
+			if !excluded[name] {
+				created[name] = value
+			}
+		}
+		return created, nil
+	}
+	value := reflect.ValueOf(properties)
+	if value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("properties must be a map[string]any or a struct, got %T", properties)
+	}
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		name, ok := structFieldName(valueType.Field(i))
+		if !ok || excluded[name] {
+			continue
+		}
+		fieldValue := value.Field(i)
+		if fieldValue.Kind() == reflect.Pointer && fieldValue.IsNil() {
+			created[name] = nil
+			continue
+		}
+		created[name] = fieldValue.Interface()
+	}
+	return created, nil
+}
+
+// updatedProperties returns the properties set by UpdateNode for the changes.
+func updatedProperties(changes any, opts UpdateOptions) (map[string]any, error) {
+	excluded := make(map[string]bool, len(opts.Exclude))
+	for _, name := range opts.Exclude {
+		excluded[name] = true
+	}
+	properties := make(map[string]any)
+	if fields, ok := changes.(map[string]any); ok {
+		for name, value := range fields {
+			if !excluded[name] {
+				properties[name] = value
+			}
+		}
+	} else {
+		value := reflect.ValueOf(changes)
+		if value.Kind() == reflect.Pointer && !value.IsNil() {
+			value = value.Elem()
+		}
+		if value.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("changes must be a map[string]any or a struct, got %T", changes)
+		}
+		included := make(map[string]bool, len(opts.Include))
+		for _, name := range opts.Include {
+			included[name] = true
+		}
+		found := make(map[string]bool)
+		valueType := value.Type()
+		for i := 0; i < valueType.NumField(); i++ {
+			name, ok := structFieldName(valueType.Field(i))
+			if !ok {
+				continue
+			}
+			found[name] = true
+			fieldValue := value.Field(i)
+			if excluded[name] || (fieldValue.IsZero() && !included[name]) {
+				continue
+			}
+			if fieldValue.Kind() == reflect.Pointer {
+				if fieldValue.IsNil() {
+					properties[name] = nil
+				} else {
+					properties[name] = fieldValue.Elem().Interface()
+				}
+				continue
+			}
+			properties[name] = fieldValue.Interface()
+		}
+		for _, name := range opts.Include {
+			if !found[name] {
+				return nil, fmt.Errorf("included property %s is not a field of %T", name, changes)
+			}
+		}
+	}
+	if len(properties) == 0 {
+		return nil, errors.New("no properties to update")
+	}
+	return properties, nil
+}
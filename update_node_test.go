@@ -0,0 +1,99 @@
+package lbug
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type personChanges struct {
+	Name     string   `lbug:"fName"`
+	Age      int64    `lbug:"age"`
+	Eyesight *float64 `lbug:"eyeSight"`
+	Note     string   `lbug:"-"`
+	hidden   string
+}
+
+func TestUpdatedProperties(t *testing.T) {
+	zero := 0.0
+	properties, err := updatedProperties(personChanges{Age: 30, Note: "ignored", hidden: "ignored"}, UpdateOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"age": int64(30)}, properties)
+
+	// A non-nil pointer sets a zero value.
+	properties, err = updatedProperties(&personChanges{Eyesight: &zero}, UpdateOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"eyeSight": 0.0}, properties)
+
+	// Included fields are set even if they are zero, a nil pointer to NULL.
+	properties, err = updatedProperties(personChanges{Age: 30}, UpdateOptions{Include: []string{"fName", "eyeSight"}, Exclude: []string{"age"}})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"fName": "", "eyeSight": nil}, properties)
+
+	properties, err = updatedProperties(map[string]any{"age": 0, "fName": nil, "gender": 1}, UpdateOptions{Exclude: []string{"gender"}})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"age": 0, "fName": nil}, properties)
+}
+
+func TestUpdatedPropertiesErrors(t *testing.T) {
+	_, err := updatedProperties(personChanges{}, UpdateOptions{})
+	assert.ErrorContains(t, err, "no properties to update")
+	_, err = updatedProperties(personChanges{}, UpdateOptions{Include: []string{"missing"}})
+	assert.ErrorContains(t, err, "included property missing")
+	_, err = updatedProperties(42, UpdateOptions{})
+	assert.ErrorContains(t, err, "got int")
+}
+
+func TestUpdateNode(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE person(id INT64, fName STRING, age INT64, eyeSight DOUBLE, PRIMARY KEY(id));")
+	mustRun(t, conn, "CREATE (:person {id: 1, fName: 'Alice', age: 35, eyeSight: 5.0});")
+
+	summary, err := conn.UpdateNode("person", int64(1), personChanges{Age: 36}, UpdateOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), summary.NumTuples)
+	rows, err := queryRows(conn, "MATCH (p:person) RETURN p.fName, p.age, p.eyeSight;")
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{{"p.fName": "Alice", "p.age": int64(36), "p.eyeSight": 5.0}}, rows)
+
+	_, err = conn.UpdateNode("person", int64(1), personChanges{}, UpdateOptions{Include: []string{"eyeSight"}})
+	assert.Nil(t, err)
+	rows, err = queryRows(conn, "MATCH (p:person) RETURN p.eyeSight;")
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{{"p.eyeSight": nil}}, rows)
+
+	_, err = conn.UpdateNode("person", int64(2), map[string]any{"age": int64(1)}, UpdateOptions{})
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = conn.UpdateNode("person", int64(1), map[string]any{"id": int64(3)}, UpdateOptions{})
+	assert.ErrorContains(t, err, "cannot be updated")
+}
+
+func TestCreatedProperties(t *testing.T) {
+	properties, err := createdProperties(personChanges{Age: 30, Note: "ignored"}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"fName": "", "age": int64(30), "eyeSight": nil}, properties)
+	properties, err = createdProperties(map[string]any{"age": 0, "gender": 1}, []string{"gender"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]any{"age": 0}, properties)
+	_, err = createdProperties(42, nil)
+	assert.ErrorContains(t, err, "got int")
+}
+
+func TestNodeAutoTimestamps(t *testing.T) {
+	_, conn := openTempTableTestConnection(t)
+	mustRun(t, conn, "CREATE NODE TABLE person(id INT64, fName STRING, age INT64, eyeSight DOUBLE, created TIMESTAMP, updated TIMESTAMP, PRIMARY KEY(id));")
+	created := time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)
+	now := created
+	conn.SetClock(ClockFunc(func() time.Time { return now }))
+	timestamps := AutoTimestamps{CreatedAt: "created", UpdatedAt: "updated"}
+
+	_, err := conn.CreateNode("person", map[string]any{"id": int64(1), "fName": "Alice"}, CreateOptions{AutoTimestamps: timestamps})
+	assert.Nil(t, err)
+	now = created.Add(time.Hour)
+	_, err = conn.UpdateNode("person", int64(1), personChanges{Age: 36}, UpdateOptions{AutoTimestamps: timestamps})
+	assert.Nil(t, err)
+	rows, err := queryRows(conn, "MATCH (p:person) RETURN p.age, p.created, p.updated;")
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]any{{"p.age": int64(36), "p.created": created, "p.updated": now}}, rows)
+}
@@ -0,0 +1,190 @@
+package lbug
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// The benchmarks in this file measure the conversion of values to Go by
+// FlatTuple.GetValue and GetAsSlice, with allocations and, for single values,
+// the calls to lbug_flat_tuple_get_value per conversion. To compare a change
+// against its parent, run them on both with, e.g.:
+//
+//	go test -run '^$' -bench '^BenchmarkConvert' -count 10 > new.txt
+//	benchstat old.txt new.txt
+
+// benchmarkTuple returns the first tuple of the query, which stays open until
+// the end of the benchmark.
+func benchmarkTuple(b *testing.B, query string) *FlatTuple {
+	b.Helper()
+	_, conn := SetupTestDatabase(b)
+	result, err := conn.Query(query)
+	if err != nil {
+		b.Fatalf("query %q failed: %v", query, err)
+	}
+	b.Cleanup(result.Close)
+	tuple, err := result.Next()
+	if err != nil {
+		b.Fatalf("query %q returned no tuple: %v", query, err)
+	}
+	b.Cleanup(tuple.Close)
+	return tuple
+}
+
+// benchmarkConvert benchmarks the conversion of the single value returned by
+// the query.
+func benchmarkConvert(b *testing.B, query string) {
+	tuple := benchmarkTuple(b, query)
+	if _, err := tuple.GetValue(0); err != nil {
+		b.Fatalf("converting the value of %q failed: %v", query, err)
+	}
+	conn := tuple.queryResult.connection
+	conn.SetCgoCallCounting(true)
+	b.ReportAllocs()
+	b.ResetTimer()
+	conn.ResetCgoCalls()
+	for i := 0; i < b.N; i++ {
+		tuple.GetValue(0)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(conn.Stats().CgoCalls.GetValue)/float64(b.N), "cgocalls/op")
+}
+
+func BenchmarkConvert_Bool(b *testing.B) { benchmarkConvert(b, "RETURN true") }
+func BenchmarkConvert_Int64(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST(1, 'INT64')")
+}
+func BenchmarkConvert_Int32(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST(1, 'INT32')")
+}
+func BenchmarkConvert_Int16(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST(1, 'INT16')")
+}
+func BenchmarkConvert_Int8(b *testing.B) { benchmarkConvert(b, "RETURN CAST(1, 'INT8')") }
+func BenchmarkConvert_Uint64(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST(1, 'UINT64')")
+}
+func BenchmarkConvert_Uint32(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST(1, 'UINT32')")
+}
+func BenchmarkConvert_Uint16(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST(1, 'UINT16')")
+}
+func BenchmarkConvert_Uint8(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST(1, 'UINT8')")
+}
+func BenchmarkConvert_Int128(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST('170141183460469231731687303715884105727', 'INT128')")
+}
+func BenchmarkConvert_Serial(b *testing.B) {
+	benchmarkConvert(b, "MATCH (m:moviesSerial) RETURN m.ID LIMIT 1")
+}
+func BenchmarkConvert_Double(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST(1.5, 'DOUBLE')")
+}
+func BenchmarkConvert_Float(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST(1.5, 'FLOAT')")
+}
+func BenchmarkConvert_Decimal(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST(12.34, 'DECIMAL(18, 2)')")
+}
+func BenchmarkConvert_String(b *testing.B) {
+	benchmarkConvert(b, "RETURN 'The quick brown fox jumps over the lazy dog'")
+}
+func BenchmarkConvert_Blob(b *testing.B) {
+	benchmarkConvert(b, "RETURN BLOB('\\\\xDE\\\\xAD\\\\xBE\\\\xEF')")
+}
+
+// BenchmarkConvert_String64KB and BenchmarkConvert_Blob64KB measure the
+// throughput of the copies of long values: blobs are copied in one pass with
+// their length, while strings are measured first since the C API returns them
+// without their length.
+func BenchmarkConvert_String64KB(b *testing.B) {
+	b.SetBytes(64 << 10)
+	benchmarkConvert(b, "RETURN repeat('x', 65536)")
+}
+func BenchmarkConvert_Blob64KB(b *testing.B) {
+	b.SetBytes(64 << 10)
+	benchmarkConvert(b, "RETURN BLOB(repeat('x', 65536))")
+}
+func BenchmarkConvert_UUID(b *testing.B) {
+	benchmarkConvert(b, "RETURN uuid('a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11')")
+}
+func BenchmarkConvert_Date(b *testing.B) {
+	benchmarkConvert(b, "RETURN date('2024-02-29')")
+}
+func BenchmarkConvert_Timestamp(b *testing.B) {
+	benchmarkConvert(b, "RETURN timestamp('2024-02-29 12:34:56.789')")
+}
+func BenchmarkConvert_TimestampNs(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST(timestamp('2024-02-29 12:34:56.789'), 'TIMESTAMP_NS')")
+}
+func BenchmarkConvert_TimestampMs(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST(timestamp('2024-02-29 12:34:56.789'), 'TIMESTAMP_MS')")
+}
+func BenchmarkConvert_TimestampSec(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST(timestamp('2024-02-29 12:34:56'), 'TIMESTAMP_SEC')")
+}
+func BenchmarkConvert_TimestampTz(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST(timestamp('2024-02-29 12:34:56.789'), 'TIMESTAMP_TZ')")
+}
+func BenchmarkConvert_Interval(b *testing.B) {
+	benchmarkConvert(b, "RETURN interval('1 day 2 hours')")
+}
+func BenchmarkConvert_InternalID(b *testing.B) {
+	benchmarkConvert(b, "MATCH (a:person) RETURN id(a) LIMIT 1")
+}
+func BenchmarkConvert_Node(b *testing.B) {
+	benchmarkConvert(b, "MATCH (a:person) RETURN a LIMIT 1")
+}
+func BenchmarkConvert_Rel(b *testing.B) {
+	benchmarkConvert(b, "MATCH (:person)-[r:knows]->(:person) RETURN r LIMIT 1")
+}
+func BenchmarkConvert_RecursiveRel(b *testing.B) {
+	benchmarkConvert(b, "MATCH (:person)-[r:knows*1..2]->(:person) RETURN r LIMIT 1")
+}
+func BenchmarkConvert_List(b *testing.B) {
+	benchmarkConvert(b, "RETURN range(1, 100)")
+}
+func BenchmarkConvert_Array(b *testing.B) {
+	benchmarkConvert(b, "RETURN CAST([1.0, 2.0, 3.0, 4.0], 'DOUBLE[4]')")
+}
+func BenchmarkConvert_Struct(b *testing.B) {
+	benchmarkConvert(b, "RETURN {name: 'Alice', age: 30, tags: ['a', 'b']}")
+}
+func BenchmarkConvert_Map(b *testing.B) {
+	benchmarkConvert(b, "RETURN map(['a', 'b', 'c'], [1, 2, 3])")
+}
+func BenchmarkConvert_Union(b *testing.B) {
+	benchmarkConvert(b, "RETURN union_value(a := 1)")
+}
+
+// BenchmarkConvert_MixedRow converts a row with a column of each common type.
+func BenchmarkConvert_MixedRow(b *testing.B) {
+	tuple := benchmarkTuple(b, "RETURN true, 1, 1.5, 'text', date('2024-02-29'), timestamp('2024-02-29 12:34:56'), "+
+		"interval('1 day'), [1, 2, 3], {a: 1}, map(['k'], [1])")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tuple.GetAsSlice()
+	}
+}
+
+// BenchmarkConvert_WideRow converts a row of 100 INT64 and STRING columns.
+func BenchmarkConvert_WideRow(b *testing.B) {
+	columns := make([]string, 100)
+	for i := range columns {
+		if i%2 == 0 {
+			columns[i] = fmt.Sprint(i)
+		} else {
+			columns[i] = fmt.Sprintf("'column %d'", i)
+		}
+	}
+	tuple := benchmarkTuple(b, "RETURN "+strings.Join(columns, ", "))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tuple.GetAsSlice()
+	}
+}
@@ -0,0 +1,354 @@
+package lbug
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// The fuzz targets in this file bind generated values as the parameter of
+// RETURN $v, read them back and compare them after normalization. Run them
+// with, e.g.:
+//
+//	go test -run '^$' -fuzz FuzzScalarRoundTrip
+//
+// A mismatch reports the value and the path of the first differing element.
+
+// scalarKinds are the kinds of scalar values generated by FuzzScalarRoundTrip.
+var scalarKinds = []string{
+	"BOOL", "INT64", "INT32", "INT16", "INT8", "UINT64", "UINT32", "UINT16", "UINT8",
+	"DOUBLE", "FLOAT", "STRING", "TIMESTAMP", "TIMESTAMP_NS", "INTERVAL",
+}
+
+// scalarValue builds the scalar value of the given kind from the fuzzed
+// inputs.
+func scalarValue(kind string, i int64, u uint64, f float64, s string) any {
+	switch kind {
+	case "BOOL":
+		return i%2 == 0
+	case "INT64":
+		return i
+	case "INT32":
+		return int32(i)
+	case "INT16":
+		return int16(i)
+	case "INT8":
+		return int8(i)
+	case "UINT64":
+		return u
+	case "UINT32":
+		return uint32(u)
+	case "UINT16":
+		return uint16(u)
+	case "UINT8":
+		return uint8(u)
+	case "DOUBLE":
+		return f
+	case "FLOAT":
+		return float32(f)
+	case "STRING":
+		return s
+	case "TIMESTAMP":
+		// Times without nanoseconds are bound as microsecond timestamps.
+		return time.Unix(0, i-i%1000).UTC()
+	case "TIMESTAMP_NS":
+		return time.Unix(0, i).UTC()
+	case "INTERVAL":
+		return time.Duration(i)
+	}
+	panic("unknown kind " + kind)
+}
+
+// roundTrip binds the value as $v of RETURN $v and returns the value read
+// back.
+func roundTrip(t *testing.T, stmt *PreparedStatement, value any) any {
+	t.Helper()
+	result, err := stmt.connection.Execute(stmt, map[string]any{"v": value})
+	if err != nil {
+		t.Fatalf("binding %s failed: %v", describeValue(value), err)
+	}
+	defer result.Close()
+	tuple, err := result.Next()
+	if err != nil {
+		t.Fatalf("reading back %s failed: %v", describeValue(value), err)
+	}
+	defer tuple.Close()
+	got, err := tuple.GetValue(0)
+	if err != nil {
+		t.Fatalf("converting back %s failed: %v", describeValue(value), err)
+	}
+	return got
+}
+
+// normalizeValue returns the value expected back from the engine for a bound
+// value: intervals lose their nanoseconds and maps of MapItem are sorted by
+// key.
+func normalizeValue(value any) any {
+	switch v := value.(type) {
+	case time.Duration:
+		return v - v%time.Microsecond
+	case time.Time:
+		return v.UTC()
+	case []any:
+		normalized := make([]any, len(v))
+		for i, item := range v {
+			normalized[i] = normalizeValue(item)
+		}
+		return normalized
+	case map[string]any:
+		normalized := make(map[string]any, len(v))
+		for key, item := range v {
+			normalized[key] = normalizeValue(item)
+		}
+		return normalized
+	case []MapItem:
+		normalized := make([]MapItem, len(v))
+		for i, item := range v {
+			normalized[i] = MapItem{Key: normalizeValue(item.Key), Value: normalizeValue(item.Value)}
+		}
+		sort.Slice(normalized, func(i, j int) bool {
+			return fmt.Sprint(normalized[i].Key) < fmt.Sprint(normalized[j].Key)
+		})
+		return normalized
+	}
+	return value
+}
+
+// diffValues returns a description of the first difference between the
+// expected and the actual value, or an empty string if they are equal. The
+// description starts with the path of the differing element, e.g. $v[1].a.
+func diffValues(path string, want any, got any) string {
+	mismatch := func() string {
+		return fmt.Sprintf("%s: want %s, got %s", path, describeValue(want), describeValue(got))
+	}
+	if reflect.TypeOf(want) != reflect.TypeOf(got) {
+		return mismatch()
+	}
+	switch w := want.(type) {
+	case float64:
+		if !(w == got.(float64) || math.IsNaN(w) && math.IsNaN(got.(float64))) {
+			return mismatch()
+		}
+	case float32:
+		g := got.(float32)
+		if !(w == g || w != w && g != g) {
+			return mismatch()
+		}
+	case time.Time:
+		if !w.Equal(got.(time.Time)) {
+			return mismatch()
+		}
+	case []any:
+		g := got.([]any)
+		if len(w) != len(g) {
+			return mismatch()
+		}
+		for i := range w {
+			if diff := diffValues(fmt.Sprintf("%s[%d]", path, i), w[i], g[i]); diff != "" {
+				return diff
+			}
+		}
+	case map[string]any:
+		g := got.(map[string]any)
+		if len(w) != len(g) {
+			return mismatch()
+		}
+		for key, item := range w {
+			if diff := diffValues(path+"."+key, item, g[key]); diff != "" {
+				return diff
+			}
+		}
+	case []MapItem:
+		g := normalizeValue(got).([]MapItem)
+		if len(w) != len(g) {
+			return mismatch()
+		}
+		for i := range w {
+			if diff := diffValues(fmt.Sprintf("%s{%d}.key", path, i), w[i].Key, g[i].Key); diff != "" {
+				return diff
+			}
+			if diff := diffValues(fmt.Sprintf("%s{%d}.value", path, i), w[i].Value, g[i].Value); diff != "" {
+				return diff
+			}
+		}
+	default:
+		if !reflect.DeepEqual(want, got) {
+			return mismatch()
+		}
+	}
+	return ""
+}
+
+// describeValue formats a value with its Go type, shortening long strings.
+func describeValue(value any) string {
+	if s, ok := value.(string); ok && len(s) > 64 {
+		return fmt.Sprintf("string(len %d)%q...", len(s), s[:64])
+	}
+	return fmt.Sprintf("%T(%#v)", value, value)
+}
+
+// checkRoundTrip fails the test if the value does not survive a round trip.
+func checkRoundTrip(t *testing.T, stmt *PreparedStatement, value any) {
+	t.Helper()
+	got := roundTrip(t, stmt, value)
+	if diff := diffValues("$v", normalizeValue(value), got); diff != "" {
+		t.Fatalf("round trip of %s: %s", describeValue(value), diff)
+	}
+}
+
+// prepareRoundTrip prepares the RETURN $v statement shared by the iterations
+// of a fuzz target.
+func prepareRoundTrip(f *testing.F) *PreparedStatement {
+	_, conn := SetupTestDatabase(f)
+	stmt, err := conn.Prepare("RETURN $v")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(stmt.Close)
+	return stmt
+}
+
+func FuzzScalarRoundTrip(f *testing.F) {
+	seeds := []struct {
+		i int64
+		u uint64
+		f float64
+		s string
+	}{
+		{0, 0, 0, ""},
+		{math.MaxInt64, math.MaxUint64, math.MaxFloat64, "Hello World"},
+		{math.MinInt64, 1, -math.SmallestNonzeroFloat64, "ünïcødé ✓ 日本語"},
+		{-1, math.MaxUint32, math.Inf(1), strings.Repeat("x", 1<<20)},
+		{math.MaxInt32 + 1, math.MaxUint16 + 1, math.NaN(), "'quoted' \"string\" \\ $v"},
+		{-1000, 255, math.Inf(-1), "\t\n\r"},
+		{1000, 256, 1e-300, " "},
+	}
+	for _, seed := range seeds {
+		for kind := range scalarKinds {
+			f.Add(uint8(kind), seed.i, seed.u, seed.f, seed.s)
+		}
+	}
+	stmt := prepareRoundTrip(f)
+	f.Fuzz(func(t *testing.T, kind uint8, i int64, u uint64, fl float64, s string) {
+		if !utf8.ValidString(s) || strings.ContainsRune(s, 0) {
+			// Strings are bound as NUL terminated UTF-8.
+			t.Skip()
+		}
+		checkRoundTrip(t, stmt, scalarValue(scalarKinds[int(kind)%len(scalarKinds)], i, u, fl, s))
+	})
+}
+
+// nestedValue builds a random nested value of lists, structs and maps of
+// depth at most depth whose leaves are scalars of the given kind. All
+// elements of a list, and all values of a map, have the same type.
+func nestedValue(random *rand.Rand, depth int, width int, kind string) any {
+	if depth == 0 {
+		return scalarValue(kind, random.Int63()-random.Int63(), random.Uint64(), random.NormFloat64(), fmt.Sprint(random.Int()))
+	}
+	shape := random.Intn(3)
+	build := func() []any {
+		// Every element is built from the same seed so that they all have
+		// the same type.
+		seed := random.Int63()
+		items := make([]any, width)
+		for i := range items {
+			elementRandom := rand.New(rand.NewSource(seed))
+			items[i] = nestedValue(elementRandom, depth-1, width, kind)
+			// Vary the leaves without changing the type.
+			if i > 0 {
+				items[i] = varyLeaves(items[i], i)
+			}
+		}
+		return items
+	}
+	switch shape {
+	case 0:
+		return build()
+	case 1:
+		value := make(map[string]any, width)
+		for i := 0; i < width; i++ {
+			value[fmt.Sprintf("f%d", i)] = nestedValue(random, depth-1, width, kind)
+		}
+		return value
+	default:
+		values := build()
+		items := make([]MapItem, len(values))
+		for i, value := range values {
+			items[i] = MapItem{Key: fmt.Sprintf("k%d", i), Value: value}
+		}
+		return items
+	}
+}
+
+// varyLeaves changes the integer and string leaves of a nested value, keeping
+// its type.
+func varyLeaves(value any, salt int) any {
+	switch v := value.(type) {
+	case int64:
+		return v ^ int64(salt)
+	case string:
+		return v + fmt.Sprint(salt)
+	case []any:
+		for i := range v {
+			v[i] = varyLeaves(v[i], salt)
+		}
+	case map[string]any:
+		for key := range v {
+			v[key] = varyLeaves(v[key], salt)
+		}
+	case []MapItem:
+		for i := range v {
+			v[i].Value = varyLeaves(v[i].Value, salt)
+		}
+	}
+	return value
+}
+
+func FuzzNestedRoundTrip(f *testing.F) {
+	for _, seed := range []struct {
+		seed  int64
+		depth uint8
+		width uint8
+		kind  uint8
+	}{
+		{0, 1, 1, 1},
+		{1, 2, 3, 11},
+		{2, 3, 2, 12},
+		{3, 8, 1, 5},
+		{4, 32, 1, 1},
+		{5, 1, 200, 14},
+	} {
+		f.Add(seed.seed, seed.depth, seed.width, seed.kind)
+	}
+	stmt := prepareRoundTrip(f)
+	f.Fuzz(func(t *testing.T, seed int64, depth uint8, width uint8, kind uint8) {
+		// Empty lists and maps cannot be bound because their element type is
+		// unknown, so every container has at least one element.
+		depth = depth%32 + 1
+		width = width%8 + 1
+		if depth > 4 {
+			width = 1
+		}
+		random := rand.New(rand.NewSource(seed))
+		value := nestedValue(random, int(depth), int(width), scalarKinds[int(kind)%len(scalarKinds)])
+		checkRoundTrip(t, stmt, value)
+	})
+}
+
+func TestDiffValuesReportsPath(t *testing.T) {
+	want := []any{map[string]any{"a": int64(1), "b": []any{"x", "y"}}}
+	got := []any{map[string]any{"a": int64(1), "b": []any{"x", int64(2)}}}
+	diff := diffValues("$v", want, got)
+	if diff != `$v[0].b[1]: want string("y"), got int64(2)` {
+		t.Fatalf("unexpected diff %q", diff)
+	}
+	if diff := diffValues("$v", []any{math.NaN()}, []any{math.NaN()}); diff != "" {
+		t.Fatalf("unexpected diff %q", diff)
+	}
+}
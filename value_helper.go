@@ -1,11 +1,68 @@
 package lbug
 
-// #include "lbug.h"
-// #include <stdlib.h>
-// #include <string.h>
+/*
+#include "lbug.h"
+#include <stdlib.h>
+#include <string.h>
+
+// lbug_go_get_internal_id gets the internal id of a value returned by get.
+static lbug_state lbug_go_get_internal_id(lbug_value *value,
+		lbug_state (*get)(lbug_value *, lbug_value *), lbug_internal_id_t *id) {
+	lbug_value id_value;
+	if (get(value, &id_value) != LbugSuccess) {
+		return LbugError;
+	}
+	lbug_state state = lbug_value_get_internal_id(&id_value, id);
+	lbug_value_destroy(&id_value);
+	return state;
+}
+
+// lbug_go_get_label gets the label of a value returned by get. The label must
+// be released with lbug_destroy_string.
+static lbug_state lbug_go_get_label(lbug_value *value,
+		lbug_state (*get)(lbug_value *, lbug_value *), char **label) {
+	lbug_value label_value;
+	if (get(value, &label_value) != LbugSuccess) {
+		return LbugError;
+	}
+	lbug_state state = lbug_value_get_string(&label_value, label);
+	lbug_value_destroy(&label_value);
+	return state;
+}
+
+// lbug_go_get_node_header gets the id, the label and the number of properties
+// of a node with one call.
+static lbug_state lbug_go_get_node_header(lbug_value *node, lbug_internal_id_t *id, char **label,
+		uint64_t *num_properties) {
+	*label = NULL;
+	if (lbug_go_get_internal_id(node, lbug_node_val_get_id_val, id) != LbugSuccess ||
+			lbug_go_get_label(node, lbug_node_val_get_label_val, label) != LbugSuccess) {
+		return LbugError;
+	}
+	return lbug_node_val_get_property_size(node, num_properties);
+}
+
+// lbug_go_get_rel_header gets the ids, the label and the number of properties
+// of a relationship with one call.
+static lbug_state lbug_go_get_rel_header(lbug_value *rel, lbug_internal_id_t *id,
+		lbug_internal_id_t *src_id, lbug_internal_id_t *dst_id, char **label,
+		uint64_t *num_properties) {
+	*label = NULL;
+	if (lbug_go_get_internal_id(rel, lbug_rel_val_get_id_val, id) != LbugSuccess ||
+			lbug_go_get_internal_id(rel, lbug_rel_val_get_src_id_val, src_id) != LbugSuccess ||
+			lbug_go_get_internal_id(rel, lbug_rel_val_get_dst_id_val, dst_id) != LbugSuccess ||
+			lbug_go_get_label(rel, lbug_rel_val_get_label_val, label) != LbugSuccess) {
+		return LbugError;
+	}
+	return lbug_rel_val_get_property_size(rel, num_properties);
+}
+*/
 import "C"
 
 import (
+	"encoding"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
@@ -18,14 +75,20 @@ import (
 	"github.com/shopspring/decimal"
 )
 
-// InternalID represents the internal ID of a node or relationship in Lbug.
+// InternalID represents the internal ID of a node or relationship in Lbug. It
+// is bound as an INTERNAL_ID query parameter, which is compared with id(n).
 type InternalID struct {
 	TableID uint64
 	Offset  uint64
 }
 
 // Node represents a node retrieved from Lbug.
-// A node has an ID, a label, and properties.
+// A node has an ID, a label, and properties. The label is the name of the node
+// table of the node, which tells apart the nodes of different tables returned
+// in one column, e.g. by a UNION or a pattern matching several labels; see
+// GroupNodesByLabel and RegisterNodeType. The engine has no node parameters,
+// so a Node is bound as the INTERNAL_ID parameter of its ID, e.g.
+// "MATCH (n) WHERE id(n) = $node".
 type Node struct {
 	ID         InternalID
 	Label      string
@@ -34,6 +97,7 @@ type Node struct {
 
 // Relationship represents a relationship retrieved from Lbug.
 // A relationship has a source ID, a destination ID, a label, and properties.
+// Like a Node, it is bound as the INTERNAL_ID parameter of its ID.
 type Relationship struct {
 	ID            InternalID
 	SourceID      InternalID
@@ -44,7 +108,8 @@ type Relationship struct {
 
 // RecursiveRelationship represents a recursive relationship retrieved from a
 // path query in Lbug. A recursive relationship has a list of nodes and a list
-// of relationships.
+// of relationships. It cannot be bound as a query parameter: bind the IDs of
+// its nodes or relationships instead.
 type RecursiveRelationship struct {
 	Nodes         []Node
 	Relationships []Relationship
@@ -57,117 +122,143 @@ type MapItem struct {
 	Value any
 }
 
+// LbugValuer is implemented by types that convert themselves to a value the
+// bindings can bind as a query parameter, like driver.Valuer for
+// database/sql. LbugValue must not return another LbugValuer.
+type LbugValuer interface {
+	LbugValue() (any, error)
+}
+
+// LbugScanner is implemented by types that decode themselves from a value of
+// a query result, as returned by FlatTuple.GetValue, when fields of their type
+// are set by Collect. ScanLbug is called with nil for NULL values.
+type LbugScanner interface {
+	ScanLbug(value any) error
+}
+
 // lbugNodeValueToGoValue converts a lbug_value representing a node to a Node
 // struct in Go.
-func lbugNodeValueToGoValue(lbugValue C.lbug_value) (Node, error) {
+func lbugNodeValueToGoValue(lbugValue C.lbug_value, converter *valueConverter) (Node, error) {
 	node := Node{}
 	node.Properties = make(map[string]any)
-	idValue := C.lbug_value{}
-	C.lbug_node_val_get_id_val(&lbugValue, &idValue)
-	nodeId, _ := lbugValueToGoValue(idValue)
-	node.ID = nodeId.(InternalID)
-	C.lbug_value_destroy(&idValue)
-	labelValue := C.lbug_value{}
-	C.lbug_node_val_get_label_val(&lbugValue, &labelValue)
-	nodeLabel, _ := lbugValueToGoValue(labelValue)
-	node.Label = nodeLabel.(string)
-	C.lbug_value_destroy(&labelValue)
+	var id C.lbug_internal_id_t
+	var label *C.char
 	var propertySize C.uint64_t
-	C.lbug_node_val_get_property_size(&lbugValue, &propertySize)
+	status := C.lbug_go_get_node_header(&lbugValue, &id, &label, &propertySize)
+	if label != nil {
+		node.Label = C.GoString(label)
+		C.lbug_destroy_string(label)
+	}
+	if status != C.LbugSuccess {
+		return node, fmt.Errorf("failed to get node with status: %d", status)
+	}
+	node.ID = InternalID{TableID: uint64(id.table_id), Offset: uint64(id.offset)}
 	var currentKey *C.char
 	var currentVal C.lbug_value
-	var errors []error
+	var errs []error
 	for i := C.uint64_t(0); i < propertySize; i++ {
 		C.lbug_node_val_get_property_name_at(&lbugValue, i, &currentKey)
 		keyString := C.GoString(currentKey)
 		C.lbug_destroy_string(currentKey)
 		C.lbug_node_val_get_property_value_at(&lbugValue, i, &currentVal)
-		value, err := lbugValueToGoValue(currentVal)
+		value, err := lbugValueToGoValue(currentVal, converter)
 		if err != nil {
-			errors = append(errors, err)
+			errs = append(errs, err)
 		}
 		node.Properties[keyString] = value
 		C.lbug_value_destroy(&currentVal)
 	}
-	if len(errors) > 0 {
-		return node, fmt.Errorf("failed to get values: %v", errors)
+	if len(errs) > 0 {
+		return node, fmt.Errorf("failed to get values: %w", errors.Join(errs...))
 	}
 	return node, nil
 }
 
 // lbugRelValueToGoValue converts a lbug_value representing a relationship to a
 // Relationship struct in Go.
-func lbugRelValueToGoValue(lbugValue C.lbug_value) (Relationship, error) {
+func lbugRelValueToGoValue(lbugValue C.lbug_value, converter *valueConverter) (Relationship, error) {
 	relation := Relationship{}
 	relation.Properties = make(map[string]any)
-	idValue := C.lbug_value{}
-	C.lbug_rel_val_get_id_val(&lbugValue, &idValue)
-	id, _ := lbugValueToGoValue(idValue)
-	relation.ID = id.(InternalID)
-	C.lbug_value_destroy(&idValue)
-	C.lbug_rel_val_get_src_id_val(&lbugValue, &idValue)
-	src, _ := lbugValueToGoValue(idValue)
-	relation.SourceID = src.(InternalID)
-	C.lbug_value_destroy(&idValue)
-	C.lbug_rel_val_get_dst_id_val(&lbugValue, &idValue)
-	dst, _ := lbugValueToGoValue(idValue)
-	relation.DestinationID = dst.(InternalID)
-	C.lbug_value_destroy(&idValue)
-	labelValue := C.lbug_value{}
-	C.lbug_rel_val_get_label_val(&lbugValue, &labelValue)
-	label, _ := lbugValueToGoValue(labelValue)
-	relation.Label = label.(string)
-	C.lbug_value_destroy(&labelValue)
+	var id, srcID, dstID C.lbug_internal_id_t
+	var label *C.char
 	var propertySize C.uint64_t
-	C.lbug_rel_val_get_property_size(&lbugValue, &propertySize)
+	status := C.lbug_go_get_rel_header(&lbugValue, &id, &srcID, &dstID, &label, &propertySize)
+	if label != nil {
+		relation.Label = C.GoString(label)
+		C.lbug_destroy_string(label)
+	}
+	if status != C.LbugSuccess {
+		return relation, fmt.Errorf("failed to get relationship with status: %d", status)
+	}
+	relation.ID = InternalID{TableID: uint64(id.table_id), Offset: uint64(id.offset)}
+	relation.SourceID = InternalID{TableID: uint64(srcID.table_id), Offset: uint64(srcID.offset)}
+	relation.DestinationID = InternalID{TableID: uint64(dstID.table_id), Offset: uint64(dstID.offset)}
 	var currentKey *C.char
 	var currentVal C.lbug_value
-	var errors []error
+	var errs []error
 	for i := C.uint64_t(0); i < propertySize; i++ {
 		C.lbug_rel_val_get_property_name_at(&lbugValue, i, &currentKey)
 		keyString := C.GoString(currentKey)
 		C.lbug_destroy_string(currentKey)
 		C.lbug_rel_val_get_property_value_at(&lbugValue, i, &currentVal)
-		value, err := lbugValueToGoValue(currentVal)
+		value, err := lbugValueToGoValue(currentVal, converter)
 		if err != nil {
-			errors = append(errors, err)
+			errs = append(errs, err)
 		}
 		relation.Properties[keyString] = value
 		C.lbug_value_destroy(&currentVal)
 	}
-	if len(errors) > 0 {
-		return relation, fmt.Errorf("failed to get values: %v", errors)
+	if len(errs) > 0 {
+		return relation, fmt.Errorf("failed to get values: %w", errors.Join(errs...))
 	}
 	return relation, nil
 }
 
 // lbugRecursiveRelValueToGoValue converts a lbug_value representing a recursive
-// relationship to a RecursiveRelationship struct in Go.
-func lbugRecursiveRelValueToGoValue(lbugValue C.lbug_value) (RecursiveRelationship, error) {
-	var nodesVal C.lbug_value
-	var relsVal C.lbug_value
-	C.lbug_value_get_recursive_rel_node_list(&lbugValue, &nodesVal)
-	C.lbug_value_get_recursive_rel_rel_list(&lbugValue, &relsVal)
-	defer C.lbug_value_destroy(&nodesVal)
-	defer C.lbug_value_destroy(&relsVal)
-	nodes, _ := lbugListValueToGoValue(nodesVal)
-	rels, _ := lbugListValueToGoValue(relsVal)
-	recursiveRel := RecursiveRelationship{}
-	recursiveRel.Nodes = make([]Node, len(nodes))
-	for i, n := range nodes {
-		recursiveRel.Nodes[i] = n.(Node)
-	}
-	relationships := make([]Relationship, len(rels))
-	for i, r := range rels {
-		relationships[i] = r.(Relationship)
-	}
-	recursiveRel.Relationships = relationships
+// relationship to a RecursiveRelationship struct in Go. The nodes and
+// relationships are gotten with one call into the C shim, and decoded in a
+// loop.
+func lbugRecursiveRelValueToGoValue(lbugValue C.lbug_value, converter *valueConverter) (RecursiveRelationship, error) {
+	var limit uint64
+	if converter != nil {
+		limit = converter.maxPathElements
+	}
+	elements, err := fetchPathElements(&lbugValue, limit)
+	if err != nil {
+		return RecursiveRelationship{}, err
+	}
+	defer elements.free()
+	if limit > 0 && elements.numNodes+elements.numRels > limit {
+		return RecursiveRelationship{}, &PathLengthError{Elements: elements.numNodes + elements.numRels, Limit: limit}
+	}
+	recursiveRel := RecursiveRelationship{
+		Nodes:         make([]Node, 0, len(elements.nodes)),
+		Relationships: make([]Relationship, 0, len(elements.rels)),
+	}
+	var errs []error
+	for _, element := range elements.nodes {
+		node, err := lbugNodeValueToGoValue(element, converter)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		recursiveRel.Nodes = append(recursiveRel.Nodes, node)
+	}
+	for _, element := range elements.rels {
+		rel, err := lbugRelValueToGoValue(element, converter)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		recursiveRel.Relationships = append(recursiveRel.Relationships, rel)
+	}
+	if len(errs) > 0 {
+		return recursiveRel, fmt.Errorf("failed to get values: %w", errors.Join(errs...))
+	}
 	return recursiveRel, nil
 }
 
 // lbugListValueToGoValue converts a lbug_value representing a LIST or ARRAY to
 // a slice of any in Go.
-func lbugListValueToGoValue(lbugValue C.lbug_value) ([]any, error) {
+func lbugListValueToGoValue(lbugValue C.lbug_value, converter *valueConverter) ([]any, error) {
 	var listSize C.uint64_t
 	cLogicalType := C.lbug_logical_type{}
 	defer C.lbug_data_type_destroy(&cLogicalType)
@@ -180,81 +271,83 @@ func lbugListValueToGoValue(lbugValue C.lbug_value) ([]any, error) {
 	}
 	list := make([]any, 0, int(listSize))
 	var currentVal C.lbug_value
-	var errors []error
+	var errs []error
 	for i := C.uint64_t(0); i < listSize; i++ {
 		C.lbug_value_get_list_element(&lbugValue, i, &currentVal)
-		value, err := lbugValueToGoValue(currentVal)
+		value, err := lbugValueToGoValue(currentVal, converter)
 		if err != nil {
-			errors = append(errors, err)
+			errs = append(errs, err)
 		}
 		list = append(list, value)
 		C.lbug_value_destroy(&currentVal)
 	}
-	if len(errors) > 0 {
-		return list, fmt.Errorf("failed to get values: %v", errors)
+	if len(errs) > 0 {
+		return list, fmt.Errorf("failed to get values: %w", errors.Join(errs...))
 	}
 	return list, nil
 }
 
 // lbugStructValueToGoValue converts a lbug_value representing a STRUCT to a
 // map of string to any in Go.
-func lbugStructValueToGoValue(lbugValue C.lbug_value) (map[string]any, error) {
+func lbugStructValueToGoValue(lbugValue C.lbug_value, converter *valueConverter) (map[string]any, error) {
 	structure := make(map[string]any)
 	var propertySize C.uint64_t
 	C.lbug_value_get_struct_num_fields(&lbugValue, &propertySize)
 	var currentKey *C.char
 	var currentVal C.lbug_value
-	var errors []error
+	var errs []error
 	for i := C.uint64_t(0); i < propertySize; i++ {
 		C.lbug_value_get_struct_field_name(&lbugValue, i, &currentKey)
 		keyString := C.GoString(currentKey)
 		C.lbug_destroy_string(currentKey)
 		C.lbug_value_get_struct_field_value(&lbugValue, i, &currentVal)
-		value, err := lbugValueToGoValue(currentVal)
+		value, err := lbugValueToGoValue(currentVal, converter)
 		if err != nil {
-			errors = append(errors, err)
+			errs = append(errs, err)
 		}
 		structure[keyString] = value
 		C.lbug_value_destroy(&currentVal)
 	}
-	if len(errors) > 0 {
-		return structure, fmt.Errorf("failed to get values: %v", errors)
+	if len(errs) > 0 {
+		return structure, fmt.Errorf("failed to get values: %w", errors.Join(errs...))
 	}
 	return structure, nil
 }
 
 // lbugMapValueToGoValue converts a lbug_value representing a MAP to a
 // slice of MapItem in Go.
-func lbugMapValueToGoValue(lbugValue C.lbug_value) ([]MapItem, error) {
+func lbugMapValueToGoValue(lbugValue C.lbug_value, converter *valueConverter) ([]MapItem, error) {
 	var mapSize C.uint64_t
 	C.lbug_value_get_map_size(&lbugValue, &mapSize)
 	mapItems := make([]MapItem, 0, int(mapSize))
 	var currentKey C.lbug_value
 	var currentValue C.lbug_value
-	var errors []error
+	var errs []error
 	for i := C.uint64_t(0); i < mapSize; i++ {
 		C.lbug_value_get_map_key(&lbugValue, i, &currentKey)
 		C.lbug_value_get_map_value(&lbugValue, i, &currentValue)
-		key, err := lbugValueToGoValue(currentKey)
+		key, err := lbugValueToGoValue(currentKey, converter)
 		if err != nil {
-			errors = append(errors, err)
+			errs = append(errs, err)
 		}
-		value, err := lbugValueToGoValue(currentValue)
+		value, err := lbugValueToGoValue(currentValue, converter)
 		if err != nil {
-			errors = append(errors, err)
+			errs = append(errs, err)
 		}
 		C.lbug_value_destroy(&currentKey)
 		C.lbug_value_destroy(&currentValue)
 		mapItems = append(mapItems, MapItem{Key: key, Value: value})
 	}
-	if len(errors) > 0 {
-		return mapItems, fmt.Errorf("failed to get values: %v", errors)
+	if len(errs) > 0 {
+		return mapItems, fmt.Errorf("failed to get values: %w", errors.Join(errs...))
 	}
 	return mapItems, nil
 }
 
 // lbugValueToGoValue converts a lbug_value to a corresponding Go value.
-func lbugValueToGoValue(lbugValue C.lbug_value) (any, error) {
+// Values of unsupported types are converted according to the policy of the
+// converter, which may be nil.
+func lbugValueToGoValue(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
 	if C.lbug_value_is_null(&lbugValue) {
 		return nil, nil
 	}
@@ -262,204 +355,341 @@ func lbugValueToGoValue(lbugValue C.lbug_value) (any, error) {
 	defer C.lbug_data_type_destroy(&logicalType)
 	C.lbug_value_get_data_type(&lbugValue, &logicalType)
 	logicalTypeId := C.lbug_data_type_get_id(&logicalType)
-	switch logicalTypeId {
-	case C.LBUG_BOOL:
-		var value C.bool
-		status := C.lbug_value_get_bool(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get bool value with status: %d", status)
-		}
-		return bool(value), nil
-	case C.LBUG_INT64, C.LBUG_SERIAL:
-		var value C.int64_t
-		status := C.lbug_value_get_int64(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get int64 value with status: %d", status)
-		}
-		return int64(value), nil
-	case C.LBUG_INT32:
-		var value C.int32_t
-		status := C.lbug_value_get_int32(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get int32 value with status: %d", status)
-		}
-		return int32(value), nil
-	case C.LBUG_INT16:
-		var value C.int16_t
-		status := C.lbug_value_get_int16(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get int16 value with status: %d", status)
-		}
-		return int16(value), nil
-	case C.LBUG_INT128:
-		var value C.lbug_int128_t
-		status := C.lbug_value_get_int128(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get int128 value with status: %d", status)
-		}
-		return int128ToBigInt(value)
-	case C.LBUG_INT8:
-		var value C.int8_t
-		status := C.lbug_value_get_int8(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get int8 value with status: %d", status)
-		}
-		return int8(value), nil
-	case C.LBUG_UUID:
-		var value *C.char
-		status := C.lbug_value_get_uuid(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get uuid value with status: %d", status)
-		}
-		defer C.lbug_destroy_string(value)
-		uuidString := C.GoString(value)
-		return uuid.Parse(uuidString)
-	case C.LBUG_UINT64:
-		var value C.uint64_t
-		status := C.lbug_value_get_uint64(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get uint64 value with status: %d", status)
-		}
-		return uint64(value), nil
-	case C.LBUG_UINT32:
-		var value C.uint32_t
-		status := C.lbug_value_get_uint32(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get uint32 value with status: %d", status)
-		}
-		return uint32(value), nil
-	case C.LBUG_UINT16:
-		var value C.uint16_t
-		status := C.lbug_value_get_uint16(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get uint16 value with status: %d", status)
-		}
-		return uint16(value), nil
-	case C.LBUG_UINT8:
-		var value C.uint8_t
-		status := C.lbug_value_get_uint8(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get uint8 value with status: %d", status)
-		}
-		return uint8(value), nil
-	case C.LBUG_DOUBLE:
-		var value C.double
-		status := C.lbug_value_get_double(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get double value with status: %d", status)
-		}
-		return float64(value), nil
-	case C.LBUG_FLOAT:
-		var value C.float
-		status := C.lbug_value_get_float(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get float value with status: %d", status)
-		}
-		return float32(value), nil
-	case C.LBUG_STRING:
-		var outString *C.char
-		status := C.lbug_value_get_string(&lbugValue, &outString)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get string value with status: %d", status)
-		}
-		defer C.lbug_destroy_string(outString)
-		return C.GoString(outString), nil
-	case C.LBUG_TIMESTAMP:
-		var value C.lbug_timestamp_t
-		status := C.lbug_value_get_timestamp(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get timestamp value with status: %d", status)
-		}
-		return time.Unix(0, int64(value.value)*1000), nil
-	case C.LBUG_TIMESTAMP_NS:
-		var value C.lbug_timestamp_ns_t
-		status := C.lbug_value_get_timestamp_ns(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get timestamp_ns value with status: %d", status)
-		}
-		return time.Unix(0, int64(value.value)), nil
-	case C.LBUG_TIMESTAMP_MS:
-		var value C.lbug_timestamp_ms_t
-		status := C.lbug_value_get_timestamp_ms(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get timestamp_ms value with status: %d", status)
-		}
-		return time.Unix(0, int64(value.value)*1000000), nil
-	case C.LBUG_TIMESTAMP_SEC:
-		var value C.lbug_timestamp_sec_t
-		status := C.lbug_value_get_timestamp_sec(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get timestamp_sec value with status: %d", status)
-		}
-		return time.Unix(int64(value.value), 0), nil
-	case C.LBUG_TIMESTAMP_TZ:
-		var value C.lbug_timestamp_tz_t
-		status := C.lbug_value_get_timestamp_tz(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get timestamp_tz value with status: %d", status)
-		}
-		return time.Unix(0, int64(value.value)*1000), nil
-	case C.LBUG_DATE:
-		var value C.lbug_date_t
-		status := C.lbug_value_get_date(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get date value with status: %d", status)
-		}
-		return lbugDateToTime(value), nil
-	case C.LBUG_INTERVAL:
-		var value C.lbug_interval_t
-		status := C.lbug_value_get_interval(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get interval value with status: %d", status)
-		}
-		return lbugIntervalToDuration(value), nil
-	case C.LBUG_INTERNAL_ID:
-		var value C.lbug_internal_id_t
-		status := C.lbug_value_get_internal_id(&lbugValue, &value)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get internal_id value with status: %d", status)
-		}
-		return InternalID{TableID: uint64(value.table_id), Offset: uint64(value.offset)}, nil
-	case C.LBUG_BLOB:
-		var value *C.uint8_t
-		var length C.uint64_t
-		status := C.lbug_value_get_blob(&lbugValue, &value, &length)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get blob value with status: %d", status)
-		}
-		defer C.lbug_destroy_blob(value)
-		blob := C.GoBytes(unsafe.Pointer(value), C.int(length))
-		return blob, nil
-	case C.LBUG_NODE:
-		return lbugNodeValueToGoValue(lbugValue)
-	case C.LBUG_REL:
-		return lbugRelValueToGoValue(lbugValue)
-	case C.LBUG_RECURSIVE_REL:
-		return lbugRecursiveRelValueToGoValue(lbugValue)
-	case C.LBUG_LIST, C.LBUG_ARRAY:
-		return lbugListValueToGoValue(lbugValue)
-	case C.LBUG_STRUCT, C.LBUG_UNION:
-		return lbugStructValueToGoValue(lbugValue)
-	case C.LBUG_MAP:
-		return lbugMapValueToGoValue(lbugValue)
-	case C.LBUG_DECIMAL:
-		var outString *C.char
-		status := C.lbug_value_get_decimal_as_string(&lbugValue, &outString)
-		if status != C.LbugSuccess {
-			return nil, fmt.Errorf("failed to get string value of decimal type with status: %d", status)
-		}
-		goString := C.GoString(outString)
-		C.lbug_destroy_string(outString)
-		goDecimal, casting_error := decimal.NewFromString(goString)
-		if casting_error != nil {
-			return nil, fmt.Errorf("failed to convert decimal value with error: %w", casting_error)
+	if unsupportedTypeIDsForTesting[int(logicalTypeId)] {
+		return converter.unsupportedValue(lbugValue, int(logicalTypeId))
+	}
+	if int(logicalTypeId) < len(valueConversions) {
+		if conversion := valueConversions[logicalTypeId]; conversion != nil {
+			return conversion(lbugValue, converter)
 		}
-		return goDecimal, casting_error
-	default:
-		valueString := C.lbug_value_to_string(&lbugValue)
-		defer C.lbug_destroy_string(valueString)
-		return C.GoString(valueString), fmt.Errorf("unsupported data type with type id: %d. the value is force-casted to string", logicalTypeId)
 	}
+	return converter.unsupportedValue(lbugValue, int(logicalTypeId))
+}
+
+// valueConversion converts a lbug_value of a given logical type to a Go value.
+type valueConversion func(lbugValue C.lbug_value, converter *valueConverter) (any, error)
+
+// maxLogicalTypeID bounds the logical type IDs of valueConversions.
+const maxLogicalTypeID = 64
+
+// valueConversions are the conversions of the logical types supported by
+// lbugValueToGoValue, indexed by logical type ID, so that adding a type does
+// not slow down the dispatch of the others. It is filled in init because the
+// conversions of nested values refer to it through lbugValueToGoValue.
+var valueConversions [maxLogicalTypeID]valueConversion
+
+func init() {
+	valueConversions = [maxLogicalTypeID]valueConversion{
+		C.LBUG_BOOL:          lbugBoolValueToGoValue,
+		C.LBUG_INT64:         lbugInt64ValueToGoValue,
+		C.LBUG_SERIAL:        lbugInt64ValueToGoValue,
+		C.LBUG_INT32:         lbugInt32ValueToGoValue,
+		C.LBUG_INT16:         lbugInt16ValueToGoValue,
+		C.LBUG_INT128:        lbugInt128ValueToGoValue,
+		C.LBUG_INT8:          lbugInt8ValueToGoValue,
+		C.LBUG_UUID:          lbugUUIDValueToGoValue,
+		C.LBUG_UINT64:        lbugUint64ValueToGoValue,
+		C.LBUG_UINT32:        lbugUint32ValueToGoValue,
+		C.LBUG_UINT16:        lbugUint16ValueToGoValue,
+		C.LBUG_UINT8:         lbugUint8ValueToGoValue,
+		C.LBUG_DOUBLE:        lbugDoubleValueToGoValue,
+		C.LBUG_FLOAT:         lbugFloatValueToGoValue,
+		C.LBUG_STRING:        lbugStringValueToGoValue,
+		C.LBUG_TIMESTAMP:     lbugTimestampValueToGoValue,
+		C.LBUG_TIMESTAMP_NS:  lbugTimestampNsValueToGoValue,
+		C.LBUG_TIMESTAMP_MS:  lbugTimestampMsValueToGoValue,
+		C.LBUG_TIMESTAMP_SEC: lbugTimestampSecValueToGoValue,
+		C.LBUG_TIMESTAMP_TZ:  lbugTimestampTzValueToGoValue,
+		C.LBUG_DATE:          lbugDateValueToGoValue,
+		C.LBUG_INTERVAL:      lbugIntervalValueToGoValue,
+		C.LBUG_INTERNAL_ID:   lbugInternalIDValueToGoValue,
+		C.LBUG_BLOB:          lbugBlobValueToGoValue,
+		C.LBUG_NODE: func(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
+			return lbugNodeValueToGoValue(lbugValue, converter)
+		},
+		C.LBUG_REL: func(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
+			return lbugRelValueToGoValue(lbugValue, converter)
+		},
+		C.LBUG_RECURSIVE_REL: func(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
+			return lbugRecursiveRelValueToGoValue(lbugValue, converter)
+		},
+		C.LBUG_LIST: func(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
+			return lbugListValueToGoValue(lbugValue, converter)
+		},
+		C.LBUG_ARRAY: func(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
+			return lbugListValueToGoValue(lbugValue, converter)
+		},
+		C.LBUG_STRUCT: func(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
+			return lbugStructValueToGoValue(lbugValue, converter)
+		},
+		C.LBUG_UNION: func(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
+			return lbugStructValueToGoValue(lbugValue, converter)
+		},
+		C.LBUG_MAP: func(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
+			return lbugMapValueToGoValue(lbugValue, converter)
+		},
+		C.LBUG_DECIMAL: lbugDecimalValueToGoValue,
+	}
+}
+
+// lbugBoolValueToGoValue converts a lbug_value representing a BOOL to a bool.
+func lbugBoolValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value C.bool
+	status := C.lbug_value_get_bool(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get bool value with status: %d", status)
+	}
+	return bool(value), nil
+}
+
+// lbugInt64ValueToGoValue converts a lbug_value representing an INT64 or SERIAL to an int64.
+func lbugInt64ValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value C.int64_t
+	status := C.lbug_value_get_int64(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get int64 value with status: %d", status)
+	}
+	return int64(value), nil
+}
+
+// lbugInt32ValueToGoValue converts a lbug_value representing an INT32 to an int32.
+func lbugInt32ValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value C.int32_t
+	status := C.lbug_value_get_int32(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get int32 value with status: %d", status)
+	}
+	return int32(value), nil
+}
+
+// lbugInt16ValueToGoValue converts a lbug_value representing an INT16 to an int16.
+func lbugInt16ValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value C.int16_t
+	status := C.lbug_value_get_int16(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get int16 value with status: %d", status)
+	}
+	return int16(value), nil
+}
+
+// lbugInt128ValueToGoValue converts a lbug_value representing an INT128 to a *big.Int.
+func lbugInt128ValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value C.lbug_int128_t
+	status := C.lbug_value_get_int128(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get int128 value with status: %d", status)
+	}
+	return int128ToBigInt(value)
+}
+
+// lbugInt8ValueToGoValue converts a lbug_value representing an INT8 to an int8.
+func lbugInt8ValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value C.int8_t
+	status := C.lbug_value_get_int8(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get int8 value with status: %d", status)
+	}
+	return int8(value), nil
+}
+
+// lbugUUIDValueToGoValue converts a lbug_value representing a UUID to a uuid.UUID.
+func lbugUUIDValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value *C.char
+	status := C.lbug_value_get_uuid(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get uuid value with status: %d", status)
+	}
+	defer C.lbug_destroy_string(value)
+	uuidString := C.GoString(value)
+	return uuid.Parse(uuidString)
+}
+
+// lbugUint64ValueToGoValue converts a lbug_value representing a UINT64 to a uint64.
+func lbugUint64ValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value C.uint64_t
+	status := C.lbug_value_get_uint64(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get uint64 value with status: %d", status)
+	}
+	return uint64(value), nil
+}
+
+// lbugUint32ValueToGoValue converts a lbug_value representing a UINT32 to a uint32.
+func lbugUint32ValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value C.uint32_t
+	status := C.lbug_value_get_uint32(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get uint32 value with status: %d", status)
+	}
+	return uint32(value), nil
+}
+
+// lbugUint16ValueToGoValue converts a lbug_value representing a UINT16 to a uint16.
+func lbugUint16ValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value C.uint16_t
+	status := C.lbug_value_get_uint16(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get uint16 value with status: %d", status)
+	}
+	return uint16(value), nil
+}
+
+// lbugUint8ValueToGoValue converts a lbug_value representing a UINT8 to a uint8.
+func lbugUint8ValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value C.uint8_t
+	status := C.lbug_value_get_uint8(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get uint8 value with status: %d", status)
+	}
+	return uint8(value), nil
+}
+
+// lbugDoubleValueToGoValue converts a lbug_value representing a DOUBLE to a float64.
+func lbugDoubleValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value C.double
+	status := C.lbug_value_get_double(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get double value with status: %d", status)
+	}
+	return float64(value), nil
+}
+
+// lbugFloatValueToGoValue converts a lbug_value representing a FLOAT to a float32.
+func lbugFloatValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value C.float
+	status := C.lbug_value_get_float(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get float value with status: %d", status)
+	}
+	return float32(value), nil
+}
+
+// lbugStringValueToGoValue converts a lbug_value representing a STRING to a string.
+// lbug_value_get_string returns a NUL-terminated copy of the string without
+// its length, so C.GoString measures the copy before copying it again; the
+// C API has no length-aware accessor of strings. Unlike blobs, the strings
+// stored by the bindings never hold NUL bytes, see SetValidateStrings.
+func lbugStringValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var outString *C.char
+	status := C.lbug_value_get_string(&lbugValue, &outString)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get string value with status: %d", status)
+	}
+	defer C.lbug_destroy_string(outString)
+	return C.GoString(outString), nil
+}
+
+// lbugTimestampValueToGoValue converts a lbug_value representing a TIMESTAMP to a time.Time.
+func lbugTimestampValueToGoValue(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
+	var value C.lbug_timestamp_t
+	status := C.lbug_value_get_timestamp(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get timestamp value with status: %d", status)
+	}
+	return converter.timeValue(RawTime{Type: "TIMESTAMP", Value: int64(value.value)})
+}
+
+// lbugTimestampNsValueToGoValue converts a lbug_value representing a TIMESTAMP_NS to a time.Time.
+func lbugTimestampNsValueToGoValue(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
+	var value C.lbug_timestamp_ns_t
+	status := C.lbug_value_get_timestamp_ns(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get timestamp_ns value with status: %d", status)
+	}
+	return converter.timeValue(RawTime{Type: "TIMESTAMP_NS", Value: int64(value.value)})
+}
+
+// lbugTimestampMsValueToGoValue converts a lbug_value representing a TIMESTAMP_MS to a time.Time.
+func lbugTimestampMsValueToGoValue(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
+	var value C.lbug_timestamp_ms_t
+	status := C.lbug_value_get_timestamp_ms(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get timestamp_ms value with status: %d", status)
+	}
+	return converter.timeValue(RawTime{Type: "TIMESTAMP_MS", Value: int64(value.value)})
+}
+
+// lbugTimestampSecValueToGoValue converts a lbug_value representing a TIMESTAMP_SEC to a time.Time.
+func lbugTimestampSecValueToGoValue(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
+	var value C.lbug_timestamp_sec_t
+	status := C.lbug_value_get_timestamp_sec(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get timestamp_sec value with status: %d", status)
+	}
+	return converter.timeValue(RawTime{Type: "TIMESTAMP_SEC", Value: int64(value.value)})
+}
+
+// lbugTimestampTzValueToGoValue converts a lbug_value representing a TIMESTAMP_TZ to a time.Time.
+func lbugTimestampTzValueToGoValue(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
+	var value C.lbug_timestamp_tz_t
+	status := C.lbug_value_get_timestamp_tz(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get timestamp_tz value with status: %d", status)
+	}
+	return converter.timeValue(RawTime{Type: "TIMESTAMP_TZ", Value: int64(value.value)})
+}
+
+// lbugDateValueToGoValue converts a lbug_value representing a DATE to a time.Time.
+func lbugDateValueToGoValue(lbugValue C.lbug_value, converter *valueConverter) (any, error) {
+	var value C.lbug_date_t
+	status := C.lbug_value_get_date(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get date value with status: %d", status)
+	}
+	return converter.timeValue(RawTime{Type: "DATE", Value: int64(value.days)})
+}
+
+// lbugIntervalValueToGoValue converts a lbug_value representing an INTERVAL to a time.Duration.
+func lbugIntervalValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value C.lbug_interval_t
+	status := C.lbug_value_get_interval(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get interval value with status: %d", status)
+	}
+	return lbugIntervalToDuration(value), nil
+}
+
+// lbugInternalIDValueToGoValue converts a lbug_value representing an INTERNAL_ID to an InternalID.
+func lbugInternalIDValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value C.lbug_internal_id_t
+	status := C.lbug_value_get_internal_id(&lbugValue, &value)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get internal_id value with status: %d", status)
+	}
+	return InternalID{TableID: uint64(value.table_id), Offset: uint64(value.offset)}, nil
+}
+
+// lbugBlobValueToGoValue converts a lbug_value representing a BLOB to a []byte.
+func lbugBlobValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var value *C.uint8_t
+	var length C.uint64_t
+	status := C.lbug_value_get_blob(&lbugValue, &value, &length)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get blob value with status: %d", status)
+	}
+	defer C.lbug_destroy_blob(value)
+	// Copied in one pass with the length returned by the engine: blobs may
+	// hold NUL bytes, and C.GoBytes takes a C.int, which truncates the
+	// length of blobs of 2 GiB or more.
+	blob := make([]byte, uint64(length))
+	copy(blob, unsafe.Slice((*byte)(unsafe.Pointer(value)), uint64(length)))
+	return blob, nil
+}
+
+// lbugDecimalValueToGoValue converts a lbug_value representing a DECIMAL to a decimal.Decimal.
+func lbugDecimalValueToGoValue(lbugValue C.lbug_value, _ *valueConverter) (any, error) {
+	var outString *C.char
+	status := C.lbug_value_get_decimal_as_string(&lbugValue, &outString)
+	if status != C.LbugSuccess {
+		return nil, fmt.Errorf("failed to get string value of decimal type with status: %d", status)
+	}
+	goString := C.GoString(outString)
+	C.lbug_destroy_string(outString)
+	goDecimal, casting_error := decimal.NewFromString(goString)
+	if casting_error != nil {
+		return nil, fmt.Errorf("failed to convert decimal value with error: %w", casting_error)
+	}
+	return goDecimal, casting_error
 }
 
 // int128ToBigInt converts a lbug_int128_t to a big.Int in Go.
@@ -497,14 +727,15 @@ func goMapToLbugStruct(value map[string]any) (*C.lbug_value, error) {
 	}
 	sort.Strings(sortedKeys)
 	for _, k := range sortedKeys {
-		fieldNames = append(fieldNames, C.CString(k))
+		cName := C.CString(k)
+		defer C.free(unsafe.Pointer(cName))
+		fieldNames = append(fieldNames, cName)
 		lbugValue, error := goValueToLbugValue(value[k])
 		if error != nil {
 			return nil, fmt.Errorf("failed to convert value in the map with error: %w", error)
 		}
 		fieldValues = append(fieldValues, lbugValue)
 		defer C.lbug_value_destroy(lbugValue)
-		defer C.free(unsafe.Pointer(C.CString(k)))
 	}
 
 	var lbugValue *C.lbug_value
@@ -573,11 +804,74 @@ func goSliceToLbugList(slice []any) (*C.lbug_value, error) {
 	return lbugValue, nil
 }
 
-// lbugValueToGoValue converts a Go value to a lbug_value.
+// jsonToLbugString returns a STRING value holding the JSON document, for
+// parameters of type json.RawMessage and of types implementing json.Marshaler
+// that are bound to STRING properties holding JSON.
+func jsonToLbugString(document []byte) (*C.lbug_value, error) {
+	cDocument := C.CString(string(document))
+	defer C.free(unsafe.Pointer(cDocument))
+	return C.lbug_value_create_string(cDocument), nil
+}
+
+// isNilContainer returns true if the value is a nil slice or map, which is
+// bound as NULL rather than as an empty LIST, MAP or STRUCT.
+func isNilContainer(value any) bool {
+	reflectValue := reflect.ValueOf(value)
+	kind := reflectValue.Kind()
+	return (kind == reflect.Slice || kind == reflect.Map) && reflectValue.IsNil()
+}
+
+// isNilPointer returns true if the value is a nil pointer.
+func isNilPointer(value any) bool {
+	reflectValue := reflect.ValueOf(value)
+	return reflectValue.Kind() == reflect.Pointer && reflectValue.IsNil()
+}
+
+// goValueToLbugValue converts a Go value to a lbug_value. nil is converted to
+// NULL, and nil pointers, slices and maps to a NULL of the type of their
+// elements if it can be derived from the Go type, e.g. INT64[] for a nil
+// []int64. time.Time is converted to a TIMESTAMP of its instant, regardless of
+// its location, or a TIMESTAMP_NS if it has nanoseconds; Date and TimestampTZ
+// select DATE and TIMESTAMP_TZ instead. A time outside the range of its type
+// is rejected with an error matching ErrTimeOutOfRange.
+// Besides the types of the switch, slices and arrays are converted to LIST,
+// maps with string keys and structs to STRUCT, other maps to MAP, named types
+// to their underlying type and pointers to the value they point to. The C API
+// cannot create empty MAP or STRUCT values or empty LIST values of unknown
+// element types, so empty maps, structs without exported fields and empty
+// []any are rejected.
+//
+// The conversion of a value is chosen in this order: a value implementing
+// LbugValuer is converted to the result of LbugValue, before any other rule
+// and regardless of its kind, so a struct implementing it is never converted
+// by its lbug tags; then the types of the switch; then json.Marshaler, bound
+// as a JSON document; then encoding.TextMarshaler, bound as a STRING; then the
+// conversions by kind. Nil pointers are converted to NULL without calling
+// their methods.
+//
+// No Go memory is passed to the C API beyond the duration of a call: strings,
+// blobs and field names are copied to C memory, which is freed once the value
+// is created since the C API copies it into the value, and the slices of
+// values and names passed to the constructors of LIST, MAP and STRUCT values
+// hold only C pointers. Binding a value copies it into the statement in turn,
+// so the value returned is destroyed by the caller once bound.
 func goValueToLbugValue(value any) (*C.lbug_value, error) {
 	if value == nil {
 		return C.lbug_value_create_null(), nil
 	}
+	if isNilContainer(value) || isNilPointer(value) {
+		return typedLbugNull(reflect.TypeOf(value)), nil
+	}
+	if valuer, ok := value.(LbugValuer); ok {
+		converted, err := valuer.LbugValue()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %T with LbugValue: %w", value, err)
+		}
+		if _, ok := converted.(LbugValuer); ok {
+			return nil, fmt.Errorf("LbugValue of %T returned a LbugValuer, %T", value, converted)
+		}
+		return goValueToLbugValue(converted)
+	}
 	var lbugValue *C.lbug_value
 	switch v := value.(type) {
 	case bool:
@@ -607,16 +901,66 @@ func goValueToLbugValue(value any) (*C.lbug_value, error) {
 	case float32:
 		lbugValue = C.lbug_value_create_float(C.float(v))
 	case string:
-		lbugValue = C.lbug_value_create_string(C.CString(v))
+		// The value holds a copy of the string, so the C copy of the string is
+		// freed as soon as the value is created.
+		cString := C.CString(v)
+		defer C.free(unsafe.Pointer(cString))
+		lbugValue = C.lbug_value_create_string(cString)
 	case time.Time:
 		if timeHasNanoseconds(v) {
-			lbugValue = C.lbug_value_create_timestamp_ns(timeToLbugTimestampNs(v))
+			timestamp, err := timeToLbugTimestampNs(v)
+			if err != nil {
+				return nil, err
+			}
+			lbugValue = C.lbug_value_create_timestamp_ns(timestamp)
 		} else {
-			lbugValue = C.lbug_value_create_timestamp(timeToLbugTimestamp(v))
+			timestamp, err := timeToLbugTimestamp(v)
+			if err != nil {
+				return nil, err
+			}
+			lbugValue = C.lbug_value_create_timestamp(timestamp)
 		}
 	case time.Duration:
 		interval := durationToLbugInterval(v)
 		lbugValue = C.lbug_value_create_interval(interval)
+	case Interval:
+		lbugValue = C.lbug_value_create_interval(C.lbug_interval_t{
+			months: C.int32_t(v.Months),
+			days:   C.int32_t(v.Days),
+			micros: C.int64_t(v.Micros),
+		})
+	case InternalID:
+		lbugValue = C.lbug_value_create_internal_id(C.lbug_internal_id_t{
+			table_id: C.uint64_t(v.TableID),
+			offset:   C.uint64_t(v.Offset),
+		})
+	case Node:
+		return goValueToLbugValue(v.ID)
+	case Relationship:
+		return goValueToLbugValue(v.ID)
+	case RecursiveRelationship:
+		return nil, fmt.Errorf("a RecursiveRelationship cannot be bound as a parameter: bind the IDs of its nodes or relationships instead")
+	case Date:
+		date, err := timeToLbugDate(v.day())
+		if err != nil {
+			return nil, err
+		}
+		lbugValue = C.lbug_value_create_date(date)
+	case TimestampTZ:
+		timestamp, err := timeToLbugTimestamp(time.Time(v))
+		if err != nil {
+			return nil, err
+		}
+		lbugValue = C.lbug_value_create_timestamp_tz(C.lbug_timestamp_tz_t{value: timestamp.value})
+	case Blob:
+		cEscaped, err := v.cEscapedBlob()
+		if err != nil {
+			return nil, err
+		}
+		defer C.free(unsafe.Pointer(cEscaped))
+		lbugValue = C.lbug_value_create_string(cEscaped)
+	case json.RawMessage:
+		return jsonToLbugString(v)
 	case map[string]any:
 		return goMapToLbugStruct(v)
 	case []MapItem:
@@ -624,15 +968,214 @@ func goValueToLbugValue(value any) (*C.lbug_value, error) {
 	case []any:
 		return goSliceToLbugList(v)
 	default:
-		if reflect.TypeOf(value).Kind() == reflect.Slice {
-			sliceValue := reflect.ValueOf(value)
-			slice := make([]any, sliceValue.Len())
-			for i := 0; i < sliceValue.Len(); i++ {
-				slice[i] = sliceValue.Index(i).Interface()
+		if marshaler, ok := value.(json.Marshaler); ok {
+			encoded, err := marshaler.MarshalJSON()
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal %T to JSON: %w", value, err)
 			}
-			return goSliceToLbugList(slice)
+			return jsonToLbugString(encoded)
 		}
-		return nil, fmt.Errorf("unsupported type: %T", v)
+		if marshaler, ok := value.(encoding.TextMarshaler); ok {
+			text, err := marshaler.MarshalText()
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal %T to text: %w", value, err)
+			}
+			return goValueToLbugValue(string(text))
+		}
+		return goReflectValueToLbugValue(reflect.ValueOf(value))
 	}
 	return lbugValue, nil
 }
+
+// goReflectValueToLbugValue converts the values of the types not handled by
+// the switch of goValueToLbugValue by their kind.
+func goReflectValueToLbugValue(value reflect.Value) (*C.lbug_value, error) {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			return typedLbugNull(value.Type()), nil
+		}
+		return goValueToLbugValue(value.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if value.Len() == 0 {
+			return emptyLbugList(value.Type())
+		}
+		slice := make([]any, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			slice[i] = value.Index(i).Interface()
+		}
+		return goSliceToLbugList(slice)
+	case reflect.Map:
+		if value.Type().Key().Kind() == reflect.String {
+			fields := make(map[string]any, value.Len())
+			for iter := value.MapRange(); iter.Next(); {
+				fields[iter.Key().String()] = iter.Value().Interface()
+			}
+			return goMapToLbugStruct(fields)
+		}
+		items := make([]MapItem, 0, value.Len())
+		for iter := value.MapRange(); iter.Next(); {
+			items = append(items, MapItem{Key: iter.Key().Interface(), Value: iter.Value().Interface()})
+		}
+		// Sort the items so that maps with the same entries are converted to
+		// the same MAP value.
+		sort.Slice(items, func(i, j int) bool { return fmt.Sprint(items[i].Key) < fmt.Sprint(items[j].Key) })
+		return goSliceOfMapItemsToLbugMap(items)
+	case reflect.Struct:
+		fields := make(map[string]any)
+		valueType := value.Type()
+		for i := 0; i < valueType.NumField(); i++ {
+			if name, ok := structFieldName(valueType.Field(i)); ok {
+				fields[name] = value.Field(i).Interface()
+			}
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("unsupported type: %s has no exported fields", valueType)
+		}
+		return goMapToLbugStruct(fields)
+	case reflect.Bool:
+		return goValueToLbugValue(value.Bool())
+	case reflect.Int, reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8,
+		reflect.Uint, reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8,
+		reflect.Float64, reflect.Float32, reflect.String:
+		if basic, ok := basicTypes[value.Kind()]; ok {
+			return goValueToLbugValue(value.Convert(basic).Interface())
+		}
+	}
+	return nil, fmt.Errorf("unsupported type: %s", value.Type())
+}
+
+// basicTypes are the predeclared types that values of named types of the
+// kinds are converted to.
+var basicTypes = map[reflect.Kind]reflect.Type{
+	reflect.Int:     reflect.TypeOf(int(0)),
+	reflect.Int64:   reflect.TypeOf(int64(0)),
+	reflect.Int32:   reflect.TypeOf(int32(0)),
+	reflect.Int16:   reflect.TypeOf(int16(0)),
+	reflect.Int8:    reflect.TypeOf(int8(0)),
+	reflect.Uint:    reflect.TypeOf(uint(0)),
+	reflect.Uint64:  reflect.TypeOf(uint64(0)),
+	reflect.Uint32:  reflect.TypeOf(uint32(0)),
+	reflect.Uint16:  reflect.TypeOf(uint16(0)),
+	reflect.Uint8:   reflect.TypeOf(uint8(0)),
+	reflect.Float64: reflect.TypeOf(float64(0)),
+	reflect.Float32: reflect.TypeOf(float32(0)),
+	reflect.String:  reflect.TypeOf(""),
+}
+
+// structFieldName returns the name of the property or STRUCT field of a
+// struct field, which is the name of the field or its "lbug" tag. It returns
+// false for unexported and embedded fields and fields tagged "-".
+func structFieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() || field.Anonymous {
+		return "", false
+	}
+	tag, ok := field.Tag.Lookup("lbug")
+	switch {
+	case tag == "-":
+		return "", false
+	case ok && tag != "":
+		return tag, true
+	}
+	return field.Name, true
+}
+
+// Types with a fixed logical type, for typed NULLs and empty lists.
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	durationType    = reflect.TypeOf(time.Duration(0))
+	intervalType    = reflect.TypeOf(Interval{})
+	dateType        = reflect.TypeOf(Date{})
+	timestampTZType = reflect.TypeOf(TimestampTZ{})
+	blobType        = reflect.TypeOf(Blob{})
+	rawJSONType     = reflect.TypeOf(json.RawMessage(nil))
+	internalIDType  = reflect.TypeOf(InternalID{})
+	nodeValueType   = reflect.TypeOf(Node{})
+	relValueType    = reflect.TypeOf(Relationship{})
+)
+
+// boundGoTypes are the logical types of the Go types of the switch of
+// goValueToLbugValue that are converted to a single logical type. time.Time is
+// converted to a TIMESTAMP_NS instead of a TIMESTAMP if it has nanoseconds.
+var boundGoTypes = map[reflect.Type]C.lbug_data_type_id{
+	timeType:        C.LBUG_TIMESTAMP,
+	durationType:    C.LBUG_INTERVAL,
+	intervalType:    C.LBUG_INTERVAL,
+	dateType:        C.LBUG_DATE,
+	timestampTZType: C.LBUG_TIMESTAMP_TZ,
+	blobType:        C.LBUG_STRING,
+	rawJSONType:     C.LBUG_STRING,
+	internalIDType:  C.LBUG_INTERNAL_ID,
+	nodeValueType:   C.LBUG_INTERNAL_ID,
+	relValueType:    C.LBUG_INTERNAL_ID,
+}
+
+// logicalTypeIDs are the logical types of the Go types that map to a single
+// logical type by their kind.
+var logicalTypeIDs = map[reflect.Kind]C.lbug_data_type_id{
+	reflect.Bool:    C.LBUG_BOOL,
+	reflect.Int:     C.LBUG_INT64,
+	reflect.Int64:   C.LBUG_INT64,
+	reflect.Int32:   C.LBUG_INT32,
+	reflect.Int16:   C.LBUG_INT16,
+	reflect.Int8:    C.LBUG_INT8,
+	reflect.Uint:    C.LBUG_UINT64,
+	reflect.Uint64:  C.LBUG_UINT64,
+	reflect.Uint32:  C.LBUG_UINT32,
+	reflect.Uint16:  C.LBUG_UINT16,
+	reflect.Uint8:   C.LBUG_UINT8,
+	reflect.Float64: C.LBUG_DOUBLE,
+	reflect.Float32: C.LBUG_FLOAT,
+	reflect.String:  C.LBUG_STRING,
+}
+
+// goTypeToLbugType creates the logical type that values of the Go type are
+// converted to, which must be destroyed by the caller. It returns false if
+// the type cannot be derived from the Go type, e.g. for interfaces, maps and
+// structs.
+func goTypeToLbugType(goType reflect.Type) (C.lbug_logical_type, bool) {
+	var logicalType C.lbug_logical_type
+	id, ok := logicalTypeIDs[goType.Kind()]
+	if boundID, found := boundGoTypes[goType]; found {
+		id, ok = boundID, true
+	}
+	if ok {
+		C.lbug_data_type_create(id, nil, 0, &logicalType)
+		return logicalType, true
+	}
+	switch goType.Kind() {
+	case reflect.Pointer:
+		return goTypeToLbugType(goType.Elem())
+	case reflect.Slice, reflect.Array:
+		child, ok := goTypeToLbugType(goType.Elem())
+		if !ok {
+			return logicalType, false
+		}
+		defer C.lbug_data_type_destroy(&child)
+		C.lbug_data_type_create(C.LBUG_LIST, &child, 0, &logicalType)
+		return logicalType, true
+	}
+	return logicalType, false
+}
+
+// typedLbugNull returns a NULL of the logical type of the Go type, or an
+// untyped NULL if it cannot be derived from the Go type.
+func typedLbugNull(goType reflect.Type) *C.lbug_value {
+	logicalType, ok := goTypeToLbugType(goType)
+	if !ok {
+		return C.lbug_value_create_null()
+	}
+	defer C.lbug_data_type_destroy(&logicalType)
+	return C.lbug_value_create_null_with_data_type(&logicalType)
+}
+
+// emptyLbugList returns an empty LIST of the element type of the Go slice or
+// array type.
+func emptyLbugList(goType reflect.Type) (*C.lbug_value, error) {
+	logicalType, ok := goTypeToLbugType(goType)
+	if !ok {
+		return nil, fmt.Errorf("failed to create LIST value because the slice is empty and the element type %s has no logical type", goType.Elem())
+	}
+	defer C.lbug_data_type_destroy(&logicalType)
+	return C.lbug_value_create_default(&logicalType), nil
+}
@@ -1,6 +1,9 @@
 package lbug
 
 import (
+	"encoding/json"
+	"errors"
+	"math"
 	"math/big"
 	"testing"
 	"time"
@@ -15,6 +18,7 @@ func TestBool(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.True(t, value.(bool))
 	res.Close()
@@ -26,6 +30,7 @@ func TestInt64(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, int64(35), value)
 	res.Close()
@@ -37,6 +42,7 @@ func TestInt32(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, int32(170), value)
 	res.Close()
@@ -48,6 +54,7 @@ func TestInt16(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, int16(888), value)
 	res.Close()
@@ -59,6 +66,7 @@ func TestInt8(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, int8(5), value)
 	res.Close()
@@ -70,6 +78,7 @@ func TestUint64(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, uint64(9223372036854775808), value)
 	res.Close()
@@ -80,6 +89,7 @@ func TestUint32(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, uint32(32800), value)
 	res.Close()
@@ -90,6 +100,7 @@ func TestUint16(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, uint16(33768), value)
 	res.Close()
@@ -100,6 +111,7 @@ func TestUint8(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, uint8(250), value)
 	res.Close()
@@ -109,8 +121,10 @@ func TestInt128(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	res, error := conn.Query("RETURN CAST (18446744073709551610, \"INT128\")")
 	assert.Nil(t, error)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	int128Value := value.(*big.Int)
 	assert.Equal(t, "18446744073709551610", int128Value.String())
@@ -119,6 +133,7 @@ func TestInt128(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ = res.Next()
+	defer next.Close()
 	value, _ = next.GetValue(0)
 	int128Value = value.(*big.Int)
 	assert.Equal(t, "-18446744073709551610", int128Value.String())
@@ -131,6 +146,7 @@ func TestSerial(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, int64(2), value)
 	res.Close()
@@ -142,6 +158,7 @@ func TestDouble(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.InDelta(t, float64(5.0), value, floatEpsilon)
 	res.Close()
@@ -153,6 +170,7 @@ func TestFloat(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.InDelta(t, float32(1.75), value, floatEpsilon)
 	res.Close()
@@ -164,6 +182,7 @@ func TestString(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, "Alice", value)
 	res.Close()
@@ -175,6 +194,7 @@ func TestBlob(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, byte(0xAA), value.([]byte)[0])
 	assert.Equal(t, byte(0xBB), value.([]byte)[1])
@@ -189,6 +209,7 @@ func TestDate(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	time := value.(time.Time)
 	assert.Equal(t, 1985, time.Year())
@@ -198,12 +219,79 @@ func TestDate(t *testing.T) {
 	res.Close()
 }
 
+func TestExtremeDates(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	preparedStatement, err := conn.Prepare("RETURN $p")
+	assert.Nil(t, err)
+	defer preparedStatement.Close()
+	read := func(value any) (any, error) {
+		res, err := conn.Execute(preparedStatement, map[string]any{"p": value})
+		if err != nil {
+			return nil, err
+		}
+		defer res.Close()
+		next, err := res.Next()
+		assert.Nil(t, err)
+		defer next.Close()
+		return next.GetValue(0)
+	}
+	// Dates before the Gregorian calendar and beyond the range of
+	// time.Duration are exact.
+	old := time.Date(1500, time.March, 1, 0, 0, 0, 0, time.UTC)
+	value, err := read(Date(old))
+	assert.Nil(t, err)
+	assert.Equal(t, old, value)
+	value, err = read(old.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.True(t, old.Add(time.Hour).Equal(value.(time.Time)))
+
+	lastDay := time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+	value, err = read(Date(lastDay))
+	assert.Nil(t, err)
+	assert.Equal(t, lastDay, value)
+	last := time.Date(9999, time.December, 31, 23, 59, 59, 999999000, time.UTC)
+	value, err = read(last)
+	assert.Nil(t, err)
+	assert.True(t, last.Equal(value.(time.Time)))
+	first := time.Date(0, time.January, 1, 0, 0, 0, 0, time.UTC)
+	value, err = read(first)
+	assert.Nil(t, err)
+	assert.True(t, first.Equal(value.(time.Time)))
+
+	_, err = read(Date(lastDay.AddDate(0, 0, 1)))
+	assert.ErrorIs(t, err, ErrTimeOutOfRange)
+	var rangeErr *TimeRangeError
+	assert.ErrorAs(t, err, &rangeErr)
+	assert.Equal(t, "DATE", rangeErr.Raw.Type)
+	_, err = read(last.Add(time.Microsecond))
+	assert.ErrorIs(t, err, ErrTimeOutOfRange)
+	_, err = read(first.Add(-time.Microsecond))
+	assert.ErrorIs(t, err, ErrTimeOutOfRange)
+
+	conn.SetTimeRangePolicy(TimeRangeLenient)
+	value, err = read(last.Add(time.Microsecond))
+	assert.Nil(t, err)
+	assert.Equal(t, RawTime{Type: "TIMESTAMP", Value: last.UnixMicro() + 1}, value)
+	value, err = read(maxTimestamp)
+	assert.Nil(t, err)
+	assert.Equal(t, RawTime{Type: "TIMESTAMP", Value: math.MaxInt64}, value)
+
+	// Times outside the range of their type are not bound.
+	_, err = read(maxTimestamp.Add(time.Microsecond))
+	assert.ErrorIs(t, err, ErrTimeOutOfRange)
+	_, err = read(TimestampTZ(minTimestamp.Add(-time.Microsecond)))
+	assert.ErrorIs(t, err, ErrTimeOutOfRange)
+	_, err = read(maxTimestampNs.Add(time.Nanosecond))
+	assert.ErrorIs(t, err, ErrTimeOutOfRange)
+}
+
 func TestTimestamp(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	res, error := conn.Query("RETURN TIMESTAMP('1970-01-01T00:00:00Z')")
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	time := value.(time.Time)
 	time = time.UTC()
@@ -220,6 +308,7 @@ func TestTimestampNs(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	preparedStatement, error := conn.Prepare("RETURN $1")
 	assert.Nil(t, error)
+	defer preparedStatement.Close()
 	params := map[string]interface{}{
 		"1": time.Date(1970, 1, 1, 0, 0, 0, 1, time.UTC),
 	}
@@ -227,6 +316,7 @@ func TestTimestampNs(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	time := value.(time.Time)
 	time = time.UTC()
@@ -244,6 +334,7 @@ func TestTimestampMs(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	preparedStatement, error := conn.Prepare("RETURN CAST ($1, \"TIMESTAMP_MS\")")
 	assert.Nil(t, error)
+	defer preparedStatement.Close()
 	inputTime, error := time.Parse(time.RFC3339, "2024-08-29T10:03:05Z")
 	// Add 3 milliseconds
 	duration, err := time.ParseDuration("3ms")
@@ -257,8 +348,10 @@ func TestTimestampMs(t *testing.T) {
 	}
 	res, error := conn.Execute(preparedStatement, params)
 	assert.Nil(t, error)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, inputTime.Local(), value)
 }
@@ -267,6 +360,7 @@ func TestTimestampSec(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	preparedStatement, error := conn.Prepare("RETURN CAST ($1, \"TIMESTAMP_SEC\")")
 	assert.Nil(t, error)
+	defer preparedStatement.Close()
 	inputTime, error := time.Parse(time.RFC3339, "2024-08-29T10:03:05Z")
 	assert.Nil(t, error)
 	params := map[string]interface{}{
@@ -274,8 +368,10 @@ func TestTimestampSec(t *testing.T) {
 	}
 	res, error := conn.Execute(preparedStatement, params)
 	assert.Nil(t, error)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, inputTime.Local(), value)
 }
@@ -284,6 +380,7 @@ func TestTimestampTz(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	preparedStatement, error := conn.Prepare("RETURN CAST ($1, \"TIMESTAMP_TZ\")")
 	assert.Nil(t, error)
+	defer preparedStatement.Close()
 	inputTime, error := time.Parse(time.RFC3339, "2024-08-29T10:03:05Z")
 	assert.Nil(t, error)
 	params := map[string]interface{}{
@@ -291,8 +388,10 @@ func TestTimestampTz(t *testing.T) {
 	}
 	res, error := conn.Execute(preparedStatement, params)
 	assert.Nil(t, error)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, inputTime.Local(), value)
 }
@@ -301,18 +400,85 @@ func TestInterval(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	res, error := conn.Query("RETURN INTERVAL(\"3 days\");")
 	assert.Nil(t, error)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, time.Duration(3*24*time.Hour), value)
 }
 
+func TestIntervalGetDuration(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	res, err := conn.Query("RETURN INTERVAL(\"3 days 4 hours\"), INTERVAL(\"-2 days 30 minutes\"), CAST(1500000, \"INT64\");")
+	assert.Nil(t, err)
+	assert.True(t, res.HasNext())
+	next, _ := res.Next()
+	duration, err := next.GetDuration(0)
+	assert.Nil(t, err)
+	assert.Equal(t, 3*24*time.Hour+4*time.Hour, duration)
+	duration, err = next.GetDuration(1)
+	assert.Nil(t, err)
+	assert.Equal(t, -2*24*time.Hour+30*time.Minute, duration)
+	duration, err = next.GetDuration(2)
+	assert.Nil(t, err)
+	assert.Equal(t, 1500*time.Millisecond, duration)
+	next.Close()
+	res.Close()
+}
+
+func TestIntervalGetDurationLossy(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	res, err := conn.Query("RETURN INTERVAL(\"1 month 2 days\"), INTERVAL(\"300 years\");")
+	assert.Nil(t, err)
+	assert.True(t, res.HasNext())
+	next, _ := res.Next()
+	_, err = next.GetDuration(0)
+	assert.True(t, errors.Is(err, ErrLossyIntervalConversion))
+	var conversionErr *IntervalConversionError
+	assert.True(t, errors.As(err, &conversionErr))
+	assert.Equal(t, Interval{Months: 1, Days: 2}, conversionErr.Interval)
+	interval, err := next.GetInterval(0)
+	assert.Nil(t, err)
+	assert.Equal(t, Interval{Months: 1, Days: 2}, interval)
+	_, err = next.GetDuration(1)
+	assert.NotNil(t, err)
+	next.Close()
+	res.Close()
+}
+
+func TestIntervalToDuration(t *testing.T) {
+	duration, err := Interval{Days: 1, Micros: 1000}.ToDuration()
+	assert.Nil(t, err)
+	assert.Equal(t, 24*time.Hour+time.Millisecond, duration)
+	duration, err = Interval{Days: -1, Micros: -1}.ToDuration()
+	assert.Nil(t, err)
+	assert.Equal(t, -24*time.Hour-time.Microsecond, duration)
+	// 290 years fit in a time.Duration, 300 years do not
+	duration, err = Interval{Days: 290 * 365}.ToDuration()
+	assert.Nil(t, err)
+	assert.Equal(t, 290*365*24*time.Hour, duration)
+	_, err = Interval{Days: 300 * 365}.ToDuration()
+	var conversionErr *IntervalConversionError
+	assert.True(t, errors.As(err, &conversionErr))
+	assert.True(t, conversionErr.Overflow)
+	assert.False(t, errors.Is(err, ErrLossyIntervalConversion))
+	_, err = Interval{Days: math.MinInt32}.ToDuration()
+	assert.NotNil(t, err)
+	_, err = Interval{Days: 1, Micros: math.MaxInt64}.ToDuration()
+	assert.NotNil(t, err)
+	_, err = Interval{Months: 1}.ToDuration()
+	assert.True(t, errors.Is(err, ErrLossyIntervalConversion))
+}
+
 func TestList(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	res, error := conn.Query("RETURN [[1, 2, 3], [4, 5, 6]]")
 	assert.Nil(t, error)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, []interface{}{int64(1), int64(2), int64(3)}, value.([]interface{})[0])
 	assert.Equal(t, []interface{}{int64(4), int64(5), int64(6)}, value.([]interface{})[1])
@@ -322,8 +488,10 @@ func TestArray(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	res, error := conn.Query("RETURN CAST([3, 4, 12, 11], 'INT64[4]')")
 	assert.Nil(t, error)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, []interface{}{int64(3), int64(4), int64(12), int64(11)}, value)
 }
@@ -332,8 +500,10 @@ func TestStruct(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	res, error := conn.Query("RETURN {name: 'Alice', age: 30}")
 	assert.Nil(t, error)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.Equal(t, "Alice", value.(map[string]interface{})["name"])
 	assert.Equal(t, int64(30), value.(map[string]interface{})["age"])
@@ -343,8 +513,10 @@ func TestMap(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	res, error := conn.Query("MATCH (m:movies) WHERE m.length = 2544 RETURN m.audience")
 	assert.Nil(t, error)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	valueList := value.([]MapItem)
 	size := len(valueList)
@@ -357,8 +529,10 @@ func TestDecimal(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	res, error := conn.Query("UNWIND [1] AS A UNWIND [5.7, 8.3, 8.7, 13.7] AS B WITH cast(CAST(A AS DECIMAL) * CAST(B AS DECIMAL) AS DECIMAL(18, 1)) AS PROD RETURN COLLECT(PROD) AS RES")
 	assert.Nil(t, error)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	valueList, _ := value.([]interface{})
 	size := len(valueList)
@@ -374,8 +548,10 @@ func TestUnion(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	res, error := conn.Query("MATCH (m:movies) WHERE m.length = 2544 RETURN m.grade;")
 	assert.Nil(t, error)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	assert.InDelta(t, float64(8.989), value.(map[string]any)["credit"], floatEpsilon)
 }
@@ -386,6 +562,7 @@ func TestNode(t *testing.T) {
 	assert.Nil(t, error)
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	node := value.(Node)
 	assert.Equal(t, "person", node.Label)
@@ -423,8 +600,10 @@ func TestRelationship(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	res, error := conn.Query("MATCH (p:person)-[r:workAt]->(o:organisation) WHERE p.ID = 5 RETURN p, r, o")
 	assert.Nil(t, error)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	m, err := next.GetAsMap()
 	assert.Nil(t, err)
 	rel := m["r"].(Relationship)
@@ -441,8 +620,10 @@ func TestRecursiveRel(t *testing.T) {
 	_, conn := SetupTestDatabase(t)
 	res, error := conn.Query("MATCH (a:person)-[e:studyAt*1..1]->(b:organisation) WHERE a.fName = 'Alice' RETURN e;")
 	assert.Nil(t, error)
+	defer res.Close()
 	assert.True(t, res.HasNext())
 	next, _ := res.Next()
+	defer next.Close()
 	value, _ := next.GetValue(0)
 	recursiveRel := value.(RecursiveRelationship)
 	assert.Equal(t, len(recursiveRel.Nodes), 0)
@@ -452,3 +633,63 @@ func TestRecursiveRel(t *testing.T) {
 	assert.Equal(t, int16(5), rel.Properties["length"])
 	assert.Equal(t, int64(2021), rel.Properties["year"])
 }
+
+func TestEmptyVersusNullContainers(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	cases := []struct {
+		name     string
+		query    string
+		expected any
+		json     string
+	}{
+		{"empty LIST", "RETURN CAST([], 'INT64[]') AS v", []any{}, "[]"},
+		{"NULL LIST", "RETURN CAST(NULL, 'INT64[]') AS v", nil, "null"},
+		{"ARRAY of empty LISTs", "RETURN CAST([[], []], 'INT64[][2]') AS v", []any{[]any{}, []any{}}, "[[],[]]"},
+		{"NULL ARRAY", "RETURN CAST(NULL, 'INT64[4]') AS v", nil, "null"},
+		{"empty MAP", "RETURN map(CAST([], 'STRING[]'), CAST([], 'INT64[]')) AS v", []MapItem{}, "[]"},
+		{"NULL MAP", "RETURN CAST(NULL, 'MAP(STRING, INT64)') AS v", nil, "null"},
+		{"STRUCT of empty and NULL LISTs", "RETURN {a: CAST([], 'INT64[]'), b: CAST(NULL, 'INT64[]')} AS v",
+			map[string]any{"a": []any{}, "b": nil}, `{"a":[],"b":null}`},
+		{"NULL STRUCT", "RETURN CAST(NULL, 'STRUCT(a INT64[])') AS v", nil, "null"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := conn.Query(c.query)
+			assert.Nil(t, err)
+			defer res.Close()
+			tuple, err := res.Next()
+			assert.Nil(t, err)
+			defer tuple.Close()
+			value, err := tuple.GetValue(0)
+			assert.Nil(t, err)
+			assert.Equal(t, c.expected, value)
+			encoded, err := json.Marshal(value)
+			assert.Nil(t, err)
+			assert.Equal(t, c.json, string(encoded))
+			values, err := tuple.GetAsMap()
+			assert.Nil(t, err)
+			assert.Equal(t, c.expected, values["v"])
+		})
+	}
+}
+
+func TestNilContainerParamsBindAsNull(t *testing.T) {
+	_, conn := SetupTestDatabase(t)
+	stmt, err := conn.Prepare("RETURN $v IS NULL")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	for _, value := range []any{[]string(nil), []any(nil), map[string]any(nil), []MapItem(nil), json.RawMessage(nil)} {
+		res, err := conn.Execute(stmt, map[string]any{"v": value})
+		assert.Nil(t, err)
+		tuple, err := res.Next()
+		assert.Nil(t, err)
+		isNull, err := tuple.GetValue(0)
+		assert.Nil(t, err)
+		assert.Equal(t, true, isNull, "%T", value)
+		tuple.Close()
+		res.Close()
+	}
+	// Empty containers cannot be bound as the C API cannot create them.
+	_, err = conn.Execute(stmt, map[string]any{"v": []string{}})
+	assert.ErrorContains(t, err, "slice is empty")
+}